@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/api"
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+var apiCmd = &cobra.Command{
+	Use:   "api",
+	Short: "Control API for orchestrators",
+}
+
+var apiServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the control API on a unix socket",
+	Long: `Serve a JSON-RPC 2.0 API (one message per line) on a unix socket so CI
+systems and other orchestrators can drive think: submit runs, stream their
+output and events, answer approval prompts remotely, and fetch results.
+
+Clients authenticate first with {"method":"auth","params":{"token":...}}.
+The token is $THINKINGSCRIPT_API_TOKEN, or a random one written next to the
+socket as <socket>.token (mode 0600).
+
+Methods: run.submit, run.list, run.get, run.events, run.wait, run.cancel,
+approval.answer. Runs are separate think processes; their prompts appear
+as "prompt" events and wait for approval.answer.
+
+Examples:
+  think api serve --socket /tmp/think.sock`,
+	Args:         cobra.NoArgs,
+	RunE:         runAPIServe,
+	SilenceUsage: true,
+}
+
+var apiSocketFlag string
+
+func init() {
+	apiServeCmd.Flags().StringVar(&apiSocketFlag, "socket", "", "Unix socket to listen on (default ~/.thinkingscript/api.sock)")
+	apiCmd.AddCommand(apiServeCmd)
+	rootCmd.AddCommand(apiCmd)
+}
+
+func runAPIServe(cmd *cobra.Command, args []string) error {
+	if err := config.EnsureHomeDir(); err != nil {
+		return fmt.Errorf("setting up home directory: %w", err)
+	}
+	socket := apiSocketFlag
+	if socket == "" {
+		socket = filepath.Join(config.HomeDir(), "api.sock")
+	}
+	socket, err := filepath.Abs(socket)
+	if err != nil {
+		return err
+	}
+
+	l, err := api.Listen(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	token := os.Getenv(api.TokenEnv)
+	tokenNote := "$" + api.TokenEnv
+	if token == "" {
+		token = api.NewToken()
+		tokenFile := socket + ".token"
+		if err := api.WriteToken(tokenFile, token); err != nil {
+			l.Close()
+			return fmt.Errorf("writing token: %w", err)
+		}
+		defer os.Remove(tokenFile)
+		tokenNote = tokenFile
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "listening on %s (token: %s)\n", socket, tokenNote)
+	server := &api.Server{Token: token, Socket: socket}
+	return server.Serve(ctx, l)
+}
+
+// connectAPIPrompter sends the approver's prompts to the `think api serve`
+// that started this run, if any. Without a connection every prompt is
+// denied, as with no TTY. Call the returned function when the run ends.
+func connectAPIPrompter(approver *approval.Approver) func() {
+	socket := os.Getenv(api.SocketEnv)
+	if socket == "" {
+		return func() {}
+	}
+	prompter, err := api.DialPrompter(socket, os.Getenv(api.RunTokenEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: api prompts unavailable: %v\n", err)
+		return func() {}
+	}
+	approver.SetPrompter(prompter)
+	return func() { prompter.Close() }
+}
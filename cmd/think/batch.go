@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/boot"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// runMap runs memory.js once per input (as the sole process.args entry) in
+// parallel sandboxes (boot.Map), then prints each input's stdout in input
+// order. Inputs that resume or fail are handed to runAgent at their
+// position in the output, so ordering is preserved end to end.
+func runMap(ctx context.Context, cfg boot.Config, name string, inputs []string, jobs int, runAgent func(input, resumeContext string) error) error {
+	if len(inputs) == 0 {
+		return errors.New("--map requires at least one input argument")
+	}
+
+	dotStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("82")) // Green for memory.js
+	nameStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
+	fileStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	resumeStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+	cfg.OnRun = func(string) func() {
+		fmt.Fprintf(os.Stderr, "%s %s %s\n", dotStyle.Render("■"), nameStyle.Render(name), fileStyle.Render(fmt.Sprintf("memory.js (%d inputs, %d jobs)", len(inputs), max(jobs, 1))))
+		return func() {}
+	}
+
+	results, fail := boot.Map(ctx, cfg, inputs, jobs)
+	if fail != nil {
+		// No memory.js yet, or it can't run: let the agent handle the
+		// first input (and fix memory.js), then fan out the rest.
+		if !os.IsNotExist(fail.Err) {
+			fmt.Fprintf(os.Stderr, "  %s %s\n", resumeStyle.Render("↳ resumed "+inputs[0]+":"), fileStyle.Render(fail.ResumeContext))
+		}
+		if err := runAgent(inputs[0], fail.ResumeContext); err != nil {
+			return err
+		}
+		if len(inputs) == 1 {
+			return nil
+		}
+		return runMap(ctx, cfg, name, inputs[1:], jobs, runAgent)
+	}
+
+	for i, res := range results {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if res.Success {
+			fmt.Fprint(os.Stdout, res.Output)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  %s %s\n", resumeStyle.Render("↳ resumed "+inputs[i]+":"), fileStyle.Render(res.ResumeContext))
+		if err := runAgent(inputs[i], res.ResumeContext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
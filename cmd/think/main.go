@@ -2,9 +2,22 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"os"
+
+	"github.com/thinkingscript/cli/internal/backend"
 )
 
 func main() {
+	// Inside a container backend, think only runs the host's sandbox
+	if os.Getenv(backend.ChildEnv) == "1" {
+		if err := backend.ServeChild(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// No SIGINT interception. Ctrl+C outside of raw-mode prompts
 	// generates SIGINT and the OS kills us immediately.
 	// During raw-mode prompts (huh), Ctrl+C is handled as a keypress
@@ -1,24 +1,48 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/agent"
 	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/backend"
+	"github.com/thinkingscript/cli/internal/boot"
+	"github.com/thinkingscript/cli/internal/codecheck"
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/fastpath"
+	"github.com/thinkingscript/cli/internal/fetchguard"
+	"github.com/thinkingscript/cli/internal/gitstate"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/lint"
+	"github.com/thinkingscript/cli/internal/lockfile"
+	"github.com/thinkingscript/cli/internal/managed"
 	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/runlog"
 	"github.com/thinkingscript/cli/internal/sandbox"
 	"github.com/thinkingscript/cli/internal/script"
+	"github.com/thinkingscript/cli/internal/shared"
+	"github.com/thinkingscript/cli/internal/snapshot"
 	"github.com/thinkingscript/cli/internal/tools"
 	"github.com/thinkingscript/cli/internal/ui"
+	"github.com/thinkingscript/cli/internal/usage"
+	"github.com/thinkingscript/cli/internal/workspace"
 	"golang.org/x/term"
 )
 
@@ -37,8 +61,53 @@ func execute(ctx context.Context) {
 	}
 }
 
+var (
+	readOnlyFlag   bool
+	cwdFlag        string
+	allowFlag      []string
+	writeFlag      []string
+	savePolicyFlag bool
+	noMemoizeFlag  bool
+	mapFlag        bool
+	jobsFlag       int
+	backendFlag    string
+	noFastPathFlag bool
+	explainFlag    bool
+	profileFlag    bool
+	resumeFlag     bool
+	noAgentFlag    bool
+	yesFlag        bool
+	noFlag         bool
+	policyOnlyFlag bool
+
+	showPromptFlag     string // "-" = stderr
+	showPromptOnlyFlag bool
+)
+
 func init() {
+	// Script names must not collide with generated subcommands
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.Flags().SetInterspersed(false)
+	rootCmd.Flags().BoolVar(&readOnlyFlag, "read-only", false, "Analysis mode: reject all filesystem writes and deletes, including workspace and memory.js")
+	rootCmd.Flags().StringVar(&cwdFlag, "cwd", "", "Working directory for the sandbox (overrides frontmatter workdir)")
+	rootCmd.Flags().StringArrayVar(&allowFlag, "allow", nil, "Grant read access to a path for this run (repeatable)")
+	rootCmd.Flags().StringArrayVar(&writeFlag, "write", nil, "Grant read/write access to a path for this run (repeatable)")
+	rootCmd.Flags().BoolVar(&savePolicyFlag, "save-policy", false, "Persist --allow/--write grants to the thought's policy.json")
+	rootCmd.Flags().BoolVar(&noMemoizeFlag, "no-memoize", false, "Ignore frontmatter memoize and always run")
+	rootCmd.Flags().BoolVar(&mapFlag, "map", false, "Batch mode: run memory.js once per argument in parallel, output in argument order")
+	rootCmd.Flags().IntVarP(&jobsFlag, "jobs", "j", runtime.NumCPU(), "Parallel workers for --map")
+	rootCmd.Flags().BoolVar(&noFastPathFlag, "no-fast-path", false, "Always use the agent for a first run, even for trivial prompts")
+	rootCmd.Flags().BoolVar(&explainFlag, "explain", false, "Show the agent's plan and ask before it runs (no tools run until you approve)")
+	rootCmd.Flags().StringVar(&showPromptFlag, "show-prompt", "", "Write the agent's system prompt and user message, secrets redacted, to a file (default stderr) before calling the API")
+	rootCmd.Flags().Lookup("show-prompt").NoOptDefVal = "-"
+	rootCmd.Flags().BoolVar(&showPromptOnlyFlag, "show-prompt-only", false, "Like --show-prompt, then exit without calling the API")
+	rootCmd.Flags().BoolVar(&profileFlag, "profile", false, "Time model calls and sandbox bridge calls, and print a summary when the run ends")
+	rootCmd.Flags().BoolVar(&resumeFlag, "resume", false, "Continue the thought's interrupted agent run from its last saved turn")
+	rootCmd.Flags().BoolVar(&noAgentFlag, "no-agent", false, "Run memory.js only; fail instead of starting the agent when it hands over")
+	rootCmd.Flags().BoolVar(&yesFlag, "yes", false, "Allow, without asking, every request no policy decides (this run only)")
+	rootCmd.Flags().BoolVar(&noFlag, "no", false, "Deny, without asking, every request no policy decides")
+	rootCmd.Flags().BoolVar(&policyOnlyFlag, "policy-only", false, "Never ask: requests no policy decides fail with an error")
+	rootCmd.Flags().StringVar(&backendFlag, "backend", "", "Where sandboxes run: process, docker, or podman (overrides config.json backend)")
 }
 
 // cacheMode returns the cache behavior: "persist" (default), "ephemeral", or "off".
@@ -53,7 +122,33 @@ func cacheMode() string {
 	}
 }
 
-func runScript(cmd *cobra.Command, args []string) error {
+// approvalMode returns how requests no policy decides are answered:
+// --yes, --no, or --policy-only, else THINKINGSCRIPT__APPROVALS (allow,
+// deny, or policy), else prompting.
+func approvalMode() (approval.Mode, error) {
+	var modes []approval.Mode
+	for _, f := range []struct {
+		set  bool
+		mode approval.Mode
+	}{{yesFlag, approval.ModeAllow}, {noFlag, approval.ModeDeny}, {policyOnlyFlag, approval.ModePolicy}} {
+		if f.set {
+			modes = append(modes, f.mode)
+		}
+	}
+	switch len(modes) {
+	case 0:
+		mode, err := approval.ParseMode(os.Getenv("THINKINGSCRIPT__APPROVALS"))
+		if err != nil {
+			return "", fmt.Errorf("THINKINGSCRIPT__APPROVALS: %w", err)
+		}
+		return mode, nil
+	case 1:
+		return modes[0], nil
+	}
+	return "", fmt.Errorf("--yes, --no, and --policy-only can't be combined")
+}
+
+func runScript(cmd *cobra.Command, args []string) (runErr error) {
 	scriptPath := args[0]
 	mode := cacheMode()
 
@@ -64,7 +159,18 @@ func runScript(cmd *cobra.Command, args []string) error {
 	}
 
 	// Resolve configuration
+	if err := config.ValidateSampling(parsed.Config); err != nil {
+		return err
+	}
 	resolved := config.Resolve(parsed.Config)
+	if resolved.CredentialHelper != "" {
+		// Short-lived key for this run only; held in memory, never persisted.
+		key, err := config.FetchCredential(cmd.Context(), resolved.CredentialHelper, resolved.Agent, resolved.Provider)
+		if err != nil {
+			return err
+		}
+		resolved.APIKey = key
+	}
 
 	// Ensure home directory exists
 	if err := config.EnsureHomeDir(); err != nil {
@@ -98,9 +204,32 @@ func runScript(cmd *cobra.Command, args []string) error {
 		defer os.RemoveAll(cacheDir)
 	}
 
-	// Read stdin if piped
+	// Read stdin if piped (stream mode reads it line by line later)
+	streamStdin := parsed.Config != nil && parsed.Config.Stdin == "stream"
+	if explainFlag && (streamStdin || mapFlag) {
+		return fmt.Errorf("--explain works on single runs, not --map or stdin: stream")
+	}
+	if showPromptOnlyFlag && showPromptFlag == "" {
+		showPromptFlag = "-"
+	}
+	if showPromptFlag != "" && (streamStdin || mapFlag) {
+		return fmt.Errorf("--show-prompt works on single runs, not --map or stdin: stream")
+	}
+	if resumeFlag && (streamStdin || mapFlag) {
+		return fmt.Errorf("--resume works on single runs, not --map or stdin: stream")
+	}
+	if resumeFlag && (explainFlag || showPromptFlag != "") {
+		return fmt.Errorf("--resume continues a conversation; --explain and --show-prompt only apply to a new one")
+	}
+	if noAgentFlag && (resumeFlag || explainFlag || showPromptFlag != "") {
+		return fmt.Errorf("--no-agent never starts the agent; --resume, --explain, and --show-prompt need it")
+	}
+	approvals, err := approvalMode()
+	if err != nil {
+		return err
+	}
 	stdinData := ""
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
+	if !streamStdin && !term.IsTerminal(int(os.Stdin.Fd())) {
 		data, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return fmt.Errorf("reading stdin: %w", err)
@@ -108,97 +237,546 @@ func runScript(cmd *cobra.Command, args []string) error {
 		stdinData = string(data)
 	}
 
+	// The thought directory is keyed by frontmatter name, or by the file
+	// name unless a different script already owns that directory
+	thoughtName := ""
+	if parsed.Config != nil && parsed.Config.Name != "" {
+		thoughtName = parsed.Config.Name
+		if err := config.ValidateThoughtName(thoughtName); err != nil {
+			return err
+		}
+	}
+	located, conflict := config.LocateThought(scriptPath, thoughtName)
+	if conflict != "" {
+		fmt.Fprintf(os.Stderr, "warning: thought %q belongs to %s; using %s for this script.\n  Set \"name:\" in the frontmatter, or 'thought rename' one of them, to choose a name.\n",
+			config.ThoughtName(scriptPath), conflict, filepath.Base(located))
+	}
+	thoughtDir, _ := filepath.Abs(located)
+
 	// Set up sandbox paths — resolve to absolute so the LLM sees full paths
-	workDir, _ := os.Getwd()
-	thoughtDir, _ := filepath.Abs(config.ThoughtDir(scriptPath))
-	workspaceDir, _ := filepath.Abs(config.WorkspaceDir(scriptPath))
-	memoriesDir, _ := filepath.Abs(config.MemoriesDir(scriptPath))
-	memoryJSPath, _ := filepath.Abs(config.MemoryJSPath(scriptPath))
+	workDir, err := resolveWorkDir(parsed, thoughtDir)
+	if err != nil {
+		return err
+	}
+
+	// Extra paths granted on the command line. Writable paths are readable too.
+	readPaths, err := resolveGrantPaths(allowFlag)
+	if err != nil {
+		return err
+	}
+	writePaths, err := resolveGrantPaths(writeFlag)
+	if err != nil {
+		return err
+	}
+	// SECURITY: --write must never make a policy file writable
+	globalPolicyPath, _ := filepath.Abs(filepath.Join(config.HomeDir(), "policy.json"))
+	for _, p := range writePaths {
+		for _, policyPath := range []string{filepath.Join(thoughtDir, "policy.json"), globalPolicyPath} {
+			if policyPath == p || strings.HasPrefix(policyPath, p+string(filepath.Separator)) {
+				return fmt.Errorf("--write %s would expose %s", p, policyPath)
+			}
+		}
+	}
+	allowPaths := append(append([]string{}, readPaths...), writePaths...)
+
+	// Memoized output: converged runs with identical args, stdin, working
+	// directory, and granted paths replay their cached stdout within the
+	// TTL. --explain and --show-prompt need a real run.
+	var memoTTL time.Duration
+	memoKey := config.MemoKey(args[1:], stdinData, workDir, readPaths, writePaths)
+	if parsed.Config != nil && parsed.Config.Memoize != "" && !noMemoizeFlag && !streamStdin && !mapFlag && !resumeFlag && !explainFlag && showPromptFlag == "" && mode != "off" {
+		memoTTL, err = time.ParseDuration(parsed.Config.Memoize)
+		if err != nil {
+			return fmt.Errorf("invalid memoize duration %q: %w", parsed.Config.Memoize, err)
+		}
+		if out, ok := config.LoadMemo(cacheDir, memoKey, memoTTL); ok {
+			fmt.Fprint(os.Stdout, out)
+			return nil
+		}
+	}
+
+	// A frozen thought ('thought freeze') runs memory.js only
+	frozen := config.LoadFrozen(thoughtDir) != nil
+	if frozen {
+		switch {
+		case resumeFlag:
+			return config.ErrFrozen(thoughtDir, "--resume needs it")
+		case explainFlag:
+			return config.ErrFrozen(thoughtDir, "--explain needs it")
+		case showPromptFlag != "":
+			return config.ErrFrozen(thoughtDir, "--show-prompt needs it")
+		}
+	}
+	// handOver stops a run that memory.js didn't handle before it gets to
+	// the agent, for --no-agent and frozen thoughts; nil means go ahead
+	handOver := func(resumeContext string) error {
+		switch {
+		case frozen:
+			return config.ErrFrozen(thoughtDir, resumeContext)
+		case noAgentFlag:
+			return errHandedOver(resumeContext)
+		}
+		return nil
+	}
+
+	// --resume picks up the conversation an interrupted agent run saved,
+	// with that run's arguments
+	var resumed *agent.Session
+	if resumeFlag {
+		resumed, err = agent.LoadSession(thoughtDir)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s has no interrupted agent run to resume", filepath.Base(thoughtDir))
+		}
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 && !slices.Equal(args[1:], resumed.Args) {
+			return fmt.Errorf("--resume continues the interrupted run with its own arguments (%q); leave them off", resumed.Args)
+		}
+		args = append([]string{args[0]}, resumed.Args...)
+	}
+
+	// data_dir moves memory.js, workspace, and memories (e.g. into the
+	// project repo); policy and run history stay in the thought directory
+	dataDir := thoughtDir
+	if parsed.Config != nil && parsed.Config.DataDir != "" {
+		dataDir, err = config.ResolveDataDir(parsed.Config.DataDir, scriptPath, thoughtDir)
+		if err != nil {
+			return err
+		}
+	}
+	workspaceDir := filepath.Join(dataDir, "workspace")
+	memoriesDir := filepath.Join(dataDir, "memories")
+	memoryJSPath := filepath.Join(dataDir, "memory.js")
 	os.MkdirAll(workspaceDir, 0700)
 	os.MkdirAll(memoriesDir, 0700)
+	if err := config.ClaimThought(thoughtDir, scriptPath); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record thought identity: %v\n", err)
+	}
+	if prev := config.ThoughtDataDir(thoughtDir); prev != dataDir {
+		// Default grants follow the data to its new root
+		if err := moveDataPolicy(filepath.Join(thoughtDir, "policy.json"), prev, dataDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to update policy for data_dir: %v\n", err)
+		}
+	}
+	if err := config.SaveDataDir(thoughtDir, dataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record data_dir: %v\n", err)
+	}
+
+	// thought_path: a read-only shared copy of this thought (e.g. installed
+	// by an admin) sits under the user's own. Its memory.js runs until this
+	// user's run writes one; workspace and memories are copied up when the
+	// user's are empty, and its policy seeds a new thought policy below.
+	sharedDir := shared.Find(filepath.Base(thoughtDir), config.LoadConfig().ThoughtPath)
+	if sharedDir != "" && !readOnlyFlag {
+		for _, sub := range []string{"workspace", "memories"} {
+			if _, err := shared.CopyUp(filepath.Join(dataDir, sub), filepath.Join(sharedDir, sub)); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: failed to copy shared %s: %v\n", sub, err)
+			}
+		}
+	}
+	loadMemoryJS := func() ([]byte, error) {
+		return shared.ReadMemoryJS(memoryJSPath, sharedDir)
+	}
+
+	// git: commit memory.js and memories after every run that changes them,
+	// so 'thought diff' can show what the agent did
+	runKind := "memory.js"
+	runModel := resolved.Model
+	if !readOnlyFlag && ((parsed.Config != nil && parsed.Config.Git) || config.LoadConfig().Git) {
+		started := time.Now()
+		repo, err := gitstate.Open(dataDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: not tracking thought in git: %v\n", err)
+		} else {
+			defer func() {
+				msg := runCommitMessage(filepath.Base(thoughtDir), runKind, scriptPath, runModel, time.Since(started), runErr)
+				if _, err := repo.Commit(msg); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: failed to commit thought state: %v\n", err)
+				}
+			}()
+		}
+	}
+
+	// workspace: per-run swaps in a fresh workspace for this run. Paths
+	// passed to fs.promote are copied into the persistent one on success.
+	persistentDir := workspaceDir
+	var wsRun *workspace.Run
+	if parsed.Config != nil && !workspace.ValidMode(parsed.Config.Workspace) {
+		return fmt.Errorf("invalid workspace %q (must be %q or %q)", parsed.Config.Workspace, workspace.ModePersistent, workspace.ModePerRun)
+	}
+	if parsed.Config != nil && parsed.Config.Workspace == workspace.ModePerRun {
+		wsRun, err = workspace.NewRun(thoughtDir, persistentDir)
+		if err != nil {
+			return fmt.Errorf("creating run workspace: %w", err)
+		}
+		defer func() {
+			if runErr == nil {
+				if _, err := wsRun.Commit(); err != nil {
+					runErr = fmt.Errorf("promoting workspace results: %w", err)
+				}
+			}
+			wsRun.Close()
+		}()
+		workspaceDir = wsRun.Dir()
+	}
+
+	// eval: whether scripts may compile strings into code (eval, Function)
+	evalMode := ""
+	if parsed.Config != nil {
+		evalMode = parsed.Config.Eval
+	}
+	if !sandbox.ValidEval(evalMode) {
+		return fmt.Errorf("invalid eval %q (must be %q, %q, or %q)", evalMode, sandbox.EvalAfterFetch, sandbox.EvalAllow, sandbox.EvalDeny)
+	}
+
+	// Every fs mutation this run makes is journaled for 'thought undo'
+	jrnl := journal.New(thoughtDir)
+
+	// The workspace is snapshotted once, the first time the agent takes
+	// over, so 'thought restore' can roll back whatever it decides to do
+	snapshotOnce := sync.OnceFunc(func() {
+		if !readOnlyFlag {
+			snapshotWorkspace(thoughtDir, persistentDir)
+		}
+	})
+
+	// Pick where sandboxes run
+	sandboxBackend, err := selectBackend()
+	if err != nil {
+		return err
+	}
+	// Streams keep one runtime across lines, so they only run in-process;
+	// running one there would drop the isolation the backend was chosen for
+	if streamStdin && sandboxBackend != backend.InProcess {
+		return fmt.Errorf("stdin: stream runs memory.js in-process, so it can't use --backend %s; use --backend process or drop stdin: stream", sandboxBackend.Name())
+	}
+
+	// The run is recorded in last-run/ for 'thought report'
+	recorder, err := runlog.Start(thoughtDir, runlog.Run{
+		Thought:  filepath.Base(thoughtDir),
+		Script:   scriptPath,
+		Agent:    resolved.Agent,
+		Provider: resolved.Provider,
+		Backend:  sandboxBackend.Name(),
+		Args:     args[1:],
+		Stdin:    stdinData,
+		Started:  time.Now(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: not recording this run: %v\n", err)
+	}
+	defer func() { recorder.Finish(runKind, runModel, runErr) }()
+
+	// Every provider call's tokens are counted, summarized when the run
+	// ends, and kept in runs/usage.json
+	// (and with --profile, every bridge call is timed)
+	meter := provider.NewMeter()
+	var profile *sandbox.Profile
+	if profileFlag {
+		profile = sandbox.NewProfile()
+	}
+	// Every agent's registry, for the schema failures they counted
+	var registries []*tools.Registry
+	usageStarted := time.Now()
+	defer func() {
+		rec := usageRecord(meter, profile, resolved)
+		for _, r := range registries {
+			rec.SchemaFailures += r.Stats().SchemaFailures
+		}
+		finishUsage(thoughtDir, recorder, rec, profile != nil, runKind, usageStarted, runErr)
+	}()
 
 	// Set up approval system
-	globalPolicyPath, _ := filepath.Abs(filepath.Join(config.HomeDir(), "policy.json"))
+	_, policyErr := os.Stat(filepath.Join(thoughtDir, "policy.json"))
 	approver := approval.NewApprover(thoughtDir, globalPolicyPath)
 	defer approver.Close()
+	defer connectAPIPrompter(approver)()
+	if err := loadManagedPolicy(cmd.Context(), approver); err != nil {
+		return err
+	}
+	if approvals != approval.ModePrompt {
+		approver.SetMode(approvals, func(kind, target string, mode approval.Mode) {
+			recorder.AutoApproval(kind, target, string(mode))
+		})
+	}
+
+	// An organization's check on agent code; a broken config stops the run
+	// rather than running unchecked
+	codeCheck, err := codecheck.FromConfig(config.LoadConfig().CodeCheck)
+	if err != nil {
+		return err
+	}
+	linter, err := lint.New(config.LoadConfig().Lint)
+	if err != nil {
+		return err
+	}
+	fetchGuard, err := fetchguard.FromConfig(config.LoadConfig().FetchGuard)
+	if err != nil {
+		return err
+	}
+
+	// Apply trust defaults for where this thought came from (local or url)
+	trust := config.TrustFor(config.ResolveOrigin(scriptPath, thoughtDir))
+	approver.SetContext(cmd.Context())
+	approver.SetOriginDefaults(approval.OriginDefaults{
+		Paths: approval.Approval(trust.Paths),
+		Env:   approval.Approval(trust.Env),
+		Net:   approval.Approval(trust.Net),
+	})
 
 	// Bootstrap default policy entries for workspace, memories, and CWD
-	approver.BootstrapDefaults(workspaceDir, memoriesDir, workDir)
+	approver.BootstrapDefaults(persistentDir, memoriesDir, workDir)
+	if sharedDir != "" && os.IsNotExist(policyErr) {
+		if p, err := shared.Policy(sharedDir, dataDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring shared policy: %v\n", err)
+		} else if p != nil {
+			approver.SeedPolicy(p)
+		}
+	}
 
-	// Try memory.js first (static execution without agent)
-	resumeContext := ""
-	if _, err := os.Stat(memoryJSPath); err == nil {
-		code, err := os.ReadFile(memoryJSPath)
+	if savePolicyFlag {
+		for _, p := range writePaths {
+			approver.GrantPath(p, "rwd")
+		}
+		for _, p := range readPaths {
+			approver.GrantPath(p, "r")
+		}
+	}
+
+	// MCP servers from the frontmatter start the first time the agent
+	// takes over, once per run, and stop when the run ends
+	var mcpServers *tools.MCPServers
+	defer func() { mcpServers.Close() }()
+	connectMCP := sync.OnceValue(func() error {
+		if parsed.Config == nil || len(parsed.Config.MCP) == 0 {
+			return nil
+		}
+		var err error
+		mcpServers, err = tools.ConnectMCP(cmd.Context(), approver, parsed.Config.MCP, workDir)
+		return err
+	})
+
+	// Every agent path gets the same tools
+	regCfg := tools.RegistryConfig{
+		Approver:     approver,
+		WorkDir:      workDir,
+		ThoughtDir:   thoughtDir,
+		WorkspaceDir: workspaceDir,
+		MemoriesDir:  memoriesDir,
+		MemoryJSPath: memoryJSPath,
+		ScriptName:   scriptPath,
+		ReadOnly:     readOnlyFlag,
+		AllowPaths:   allowPaths,
+		WritePaths:   writePaths,
+	}
+
+	// Secret env.get and secrets.get values the run reads are masked in
+	// console output, tool results, and memories, whichever sandbox read them
+	redactions := redact.New()
+
+	// memory.js runs the same way on every path (internal/boot)
+	bootCfg := boot.Config{
+		MemoryJSPath:  memoryJSPath,
+		LockPath:      lockfile.Path(dataDir),
+		SharedDir:     sharedDir,
+		WorkDir:       workDir,
+		ThoughtDir:    thoughtDir,
+		WorkspaceDir:  workspaceDir,
+		MemoriesDir:   memoriesDir,
+		Args:          args[1:],
+		ApprovePath:   approver.ApprovePath,
+		PathDenied:    approver.PathDenied,
+		ApproveEnv:    approver.ApproveEnvRead,
+		ApproveNet:    approver.ApproveNet,
+		ApproveSecret: approver.ApproveSecret,
+		Journal:       jrnl,
+		Workspace:     wsRun,
+		ReadOnly:      readOnlyFlag,
+		Eval:          evalMode,
+		AllowPaths:    allowPaths,
+		WritePaths:    writePaths,
+		Profile:       profile,
+		Redact:        redactions,
+		Protected:     approver.ProtectedPaths(),
+		Review: func(code string) error {
+			// The agent rewrites memory.js without whatever lint blocked
+			_, err := linter.Review(code, "memory.js", approver.Confirm)
+			return err
+		},
+	}
+
+	// newAgent builds an agent with the run's tools and settings; the
+	// stream, map, and main paths all start theirs here
+	newAgent := func(model, resumeContext string) (*agent.Agent, error) {
+		registry := tools.NewRegistry(regCfg)
+		registries = append(registries, registry)
+		registry.SetJournal(jrnl)
+		registry.SetWorkspaceRun(wsRun)
+		registry.SetBackend(recorder.Wrap(sandboxBackend, "run_script"))
+		registry.SetCodeCheck(codeCheck)
+		registry.SetLinter(linter)
+		registry.SetFetchGuard(fetchGuard)
+		registry.SetEval(evalMode)
+		registry.SetProfile(profile)
+		registry.SetRedact(redactions)
+		registry.SetParallelism(resolved.ParallelTools)
+		if err := connectMCP(); err != nil {
+			return nil, err
+		}
+		registry.SetMCP(mcpServers)
+		p, err := createProvider(resolved, meter)
 		if err != nil {
-			resumeContext = fmt.Sprintf("failed to read memory.js: %s", err)
-		} else {
-			// SECURITY: ThoughtDir is readable but NOT writable (protects policy.json)
-			// Only memory.js, workspace, and memories are writable
-			sb, err := sandbox.New(sandbox.Config{
-				AllowedPaths:  []string{workDir, thoughtDir, workspaceDir, memoriesDir},
-				WritablePaths: []string{workspaceDir, memoriesDir, memoryJSPath},
-				WorkDir:       workDir,
-				Stderr:        os.Stderr,
-				Args:          args[1:],
-				ApprovePath:   approver.ApprovePath,
-				ApproveEnv:    approver.ApproveEnvRead,
-				ApproveNet:    approver.ApproveNet,
-			})
-			if err != nil {
-				resumeContext = fmt.Sprintf("failed to create sandbox: %s", err)
-			} else {
-				// Show memory.js execution
-				scriptName := config.ThoughtName(scriptPath)
-				dotStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("82")) // Green for memory.js
-				nameStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
-				fileStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
-				fmt.Fprintf(os.Stderr, "%s %s %s\n", dotStyle.Render("■"), nameStyle.Render(scriptName), fileStyle.Render("memory.js"))
-
-				stopSpinner := ui.Spinner("Working...")
-				result, err := sb.Run(cmd.Context(), string(code))
-				stopSpinner()
+			return nil, err
+		}
+		a := agent.New(p, registry, model, resolved.MaxTokens, resolved.MaxIterations, scriptPath, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, mode, resumeContext, readOnlyFlag)
+		if wsRun != nil {
+			a.SetPerRunWorkspace(persistentDir)
+		}
+		setCostLimits(a, model, resolved, approver)
+		setBudget(a, meter, resolved)
+		a.SetContextLimit(resolved.ContextLimit)
+		a.SetPruning(resolved.PruneAfter, resolved.PruneKeep, resolved.PruneMinChars)
+		a.SetSampling(resolved.Temperature, resolved.TopP, resolved.Stop)
+		a.SetRecorder(recorder)
+		return a, nil
+	}
 
-				if err == nil {
-					// Success! memory.js handled everything
-					if result != "" {
-						fmt.Fprint(os.Stdout, result)
-					}
-					return nil
-				}
+	if streamStdin {
+		runKind = "stream"
+		return runStream(cmd.Context(), bootCfg, filepath.Base(thoughtDir), func(line, resumeContext string) error {
+			if err := handOver(resumeContext); err != nil {
+				return err
+			}
+			snapshotOnce()
+			prompt := parsed.Prompt + streamPrompt + "\n\nStdin:\n" + line
+			if len(args) > 1 {
+				prompt += "\n\nArguments: " + strings.Join(args[1:], " ")
+			}
+			a, err := newAgent(resolved.Model, resumeContext)
+			if err != nil {
+				return err
+			}
+			return a.Run(cmd.Context(), prompt)
+		})
+	}
 
-				// Check if it's a resume request or an error
-				var resumeErr *sandbox.ResumeError
-				if errors.As(err, &resumeErr) {
-					resumeContext = resumeErr.Context
-					// Show resume indicator (indented under memory.js)
-					resumeStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
-					contextStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
-					if resumeContext != "" {
-						fmt.Fprintf(os.Stderr, "  %s %s\n", resumeStyle.Render("↳ resumed:"), contextStyle.Render(resumeContext))
-					} else {
-						fmt.Fprintf(os.Stderr, "  %s\n", resumeStyle.Render("↳ resumed"))
-					}
-				} else {
-					resumeContext = fmt.Sprintf("memory.js error: %s", err)
-					// Show error indicator (indented under memory.js)
-					errorStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
-					fmt.Fprintf(os.Stderr, "  %s %s\n", errorStyle.Render("↳ error:"), err.Error())
-				}
+	if mapFlag {
+		runKind = "map"
+		mapCfg := bootCfg
+		mapCfg.Backend = recorder.Wrap(sandboxBackend, "memory.js")
+		return runMap(cmd.Context(), mapCfg, filepath.Base(thoughtDir), args[1:], jobsFlag, func(input, resumeContext string) error {
+			if err := handOver(resumeContext); err != nil {
+				return err
 			}
+			snapshotOnce()
+			prompt := parsed.Prompt
+			if stdinData != "" {
+				prompt += "\n\nStdin:\n" + stdinData
+			}
+			prompt += "\n\nArguments: " + input
+			a, err := newAgent(resolved.Model, resumeContext)
+			if err != nil {
+				return err
+			}
+			return a.Run(cmd.Context(), prompt)
+		})
+	}
+
+	// Trivial prompts get memory.js without calling the provider
+	if fastPathEnabled() && !readOnlyFlag && !explainFlag && showPromptFlag == "" && resumed == nil {
+		if _, err := loadMemoryJS(); os.IsNotExist(err) {
+			writeFastPath(parsed.Prompt, memoryJSPath, jrnl)
 		}
-	} else {
-		resumeContext = "no memory.js exists, first run"
 	}
 
-	// Set up tool registry
-	registry := tools.NewRegistry(approver, workDir, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, scriptPath)
+	// Try memory.js first (static execution without agent)
+	resumeContext := ""
+	if resumed != nil {
+		// Straight back to the agent, as the interrupted run was
+		resumeContext = resumed.ResumeContext
+		resumeStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+		contextStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+		fmt.Fprintf(os.Stderr, "%s %s\n", resumeStyle.Render("↻ resuming:"),
+			contextStyle.Render(fmt.Sprintf("%d messages saved %s", len(resumed.Messages), resumed.Updated.Local().Format("2006-01-02 15:04:05"))))
+	} else {
+		// Capture stdout so a successful run can be memoized
+		var memoOut strings.Builder
+		var stdout io.Writer = os.Stdout
+		if memoTTL > 0 {
+			stdout = io.MultiWriter(os.Stdout, &memoOut)
+		}
 
-	// Create provider
-	p, err := createProvider(resolved)
-	if err != nil {
-		return err
+		scriptName := filepath.Base(thoughtDir)
+		dotStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("82")) // Green for memory.js
+		nameStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
+		fileStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+		ran := false
+		cfg := bootCfg
+		cfg.Stdout = stdout
+		cfg.Backend = recorder.Wrap(sandboxBackend, "memory.js")
+		cfg.OnRun = func(code string) func() {
+			// Show memory.js execution
+			ran = true
+			fmt.Fprintf(os.Stderr, "%s %s %s\n", dotStyle.Render("■"), nameStyle.Render(scriptName), fileStyle.Render("memory.js"))
+			approver.SetActivity("running memory.js", code)
+			stopSpinner := ui.Spinner(i18n.T("spinner.working"))
+			return func() {
+				stopSpinner()
+				approver.SetActivity("", "")
+			}
+		}
+		res := boot.TryMemoryJS(cmd.Context(), cfg)
+		resumeContext = res.ResumeContext
+
+		switch {
+		case res.Success:
+			// Success! memory.js handled everything
+			if res.Output != "" {
+				fmt.Fprint(stdout, res.Output)
+			}
+			if memoTTL > 0 {
+				if err := config.SaveMemo(cacheDir, memoKey, memoOut.String()); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: failed to memoize output: %v\n", err)
+				}
+			}
+			if !readOnlyFlag {
+				config.ResetRouteFailures(thoughtDir)
+			}
+			if explainFlag {
+				fmt.Fprintln(os.Stderr, fileStyle.Render("--explain: memory.js handled this run, so the agent had nothing to plan"))
+			}
+			if showPromptFlag != "" {
+				fmt.Fprintln(os.Stderr, fileStyle.Render("--show-prompt: memory.js handled this run, so no prompt was sent"))
+			}
+			return nil
+		case res.Resumed:
+			// Show resume indicator (indented under memory.js)
+			resumeStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+			contextStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+			if resumeContext != "" {
+				fmt.Fprintf(os.Stderr, "  %s %s\n", resumeStyle.Render("↳ resumed:"), contextStyle.Render(resumeContext))
+			} else {
+				fmt.Fprintf(os.Stderr, "  %s\n", resumeStyle.Render("↳ resumed"))
+			}
+		case res.Drift != nil:
+			warnStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+			fmt.Fprintf(os.Stderr, "%s %s\n", warnStyle.Render("■"), fileStyle.Render("thought.lock: memory.js's modules changed, so it didn't run"))
+			for _, d := range res.Drift {
+				fmt.Fprintf(os.Stderr, "  %s %s\n", warnStyle.Render("↳"), d)
+			}
+		case ran:
+			// Show error indicator (indented under memory.js)
+			errorStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
+			fmt.Fprintf(os.Stderr, "  %s %s\n", errorStyle.Render("↳ error:"), res.Err.Error())
+		}
+		if err := handOver(resumeContext); err != nil {
+			return err
+		}
 	}
 
+	snapshotOnce()
+
 	// Build prompt: script content + stdin + CLI arguments
 	prompt := parsed.Prompt
 	if stdinData != "" {
@@ -209,15 +787,429 @@ func runScript(cmd *cobra.Command, args []string) error {
 	}
 
 	// Run agent loop
-	a := agent.New(p, registry, resolved.Model, resolved.MaxTokens, resolved.MaxIterations, scriptPath, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, mode, resumeContext)
+	runKind = "agent"
+	runModel = routeModel(resolved, thoughtDir, resumeContext)
+	a, err := newAgent(runModel, resumeContext)
+	if err != nil {
+		return err
+	}
+	session := resumed
+	if session == nil {
+		session = &agent.Session{Script: scriptPath, Args: args[1:], ResumeContext: resumeContext}
+	}
+	a.SetSession(session)
+	if showPromptFlag != "" {
+		if err := showPrompt(a, prompt, runModel, resolved); err != nil {
+			return err
+		}
+		if showPromptOnlyFlag {
+			return nil
+		}
+	}
+	if explainFlag {
+		if err := explainFirst(cmd.Context(), a, prompt, approver); err != nil {
+			return err
+		}
+	}
+	// Proposals need someone to approve them; don't pay for one otherwise
+	if approver.Interactive() {
+		a.SetMemoryProposals(approver.Confirm, jrnl)
+	}
 	return a.Run(cmd.Context(), prompt)
 }
 
-func createProvider(cfg *config.ResolvedConfig) (provider.Provider, error) {
-	switch cfg.Provider {
-	case "anthropic":
-		return provider.NewAnthropicProvider(cfg.APIKey), nil
-	default:
-		return nil, fmt.Errorf("unsupported provider: %s", cfg.Provider)
+// errHandedOver is the --no-agent failure when memory.js didn't handle
+// the run.
+func errHandedOver(resumeContext string) error {
+	return fmt.Errorf("memory.js didn't handle the run (%s); not starting the agent (--no-agent)", resumeContext)
+}
+
+// routeModel picks the agent's model from config.json "routes" by why the
+// agent is running, falling back to the primary model once the routed one
+// has failed to fix memory.js config.RouteFallbackAfter times in a row.
+func routeModel(resolved *config.ResolvedConfig, thoughtDir, resumeContext string) string {
+	kind := agent.ResumeKind(resumeContext)
+	model, routed := config.Route(resolved, thoughtDir, kind)
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	if !routed {
+		if route := resolved.Routes[kind]; route != "" && route != resolved.Model {
+			fmt.Fprintln(os.Stderr, labelStyle.Render(fmt.Sprintf("model: %s (routes.%s %s failed %d runs in a row)", model, kind, route, config.RouteFallbackAfter)))
+		}
+		return model
+	}
+	// Read-only runs can't fix memory.js, so they don't count against the route
+	if !readOnlyFlag {
+		if err := config.RecordRoutedRun(thoughtDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: recording routed run: %v\n", err)
+		}
+	}
+	fmt.Fprintln(os.Stderr, labelStyle.Render(fmt.Sprintf("model: %s (routes.%s)", model, kind)))
+	return model
+}
+
+// showPrompt writes the system prompt and user message the agent is about
+// to send to --show-prompt's file, or stderr for "-", with the agent's
+// credentials and secret-looking environment variables redacted.
+func showPrompt(a *agent.Agent, prompt, model string, resolved *config.ResolvedConfig) error {
+	system, user := a.Prompt(prompt)
+	secrets := []string{resolved.APIKey}
+	for _, v := range resolved.Headers {
+		secrets = append(secrets, v)
+	}
+	text := runlog.RedactText(fmt.Sprintf("# think --show-prompt: model %s (%s)\n\n## System\n\n%s\n\n## User\n\n%s\n", model, resolved.Provider, system, user), secrets...)
+	if showPromptFlag == "-" {
+		fmt.Fprint(os.Stderr, text)
+		return nil
+	}
+	if err := os.WriteFile(showPromptFlag, []byte(text), 0600); err != nil {
+		return fmt.Errorf("--show-prompt: %w", err)
+	}
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintln(os.Stderr, labelStyle.Render("prompt written to "+showPromptFlag))
+	return nil
+}
+
+// explainFirst shows the agent's plan for prompt and asks whether to run
+// it. An approved plan is passed on to the run.
+func explainFirst(ctx context.Context, a *agent.Agent, prompt string, approver *approval.Approver) error {
+	plan, err := a.Explain(ctx, prompt)
+	if err != nil {
+		return fmt.Errorf("--explain: %w", err)
+	}
+	markerStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("39"))
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintf(os.Stderr, "\n  %s %s\n", markerStyle.Render("◆"), labelStyle.Render("plan"))
+	for _, line := range strings.Split(plan, "\n") {
+		fmt.Fprintf(os.Stderr, "    %s\n", line)
+	}
+	ok, err := approver.Confirm(i18n.T("confirm.run_plan"))
+	if err != nil {
+		return fmt.Errorf("--explain: %w", err)
+	}
+	if !ok {
+		return errors.New("run cancelled; the plan was not approved")
+	}
+	a.SetPlan(plan)
+	return nil
+}
+
+var costNoteOnce sync.Once
+
+// setCostLimits applies cost_confirm and cost_ceiling to a, which runs
+// model. Without a known price nothing can be estimated; that is noted once.
+func setCostLimits(a *agent.Agent, model string, resolved *config.ResolvedConfig, approver *approval.Approver) {
+	if resolved.CostConfirm <= 0 && resolved.CostCeiling <= 0 {
+		return
+	}
+	price, ok := cost.Lookup(model, prices(resolved))
+	if !ok {
+		costNoteOnce.Do(func() {
+			fmt.Fprintf(os.Stderr, "warning: no known price for model %s; cost_confirm and cost_ceiling are not enforced\n", model)
+		})
+		return
+	}
+	a.SetCostLimits(price, resolved.CostConfirm, resolved.CostCeiling, approver.Confirm)
+}
+
+var budgetNoteOnce, budgetPriceNoteOnce sync.Once
+
+// setBudget applies max_total_tokens and max_cost to a, counting what all
+// of the run's agents used through meter. Usage the provider doesn't
+// report, or can't be priced, can't be limited; that is noted once.
+func setBudget(a *agent.Agent, meter *provider.Meter, resolved *config.ResolvedConfig) {
+	if resolved.MaxTotalTokens <= 0 && resolved.MaxCost <= 0 {
+		return
+	}
+	a.SetBudget(resolved.MaxTotalTokens, resolved.MaxCost, func() (int, float64, bool) {
+		rec := usage.NewRecord(meter.Usage(), prices(resolved))
+		if rec.Calls > 0 && rec.Usage == (provider.Usage{}) {
+			budgetNoteOnce.Do(func() {
+				fmt.Fprintln(os.Stderr, "warning: the provider reports no token usage; max_total_tokens and max_cost are not enforced")
+			})
+		}
+		if rec.Cost == nil {
+			if resolved.MaxCost > 0 {
+				budgetPriceNoteOnce.Do(func() {
+					fmt.Fprintln(os.Stderr, "warning: no known price for a model in this run; max_cost is not enforced (set one in config.json \"prices\")")
+				})
+			}
+			return rec.InputTokens + rec.OutputTokens, 0, false
+		}
+		return rec.InputTokens + rec.OutputTokens, *rec.Cost, true
+	})
+}
+
+// prices converts config.json "prices" for cost.Lookup.
+func prices(resolved *config.ResolvedConfig) map[string]cost.Price {
+	out := make(map[string]cost.Price, len(resolved.Prices))
+	for model, p := range resolved.Prices {
+		out[model] = cost.Price{Input: p.Input, Output: p.Output, Cached: p.Cached}
+	}
+	return out
+}
+
+// usageRecord totals the run's token usage from meter and, with
+// --profile, its timings.
+func usageRecord(meter *provider.Meter, profile *sandbox.Profile, resolved *config.ResolvedConfig) usage.Record {
+	rec := usage.NewRecord(meter.Usage(), prices(resolved))
+	if profile != nil {
+		runs := profile.Runs()
+		rec.Sandbox = &runs
+		rec.Bridges = profile.Bridges()
+	}
+	return rec
+}
+
+// finishUsage prints the run's token usage, appends it to the thought's
+// usage.json, and records it in last-run/run.json. Runs that never called
+// the provider have none. With --profile it prints the timing summary too,
+// and records it alongside.
+func finishUsage(thoughtDir string, recorder *runlog.Recorder, rec usage.Record, profiled bool, kind string, started time.Time, runErr error) {
+	models := rec.Models
+	if len(models) == 0 && !profiled {
+		return
+	}
+	rec.Started = started
+	rec.Kind = kind
+	rec.Duration = time.Since(started).Round(time.Millisecond).String()
+	rec.Status = "ok"
+	if runErr != nil {
+		rec.Status = "error"
+	}
+	recorder.SetUsage(rec)
+	if profiled {
+		printProfile(rec)
+	}
+	if len(models) == 0 {
+		return
+	}
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintf(os.Stderr, "%s\n", labelStyle.Render("usage: "+rec.Summary()))
+	if err := usage.Append(thoughtDir, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: recording usage: %v\n", err)
+	}
+}
+
+// printProfile prints the --profile summary: time spent waiting on each
+// model, in sandbox runs, and in each bridge function they called.
+func printProfile(rec usage.Record) {
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintln(os.Stderr, labelStyle.Render("profile:"))
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  \tCALLS\tTOTAL\tMAX")
+	for _, m := range rec.Models {
+		fmt.Fprintf(w, "  model %s\t%d\t%s\t-\n", m.Model, m.Calls, profileTime(m.TimeMS))
+	}
+	if rec.Sandbox != nil {
+		fmt.Fprintf(w, "  sandbox\t%d\t%s\t%s\n", rec.Sandbox.Calls, profileTime(rec.Sandbox.TimeMS), profileTime(rec.Sandbox.MaxMS))
+	}
+	for _, b := range rec.Bridges {
+		fmt.Fprintf(w, "    %s\t%d\t%s\t%s\n", b.Name, b.Calls, profileTime(b.TimeMS), profileTime(b.MaxMS))
+	}
+	w.Flush()
+}
+
+// profileTime formats a --profile duration given in milliseconds.
+func profileTime(ms float64) string {
+	d := time.Duration(ms * float64(time.Millisecond))
+	if d >= time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(10 * time.Microsecond).String()
+}
+
+// fastPathEnabled reports whether first runs may skip the agent for
+// trivial prompts: on unless --no-fast-path or config.json "fast_path": false.
+func fastPathEnabled() bool {
+	if noFastPathFlag {
+		return false
+	}
+	cfg := config.LoadConfig()
+	return cfg.FastPath == nil || *cfg.FastPath
+}
+
+// writeFastPath writes memory.js for prompt if it is a trivial instruction
+// (see internal/fastpath), saying so on stderr.
+func writeFastPath(prompt, memoryJSPath string, jrnl *journal.Journal) {
+	kind, code, ok := fastpath.Synthesize(prompt)
+	if !ok {
+		return
+	}
+	jrnl.Write(memoryJSPath)
+	err := os.MkdirAll(filepath.Dir(memoryJSPath), 0700)
+	if err == nil {
+		err = os.WriteFile(memoryJSPath, []byte(code), 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: fast path: %v\n", err)
+		return
+	}
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintf(os.Stderr, "%s\n", labelStyle.Render(fmt.Sprintf("fast path (%s): wrote memory.js without the agent; --no-fast-path to disable", kind)))
+}
+
+// loadManagedPolicy merges the organization's policy (managed_policy_url in
+// config.json) into the approver. When it can't be fetched the last
+// verified copy is used, with a warning; without one, or with settings
+// that can't verify it, the run is refused rather than going ahead
+// unmanaged.
+func loadManagedPolicy(ctx context.Context, approver *approval.Approver) error {
+	settings, err := managed.FromConfig(config.LoadConfig())
+	if err != nil {
+		return fmt.Errorf("managed policy: %w", err)
+	}
+	if settings == nil {
+		return nil
+	}
+	policy, stale, err := managed.Load(ctx, settings)
+	if policy == nil {
+		return fmt.Errorf("managed policy unavailable, refusing to run without it: %w", err)
+	}
+	if stale {
+		fmt.Fprintf(os.Stderr, "warning: using cached managed policy: %v\n", err)
+	}
+	approver.SetManagedPolicy(policy)
+	return nil
+}
+
+// selectBackend picks where sandboxes run from --backend and config.json.
+// A container backend that isn't available falls back to in-process with a
+// warning, so thoughts keep working on machines without docker.
+func selectBackend() (backend.Backend, error) {
+	settings, err := backend.FromConfig(config.LoadConfig(), backendFlag)
+	if err != nil {
+		return nil, err
+	}
+	b, fallback := backend.Select(settings)
+	if fallback != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", fallback)
+	}
+	return b, nil
+}
+
+// runCommitMessage describes a run for the thought's git history. Arguments
+// are left out: the history is meant to be shared and they may hold secrets.
+func runCommitMessage(name, kind, scriptPath, model string, took time.Duration, runErr error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s run\n\n", name, kind)
+	fmt.Fprintf(&b, "Script: %s\n", config.ScriptIdentity(scriptPath))
+	if kind != "memory.js" {
+		fmt.Fprintf(&b, "Model: %s\n", model)
+	}
+	if runErr != nil {
+		fmt.Fprintf(&b, "Status: failed: %v\n", runErr)
+	} else {
+		fmt.Fprintf(&b, "Status: ok\n")
+	}
+	fmt.Fprintf(&b, "Duration: %s\n", took.Round(100*time.Millisecond))
+	return b.String()
+}
+
+// moveDataPolicy repoints a thought's workspace and memories grants from
+// the old data directory to the new one. Only those two subtrees move, so
+// a grant for the project directory a data_dir sits in is left alone.
+func moveDataPolicy(policyPath, oldDir, newDir string) error {
+	if _, err := os.Stat(policyPath); err != nil {
+		return nil
+	}
+	policy, err := approval.LoadPolicy(policyPath)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, sub := range []string{"workspace", "memories"} {
+		if policy.MovePaths(filepath.Join(oldDir, sub), filepath.Join(newDir, sub)) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return policy.Save(policyPath)
+}
+
+// snapshotWorkspace saves a copy of the workspace before the agent runs and
+// drops snapshots beyond the configured limit. Failures only warn: a missing
+// snapshot shouldn't stop the script.
+func snapshotWorkspace(thoughtDir, workspaceDir string) {
+	keep := config.LoadConfig().Snapshots
+	if keep < 0 {
+		return
+	}
+	_, err := snapshot.Take(thoughtDir, workspaceDir)
+	if errors.Is(err, snapshot.ErrTooLarge) {
+		fmt.Fprintf(os.Stderr, "warning: workspace is over %d MB, not snapshotting it\n", snapshot.MaxSize>>20)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to snapshot workspace: %v\n", err)
+		return
+	}
+	if _, err := snapshot.GC(thoughtDir, keep); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to prune workspace snapshots: %v\n", err)
+	}
+}
+
+// resolveWorkDir picks the sandbox working directory: --cwd wins, then
+// frontmatter workdir (checked by config.ResolveScriptWorkDir), then
+// os.Getwd.
+func resolveWorkDir(parsed *script.ParsedScript, thoughtDir string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("getting working directory: %w", err)
+	}
+	if cwdFlag != "" {
+		return config.ResolveWorkDir(cwdFlag, cwd)
+	}
+	if parsed.Config != nil && parsed.Config.WorkDir != "" {
+		return config.ResolveScriptWorkDir(parsed.Config.WorkDir, parsed.Path, thoughtDir)
+	}
+	return cwd, nil
+}
+
+// resolveGrantPaths converts --allow/--write arguments to absolute paths,
+// rejecting anything that doesn't exist so typos don't silently grant nothing.
+func resolveGrantPaths(paths []string) ([]string, error) {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s: %w", p, err)
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return nil, fmt.Errorf("granted path %s: %w", p, err)
+		}
+		resolved = append(resolved, abs)
+	}
+	return resolved, nil
+}
+
+// createProvider returns the configured provider with retries for
+// transient errors, then the agent's fallback_models, behind the dev
+// response cache when THINKINGSCRIPT__DEV_CACHE=1, with its usage
+// counted by meter.
+func createProvider(cfg *config.ResolvedConfig, meter *provider.Meter) (provider.Provider, error) {
+	p, err := provider.FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	attempts := cmp.Or(cfg.RetryAttempts, provider.DefaultRetryAttempts)
+	p = provider.NewRetry(p, provider.RetryConfig{
+		Attempts: attempts,
+		MaxWait:  cmp.Or(cfg.RetryMaxWait, provider.DefaultRetryMaxWait),
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			fmt.Fprintf(os.Stderr, "\r\033[K  %s\n  retrying in %s (attempt %d of %d)\n", err, wait.Round(100*time.Millisecond), attempt+1, attempts)
+		},
+	})
+	if len(cfg.FallbackModels) > 0 {
+		p = provider.NewFallback(p, cfg.FallbackModels, func(from, to string, err error) {
+			fmt.Fprintf(os.Stderr, "\r\033[K  %s\n  %s failed, trying %s\n", err, from, to)
+		})
+	}
+	if os.Getenv("THINKINGSCRIPT__DEV_CACHE") != "1" {
+		return meter.Wrap(p), nil
 	}
+	dir := filepath.Join(config.HomeDir(), "devcache", cfg.Provider)
+	fmt.Fprintf(os.Stderr, "warning: dev cache on, replaying recorded responses from %s\n", dir)
+	return meter.Wrap(provider.NewDevCache(p, dir)), nil
 }
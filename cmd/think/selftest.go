@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/backend"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that sandboxes work on this machine",
+	Long: `Start sandboxes and exercise every bridge with harmless operations: files
+in a temporary directory, glob, require, json and mime, env and secrets,
+network requests that must be denied, and the timeout. Each check must behave as
+expected within its time limit. Nothing outside the temporary directory
+is touched and nothing is sent over the network.
+
+Exits 1 when a check fails, so packagers can run it after a build.
+
+Examples:
+  think selftest
+  think selftest --backend docker`,
+	Args:         cobra.NoArgs,
+	RunE:         runSelftest,
+	SilenceUsage: true,
+}
+
+func init() {
+	selftestCmd.Flags().StringVar(&backendFlag, "backend", "", "Where sandboxes run: process, docker, or podman (overrides config.json backend)")
+	rootCmd.AddCommand(selftestCmd)
+}
+
+// selftestLimit is how long one check may take in-process; container
+// backends start a container per check, so they get selftestContainerLimit.
+const (
+	selftestLimit          = 5 * time.Second
+	selftestContainerLimit = 60 * time.Second
+)
+
+// selftestDirs are the paths a check's sandbox is given.
+type selftestDirs struct {
+	work    string // allowed and writable, the sandbox's working directory
+	outside string // neither; holds secret.txt
+}
+
+// selftestCheck is one sandbox run and what it should produce.
+type selftestCheck struct {
+	name  string
+	setup func(cfg *sandbox.Config, dirs selftestDirs)
+	code  string
+	// want checks the run's result, stdout, and error
+	want func(result, stdout string, err error) error
+}
+
+// wantResult expects the run to succeed with result want.
+func wantResult(want string) func(string, string, error) error {
+	return func(result, _ string, err error) error {
+		if err != nil {
+			return err
+		}
+		if result != want {
+			return fmt.Errorf("got %q, want %q", result, want)
+		}
+		return nil
+	}
+}
+
+// wantError expects the run to fail with an error containing substr.
+func wantError(substr string) func(string, string, error) error {
+	return func(result, _ string, err error) error {
+		if err == nil {
+			return fmt.Errorf("succeeded with %q, want an error containing %q", result, substr)
+		}
+		if !strings.Contains(err.Error(), substr) {
+			return fmt.Errorf("error %q, want one containing %q", err, substr)
+		}
+		return nil
+	}
+}
+
+var selftestChecks = []selftestCheck{
+	{
+		name: "console and process",
+		setup: func(cfg *sandbox.Config, dirs selftestDirs) {
+			cfg.Args = []string{cfg.WorkDir}
+		},
+		code: `process.stdout.write("out"); console.log("log"); process.cwd() === process.args[0]`,
+		want: func(result, stdout string, err error) error {
+			if err != nil {
+				return err
+			}
+			if stdout != "out" {
+				return fmt.Errorf("stdout %q, want %q", stdout, "out")
+			}
+			if result != "true" {
+				return errors.New("process.cwd() isn't the working directory")
+			}
+			return nil
+		},
+	},
+	{
+		name: "fs read and write",
+		code: `fs.writeFile("a.txt", "hello");
+fs.appendFile("a.txt", " world");
+fs.mkdir("sub");
+fs.copy("a.txt", "sub/b.txt");
+fs.delete("a.txt");
+[fs.readFile("sub/b.txt"), fs.exists("a.txt"), fs.stat("sub/b.txt").size, fs.readDir("sub").length].join(",")`,
+		want: wantResult("hello world,false,11,1"),
+	},
+	{
+		name: "fs outside the sandbox denied",
+		code: `fs.readFile(process.args[0])`,
+		setup: func(cfg *sandbox.Config, dirs selftestDirs) {
+			cfg.Args = []string{filepath.Join(dirs.outside, "secret.txt")}
+		},
+		want: wantError("outside the sandbox"),
+	},
+	{
+		name: "fs.glob",
+		code: `fs.mkdir("g");
+fs.mkdir("g/deep");
+fs.writeFile("g/one.txt", "");
+fs.writeFile("g/deep/two.txt", "");
+fs.writeFile("g/skip.md", "");
+JSON.stringify(fs.glob("g/**/*.txt").map(p => p.slice(process.cwd().length + 1)))`,
+		want: wantResult(`["g/deep/two.txt","g/one.txt"]`),
+	},
+	{
+		name: "require",
+		code: `fs.writeFile("lib.js", "module.exports = { twice: n => n * 2 };");
+require("./lib.js").twice(21)`,
+		want: wantResult("42"),
+	},
+	{
+		name: "json and mime",
+		code: `fs.writeFile("page.html", "<!DOCTYPE html><html><body>hi</body></html>");
+JSON.stringify(json.stream('{"items": [{"id": 1}, {"id": 2}]}', "items.#.id")) + " " + mime.detect("page.html")`,
+		want: func(result, _ string, err error) error {
+			if err != nil {
+				return err
+			}
+			if !strings.HasPrefix(result, "[1,2] text/html") {
+				return fmt.Errorf("got %q, want [1,2] and text/html", result)
+			}
+			return nil
+		},
+	},
+	{
+		name: "sys",
+		code: `[typeof sys.platform(), sys.cpus() > 0].join(",")`,
+		want: wantResult("string,true"),
+	},
+	{
+		name: "env denied",
+		code: `env.get("HOME")`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			cfg.ApproveEnv = func(string) (bool, error) { return false, nil }
+		},
+		want: wantError("access denied"),
+	},
+	{
+		name: "env approved",
+		code: `env.get("THINK_SELFTEST")`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			cfg.ApproveEnv = func(name string) (bool, error) { return name == "THINK_SELFTEST", nil }
+			cfg.Getenv = func(string) string { return "ok" }
+		},
+		want: wantResult("ok"),
+	},
+	{
+		name: "secrets denied",
+		code: `secrets.get("THINK_SELFTEST")`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			cfg.ApproveSecret = func(string) (bool, error) { return false, nil }
+		},
+		want: wantError("access denied"),
+	},
+	{
+		name: "secrets approved",
+		// The script gets a handle, never the value
+		code: `var h = secrets.get("THINK_SELFTEST"); h.startsWith("{{secret:THINK_SELFTEST:") && h.indexOf("s3cret") < 0`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			cfg.ApproveSecret = func(name string) (bool, error) { return name == "THINK_SELFTEST", nil }
+			cfg.Secret = func(string) (string, error) { return "s3cret", nil }
+		},
+		want: wantResult("true"),
+	},
+	{
+		name: "loopback fetch denied",
+		code: `net.fetch("http://127.0.0.1:1/")`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			// Even an approved host can't reach private addresses
+			cfg.ApproveNet = func(string) (bool, error) { return true, nil }
+		},
+		want: wantError("private IP"),
+	},
+	{
+		name: "fetch without approval denied",
+		// A documentation address (TEST-NET-1), so there's no DNS lookup
+		code: `net.fetch("https://192.0.2.1/")`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			cfg.ApproveNet = func(string) (bool, error) { return false, nil }
+		},
+		want: wantError("denied"),
+	},
+	{
+		name: "timeout",
+		code: `while (true) {}`,
+		setup: func(cfg *sandbox.Config, _ selftestDirs) {
+			cfg.Timeout = 200 * time.Millisecond
+		},
+		want: func(_, _ string, err error) error {
+			if err == nil {
+				return errors.New("an endless loop finished")
+			}
+			return nil
+		},
+	},
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	b, err := selectBackend()
+	if err != nil {
+		return err
+	}
+	limit := selftestLimit
+	if b.Name() != backend.NameProcess {
+		limit = selftestContainerLimit
+	}
+
+	okStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("82"))
+	failStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
+	dimStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintf(os.Stderr, "think selftest: %s/%s, %s backend\n", goruntime.GOOS, goruntime.GOARCH, b.Name())
+
+	failed := 0
+	for _, c := range selftestChecks {
+		took, err := runSelftestCheck(cmd.Context(), b, c, limit)
+		if err != nil {
+			failed++
+			msg, _, _ := strings.Cut(err.Error(), "\n")
+			fmt.Fprintf(os.Stderr, "  %s %-30s %s\n", failStyle.Render("✕"), c.name, failStyle.Render(msg))
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  %s %-30s %s\n", okStyle.Render("✓"), c.name, dimStyle.Render(took.Round(time.Millisecond).String()))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(selftestChecks))
+	}
+	fmt.Fprintf(os.Stderr, "All %d checks passed.\n", len(selftestChecks))
+	return nil
+}
+
+// runSelftestCheck runs a check in a fresh temporary directory and returns
+// how long it took, or why it failed.
+func runSelftestCheck(ctx context.Context, b backend.Backend, c selftestCheck, limit time.Duration) (time.Duration, error) {
+	root, err := os.MkdirTemp("", "think-selftest-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(root)
+	dirs := selftestDirs{work: filepath.Join(root, "work"), outside: filepath.Join(root, "outside")}
+	for _, d := range []string{dirs.work, dirs.outside} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dirs.outside, "secret.txt"), []byte("secret"), 0600); err != nil {
+		return 0, err
+	}
+
+	var stdout, stderr strings.Builder
+	cfg := sandbox.Config{
+		AllowedPaths:  []string{dirs.work},
+		WritablePaths: []string{dirs.work},
+		WorkDir:       dirs.work,
+		Stdout:        &stdout,
+		Stderr:        &stderr,
+		Timeout:       limit,
+	}
+	if c.setup != nil {
+		c.setup(&cfg, dirs)
+	}
+	ctx, cancel := context.WithTimeout(ctx, limit)
+	defer cancel()
+
+	started := time.Now()
+	result, runErr := b.Run(ctx, cfg, c.code)
+	took := time.Since(started)
+	if err := c.want(result, stdout.String(), runErr); err != nil {
+		return took, err
+	}
+	if took > limit {
+		return took, fmt.Errorf("took %s, want under %s", took.Round(time.Millisecond), limit)
+	}
+	return took, nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/mcp"
+	"github.com/thinkingscript/cli/internal/script"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Offer installed thoughts to other programs",
+	Long: `Offer every thought installed in ~/.thinkingscript/bin/ to other programs.
+
+--mcp speaks the Model Context Protocol on stdin and stdout, so MCP clients
+(Claude Desktop, IDEs, other agents) can call each thought as a tool. A
+thought's frontmatter description and arguments become the tool's
+description and input schema; a thought that declares no arguments takes
+an "args" array. Each call runs the thought as 'think <thought> <args>',
+memory.js first and the agent when it hands over, and returns its stdout.
+
+--http serves an HTTP API on the given address:
+
+  GET  /thoughts             the installed thoughts, as the MCP tools above
+  POST /thoughts/{name}/run  run one; the JSON body has "args" (an array),
+                             or "arguments" (an object, as the MCP tool
+                             takes them), and "stdin"
+
+A run's stdout and stderr stream back as server-sent events ("stdout",
+"stderr", each a JSON string), then an "exit" event with its exit code.
+Closing the connection interrupts the run. Set
+$THINKINGSCRIPT_SERVE_TOKEN to require "Authorization: Bearer <token>";
+without it, only loopback addresses are allowed.
+
+Calls have no terminal, so approval prompts are denied: grant what a
+thought needs first with 'thought policy add'.
+
+Examples:
+  think serve --mcp
+  think serve --http 127.0.0.1:8080
+  curl -N -d '{"args": ["Paris"]}' localhost:8080/thoughts/weather/run
+
+  # Claude Desktop (claude_desktop_config.json)
+  {"mcpServers": {"thoughts": {"command": "think", "args": ["serve", "--mcp"]}}}`,
+	Args:         cobra.NoArgs,
+	RunE:         runServe,
+	SilenceUsage: true,
+}
+
+var (
+	serveMCPFlag  bool
+	serveHTTPFlag string
+)
+
+func init() {
+	serveCmd.Flags().BoolVar(&serveMCPFlag, "mcp", false, "Speak MCP on stdin and stdout")
+	serveCmd.Flags().StringVar(&serveHTTPFlag, "http", "", "Serve an HTTP API on this address (e.g. 127.0.0.1:8080)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// serveStderrTail is how much of a failed run's stderr a tool result keeps.
+const serveStderrTail = 4 << 10
+
+var toolNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveMCPFlag == (serveHTTPFlag != "") {
+		return errors.New("choose what to serve: --mcp or --http <addr>")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	tools, bins := thoughtTools()
+	if len(tools) == 0 {
+		fmt.Fprintln(os.Stderr, "warning: no thoughts installed; install some with 'thought install'")
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if serveHTTPFlag != "" {
+		return serveHTTP(ctx, serveHTTPFlag, exe, tools, bins)
+	}
+	server := &mcp.Server{
+		Info:         mcp.Implementation{Name: "think", Version: "1.0"},
+		Instructions: "Each tool runs an installed thought: a script that does one job, described by the tool. Its output is the tool result.",
+		Tools:        tools,
+		Call: func(ctx context.Context, name string, arguments json.RawMessage) (*mcp.CallToolResult, error) {
+			return runThoughtTool(ctx, exe, bins[name], arguments)
+		},
+	}
+	return server.Serve(ctx, os.Stdin, os.Stdout)
+}
+
+// servedThought is an installed thought offered as a tool.
+type servedThought struct {
+	path      string
+	arguments []config.Argument // nil = takes an args array
+}
+
+// thoughtTools describes the installed thoughts as MCP tools. Thoughts
+// that can't be parsed or whose names aren't valid tool names are skipped
+// with a warning.
+func thoughtTools() ([]mcp.Tool, map[string]servedThought) {
+	entries, err := os.ReadDir(config.BinDir())
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "warning: reading installed thoughts: %v\n", err)
+	}
+	var tools []mcp.Tool
+	bins := make(map[string]servedThought)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if !toolNameRe.MatchString(name) {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: not a valid tool name\n", name)
+			continue
+		}
+		path := filepath.Join(config.BinDir(), name)
+		parsed, err := script.Parse(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: skipping %s: %v\n", name, err)
+			continue
+		}
+		cfg := parsed.Config
+		if cfg == nil {
+			cfg = &config.ScriptConfig{}
+		}
+
+		desc := cfg.Description
+		if desc == "" {
+			desc, _, _ = strings.Cut(strings.TrimSpace(parsed.Prompt), "\n")
+			if r := []rune(desc); len(r) > 200 {
+				desc = string(r[:200]) + "…"
+			}
+		}
+		schema := map[string]any{"type": "object"}
+		if len(cfg.Arguments) > 0 {
+			props := map[string]any{}
+			required := []string{}
+			for _, arg := range cfg.Arguments {
+				props[arg.Name] = map[string]any{"type": "string", "description": arg.Description}
+				if arg.Required {
+					required = append(required, arg.Name)
+				}
+			}
+			schema["properties"] = props
+			schema["required"] = required
+		} else {
+			schema["properties"] = map[string]any{
+				"args": map[string]any{
+					"type":        "array",
+					"items":       map[string]any{"type": "string"},
+					"description": "Arguments for the thought, in order",
+				},
+			}
+		}
+		raw, _ := json.Marshal(schema)
+		tools = append(tools, mcp.Tool{Name: name, Description: desc, InputSchema: raw})
+		bins[name] = servedThought{path: path, arguments: cfg.Arguments}
+	}
+	return tools, bins
+}
+
+// thoughtArgs turns a tool call's arguments into the thought's command
+// line: declared arguments in order (a skipped optional one is passed as
+// "" when a later one is given), or the args array.
+func thoughtArgs(t servedThought, arguments json.RawMessage) ([]string, error) {
+	var values map[string]json.RawMessage
+	if len(arguments) > 0 {
+		if err := json.Unmarshal(arguments, &values); err != nil {
+			return nil, fmt.Errorf("arguments must be an object: %w", err)
+		}
+	}
+	if t.arguments == nil {
+		var args []string
+		if raw, ok := values["args"]; ok {
+			if err := json.Unmarshal(raw, &args); err != nil {
+				return nil, fmt.Errorf("args must be an array of strings: %w", err)
+			}
+		}
+		return args, nil
+	}
+
+	args := make([]string, len(t.arguments))
+	last := -1
+	for i, arg := range t.arguments {
+		raw, ok := values[arg.Name]
+		if !ok || string(raw) == "null" {
+			if arg.Required {
+				return nil, fmt.Errorf("%s is required", arg.Name)
+			}
+			continue
+		}
+		// Strings as they are; numbers and the like as JSON
+		if err := json.Unmarshal(raw, &args[i]); err != nil {
+			args[i] = string(raw)
+		}
+		last = i
+	}
+	return args[:last+1], nil
+}
+
+// runThoughtTool runs an installed thought for a tool call, with no
+// terminal and no stdin. A failed run is an error result carrying the end
+// of its stderr.
+func runThoughtTool(ctx context.Context, exe string, t servedThought, arguments json.RawMessage) (*mcp.CallToolResult, error) {
+	args, err := thoughtArgs(t, arguments)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := thoughtCommand(ctx, exe, t.path, args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+
+	text := strings.TrimRight(stdout.String(), "\n")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		tail := stderr.String()
+		if len(tail) > serveStderrTail {
+			tail = tail[len(tail)-serveStderrTail:]
+		}
+		msg := fmt.Sprintf("%s failed: %v", filepath.Base(t.path), err)
+		if s := strings.TrimSpace(tail); s != "" {
+			msg += "\n" + s
+		}
+		if text != "" {
+			msg = text + "\n\n" + msg
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: msg}}, IsError: true}, nil
+	}
+	if text == "" {
+		text = "(no output)"
+	}
+	return &mcp.CallToolResult{Content: []mcp.Content{{Type: "text", Text: text}}}, nil
+}
+
+// thoughtCommand runs the thought at path with args as a child think.
+// Cancelling ctx interrupts it the way Ctrl+C would.
+func thoughtCommand(ctx context.Context, exe, path string, args []string) *exec.Cmd {
+	// Flags end here, so arguments are never parsed as flags
+	cmd := exec.CommandContext(ctx, exe, append([]string{"--", path}, args...)...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
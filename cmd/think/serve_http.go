@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/api"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/mcp"
+)
+
+const (
+	serveMaxBody  = 10 << 20                     // caps a run request's body (arguments and stdin)
+	serveTokenEnv = "THINKINGSCRIPT_SERVE_TOKEN" // bearer token for `think serve --http`
+)
+
+// runRequest is the body of POST /thoughts/{name}/run.
+type runRequest struct {
+	Args      []string        `json:"args,omitempty"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Stdin     string          `json:"stdin,omitempty"`
+}
+
+// serveHTTP serves the installed thoughts over HTTP on addr until ctx is
+// done, then waits for running thoughts to be interrupted. Without
+// $THINKINGSCRIPT_SERVE_TOKEN a token is generated and written, readable
+// only by the user, next to the config as serve-<port>.token.
+func serveHTTP(ctx context.Context, addr, exe string, tools []mcp.Tool, bins map[string]servedThought) error {
+	token := os.Getenv(serveTokenEnv)
+	if token == "" && !loopbackAddr(addr) {
+		return fmt.Errorf("refusing to serve %s without a token: set $THINKINGSCRIPT_SERVE_TOKEN or listen on a loopback address", addr)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	tokenNote := "$" + serveTokenEnv
+	if token == "" {
+		token = api.NewToken()
+		tokenFile := filepath.Join(config.HomeDir(), fmt.Sprintf("serve-%d.token", ln.Addr().(*net.TCPAddr).Port))
+		err := os.MkdirAll(config.HomeDir(), 0700)
+		if err == nil {
+			err = api.WriteToken(tokenFile, token)
+		}
+		if err != nil {
+			ln.Close()
+			return fmt.Errorf("writing token: %w", err)
+		}
+		defer os.Remove(tokenFile)
+		tokenNote = tokenFile
+	}
+	fmt.Fprintf(os.Stderr, "Serving %d thoughts on http://%s (token: %s)\n", len(tools), ln.Addr(), tokenNote)
+	server := &http.Server{
+		Handler:           serveHandler(addr, token, exe, tools, bins),
+		ReadHeaderTimeout: 10 * time.Second,
+		// Runs end with the server: their requests' contexts derive from ctx
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveHandler returns the HTTP API for a server listening on addr. Every
+// request needs the bearer token. A web page can reach a loopback server
+// too, so what a browser would send is refused as well: requests with an
+// Origin header, requests whose Host isn't loopback when addr is (DNS
+// rebinding), and runs whose body isn't sent as application/json (a
+// cross-site form or text/plain POST).
+func serveHandler(addr, token, exe string, tools []mcp.Tool, bins map[string]servedThought) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /thoughts", func(w http.ResponseWriter, r *http.Request) {
+		list := tools
+		if list == nil {
+			list = []mcp.Tool{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("POST /thoughts/{name}/run", func(w http.ResponseWriter, r *http.Request) {
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		t, ok := bins[r.PathValue("name")]
+		if !ok {
+			http.Error(w, "no such thought", http.StatusNotFound)
+			return
+		}
+		var req runRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, serveMaxBody)).Decode(&req); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		args := req.Args
+		if req.Arguments != nil {
+			var err error
+			if args, err = thoughtArgs(t, req.Arguments); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		streamThought(w, r, exe, t, args, req.Stdin)
+	})
+
+	loopback := loopbackAddr(addr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		if loopback && !loopbackHost(r.Host) {
+			http.Error(w, "Host must be a loopback address", http.StatusForbidden)
+			return
+		}
+		got, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// streamThought runs a thought for a request, streaming its stdout and
+// stderr as server-sent events and ending with its exit code.
+func streamThought(w http.ResponseWriter, r *http.Request, exe string, t servedThought, args []string, stdin string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	events := &sseWriter{w: w, flusher: flusher}
+
+	cmd := thoughtCommand(r.Context(), exe, t.path, args)
+	// Thoughts never see the server's token
+	cmd.Env = slices.DeleteFunc(os.Environ(), func(kv string) bool { return strings.HasPrefix(kv, serveTokenEnv+"=") })
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	cmd.Stdout = sseStream{events, "stdout"}
+	cmd.Stderr = sseStream{events, "stderr"}
+	err := cmd.Run()
+	if r.Context().Err() != nil {
+		return // the client is gone or the server is stopping
+	}
+
+	code := 0
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		code = exitErr.ExitCode()
+	case err != nil:
+		events.send("error", err.Error())
+		code = -1
+	}
+	events.sendJSON("exit", map[string]int{"code": code})
+}
+
+// sseWriter writes server-sent events; a run's stdout and stderr share it.
+type sseWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// send writes an event whose data is text as a JSON string, so newlines
+// can't break the stream.
+func (s *sseWriter) send(event, text string) error {
+	return s.sendJSON(event, text)
+}
+
+func (s *sseWriter) sendJSON(event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// sseStream sends each write to an sseWriter as one event.
+type sseStream struct {
+	events *sseWriter
+	event  string
+}
+
+func (s sseStream) Write(p []byte) (int, error) {
+	if err := s.events.send(s.event, string(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// loopbackAddr reports whether addr only listens on a loopback interface.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	return err == nil && loopbackName(host)
+}
+
+// loopbackHost reports whether a request's Host header, with or without a
+// port, names a loopback address.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return loopbackName(strings.Trim(host, "[]"))
+}
+
+func loopbackName(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/mcp"
+)
+
+func TestServeHandler(t *testing.T) {
+	tools := []mcp.Tool{{Name: "weather"}}
+	tests := []struct {
+		name    string
+		addr    string
+		method  string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"list", "127.0.0.1:8080", "GET", "/thoughts", nil, http.StatusOK},
+		{"localhost host", "localhost:8080", "GET", "/thoughts", map[string]string{"Host": "localhost:8080"}, http.StatusOK},
+		{"IPv6 host", "[::1]:8080", "GET", "/thoughts", map[string]string{"Host": "[::1]:8080"}, http.StatusOK},
+		{"no token", "127.0.0.1:8080", "GET", "/thoughts", map[string]string{"Authorization": ""}, http.StatusUnauthorized},
+		{"wrong token", "127.0.0.1:8080", "GET", "/thoughts", map[string]string{"Authorization": "Bearer nope"}, http.StatusUnauthorized},
+		{"origin", "127.0.0.1:8080", "GET", "/thoughts", map[string]string{"Origin": "https://evil.example"}, http.StatusForbidden},
+		{"rebound host", "127.0.0.1:8080", "GET", "/thoughts", map[string]string{"Host": "evil.example:8080"}, http.StatusForbidden},
+		{"any host off loopback", "0.0.0.0:8080", "GET", "/thoughts", map[string]string{"Host": "build.example:8080"}, http.StatusOK},
+		{"run as text/plain", "127.0.0.1:8080", "POST", "/thoughts/weather/run", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"run as form", "127.0.0.1:8080", "POST", "/thoughts/weather/run", map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, http.StatusUnsupportedMediaType},
+		{"run without content type", "127.0.0.1:8080", "POST", "/thoughts/weather/run", nil, http.StatusUnsupportedMediaType},
+		{"run as JSON", "127.0.0.1:8080", "POST", "/thoughts/missing/run", map[string]string{"Content-Type": "application/json; charset=utf-8"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		handler := serveHandler(tt.addr, "s3cret", "think", tools, map[string]servedThought{})
+		req := httptest.NewRequest(tt.method, "http://127.0.0.1:8080"+tt.path, strings.NewReader("{}"))
+		req.Header.Set("Authorization", "Bearer s3cret")
+		for k, v := range tt.headers {
+			if k == "Host" {
+				req.Host = v
+				continue
+			}
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/boot"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// streamPrompt is appended to the thought when frontmatter sets stdin: stream.
+const streamPrompt = `
+
+## Streaming stdin
+
+This thought runs in STREAM mode: stdin is processed one line at a time.
+You are being called for a single line that memory.js could not handle
+(it is shown after "Stdin:"). Handle that line, then make sure memory.js
+registers a line handler so future lines never need you:
+
+  process.stdin.on("line", function (line) {
+    if (!recognized(line)) {
+      agent.resume("unrecognized line: " + line);
+    }
+    process.stdout.write(handle(line) + "\n");
+  });
+  process.stdin.on("end", function () { /* optional summary */ });
+
+Output MUST go through process.stdout.write inside the handler.`
+
+// runStream feeds stdin to memory.js line by line (boot.Stream). Lines
+// memory.js can't handle are passed to runAgent; memory.js is then reloaded
+// (the agent may have improved it) and streaming continues with the next
+// line.
+func runStream(ctx context.Context, cfg boot.Config, name string, runAgent func(line, resumeContext string) error) error {
+	lines := bufio.NewScanner(os.Stdin)
+	lines.Buffer(make([]byte, 64*1024), sandbox.MaxStdinLine)
+
+	dotStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("82")) // Green for memory.js
+	nameStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
+	fileStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	resumeStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+	cfg.OnRun = func(string) func() {
+		fmt.Fprintf(os.Stderr, "%s %s %s\n", dotStyle.Render("■"), nameStyle.Render(name), fileStyle.Render("memory.js (stream)"))
+		return func() {}
+	}
+
+	for {
+		res, err := boot.Stream(ctx, cfg, lines)
+		if err != nil {
+			return err
+		}
+		if res.Success {
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "  %s %s\n", resumeStyle.Render("↳ resumed:"), fileStyle.Render(res.ResumeContext))
+
+		if err := runAgent(res.Line, res.ResumeContext); err != nil {
+			return err
+		}
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/blobcache"
 	"github.com/thinkingscript/cli/internal/config"
 	"github.com/thinkingscript/cli/internal/script"
 )
@@ -24,9 +29,52 @@ var cacheCmd = &cobra.Command{
 	SilenceUsage: true,
 }
 
+var cacheBlobsCmd = &cobra.Command{
+	Use:   "blobs",
+	Short: "List or prune the download cache shared by all thoughts",
+	Long: `net.download keeps what it fetches in one cache shared by every thought,
+so the same model file or dataset is downloaded and stored once. Cached
+URLs are revalidated with their ETag or Last-Modified before reuse.
+
+Examples:
+  thought cache blobs ls
+  thought cache blobs gc
+  thought cache blobs gc --older-than 168h --max-size 20GB`,
+	SilenceUsage: true,
+}
+
+var cacheBlobsListCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List cached downloads, most recently used first",
+	Args:         cobra.NoArgs,
+	RunE:         runCacheBlobsList,
+	SilenceUsage: true,
+}
+
+var cacheBlobsGCCmd = &cobra.Command{
+	Use:          "gc",
+	Short:        "Remove downloads not used recently, or beyond a size limit",
+	Args:         cobra.NoArgs,
+	RunE:         runCacheBlobsGC,
+	SilenceUsage: true,
+}
+
+var (
+	blobsOlderThanFlag time.Duration
+	blobsMaxSizeFlag   string
+)
+
 func init() {
 	cacheCmd.Flags().BoolVar(&clearFlag, "clear", false, "Clear cache for the specified script")
 	cacheCmd.Flags().BoolVar(&clearAllFlag, "clear-all", false, "Clear all script caches")
+
+	cacheBlobsGCCmd.Flags().DurationVar(&blobsOlderThanFlag, "older-than", 30*24*time.Hour, "Remove downloads not used for this long (0 = keep regardless of age)")
+	cacheBlobsGCCmd.Flags().StringVar(&blobsMaxSizeFlag, "max-size", "", "Then remove the least recently used until the cache fits (e.g. 20GB)")
+
+	cacheBlobsCmd.AddCommand(cacheBlobsListCmd)
+	cacheBlobsCmd.AddCommand(cacheBlobsGCCmd)
+	cacheCmd.AddCommand(cacheBlobsCmd)
 }
 
 func runCache(cmd *cobra.Command, args []string) error {
@@ -69,3 +117,68 @@ func runCache(cmd *cobra.Command, args []string) error {
 	fmt.Println(cacheDir)
 	return nil
 }
+
+func runCacheBlobsList(cmd *cobra.Command, args []string) error {
+	entries, err := blobcache.List(blobcache.Dir())
+	if err != nil {
+		return fmt.Errorf("reading download cache: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "Download cache is empty.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SHA256\tSIZE\tLAST USED\tURL")
+	seen := map[string]bool{}
+	var total int64
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.SHA256[:12], formatBytes(e.Size), e.LastUsed.Local().Format("2006-01-02 15:04"), e.URL)
+		if !seen[e.SHA256] {
+			seen[e.SHA256] = true
+			total += e.Size
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d URL%s, %s on disk in %s\n", len(entries), plural(len(entries), "", "s"), formatBytes(total), blobcache.Dir())
+	return nil
+}
+
+func runCacheBlobsGC(cmd *cobra.Command, args []string) error {
+	var maxSize int64
+	if blobsMaxSizeFlag != "" {
+		n, err := parseBytes(blobsMaxSizeFlag)
+		if err != nil {
+			return fmt.Errorf("--max-size: %w", err)
+		}
+		maxSize = n
+	}
+	res, err := blobcache.GC(blobcache.Dir(), blobsOlderThanFlag, maxSize)
+	if err != nil {
+		return fmt.Errorf("pruning download cache: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d URL%s and %d file%s, freed %s.\n",
+		res.Entries, plural(res.Entries, "", "s"), res.Blobs, plural(res.Blobs, "", "s"), formatBytes(res.Freed))
+	return nil
+}
+
+// parseBytes parses a size such as "500MB", "20GB", "1.5G", or a plain
+// number of bytes. Units are powers of 1024, as formatBytes prints them.
+func parseBytes(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "IB"), "B")
+	mult := int64(1)
+	if n := len(v); n > 0 {
+		if i := strings.IndexByte("KMGT", v[n-1]); i >= 0 {
+			mult = 1 << (10 * (i + 1))
+			v = v[:n-1]
+		}
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(f * float64(mult)), nil
+}
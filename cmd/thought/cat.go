@@ -2,15 +2,24 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/script"
 )
 
 var catCmd = &cobra.Command{
-	Use:          "cat <name|file|url>",
-	Short:        "Display a thought's content",
-	Long:         "Print the script content of an installed thought, local file, or URL.",
+	Use:   "cat <name|file|url>",
+	Short: "Display a thought's content",
+	Long: `Print the script of an installed thought or URL as it will run: the
+shebang, the frontmatter (highlighted on a terminal), and the prompt.
+
+Examples:
+  thought cat weather
+  thought cat weather | less
+  thought cat https://example.com/weather.md`,
 	Args:         cobra.ExactArgs(1),
 	RunE:         runCat,
 	SilenceUsage: true,
@@ -27,11 +36,79 @@ func runCat(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("'%s' is a file. Use 'cat %s' directly.", args[0], args[0])
 	}
 
-	parsed, err := script.Parse(resolved.Path)
-	if err != nil {
+	// Parse first so a broken thought is reported rather than printed
+	if _, err := script.Parse(resolved.Path); err != nil {
 		return fmt.Errorf("parsing script: %w", err)
 	}
+	data, err := script.Read(resolved.Path)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprint(os.Stdout, highlightScript(string(data), lipgloss.NewRenderer(os.Stdout)))
+	return err
+}
+
+// highlightScript colors a script's shebang and frontmatter; the prompt is
+// left as written. r decides whether color is shown at all.
+func highlightScript(content string, r *lipgloss.Renderer) string {
+	dim := r.NewStyle().Foreground(lipgloss.Color("245"))
+	key := r.NewStyle().Foreground(lipgloss.Color("39"))
+	value := r.NewStyle().Foreground(lipgloss.Color("214"))
+
+	var b strings.Builder
+	lines := strings.SplitAfter(content, "\n")
+	i := 0
+	if i < len(lines) && strings.HasPrefix(lines[i], "#!") {
+		b.WriteString(styleLine(dim, lines[i]))
+		i++
+	}
+	// Frontmatter as script.Parse finds it: after blank lines, from a ---
+	// line to the next ---
+	start := i
+	for start < len(lines) && strings.TrimSpace(lines[start]) == "" {
+		start++
+	}
+	end := -1
+	if start < len(lines) && strings.HasPrefix(lines[start], "---") {
+		for j := start + 1; j < len(lines); j++ {
+			if strings.HasPrefix(lines[j], "---") {
+				end = j
+				break
+			}
+		}
+	}
+	if end == -1 {
+		b.WriteString(strings.Join(lines[i:], ""))
+		return b.String()
+	}
 
-	fmt.Print(parsed.Prompt)
-	return nil
+	b.WriteString(strings.Join(lines[i:start], ""))
+	b.WriteString(styleLine(dim, lines[start]))
+	for _, line := range lines[start+1 : end] {
+		k, v, ok := strings.Cut(line, ":")
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "#"):
+			b.WriteString(styleLine(dim, line))
+		case ok && !strings.HasPrefix(strings.TrimSpace(k), "-"):
+			b.WriteString(key.Render(k) + ":" + styleLine(value, v))
+		default: // list items and continued values
+			b.WriteString(styleLine(value, line))
+		}
+	}
+	b.WriteString(styleLine(dim, lines[end]))
+	b.WriteString(strings.Join(lines[end+1:], ""))
+	return b.String()
+}
+
+// styleLine renders line with s, keeping its line ending unstyled.
+func styleLine(s lipgloss.Style, line string) string {
+	text, ok := strings.CutSuffix(line, "\n")
+	if strings.TrimSpace(text) != "" {
+		text = s.Render(text)
+	}
+	if ok {
+		text += "\n"
+	}
+	return text
 }
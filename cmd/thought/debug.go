@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/boot"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/debugger"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/script"
+	"github.com/thinkingscript/cli/internal/shared"
+)
+
+var debugCmd = &cobra.Command{
+	Use:   "debug <thought> [args...]",
+	Short: "Step through a thought's memory.js",
+	Long: `Run memory.js under a debugger: it stops before the first statement and
+between statements as you step, showing the source around the current line,
+the fs, net, env, and sys calls made since the last stop, and any watch
+expressions. Type h at the (debug) prompt for commands.
+
+memory.js runs in-process with the thought's policy, as in a normal run:
+its file changes are journaled for 'thought undo' and access outside the
+policy prompts. It never hands over to the agent; agent.resume() just ends
+the run. Modules loaded with require() run without stopping.
+
+Examples:
+  thought debug weather "San Francisco"
+  thought debug weather -b 12 -b 30 -w data.temp -c`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runDebug,
+	SilenceUsage: true,
+}
+
+var (
+	debugBreakFlag    []int
+	debugWatchFlag    []string
+	debugContinueFlag bool
+)
+
+func init() {
+	debugCmd.Flags().IntSliceVarP(&debugBreakFlag, "break", "b", nil, "Set a breakpoint at a line (repeatable)")
+	debugCmd.Flags().StringArrayVarP(&debugWatchFlag, "watch", "w", nil, "Watch an expression (repeatable)")
+	debugCmd.Flags().BoolVarP(&debugContinueFlag, "continue", "c", false, "Run to the first breakpoint instead of stopping at the start")
+}
+
+func runDebug(cmd *cobra.Command, args []string) error {
+	resolved, err := ResolveThought(args[0], "debug")
+	if err != nil {
+		return err
+	}
+	thoughtDir := thoughtDirFor(resolved)
+	dataDir := config.ThoughtDataDir(thoughtDir)
+	memoryJSPath := filepath.Join(dataDir, "memory.js")
+	sharedDir := shared.Find(filepath.Base(thoughtDir), config.LoadConfig().ThoughtPath)
+	code, err := shared.ReadMemoryJS(memoryJSPath, sharedDir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no memory.js yet; run it first", filepath.Base(thoughtDir))
+	}
+	if err != nil {
+		return err
+	}
+	evalMode := ""
+	if resolved.Target != TargetURL {
+		if parsed, err := script.Parse(resolved.Path); err == nil && parsed.Config != nil {
+			evalMode = parsed.Config.Eval
+		}
+	}
+
+	session := debugger.New(string(code), os.Stdin, os.Stderr)
+	for _, line := range debugBreakFlag {
+		if err := session.Break(line); err != nil {
+			return err
+		}
+	}
+	for _, expr := range debugWatchFlag {
+		session.Watch(expr)
+	}
+	if debugContinueFlag {
+		session.Continue()
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	globalPolicyPath, _ := filepath.Abs(filepath.Join(config.HomeDir(), "policy.json"))
+	approver := approval.NewApprover(thoughtDir, globalPolicyPath)
+	defer approver.Close()
+	approver.SetContext(cmd.Context())
+	trust := config.TrustFor(config.ResolveOrigin(resolved.Path, thoughtDir))
+	approver.SetOriginDefaults(approval.OriginDefaults{
+		Paths: approval.Approval(trust.Paths),
+		Env:   approval.Approval(trust.Env),
+		Net:   approval.Approval(trust.Net),
+	})
+
+	// The same run as think's, in-process and paused by the session
+	res := boot.TryMemoryJS(cmd.Context(), boot.Config{
+		MemoryJSPath:  memoryJSPath,
+		SharedDir:     sharedDir,
+		WorkDir:       workDir,
+		ThoughtDir:    thoughtDir,
+		WorkspaceDir:  filepath.Join(dataDir, "workspace"),
+		MemoriesDir:   filepath.Join(dataDir, "memories"),
+		Args:          args[1:],
+		ApprovePath:   approver.ApprovePath,
+		PathDenied:    approver.PathDenied,
+		ApproveEnv:    approver.ApproveEnvRead,
+		ApproveNet:    approver.ApproveNet,
+		ApproveSecret: approver.ApproveSecret,
+		PromptInput:   approver.PromptInput,
+		Journal:       journal.New(thoughtDir),
+		Eval:          evalMode,
+		Debug:         session.Debug(),
+		Redact:        redact.New(),
+		Protected:     approver.ProtectedPaths(),
+	})
+	session.Finish()
+	switch {
+	case res.Resumed:
+		fmt.Fprintf(os.Stderr, "memory.js would hand over to the agent here (%s)\n", (&sandbox.ResumeError{Context: res.ResumeContext}).Error())
+		return nil
+	case res.Err != nil:
+		return res.Err
+	}
+	if res.Output != "" {
+		fmt.Println(res.Output)
+	}
+	return nil
+}
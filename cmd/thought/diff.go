@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/gitstate"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <thought>",
+	Short: "Show what the last run changed in memory.js and memories",
+	Long: `Thoughts with "git: true" in their frontmatter (or "git": true in
+config.json) keep memory.js and memories/ in a git repository, committed
+after every run that changes them. diff shows the most recent change with
+the run that made it (mode, model, status, duration).
+
+The repository is an ordinary one: use git log, git revert, or a remote to
+share converged thoughts with a team.
+
+Examples:
+  thought diff weather
+  thought diff weather -n 5 --stat`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runDiff,
+	SilenceUsage: true,
+}
+
+var (
+	diffCountFlag int
+	diffStatFlag  bool
+)
+
+func init() {
+	diffCmd.Flags().IntVarP(&diffCountFlag, "count", "n", 1, "Number of runs to show")
+	diffCmd.Flags().BoolVar(&diffStatFlag, "stat", false, "Summarize changed files instead of showing the diff")
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "diff")
+	if err != nil {
+		return err
+	}
+	if diffCountFlag < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	repo, err := gitstate.Load(config.ThoughtDataDir(thoughtDir))
+	if err != nil {
+		return err
+	}
+	return repo.Show(os.Stdout, diffCountFlag, diffStatFlag)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/examples"
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples",
+	Short: "Browse and install built-in example thoughts",
+	Long: `A few example thoughts ship with thought. List them, read one, or
+install it to ~/.thinkingscript/bin/ to try it.
+
+Examples:
+  thought examples ls
+  thought examples show organize
+  thought examples install weather`,
+	SilenceUsage: true,
+}
+
+var examplesListCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List example thoughts",
+	Args:         cobra.NoArgs,
+	RunE:         runExamplesList,
+	SilenceUsage: true,
+}
+
+var examplesShowCmd = &cobra.Command{
+	Use:          "show <name>",
+	Short:        "Print an example thought",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runExamplesShow,
+	SilenceUsage: true,
+}
+
+var examplesInstallCmd = &cobra.Command{
+	Use:          "install <name>",
+	Short:        "Install an example thought to the bin directory",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runExamplesInstall,
+	SilenceUsage: true,
+}
+
+var examplesForceFlag bool
+
+func init() {
+	examplesInstallCmd.Flags().BoolVar(&examplesForceFlag, "force", false, "Replace an installed thought with the same name")
+
+	examplesCmd.AddCommand(examplesListCmd)
+	examplesCmd.AddCommand(examplesShowCmd)
+	examplesCmd.AddCommand(examplesInstallCmd)
+}
+
+func getExample(name string) (examples.Example, error) {
+	e, ok := examples.Get(name)
+	if !ok {
+		return e, fmt.Errorf("no example %q (see 'thought examples ls')", name)
+	}
+	return e, nil
+}
+
+func runExamplesList(cmd *cobra.Command, args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tDESCRIPTION\tTRY")
+	for _, e := range examples.All {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", e.Name, e.Summary, e.Usage)
+	}
+	return w.Flush()
+}
+
+func runExamplesShow(cmd *cobra.Command, args []string) error {
+	e, err := getExample(args[0])
+	if err != nil {
+		return err
+	}
+	content, err := e.Content()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(content)
+	return err
+}
+
+func runExamplesInstall(cmd *cobra.Command, args []string) error {
+	e, err := getExample(args[0])
+	if err != nil {
+		return err
+	}
+	content, err := e.Content()
+	if err != nil {
+		return err
+	}
+	binPath := filepath.Join(config.BinDir(), e.Name)
+	if _, err := os.Stat(binPath); err == nil && !examplesForceFlag {
+		return fmt.Errorf("'%s' is already installed; use --force to replace it", e.Name)
+	}
+
+	// Examples ship with the binary; the installed copy is their source
+	origin := &config.Origin{
+		Origin:    config.OriginLocal,
+		Source:    binPath,
+		Installed: time.Now(),
+	}
+	outPath, err := installScript(e.Name, content, origin)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Installed example %s → %s\n", e.Name, outPath)
+	fmt.Fprintf(os.Stderr, "Try it: %s\n", e.Usage)
+	fmt.Fprintln(os.Stderr, "Make sure this is in your PATH:")
+	fmt.Fprintf(os.Stderr, "  export PATH=\"$PATH:%s\"\n", config.BinDir())
+	return nil
+}
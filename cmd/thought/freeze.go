@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/shared"
+)
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze <thought>",
+	Short: "Stop a converged thought from ever starting the agent",
+	Long: `Mark a thought as frozen. A frozen thought runs memory.js only: when
+memory.js fails or calls agent.resume(), the run fails with an error
+instead of calling the provider, so a converged thought can't silently
+cost money or send data to the API again. think --resume, --explain, and
+--show-prompt are refused for it, and so are embedders' runtime.Run calls
+that would start the agent.
+
+The thought must have a memory.js. Use 'thought unfreeze' to let the agent
+run again.
+
+Examples:
+  thought freeze weather
+  thought unfreeze weather`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runFreeze,
+	SilenceUsage: true,
+}
+
+var unfreezeCmd = &cobra.Command{
+	Use:          "unfreeze <thought>",
+	Short:        "Let a frozen thought start the agent again",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runUnfreeze,
+	SilenceUsage: true,
+}
+
+func runFreeze(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "freeze")
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(thoughtDir)
+	if f := config.LoadFrozen(thoughtDir); f != nil {
+		fmt.Fprintf(os.Stderr, "'%s' is already frozen (since %s)\n", name, f.Frozen.Local().Format("2006-01-02 15:04"))
+		return nil
+	}
+	if !hasMemoryJS(thoughtDir) {
+		return fmt.Errorf("'%s' has no memory.js; run it until it converges before freezing it", name)
+	}
+	if err := config.Freeze(thoughtDir); err != nil {
+		return fmt.Errorf("freezing %s: %w", name, err)
+	}
+	fmt.Fprintf(os.Stderr, "Froze '%s': runs use memory.js only and never start the agent\n", name)
+	return nil
+}
+
+func runUnfreeze(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "unfreeze")
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(thoughtDir)
+	if config.LoadFrozen(thoughtDir) == nil {
+		fmt.Fprintf(os.Stderr, "'%s' is not frozen\n", name)
+		return nil
+	}
+	if err := config.Unfreeze(thoughtDir); err != nil {
+		return fmt.Errorf("unfreezing %s: %w", name, err)
+	}
+	fmt.Fprintf(os.Stderr, "Unfroze '%s': the agent can run again\n", name)
+	return nil
+}
+
+// hasMemoryJS reports whether a thought has a memory.js to run, its own or
+// a shared one from thought_path.
+func hasMemoryJS(thoughtDir string) bool {
+	if _, err := os.Stat(filepath.Join(config.ThoughtDataDir(thoughtDir), "memory.js")); err == nil {
+		return true
+	}
+	sharedDir := shared.Find(filepath.Base(thoughtDir), config.LoadConfig().ThoughtPath)
+	if sharedDir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(sharedDir, "memory.js"))
+	return err == nil
+}
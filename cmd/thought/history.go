@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/runlog"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <thought> [id]",
+	Short: "List a thought's agent runs or show one's transcript",
+	Long: `Every run that reaches the agent saves its conversation in the thought's
+history/: the system prompt, each message, every tool call with its
+input and result, and why each response stopped. The last 50 runs are
+kept.
+
+With just a thought, lists the saved runs, newest first. With an ID (or
+any prefix of one that matches a single run), prints that run's
+transcript to see why the thought did what it did. Long tool inputs and
+results are cut to their first lines unless --full is given.
+
+Examples:
+  thought history weather
+  thought history weather 20260101-1200
+  thought history weather 20260101-120000 --full
+  thought history weather 20260101-120000 --json | jq .`,
+	Args:         cobra.RangeArgs(1, 2),
+	RunE:         runHistory,
+	SilenceUsage: true,
+}
+
+var (
+	historyFullFlag bool
+	historyJSONFlag bool
+)
+
+// historyLines is how many lines of a tool input or result are shown
+// without --full.
+const historyLines = 12
+
+func init() {
+	historyCmd.Flags().BoolVar(&historyFullFlag, "full", false, "Show the system prompt and tool inputs and results in full")
+	historyCmd.Flags().BoolVar(&historyJSONFlag, "json", false, "Print the transcript's JSONL entries as saved")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "history")
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		return listHistory(thoughtDir)
+	}
+	id, entries, err := runlog.ReadHistory(thoughtDir, args[1])
+	if err != nil {
+		return err
+	}
+	if historyJSONFlag {
+		data, err := os.ReadFile(filepath.Join(runlog.HistoryDir(thoughtDir), id+".jsonl"))
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	printTranscript(os.Stdout, id, entries, historyFullFlag)
+	return nil
+}
+
+func listHistory(thoughtDir string) error {
+	runs, err := runlog.ListHistory(thoughtDir)
+	if err != nil {
+		return fmt.Errorf("reading history: %w", err)
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(os.Stderr, "%s has no saved agent runs yet.\n", filepath.Base(thoughtDir))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tKIND\tMODEL\tSTATUS\tDURATION\tMESSAGES\tARGS")
+	for _, h := range runs {
+		messages := fmt.Sprint(h.Messages)
+		if h.Agents > 1 {
+			messages += fmt.Sprintf(" (%d agents)", h.Agents)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", h.ID, h.Run.Started.Local().Format("2006-01-02 15:04"),
+			orDash(h.Run.Kind), orDash(h.Run.Model), orDash(h.Run.Status), orDash(h.Run.Duration), messages, truncateArgs(h.Run.Args, 40))
+	}
+	return w.Flush()
+}
+
+// printTranscript writes entries as a readable conversation.
+func printTranscript(w io.Writer, id string, entries []runlog.Entry, full bool) {
+	tools := map[string]string{} // tool use ID → tool name, for results
+	multi := false
+	for _, e := range entries {
+		if e.Conversation > 1 {
+			multi = true
+		}
+	}
+	header := func(e runlog.Entry, title string) {
+		if multi {
+			title = fmt.Sprintf("#%d %s", e.Conversation, title)
+		}
+		fmt.Fprintf(w, "\n=== %s\n", title)
+	}
+
+	for _, e := range entries {
+		switch e.Type {
+		case "run":
+			run := e.Run
+			if run == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s  %s", run.Thought, id)
+			if run.Provider != "" {
+				fmt.Fprintf(w, "  %s", run.Provider)
+			}
+			fmt.Fprintf(w, "  %s\n", run.Started.Local().Format("2006-01-02 15:04:05"))
+			if len(run.Args) > 0 {
+				fmt.Fprintf(w, "args: %s\n", strings.Join(run.Args, " "))
+			}
+		case "system":
+			if full {
+				header(e, "system")
+				fmt.Fprintln(w, e.System)
+			} else {
+				header(e, fmt.Sprintf("system (%d chars; --full to show)", len(e.System)))
+			}
+		case "message":
+			if e.Message == nil {
+				continue
+			}
+			title := e.Message.Role
+			var notes []string
+			if e.StopReason != "" {
+				notes = append(notes, "stop: "+e.StopReason)
+			}
+			if e.Usage != nil {
+				note := fmt.Sprintf("%d in / %d out", e.Usage.InputTokens, e.Usage.OutputTokens)
+				if e.Usage.CachedTokens > 0 {
+					note = fmt.Sprintf("%d in (%d cached) / %d out", e.Usage.InputTokens, e.Usage.CachedTokens, e.Usage.OutputTokens)
+				}
+				notes = append(notes, note)
+			}
+			if e.LatencyMS > 0 {
+				notes = append(notes, (time.Duration(e.LatencyMS) * time.Millisecond).String())
+			}
+			if e.Model != "" {
+				notes = append(notes, "served by "+e.Model)
+			}
+			if len(notes) > 0 {
+				title += " (" + strings.Join(notes, ", ") + ")"
+			}
+			header(e, title)
+			for _, b := range e.Message.Content {
+				printBlock(w, b, tools, full)
+			}
+		case "end":
+			if e.Run == nil {
+				continue
+			}
+			fmt.Fprintf(w, "\n=== end: %s", e.Run.Status)
+			if e.Run.Duration != "" {
+				fmt.Fprintf(w, " in %s", e.Run.Duration)
+			}
+			fmt.Fprintln(w)
+			if e.Run.Error != "" {
+				fmt.Fprintf(w, "error: %s\n", e.Run.Error)
+			}
+		}
+	}
+	if len(entries) > 0 && entries[len(entries)-1].Type != "end" {
+		fmt.Fprintln(w, "\n=== (no end: the run was interrupted or is still going)")
+	}
+}
+
+func printBlock(w io.Writer, b provider.ContentBlock, tools map[string]string, full bool) {
+	switch b.Type {
+	case "text":
+		fmt.Fprintln(w, b.Text)
+	case "tool_use":
+		tools[b.ToolUseID] = b.ToolName
+		input := string(b.Input)
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, b.Input, "", "  ") == nil {
+			input = pretty.String()
+		}
+		// Scripts read better unquoted
+		var args struct {
+			Code string `json:"code"`
+		}
+		if json.Unmarshal(b.Input, &args) == nil && args.Code != "" {
+			input = args.Code
+		}
+		fmt.Fprintf(w, "→ %s\n%s\n", b.ToolName, indentLines(input, full))
+	case "tool_result":
+		label := "← " + orDash(tools[b.ToolUseIDRef])
+		if b.IsError {
+			label += " [error]"
+		}
+		fmt.Fprintf(w, "%s\n%s\n", label, indentLines(b.Content, full))
+	}
+}
+
+// indentLines indents s, cut to historyLines lines unless full.
+func indentLines(s string, full bool) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if !full && len(lines) > historyLines {
+		more := len(lines) - historyLines
+		lines = append(lines[:historyLines], fmt.Sprintf("… %d more lines (--full to show)", more))
+	}
+	return "    " + strings.Join(lines, "\n    ")
+}
+
+func truncateArgs(args []string, n int) string {
+	s := strings.Join(args, " ")
+	if len(s) > n {
+		return s[:n-1] + "…"
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
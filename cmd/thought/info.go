@@ -9,6 +9,8 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/approval"
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/lockfile"
+	"github.com/thinkingscript/cli/internal/shared"
 )
 
 var infoCmd = &cobra.Command{
@@ -50,8 +52,26 @@ func runInfo(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// Origin info
+	if origin := config.LoadOrigin(thoughtDir); origin != nil {
+		fmt.Printf("Origin: %s (%s)\n", origin.Origin, origin.Source)
+	} else {
+		fmt.Printf("Origin: %s (not recorded)\n", config.OriginLocal)
+	}
+
+	if f := config.LoadFrozen(thoughtDir); f != nil {
+		fmt.Printf("Frozen: since %s (memory.js only; 'thought unfreeze' to allow the agent)\n", f.Frozen.Local().Format("2006-01-02 15:04"))
+	}
+
 	// Workspace info
-	workspaceDir := filepath.Join(thoughtDir, "workspace")
+	dataDir := config.ThoughtDataDir(thoughtDir)
+	if dataDir != thoughtDir {
+		fmt.Printf("Data: %s\n", dataDir)
+	}
+	if sharedDir := shared.Find(filepath.Base(thoughtDir), config.LoadConfig().ThoughtPath); sharedDir != "" {
+		fmt.Printf("Shared: %s\n", sharedDir)
+	}
+	workspaceDir := filepath.Join(dataDir, "workspace")
 	workspaceSize, workspaceCount := dirStats(workspaceDir)
 	if workspaceCount > 0 {
 		fmt.Printf("Workspace: %s (%d files, %s)\n", workspaceDir, workspaceCount, formatBytes(workspaceSize))
@@ -60,7 +80,7 @@ func runInfo(cmd *cobra.Command, args []string) error {
 	}
 
 	// Memories info
-	memoriesDir := filepath.Join(thoughtDir, "memories")
+	memoriesDir := filepath.Join(dataDir, "memories")
 	_, memoryCount := dirStats(memoriesDir)
 	if memoryCount > 0 {
 		fmt.Printf("Memories: %s (%d files)\n", memoriesDir, memoryCount)
@@ -68,6 +88,20 @@ func runInfo(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Memories: %s (empty)\n", memoriesDir)
 	}
 
+	// Lockfile info
+	lockPath := lockfile.Path(dataDir)
+	if lock, err := lockfile.Load(lockPath); err != nil {
+		fmt.Printf("Lock: %s (error reading: %v)\n", lockPath, err)
+	} else if lock != nil {
+		fmt.Printf("Lock: %s (%d modules, %d APIs, since %s)\n", lockPath, len(lock.Modules), len(lock.APIs), lock.Created.Local().Format("2006-01-02 15:04"))
+		for _, m := range lock.Modules {
+			fmt.Printf("  Module: %s %s\n", m.Path, dash(m.Version))
+		}
+		for _, api := range lock.APIs {
+			fmt.Printf("  API: %s\n", api)
+		}
+	}
+
 	// Policy info
 	policyPath := filepath.Join(thoughtDir, "policy.json")
 	if _, err := os.Stat(policyPath); err == nil {
@@ -85,6 +119,12 @@ func runInfo(cmd *cobra.Command, args []string) error {
 			if len(policy.netSummary) > 0 {
 				fmt.Printf("  Net: %s\n", policy.netSummary)
 			}
+			if len(policy.toolSummary) > 0 {
+				fmt.Printf("  Tools: %s\n", policy.toolSummary)
+			}
+			if len(policy.secretSummary) > 0 {
+				fmt.Printf("  Secrets: %s\n", policy.secretSummary)
+			}
 		}
 	} else {
 		fmt.Printf("Policy: (default)\n")
@@ -121,9 +161,11 @@ func formatBytes(b int64) string {
 }
 
 type policySummary struct {
-	pathSummary string
-	envSummary  string
-	netSummary  string
+	pathSummary   string
+	envSummary    string
+	netSummary    string
+	toolSummary   string
+	secretSummary string
 }
 
 func loadPolicySummary(path string) (*policySummary, error) {
@@ -181,5 +223,33 @@ func loadPolicySummary(path string) (*policySummary, error) {
 		summary.netSummary = fmt.Sprintf("%d hosts allowed, %d denied", allowedHosts, deniedHosts)
 	}
 
+	// Summarize MCP tools
+	allowedTools := 0
+	deniedTools := 0
+	for _, entry := range policy.Tools.Entries {
+		if entry.Approval == approval.ApprovalAllow {
+			allowedTools++
+		} else if entry.Approval == approval.ApprovalDeny {
+			deniedTools++
+		}
+	}
+	if allowedTools > 0 || deniedTools > 0 {
+		summary.toolSummary = fmt.Sprintf("%d allowed, %d denied", allowedTools, deniedTools)
+	}
+
+	// Summarize secrets
+	allowedSecrets := 0
+	deniedSecrets := 0
+	for _, entry := range policy.Secrets.Entries {
+		if entry.Approval == approval.ApprovalAllow {
+			allowedSecrets++
+		} else if entry.Approval == approval.ApprovalDeny {
+			deniedSecrets++
+		}
+	}
+	if allowedSecrets > 0 || deniedSecrets > 0 {
+		summary.secretSummary = fmt.Sprintf("%d allowed, %d denied", allowedSecrets, deniedSecrets)
+	}
+
 	return summary, nil
 }
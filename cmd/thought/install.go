@@ -6,15 +6,17 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/script"
 	"github.com/spf13/cobra"
 )
 
 var installCmd = &cobra.Command{
 	Use:          "install <script>",
 	Short:        "Build and install a thought to the bin directory",
-	Long:         "Builds a script with shebang and executable permissions, then installs it to ~/.thinkingscript/bin/. Add that directory to your PATH to run scripts by name.",
+	Long:         "Builds a script with shebang and executable permissions, then installs it to ~/.thinkingscript/bin/. Add that directory to your PATH to run scripts by name.\n\nAccepts a local file or URL. The origin is recorded so that thoughts installed from URLs get the stricter \"url\" trust defaults from config.json.",
 	Args:         cobra.ExactArgs(1),
 	RunE:         runInstall,
 	SilenceUsage: true,
@@ -23,11 +25,38 @@ var installCmd = &cobra.Command{
 func runInstall(cmd *cobra.Command, args []string) error {
 	inputPath := args[0]
 
-	content, err := os.ReadFile(inputPath)
+	content, err := script.Read(inputPath)
 	if err != nil {
 		return fmt.Errorf("reading input file: %w", err)
 	}
 
+	origin := &config.Origin{
+		Origin:    config.OriginLocal,
+		Source:    inputPath,
+		Installed: time.Now(),
+	}
+	if script.IsURL(inputPath) {
+		origin.Origin = config.OriginURL
+	} else if abs, err := filepath.Abs(inputPath); err == nil {
+		origin.Source = abs
+	}
+
+	// Strip known extensions for the binary name
+	outPath, err := installScript(config.ThoughtName(inputPath), content, origin)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Installed %s → %s (origin: %s)\n", inputPath, outPath, origin.Origin)
+	fmt.Fprintln(os.Stderr, "Make sure this is in your PATH:")
+	fmt.Fprintf(os.Stderr, "  export PATH=\"$PATH:%s\"\n", config.BinDir())
+	return nil
+}
+
+// installScript writes content to the bin directory as name, adding the
+// shebang if it's missing, and records its origin. Returns the installed
+// path.
+func installScript(name string, content []byte, origin *config.Origin) (string, error) {
 	shebang := "#!/usr/bin/env think\n"
 	body := string(content)
 	if !strings.HasPrefix(body, shebang) {
@@ -36,13 +65,9 @@ func runInstall(cmd *cobra.Command, args []string) error {
 
 	dir := config.BinDir()
 	if err := os.MkdirAll(dir, 0700); err != nil {
-		return fmt.Errorf("creating bin directory: %w", err)
+		return "", fmt.Errorf("creating bin directory: %w", err)
 	}
 
-	// Strip known extensions for the binary name
-	base := filepath.Base(inputPath)
-	name := strings.TrimSuffix(base, filepath.Ext(base))
-
 	// Warn if an existing command would shadow this thought
 	if existing, err := exec.LookPath(name); err == nil {
 		fmt.Fprintf(os.Stderr, "Warning: %q already exists at %s and will shadow the installed thought\n", name, existing)
@@ -50,11 +75,21 @@ func runInstall(cmd *cobra.Command, args []string) error {
 
 	outPath := filepath.Join(dir, name)
 	if err := os.WriteFile(outPath, []byte(body), 0755); err != nil {
-		return fmt.Errorf("writing script: %w", err)
+		return "", fmt.Errorf("writing script: %w", err)
 	}
 
-	fmt.Fprintf(os.Stderr, "Installed %s → %s\n", inputPath, outPath)
-	fmt.Fprintln(os.Stderr, "Make sure this is in your PATH:")
-	fmt.Fprintf(os.Stderr, "  export PATH=\"$PATH:%s\"\n", dir)
-	return nil
+	// The origin lives with the thought's data, which a frontmatter name
+	// can move away from the command name
+	thoughtDir := filepath.Join(config.HomeDir(), "thoughts", name)
+	if parsed, err := script.Parse(outPath); err == nil && parsed.Config != nil && parsed.Config.Name != "" {
+		if err := config.ValidateThoughtName(parsed.Config.Name); err != nil {
+			os.Remove(outPath)
+			return "", err
+		}
+		thoughtDir = filepath.Join(config.HomeDir(), "thoughts", parsed.Config.Name)
+	}
+	if err := config.SaveOrigin(thoughtDir, origin); err != nil {
+		return "", fmt.Errorf("recording origin: %w", err)
+	}
+	return outPath, nil
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/i18n"
+)
+
+var templateFlag bool
+
+var localeCmd = &cobra.Command{
+	Use:   "locale",
+	Short: "Show the language prompts are shown in",
+	Long: "Show the locale think uses for prompts and labels, the built-in translations, and where your own translations go.\n" +
+		"Set it with THINKINGSCRIPT__LOCALE or \"locale\" in config.json; otherwise LC_ALL, LC_MESSAGES, or LANG decide.\n" +
+		"--template prints the English catalog to start a translation from.",
+	Args:         cobra.NoArgs,
+	RunE:         runLocale,
+	SilenceUsage: true,
+}
+
+func init() {
+	localeCmd.Flags().BoolVar(&templateFlag, "template", false, "Print the English catalog as a starting point for a translation")
+}
+
+func runLocale(cmd *cobra.Command, args []string) error {
+	if templateFlag {
+		data, err := json.MarshalIndent(i18n.Builtin(i18n.Source), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("locale:    %s\n", i18n.Locale())
+	fmt.Printf("built-in:  %s\n", strings.Join(i18n.Locales(), ", "))
+	fmt.Printf("yours:     %s\n", filepath.Join(i18n.Dir(), "<locale>.json"))
+	if entries, err := os.ReadDir(i18n.Dir()); err == nil {
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".json") {
+				fmt.Printf("           %s\n", e.Name())
+			}
+		}
+	}
+	return nil
+}
@@ -51,7 +51,7 @@ func listAllMemories() error {
 		if !e.IsDir() {
 			continue
 		}
-		memoriesDir := filepath.Join(thoughtsBase, e.Name(), "memories")
+		memoriesDir := filepath.Join(config.ThoughtDataDir(filepath.Join(thoughtsBase, e.Name())), "memories")
 		memEntries, err := os.ReadDir(memoriesDir)
 		if err != nil || len(memEntries) == 0 {
 			continue
@@ -77,12 +77,7 @@ func listScriptMemories(arg string) error {
 		return err
 	}
 
-	var memoriesDir string
-	if resolved.Target == TargetInstalled {
-		memoriesDir = filepath.Join(config.HomeDir(), "thoughts", resolved.Name, "memories")
-	} else {
-		memoriesDir = config.MemoriesDir(resolved.Path)
-	}
+	memoriesDir := filepath.Join(config.ThoughtDataDir(thoughtDirFor(resolved)), "memories")
 
 	entries, err := os.ReadDir(memoriesDir)
 	if err != nil {
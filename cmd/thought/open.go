@@ -4,11 +4,9 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
 	goruntime "runtime"
 
 	"github.com/spf13/cobra"
-	"github.com/thinkingscript/cli/internal/config"
 )
 
 var openCmd = &cobra.Command{
@@ -26,12 +24,7 @@ func runOpen(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var thoughtDir string
-	if resolved.Target == TargetInstalled {
-		thoughtDir = filepath.Join(config.HomeDir(), "thoughts", resolved.Name)
-	} else {
-		thoughtDir = config.ThoughtDir(resolved.Path)
-	}
+	thoughtDir := thoughtDirFor(resolved)
 
 	if _, err := os.Stat(thoughtDir); os.IsNotExist(err) {
 		fmt.Fprintln(os.Stderr, "No thought data yet.")
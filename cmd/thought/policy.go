@@ -3,18 +3,23 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/approval"
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/managed"
 )
 
 var policyCmd = &cobra.Command{
 	Use:          "policy",
 	Short:        "Manage policy settings",
-	Long:         "View and manage policy entries for paths, environment variables, and network hosts.",
+	Long:         "View and manage policy entries for paths, environment variables, network hosts, MCP tools, and secrets.",
 	SilenceUsage: true,
 }
 
@@ -22,7 +27,7 @@ var policyListCmd = &cobra.Command{
 	Use:          "ls [name]",
 	Aliases:      []string{"list"},
 	Short:        "List policy entries",
-	Long:         "List all policy entries for an installed thought.\nIf no name is provided, lists the global policy.",
+	Long:         "List all policy entries for an installed thought with their source, creation time, and note.\nIf no name is provided, lists the global policy.",
 	Args:         cobra.MaximumNArgs(1),
 	RunE:         runPolicyList,
 	SilenceUsage: true,
@@ -31,13 +36,30 @@ var policyListCmd = &cobra.Command{
 var policyAddCmd = &cobra.Command{
 	Use:   "add <type> <name> <value>",
 	Short: "Add a policy entry",
-	Long: `Add a policy entry for an installed thought. Type must be 'path', 'env', or 'host'.
+	Long: `Add a policy entry for an installed thought. Type must be 'path', 'env', 'host', 'tool', or 'secret'.
+
+Tool entries name MCP servers (mcp__<server>, for starting it) and their
+tools (mcp__<server>__<tool>); a trailing * matches a prefix. Secret entries
+name secrets in the OS credential store that secrets.get may read.
+
+With --global, the entry goes in the global policy; leave out the name.
+
+Some locations are protected by default: ~/.ssh, ~/.gnupg,
+~/.aws/credentials, browser profiles, and thinkingscript's agents/,
+managed/, and global policy.json. No thought policy can allow them; only a
+protected entry in the global policy can, which takes
+--global --i-know-what-im-doing.
 
 Examples:
   thought policy add path myapp /Users/brad/data --mode rwd
   thought policy add env myapp HOME
-  thought policy add host myapp "*.github.com"`,
-	Args:         cobra.ExactArgs(3),
+  thought policy add host myapp "*.github.com"
+  thought policy add host weather api.weather.gov --note "for weather API"
+  thought policy add tool myapp "mcp__github__*"
+  thought policy add secret myapp GITHUB_TOKEN
+  thought policy add host --global api.github.com
+  thought policy add path --global ~/.ssh/known_hosts --mode r --i-know-what-im-doing`,
+	Args:         policyEntryArgs,
 	RunE:         runPolicyAdd,
 	SilenceUsage: true,
 }
@@ -46,29 +68,90 @@ var policyRemoveCmd = &cobra.Command{
 	Use:   "rm <type> <name> <value>",
 	Aliases: []string{"remove"},
 	Short: "Remove a policy entry",
-	Long: `Remove a policy entry from an installed thought. Type must be 'path', 'env', or 'host'.
+	Long: `Remove a policy entry from an installed thought. Type must be 'path', 'env', 'host', 'tool', or 'secret'.
+
+With --global, the entry is removed from the global policy (including
+protected entries); leave out the name.
 
 Examples:
   thought policy rm path myapp /Users/brad/data
   thought policy rm env myapp HOME
-  thought policy rm host myapp "*.github.com"`,
-	Args:         cobra.ExactArgs(3),
+  thought policy rm host myapp "*.github.com"
+  thought policy rm tool myapp "mcp__github__*"
+  thought policy rm secret myapp GITHUB_TOKEN`,
+	Args:         policyEntryArgs,
 	RunE:         runPolicyRemove,
 	SilenceUsage: true,
 }
 
+var policyExportCmd = &cobra.Command{
+	Use:   "export <name>",
+	Short: "Export a thought's policy to share",
+	Long: `Print a thought's policy as JSON to share with a team or copy to another
+machine. Only the decisions you made are exported: defaults generated at
+first run, managed entries, and time-limited grants are left out, and paths
+under your home directory are written as ~/... so they resolve on the
+importing machine.
+
+Examples:
+  thought policy export weather > weather-policy.json
+  thought policy export weather -o weather-policy.json`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runPolicyExport,
+	SilenceUsage: true,
+}
+
+var policyImportCmd = &cobra.Command{
+	Use:   "import <name> [file.json|-]",
+	Short: "Import a shared policy or a built-in template",
+	Long: `Merge an exported policy file (or - for stdin) or a built-in template into
+a thought's policy. Defaults in the file replace the thought's; each entry
+replaces the thought's entry for the same path, variable, host, port, or
+tool, and is added otherwise. Imported entries show source 'import' in
+'thought policy ls'.
+
+Examples:
+  thought policy import weather weather-policy.json
+  thought policy import weather --template offline
+  thought policy export weather | thought policy import forecast -`,
+	Args:         cobra.RangeArgs(1, 2),
+	RunE:         runPolicyImport,
+	SilenceUsage: true,
+}
+
 var (
+	policyGlobalFlag   bool
+	policyOverrideFlag bool
+	policyOutputFlag   string
+	policyTemplateFlag string
 	policyModeFlag     string
 	policyApprovalFlag string
+	policyNoteFlag     string
+	policySortFlag     string
+	policyJSONFlag     bool
 )
 
 func init() {
 	policyAddCmd.Flags().StringVar(&policyModeFlag, "mode", "rwd", "Permission mode for paths (r=read, w=write, d=delete)")
 	policyAddCmd.Flags().StringVar(&policyApprovalFlag, "approval", "allow", "Approval decision (allow, deny, prompt)")
+	policyAddCmd.Flags().StringVar(&policyNoteFlag, "note", "", "Why this entry exists (shown in policy ls)")
+	policyAddCmd.Flags().BoolVar(&policyGlobalFlag, "global", false, "Add to the global policy instead of a thought's")
+	policyAddCmd.Flags().BoolVar(&policyOverrideFlag, "i-know-what-im-doing", false, "Allow a path that is protected by default (with --global)")
+	policyRemoveCmd.Flags().BoolVar(&policyGlobalFlag, "global", false, "Remove from the global policy instead of a thought's")
+	policyListCmd.Flags().StringVar(&policySortFlag, "sort", "", "Sort entries by: created, value, type")
+	policyListCmd.Flags().BoolVar(&policyJSONFlag, "json", false, "Print the raw policy JSON")
+	policyExportCmd.Flags().StringVarP(&policyOutputFlag, "output", "o", "", "Write to this file instead of stdout")
+	policyImportCmd.Flags().StringVar(&policyTemplateFlag, "template", "", "Import a built-in template instead of a file")
+	policyImportCmd.Long += "\n\nTemplates:"
+	for _, t := range approval.Templates {
+		policyImportCmd.Long += fmt.Sprintf("\n  %-13s %s", t.Name, t.Description)
+	}
 
 	policyCmd.AddCommand(policyListCmd)
 	policyCmd.AddCommand(policyAddCmd)
 	policyCmd.AddCommand(policyRemoveCmd)
+	policyCmd.AddCommand(policyExportCmd)
+	policyCmd.AddCommand(policyImportCmd)
 }
 
 func runPolicyList(cmd *cobra.Command, args []string) error {
@@ -85,21 +168,173 @@ func runPolicyList(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("loading policy: %w", err)
 	}
 
-	data, err := json.MarshalIndent(policy, "", "  ")
+	if policyJSONFlag {
+		data, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			return fmt.Errorf("formatting policy: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	rows := policyRows(policy)
+	if len(args) == 0 {
+		rows = append(append(managedRows(), rows...), builtinRows()...)
+	}
+	switch policySortFlag {
+	case "":
+	case "created":
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Created.Before(rows[j].Created) })
+	case "value":
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Value < rows[j].Value })
+	case "type":
+		sort.SliceStable(rows, func(i, j int) bool { return rows[i].Type < rows[j].Type })
+	default:
+		return fmt.Errorf("invalid sort: %s (must be created, value, or type)", policySortFlag)
+	}
+
+	fmt.Printf("Defaults: paths=%s env=%s net=%s tools=%s secrets=%s\n", policy.Paths.Default, policy.Env.Default, policy.Net.Hosts.Default, dash(string(policy.Tools.Default)), dash(string(policy.Secrets.Default)))
+	if len(rows) == 0 {
+		fmt.Println("No entries.")
+		return nil
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tVALUE\tMODE\tAPPROVAL\tSOURCE\tCREATED\tNOTE")
+	for _, r := range rows {
+		created := "-"
+		if !r.Created.IsZero() {
+			created = r.Created.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Type, r.Value, dash(r.Mode), r.Approval, dash(string(r.Source)), created, r.Note)
+	}
+	return w.Flush()
+}
+
+// policyRow is one policy entry flattened for display.
+type policyRow struct {
+	Type     string // path, protected, env, host, tool, secret
+	Value    string
+	Mode     string
+	Approval approval.Approval
+	Source   approval.Source
+	Created  time.Time
+	Note     string
+}
+
+func policyRows(p *approval.Policy) []policyRow {
+	var rows []policyRow
+	for _, e := range p.Paths.Protected {
+		rows = append(rows, policyRow{"protected", e.Path, e.Mode, e.Approval, e.Source, e.Created, e.Note})
+	}
+	for _, e := range p.Paths.Entries {
+		rows = append(rows, policyRow{"path", e.Path, e.Mode, e.Approval, e.Source, e.Created, expiryNote(e.Note, e.Expires)})
+	}
+	for _, e := range p.Env.Protected {
+		rows = append(rows, policyRow{"protected", "env:" + e.Name, "", e.Approval, e.Source, e.Created, e.Note})
+	}
+	for _, e := range p.Net.Hosts.Protected {
+		rows = append(rows, policyRow{"protected", "host:" + e.Host, "", e.Approval, e.Source, e.Created, e.Note})
+	}
+	for _, e := range p.Tools.Protected {
+		rows = append(rows, policyRow{"protected", "tool:" + e.Tool, "", e.Approval, e.Source, e.Created, e.Note})
+	}
+	for _, e := range p.Secrets.Protected {
+		rows = append(rows, policyRow{"protected", "secret:" + e.Name, "", e.Approval, e.Source, e.Created, e.Note})
+	}
+	for _, e := range p.Env.Entries {
+		rows = append(rows, policyRow{"env", e.Name, "", e.Approval, e.Source, e.Created, expiryNote(e.Note, e.Expires)})
+	}
+	for _, e := range p.Net.Hosts.Entries {
+		rows = append(rows, policyRow{"host", e.Host, "", e.Approval, e.Source, e.Created, expiryNote(e.Note, e.Expires)})
+	}
+	for _, e := range p.Tools.Entries {
+		rows = append(rows, policyRow{"tool", e.Tool, "", e.Approval, e.Source, e.Created, expiryNote(e.Note, e.Expires)})
+	}
+	for _, e := range p.Secrets.Entries {
+		rows = append(rows, policyRow{"secret", e.Name, "", e.Approval, e.Source, e.Created, expiryNote(e.Note, e.Expires)})
+	}
+	return rows
+}
+
+// expiryNote adds when a time-limited entry expires to its note.
+func expiryNote(note string, expires *time.Time) string {
+	if expires == nil {
+		return note
+	}
+	e := "expires " + expires.Local().Format("2006-01-02 15:04")
+	if time.Now().After(*expires) {
+		e = "expired"
+	}
+	if note == "" {
+		return "(" + e + ")"
+	}
+	return note + " (" + e + ")"
+}
+
+// managedRows lists the cached managed policy, which applies on top of the
+// global policy as protected entries. Nothing is fetched here.
+func managedRows() []policyRow {
+	settings, err := managed.FromConfig(config.LoadConfig())
+	if err != nil || settings == nil {
+		return nil
+	}
+	policy, _, err := managed.Cached(settings)
 	if err != nil {
-		return fmt.Errorf("formatting policy: %w", err)
+		fmt.Fprintf(os.Stderr, "Managed policy: not cached yet (%s)\n", settings.URL)
+		return nil
 	}
-	fmt.Println(string(data))
-	return nil
+	m := approval.NewPolicy()
+	m.Paths.Protected = append(policy.Paths.Protected, policy.Paths.Entries...)
+	m.Env.Protected = append(policy.Env.Protected, policy.Env.Entries...)
+	m.Net.Hosts.Protected = append(policy.Net.Hosts.Protected, policy.Net.Hosts.Entries...)
+	m.Tools.Protected = append(policy.Tools.Protected, policy.Tools.Entries...)
+	m.Secrets.Protected = append(policy.Secrets.Protected, policy.Secrets.Entries...)
+	rows := policyRows(m)
+	for i := range rows {
+		rows[i].Type = "managed"
+		rows[i].Source = approval.SourceManaged
+	}
+	return rows
 }
 
-func runPolicyAdd(cmd *cobra.Command, args []string) error {
-	entryType := args[0]
-	thoughtName := args[1]
-	value := args[2]
+// builtinRows returns the built-in protected entries every global policy
+// ends with.
+func builtinRows() []policyRow {
+	home, _ := os.UserHomeDir()
+	m := approval.NewPolicy()
+	m.Paths.Protected = approval.BuiltinProtected(home, config.HomeDir())
+	return policyRows(m)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// policyEntryArgs checks add and rm arguments: <type> <name> <value>, or
+// <type> <value> with --global.
+func policyEntryArgs(cmd *cobra.Command, args []string) error {
+	if policyGlobalFlag {
+		return cobra.ExactArgs(2)(cmd, args)
+	}
+	return cobra.ExactArgs(3)(cmd, args)
+}
+
+// policyEntryTarget returns the entry type, value, and policy file that add
+// and rm arguments name.
+func policyEntryTarget(args []string) (entryType, value, policyPath string) {
+	if policyGlobalFlag {
+		return args[0], args[1], filepath.Join(config.HomeDir(), "policy.json")
+	}
+	return args[0], args[2], filepath.Join(config.HomeDir(), "thoughts", args[1], "policy.json")
+}
 
-	thoughtDir := filepath.Join(config.HomeDir(), "thoughts", thoughtName)
-	policyPath := filepath.Join(thoughtDir, "policy.json")
+func runPolicyAdd(cmd *cobra.Command, args []string) error {
+	entryType, value, policyPath := policyEntryTarget(args)
 
 	policy, err := approval.LoadPolicy(policyPath)
 	if err != nil {
@@ -113,16 +348,34 @@ func runPolicyAdd(cmd *cobra.Command, args []string) error {
 
 	switch entryType {
 	case "path":
-		policy.AddPathEntry(value, policyModeFlag, approvalVal, approval.SourceCLI)
+		home, _ := os.UserHomeDir()
+		abs, _ := filepath.Abs(value)
+		if b := approval.BuiltinProtectedPath(abs, home, config.HomeDir()); b != nil && approvalVal != approval.ApprovalDeny {
+			if !policyGlobalFlag || !policyOverrideFlag {
+				return fmt.Errorf("%s is protected by default (%s); only the global policy can allow it, with --global --i-know-what-im-doing", value, b.Path)
+			}
+			// Protected entries are checked in order, ahead of the built-in ones
+			entry := approval.PathEntry{Path: abs, Mode: policyModeFlag, Approval: approvalVal, Source: approval.SourceCLI, Created: time.Now(), Note: policyNoteFlag}
+			policy.Paths.Protected = append([]approval.PathEntry{entry}, policy.Paths.Protected...)
+			fmt.Fprintf(os.Stderr, "Added protected path entry: %s (mode=%s, approval=%s), overriding the built-in deny for %s\n", abs, policyModeFlag, policyApprovalFlag, b.Path)
+			break
+		}
+		policy.AddPathEntry(value, policyModeFlag, approvalVal, approval.SourceCLI).Note = policyNoteFlag
 		fmt.Fprintf(os.Stderr, "Added path entry: %s (mode=%s, approval=%s)\n", value, policyModeFlag, policyApprovalFlag)
 	case "env":
-		policy.AddEnvEntry(value, approvalVal, approval.SourceCLI)
+		policy.AddEnvEntry(value, approvalVal, approval.SourceCLI).Note = policyNoteFlag
 		fmt.Fprintf(os.Stderr, "Added env entry: %s (approval=%s)\n", value, policyApprovalFlag)
 	case "host":
-		policy.AddHostEntry(value, approvalVal, approval.SourceCLI)
+		policy.AddHostEntry(value, approvalVal, approval.SourceCLI).Note = policyNoteFlag
 		fmt.Fprintf(os.Stderr, "Added host entry: %s (approval=%s)\n", value, policyApprovalFlag)
+	case "tool":
+		policy.AddToolEntry(value, approvalVal, approval.SourceCLI).Note = policyNoteFlag
+		fmt.Fprintf(os.Stderr, "Added tool entry: %s (approval=%s)\n", value, policyApprovalFlag)
+	case "secret":
+		policy.AddSecretEntry(value, approvalVal, approval.SourceCLI).Note = policyNoteFlag
+		fmt.Fprintf(os.Stderr, "Added secret entry: %s (approval=%s)\n", value, policyApprovalFlag)
 	default:
-		return fmt.Errorf("invalid type: %s (must be path, env, or host)", entryType)
+		return fmt.Errorf("invalid type: %s (must be path, env, host, tool, or secret)", entryType)
 	}
 
 	if err := policy.Save(policyPath); err != nil {
@@ -133,12 +386,7 @@ func runPolicyAdd(cmd *cobra.Command, args []string) error {
 }
 
 func runPolicyRemove(cmd *cobra.Command, args []string) error {
-	entryType := args[0]
-	thoughtName := args[1]
-	value := args[2]
-
-	thoughtDir := filepath.Join(config.HomeDir(), "thoughts", thoughtName)
-	policyPath := filepath.Join(thoughtDir, "policy.json")
+	entryType, value, policyPath := policyEntryTarget(args)
 
 	policy, err := approval.LoadPolicy(policyPath)
 	if err != nil {
@@ -157,6 +405,17 @@ func runPolicyRemove(cmd *cobra.Command, args []string) error {
 			}
 		}
 		policy.Paths.Entries = newEntries
+		if policyGlobalFlag {
+			protected := make([]approval.PathEntry, 0, len(policy.Paths.Protected))
+			for _, e := range policy.Paths.Protected {
+				if e.Path != value {
+					protected = append(protected, e)
+				} else {
+					removed = true
+				}
+			}
+			policy.Paths.Protected = protected
+		}
 
 	case "env":
 		newEntries := make([]approval.EnvEntry, 0, len(policy.Env.Entries))
@@ -180,8 +439,30 @@ func runPolicyRemove(cmd *cobra.Command, args []string) error {
 		}
 		policy.Net.Hosts.Entries = newEntries
 
+	case "tool":
+		newEntries := make([]approval.ToolEntry, 0, len(policy.Tools.Entries))
+		for _, e := range policy.Tools.Entries {
+			if e.Tool != value {
+				newEntries = append(newEntries, e)
+			} else {
+				removed = true
+			}
+		}
+		policy.Tools.Entries = newEntries
+
+	case "secret":
+		newEntries := make([]approval.SecretEntry, 0, len(policy.Secrets.Entries))
+		for _, e := range policy.Secrets.Entries {
+			if e.Name != value {
+				newEntries = append(newEntries, e)
+			} else {
+				removed = true
+			}
+		}
+		policy.Secrets.Entries = newEntries
+
 	default:
-		return fmt.Errorf("invalid type: %s (must be path, env, or host)", entryType)
+		return fmt.Errorf("invalid type: %s (must be path, env, host, tool, or secret)", entryType)
 	}
 
 	if !removed {
@@ -195,3 +476,81 @@ func runPolicyRemove(cmd *cobra.Command, args []string) error {
 	fmt.Fprintf(os.Stderr, "Removed %s entry: %s\n", entryType, value)
 	return nil
 }
+
+func runPolicyExport(cmd *cobra.Command, args []string) error {
+	policyPath := filepath.Join(config.HomeDir(), "thoughts", args[0], "policy.json")
+	policy, err := approval.LoadPolicy(policyPath)
+	if err != nil {
+		return fmt.Errorf("loading policy: %w", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	data, err := json.MarshalIndent(policy.Export(home), "", "  ")
+	if err != nil {
+		return fmt.Errorf("formatting policy: %w", err)
+	}
+	data = append(data, '\n')
+
+	if policyOutputFlag == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(policyOutputFlag, data, 0644); err != nil {
+		return fmt.Errorf("writing policy: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported policy for '%s' to %s\n", args[0], policyOutputFlag)
+	return nil
+}
+
+func runPolicyImport(cmd *cobra.Command, args []string) error {
+	thoughtName := args[0]
+
+	var from *approval.Policy
+	var what string
+	switch {
+	case policyTemplateFlag != "" && len(args) == 2:
+		return fmt.Errorf("give a file or --template, not both")
+	case policyTemplateFlag != "":
+		p, err := approval.LoadTemplate(policyTemplateFlag)
+		if err != nil {
+			return err
+		}
+		from, what = p, "template "+policyTemplateFlag
+	case len(args) == 2:
+		var data []byte
+		var err error
+		if args[1] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(args[1])
+		}
+		if err != nil {
+			return fmt.Errorf("reading policy: %w", err)
+		}
+		p, err := approval.ParsePolicy(data)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", args[1], err)
+		}
+		from, what = p, args[1]
+	default:
+		return fmt.Errorf("give a policy file to import, or --template")
+	}
+
+	policyPath := filepath.Join(config.HomeDir(), "thoughts", thoughtName, "policy.json")
+	policy, err := approval.LoadPolicy(policyPath)
+	if err != nil {
+		return fmt.Errorf("loading policy: %w", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	n, err := policy.Import(from, home)
+	if err != nil {
+		return fmt.Errorf("importing %s: %w", what, err)
+	}
+	if err := policy.Save(policyPath); err != nil {
+		return fmt.Errorf("saving policy: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Imported %s into '%s' (%d entries)\n", what, thoughtName, n)
+	return nil
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/ui"
+	"golang.org/x/term"
+)
+
+var policyReviewCmd = &cobra.Command{
+	Use:   "review",
+	Short: "Review policy entries across all thoughts",
+	Long: `Open an interactive list of every policy entry, global and per-thought.
+
+Keys:
+  up/down   move              /       filter
+  space     select            a       select all shown
+  d         delete            f       flip allow/deny
+  g         copy to global    q       save and quit
+  ctrl+c    quit without saving
+
+Actions apply to the selected entries, or to the entry under the cursor
+when nothing is selected. Protected entries are not listed.`,
+	Args:         cobra.NoArgs,
+	RunE:         runPolicyReview,
+	SilenceUsage: true,
+}
+
+func init() {
+	policyCmd.AddCommand(policyReviewCmd)
+}
+
+// reviewFile is one policy.json loaded for review.
+type reviewFile struct {
+	scope  string // "global" or the thought name
+	path   string
+	policy *approval.Policy
+	dirty  bool
+}
+
+// reviewItem points at one entry inside a reviewFile. Index is the
+// position within the entry list for its type.
+type reviewItem struct {
+	file  *reviewFile
+	index int
+	row   policyRow
+}
+
+func runPolicyReview(cmd *cobra.Command, args []string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("policy review needs a terminal; use 'thought policy ls' instead")
+	}
+
+	files, err := loadReviewFiles()
+	if err != nil {
+		return err
+	}
+
+	m := newReviewModel(files)
+	final, err := tea.NewProgram(m, tea.WithOutput(os.Stderr)).Run()
+	if err != nil {
+		return err
+	}
+	m = final.(reviewModel)
+	if !m.save {
+		fmt.Fprintln(os.Stderr, "No changes saved.")
+		return nil
+	}
+
+	var saved int
+	for _, f := range m.files {
+		if !f.dirty {
+			continue
+		}
+		if err := f.policy.Save(f.path); err != nil {
+			return fmt.Errorf("saving %s policy: %w", f.scope, err)
+		}
+		saved++
+	}
+	if saved == 0 {
+		fmt.Fprintln(os.Stderr, "No changes.")
+	} else {
+		fmt.Fprintf(os.Stderr, "Saved %d policy file(s).\n", saved)
+	}
+	return nil
+}
+
+// loadReviewFiles loads the global policy followed by every installed
+// thought's policy, sorted by thought name.
+func loadReviewFiles() ([]*reviewFile, error) {
+	globalPath := filepath.Join(config.HomeDir(), "policy.json")
+	global, err := approval.LoadPolicy(globalPath)
+	if err != nil {
+		return nil, fmt.Errorf("loading global policy: %w", err)
+	}
+	files := []*reviewFile{{scope: "global", path: globalPath, policy: global}}
+
+	dirs, err := os.ReadDir(filepath.Join(config.HomeDir(), "thoughts"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading thoughts: %w", err)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name() < dirs[j].Name() })
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		path := filepath.Join(config.HomeDir(), "thoughts", d.Name(), "policy.json")
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		p, err := approval.LoadPolicy(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s policy: %w", d.Name(), err)
+		}
+		files = append(files, &reviewFile{scope: d.Name(), path: path, policy: p})
+	}
+	return files, nil
+}
+
+// reviewItems flattens the entries of all files in display order. Protected
+// entries are skipped; they are managed by hand in the global policy.
+func reviewItems(files []*reviewFile) []reviewItem {
+	var items []reviewItem
+	for _, f := range files {
+		counts := map[string]int{}
+		for _, r := range policyRows(f.policy) {
+			i := counts[r.Type]
+			counts[r.Type]++
+			if r.Type == "protected" {
+				continue
+			}
+			items = append(items, reviewItem{file: f, index: i, row: r})
+		}
+	}
+	return items
+}
+
+func (it reviewItem) key() string {
+	return fmt.Sprintf("%s\x00%s\x00%s", it.file.scope, it.row.Type, it.row.Value)
+}
+
+func (it reviewItem) matches(filter string) bool {
+	if filter == "" {
+		return true
+	}
+	text := strings.ToLower(strings.Join([]string{it.file.scope, it.row.Type, it.row.Value, string(it.row.Approval), it.row.Note}, " "))
+	return strings.Contains(text, strings.ToLower(filter))
+}
+
+// setApproval changes the approval of the entry an item points at.
+func (it reviewItem) setApproval(a approval.Approval) {
+	p := it.file.policy
+	switch it.row.Type {
+	case "path":
+		p.Paths.Entries[it.index].Approval = a
+	case "env":
+		p.Env.Entries[it.index].Approval = a
+	case "host":
+		p.Net.Hosts.Entries[it.index].Approval = a
+	case "tool":
+		p.Tools.Entries[it.index].Approval = a
+	case "secret":
+		p.Secrets.Entries[it.index].Approval = a
+	}
+	it.file.dirty = true
+}
+
+// deleteItems removes the given entries. Indexes are removed from the end
+// of each list first so earlier indexes stay valid.
+func deleteItems(items []reviewItem) {
+	sort.Slice(items, func(i, j int) bool { return items[i].index > items[j].index })
+	for _, it := range items {
+		p := it.file.policy
+		i := it.index
+		switch it.row.Type {
+		case "path":
+			p.Paths.Entries = append(p.Paths.Entries[:i], p.Paths.Entries[i+1:]...)
+		case "env":
+			p.Env.Entries = append(p.Env.Entries[:i], p.Env.Entries[i+1:]...)
+		case "host":
+			p.Net.Hosts.Entries = append(p.Net.Hosts.Entries[:i], p.Net.Hosts.Entries[i+1:]...)
+		case "tool":
+			p.Tools.Entries = append(p.Tools.Entries[:i], p.Tools.Entries[i+1:]...)
+		case "secret":
+			p.Secrets.Entries = append(p.Secrets.Entries[:i], p.Secrets.Entries[i+1:]...)
+		}
+		it.file.dirty = true
+	}
+}
+
+// copyToGlobal adds an item's entry to the global policy unless an entry
+// with the same type and value is already there. Reports whether it copied.
+func copyToGlobal(global *reviewFile, it reviewItem) bool {
+	if it.file == global {
+		return false
+	}
+	for _, r := range policyRows(global.policy) {
+		if r.Type == it.row.Type && r.Value == it.row.Value {
+			return false
+		}
+	}
+	r := it.row
+	switch r.Type {
+	case "path":
+		e := global.policy.AddPathEntry(r.Value, r.Mode, r.Approval, approval.SourceCLI)
+		e.Note = r.Note
+	case "env":
+		global.policy.AddEnvEntry(r.Value, r.Approval, approval.SourceCLI).Note = r.Note
+	case "host":
+		global.policy.AddHostEntry(r.Value, r.Approval, approval.SourceCLI).Note = r.Note
+	case "tool":
+		global.policy.AddToolEntry(r.Value, r.Approval, approval.SourceCLI).Note = r.Note
+	case "secret":
+		global.policy.AddSecretEntry(r.Value, r.Approval, approval.SourceCLI).Note = r.Note
+	default:
+		return false
+	}
+	global.dirty = true
+	return true
+}
+
+// reviewModel is the bubbletea model for 'thought policy review'.
+type reviewModel struct {
+	files     []*reviewFile
+	items     []reviewItem
+	shown     []int           // indexes into items that pass the filter
+	selected  map[string]bool // item keys
+	cursor    int
+	filter    string
+	filtering bool
+	status    string
+	save      bool
+	height    int
+}
+
+func newReviewModel(files []*reviewFile) reviewModel {
+	m := reviewModel{files: files, selected: map[string]bool{}, height: 20}
+	m.refresh()
+	return m
+}
+
+// refresh rebuilds the item list after the policies change and keeps the
+// cursor in range.
+func (m *reviewModel) refresh() {
+	m.items = reviewItems(m.files)
+	m.shown = m.shown[:0]
+	for i, it := range m.items {
+		if it.matches(m.filter) {
+			m.shown = append(m.shown, i)
+		}
+	}
+	live := map[string]bool{}
+	for _, it := range m.items {
+		if m.selected[it.key()] {
+			live[it.key()] = true
+		}
+	}
+	m.selected = live
+	if m.cursor >= len(m.shown) {
+		m.cursor = len(m.shown) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
+
+// targets returns the items an action applies to: the selection if there
+// is one, otherwise the item under the cursor.
+func (m reviewModel) targets() []reviewItem {
+	var out []reviewItem
+	for _, it := range m.items {
+		if m.selected[it.key()] {
+			out = append(out, it)
+		}
+	}
+	if len(out) == 0 && len(m.shown) > 0 {
+		out = append(out, m.items[m.shown[m.cursor]])
+	}
+	return out
+}
+
+func (m reviewModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m reviewModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if size, ok := msg.(tea.WindowSizeMsg); ok {
+		m.height = size.Height
+		return m, nil
+	}
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	if m.filtering {
+		switch key.Type {
+		case tea.KeyEnter:
+			m.filtering = false
+		case tea.KeyEsc:
+			m.filtering = false
+			m.filter = ""
+		case tea.KeyBackspace:
+			if r := []rune(m.filter); len(r) > 0 {
+				m.filter = string(r[:len(r)-1])
+			}
+		case tea.KeyCtrlC:
+			return m, tea.Quit
+		case tea.KeyRunes, tea.KeySpace:
+			m.filter += string(key.Runes)
+		}
+		m.cursor = 0
+		m.refresh()
+		return m, nil
+	}
+
+	m.status = ""
+	switch key.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "q", "esc":
+		m.save = true
+		return m, tea.Quit
+	case "/":
+		m.filtering = true
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.shown)-1 {
+			m.cursor++
+		}
+	case " ":
+		if len(m.shown) > 0 {
+			k := m.items[m.shown[m.cursor]].key()
+			if m.selected[k] {
+				delete(m.selected, k)
+			} else {
+				m.selected[k] = true
+			}
+			if m.cursor < len(m.shown)-1 {
+				m.cursor++
+			}
+		}
+	case "a":
+		all := true
+		for _, i := range m.shown {
+			if !m.selected[m.items[i].key()] {
+				all = false
+			}
+		}
+		for _, i := range m.shown {
+			if all {
+				delete(m.selected, m.items[i].key())
+			} else {
+				m.selected[m.items[i].key()] = true
+			}
+		}
+	case "d":
+		t := m.targets()
+		deleteItems(t)
+		m.status = fmt.Sprintf("deleted %d entr%s", len(t), plural(len(t), "y", "ies"))
+		m.refresh()
+	case "f":
+		var n int
+		for _, it := range m.targets() {
+			switch it.row.Approval {
+			case approval.ApprovalAllow:
+				it.setApproval(approval.ApprovalDeny)
+				n++
+			case approval.ApprovalDeny:
+				it.setApproval(approval.ApprovalAllow)
+				n++
+			}
+		}
+		m.status = fmt.Sprintf("flipped %d entr%s", n, plural(n, "y", "ies"))
+		m.refresh()
+	case "g":
+		var n int
+		for _, it := range m.targets() {
+			if copyToGlobal(m.files[0], it) {
+				n++
+			}
+		}
+		m.status = fmt.Sprintf("copied %d entr%s to global", n, plural(n, "y", "ies"))
+		m.refresh()
+	}
+	return m, nil
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+var (
+	reviewAmber     = lipgloss.Color("214")
+	reviewDim       = lipgloss.Color("242")
+	reviewTitle     = ui.Renderer.NewStyle().Foreground(reviewAmber).Bold(true)
+	reviewCursor    = ui.Renderer.NewStyle().Foreground(reviewAmber)
+	reviewScope     = ui.Renderer.NewStyle().Foreground(lipgloss.Color("39"))
+	reviewValue     = ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
+	reviewMuted     = ui.Renderer.NewStyle().Foreground(reviewDim)
+	reviewAllow     = ui.Renderer.NewStyle().Foreground(lipgloss.Color("82"))
+	reviewDeny      = ui.Renderer.NewStyle().Foreground(lipgloss.Color("203"))
+	reviewMarkStyle = ui.Renderer.NewStyle().Foreground(reviewAmber).Bold(true)
+)
+
+func (m reviewModel) View() string {
+	var b strings.Builder
+
+	dirty := 0
+	for _, f := range m.files {
+		if f.dirty {
+			dirty++
+		}
+	}
+	header := fmt.Sprintf("◆ POLICY REVIEW  %d entries", len(m.items))
+	if len(m.selected) > 0 {
+		header += fmt.Sprintf(" · %d selected", len(m.selected))
+	}
+	b.WriteString("\n  " + reviewTitle.Render(header))
+	if dirty > 0 {
+		b.WriteString(reviewMuted.Render(fmt.Sprintf("  (%d unsaved file(s))", dirty)))
+	}
+	b.WriteString("\n")
+
+	switch {
+	case m.filtering:
+		b.WriteString(fmt.Sprintf("  %s %s%s\n", reviewMuted.Render("filter:"), reviewValue.Render(m.filter), reviewCursor.Render("█")))
+	case m.filter != "":
+		b.WriteString(fmt.Sprintf("  %s %s\n", reviewMuted.Render("filter:"), reviewValue.Render(m.filter)))
+	default:
+		b.WriteString("\n")
+	}
+
+	if len(m.shown) == 0 {
+		b.WriteString(reviewMuted.Render("  No entries.") + "\n")
+	}
+
+	// Scroll so the cursor stays visible; leave room for header and footer.
+	visible := m.height - 7
+	if visible < 5 {
+		visible = 5
+	}
+	start := 0
+	if m.cursor >= visible {
+		start = m.cursor - visible + 1
+	}
+	end := start + visible
+	if end > len(m.shown) {
+		end = len(m.shown)
+	}
+
+	scopeWidth, valueWidth := 6, 10
+	for _, i := range m.shown {
+		it := m.items[i]
+		scopeWidth = max(scopeWidth, len(it.file.scope))
+		valueWidth = max(valueWidth, len(it.row.Value))
+	}
+	valueWidth = min(valueWidth, 48)
+
+	for n := start; n < end; n++ {
+		it := m.items[m.shown[n]]
+		cursor := "  "
+		if n == m.cursor {
+			cursor = reviewCursor.Render("❯ ")
+		}
+		mark := reviewMuted.Render("·")
+		if m.selected[it.key()] {
+			mark = reviewMarkStyle.Render("●")
+		}
+		appr := string(it.row.Approval)
+		switch it.row.Approval {
+		case approval.ApprovalAllow:
+			appr = reviewAllow.Render(fmt.Sprintf("%-6s", appr))
+		case approval.ApprovalDeny:
+			appr = reviewDeny.Render(fmt.Sprintf("%-6s", appr))
+		default:
+			appr = reviewMuted.Render(fmt.Sprintf("%-6s", appr))
+		}
+		value := it.row.Value
+		if len(value) > valueWidth {
+			value = value[:valueWidth-1] + "…"
+		}
+		line := fmt.Sprintf("%s%s %s %-4s %s %s %s",
+			cursor, mark,
+			reviewScope.Render(fmt.Sprintf("%-*s", scopeWidth, it.file.scope)),
+			it.row.Type,
+			reviewValue.Render(fmt.Sprintf("%-*s", valueWidth, value)),
+			appr,
+			reviewMuted.Render(dash(it.row.Mode)))
+		if it.row.Note != "" {
+			line += "  " + reviewMuted.Render(it.row.Note)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	b.WriteString("\n")
+	if m.status != "" {
+		b.WriteString("  " + reviewCursor.Render(m.status) + "\n")
+	}
+	b.WriteString(reviewMuted.Render("  space select · a all · / filter · d delete · f flip · g copy to global · q save · ctrl+c discard") + "\n")
+	return b.String()
+}
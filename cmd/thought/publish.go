@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/lockfile"
+	"github.com/thinkingscript/cli/internal/publish"
+	"github.com/thinkingscript/cli/internal/shared"
+)
+
+var publishCmd = &cobra.Command{
+	Use:   "publish <thought>",
+	Short: "Package a converged thought into a signed archive to share",
+	Long: `Write a .tar.gz with what someone else needs to run a converged thought
+without starting over: the script, memory.js, thought.lock, and
+permissions.json, the permissions its policy allows (paths, env, hosts,
+ports, tools, and secret names) and the APIs its lock lists. manifest.json
+records each file's SHA-256 and the public key, and <archive>.sig holds a
+base64 ed25519 signature of the archive.
+
+The thought's policies, secret values, memories, and workspace are never
+included. permissions.json describes what the thought used; it grants
+nothing where it's installed.
+
+The signing key is created in ~/.thinkingscript/keys/publish.key the
+first time. Before writing, publish scans the files for embedded secrets:
+private keys, API key and token formats, hardcoded credentials, and values
+of your environment variables with secret-looking names. It refuses when it
+finds any; check each one, and pass --force if it isn't a secret.
+
+Examples:
+  thought publish weather
+  thought publish weather -o weather-1.0.tar.gz`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runPublish,
+	SilenceUsage: true,
+}
+
+var (
+	publishOutputFlag string
+	publishForceFlag  bool
+)
+
+func init() {
+	publishCmd.Flags().StringVarP(&publishOutputFlag, "output", "o", "", "Archive path (default <thought>.tar.gz); the signature goes next to it as <path>.sig")
+	publishCmd.Flags().BoolVar(&publishForceFlag, "force", false, "Publish even when the secret scan finds something")
+}
+
+func runPublish(cmd *cobra.Command, args []string) error {
+	resolved, err := ResolveThought(args[0], "publish")
+	if err != nil {
+		return err
+	}
+	if resolved.Target == TargetURL {
+		return fmt.Errorf("can't publish a URL; publish the local file or installed thought")
+	}
+	thoughtDir := thoughtDirFor(resolved)
+	name := filepath.Base(thoughtDir)
+
+	scriptData, err := os.ReadFile(resolved.Path)
+	if err != nil {
+		return err
+	}
+	dataDir := config.ThoughtDataDir(thoughtDir)
+	sharedDir := shared.Find(name, config.LoadConfig().ThoughtPath)
+	memoryJS, err := shared.ReadMemoryJS(filepath.Join(dataDir, "memory.js"), sharedDir)
+	if err != nil {
+		return fmt.Errorf("'%s' has no memory.js; run it until it converges before publishing it", name)
+	}
+
+	policy, err := approval.LoadPolicy(filepath.Join(thoughtDir, "policy.json"))
+	if err != nil {
+		return fmt.Errorf("reading policy: %w", err)
+	}
+	home, _ := os.UserHomeDir()
+	pkg := &publish.Package{
+		Name:        name,
+		Script:      filepath.Base(resolved.Path),
+		ScriptData:  scriptData,
+		MemoryJS:    memoryJS,
+		Permissions: publish.Declare(policy, home),
+	}
+
+	// A lock for an older memory.js would pin the wrong modules
+	lock, err := lockfile.Load(lockfile.Path(dataDir))
+	if err != nil {
+		return err
+	}
+	if lock != nil && lock.For(memoryJS) {
+		if pkg.Lock, err = os.ReadFile(lockfile.Path(dataDir)); err != nil {
+			return err
+		}
+		pkg.Permissions.APIs = lock.APIs
+	} else {
+		fmt.Fprintf(os.Stderr, "warning: no thought.lock for this memory.js; run '%s' once to write one\n", name)
+	}
+
+	if found := pkg.Scan(); len(found) > 0 {
+		for _, f := range found {
+			fmt.Fprintf(os.Stderr, "  %s\n", f)
+		}
+		if !publishForceFlag {
+			return fmt.Errorf("found %d possible secret(s) in '%s'; remove them (use secrets.get or env.get instead), or pass --force if they aren't secrets", len(found), name)
+		}
+		fmt.Fprintf(os.Stderr, "warning: publishing with %d possible secret(s) (--force)\n", len(found))
+	}
+
+	key, created, err := publish.LoadKey(publish.KeyPath(config.HomeDir()))
+	if err != nil {
+		return fmt.Errorf("loading publishing key: %w", err)
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Created publishing key %s\n", publish.KeyPath(config.HomeDir()))
+	}
+
+	path := publishOutputFlag
+	if path == "" {
+		path = name + ".tar.gz"
+	}
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	sig, err := publish.Write(out, pkg, key)
+	if err == nil {
+		err = out.Close()
+	}
+	if err == nil {
+		err = os.WriteFile(path+".sig", sig, 0644)
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s and %s.sig\n", path, path)
+	fmt.Fprintf(os.Stderr, "Public key: %s\n", publish.PublicKey(key))
+	if perms := describePermissions(pkg.Permissions); perms != "" {
+		fmt.Fprintf(os.Stderr, "Declared permissions: %s\n", perms)
+	}
+	return nil
+}
+
+// describePermissions summarizes declared permissions in one line.
+func describePermissions(p publish.Permissions) string {
+	var parts []string
+	count := func(n int, what string) {
+		if n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	count(len(p.Paths), "paths")
+	count(len(p.Env), "env vars")
+	count(len(p.Hosts), "hosts")
+	count(len(p.Listen), "ports")
+	count(len(p.Tools), "tools")
+	count(len(p.Secrets), "secrets")
+	count(len(p.APIs), "APIs")
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/queue"
+)
+
+var queueCmd = &cobra.Command{
+	Use:   "queue",
+	Short: "Run thoughts one after another in the background",
+	Long: `Queue runs of thoughts and work through them one at a time, oldest first.
+The queue lives in ~/.thinkingscript/queue/ and survives restarts; each
+item's stdout and stderr go to its own log.
+
+Runs have no stdin and no terminal, so approval prompts are denied: grant
+what a queued thought needs beforehand (thought policy add) or with
+--allow/--write when adding it.
+
+Examples:
+  thought queue add ./report.md --month 2026-01
+  thought queue add --write ./out ./report.md
+  thought queue add weather "San Francisco"
+  thought queue work
+  thought queue ls
+  thought queue log 20260101-120000-0`,
+	SilenceUsage: true,
+}
+
+var queueAddCmd = &cobra.Command{
+	Use:   "add <script> [args...]",
+	Short: "Add a run to the queue",
+	Long: `Add a run of a script file, URL, or installed thought to the queue. It
+runs in the current directory with the worker's environment. Flags go
+before the script; everything after it is passed to the script.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runQueueAdd,
+	SilenceUsage: true,
+}
+
+var queueWorkCmd = &cobra.Command{
+	Use:   "work",
+	Short: "Run queued items one at a time",
+	Long: `Run queued items oldest first, waiting for new ones until interrupted.
+Only one worker runs at a time. Interrupting the worker stops the current
+run and puts it back in the queue.`,
+	Args:         cobra.NoArgs,
+	RunE:         runQueueWork,
+	SilenceUsage: true,
+}
+
+var queueListCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List queued and finished items",
+	Args:         cobra.NoArgs,
+	RunE:         runQueueList,
+	SilenceUsage: true,
+}
+
+var queueLogCmd = &cobra.Command{
+	Use:          "log <id>",
+	Short:        "Print an item's output",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runQueueLog,
+	SilenceUsage: true,
+}
+
+var queueRmCmd = &cobra.Command{
+	Use:          "rm <id>...",
+	Short:        "Remove items that aren't running",
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runQueueRm,
+	SilenceUsage: true,
+}
+
+var queueClearCmd = &cobra.Command{
+	Use:          "clear",
+	Short:        "Remove finished items and their logs",
+	Args:         cobra.NoArgs,
+	RunE:         runQueueClear,
+	SilenceUsage: true,
+}
+
+var (
+	queueAllowFlag    []string
+	queueWriteFlag    []string
+	queueReadOnlyFlag bool
+	queueDrainFlag    bool
+)
+
+func init() {
+	// Everything after the script belongs to the script
+	queueAddCmd.Flags().SetInterspersed(false)
+	queueAddCmd.Flags().StringArrayVar(&queueAllowFlag, "allow", nil, "Grant the run read access to a path (repeatable)")
+	queueAddCmd.Flags().StringArrayVar(&queueWriteFlag, "write", nil, "Grant the run read/write/delete access to a path (repeatable)")
+	queueAddCmd.Flags().BoolVar(&queueReadOnlyFlag, "read-only", false, "Run in read-only mode")
+	queueWorkCmd.Flags().BoolVar(&queueDrainFlag, "drain", false, "Exit once the queue is empty")
+
+	queueCmd.AddCommand(queueAddCmd)
+	queueCmd.AddCommand(queueWorkCmd)
+	queueCmd.AddCommand(queueListCmd)
+	queueCmd.AddCommand(queueLogCmd)
+	queueCmd.AddCommand(queueRmCmd)
+	queueCmd.AddCommand(queueClearCmd)
+}
+
+func queueDir() string {
+	return filepath.Join(config.HomeDir(), "queue")
+}
+
+func runQueueAdd(cmd *cobra.Command, args []string) error {
+	resolved, err := ResolveThought(args[0], "queue add")
+	if err != nil {
+		return err
+	}
+	script := resolved.Path
+	if resolved.Target == TargetFile {
+		if script, err = filepath.Abs(script); err != nil {
+			return err
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	// Paths are made absolute here; the worker runs elsewhere
+	var flags []string
+	if queueReadOnlyFlag {
+		flags = append(flags, "--read-only")
+	}
+	for _, grant := range []struct {
+		flag  string
+		paths []string
+	}{{"--allow", queueAllowFlag}, {"--write", queueWriteFlag}} {
+		for _, path := range grant.paths {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			flags = append(flags, grant.flag, abs)
+		}
+	}
+	it, err := queue.Add(queueDir(), script, args[1:], flags, cwd)
+	if err != nil {
+		return fmt.Errorf("adding to queue: %w", err)
+	}
+	fmt.Println(it.ID)
+	return nil
+}
+
+func runQueueWork(cmd *cobra.Command, args []string) error {
+	think, err := thinkBinary()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	w := &queue.Worker{
+		Dir:   queueDir(),
+		Think: think,
+		OnStart: func(it queue.Item) {
+			fmt.Fprintf(os.Stderr, "%s  running %s\n", it.ID, queueCommand(it))
+		},
+		OnDone: func(it queue.Item) {
+			fmt.Fprintf(os.Stderr, "%s  %s (exit %d, %s)\n", it.ID, it.Status, it.ExitCode, it.Ended.Sub(*it.Started).Round(time.Millisecond))
+		},
+	}
+	err = w.Work(ctx, queueDrainFlag)
+	if errors.Is(err, queue.ErrBusy) {
+		return fmt.Errorf("%w on %s", err, w.Dir)
+	}
+	return err
+}
+
+// thinkBinary finds the think installed next to this thought binary,
+// falling back to the one on PATH.
+func thinkBinary() (string, error) {
+	if exe, err := os.Executable(); err == nil {
+		sibling := filepath.Join(filepath.Dir(exe), "think")
+		if info, err := os.Stat(sibling); err == nil && !info.IsDir() {
+			return sibling, nil
+		}
+	}
+	path, err := exec.LookPath("think")
+	if err != nil {
+		return "", fmt.Errorf("think not found next to thought or on PATH")
+	}
+	return path, nil
+}
+
+func runQueueList(cmd *cobra.Command, args []string) error {
+	items, err := queue.List(queueDir())
+	if err != nil {
+		return fmt.Errorf("reading queue: %w", err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, "Queue is empty.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tADDED\tCOMMAND")
+	for _, it := range items {
+		status := it.Status
+		if it.Status == queue.StatusFailed {
+			status = fmt.Sprintf("failed (%d)", it.ExitCode)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", it.ID, status, it.Added.Local().Format("2006-01-02 15:04"), queueCommand(it))
+	}
+	return w.Flush()
+}
+
+// queueCommand shows an item as the command it runs.
+func queueCommand(it queue.Item) string {
+	script := it.Script
+	if strings.HasPrefix(script, config.BinDir()+string(filepath.Separator)) {
+		script = filepath.Base(script)
+	}
+	return strings.TrimSpace(script + " " + strings.Join(it.Args, " "))
+}
+
+func runQueueLog(cmd *cobra.Command, args []string) error {
+	it, err := queue.Get(queueDir(), args[0])
+	if errors.Is(err, queue.ErrNotFound) {
+		return fmt.Errorf("no queue item %q (see 'thought queue ls')", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if it.Error != "" {
+		fmt.Fprintf(os.Stderr, "error: %s\n", it.Error)
+	}
+	data, err := os.ReadFile(queue.LogPath(queueDir(), it.ID))
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "No output yet (%s).\n", it.Status)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading log: %w", err)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func runQueueRm(cmd *cobra.Command, args []string) error {
+	for _, id := range args {
+		err := queue.Remove(queueDir(), id)
+		switch {
+		case errors.Is(err, queue.ErrNotFound):
+			return fmt.Errorf("no queue item %q (see 'thought queue ls')", id)
+		case errors.Is(err, queue.ErrRunning):
+			return fmt.Errorf("%s is running; stop the worker to interrupt it", id)
+		case err != nil:
+			return fmt.Errorf("removing %s: %w", id, err)
+		}
+		fmt.Fprintf(os.Stderr, "Removed %s\n", id)
+	}
+	return nil
+}
+
+func runQueueClear(cmd *cobra.Command, args []string) error {
+	n, err := queue.Clear(queueDir())
+	if err != nil {
+		return fmt.Errorf("clearing queue: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d finished item(s).\n", n)
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename <thought> <new-name>",
+	Short: "Move a thought's data to a new name",
+	Long: `Rename a thought's data directory (memory.js, workspace, memories, policy).
+
+Thought data is keyed by file name, so two scripts called weather.md in
+different projects collide; the second one to run gets a directory named
+weather-<hash> and a warning. Use rename to give either one a real name.
+<thought> may be a script path, an installed thought, or a directory name
+under ~/.thinkingscript/thoughts/.
+
+Renaming from a script path records that script as the owner, so it keeps
+finding its data under the new name. Installed thoughts have their command
+renamed too. Scripts that set "name:" in their frontmatter must be updated
+to the new name.
+
+Examples:
+  thought rename ./work/weather.md weather-work
+  thought rename weather-1a2b3c4d weather-home`,
+	Args:         cobra.ExactArgs(2),
+	RunE:         runRename,
+	SilenceUsage: true,
+}
+
+func runRename(cmd *cobra.Command, args []string) error {
+	newName := args[1]
+	if err := config.ValidateThoughtName(newName); err != nil {
+		return err
+	}
+
+	oldDir, resolved, err := resolveRenameSource(args[0])
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(oldDir); err != nil {
+		return fmt.Errorf("no thought data found for '%s'", args[0])
+	}
+	newDir := filepath.Join(config.HomeDir(), "thoughts", newName)
+	if _, err := os.Lstat(newDir); err == nil {
+		return fmt.Errorf("thought '%s' already exists", newName)
+	}
+
+	// An unowned directory renamed by script path is claimed first, so the
+	// script finds it under its new name.
+	if resolved != nil && resolved.Target == TargetFile {
+		if err := config.ClaimThought(oldDir, resolved.Path); err != nil {
+			return fmt.Errorf("recording owner: %w", err)
+		}
+	}
+
+	oldName := filepath.Base(oldDir)
+	oldBin := filepath.Join(config.BinDir(), oldName)
+	newBin := filepath.Join(config.BinDir(), newName)
+	installed := config.LoadOrigin(oldDir) != nil && fileExists(oldBin)
+	if installed && fileExists(newBin) {
+		return fmt.Errorf("an installed thought named '%s' already exists", newName)
+	}
+
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return fmt.Errorf("renaming thought: %w", err)
+	}
+	if err := rewritePolicyPaths(filepath.Join(newDir, "policy.json"), oldDir, newDir); err != nil {
+		return fmt.Errorf("updating policy paths: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Renamed %s → %s\n", oldDir, newDir)
+
+	if installed {
+		if err := os.Rename(oldBin, newBin); err != nil {
+			return fmt.Errorf("renaming installed command: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Renamed command %s → %s\n", oldName, newName)
+	} else if config.ThoughtOwner(newDir) == "" {
+		fmt.Fprintf(os.Stderr, "Note: no script owns this data yet. Add \"name: %s\" to the script's frontmatter to use it.\n", newName)
+	}
+	return nil
+}
+
+// resolveRenameSource finds the directory to rename. Plain names that are
+// neither a file nor an installed thought are taken as directory names
+// under thoughts/, which covers the weather-<hash> directories collisions
+// create. The resolved script is nil in that case.
+func resolveRenameSource(arg string) (string, *ResolveResult, error) {
+	dir := filepath.Join(config.HomeDir(), "thoughts", arg)
+	if config.ValidateThoughtName(arg) == nil && !fileExists(arg) && !fileExists(filepath.Join(config.BinDir(), arg)) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil, nil
+		}
+	}
+	resolved, err := ResolveThought(arg, "rename")
+	if err != nil {
+		return "", nil, err
+	}
+	return thoughtDirFor(resolved), resolved, nil
+}
+
+// rewritePolicyPaths moves path entries that pointed into the old thought
+// directory (the bootstrapped workspace and memories entries, the
+// policy.json deny) to the new one.
+func rewritePolicyPaths(policyPath, oldDir, newDir string) error {
+	if !fileExists(policyPath) {
+		return nil
+	}
+	policy, err := approval.LoadPolicy(policyPath)
+	if err != nil {
+		return err
+	}
+	oldAbs, _ := filepath.Abs(oldDir)
+	newAbs, _ := filepath.Abs(newDir)
+	if !policy.MovePaths(oldAbs, newAbs) {
+		return nil
+	}
+	return policy.Save(policyPath)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
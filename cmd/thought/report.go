@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/runlog"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report <thought>",
+	Short: "Package the last run into an archive for a bug report",
+	Long: `Write a .tar.gz with what's needed to reproduce a problem: the last run's
+arguments, stdin, agent transcript, and every sandbox run's code and
+output, plus memory.js, the script, the thought and global policies,
+config.json and the agent definition with keys and headers redacted, and
+the OS, Go, and think versions.
+
+Only secrets in config files are redacted. Arguments, stdin, the
+transcript, and script output are included as they were, so look through
+the archive before attaching it anywhere public.
+
+Examples:
+  thought report weather
+  thought report weather -o weather-bug.tar.gz
+  thought report weather --last-run=false`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runReport,
+	SilenceUsage: true,
+}
+
+var (
+	reportLastRunFlag bool
+	reportOutputFlag  string
+)
+
+func init() {
+	reportCmd.Flags().BoolVar(&reportLastRunFlag, "last-run", true, "Include the most recent run (false: only config, policy, and environment)")
+	reportCmd.Flags().StringVarP(&reportOutputFlag, "output", "o", "", "Archive path, or - for stdout (default <thought>-report-<time>.tar.gz)")
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "report")
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(thoughtDir)
+	if reportLastRunFlag {
+		if _, err := os.Stat(filepath.Join(runlog.Dir(thoughtDir), "run.json")); err != nil {
+			return fmt.Errorf("%s has no recorded run; run it first, or use --last-run=false", name)
+		}
+	}
+	root := fmt.Sprintf("%s-report-%s", name, time.Now().Format("20060102-150405"))
+
+	out := os.Stdout
+	path := reportOutputFlag
+	if path == "" {
+		path = root + ".tar.gz"
+	}
+	if path != "-" {
+		out, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	names, err := runlog.WriteReport(out, thoughtDir, root, reportLastRunFlag)
+	if err != nil {
+		if path != "-" {
+			os.Remove(path)
+		}
+		return fmt.Errorf("writing report: %w", err)
+	}
+	if path != "-" {
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %s (%d files).\n", path, len(names))
+	}
+	fmt.Fprintln(os.Stderr, "Review it before sharing: arguments, stdin, the transcript, and output are not redacted.")
+	return nil
+}
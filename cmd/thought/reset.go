@@ -1,48 +1,158 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/charmbracelet/huh"
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/lockfile"
+	"github.com/thinkingscript/cli/internal/runlog"
+	"github.com/thinkingscript/cli/internal/snapshot"
+	"github.com/thinkingscript/cli/internal/ui"
+	"golang.org/x/term"
 )
 
 var (
-	resetAllFlag      bool
-	resetMemoriesFlag bool
-	resetPolicyFlag   bool
+	resetAllFlag    bool
+	resetDryRunFlag bool
+	resetYesFlag    bool
 )
 
+// resetConfirmBytes is how much a reset may delete before it asks first.
+const resetConfirmBytes = 100 << 20
+
+// resetComponent is one part of a thought's state that reset can remove,
+// selected by the flag of the same name.
+type resetComponent struct {
+	flag   string
+	label  string // as printed: "workspace/", "memory.js"
+	usage  string
+	byData bool // lives in the data directory rather than the thought directory
+	path   func(dir string) string
+	chosen bool // set by its flag
+}
+
+// resetComponents is the per-thought layout reset knows about, in the order
+// it's listed. memory.js, workspace/, memories/, and thought.lock follow
+// data_dir; policy.json, history/, and snapshots/ stay in the thought
+// directory.
+var resetComponents = []*resetComponent{
+	{flag: "memory-js", label: "memory.js", usage: "Remove memory.js", byData: true,
+		path: func(dir string) string { return filepath.Join(dir, "memory.js") }},
+	{flag: "workspace", label: "workspace/", usage: "Clear workspace/", byData: true,
+		path: func(dir string) string { return filepath.Join(dir, "workspace") }},
+	{flag: "memories", label: "memories/", usage: "Clear memories/", byData: true,
+		path: func(dir string) string { return filepath.Join(dir, "memories") }},
+	{flag: "lock", label: "thought.lock", usage: "Remove thought.lock, so the next successful run locks memory.js again", byData: true,
+		path: lockfile.Path},
+	{flag: "policy", label: "policy.json", usage: "Reset policy.json to defaults",
+		path: func(dir string) string { return filepath.Join(dir, "policy.json") }},
+	{flag: "history", label: "history/", usage: "Clear saved agent transcripts (history/)",
+		path: runlog.HistoryDir},
+	{flag: "snapshots", label: "snapshots/", usage: "Remove workspace snapshots (snapshots/)",
+		path: snapshot.Dir},
+}
+
 var resetCmd = &cobra.Command{
 	Use:   "reset <thought>",
 	Short: "Reset a thought's state",
-	Long: `Reset a thought's memory.js and workspace/ directory.
-
-By default, this command removes:
-  - memory.js (the static script)
-  - workspace/ (agent's scratch space)
-
-Use --memories to also clear the memories/ directory.
-Use --policy to also reset policy.json to defaults.
-Use --all to clear everything.
+	Long: `Reset a thought's state: by default its memory.js and workspace/.
+
+Each part of a thought's state has a flag; give one or more to remove only
+those:
+  --memory-js   memory.js (the static script)
+  --workspace   workspace/ (agent's scratch space)
+  --memories    memories/ (text memories)
+  --lock        thought.lock (modules and APIs memory.js was locked with)
+  --policy      policy.json (approval policy, back to defaults)
+  --history     history/ (saved agent transcripts)
+  --snapshots   snapshots/ (workspace copies for 'thought restore')
+Use --all to clear all of them.
+
+Use --dry-run to list what would be removed, with sizes, without removing
+anything. A reset that would delete more than 100 MB asks first; --yes
+skips the question, and is required when there's no terminal to ask on.
 
 Accepts an installed thought name, local file path, or URL.
 
 Examples:
-  thought reset weather          # Reset installed thought
-  thought reset ./weather.md     # Reset thought for a file
-  thought reset weather --all    # Reset everything including memories and policy`,
+  thought reset weather                       # Reset installed thought
+  thought reset ./weather.md                  # Reset thought for a file
+  thought reset weather --workspace           # Clear only the workspace
+  thought reset weather --memories --policy   # Clear memories and policy only
+  thought reset weather --all --dry-run
+  thought reset weather --all                 # Reset everything`,
 	Args:         cobra.ExactArgs(1),
 	RunE:         runReset,
 	SilenceUsage: true,
 }
 
 func init() {
-	resetCmd.Flags().BoolVarP(&resetAllFlag, "all", "a", false, "Reset everything (memory.js, workspace/, memories/, policy.json)")
-	resetCmd.Flags().BoolVar(&resetMemoriesFlag, "memories", false, "Also clear memories/")
-	resetCmd.Flags().BoolVar(&resetPolicyFlag, "policy", false, "Also reset policy.json")
+	resetCmd.Flags().BoolVarP(&resetAllFlag, "all", "a", false, "Reset everything (memory.js, workspace/, memories/, policy.json, history/, snapshots/)")
+	for _, c := range resetComponents {
+		resetCmd.Flags().BoolVar(&c.chosen, c.flag, false, c.usage)
+	}
+	resetCmd.Flags().BoolVarP(&resetDryRunFlag, "dry-run", "n", false, "List what would be removed, with sizes, and remove nothing")
+	resetCmd.Flags().BoolVarP(&resetYesFlag, "yes", "y", false, "Don't ask before deleting more than 100 MB")
+}
+
+// resetItem is one existing component that a reset will remove.
+type resetItem struct {
+	label string
+	path  string
+	size  int64
+	files int
+}
+
+// selectReset returns the components a reset removes: all of them with
+// all, the chosen ones if any are, and otherwise memory.js and workspace/.
+func selectReset(components []*resetComponent, all bool) []*resetComponent {
+	var chosen []*resetComponent
+	for _, c := range components {
+		if all || c.chosen {
+			chosen = append(chosen, c)
+		}
+	}
+	if len(chosen) > 0 {
+		return chosen
+	}
+	for _, c := range components {
+		if c.flag == "memory-js" || c.flag == "workspace" {
+			chosen = append(chosen, c)
+		}
+	}
+	return chosen
+}
+
+// planReset measures the selected components that exist for thoughtDir and
+// returns them with their total size.
+func planReset(thoughtDir string, components []*resetComponent) ([]resetItem, int64) {
+	dataDir := config.ThoughtDataDir(thoughtDir)
+	var items []resetItem
+	var total int64
+	for _, c := range components {
+		dir := thoughtDir
+		if c.byData {
+			dir = dataDir
+		}
+		path := c.path(dir)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		item := resetItem{label: c.label, path: path, size: info.Size(), files: 1}
+		if info.IsDir() {
+			item.size, item.files = dirStats(path)
+		}
+		items = append(items, item)
+		total += item.size
+	}
+	return items, total
 }
 
 func runReset(cmd *cobra.Command, args []string) error {
@@ -51,69 +161,83 @@ func runReset(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var thoughtDir string
-	var name string
+	name := args[0]
 	if resolved.Target == TargetInstalled {
 		name = resolved.Name
-		thoughtDir = filepath.Join(config.HomeDir(), "thoughts", name)
-	} else {
-		name = args[0]
-		thoughtDir = config.ThoughtDir(resolved.Path)
 	}
+	thoughtDir := thoughtDirFor(resolved)
 
 	// Check if thought directory exists
 	if _, err := os.Stat(thoughtDir); os.IsNotExist(err) {
 		return fmt.Errorf("no thought data found for '%s'", name)
 	}
 
-	// Define what to clear
-	memoryJS := filepath.Join(thoughtDir, "memory.js")
-	workspaceDir := filepath.Join(thoughtDir, "workspace")
-	memoriesDir := filepath.Join(thoughtDir, "memories")
-	policyJSON := filepath.Join(thoughtDir, "policy.json")
-
-	// Always clear: memory.js, workspace/
-	cleared := []string{}
+	items, total := planReset(thoughtDir, selectReset(resetComponents, resetAllFlag))
+	if len(items) == 0 {
+		fmt.Fprintf(os.Stderr, "Nothing to reset for '%s'\n", name)
+		return nil
+	}
 
-	if _, err := os.Stat(memoryJS); err == nil {
-		if err := os.Remove(memoryJS); err != nil {
-			return fmt.Errorf("removing memory.js: %w", err)
-		}
-		cleared = append(cleared, "memory.js")
+	if resetDryRunFlag {
+		fmt.Fprintf(os.Stderr, "Would reset '%s':\n", name)
+		printResetItems(items, total)
+		return nil
 	}
 
-	if _, err := os.Stat(workspaceDir); err == nil {
-		if err := os.RemoveAll(workspaceDir); err != nil {
-			return fmt.Errorf("removing workspace/: %w", err)
+	if total > resetConfirmBytes && !resetYesFlag {
+		ok, err := confirmReset(name, items, total)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(os.Stderr, "Reset cancelled")
+			return nil
 		}
-		cleared = append(cleared, "workspace/")
 	}
 
-	// Optionally clear memories/
-	if resetAllFlag || resetMemoriesFlag {
-		if _, err := os.Stat(memoriesDir); err == nil {
-			if err := os.RemoveAll(memoriesDir); err != nil {
-				return fmt.Errorf("removing memories/: %w", err)
-			}
-			cleared = append(cleared, "memories/")
+	cleared := []string{}
+	for _, item := range items {
+		if err := os.RemoveAll(item.path); err != nil {
+			return fmt.Errorf("removing %s: %w", item.label, err)
 		}
+		cleared = append(cleared, item.label)
 	}
+	fmt.Fprintf(os.Stderr, "Reset '%s': %v\n", name, cleared)
 
-	// Optionally clear policy.json
-	if resetAllFlag || resetPolicyFlag {
-		if _, err := os.Stat(policyJSON); err == nil {
-			if err := os.Remove(policyJSON); err != nil {
-				return fmt.Errorf("removing policy.json: %w", err)
-			}
-			cleared = append(cleared, "policy.json")
+	return nil
+}
+
+// printResetItems lists items with their sizes and the total on stderr.
+func printResetItems(items []resetItem, total int64) {
+	for _, item := range items {
+		detail := formatBytes(item.size)
+		if strings.HasSuffix(item.label, "/") {
+			detail += fmt.Sprintf(", %d files", item.files)
 		}
+		fmt.Fprintf(os.Stderr, "  %-12s %s (%s)\n", item.label, item.path, detail)
 	}
+	fmt.Fprintf(os.Stderr, "  %-12s %s\n", "total", formatBytes(total))
+}
 
-	if len(cleared) == 0 {
-		fmt.Fprintf(os.Stderr, "Nothing to reset for '%s'\n", name)
-	} else {
-		fmt.Fprintf(os.Stderr, "Reset '%s': %v\n", name, cleared)
+// confirmReset asks before a large reset. Without a terminal it refuses,
+// so scripts have to say --yes.
+func confirmReset(name string, items []resetItem, total int64) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("reset would delete %s for '%s'; rerun with --yes to confirm or --dry-run to see what", formatBytes(total), name)
 	}
-
-	return nil
+	fmt.Fprintf(os.Stderr, "Reset '%s' will delete:\n", name)
+	printResetItems(items, total)
+
+	var ok bool
+	confirm := huh.NewConfirm().
+		Title(fmt.Sprintf("Delete %s?", formatBytes(total))).
+		Affirmative("Yes").
+		Negative("No").
+		Value(&ok)
+
+	form := huh.NewForm(huh.NewGroup(confirm)).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
+	if err := form.Run(); err != nil {
+		return false, errors.New("prompt cancelled")
+	}
+	return ok, nil
 }
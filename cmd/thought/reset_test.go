@@ -0,0 +1,156 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+// components returns copies of resetComponents with the named flags set.
+func components(flags ...string) []*resetComponent {
+	var out []*resetComponent
+	for _, c := range resetComponents {
+		cp := *c
+		cp.chosen = false
+		for _, f := range flags {
+			if f == c.flag {
+				cp.chosen = true
+			}
+		}
+		out = append(out, &cp)
+	}
+	return out
+}
+
+func labels(items []resetItem) []string {
+	var out []string
+	for _, item := range items {
+		out = append(out, item.label)
+	}
+	return out
+}
+
+// setupThought lays out every component reset knows about under dir.
+func setupThought(t *testing.T, thoughtDir, dataDir string) {
+	t.Helper()
+	for _, d := range []string{
+		filepath.Join(dataDir, "workspace", "sub"),
+		filepath.Join(dataDir, "memories"),
+		filepath.Join(thoughtDir, "history"),
+		filepath.Join(thoughtDir, "snapshots", "20260101-000000", "data"),
+	} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{
+		filepath.Join(dataDir, "memory.js"):                                        "console.log(1)",
+		filepath.Join(dataDir, "workspace", "a.txt"):                               "alpha",
+		filepath.Join(dataDir, "workspace", "sub", "b.txt"):                        "beta",
+		filepath.Join(dataDir, "memories", "note.md"):                              "remember",
+		filepath.Join(thoughtDir, "policy.json"):                                   "{}",
+		filepath.Join(thoughtDir, "history", "1.jsonl"):                            "{}\n",
+		filepath.Join(thoughtDir, "snapshots", "20260101-000000", "data", "a.txt"): "old",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSelectReset(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags []string
+		all   bool
+		want  []string
+	}{
+		{"default", nil, false, []string{"memory-js", "workspace"}},
+		{"one", []string{"history"}, false, []string{"history"}},
+		{"only workspace", []string{"workspace"}, false, []string{"workspace"}},
+		{"several", []string{"snapshots", "memories", "policy"}, false, []string{"memories", "policy", "snapshots"}},
+		{"all", nil, true, []string{"memory-js", "workspace", "memories", "lock", "policy", "history", "snapshots"}},
+		{"all with flags", []string{"policy"}, true, []string{"memory-js", "workspace", "memories", "lock", "policy", "history", "snapshots"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range selectReset(components(tt.flags...), tt.all) {
+				got = append(got, c.flag)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("selected %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("selected %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanReset(t *testing.T) {
+	thoughtDir := t.TempDir()
+	setupThought(t, thoughtDir, thoughtDir)
+
+	items, total := planReset(thoughtDir, selectReset(components(), true))
+	want := []string{"memory.js", "workspace/", "memories/", "policy.json", "history/", "snapshots/"}
+	got := labels(items)
+	if len(got) != len(want) {
+		t.Fatalf("planned %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("planned %v, want %v", got, want)
+		}
+	}
+	var sum int64
+	for _, item := range items {
+		sum += item.size
+	}
+	if total != sum {
+		t.Errorf("total = %d, want %d", total, sum)
+	}
+	for _, item := range items {
+		if item.label == "workspace/" {
+			if item.files != 2 || item.size != int64(len("alpha")+len("beta")) {
+				t.Errorf("workspace/ = %d files, %d bytes; want 2 files, 9 bytes", item.files, item.size)
+			}
+		}
+	}
+
+	// Missing components are left out
+	os.RemoveAll(filepath.Join(thoughtDir, "history"))
+	os.Remove(filepath.Join(thoughtDir, "policy.json"))
+	items, _ = planReset(thoughtDir, selectReset(components("history", "policy", "memories"), false))
+	if got := labels(items); len(got) != 1 || got[0] != "memories/" {
+		t.Errorf("planned %v, want [memories/]", got)
+	}
+}
+
+func TestPlanResetDataDir(t *testing.T) {
+	thoughtDir := t.TempDir()
+	dataDir := t.TempDir()
+	setupThought(t, thoughtDir, dataDir)
+	if err := config.SaveDataDir(thoughtDir, dataDir); err != nil {
+		t.Fatal(err)
+	}
+
+	items, _ := planReset(thoughtDir, selectReset(components(), true))
+	if len(items) != 6 {
+		t.Fatalf("planned %v, want all six", labels(items))
+	}
+	for _, item := range items {
+		want := thoughtDir
+		switch item.label {
+		case "memory.js", "workspace/", "memories/":
+			want = dataDir
+		}
+		if filepath.Dir(item.path) != want {
+			t.Errorf("%s at %s, want it in %s", item.label, item.path, want)
+		}
+	}
+}
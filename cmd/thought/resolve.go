@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/script"
 	"github.com/thinkingscript/cli/internal/ui"
 	"golang.org/x/term"
 )
@@ -34,9 +36,28 @@ type ResolveResult struct {
 // and the user cannot be prompted (non-interactive).
 var ErrAmbiguous = errors.New("ambiguous target")
 
+// preferFlag is the global --prefer flag; see preference.
+var preferFlag string
+
+// preference returns what a name that is both a file and an installed
+// thought resolves to: "file", "installed", or "" to ask. --prefer wins
+// over THINKINGSCRIPT__PREFER, which wins over config.json "prefer"; "ask"
+// at any level means "".
+func preference() (string, error) {
+	p := cmp.Or(preferFlag, os.Getenv("THINKINGSCRIPT__PREFER"), config.LoadConfig().Prefer)
+	switch p {
+	case "ask":
+		return "", nil
+	case "", "file", "installed":
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid prefer %q: use file, installed, or ask", p)
+}
+
 // ResolveThought resolves a reference to either a file, URL, or installed thought.
-// If both file and installed thought exist and we're in a TTY, prompts user to choose.
-// If both exist and not TTY, returns ErrAmbiguous.
+// If both file and installed thought exist, the preference (--prefer or the
+// config default) picks one; without one, a TTY prompts the user to choose
+// and anything else returns ErrAmbiguous.
 //
 // Resolution rules:
 //   - Starts with "http://" or "https://" → URL (passed through directly)
@@ -102,15 +123,63 @@ func ResolveThought(arg, cmdName string) (*ResolveResult, error) {
 	return resolveAmbiguous(arg, binPath, cmdName)
 }
 
+// ResolveThoughtDir resolves a reference like ResolveThought and returns
+// the thought's data directory.
+func ResolveThoughtDir(arg, cmdName string) (string, error) {
+	resolved, err := ResolveThought(arg, cmdName)
+	if err != nil {
+		return "", err
+	}
+	return thoughtDirFor(resolved), nil
+}
+
+// thoughtDirFor returns the data directory of a resolved thought, honoring
+// a frontmatter `name:` in local and installed scripts.
+func thoughtDirFor(resolved *ResolveResult) string {
+	name := ""
+	if resolved.Target != TargetURL {
+		if parsed, err := script.Parse(resolved.Path); err == nil && parsed.Config != nil {
+			name = parsed.Config.Name
+		}
+	}
+	if name == "" && resolved.Target == TargetInstalled {
+		return filepath.Join(config.HomeDir(), "thoughts", resolved.Name)
+	}
+	dir, _ := config.LocateThought(resolved.Path, name)
+	return dir
+}
+
 func resolveAmbiguous(arg, binPath, cmdName string) (*ResolveResult, error) {
-	isTTY := term.IsTerminal(int(os.Stderr.Fd()))
+	choice, err := preference()
+	if err != nil {
+		return nil, err
+	}
+	if choice == "" {
+		if !term.IsTerminal(int(os.Stderr.Fd())) {
+			return nil, fmt.Errorf("%w: both file './%s' and installed thought '%s' exist.\nRun one of:\n  thought %s ./%s\n  thought %s %s\nor pass --prefer file|installed (or set \"prefer\" in config.json)",
+				ErrAmbiguous, arg, arg, cmdName, arg, cmdName, arg)
+		}
+		if choice, err = pickAmbiguous(arg); err != nil {
+			return nil, err
+		}
+	}
 
-	if !isTTY {
-		return nil, fmt.Errorf("%w: both file './%s' and installed thought '%s' exist.\nRun one of:\n  thought %s ./%s\n  thought %s %s",
-			ErrAmbiguous, arg, arg, cmdName, arg, cmdName, arg)
+	if choice == "file" {
+		return &ResolveResult{
+			Path:   arg,
+			Target: TargetFile,
+		}, nil
 	}
 
-	// TTY - prompt user
+	return &ResolveResult{
+		Path:   binPath,
+		Target: TargetInstalled,
+		Name:   arg,
+	}, nil
+}
+
+// pickAmbiguous asks whether arg means the file or the installed thought.
+func pickAmbiguous(arg string) (string, error) {
 	var choice string
 
 	fileOpt := fmt.Sprintf("File ./%s", arg)
@@ -128,24 +197,12 @@ func resolveAmbiguous(arg, binPath, cmdName string) (*ResolveResult, error) {
 				).
 				Value(&choice),
 		),
-	).WithTheme(resolveTheme()).WithOutput(os.Stderr)
+	).WithTheme(resolveTheme()).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
 
 	if err := form.Run(); err != nil {
-		return nil, fmt.Errorf("prompt cancelled")
-	}
-
-	if choice == "file" {
-		return &ResolveResult{
-			Path:   arg,
-			Target: TargetFile,
-		}, nil
+		return "", fmt.Errorf("prompt cancelled")
 	}
-
-	return &ResolveResult{
-		Path:   binPath,
-		Target: TargetInstalled,
-		Name:   arg,
-	}, nil
+	return choice, nil
 }
 
 func resolveTheme() *huh.Theme {
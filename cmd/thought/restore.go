@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/snapshot"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <thought>",
+	Short: "Restore a thought's workspace from a snapshot",
+	Long: `The workspace is snapshotted each time the agent takes over a run, so a
+destructive decision can be rolled back. Restoring replaces the whole
+workspace; its current contents are snapshotted first, so a restore can
+itself be restored. Without --to, the snapshots are listed.
+
+Set "snapshots" in config.json to change how many are kept (default 5,
+-1 disables snapshots).
+
+Examples:
+  thought restore weather --list
+  thought restore weather --to 20260101-120000`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runRestore,
+	SilenceUsage: true,
+}
+
+var (
+	restoreToFlag   string
+	restoreListFlag bool
+)
+
+func init() {
+	restoreCmd.Flags().StringVar(&restoreToFlag, "to", "", "Snapshot ID to restore (see --list)")
+	restoreCmd.Flags().BoolVar(&restoreListFlag, "list", false, "List workspace snapshots")
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "restore")
+	if err != nil {
+		return err
+	}
+
+	if restoreListFlag || restoreToFlag == "" {
+		snaps, err := snapshot.List(thoughtDir)
+		if err != nil {
+			return fmt.Errorf("reading snapshots: %w", err)
+		}
+		if len(snaps) == 0 {
+			fmt.Fprintln(os.Stderr, "No workspace snapshots.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SNAPSHOT\tCREATED\tFILES\tSIZE")
+		for _, s := range snaps {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", s.ID, s.Created.Local().Format("2006-01-02 15:04:05"), s.Files, s.Size)
+		}
+		return w.Flush()
+	}
+
+	backup, err := snapshot.Restore(thoughtDir, filepath.Join(config.ThoughtDataDir(thoughtDir), "workspace"), restoreToFlag)
+	if errors.Is(err, snapshot.ErrNotFound) {
+		return fmt.Errorf("no snapshot %q (see 'thought restore %s --list')", restoreToFlag, args[0])
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Restored workspace to snapshot %s.\n", restoreToFlag)
+	if backup != nil {
+		fmt.Fprintf(os.Stderr, "The previous workspace was saved as snapshot %s.\n", backup.ID)
+	}
+	return nil
+}
@@ -3,10 +3,8 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
-	"github.com/thinkingscript/cli/internal/config"
 )
 
 var rmForceFlag bool
@@ -35,9 +33,8 @@ func runRm(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("'%s' is a file, not an installed thought.\nTo remove the file: rm %s", args[0], args[0])
 	}
 
-	name := resolved.Name
 	binPath := resolved.Path
-	thoughtDir := filepath.Join(config.HomeDir(), "thoughts", name)
+	thoughtDir := thoughtDirFor(resolved)
 
 	// Remove binary (resolver already verified it exists)
 	if err := os.Remove(binPath); err != nil {
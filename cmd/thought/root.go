@@ -11,6 +11,10 @@ var rootCmd = &cobra.Command{
 	Use:          "thought",
 	Short:        "Manage thinkingscript thoughts",
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		_, err := preference() // reject a bad --prefer before it's needed
+		return err
+	},
 }
 
 func execute(ctx context.Context) {
@@ -20,6 +24,8 @@ func execute(ctx context.Context) {
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&preferFlag, "prefer", "", "When a name is both a file and an installed thought, use the \"file\" or \"installed\" one instead of asking (default from config.json \"prefer\")")
+
 	rootCmd.AddCommand(cacheCmd)
 	rootCmd.AddCommand(buildCmd)
 	rootCmd.AddCommand(infoCmd)
@@ -33,7 +39,24 @@ func init() {
 	rootCmd.AddCommand(rmCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(whichCmd)
 	rootCmd.AddCommand(memoryCmd)
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(policyCmd)
+	rootCmd.AddCommand(trashCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(renameCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(unfreezeCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(publishCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(debugCmd)
+	rootCmd.AddCommand(queueCmd)
+	rootCmd.AddCommand(scheduleCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(examplesCmd)
+	rootCmd.AddCommand(localeCmd)
 }
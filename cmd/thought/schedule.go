@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/schedule"
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run thoughts on cron schedules",
+	Long: `Schedule thoughts to run at set times; 'thought daemon' runs them. The
+schedule lives in ~/.thinkingscript/schedule/.
+
+Scheduled runs use memory.js only (think --no-agent): when memory.js
+hands over to the agent, the run fails instead. They have no stdin and no
+terminal, so approval prompts are denied: grant what a scheduled thought
+needs beforehand (thought policy add), and run it by hand once so it has
+a working memory.js. Each run's output and exit status, including why
+memory.js handed over, are appended to the thought's runs/schedule.log.
+
+Examples:
+  thought schedule add weather "0 8 * * *"
+  thought schedule add report "0 9 * * mon" --week last
+  thought schedule ls
+  thought schedule log weather
+  thought daemon`,
+	SilenceUsage: true,
+}
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add <script> <cron> [args...]",
+	Short: "Schedule a thought",
+	Long: `Schedule a script file, URL, or installed thought. cron is a five-field
+expression (minute hour day-of-month month day-of-week, in local time) or
+one of @hourly, @daily, @weekly, @monthly, and @yearly. The thought runs
+in the current directory with the daemon's environment; everything after
+cron is passed to it.`,
+	Args:         cobra.MinimumNArgs(2),
+	RunE:         runScheduleAdd,
+	SilenceUsage: true,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List scheduled thoughts and when they next run",
+	Args:         cobra.NoArgs,
+	RunE:         runScheduleList,
+	SilenceUsage: true,
+}
+
+var scheduleRmCmd = &cobra.Command{
+	Use:          "rm <id>...",
+	Short:        "Remove scheduled thoughts",
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runScheduleRm,
+	SilenceUsage: true,
+}
+
+var scheduleLogCmd = &cobra.Command{
+	Use:          "log <script|id>",
+	Short:        "Print a thought's scheduled run log",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runScheduleLog,
+	SilenceUsage: true,
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled thoughts when they're due",
+	Long: `Run scheduled thoughts (see 'thought schedule') when they're due, until
+interrupted. The schedule is re-read every minute, so changes apply
+without a restart. Only one daemon runs at a time; a thought still
+running when it's next due is skipped. Interrupting the daemon stops the
+running thoughts.`,
+	Args:         cobra.NoArgs,
+	RunE:         runDaemon,
+	SilenceUsage: true,
+}
+
+func init() {
+	// Everything after the cron expression belongs to the script
+	scheduleAddCmd.Flags().SetInterspersed(false)
+
+	scheduleCmd.AddCommand(scheduleAddCmd)
+	scheduleCmd.AddCommand(scheduleListCmd)
+	scheduleCmd.AddCommand(scheduleRmCmd)
+	scheduleCmd.AddCommand(scheduleLogCmd)
+}
+
+func scheduleDir() string {
+	return filepath.Join(config.HomeDir(), "schedule")
+}
+
+func runScheduleAdd(cmd *cobra.Command, args []string) error {
+	resolved, err := ResolveThought(args[0], "schedule add")
+	if err != nil {
+		return err
+	}
+	script := resolved.Path
+	if resolved.Target == TargetFile {
+		if script, err = filepath.Abs(script); err != nil {
+			return err
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	e, err := schedule.Add(scheduleDir(), script, args[2:], cwd, thoughtDirFor(resolved), args[1])
+	if err != nil {
+		return fmt.Errorf("adding to schedule: %w", err)
+	}
+	c, _ := schedule.ParseCron(e.Cron)
+	fmt.Println(e.ID)
+	fmt.Fprintf(os.Stderr, "Next run: %s (while 'thought daemon' is running)\n", nextRun(c))
+	return nil
+}
+
+func runScheduleList(cmd *cobra.Command, args []string) error {
+	entries, err := schedule.List(scheduleDir())
+	if err != nil {
+		return fmt.Errorf("reading schedule: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "Nothing is scheduled.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSCHEDULE\tNEXT\tCOMMAND")
+	for _, e := range entries {
+		next := "invalid"
+		if c, err := schedule.ParseCron(e.Cron); err == nil {
+			next = nextRun(c)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.ID, e.Cron, next, scheduleCommand(e))
+	}
+	return w.Flush()
+}
+
+// nextRun formats when a schedule next runs.
+func nextRun(c *schedule.Cron) string {
+	next := c.Next(time.Now())
+	if next.IsZero() {
+		return "never"
+	}
+	return next.Format("2006-01-02 15:04")
+}
+
+// scheduleCommand shows an entry as the command it runs.
+func scheduleCommand(e schedule.Entry) string {
+	script := e.Script
+	if strings.HasPrefix(script, config.BinDir()+string(filepath.Separator)) {
+		script = filepath.Base(script)
+	}
+	return strings.TrimSpace(script + " " + strings.Join(e.Args, " "))
+}
+
+func runScheduleRm(cmd *cobra.Command, args []string) error {
+	for _, id := range args {
+		err := schedule.Remove(scheduleDir(), id)
+		switch {
+		case errors.Is(err, schedule.ErrNotFound):
+			return fmt.Errorf("no scheduled thought %q (see 'thought schedule ls')", id)
+		case err != nil:
+			return fmt.Errorf("removing %s: %w", id, err)
+		}
+		fmt.Fprintf(os.Stderr, "Removed %s\n", id)
+	}
+	return nil
+}
+
+func runScheduleLog(cmd *cobra.Command, args []string) error {
+	// An entry ID, or any reference to the thought
+	var thoughtDir string
+	entries, err := schedule.List(scheduleDir())
+	if err != nil {
+		return fmt.Errorf("reading schedule: %w", err)
+	}
+	for _, e := range entries {
+		if e.ID == args[0] {
+			thoughtDir = e.ThoughtDir
+		}
+	}
+	if thoughtDir == "" {
+		if thoughtDir, err = ResolveThoughtDir(args[0], "schedule log"); err != nil {
+			return err
+		}
+	}
+	data, err := os.ReadFile(schedule.LogPath(thoughtDir))
+	if os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "No scheduled runs yet.")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading log: %w", err)
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func runDaemon(cmd *cobra.Command, args []string) error {
+	think, err := thinkBinary()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := &schedule.Daemon{
+		Dir:   scheduleDir(),
+		Think: think,
+		OnStart: func(e schedule.Entry) {
+			fmt.Fprintf(os.Stderr, "%s  running %s\n", e.ID, scheduleCommand(e))
+		},
+		OnDone: func(e schedule.Entry, r schedule.Run) {
+			status := "succeeded"
+			switch {
+			case r.Error != "":
+				status = "error: " + r.Error
+			case r.ExitCode != 0:
+				status = "failed"
+			}
+			fmt.Fprintf(os.Stderr, "%s  %s (exit %d, %s)\n", e.ID, status, r.ExitCode, r.Ended.Sub(r.Started).Round(time.Millisecond))
+		},
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "warning: reading schedule: %v\n", err)
+		},
+	}
+	fmt.Fprintf(os.Stderr, "Running scheduled thoughts from %s\n", d.Dir)
+	err = d.Run(ctx)
+	if errors.Is(err, schedule.ErrBusy) {
+		return fmt.Errorf("%w on %s", err, d.Dir)
+	}
+	return err
+}
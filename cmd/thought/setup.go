@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -12,17 +14,31 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
 	"github.com/thinkingscript/cli/internal/ui"
 )
 
 var (
-	setupAPIKeyFlag string
-	setupModelFlag  string
+	setupAPIKeyFlag   string
+	setupModelFlag    string
+	setupProviderFlag string
+	setupAPIBaseFlag  string
 )
 
 var setupCmd = &cobra.Command{
-	Use:          "setup",
-	Short:        "Configure thinkingscript with your API key and model",
+	Use:   "setup",
+	Short: "Configure thinkingscript with your API key and model",
+	Long: `Configure the agent thoughts run with and make it the default.
+
+With --provider anthropic (the default), saves an Anthropic API key and
+model to agents/anthropic.json. With --provider ollama, picks one of the
+models installed on a local Ollama server and saves it to
+agents/ollama.json, so thoughts run fully offline.
+
+Examples:
+  thought setup
+  thought setup --provider ollama --model llama3.1`,
 	RunE:         runSetup,
 	SilenceUsage: true,
 }
@@ -30,6 +46,8 @@ var setupCmd = &cobra.Command{
 func init() {
 	setupCmd.Flags().StringVar(&setupAPIKeyFlag, "api-key", "", "Anthropic API key")
 	setupCmd.Flags().StringVar(&setupModelFlag, "model", "", "Model to use (e.g. claude-sonnet-4-5-20250929)")
+	setupCmd.Flags().StringVar(&setupProviderFlag, "provider", "", "Provider to set up: anthropic or ollama")
+	setupCmd.Flags().StringVar(&setupAPIBaseFlag, "api-base", "", "Ollama server address (default $OLLAMA_HOST or "+provider.DefaultOllamaBase+")")
 }
 
 func runSetup(cmd *cobra.Command, args []string) error {
@@ -37,6 +55,24 @@ func runSetup(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("initializing home directory: %w", err)
 	}
 
+	providerName := setupProviderFlag
+	if providerName == "" {
+		providerName = "anthropic"
+		if setupAPIKeyFlag == "" && setupModelFlag == "" {
+			var err error
+			if providerName, err = promptProvider(); err != nil {
+				return err
+			}
+		}
+	}
+	switch providerName {
+	case "anthropic":
+	case "ollama":
+		return setupOllama(cmd.Context())
+	default:
+		return fmt.Errorf("unsupported provider %q (must be anthropic or ollama)", providerName)
+	}
+
 	existing := config.LoadAgent("anthropic")
 
 	apiKey := setupAPIKeyFlag
@@ -87,6 +123,9 @@ func runSetup(cmd *cobra.Command, args []string) error {
 	if err := config.SaveAgent("anthropic", agent); err != nil {
 		return fmt.Errorf("saving config: %w", err)
 	}
+	if err := makeDefaultAgent("anthropic"); err != nil {
+		return err
+	}
 
 	configPath := filepath.Join(config.HomeDir(), "agents", "anthropic.json")
 	fmt.Fprintf(os.Stderr, "\n  Config saved to %s\n", configPath)
@@ -94,6 +133,106 @@ func runSetup(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// setupOllama saves an agent for a model on a local Ollama server.
+func setupOllama(ctx context.Context) error {
+	existing := config.LoadAgent("ollama")
+	base := setupAPIBaseFlag
+	if base == "" {
+		base = existing.APIBase
+	}
+
+	stop := ui.Spinner(i18n.T("spinner.connecting_ollama"))
+	models, err := provider.OllamaModels(ctx, base)
+	stop()
+
+	model := setupModelFlag
+	switch {
+	case model != "" && err != nil:
+		fmt.Fprintf(os.Stderr, "\n  %v\n  Saving anyway.\n", err)
+	case model != "":
+		if !slices.Contains(models, model) && !slices.Contains(models, model+":latest") {
+			fmt.Fprintf(os.Stderr, "\n  %s is not installed yet; run: ollama pull %s\n", model, model)
+		}
+	case err != nil:
+		return fmt.Errorf("%w; start Ollama (https://ollama.com) or pass --model to save without checking", err)
+	case len(models) == 0:
+		return errors.New("no models are installed in Ollama; pull one first, e.g. ollama pull llama3.1")
+	default:
+		if model, err = promptOllamaModel(models, existing.Model); err != nil {
+			return err
+		}
+	}
+
+	agent := &config.AgentConfig{
+		Version:  1,
+		Provider: "ollama",
+		APIBase:  base,
+		Model:    model,
+	}
+	if err := config.SaveAgent("ollama", agent); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	if err := makeDefaultAgent("ollama"); err != nil {
+		return err
+	}
+
+	configPath := filepath.Join(config.HomeDir(), "agents", "ollama.json")
+	fmt.Fprintf(os.Stderr, "\n  Config saved to %s\n", configPath)
+	fmt.Fprintf(os.Stderr, "  Thoughts now run on %s through Ollama. Switch back with: thought setup --provider anthropic\n", model)
+	return nil
+}
+
+// makeDefaultAgent points config.json at the agent just set up.
+func makeDefaultAgent(name string) error {
+	if config.LoadConfig().Agent == name {
+		return nil
+	}
+	if err := config.SetDefaultAgent(name); err != nil {
+		return fmt.Errorf("saving config: %w", err)
+	}
+	return nil
+}
+
+func promptProvider() (string, error) {
+	var name string
+	sel := huh.NewSelect[string]().
+		Title("Provider").
+		Description("Where should thoughts run?").
+		Options(
+			huh.NewOption("Anthropic API (recommended)", "anthropic"),
+			huh.NewOption("Ollama (local models, offline)", "ollama"),
+		).
+		Value(&name)
+
+	form := huh.NewForm(huh.NewGroup(sel)).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
+	if err := form.Run(); err != nil {
+		return "", fmt.Errorf("prompt cancelled")
+	}
+	return name, nil
+}
+
+func promptOllamaModel(models []string, existing string) (string, error) {
+	options := make([]huh.Option[string], len(models))
+	for i, m := range models {
+		options[i] = huh.NewOption(m, m)
+	}
+	model := models[0]
+	if slices.Contains(models, existing) {
+		model = existing
+	}
+	sel := huh.NewSelect[string]().
+		Title("Model").
+		Description("Models with tool support work best; others answer in JSON mode").
+		Options(options...).
+		Value(&model)
+
+	form := huh.NewForm(huh.NewGroup(sel)).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
+	if err := form.Run(); err != nil {
+		return "", fmt.Errorf("prompt cancelled")
+	}
+	return model, nil
+}
+
 func promptAPIKey(existing string) (string, error) {
 	var apiKey string
 	placeholder := "sk-ant-..."
@@ -114,7 +253,7 @@ func promptAPIKey(existing string) (string, error) {
 		EchoMode(huh.EchoModePassword).
 		Value(&apiKey)
 
-	form := huh.NewForm(huh.NewGroup(input)).WithOutput(os.Stderr)
+	form := huh.NewForm(huh.NewGroup(input)).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
 	if err := form.Run(); err != nil {
 		return "", fmt.Errorf("prompt cancelled")
 	}
@@ -149,7 +288,7 @@ func promptModel(existing string) (string, error) {
 	// Pre-select the existing/default model
 	model = defaultModel
 
-	form := huh.NewForm(huh.NewGroup(sel)).WithOutput(os.Stderr)
+	form := huh.NewForm(huh.NewGroup(sel)).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
 	if err := form.Run(); err != nil {
 		return "", fmt.Errorf("prompt cancelled")
 	}
@@ -158,7 +297,7 @@ func promptModel(existing string) (string, error) {
 }
 
 func validateAPIKey(ctx context.Context, apiKey string) bool {
-	stop := ui.Spinner("Validating API key...")
+	stop := ui.Spinner(i18n.T("spinner.validating_key"))
 	defer stop()
 
 	client := anthropic.NewClient(option.WithAPIKey(apiKey))
@@ -181,7 +320,7 @@ func promptSaveAnyway() (bool, error) {
 		Negative("No").
 		Value(&save)
 
-	form := huh.NewForm(huh.NewGroup(confirm)).WithOutput(os.Stderr)
+	form := huh.NewForm(huh.NewGroup(confirm)).WithOutput(os.Stderr).WithAccessible(ui.Accessible())
 	if err := form.Run(); err != nil {
 		return false, fmt.Errorf("prompt cancelled")
 	}
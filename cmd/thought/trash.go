@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/trash"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore, or empty a thought's trash",
+	Long: `Scripts' fs.delete moves paths outside the workspace into the thought's
+trash instead of removing them. Use these commands to get them back or
+free the space.
+
+Examples:
+  thought trash ls weather
+  thought trash restore weather 20260101-120000-0
+  thought trash empty weather --older-than 168h`,
+	SilenceUsage: true,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:          "ls <thought>",
+	Aliases:      []string{"list"},
+	Short:        "List trashed paths",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runTrashList,
+	SilenceUsage: true,
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:          "restore <thought> <id>",
+	Short:        "Move a trashed path back to where it was deleted from",
+	Args:         cobra.ExactArgs(2),
+	RunE:         runTrashRestore,
+	SilenceUsage: true,
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:          "empty <thought>",
+	Short:        "Permanently remove trashed paths",
+	Args:         cobra.ExactArgs(1),
+	RunE:         runTrashEmpty,
+	SilenceUsage: true,
+}
+
+var (
+	trashToFlag        string
+	trashOlderThanFlag time.Duration
+)
+
+func init() {
+	trashRestoreCmd.Flags().StringVar(&trashToFlag, "to", "", "Restore to this path instead of the original location")
+	trashEmptyCmd.Flags().DurationVar(&trashOlderThanFlag, "older-than", 0, "Only remove entries deleted longer ago than this (e.g. 168h)")
+
+	trashCmd.AddCommand(trashListCmd)
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashEmptyCmd)
+}
+
+func runTrashList(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "trash ls")
+	if err != nil {
+		return err
+	}
+	entries, err := trash.List(trash.Dir(thoughtDir))
+	if err != nil {
+		return fmt.Errorf("reading trash: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "Trash is empty.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDELETED\tSIZE\tPATH")
+	for _, e := range entries {
+		path := e.Path
+		if e.IsDir {
+			path += "/"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.ID, e.Deleted.Local().Format("2006-01-02 15:04"), formatBytes(e.Size), path)
+	}
+	return w.Flush()
+}
+
+func runTrashRestore(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "trash restore")
+	if err != nil {
+		return err
+	}
+	to := trashToFlag
+	if to != "" {
+		if to, err = filepath.Abs(to); err != nil {
+			return err
+		}
+	}
+	e, err := trash.Restore(trash.Dir(thoughtDir), args[1], to)
+	if errors.Is(err, trash.ErrNotFound) {
+		return fmt.Errorf("no trash entry %q (see 'thought trash ls %s')", args[1], args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("restoring: %w", err)
+	}
+	dest := e.Path
+	if to != "" {
+		dest = to
+	}
+	fmt.Fprintf(os.Stderr, "Restored %s\n", dest)
+	return nil
+}
+
+func runTrashEmpty(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "trash empty")
+	if err != nil {
+		return err
+	}
+	var before time.Time
+	if trashOlderThanFlag > 0 {
+		before = time.Now().Add(-trashOlderThanFlag)
+	}
+	n, err := trash.Empty(trash.Dir(thoughtDir), before)
+	if err != nil {
+		return fmt.Errorf("emptying trash: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Removed %d trash entr%s.\n", n, plural(n, "y", "ies"))
+	return nil
+}
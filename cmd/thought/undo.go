@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/journal"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo <thought>",
+	Short: "Roll back the filesystem changes of a run",
+	Long: `Revert the file writes, moves, directory creations, and deletes a run made.
+Without --run, the most recent run that hasn't been undone is rolled back.
+Files larger than 1 MB that were overwritten or removed permanently can't
+be restored; those are reported and skipped.
+
+Examples:
+  thought undo weather --list
+  thought undo weather
+  thought undo weather --run 20260101-120000-0`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runUndo,
+	SilenceUsage: true,
+}
+
+var (
+	undoRunFlag  string
+	undoListFlag bool
+)
+
+func init() {
+	undoCmd.Flags().StringVar(&undoRunFlag, "run", "", "Run ID to undo (see --list)")
+	undoCmd.Flags().BoolVar(&undoListFlag, "list", false, "List journaled runs")
+}
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	thoughtDir, err := ResolveThoughtDir(args[0], "undo")
+	if err != nil {
+		return err
+	}
+	runs, err := journal.Runs(thoughtDir)
+	if err != nil {
+		return fmt.Errorf("reading journal: %w", err)
+	}
+
+	if undoListFlag {
+		if len(runs) == 0 {
+			fmt.Fprintln(os.Stderr, "No journaled runs.")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RUN\tSTARTED\tOPS\tSTATUS")
+		for _, r := range runs {
+			status := "-"
+			if r.Undone {
+				status = "undone"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", r.ID, r.Started.Local().Format("2006-01-02 15:04:05"), r.Ops, status)
+		}
+		return w.Flush()
+	}
+
+	runID := undoRunFlag
+	if runID == "" {
+		for i := len(runs) - 1; i >= 0; i-- {
+			if !runs[i].Undone {
+				runID = runs[i].ID
+				break
+			}
+		}
+		if runID == "" {
+			return errors.New("no run to undo")
+		}
+	}
+
+	undone, problems, err := journal.Undo(thoughtDir, runID)
+	if errors.Is(err, journal.ErrUndone) {
+		return fmt.Errorf("run %s was already undone", runID)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Undid %d change%s from run %s.\n", undone, plural(undone, "", "s"), runID)
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "  could not undo %s\n", p)
+	}
+	return nil
+}
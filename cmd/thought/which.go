@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+var whichCmd = &cobra.Command{
+	Use:   "which <name>",
+	Short: "Show which thought or command runs for a name",
+	Long: `Show what a name refers to: a file in the current directory, an
+installed thought and its data directory, and every command of that name
+on PATH in the order the shell searches them. The first one on PATH is
+what runs when you type the name; if it isn't the installed thought, it
+shadows it.
+
+Examples:
+  thought which weather
+  thought which ./weather.md`,
+	Args:         cobra.ExactArgs(1),
+	RunE:         runWhich,
+	SilenceUsage: true,
+}
+
+func runWhich(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	// Paths and URLs aren't looked up on PATH
+	if strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		resolved, err := ResolveThought(name, "which")
+		if err != nil {
+			return err
+		}
+		kind := "file"
+		if resolved.Target == TargetURL {
+			kind = "url"
+		}
+		fmt.Printf("%-10s %s\n", kind+":", resolved.Path)
+		if resolved.Target != TargetURL {
+			fmt.Printf("%-10s %s\n", "data:", thoughtDirFor(resolved))
+		}
+		return nil
+	}
+
+	binPath := filepath.Join(config.BinDir(), name)
+	found := false
+	if info, err := os.Stat(name); err == nil && !info.IsDir() {
+		found = true
+		fmt.Printf("%-10s ./%s (thought commands ask which you mean unless --prefer says; use ./%s to pick the file)\n", "file:", name, name)
+	}
+	if info, err := os.Stat(binPath); err == nil && !info.IsDir() {
+		found = true
+		fmt.Printf("%-10s %s\n", "installed:", binPath)
+		fmt.Printf("%-10s %s\n", "data:", thoughtDirFor(&ResolveResult{Path: binPath, Target: TargetInstalled, Name: name}))
+	}
+
+	onPath := pathCommands(name)
+	if len(onPath) > 0 {
+		found = true
+		fmt.Println("PATH:")
+		for i, p := range onPath {
+			var notes []string
+			if sameFile(p, binPath) {
+				notes = append(notes, "installed thought")
+			}
+			if i == 0 {
+				notes = append(notes, "runs as `"+name+"`")
+				if !sameFile(p, binPath) && fileExists(binPath) {
+					notes = append(notes, "shadows the installed thought")
+				}
+			}
+			line := "  " + p
+			if len(notes) > 0 {
+				line += "  (" + strings.Join(notes, "; ") + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+	if fileExists(binPath) && !onPathDir(config.BinDir()) {
+		fmt.Printf("note: %s isn't on PATH, so the installed thought only runs as `think %s`; add it with\n  export PATH=\"$PATH:%s\"\n", config.BinDir(), binPath, config.BinDir())
+	}
+
+	if !found {
+		return fmt.Errorf("'%s' not found (no file, installed thought, or command on PATH)", name)
+	}
+	return nil
+}
+
+// pathCommands returns every executable named name on PATH, in search
+// order.
+func pathCommands(name string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		p, err := exec.LookPath(filepath.Join(dir, name))
+		if err != nil || seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out
+}
+
+// onPathDir reports whether dir is one of PATH's directories.
+func onPathDir(dir string) bool {
+	for _, d := range filepath.SplitList(os.Getenv("PATH")) {
+		if sameFile(d, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
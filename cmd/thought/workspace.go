@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/thinkingscript/cli/internal/config"
@@ -25,16 +24,11 @@ func runPath(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	var thoughtDir string
-	if resolved.Target == TargetInstalled {
-		thoughtDir = filepath.Join(config.HomeDir(), "thoughts", resolved.Name)
-	} else {
-		thoughtDir = config.ThoughtDir(resolved.Path)
-	}
+	dataDir := config.ThoughtDataDir(thoughtDirFor(resolved))
 
-	if _, err := os.Stat(thoughtDir); os.IsNotExist(err) {
+	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
 		fmt.Fprintln(os.Stderr, "No thought data yet.")
 	}
-	fmt.Println(thoughtDir)
+	fmt.Println(dataDir)
 	return nil
 }
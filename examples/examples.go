@@ -0,0 +1,46 @@
+// Package examples embeds the curated example thoughts that ship with the
+// thought binary (`thought examples`).
+package examples
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed weather.md organize.md changelog.md
+var files embed.FS
+
+// Example is a built-in example thought.
+type Example struct {
+	Name    string // install name
+	File    string // file in this directory
+	Summary string
+	Usage   string // how to run it once installed
+}
+
+// All lists the built-in examples in the order `thought examples ls` shows
+// them.
+var All = []Example{
+	{Name: "weather", File: "weather.md", Summary: "Current weather for your location or a city", Usage: `weather "San Francisco"`},
+	{Name: "organize", File: "organize.md", Summary: "Sort a directory's files into folders by type", Usage: "organize ~/Downloads --apply"},
+	{Name: "changelog", File: "changelog.md", Summary: "Turn commit subjects into a Markdown changelog entry", Usage: "git log --oneline v1.2.0..HEAD | changelog 1.3.0"},
+}
+
+// Get returns the example with the given name.
+func Get(name string) (Example, bool) {
+	for _, e := range All {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Example{}, false
+}
+
+// Content returns the example's script.
+func (e Example) Content() ([]byte, error) {
+	data, err := files.ReadFile(e.File)
+	if err != nil {
+		return nil, fmt.Errorf("example %s: %w", e.Name, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,40 @@
+package examples
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/script"
+)
+
+func TestExamplesParse(t *testing.T) {
+	dir := t.TempDir()
+	for _, e := range All {
+		data, err := e.Content()
+		if err != nil {
+			t.Fatalf("Content error: %v", err)
+		}
+		path := filepath.Join(dir, e.File)
+		os.WriteFile(path, data, 0644)
+		parsed, err := script.Parse(path)
+		if err != nil {
+			t.Errorf("%s: %v", e.Name, err)
+			continue
+		}
+		// Installed under its name, the thought's data must use it too
+		if parsed.Config != nil && parsed.Config.Name != "" && parsed.Config.Name != e.Name {
+			t.Errorf("%s: frontmatter name is %q", e.Name, parsed.Config.Name)
+		}
+		if err := config.ValidateThoughtName(e.Name); err != nil {
+			t.Errorf("%s: %v", e.Name, err)
+		}
+	}
+	if _, ok := Get("organize"); !ok {
+		t.Error("Get(organize) found nothing")
+	}
+	if _, ok := Get("nope"); ok {
+		t.Error("Get(nope) found an example")
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -170,6 +172,134 @@ func TestBootFlowMemoryJSError(t *testing.T) {
 	}
 }
 
+// Stream and Map read and review memory.js the way TryMemoryJS does, so a
+// memory.js lint blocks never runs on any path.
+func TestBootFlowReviewEveryPath(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+	thoughtDir := filepath.Join(tmpHome, "thoughts", "test")
+	workspaceDir := filepath.Join(thoughtDir, "workspace")
+	memoriesDir := filepath.Join(thoughtDir, "memories")
+	memoryJSPath := filepath.Join(thoughtDir, "memory.js")
+	ranPath := filepath.Join(workspaceDir, "ran")
+
+	os.MkdirAll(workspaceDir, 0700)
+	os.MkdirAll(memoriesDir, 0700)
+	os.WriteFile(memoryJSPath, []byte(`fs.writeFile("`+ranPath+`", "yes")
+		process.stdin.on("line", function (line) { process.stdout.write(line) });`), 0644)
+
+	var reviewed []string
+	cfg := boot.Config{
+		MemoryJSPath: memoryJSPath,
+		WorkDir:      thoughtDir,
+		ThoughtDir:   thoughtDir,
+		WorkspaceDir: workspaceDir,
+		MemoriesDir:  memoriesDir,
+		Review: func(code string) error {
+			reviewed = append(reviewed, code)
+			return errors.New("lint: blocked")
+		},
+	}
+
+	result := boot.TryMemoryJS(context.Background(), cfg)
+	if result.Success || result.ResumeContext != "memory.js error: lint: blocked" {
+		t.Errorf("TryMemoryJS = %+v, want the review error", result)
+	}
+
+	lines := bufio.NewScanner(strings.NewReader("first\nsecond\n"))
+	result, err := boot.Stream(context.Background(), cfg, lines)
+	if err != nil || result.Success || result.Line != "first" || result.ResumeContext != "memory.js error: lint: blocked" {
+		t.Errorf("Stream = %+v, %v; want the review error with the first line", result, err)
+	}
+
+	results, fail := boot.Map(context.Background(), cfg, []string{"a", "b"}, 2)
+	if results != nil || fail == nil || fail.ResumeContext != "memory.js error: lint: blocked" {
+		t.Errorf("Map = %v, %+v; want the review error", results, fail)
+	}
+
+	if len(reviewed) != 3 {
+		t.Errorf("Review called %d times, want 3", len(reviewed))
+	}
+	if _, err := os.Stat(ranPath); err == nil {
+		t.Error("memory.js ran although Review rejected it")
+	}
+}
+
+func TestBootFlowStream(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+	thoughtDir := filepath.Join(tmpHome, "thoughts", "test")
+	memoryJSPath := filepath.Join(thoughtDir, "memory.js")
+	os.MkdirAll(thoughtDir, 0700)
+	os.WriteFile(memoryJSPath, []byte(`process.stdin.on("line", function (line) {
+		if (line === "?") agent.resume("unknown line");
+		process.stdout.write(line.toUpperCase() + "\n");
+	});`), 0644)
+
+	var stdout strings.Builder
+	cfg := boot.Config{
+		MemoryJSPath: memoryJSPath,
+		WorkDir:      thoughtDir,
+		ThoughtDir:   thoughtDir,
+		WorkspaceDir: filepath.Join(thoughtDir, "workspace"),
+		MemoriesDir:  filepath.Join(thoughtDir, "memories"),
+		Stdout:       &stdout,
+	}
+	lines := bufio.NewScanner(strings.NewReader("a\n?\nb\n"))
+
+	result, err := boot.Stream(context.Background(), cfg, lines)
+	if err != nil || !result.Resumed || result.Line != "?" || result.ResumeContext != "unknown line" {
+		t.Errorf("Stream = %+v, %v; want a resume at line ?", result, err)
+	}
+	// The caller hands the line to the agent and streams the rest
+	result, err = boot.Stream(context.Background(), cfg, lines)
+	if err != nil || !result.Success {
+		t.Errorf("second Stream = %+v, %v; want success", result, err)
+	}
+	if stdout.String() != "A\nB\n" {
+		t.Errorf("stdout = %q, want %q", stdout.String(), "A\nB\n")
+	}
+}
+
+func TestBootFlowMap(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+	thoughtDir := filepath.Join(tmpHome, "thoughts", "test")
+	memoryJSPath := filepath.Join(thoughtDir, "memory.js")
+	cfg := boot.Config{
+		MemoryJSPath: memoryJSPath,
+		WorkDir:      thoughtDir,
+		ThoughtDir:   thoughtDir,
+		WorkspaceDir: filepath.Join(thoughtDir, "workspace"),
+		MemoriesDir:  filepath.Join(thoughtDir, "memories"),
+	}
+
+	// No memory.js: nothing runs
+	if results, fail := boot.Map(context.Background(), cfg, []string{"a"}, 1); results != nil || fail == nil || !os.IsNotExist(fail.Err) {
+		t.Errorf("Map without memory.js = %v, %+v", results, fail)
+	}
+
+	os.MkdirAll(thoughtDir, 0700)
+	os.WriteFile(memoryJSPath, []byte(`var x = process.args[0];
+		if (x === "?") agent.resume("unknown input");
+		process.stdout.write("out:");
+		x.toUpperCase()`), 0644)
+
+	results, fail := boot.Map(context.Background(), cfg, []string{"a", "?", "b"}, 2)
+	if fail != nil || len(results) != 3 {
+		t.Fatalf("Map = %v, %+v", results, fail)
+	}
+	if !results[0].Success || results[0].Output != "out:A" || !results[2].Success || results[2].Output != "out:B" {
+		t.Errorf("Map outputs = %q, %q", results[0].Output, results[2].Output)
+	}
+	if !results[1].Resumed || results[1].ResumeContext != "unknown input" {
+		t.Errorf("Map result for ? = %+v, want a resume", results[1])
+	}
+}
+
 func TestSandboxWithRealFileSystem(t *testing.T) {
 	tmpHome := t.TempDir()
 	tmpHome, _ = filepath.EvalSymlinks(tmpHome)
@@ -10,7 +10,10 @@ import (
 	"strings"
 
 	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/journal"
 	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/runlog"
 	"github.com/thinkingscript/cli/internal/tools"
 	"github.com/thinkingscript/cli/internal/ui"
 	"github.com/charmbracelet/lipgloss"
@@ -37,6 +40,8 @@ input you need (stdin, arguments) is already in the user message.
   parameter. Do NOT try to run files — there is no file execution, only
   inline code. All code is synchronous — do NOT use async, await, or
   Promises. The last expression value is returned as the result.
+  Pass a short "reason" too — it's shown to the user if the script needs
+  their approval (e.g. to read a file outside the sandbox).
 
   IMPORTANT: This is NOT Node.js. There are no Node.js built-in modules
   (no "fs", "path", "http", etc). ONLY the globals listed below exist.
@@ -66,21 +71,61 @@ input you need (stdin, arguments) is already in the user message.
     fs.readFile(path) → string (reads entire file contents)
     fs.writeFile(path, content)
     fs.appendFile(path, content)
-    fs.readDir(path) → [{name, isDir, size}] (includes file sizes)
-    fs.stat(path) → {name, isDir, size, modTime} (file metadata without reading contents)
+    fs.readDir(path) → [{name, isDir, isSymlink, size, mode}] (includes file sizes)
+    fs.readDir(path, {recursive, maxEntries, offset, sort})
+      → {entries: [{name, isDir, isSymlink, size, modTime, mode}], hasMore, nextOffset}
+      Pages of 1000 by default. sort: "name", "size", "modTime"; prefix
+      "-" to reverse. Recursive names are relative ("src/main.go"). Use
+      this for large trees and keep calling with offset: nextOffset while
+      hasMore.
+    fs.stat(path) → {name, isDir, size, modTime, mode, perm, isSymlink, uid, gid}
+      (file metadata without reading contents; mode is "-rw-r--r--", perm "0644")
+    fs.statMany([paths]) → [{path, ...fs.stat fields}] in one call; a path
+      that fails gets {path, error, code} instead of throwing
+    fs.du(path, {maxDepth, maxEntries}?) → {size, files, dirs, truncated,
+      entries: [{name, isDir, size, files}]} total bytes under path,
+      computed in one call; entries (largest first) break it down to
+      maxDepth levels (default 1). Use this for "how big is this folder"
+      instead of recursing with fs.stat.
+    mime.detect(path) → string (content type sniffed from the first 4 KB,
+      e.g. "image/png", "application/json", "inode/directory")
+    json.stream(source, selector, {limit}?) → [values] at selector, parsed
+      Go-side without loading the whole document (source is a file path,
+      or JSON text starting with { or [; JSON Lines work too). Selector is
+      a dot path: "data.items.#.id" ("#" or "*" = every element or member,
+      "0" = an index, "" = each whole document). Prefer this over
+      JSON.parse for multi-megabyte files and response bodies.
       Use fs.stat or fs.readDir for file sizes — do NOT read file contents
       just to get metadata.
     fs.exists(path) → boolean
-    fs.delete(path)
+    fs.delete(path, options?) (outside the workspace, moves to the thought's
+      trash so the user can restore it; {permanent: true} skips the trash)
     fs.mkdir(path) (recursive, like mkdir -p)
     fs.copy(src, dst)
     fs.move(src, dst)
-    fs.glob(pattern) → [string] (supports ** for recursive matching)
+    fs.glob(pattern, options?) → [string] sorted absolute paths
+      Supports ** (recursive), {a,b} alternatives and [abc]/[!abc] classes.
       Use fs.glob to find files instead of manually recursing with
-      fs.readDir. Example: fs.glob("**/*.jpg") finds all JPGs recursively.
+      fs.readDir. Example: fs.glob("**/*.{jpg,png}") finds all images.
+      options: {withSkipped: true} → {matches, skipped: [{path, reason}]}
+      listing directories that were denied or unreadable.
+    fs.promote(path) → string (only for thoughts with "workspace: per-run":
+      copies path from this run's workspace, fs.workspace, into the
+      persistent workspace when the run succeeds; returns the destination)
     net.fetch(url, options?) → {status, headers, body}
       options: {method, headers, body}
+    net.download(url, dest, options?) → {path, size, sha256, cached}
+      Saves a URL to a file (up to 10 GB; net.fetch bodies are limited to
+      50 MB). Downloads are cached across thoughts: a URL seen before is
+      revalidated, and content with a known {sha256} is copied without a
+      request. options: {headers, sha256}; a sha256 that doesn't match
+      the content throws. Use it for datasets, archives, and model files.
     env.get(name) → string (prompts user for approval)
+    secrets.get(name) → handle (prompts user for approval)
+      Reads a secret (API key, token) from the OS keychain. The handle
+      is a placeholder, not the value: put it in a net.fetch header,
+      alone or inside one ("Bearer " + handle), and net.fetch sends the
+      value over https. Prefer it to env.get for credentials.
     input.prompt(question, options?) → string
       Ask the user a free-form question and block until they answer.
       options: {default: string} — returned if the user submits empty.
@@ -105,6 +150,8 @@ input you need (stdin, arguments) is already in the user message.
     process.exit(code)
     process.sleep(ms) (pause execution, respects Ctrl+C)
     process.stdout.write(text) (write directly to stdout from JS)
+    process.stdin.on(event, fn) ("line" or "end"; only used by thoughts
+      with frontmatter stdin: stream — memory.js gets one call per line)
     require(path) → module.exports (CommonJS module loading)
     agent.resume(context) → signals back to you with a message
 
@@ -245,6 +292,10 @@ handle these dynamically — never hardcode values from when you wrote it.
   var apiKey = env.get("API_KEY");
   // User will be prompted for approval on first access
 
+**Secrets** (secrets.get, for credentials):
+  var token = secrets.get("GITHUB_TOKEN");
+  net.fetch(url, {headers: {Authorization: "Bearer " + token}});
+
 **Stdin** (piped data):
   // IMPORTANT: Stdin is NOT available in memory.js!
   // Stdin is captured before memory.js runs and only appears in the
@@ -260,11 +311,16 @@ When writing memory.js, think about what varies between runs:
 - If only arguments change → use process.args
 - If stdin is the main input → delegate to agent.resume()
 - If env vars are needed → use env.get() (approval required)
+- If credentials are needed → use secrets.get() in net.fetch headers
 - If reading user files → use fs.readFile()
 
 ### Writing memory.js
 
-Use fs.writeFile with the memory.js path shown above.
+Write the first version with fs.writeFile at the memory.js path shown
+above. To change an existing memory.js, read it, then call update_memory
+with a unified diff of just the lines that change. Small diffs are easy
+for the user to review and keep the edits they made elsewhere in the
+file; a diff that doesn't match the current file is rejected.
 
 memory.js has access to all bridges:
 - fs, net, env, sys, console, process
@@ -296,6 +352,8 @@ Common patterns:
     throw new Error("API error: " + resp.status);
   }
   var data = JSON.parse(resp.body);
+  // Never eval() or new Function() fetched text: after net.fetch they throw.
+  // Parse data with JSON.parse; require() refuses files written after a fetch too.
 
   // Error handling: JSON.parse throws on invalid input
   try {
@@ -304,6 +362,16 @@ Common patterns:
     // handle parse error
   }
 
+  // Bridge calls throw FsError, NetError, AccessDeniedError (outside the
+  // sandbox or refused), or LimitError (too large), each with a code:
+  // EACCES, EROFS, ENOENT, EFBIG, EINVAL, ECANCELED, or EIO. A denied
+  // fs call is both an AccessDeniedError and an FsError.
+  try {
+    var text = fs.readFile(path);
+  } catch (e) {
+    if (!(e instanceof FsError) || e.code !== "ENOENT") throw e;
+  }
+
   // Nested paths: mkdir before writing
   fs.mkdir("/path/to/workspace/subdir");
   fs.writeFile("/path/to/workspace/subdir/file.txt", content);
@@ -319,6 +387,12 @@ Common patterns:
   // Debugging: console.log writes to stderr, won't break output
   console.log("debug:", variable);
 
+- spawn_agent: Hand a self-contained subtask to a sub-agent that works in
+  its own conversation with run_script and answers with only its result.
+  Use it for work whose intermediate data would flood your context, such
+  as reading or summarizing many files or a long search, not for single
+  steps. The sub-agent sees only the task you give it.
+
 ## Rules
 
 1. ONLY use write_stdout to produce output. Any text you generate outside
@@ -363,6 +437,48 @@ At the END of execution, update your memories:
 Keep memories short and actionable. One topic per file.
 %s`
 
+const readOnlyPrompt = `
+
+## Analysis mode (read-only)
+
+This run is in READ-ONLY mode. Every filesystem write, append, delete,
+mkdir, copy, and move will fail — including the workspace, memories, and
+memory.js. Do NOT try to write files or update memory.js. Read what you
+need, analyze it, and report results with write_stdout only.`
+
+const perRunPrompt = `
+
+## Per-run workspace
+
+This thought gets a fresh workspace every run; the workspace path above is
+deleted when the run ends. To keep a result, call fs.promote(path) — it is
+copied to the same relative path under the persistent workspace, %s,
+once the run succeeds. The persistent workspace is readable directly. The
+run workspace path changes each run, so memory.js must use fs.workspace
+instead of a hard-coded path.`
+
+const mcpPrompt = `
+
+## MCP tools
+
+This script connects MCP servers, whose tools you can call directly; each
+call may ask the user for approval. memory.js can't call them: if a future
+run needs one, have memory.js call agent.resume() for that part.
+%s`
+
+// mcpServersPrompt lists the MCP servers and their tools, with each
+// server's own instructions.
+func mcpServersPrompt(servers []tools.MCPServerInfo) string {
+	var b strings.Builder
+	for _, s := range servers {
+		fmt.Fprintf(&b, "\n- %s: %s", s.Name, strings.Join(s.Tools, ", "))
+		if s.Instructions != "" {
+			fmt.Fprintf(&b, "\n  %s", strings.ReplaceAll(strings.TrimSpace(s.Instructions), "\n", "\n  "))
+		}
+	}
+	return fmt.Sprintf(mcpPrompt, b.String())
+}
+
 var (
 	debugStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
 	errorStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
@@ -382,10 +498,42 @@ type Agent struct {
 	memoryJSPath  string
 	cacheMode     string
 	resumeContext string
+	readOnly      bool
+	persistentWS  string // persistent workspace when workspaceDir is per-run; "" otherwise
+	lastText      string // most recent agent text, shown if the run fails
+	plan          string // plan approved with --explain; "" = none
+
+	transcript []provider.Message                  // the run's conversation, for proposeMemoryJS
+	confirm    func(question string) (bool, error) // nil = no memory.js proposals
+	journal    *journal.Journal                    // records a saved proposal for `thought undo`
+
+	costs    *costLimits      // nil = no cost preview or ceiling
+	budget   *budget          // nil = no max_total_tokens or max_cost
+	recorder *runlog.Recorder // records the transcript for `thought report` and `thought history`; nil = off
+	conv     int              // this agent's conversation in the recorder's history
+	recorded int              // messages of the current run already in the history
+	session  *Session         // saved after every turn for think --resume; nil = off
+
+	contextLimit int     // estimated request tokens that trigger compaction; 0 = DefaultContextLimit, < 0 = off
+	tokenScale   float64 // reported / estimated input tokens of the last call; 0 = not known yet
+
+	pruneAfter, pruneKeep, pruneMinChars int // see SetPruning; 0 = default
+
+	temperature, topP *float64 // see SetSampling; nil = provider default
+	stop              []string
+
+	failing  failStreak // the same call failing turn after turn; see branch
+	branches int        // times the conversation was rolled back for it
+
+	sub        bool // a spawn_agent sub-agent; see spawn
+	tokenLimit int  // tokens a sub-agent may use; 0 = no limit of its own
+	tokensUsed int
 }
 
-func New(p provider.Provider, r *tools.Registry, model string, maxTokens, maxIterations int, scriptName, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, cacheMode, resumeContext string) *Agent {
-	return &Agent{
+// New creates an agent using r's tools, and adds spawn_agent to r so the
+// agent can hand subtasks to sub-agents.
+func New(p provider.Provider, r *tools.Registry, model string, maxTokens, maxIterations int, scriptName, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, cacheMode, resumeContext string, readOnly bool) *Agent {
+	a := &Agent{
 		provider:      p,
 		registry:      r,
 		model:         model,
@@ -398,7 +546,42 @@ func New(p provider.Provider, r *tools.Registry, model string, maxTokens, maxIte
 		memoryJSPath:  memoryJSPath,
 		cacheMode:     cacheMode,
 		resumeContext: resumeContext,
+		readOnly:      readOnly,
+	}
+	r.SetSpawner(a.spawn)
+	return a
+}
+
+// SetPerRunWorkspace tells the agent its workspace is discarded after the
+// run and that results must be promoted into persistentDir.
+func (a *Agent) SetPerRunWorkspace(persistentDir string) {
+	a.persistentWS = persistentDir
+}
+
+// SetSampling sets the temperature, top_p, and stop sequences sent with
+// every request. nil leaves each to the provider.
+func (a *Agent) SetSampling(temperature, topP *float64, stop []string) {
+	a.temperature = temperature
+	a.topP = topP
+	a.stop = stop
+}
+
+// SetRecorder makes the agent record its transcript in r as it goes.
+func (a *Agent) SetRecorder(r *runlog.Recorder) {
+	a.recorder = r
+	a.conv = r.Conversation()
+}
+
+// record saves the conversation so far: all of it to last-run/ and the
+// session, and the messages not yet saved to the run's history, with resp
+// as the response the newest assistant message came from.
+func (a *Agent) record(system string, messages []provider.Message, resp *provider.ChatResponse) {
+	a.recorder.Transcript(system, messages)
+	a.saveSession(messages)
+	for _, m := range messages[a.recorded:] {
+		a.recorder.Message(a.conv, system, m, resp)
 	}
+	a.recorded = len(messages)
 }
 
 // loadMemories reads all files from the memories directory and returns
@@ -428,26 +611,98 @@ func (a *Agent) loadMemories() string {
 	return b.String()
 }
 
+// Run executes the agent loop. If it ends abnormally, a summary of the
+// files the run wrote and its last known state is printed so the user can
+// pick up manually.
 func (a *Agent) Run(ctx context.Context, prompt string) error {
+	err := a.run(ctx, prompt)
+	a.endSession(err)
+	if err != nil {
+		a.printPartial(err)
+		return err
+	}
+	a.proposeMemoryJS(ctx)
+	return nil
+}
+
+// printPartial summarizes what a failed run left behind.
+func (a *Agent) printPartial(err error) {
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	fmt.Fprintf(os.Stderr, "\n  %s %s\n", errorStyle.Render(i18n.T("agent.run_stopped")), err.Error())
+
+	if writes := a.registry.Writes(); len(writes) > 0 {
+		fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(i18n.T("agent.files_written")))
+		for _, path := range writes {
+			fmt.Fprintf(os.Stderr, "    %s\n", path)
+		}
+		for _, path := range writes {
+			if path == a.memoryJSPath {
+				fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(i18n.T("agent.memoryjs_updated")))
+				break
+			}
+		}
+	}
+	if a.resumeContext != "" {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", labelStyle.Render(i18n.T("agent.resumed_with")), truncate(a.resumeContext, 300))
+	}
+	if a.lastText != "" {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", labelStyle.Render(i18n.T("agent.last_note")), truncate(a.lastText, 500))
+	}
+	if a.session != nil && len(a.session.Messages) > 0 {
+		fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(i18n.T("agent.resume_hint", a.session.Script)))
+	}
+}
+
+// Kinds of resume context, which config.json "routes" map to models.
+const (
+	KindFirstRun    = "first_run"    // no memory.js yet
+	KindMemoryError = "memory_error" // memory.js threw
+	KindResume      = "resume"       // memory.js called agent.resume(), or couldn't be read
+)
+
+// ResumeKind classifies why the agent is being called.
+func ResumeKind(resumeContext string) string {
+	switch {
+	case resumeContext == "no memory.js exists, first run":
+		return KindFirstRun
+	case strings.HasPrefix(resumeContext, "memory.js error:"):
+		return KindMemoryError
+	default:
+		return KindResume
+	}
+}
+
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= max {
+		return s
+	}
+	return s[:max-3] + "..."
+}
+
+// userPrompt adds the resume context (why the agent is running) and any
+// approved plan to the thought's prompt.
+func (a *Agent) userPrompt(prompt string) string {
 	// Include resume context if memory.js failed or doesn't exist
 	fullPrompt := prompt
 	if a.resumeContext != "" {
 		fullPrompt += "\n\n## Resume Context\n\n"
 
-		if a.resumeContext == "no memory.js exists, first run" {
+		switch ResumeKind(a.resumeContext) {
+		case KindFirstRun:
 			// First run - no memory.js yet
 			fullPrompt += "This is the first run — no memory.js exists yet.\n\n"
 			fullPrompt += "Read the thought above and accomplish the task. Then write memory.js "
 			fullPrompt += "so future runs can handle this without calling you. For simple tasks, "
 			fullPrompt += "write a complete solution. For complex tasks, write what you can and "
 			fullPrompt += "use agent.resume() for parts that need more work."
-		} else if strings.HasPrefix(a.resumeContext, "memory.js error:") {
+		case KindMemoryError:
 			// Runtime error in memory.js
 			fullPrompt += "memory.js threw an error:\n\n"
 			fullPrompt += strings.TrimPrefix(a.resumeContext, "memory.js error: ")
 			fullPrompt += "\n\nFix the bug in memory.js. Read the current memory.js, understand "
 			fullPrompt += "what went wrong, and write a corrected version."
-		} else {
+		default:
 			// Explicit agent.resume() call with context
 			fullPrompt += "memory.js called agent.resume() with this context:\n\n"
 			fullPrompt += a.resumeContext
@@ -456,38 +711,119 @@ func (a *Agent) Run(ctx context.Context, prompt string) error {
 			fullPrompt += "problem, then update memory.js to handle this case in the future."
 		}
 	}
+	if a.plan != "" {
+		fullPrompt += fmt.Sprintf(approvedPlanPrompt, a.plan)
+	}
+	return fullPrompt
+}
+
+// systemPrompt renders the system prompt for the next provider call;
+// memories are reloaded each time since the agent may have changed them.
+func (a *Agent) systemPrompt() string {
+	memories := ""
+	if a.cacheMode == "persist" && !a.sub {
+		memories = fmt.Sprintf(memoriesPrompt, a.memoriesDir, a.loadMemories())
+	}
+	system := fmt.Sprintf(systemPromptTemplate, a.workspaceDir, a.memoriesDir, a.memoryJSPath, memories)
+	if a.readOnly {
+		system += readOnlyPrompt
+	}
+	if a.persistentWS != "" {
+		system += fmt.Sprintf(perRunPrompt, a.persistentWS)
+	}
+	if a.sub {
+		system += subAgentPrompt
+	}
+	if servers := a.registry.MCPServers(); len(servers) > 0 {
+		system += mcpServersPrompt(servers)
+	}
+	return system
+}
 
+// Prompt returns the system prompt and user message the agent's first
+// provider call would send for prompt, for think --show-prompt.
+func (a *Agent) Prompt(prompt string) (system, user string) {
+	return a.systemPrompt(), a.userPrompt(prompt)
+}
+
+func (a *Agent) run(ctx context.Context, prompt string) error {
 	messages := []provider.Message{
-		provider.NewUserMessage(provider.NewTextBlock(fullPrompt)),
+		provider.NewUserMessage(provider.NewTextBlock(a.userPrompt(prompt))),
+	}
+	if a.session != nil && len(a.session.Messages) > 0 {
+		messages = resumeMessages(a.session)
 	}
+	a.recorded = 0
 
 	// Show agent starting (blank line for mode switch)
 	agentStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("213")) // Magenta for agent
 	nameStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
 	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
-	fmt.Fprintf(os.Stderr, "\n%s %s %s\n", agentStyle.Render("●"), nameStyle.Render(a.scriptName), labelStyle.Render("agent"))
+	kind := "agent"
+	if a.sub {
+		kind = "sub-agent"
+	}
+	fmt.Fprintf(os.Stderr, "\n%s %s %s\n", agentStyle.Render("●"), nameStyle.Render(a.scriptName), labelStyle.Render(kind))
+	defer func() {
+		if n := a.registry.Stats().SchemaFailures; n > 0 {
+			fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(fmt.Sprintf("%d tool call(s) rejected by schema validation", n)))
+		}
+	}()
 
 	for i := 0; i < a.maxIterations; i++ {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
-		stopSpinner := ui.Spinner("  Thinking...")
-		memories := ""
-		if a.cacheMode == "persist" {
-			memories = fmt.Sprintf(memoriesPrompt, a.memoriesDir, a.loadMemories())
+		if pruned, note := a.prune(messages); pruned != nil {
+			fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(note))
+			messages = pruned
+			a.transcript = messages
+		}
+		params := provider.ChatParams{
+			Model:         a.model,
+			System:        a.systemPrompt(),
+			Messages:      messages,
+			Tools:         a.registry.Definitions(),
+			MaxTokens:     a.maxTokens,
+			Temperature:   a.temperature,
+			TopP:          a.topP,
+			StopSequences: a.stop,
+		}
+		a.record(params.System, messages, nil)
+		// The history keeps every message; the request and session get the
+		// compacted conversation
+		if compacted, note := a.compact(params); compacted != nil {
+			fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(note))
+			messages = compacted
+			params.Messages = messages
+			a.transcript = messages
+			a.recorded = len(messages)
+			a.saveSession(messages)
+			a.failing = failStreak{} // its start may be gone
+		}
+		if err := a.budget.check(); err != nil {
+			return err
+		}
+		if err := a.checkTokens(); err != nil {
+			return err
 		}
-		resp, err := a.provider.Chat(ctx, provider.ChatParams{
-			Model:     a.model,
-			System:    fmt.Sprintf(systemPromptTemplate, a.workspaceDir, a.memoriesDir, a.memoryJSPath, memories),
-			Messages:  messages,
-			Tools:     a.registry.Definitions(),
-			MaxTokens: a.maxTokens,
-		})
-		stopSpinner()
+		if err := a.costs.check(i, params); err != nil {
+			return err
+		}
+		// Text and tool calls are printed as they stream in
+		render := newStreamRenderer()
+		resp, err := provider.ChatStream(ctx, a.provider, params, render.event)
+		render.finish()
 		if err != nil {
 			return fmt.Errorf("API call failed: %w", err)
 		}
+		if resp.Model != "" && resp.Model != a.model {
+			fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(i18n.T("agent.served_by", resp.Model)))
+		}
+		a.costs.add(params, resp)
+		a.calibrate(params, resp)
+		a.countTokens(params, resp)
 
 		// Process response blocks
 		var toolUses []provider.ContentBlock
@@ -495,32 +831,33 @@ func (a *Agent) Run(ctx context.Context, prompt string) error {
 			switch block.Type {
 			case "text":
 				if block.Text != "" {
-					fmt.Fprintln(os.Stderr, debugStyle.Render(block.Text))
+					a.lastText = block.Text
 				}
 			case "tool_use":
 				toolUses = append(toolUses, block)
 			}
 		}
 
+		// Add assistant message with all content blocks
+		messages = append(messages, provider.NewAssistantMessage(resp.Content...))
+		a.transcript = messages
+		a.record(params.System, messages, resp)
+
 		// If no tool calls, we're done
 		if len(toolUses) == 0 {
 			return nil
 		}
 
-		// Add assistant message with all content blocks
-		messages = append(messages, provider.NewAssistantMessage(resp.Content...))
-
-		// Execute each tool call and collect results
+		// Execute the tool calls (independent ones in parallel) and collect
+		// results in order
+		a.registry.BeginTurn()
+		calls := make([]tools.Call, len(toolUses))
+		for i, tu := range toolUses {
+			calls[i] = tools.Call{ID: tu.ToolUseID, Name: tu.ToolName, Input: tu.Input}
+		}
 		var resultBlocks []provider.ContentBlock
-		for _, tu := range toolUses {
-			displayName := tu.ToolName
-			if displayName == "run_script" {
-				displayName = "script"
-			}
-			fmt.Fprintf(os.Stderr, "  %s %s\n", toolStyle.Render("▸"), debugStyle.Render(displayName)) // Triangle for scripts
-			printToolInput(tu.ToolName, tu.Input)
-
-			result, err := a.registry.Execute(ctx, tu.ToolName, tu.Input)
+		for i, res := range a.registry.ExecuteAll(ctx, calls) {
+			tu, result, err := toolUses[i], res.Output, res.Err
 			if err != nil {
 				if ctx.Err() != nil || errors.Is(err, approval.ErrInterrupted) {
 					return err
@@ -532,8 +869,28 @@ func (a *Agent) Run(ctx context.Context, prompt string) error {
 			}
 		}
 
+		if used := i + 1; used == budgetWarnAt(a.maxIterations) && used < a.maxIterations && !a.sub {
+			fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(fmt.Sprintf("iteration budget %d/%d — asking agent to wrap up", used, a.maxIterations)))
+			resultBlocks = append(resultBlocks, provider.NewTextBlock(fmt.Sprintf(budgetWarning, used, a.maxIterations, a.maxIterations-used)))
+		}
+
 		// Send tool results back
 		messages = append(messages, provider.NewUserMessage(resultBlocks...))
+		a.transcript = messages
+		a.record(params.System, messages, nil)
+
+		// Stuck on the same failing call: go back to before the first
+		// attempt and ask for another approach. The history keeps the
+		// attempts
+		a.failing.noteFailures(len(messages)-2, toolUses, resultBlocks)
+		if branched, note := a.branch(messages); branched != nil {
+			fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(note))
+			messages = branched
+			a.transcript = messages
+			a.recorded = len(messages)
+			a.saveSession(messages)
+			continue
+		}
 
 		// If stop reason is end_turn (not tool_use), we're done
 		if resp.StopReason == "end_turn" {
@@ -541,7 +898,16 @@ func (a *Agent) Run(ctx context.Context, prompt string) error {
 		}
 	}
 
-	return fmt.Errorf("agent loop exceeded maximum iterations (%d)", a.maxIterations)
+	return fmt.Errorf("agent loop exceeded maximum iterations (%d); raise max_iterations in the frontmatter to allow more", a.maxIterations)
+}
+
+// budgetWarning is appended to the tool results once 80% of the iteration
+// budget is spent, so the agent wraps up instead of hitting the wall.
+const budgetWarning = `You have used %d of %d iterations; %d remain before this run is stopped. Wrap up now: finish the essential output, and write memory.js (even partial, using agent.resume() for unfinished parts) and any memories so the next run can continue where you left off.`
+
+// budgetWarnAt returns the iteration after which the budget warning is sent.
+func budgetWarnAt(maxIterations int) int {
+	return maxIterations * 4 / 5
 }
 
 var codeStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("242"))
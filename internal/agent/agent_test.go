@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/tools"
+)
+
+// errStubDown is returned by a stubProvider whose reply is nil.
+var errStubDown = errors.New("connection reset by peer")
+
+// stubProvider answers each request with reply, or fails with errStubDown
+// when that is nil, and keeps the requests.
+type stubProvider struct {
+	reply    func(turn int, params provider.ChatParams) *provider.ChatResponse
+	requests []provider.ChatParams
+}
+
+func (p *stubProvider) Chat(ctx context.Context, params provider.ChatParams) (*provider.ChatResponse, error) {
+	params.Messages = append([]provider.Message{}, params.Messages...)
+	p.requests = append(p.requests, params)
+	if resp := p.reply(len(p.requests)-1, params); resp != nil {
+		return resp, nil
+	}
+	return nil, errStubDown
+}
+
+// testAgent returns an agent for p with no tools but spawn_agent, in a
+// fresh thought directory. What the agent prints is discarded.
+func testAgent(t *testing.T, p provider.Provider, maxIterations int) *Agent {
+	t.Helper()
+	dir := t.TempDir()
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		devNull.Close()
+	})
+	thoughtDir := filepath.Join(dir, "thought")
+	if err := os.Mkdir(thoughtDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	r := tools.NewRegistry(tools.RegistryConfig{Tools: []string{}})
+	return New(p, r, "test-model", 1024, maxIterations, "test", thoughtDir,
+		filepath.Join(dir, "workspace"), filepath.Join(dir, "memories"), filepath.Join(thoughtDir, "memory.js"), "", "", false)
+}
+
+// toolTurn is a response calling the tool "note" with input, after text.
+func toolTurn(text, input string) *provider.ChatResponse {
+	return &provider.ChatResponse{
+		Content:    []provider.ContentBlock{provider.NewTextBlock(text), provider.NewToolUseBlock("call_"+input, "note", []byte(`{"n":"`+input+`"}`))},
+		StopReason: "tool_use",
+	}
+}
+
+// endTurn is a final response.
+func endTurn(text string) *provider.ChatResponse {
+	return &provider.ChatResponse{Content: []provider.ContentBlock{provider.NewTextBlock(text)}, StopReason: "end_turn"}
+}
+
+// unmatchedToolUses returns the IDs of tool_use blocks in messages that
+// aren't answered by a tool_result in the next message.
+func unmatchedToolUses(messages []provider.Message) []string {
+	var unmatched []string
+	for i, m := range messages {
+		for _, b := range m.Content {
+			if b.Type != "tool_use" {
+				continue
+			}
+			found := false
+			if i+1 < len(messages) {
+				for _, r := range messages[i+1].Content {
+					found = found || r.Type == "tool_result" && r.ToolUseIDRef == b.ToolUseID
+				}
+			}
+			if !found {
+				unmatched = append(unmatched, b.ToolUseID)
+			}
+		}
+	}
+	return unmatched
+}
+
+func TestBudgetWarnAt(t *testing.T) {
+	tests := []struct{ max, want int }{
+		{10, 8},
+		{25, 20},
+		{5, 4},
+		{2, 1},
+		{1, 0},
+	}
+	for _, tt := range tests {
+		if got := budgetWarnAt(tt.max); got != tt.want {
+			t.Errorf("budgetWarnAt(%d) = %d, want %d", tt.max, got, tt.want)
+		}
+	}
+}
+
+// warnings returns the indexes of the requests whose last message carries
+// the iteration budget warning, and how many warnings the last request
+// holds in all.
+func warnings(requests []provider.ChatParams) (at []int, total int) {
+	warned := func(m provider.Message) int {
+		n := 0
+		for _, b := range m.Content {
+			if b.Type == "text" && strings.HasPrefix(b.Text, "You have used ") && strings.Contains(b.Text, "Wrap up now") {
+				n++
+			}
+		}
+		return n
+	}
+	for i, req := range requests {
+		if warned(req.Messages[len(req.Messages)-1]) > 0 {
+			at = append(at, i)
+		}
+	}
+	for _, m := range requests[len(requests)-1].Messages {
+		total += warned(m)
+	}
+	return at, total
+}
+
+func TestBudgetWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      int
+		endAfter int // the turn the agent finishes on; -1 = never
+		sub      bool
+		want     []int // requests that carry the warning
+	}{
+		{"runs out", 10, -1, false, []int{8}},
+		{"finishes after the warning", 10, 9, false, []int{8}},
+		{"finishes before it", 10, 5, false, nil},
+		{"short budget", 2, -1, false, []int{1}},
+		{"single iteration", 1, -1, false, nil},
+		{"sub-agent", 10, -1, true, nil},
+	}
+	for _, tt := range tests {
+		p := &stubProvider{reply: func(turn int, _ provider.ChatParams) *provider.ChatResponse {
+			if turn == tt.endAfter {
+				return endTurn("done")
+			}
+			return toolTurn("working", fmt.Sprint(turn))
+		}}
+		a := testAgent(t, p, tt.max)
+		a.sub = tt.sub
+		err := a.Run(context.Background(), "count")
+		if tt.endAfter < 0 && (err == nil || !strings.Contains(err.Error(), "exceeded maximum iterations")) {
+			t.Errorf("%s: err = %v, want the iteration limit", tt.name, err)
+		}
+		if tt.endAfter >= 0 && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		at, total := warnings(p.requests)
+		if fmt.Sprint(at) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: warned in requests %v, want %v", tt.name, at, tt.want)
+		}
+		// Sent once, and it stays in the conversation
+		if want := min(len(tt.want), 1); total != want {
+			t.Errorf("%s: the last request holds %d warnings, want %d", tt.name, total, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/tools"
+)
+
+// When the model repeats the same failing tool call turn after turn, the
+// loop rolls the conversation back to before the first of those turns and
+// tells the model to try something else, instead of letting it burn the
+// iteration budget. Each agent branches at most maxBranchRetries times.
+const (
+	branchAfterFailures = 3 // consecutive turns failing the same call
+	maxBranchRetries    = 2
+	branchErrorChars    = 500 // of the failure, quoted in the instruction
+)
+
+// branchNote is added to the last message kept when the conversation is
+// rolled back.
+const branchNote = `[Your last %d attempts called %s with the same input, and it failed every time with:
+%s
+Those attempts were removed from the conversation. Don't repeat that call: work out why it fails and try a different approach.]`
+
+// failStreak tracks the failing call the latest turns have in common.
+type failStreak struct {
+	key   string // tool name and compacted input
+	turns int    // consecutive turns it failed in
+	start int    // length of the conversation before the first of them
+	tool  string
+	err   string // its latest failure
+}
+
+// noteFailures updates the streak with a finished turn: its calls and
+// their results. start is the conversation's length before the turn's
+// assistant message.
+func (f *failStreak) noteFailures(start int, calls []provider.ContentBlock, results []provider.ContentBlock) {
+	failed := map[string]string{} // call key → failure
+	var first, firstTool string
+	for i, c := range calls {
+		if i >= len(results) || !results[i].IsError {
+			continue
+		}
+		key := tools.CallKey(c.ToolName, c.Input)
+		if _, ok := failed[key]; !ok && first == "" {
+			first, firstTool = key, c.ToolName
+		}
+		failed[key] = results[i].Content
+	}
+	if msg, ok := failed[f.key]; ok && f.turns > 0 {
+		f.turns++
+		f.err = msg
+		return
+	}
+	*f = failStreak{}
+	if first != "" {
+		*f = failStreak{key: first, turns: 1, start: start, tool: firstTool, err: failed[first]}
+	}
+}
+
+// branch returns the conversation rolled back to before the streak, with
+// an instruction to try another approach added to its last message, and a
+// line saying what was done; or nil when the streak isn't long enough or
+// the agent is out of branch retries.
+func (a *Agent) branch(messages []provider.Message) ([]provider.Message, string) {
+	f := a.failing
+	if f.turns < branchAfterFailures || a.branches >= maxBranchRetries || f.start < 1 || f.start > len(messages) {
+		return nil, ""
+	}
+	a.branches++
+	a.failing = failStreak{}
+
+	errText := strings.TrimSpace(f.err)
+	if len(errText) > branchErrorChars {
+		errText = strings.ToValidUTF8(errText[:branchErrorChars-1], "") + "…"
+	}
+	kept := append([]provider.Message{}, messages[:f.start]...)
+	last := kept[len(kept)-1]
+	last.Content = append(append([]provider.ContentBlock{}, last.Content...),
+		provider.NewTextBlock(fmt.Sprintf(branchNote, f.turns, f.tool, errText)))
+	kept[len(kept)-1] = last
+	return kept, i18n.T("agent.branched", f.tool, f.turns, a.branches, maxBranchRetries)
+}
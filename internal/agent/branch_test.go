@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// turn is one round of tool calls: "tool input" → error, or "" for
+// success.
+type turn []struct{ call, err string }
+
+// appendTurn adds t's assistant message and tool results to messages, and
+// notes its failures in f, as the agent loop does.
+func appendTurn(f *failStreak, messages []provider.Message, t turn) []provider.Message {
+	var calls, results []provider.ContentBlock
+	for i, c := range t {
+		id := fmt.Sprintf("t%d_%d", len(messages), i)
+		name, input, _ := strings.Cut(c.call, " ")
+		calls = append(calls, provider.NewToolUseBlock(id, name, json.RawMessage(input)))
+		content := "ok"
+		if c.err != "" {
+			content = c.err
+		}
+		results = append(results, provider.NewToolResultBlock(id, content, c.err != ""))
+	}
+	messages = append(messages, provider.NewAssistantMessage(calls...), provider.NewUserMessage(results...))
+	f.noteFailures(len(messages)-2, calls, results)
+	return messages
+}
+
+func TestNoteFailures(t *testing.T) {
+	failRead := turn{{`read {"path":"a"}`, "no such file"}}
+	tests := []struct {
+		name  string
+		turns []turn
+		want  failStreak
+	}{
+		{"no failures", []turn{{{`read {}`, ""}}}, failStreak{}},
+		{"one failure", []turn{failRead}, failStreak{turns: 1, start: 1, tool: "read", err: "no such file"}},
+		{"same call", []turn{failRead, failRead, failRead}, failStreak{turns: 3, start: 1, tool: "read", err: "no such file"}},
+		{
+			"same call formatted differently, latest error",
+			[]turn{failRead, {{`read { "path": "a" }`, "permission denied"}}},
+			failStreak{turns: 2, start: 1, tool: "read", err: "permission denied"},
+		},
+		{
+			"alongside other calls",
+			[]turn{failRead, {{`ls {}`, ""}, {`write {}`, "disk full"}, {`read {"path":"a"}`, "no such file"}}},
+			failStreak{turns: 2, start: 1, tool: "read", err: "no such file"},
+		},
+		{"success resets", []turn{failRead, failRead, {{`read {"path":"a"}`, ""}}}, failStreak{}},
+		{"turn without the call resets", []turn{failRead, failRead, {{`ls {}`, ""}}}, failStreak{}},
+		{
+			"another failing call starts over",
+			[]turn{failRead, failRead, {{`read {"path":"b"}`, "no such file"}}},
+			failStreak{turns: 1, start: 5, tool: "read", err: "no such file"},
+		},
+		{
+			"first failure of a turn starts a streak",
+			[]turn{{{`ls {}`, ""}, {`write {}`, "disk full"}, {`read {}`, "bad input"}}},
+			failStreak{turns: 1, start: 1, tool: "write", err: "disk full"},
+		},
+	}
+	for _, tt := range tests {
+		var f failStreak
+		messages := []provider.Message{provider.NewUserMessage(provider.NewTextBlock("go"))}
+		for _, turn := range tt.turns {
+			messages = appendTurn(&f, messages, turn)
+		}
+		f.key = ""
+		if f != tt.want {
+			t.Errorf("%s: streak = %+v, want %+v", tt.name, f, tt.want)
+		}
+	}
+}
+
+func TestBranch(t *testing.T) {
+	failRead := turn{{`read {"path":"a"}`, "no such file"}}
+	a := &Agent{}
+	messages := []provider.Message{provider.NewUserMessage(provider.NewTextBlock("go"))}
+	messages = appendTurn(&a.failing, messages, turn{{`ls {}`, ""}})
+
+	for i := 1; i < branchAfterFailures; i++ {
+		messages = appendTurn(&a.failing, messages, failRead)
+		if branched, _ := a.branch(messages); branched != nil {
+			t.Fatalf("branched after %d failures", i)
+		}
+	}
+	messages = appendTurn(&a.failing, messages, failRead)
+	before := fmt.Sprint(messages)
+
+	branched, note := a.branch(messages)
+	if len(branched) != 3 {
+		t.Fatalf("kept %d messages, want the 3 before the first failure", len(branched))
+	}
+	last := branched[2].Content
+	if len(last) != 2 || last[0].Type != "tool_result" || !strings.Contains(last[1].Text, "Your last 3 attempts called read") || !strings.Contains(last[1].Text, "no such file") {
+		t.Errorf("last message = %+v, want ls's result and the instruction", last)
+	}
+	if fmt.Sprint(messages) != before {
+		t.Error("branch changed the conversation it was given")
+	}
+	if !strings.Contains(note, "read") || a.branches != 1 || a.failing != (failStreak{}) {
+		t.Errorf("note = %q, branches = %d, failing = %+v", note, a.branches, a.failing)
+	}
+
+	// Every branch counts against maxBranchRetries, then the agent is
+	// left to fail
+	messages = branched
+	for b := 2; b <= maxBranchRetries+1; b++ {
+		for range branchAfterFailures {
+			messages = appendTurn(&a.failing, messages, failRead)
+		}
+		branched, _ = a.branch(messages)
+		if b <= maxBranchRetries && len(branched) != 3 {
+			t.Errorf("branch %d kept %d messages, want 3", b, len(branched))
+		}
+		if b > maxBranchRetries && branched != nil {
+			t.Errorf("branched %d times, more than maxBranchRetries", b)
+		}
+		if branched != nil {
+			messages = branched
+		}
+	}
+}
+
+func TestBranchStartGone(t *testing.T) {
+	// The conversation was compacted under the streak
+	a := &Agent{failing: failStreak{turns: branchAfterFailures, start: 9, tool: "read"}}
+	messages := []provider.Message{provider.NewUserMessage(provider.NewTextBlock("summary"))}
+	if branched, _ := a.branch(messages); branched != nil || a.branches != 0 {
+		t.Errorf("branched to before a start the conversation no longer has")
+	}
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thinkingscript/cli/internal/cost"
+)
+
+// ErrBudgetExceeded is wrapped by the error a run stops with once it has
+// used up max_total_tokens or max_cost.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Spent reports what the run has used so far, across all its agents:
+// tokens, and dollars if every model used could be priced.
+type Spent func() (tokens int, dollars float64, priced bool)
+
+// budget is a hard limit on a run's usage. Unlike costLimits it never
+// asks: a runaway thought stops.
+type budget struct {
+	maxTokens int     // 0 = no limit
+	maxCost   float64 // dollars; 0 = no limit
+	spent     Spent
+}
+
+// SetBudget makes the agent stop before its next provider call once spent
+// reports at least maxTokens tokens or maxCost dollars; 0 disables either.
+// spent covers the whole run, so a --map run's later agents don't start
+// once earlier ones used the budget.
+func (a *Agent) SetBudget(maxTokens int, maxCost float64, spent Spent) {
+	if maxTokens <= 0 && maxCost <= 0 {
+		return
+	}
+	a.budget = &budget{maxTokens: maxTokens, maxCost: maxCost, spent: spent}
+}
+
+// check returns an error once the budget is used up.
+func (b *budget) check() error {
+	if b == nil {
+		return nil
+	}
+	tokens, dollars, priced := b.spent()
+	if b.maxTokens > 0 && tokens >= b.maxTokens {
+		return fmt.Errorf("%w: this run used %d tokens (max_total_tokens is %d); raise max_total_tokens to allow more", ErrBudgetExceeded, tokens, b.maxTokens)
+	}
+	if b.maxCost > 0 && priced && dollars >= b.maxCost {
+		return fmt.Errorf("%w: this run spent about %s (max_cost is %s); raise max_cost to allow more", ErrBudgetExceeded, cost.Format(dollars), cost.Format(b.maxCost))
+	}
+	return nil
+}
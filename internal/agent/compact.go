@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// DefaultContextLimit is the estimated size of a request, in tokens, above
+// which old turns are compacted; it leaves room under the smallest context
+// windows in common use (128k) for the estimate to be off.
+const DefaultContextLimit = 100_000
+
+// Compaction keeps the prompt message and the latest turns as they are,
+// and shrinks everything in between until the request is back under three
+// quarters of the limit.
+const (
+	keepRecentMessages = 6    // the last three exchanges
+	keptResultChars    = 600  // kept from the start of a shortened tool result
+	maxSummaryLines    = 200  // of the note describing dropped turns
+	summaryLineChars   = 160  // per line of that note
+	compactTargetRatio = 0.75 // of the limit
+)
+
+// compactedNote starts the note added to the prompt message when earlier
+// turns are dropped; the note lists what those turns did.
+const compactedNote = "[Earlier turns of this run were removed to fit the context window. What they did, oldest first:"
+
+// SetContextLimit sets the estimated request size, in tokens, at which old
+// tool results are shortened and then the oldest turns dropped. 0 means
+// DefaultContextLimit; a negative limit turns compaction off.
+func (a *Agent) SetContextLimit(tokens int) {
+	a.contextLimit = tokens
+}
+
+// estimateTokens estimates the input tokens of params, corrected by how far
+// the last estimate was from what the API reported.
+func (a *Agent) estimateTokens(params provider.ChatParams) int {
+	n := cost.RequestTokens(params)
+	if a.tokenScale > 0 {
+		n = int(float64(n) * a.tokenScale)
+	}
+	return n
+}
+
+// calibrate compares the estimate for params with the input tokens the API
+// reported for it, so later estimates are closer.
+func (a *Agent) calibrate(params provider.ChatParams, resp *provider.ChatResponse) {
+	if resp == nil || resp.Usage.InputTokens == 0 {
+		return
+	}
+	if est := cost.RequestTokens(params); est > 0 {
+		a.tokenScale = float64(resp.Usage.InputTokens) / float64(est)
+	}
+}
+
+// compact returns params' messages shrunk to fit the context limit, and a
+// line saying what was done, or nil when they already fit. Old tool
+// results are shortened first, oldest first; if that isn't enough, the
+// oldest exchanges are dropped and summarized in a note appended to the
+// prompt message. The prompt itself
+// and the last keepRecentMessages messages are never changed.
+func (a *Agent) compact(params provider.ChatParams) ([]provider.Message, string) {
+	limit := a.contextLimit
+	if limit == 0 {
+		limit = DefaultContextLimit
+	}
+	before := a.estimateTokens(params)
+	if limit < 0 || before <= limit || len(params.Messages) <= keepRecentMessages+1 {
+		return nil, ""
+	}
+	target := int(float64(limit) * compactTargetRatio)
+	messages := append([]provider.Message{}, params.Messages...)
+	size := func() int {
+		p := params
+		p.Messages = messages
+		return a.estimateTokens(p)
+	}
+
+	// Shorten old tool results
+	shortened := 0
+	for i := 1; i < len(messages)-keepRecentMessages && size() > target; i++ {
+		var blocks []provider.ContentBlock
+		for j, b := range messages[i].Content {
+			if b.Type != "tool_result" || len(b.Content) <= 2*keptResultChars {
+				continue
+			}
+			if blocks == nil {
+				blocks = append([]provider.ContentBlock{}, messages[i].Content...)
+			}
+			b.Content = fmt.Sprintf("%s\n[… %d more characters of this result were removed to fit the context window; rerun the step if you need them]",
+				strings.ToValidUTF8(b.Content[:keptResultChars], ""), len(b.Content)-keptResultChars)
+			blocks[j] = b
+			shortened++
+		}
+		if blocks != nil {
+			messages[i].Content = blocks
+		}
+	}
+
+	// Drop the oldest exchanges: an assistant message and the tool results
+	// answering it, so every tool_use keeps its tool_result
+	var dropped []provider.Message
+	for len(messages)-2 > keepRecentMessages && messages[1].Role == "assistant" && size() > target {
+		dropped = append(dropped, messages[1], messages[2])
+		messages = append(messages[:1], messages[3:]...)
+	}
+	if len(dropped) > 0 {
+		messages[0] = withSummary(messages[0], dropped)
+	}
+
+	if shortened == 0 && len(dropped) == 0 {
+		return nil, ""
+	}
+	return messages, i18n.T("agent.compacted", shortened, len(dropped), before/1000, size()/1000)
+}
+
+// withSummary returns the prompt message with the note about dropped turns
+// extended by a line for each of dropped's assistant messages.
+func withSummary(prompt provider.Message, dropped []provider.Message) provider.Message {
+	var lines []string
+	blocks := append([]provider.ContentBlock{}, prompt.Content...)
+	if last := blocks[len(blocks)-1]; last.Type == "text" && strings.HasPrefix(last.Text, compactedNote) {
+		note := strings.TrimSuffix(strings.TrimPrefix(last.Text, compactedNote), "]")
+		lines = strings.Split(strings.Trim(note, "\n"), "\n")
+		blocks = blocks[:len(blocks)-1]
+	}
+
+	failed := map[string]bool{} // tool_use ID → its result was an error
+	for _, m := range dropped {
+		for _, b := range m.Content {
+			if b.Type == "tool_result" && b.IsError {
+				failed[b.ToolUseIDRef] = true
+			}
+		}
+	}
+	for _, m := range dropped {
+		if m.Role != "assistant" {
+			continue
+		}
+		for _, b := range m.Content {
+			switch b.Type {
+			case "text":
+				if text := strings.Join(strings.Fields(b.Text), " "); text != "" {
+					lines = append(lines, "- said: "+text)
+				}
+			case "tool_use":
+				line := "- " + b.ToolName + ": " + strings.Join(strings.Fields(toolSummary(b.Input)), " ")
+				if failed[b.ToolUseID] {
+					line += " (failed)"
+				}
+				lines = append(lines, line)
+			}
+		}
+	}
+	for i, l := range lines {
+		if len(l) > summaryLineChars {
+			lines[i] = strings.ToValidUTF8(l[:summaryLineChars-1], "") + "…"
+		}
+	}
+	if len(lines) > maxSummaryLines {
+		lines = append([]string{"- …"}, lines[len(lines)-maxSummaryLines+1:]...)
+	}
+
+	note := compactedNote + "\n" + strings.Join(lines, "\n") + "]"
+	prompt.Content = append(blocks, provider.NewTextBlock(note))
+	return prompt
+}
+
+// toolSummary describes a tool call by its reason, or the first line of
+// its code or content.
+func toolSummary(input json.RawMessage) string {
+	var args struct {
+		Reason  string `json:"reason"`
+		Code    string `json:"code"`
+		Content string `json:"content"`
+	}
+	json.Unmarshal(input, &args)
+	if args.Reason != "" {
+		return args.Reason
+	}
+	for _, s := range []string{args.Code, args.Content} {
+		for _, line := range strings.Split(s, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "//") {
+				return line
+			}
+		}
+	}
+	return string(input)
+}
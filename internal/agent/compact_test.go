@@ -0,0 +1,190 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// compactConversation is a prompt and the given number of exchanges, each
+// a note and a read call whose result is resultChars long. Odd calls fail.
+func compactConversation(exchanges, resultChars int) []provider.Message {
+	messages := []provider.Message{provider.NewUserMessage(provider.NewTextBlock("summarize the logs"))}
+	for i := range exchanges {
+		id := fmt.Sprintf("t%d", i)
+		messages = append(messages,
+			provider.NewAssistantMessage(provider.NewTextBlock(fmt.Sprintf("Reading log %d.", i)),
+				provider.NewToolUseBlock(id, "read", []byte(fmt.Sprintf(`{"reason": "read log %d"}`, i)))),
+			provider.NewUserMessage(provider.NewToolResultBlock(id, strings.Repeat("x", resultChars), i%2 == 1)))
+	}
+	return messages
+}
+
+func TestCompactThreshold(t *testing.T) {
+	params := provider.ChatParams{Messages: compactConversation(8, 4000)}
+	size := cost.RequestTokens(params)
+	tests := []struct {
+		name      string
+		limit     int
+		compacted bool
+	}{
+		{"under the limit", size + 1, false},
+		{"at the limit", size, false},
+		{"over the limit", size - 1, true},
+		{"off", -1, false},
+	}
+	for _, tt := range tests {
+		a := &Agent{}
+		a.SetContextLimit(tt.limit)
+		if got, _ := a.compact(params); (got != nil) != tt.compacted {
+			t.Errorf("%s: compacted = %v, want %v", tt.name, got != nil, tt.compacted)
+		}
+	}
+
+	// Too few messages to compact, however large
+	a := &Agent{}
+	a.SetContextLimit(10)
+	if got, _ := a.compact(provider.ChatParams{Messages: compactConversation(3, 4000)}); got != nil {
+		t.Error("compacted the latest exchanges")
+	}
+
+	// Calibrated estimates count: the API said the request is twice the
+	// estimate
+	a = &Agent{}
+	a.SetContextLimit(size + size/2)
+	a.calibrate(params, &provider.ChatResponse{Usage: provider.Usage{InputTokens: 2 * size}})
+	if got, _ := a.compact(params); got == nil {
+		t.Error("a request over the limit by the calibrated estimate wasn't compacted")
+	}
+}
+
+func TestCompactShortensResults(t *testing.T) {
+	messages := compactConversation(8, 4000)
+	params := provider.ChatParams{Messages: messages}
+	size := cost.RequestTokens(params)
+	a := &Agent{}
+	// Just over: shortening old results is enough
+	a.SetContextLimit(size - 1)
+
+	got, _ := a.compact(params)
+	if got == nil {
+		t.Fatal("not compacted")
+	}
+	if len(got) != len(messages) {
+		t.Fatalf("%d messages, want all %d", len(got), len(messages))
+	}
+	first := got[2].Content[0].Content
+	if !strings.HasPrefix(first, strings.Repeat("x", keptResultChars)+"\n[… 3400 more characters") {
+		t.Errorf("oldest result = %.80q…", first)
+	}
+	if n, target := cost.RequestTokens(provider.ChatParams{Messages: got}), (size-1)*3/4; n > target {
+		t.Errorf("compacted to %d tokens, want at most %d", n, target)
+	}
+	for i := len(got) - keepRecentMessages; i < len(got); i++ {
+		if fmt.Sprint(got[i]) != fmt.Sprint(messages[i]) {
+			t.Errorf("recent message %d changed", i)
+		}
+	}
+	if messages[2].Content[0].Content != strings.Repeat("x", 4000) {
+		t.Error("compact changed the conversation it was given")
+	}
+}
+
+func TestCompactSummary(t *testing.T) {
+	messages := compactConversation(8, 4000)
+	a := &Agent{}
+	a.SetContextLimit(2000)
+
+	got, _ := a.compact(provider.ChatParams{Messages: messages})
+	if len(got) != 1+keepRecentMessages {
+		t.Fatalf("%d messages, want the prompt and the latest %d", len(got), keepRecentMessages)
+	}
+	if unmatched := unmatchedToolUses(got); len(unmatched) > 0 {
+		t.Errorf("tool calls without results: %v", unmatched)
+	}
+	for i := 1; i < len(got); i++ {
+		if fmt.Sprint(got[i]) != fmt.Sprint(messages[len(messages)-keepRecentMessages+i-1]) {
+			t.Errorf("message %d isn't the recent message it should be", i)
+		}
+	}
+
+	prompt := got[0].Content
+	want := compactedNote + `
+- said: Reading log 0.
+- read: read log 0
+- said: Reading log 1.
+- read: read log 1 (failed)
+- said: Reading log 2.
+- read: read log 2
+- said: Reading log 3.
+- read: read log 3 (failed)
+- said: Reading log 4.
+- read: read log 4]`
+	if len(prompt) != 2 || prompt[0].Text != "summarize the logs" || prompt[1].Text != want {
+		t.Errorf("prompt message = %+v\nwant the prompt, then\n%s", prompt, want)
+	}
+
+	// Compacting again extends the note rather than adding another
+	more := append(got, compactConversation(3, 4000)[1:]...)
+	for i := range more[1+keepRecentMessages:] {
+		for j := range more[1+keepRecentMessages+i].Content {
+			more[1+keepRecentMessages+i].Content[j].ToolUseID += "b"
+			more[1+keepRecentMessages+i].Content[j].ToolUseIDRef += "b"
+		}
+	}
+	again, _ := a.compact(provider.ChatParams{Messages: more})
+	prompt = again[0].Content
+	if len(prompt) != 2 || !strings.HasPrefix(prompt[1].Text, want[:len(want)-1]+"\n- said: Reading log 5.") {
+		t.Errorf("prompt message after compacting again = %+v", prompt)
+	}
+}
+
+// The agent loop compacts each request that grows past the limit, and
+// the provider sees the summary in place of the oldest turns.
+func TestCompactInRun(t *testing.T) {
+	p := &stubProvider{reply: func(turn int, params provider.ChatParams) *provider.ChatResponse {
+		if turn == 12 {
+			return endTurn("done")
+		}
+		return toolTurn(fmt.Sprintf("step %d %s", turn, strings.Repeat("y", 2000)), fmt.Sprint(turn))
+	}}
+	a := testAgent(t, p, 20)
+	system, user := a.Prompt("count")
+	base := cost.RequestTokens(provider.ChatParams{System: system, Tools: a.registry.Definitions(), Messages: []provider.Message{provider.NewUserMessage(provider.NewTextBlock(user))}})
+	limit := base + 3000
+	a.SetContextLimit(limit)
+
+	if err := a.Run(context.Background(), "count"); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.requests) != 13 {
+		t.Fatalf("%d requests, want 13", len(p.requests))
+	}
+	compacted := 0
+	for i, req := range p.requests {
+		if n := cost.RequestTokens(req); n > limit {
+			t.Errorf("request %d is %d tokens, over the limit of %d", i, n, limit)
+		}
+		if unmatched := unmatchedToolUses(req.Messages); len(unmatched) > 0 {
+			t.Errorf("request %d: tool calls without results: %v", i, unmatched)
+		}
+		if last := req.Messages[0].Content; strings.HasPrefix(last[len(last)-1].Text, compactedNote) {
+			compacted++
+		}
+	}
+	if compacted == 0 {
+		t.Fatal("no request was compacted")
+	}
+	last := p.requests[12].Messages
+	note := last[0].Content[len(last[0].Content)-1].Text
+	if !strings.Contains(note, "- said: step 0 yyy") || !strings.Contains(note, "- note: {\"n\":\"0\"} (failed)") {
+		t.Errorf("note = %.300q", note)
+	}
+	if !strings.Contains(last[len(last)-2].Content[0].Text, "step 11") {
+		t.Errorf("the latest turn isn't in the last request")
+	}
+}
@@ -0,0 +1,84 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// costLimits asks before a run that looks expensive starts, and again each
+// time its estimated spend crosses another multiple of the ceiling.
+type costLimits struct {
+	price   cost.Price
+	preview float64 // confirm before starting above this estimate; 0 = never
+	ceiling float64 // confirm to continue past this spend; 0 = never
+	confirm func(question string) (bool, error)
+
+	spent float64 // estimated dollars spent so far
+	next  float64 // spend at which to ask again
+}
+
+// SetCostLimits enables the cost preview and ceiling for models with a
+// known price. preview and ceiling are in dollars; 0 disables either.
+// confirm asks the user, and an error from it (no terminal) stops the run.
+func (a *Agent) SetCostLimits(price cost.Price, preview, ceiling float64, confirm func(question string) (bool, error)) {
+	if preview <= 0 && ceiling <= 0 {
+		return
+	}
+	a.costs = &costLimits{price: price, preview: preview, ceiling: ceiling, confirm: confirm, next: ceiling}
+}
+
+// check runs before each provider call: the preview before the run's
+// first, the ceiling before every later one (a sub-agent's first call
+// included, as it shares the parent's limits).
+func (c *costLimits) check(iteration int, params provider.ChatParams) error {
+	if c == nil {
+		return nil
+	}
+	if iteration == 0 && c.spent == 0 && c.preview > 0 {
+		est := c.price.Preview(params)
+		if est <= c.preview {
+			return nil
+		}
+		question := i18n.T("confirm.cost_preview", cost.Format(est), cost.Format(c.preview))
+		if err := c.ask(question); err != nil {
+			return fmt.Errorf("run not started: estimated cost %s is over cost_confirm %s: %w", cost.Format(est), cost.Format(c.preview), err)
+		}
+		return nil
+	}
+	if c.ceiling <= 0 || c.spent < c.next {
+		return nil
+	}
+	question := i18n.T("confirm.cost_ceiling", cost.Format(c.spent), cost.Format(c.ceiling))
+	if err := c.ask(question); err != nil {
+		return fmt.Errorf("stopped at cost ceiling after about %s: %w", cost.Format(c.spent), err)
+	}
+	for c.next <= c.spent {
+		c.next += c.ceiling
+	}
+	return nil
+}
+
+// add records the estimated cost of a finished call.
+func (c *costLimits) add(params provider.ChatParams, resp *provider.ChatResponse) {
+	if c == nil {
+		return
+	}
+	c.spent += c.price.Call(params, resp)
+}
+
+var errDeclined = errors.New("declined")
+
+func (c *costLimits) ask(question string) error {
+	ok, err := c.confirm(question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errDeclined
+	}
+	return nil
+}
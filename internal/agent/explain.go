@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// explainPrompt asks for a plan instead of a run. It is appended to the
+// run's own prompt so the plan covers the same stdin, arguments, and
+// resume context.
+const explainPrompt = `
+
+## Explain first
+
+Do NOT start the task yet and do not call any tools. The user wants to check how you read this thought before anything runs.
+
+Reply with a short plan: how you interpret the thought, then at most 8 numbered steps saying what you will read, write, fetch, and print. If anything is ambiguous, say which reading you chose and why. Plain text only, no code.`
+
+// approvedPlanPrompt seeds the run with the plan the user approved.
+const approvedPlanPrompt = `
+
+## Approved plan
+
+Before this run you explained your plan and the user approved it. Follow it:
+
+%s`
+
+// SetPlan seeds the run with a plan the user approved after Explain.
+func (a *Agent) SetPlan(plan string) {
+	a.plan = plan
+}
+
+// Explain asks the model how it would carry out prompt, without tools, and
+// returns its plan. Nothing is executed.
+func (a *Agent) Explain(ctx context.Context, prompt string) (string, error) {
+	stopSpinner := ui.Spinner("  " + i18n.T("spinner.planning"))
+	params := provider.ChatParams{
+		Model:       a.model,
+		System:      a.systemPrompt(),
+		Messages:    []provider.Message{provider.NewUserMessage(provider.NewTextBlock(a.userPrompt(prompt) + explainPrompt))},
+		MaxTokens:   a.maxTokens,
+		Temperature: a.temperature,
+		TopP:        a.topP,
+	}
+	resp, err := a.provider.Chat(ctx, params)
+	stopSpinner()
+	if err != nil {
+		return "", fmt.Errorf("API call failed: %w", err)
+	}
+	a.costs.add(params, resp)
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	plan := strings.TrimSpace(text.String())
+	if plan == "" {
+		return "", errors.New("the model returned no plan")
+	}
+	return plan, nil
+}
@@ -0,0 +1,131 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// proposalPrompt asks for memory.js after a run that succeeded without
+// writing one. It is appended to the run's own conversation.
+const proposalPrompt = `The run is finished and succeeded, but memory.js at %s was not updated, so the next run will need you again.
+
+Based on everything above, write a memory.js that does this task on its own next time: the same sandbox APIs you used in run_script, reading process.args instead of hard-coded inputs, process.stdout.write for output (console.log goes to stderr), and agent.resume("reason") for anything it can't handle. Do not call any tools.
+
+Reply with the complete file in a single javascript code block. If this task can't be done without you, reply with just NONE.`
+
+var codeBlockRE = regexp.MustCompile("(?s)```(?:javascript|js)?[ \t]*\n(.*?)```")
+
+// SetMemoryProposals makes a successful run that didn't write memory.js
+// ask the model once for one, show it, and save it if confirm approves.
+// The write is recorded in j (nil-safe) so `thought undo` can revert it.
+func (a *Agent) SetMemoryProposals(confirm func(question string) (bool, error), j *journal.Journal) {
+	a.confirm = confirm
+	a.journal = j
+}
+
+// proposeMemoryJS runs the post-run synthesis step. Failures only print a
+// note: the run itself already succeeded.
+func (a *Agent) proposeMemoryJS(ctx context.Context) {
+	if a.confirm == nil || a.readOnly || len(a.transcript) == 0 {
+		return
+	}
+	for _, path := range a.registry.Writes() {
+		if path == a.memoryJSPath {
+			return
+		}
+	}
+	if err := a.budget.check(); err != nil {
+		return // drafting would be one more call over the budget
+	}
+
+	labelStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	stopSpinner := ui.Spinner("  " + i18n.T("spinner.drafting"))
+	code, err := a.draftMemoryJS(ctx)
+	stopSpinner()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", errorStyle.Render("memory.js proposal failed:"), err.Error())
+		return
+	}
+	if code == "" {
+		fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render("no memory.js proposed; the next run uses the agent again"))
+		return
+	}
+	// The same checks update_memory makes
+	notes, err := a.registry.CheckMemoryJS(code)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", errorStyle.Render("memory.js proposal rejected: it"), err.Error())
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "\n  %s %s\n", toolStyle.Render("◆"), labelStyle.Render("proposed memory.js (memory.js was not updated this run)"))
+	for _, line := range strings.Split(code, "\n") {
+		fmt.Fprintf(os.Stderr, "    %s\n", codeStyle.Render(line))
+	}
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render(note))
+	}
+	ok, err := a.confirm(i18n.T("confirm.save_memoryjs"))
+	if err != nil || !ok {
+		fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render("not saved"))
+		return
+	}
+	a.journal.Write(a.memoryJSPath)
+	if err := os.WriteFile(a.memoryJSPath, []byte(code), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "  %s %s\n", errorStyle.Render("saving memory.js:"), err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  %s\n", labelStyle.Render("saved "+a.memoryJSPath))
+}
+
+// draftMemoryJS asks the model for memory.js given the run's transcript.
+// It returns "" when the model declines.
+func (a *Agent) draftMemoryJS(ctx context.Context) (string, error) {
+	messages := append([]provider.Message(nil), a.transcript...)
+	ask := provider.NewTextBlock(fmt.Sprintf(proposalPrompt, a.memoryJSPath))
+	// Keep roles alternating: the transcript may end with tool results
+	if last := &messages[len(messages)-1]; last.Role == "user" {
+		last.Content = append(append([]provider.ContentBlock(nil), last.Content...), ask)
+	} else {
+		messages = append(messages, provider.NewUserMessage(ask))
+	}
+
+	system := fmt.Sprintf(systemPromptTemplate, a.workspaceDir, a.memoriesDir, a.memoryJSPath, "")
+	resp, err := a.provider.Chat(ctx, provider.ChatParams{
+		Model:    a.model,
+		System:   system,
+		Messages: messages,
+		// The transcript holds tool calls, which providers only accept
+		// alongside tool definitions
+		Tools:     a.registry.Definitions(),
+		MaxTokens: a.maxTokens,
+	})
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return extractCode(text.String()), nil
+}
+
+// extractCode returns the first fenced code block in a reply, or "" if
+// there is none.
+func extractCode(reply string) string {
+	m := codeBlockRE.FindStringSubmatch(reply)
+	if m == nil {
+		return ""
+	}
+	return strings.TrimSpace(m[1]) + "\n"
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+func TestProposeMemoryJS(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		saved string // "" = not saved
+	}{
+		{"compiles", "```javascript\nprocess.stdout.write(process.args[0]);\n```", "process.stdout.write(process.args[0]);\n"},
+		{"doesn't compile", "```javascript\nprocess.stdout.write(\n```", ""},
+		{"declined", "NONE", ""},
+	}
+	for _, tt := range tests {
+		p := &stubProvider{reply: func(turn int, _ provider.ChatParams) *provider.ChatResponse {
+			if turn == 0 {
+				return endTurn("done")
+			}
+			return endTurn(tt.reply)
+		}}
+		a := testAgent(t, p, 10)
+		asked := false
+		a.SetMemoryProposals(func(string) (bool, error) { asked = true; return true, nil }, nil)
+		if err := a.Run(context.Background(), "echo"); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := os.ReadFile(a.memoryJSPath)
+		if tt.saved == "" && !os.IsNotExist(err) {
+			t.Errorf("%s: memory.js = %q, %v, want none", tt.name, data, err)
+		}
+		if tt.saved != "" && string(data) != tt.saved {
+			t.Errorf("%s: memory.js = %q, %v, want %q", tt.name, data, err, tt.saved)
+		}
+		if asked != (tt.saved != "") {
+			t.Errorf("%s: asked to save = %v", tt.name, asked)
+		}
+	}
+}
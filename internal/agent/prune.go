@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// Pruning replaces bulky tool results from older turns with a short
+// summary and the path of a workspace file holding the full result, long
+// before the request nears the context limit.
+const (
+	DefaultPruneAfter    = 10   // turns before pruning starts
+	DefaultPruneKeep     = 4    // latest turns never pruned
+	DefaultPruneMinChars = 4000 // results shorter than this stay as they are
+	prunedPreviewLines   = 5    // of the result, quoted in the summary
+	prunedLineChars      = 200  // per quoted line
+)
+
+// prunedNote starts a pruned tool result.
+const prunedNote = "[Tool result pruned from the conversation:"
+
+// prunedDir is where pruned results are saved, under the workspace.
+const prunedDir = ".pruned"
+
+// SetPruning sets when old tool results are pruned: once the conversation
+// has more than after turns, results of at least minChars characters
+// outside the latest keep turns are saved to the workspace and replaced by
+// a summary. 0 means the default for each; a negative after turns it
+// off.
+func (a *Agent) SetPruning(after, keep, minChars int) {
+	a.pruneAfter = after
+	a.pruneKeep = keep
+	a.pruneMinChars = minChars
+}
+
+// prune returns messages with old bulky tool results replaced by
+// summaries, and a line saying what was done, or nil when nothing was
+// pruned. A result whose file can't be written stays as it is. Read-only
+// runs never prune, since they don't write to the workspace.
+func (a *Agent) prune(messages []provider.Message) ([]provider.Message, string) {
+	after := cmp.Or(a.pruneAfter, DefaultPruneAfter)
+	keep := cmp.Or(a.pruneKeep, DefaultPruneKeep)
+	minChars := cmp.Or(a.pruneMinChars, DefaultPruneMinChars)
+	if after < 0 || a.readOnly || a.workspaceDir == "" {
+		return nil, ""
+	}
+	turns := 0
+	for _, m := range messages {
+		if m.Role == "assistant" {
+			turns++
+		}
+	}
+	// A turn is an assistant message and the tool results answering it
+	if turns <= after || len(messages) <= 2*keep+1 {
+		return nil, ""
+	}
+
+	var out []provider.Message
+	pruned, saved := 0, 0
+	for i := 1; i < len(messages)-2*keep; i++ {
+		var blocks []provider.ContentBlock
+		for j, b := range messages[i].Content {
+			if b.Type != "tool_result" || len(b.Content) < minChars || strings.HasPrefix(b.Content, prunedNote) {
+				continue
+			}
+			path, err := a.savePruned(b.ToolUseIDRef, b.Content)
+			if err != nil {
+				continue
+			}
+			if blocks == nil {
+				blocks = append([]provider.ContentBlock{}, messages[i].Content...)
+			}
+			saved += len(b.Content)
+			b.Content = prunedSummary(b.Content, path)
+			blocks[j] = b
+			pruned++
+		}
+		if blocks != nil {
+			if out == nil {
+				out = append([]provider.Message{}, messages...)
+			}
+			out[i].Content = blocks
+		}
+	}
+	if out == nil {
+		return nil, ""
+	}
+	return out, i18n.T("agent.pruned", pruned, saved/1000, filepath.Join(a.workspaceDir, prunedDir))
+}
+
+// savePruned writes a tool result to the workspace and returns its path.
+func (a *Agent) savePruned(toolUseID, content string) (string, error) {
+	dir := filepath.Join(a.workspaceDir, prunedDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, toolUseID)
+	if name == "" {
+		name = "result"
+	}
+	path := filepath.Join(dir, name+".txt")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// prunedSummary describes a pruned result: its size, where it was saved,
+// and its first lines.
+func prunedSummary(content, path string) string {
+	lines := strings.Split(content, "\n")
+	preview := lines
+	if len(preview) > prunedPreviewLines {
+		preview = preview[:prunedPreviewLines]
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d characters, %d lines, saved to %s. It began:\n", prunedNote, len(content), len(lines), path)
+	for _, l := range preview {
+		if len(l) > prunedLineChars {
+			l = strings.ToValidUTF8(l[:prunedLineChars-1], "") + "…"
+		}
+		b.WriteString(l + "\n")
+	}
+	b.WriteString("Read the file if you need the rest.]")
+	return b.String()
+}
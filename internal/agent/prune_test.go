@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// pruneConversation is a task and the given number of turns, each
+// calling a tool twice: a bulky result and a short one.
+func pruneConversation(turns int) []provider.Message {
+	messages := []provider.Message{provider.NewUserMessage(provider.NewTextBlock("go"))}
+	for i := range turns {
+		big, small := fmt.Sprintf("big_%d", i), fmt.Sprintf("small_%d", i)
+		messages = append(messages,
+			provider.NewAssistantMessage(provider.NewTextBlock("reading"),
+				provider.NewToolUseBlock(big, "read", nil), provider.NewToolUseBlock(small, "ls", nil)),
+			provider.NewUserMessage(
+				provider.NewToolResultBlock(big, fmt.Sprintf("turn %d\n%s", i, strings.Repeat("x", 500)), i%2 == 1),
+				provider.NewToolResultBlock(small, "a.txt", false)))
+	}
+	return messages
+}
+
+func TestPrune(t *testing.T) {
+	a := &Agent{workspaceDir: t.TempDir()}
+	a.SetPruning(3, 2, 100)
+	messages := pruneConversation(6)
+	before := fmt.Sprint(messages)
+
+	pruned, note := a.prune(messages)
+	if pruned == nil || note == "" {
+		t.Fatal("nothing pruned")
+	}
+	if fmt.Sprint(messages) != before {
+		t.Error("prune changed the conversation it was given")
+	}
+	if len(pruned) != len(messages) {
+		t.Fatalf("%d messages, want %d", len(pruned), len(messages))
+	}
+
+	for i := range pruned {
+		if len(pruned[i].Content) != len(messages[i].Content) || pruned[i].Role != messages[i].Role {
+			t.Fatalf("message %d changed shape", i)
+		}
+		for j, b := range pruned[i].Content {
+			orig := messages[i].Content[j]
+			// Every tool_use keeps its tool_result, in place
+			if b.Type != orig.Type || b.ToolUseID != orig.ToolUseID || b.ToolUseIDRef != orig.ToolUseIDRef || b.IsError != orig.IsError {
+				t.Errorf("message %d block %d = %+v, was %+v", i, j, b, orig)
+			}
+			if b.Type != "tool_result" {
+				continue
+			}
+			if use := pruned[i-1].Content[j+1]; use.ToolUseID != b.ToolUseIDRef { // after the text block
+				t.Errorf("result %s follows call %s", b.ToolUseIDRef, use.ToolUseID)
+			}
+
+			// Only bulky results outside the latest 2 turns are pruned
+			old := i < len(pruned)-4
+			wantPruned := old && strings.HasPrefix(b.ToolUseIDRef, "big_")
+			if got := strings.HasPrefix(b.Content, prunedNote); got != wantPruned {
+				t.Errorf("result %s pruned = %v, want %v", b.ToolUseIDRef, got, wantPruned)
+			}
+			if !wantPruned {
+				if b.Content != orig.Content {
+					t.Errorf("result %s changed", b.ToolUseIDRef)
+				}
+				continue
+			}
+			_, path, _ := strings.Cut(b.Content, "saved to ")
+			path, _, _ = strings.Cut(path, ". It began")
+			if data, err := os.ReadFile(path); err != nil || string(data) != orig.Content {
+				t.Errorf("result %s saved as %q: %v", b.ToolUseIDRef, data, err)
+			}
+			if !strings.Contains(b.Content, "It began:\nturn ") {
+				t.Errorf("result %s summary = %q", b.ToolUseIDRef, b.Content)
+			}
+		}
+	}
+
+	// Pruned results aren't pruned again
+	if again, _ := a.prune(pruned); again != nil {
+		t.Error("pruning twice pruned again")
+	}
+}
+
+func TestPruneSkipped(t *testing.T) {
+	tests := []struct {
+		name             string
+		after, keep, min int
+		readOnly         bool
+		turns            int
+	}{
+		{"too few turns", 3, 2, 100, false, 3},
+		{"all kept", 1, 6, 100, false, 6},
+		{"nothing bulky", 3, 2, 1000, false, 6},
+		{"turned off", -1, 2, 100, false, 6},
+		{"read-only", 3, 2, 100, true, 6},
+	}
+	for _, tt := range tests {
+		a := &Agent{workspaceDir: t.TempDir(), readOnly: tt.readOnly}
+		a.SetPruning(tt.after, tt.keep, tt.min)
+		if pruned, note := a.prune(pruneConversation(tt.turns)); pruned != nil {
+			t.Errorf("%s: pruned: %s", tt.name, note)
+		}
+	}
+}
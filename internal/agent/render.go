@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// streamRenderer prints a model turn to stderr as it streams in: text as it
+// arrives, and each tool call's name as soon as the call starts, followed
+// by its input (the code, for run_script) once the input is complete. A
+// spinner runs whenever nothing new is showing.
+type streamRenderer struct {
+	stopSpinner func() // nil when no spinner is running
+	midLine     bool   // text was printed without a trailing newline
+	tool        string // tool call whose input is streaming; "" = none
+	input       strings.Builder
+}
+
+func newStreamRenderer() *streamRenderer {
+	return &streamRenderer{stopSpinner: ui.Spinner("  " + i18n.T("spinner.thinking"))}
+}
+
+func (r *streamRenderer) event(e provider.StreamEvent) {
+	switch e.Type {
+	case "text":
+		r.endTool()
+		r.stop()
+		r.writeText(e.Text)
+	case "tool_use":
+		r.endTool()
+		r.endLine()
+		r.stop()
+		fmt.Fprintf(os.Stderr, "  %s %s\n", toolStyle.Render("▸"), debugStyle.Render(toolLabel(e.ToolName)))
+		r.tool = e.ToolName
+		r.stopSpinner = ui.Spinner("    " + i18n.T("spinner.writing"))
+	case "tool_input":
+		r.input.WriteString(e.Text)
+	}
+}
+
+// finish completes the output once the response has ended or failed.
+func (r *streamRenderer) finish() {
+	r.endTool()
+	r.endLine()
+	r.stop()
+}
+
+func (r *streamRenderer) stop() {
+	if r.stopSpinner != nil {
+		r.stopSpinner()
+		r.stopSpinner = nil
+	}
+}
+
+// writeText styles each line of a text delta separately; styling text
+// with a newline in it would pad the lines to one width.
+func (r *streamRenderer) writeText(text string) {
+	if text == "" {
+		return
+	}
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		if line != "" {
+			fmt.Fprint(os.Stderr, debugStyle.Render(line))
+		}
+	}
+	r.midLine = !strings.HasSuffix(text, "\n")
+}
+
+func (r *streamRenderer) endLine() {
+	if r.midLine {
+		fmt.Fprintln(os.Stderr)
+		r.midLine = false
+	}
+}
+
+// endTool prints the input of the tool call that was streaming.
+func (r *streamRenderer) endTool() {
+	if r.tool == "" {
+		return
+	}
+	r.stop()
+	printToolInput(r.tool, json.RawMessage(r.input.String()))
+	r.tool = ""
+	r.input.Reset()
+}
+
+// toolLabel is the name a tool call is shown under.
+func toolLabel(name string) string {
+	if name == "run_script" {
+		return "script"
+	}
+	return name
+}
@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// Session is an agent run's conversation, saved after every turn so
+// `think --resume` can continue a run that was interrupted (Ctrl+C, a
+// crash, the iteration limit, a failed API call) instead of starting over
+// and paying for its turns again. A run that finishes removes it.
+type Session struct {
+	Script        string             `json:"script"`
+	Args          []string           `json:"args"`
+	ResumeContext string             `json:"resume_context"`
+	Started       time.Time          `json:"started"`
+	Updated       time.Time          `json:"updated"`
+	Error         string             `json:"error,omitempty"` // why the run stopped, once it has
+	Messages      []provider.Message `json:"messages"`
+}
+
+// SessionPath returns where a thought's interrupted session is saved.
+func SessionPath(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "session.json")
+}
+
+// LoadSession returns a thought's saved session. The error satisfies
+// os.IsNotExist when there is none.
+func LoadSession(thoughtDir string) (*Session, error) {
+	data, err := os.ReadFile(SessionPath(thoughtDir))
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", SessionPath(thoughtDir), err)
+	}
+	return &s, nil
+}
+
+// SetSession makes the agent save its conversation into s (written to
+// SessionPath) after every turn, and remove it when the run finishes. When
+// s already has messages, Run continues that conversation instead of
+// starting from the prompt.
+func (a *Agent) SetSession(s *Session) {
+	a.session = s
+}
+
+// saveSession writes the session with messages; errors are ignored, as a
+// session that can't be saved only means the run can't be resumed.
+func (a *Agent) saveSession(messages []provider.Message) {
+	if a.session == nil {
+		return
+	}
+	a.session.Messages = messages
+	a.session.Updated = time.Now()
+	if a.session.Started.IsZero() {
+		a.session.Started = a.session.Updated
+	}
+	data, err := json.Marshal(a.session)
+	if err != nil {
+		return
+	}
+	path := SessionPath(a.thoughtDir)
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if os.WriteFile(tmp, data, 0600) == nil {
+		os.Rename(tmp, path)
+	}
+}
+
+// endSession removes the saved session after a finished run, or records
+// why it stopped so --resume can tell the agent.
+func (a *Agent) endSession(err error) {
+	if a.session == nil {
+		return
+	}
+	if err == nil {
+		os.Remove(SessionPath(a.thoughtDir))
+		return
+	}
+	if len(a.session.Messages) > 0 {
+		a.session.Error = err.Error()
+		a.saveSession(a.session.Messages)
+	}
+}
+
+// interruptedNote tells the agent its conversation is being continued.
+const interruptedNote = `[The previous run of this script stopped before it finished (%s) and is being resumed. Continue from where this conversation left off. Steps that were cut short may have done part of their work, so check their effects before repeating them.]`
+
+// resumeMessages makes a saved conversation ready to send again. Tool
+// calls the run never got results for are answered with an error, and the
+// agent is told the run was interrupted.
+func resumeMessages(s *Session) []provider.Message {
+	messages := append([]provider.Message{}, s.Messages...)
+	reason := s.Error
+	if reason == "" {
+		reason = "the process was killed"
+	}
+	note := provider.NewTextBlock(fmt.Sprintf(interruptedNote, truncate(reason, 300)))
+
+	last := messages[len(messages)-1]
+	if last.Role == "user" {
+		last.Content = append(append([]provider.ContentBlock{}, last.Content...), note)
+		messages[len(messages)-1] = last
+		return messages
+	}
+	var blocks []provider.ContentBlock
+	for _, b := range last.Content {
+		if b.Type == "tool_use" {
+			blocks = append(blocks, provider.NewToolResultBlock(b.ToolUseID, "interrupted: the run stopped before this tool call finished", true))
+		}
+	}
+	return append(messages, provider.NewUserMessage(append(blocks, note)...))
+}
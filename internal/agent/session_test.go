@@ -0,0 +1,123 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+func TestSessionResume(t *testing.T) {
+	// The first run fails on its third call
+	first := &stubProvider{reply: func(turn int, _ provider.ChatParams) *provider.ChatResponse {
+		if turn == 2 {
+			return nil
+		}
+		return toolTurn("working", fmt.Sprint(turn))
+	}}
+	a := testAgent(t, first, 10)
+	a.SetSession(&Session{Script: "test", Args: []string{"a", "b"}})
+	if err := a.Run(context.Background(), "count"); !errors.Is(err, errStubDown) {
+		t.Fatalf("first run: %v", err)
+	}
+
+	saved, err := LoadSession(a.thoughtDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Script != "test" || strings.Join(saved.Args, " ") != "a b" || !strings.Contains(saved.Error, errStubDown.Error()) || saved.Started.IsZero() {
+		t.Errorf("saved session = %+v", saved)
+	}
+	if got, want := fmt.Sprint(saved.Messages), fmt.Sprint(first.requests[2].Messages); got != want {
+		t.Errorf("saved messages =\n%s\nwant the last request's\n%s", got, want)
+	}
+
+	// The resumed run picks up where it stopped, told why it did
+	second := &stubProvider{reply: func(int, provider.ChatParams) *provider.ChatResponse { return endTurn("done") }}
+	b := testAgent(t, second, 10)
+	b.thoughtDir = a.thoughtDir
+	b.SetSession(saved)
+	want := len(saved.Messages)
+	if err := b.Run(context.Background(), "count"); err != nil {
+		t.Fatal(err)
+	}
+	sent := second.requests[0].Messages
+	if len(sent) != want {
+		t.Fatalf("resumed with %d messages, want %d", len(sent), want)
+	}
+	note := sent[len(sent)-1].Content
+	if text := note[len(note)-1].Text; !strings.HasPrefix(text, "[The previous run of this script stopped") || !strings.Contains(text, errStubDown.Error()) {
+		t.Errorf("resume note = %q", text)
+	}
+	if _, err := LoadSession(a.thoughtDir); !os.IsNotExist(err) {
+		t.Errorf("session after a finished run: %v, want it removed", err)
+	}
+}
+
+func TestResumeMessages(t *testing.T) {
+	prompt := provider.NewUserMessage(provider.NewTextBlock("go"))
+	calls := provider.NewAssistantMessage(provider.NewTextBlock("reading"),
+		provider.NewToolUseBlock("t1", "read", nil), provider.NewToolUseBlock("t2", "ls", nil))
+
+	// Killed while the tools ran: their calls get error results
+	got := resumeMessages(&Session{Messages: []provider.Message{prompt, calls}})
+	if len(got) != 3 || got[2].Role != "user" {
+		t.Fatalf("messages = %+v", got)
+	}
+	results := got[2].Content
+	if len(results) != 3 || results[0].ToolUseIDRef != "t1" || results[1].ToolUseIDRef != "t2" || !results[0].IsError {
+		t.Errorf("results = %+v", results)
+	}
+	if !strings.Contains(results[2].Text, "(the process was killed)") {
+		t.Errorf("note = %q", results[2].Text)
+	}
+	if unmatched := unmatchedToolUses(got); len(unmatched) > 0 {
+		t.Errorf("tool calls without results: %v", unmatched)
+	}
+
+	// Stopped before calling the model: the note joins the last message
+	saved := []provider.Message{prompt}
+	got = resumeMessages(&Session{Messages: saved, Error: "iteration limit"})
+	if len(got) != 1 || len(got[0].Content) != 2 || !strings.Contains(got[0].Content[1].Text, "(iteration limit)") {
+		t.Errorf("messages = %+v", got)
+	}
+	if len(saved[0].Content) != 1 {
+		t.Error("resumeMessages changed the saved session")
+	}
+}
+
+func TestLoadSessionDamaged(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadSession(dir); !os.IsNotExist(err) {
+		t.Errorf("no session: err = %v, want not exist", err)
+	}
+
+	a := &Agent{thoughtDir: dir, session: &Session{Script: "test"}}
+	a.saveSession([]provider.Message{provider.NewUserMessage(provider.NewTextBlock("go"))})
+	data, err := os.ReadFile(SessionPath(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := LoadSession(dir); err != nil || s.Script != "test" || len(s.Messages) != 1 {
+		t.Fatalf("LoadSession = %+v, %v", s, err)
+	}
+
+	for name, damaged := range map[string][]byte{
+		"truncated":  data[:len(data)/2],
+		"empty":      {},
+		"garbage":    []byte("\x00\x01not json"),
+		"wrong type": []byte(`{"messages": "go"}`),
+	} {
+		if err := os.WriteFile(SessionPath(dir), damaged, 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadSession(dir)
+		if err == nil || os.IsNotExist(err) || !strings.Contains(err.Error(), SessionPath(dir)) {
+			t.Errorf("%s: err = %v, want a decoding error naming the file", name, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// Defaults for a spawn_agent call that doesn't set its own limits.
+const (
+	DefaultSubAgentIterations = 20
+	DefaultSubAgentTokens     = 200_000 // input plus output, all calls
+)
+
+const subAgentPrompt = `
+
+## Sub-agent
+
+You are a sub-agent: the agent running this script handed you one
+subtask, which is the user message. Do only that subtask. You have
+run_script but not write_stdout or spawn_agent, and nothing you do reaches
+the script's output. Do not write memory.js or memories. When you're done,
+stop calling tools and reply with the result as plain text. That final
+message is all the other agent gets back, so make it complete and
+concise: the answer, the values it asked for, or what you couldn't do
+and why.`
+
+// errSubAgentTokens stops a sub-agent that used up its token budget.
+var errSubAgentTokens = errors.New("sub-agent token budget used up")
+
+// spawn runs task in a child agent and returns its final answer; it backs
+// the spawn_agent tool. The child shares the run's provider, model, and
+// budget, runs at most maxIterations turns (no more than the parent's
+// limit) and maxTokens tokens, and has only run_script, so it can't spawn
+// agents of its own. Its conversation goes into the run's history.
+func (a *Agent) spawn(ctx context.Context, task string, maxIterations, maxTokens int) (string, error) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultSubAgentIterations
+	}
+	if maxTokens <= 0 {
+		maxTokens = DefaultSubAgentTokens
+	}
+	child := &Agent{
+		provider:      a.provider,
+		registry:      a.registry.Subset("run_script"),
+		model:         a.model,
+		maxTokens:     a.maxTokens,
+		maxIterations: min(maxIterations, a.maxIterations),
+		scriptName:    a.scriptName,
+		thoughtDir:    a.thoughtDir,
+		workspaceDir:  a.workspaceDir,
+		memoriesDir:   a.memoriesDir,
+		memoryJSPath:  a.memoryJSPath,
+		cacheMode:     a.cacheMode,
+		readOnly:      a.readOnly,
+		persistentWS:  a.persistentWS,
+		costs:         a.costs,
+		budget:        a.budget,
+		contextLimit:  a.contextLimit,
+		pruneAfter:    a.pruneAfter,
+		pruneKeep:     a.pruneKeep,
+		pruneMinChars: a.pruneMinChars,
+		temperature:   a.temperature,
+		topP:          a.topP,
+		stop:          a.stop,
+		tokenScale:    a.tokenScale,
+		sub:           true,
+		tokenLimit:    maxTokens,
+	}
+	child.SetRecorder(a.recorder)
+
+	err := child.run(ctx, task)
+	a.registry.AddStats(child.registry.Stats())
+	if ctx.Err() != nil || errors.Is(err, approval.ErrInterrupted) || errors.Is(err, ErrBudgetExceeded) {
+		return "", err
+	}
+	if err != nil {
+		msg := fmt.Sprintf("the sub-agent stopped before finishing: %v", err)
+		if child.lastText != "" {
+			msg += "\nIts last message: " + child.lastText
+		}
+		return "", errors.New(msg)
+	}
+	if child.lastText == "" {
+		return "", errors.New("the sub-agent finished without an answer")
+	}
+	return child.lastText, nil
+}
+
+// countTokens adds a call's tokens to a sub-agent's total, estimated when
+// the API reports none.
+func (a *Agent) countTokens(params provider.ChatParams, resp *provider.ChatResponse) {
+	if a.tokenLimit <= 0 {
+		return
+	}
+	if n := resp.Usage.InputTokens + resp.Usage.OutputTokens; n > 0 {
+		a.tokensUsed += n
+	} else {
+		a.tokensUsed += cost.RequestTokens(params) + cost.ResponseTokens(resp)
+	}
+}
+
+// checkTokens returns an error once a sub-agent has used up its tokens.
+func (a *Agent) checkTokens() error {
+	if a.tokenLimit > 0 && a.tokensUsed >= a.tokenLimit {
+		return fmt.Errorf("%w: used %d tokens (limit %d)", errSubAgentTokens, a.tokensUsed, a.tokenLimit)
+	}
+	return nil
+}
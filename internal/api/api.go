@@ -0,0 +1,323 @@
+// Package api is the control API behind `think api serve`: JSON-RPC 2.0,
+// one message per line, over a unix socket, so orchestrators such as CI
+// systems can drive think without a terminal. Clients authenticate with a
+// shared token, submit runs, stream their output and events, answer
+// approval prompts, and collect results.
+//
+// Each run is a child think process. Its approval and input prompts come
+// back over the same socket (Prompter, authenticated with a per-run
+// token), are published as "prompt" events, and block until a client
+// answers them with approval.answer.
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Environment variables.
+const (
+	TokenEnv    = "THINKINGSCRIPT_API_TOKEN"     // client token for `think api serve`; generated when unset
+	SocketEnv   = "THINKINGSCRIPT_API_SOCKET"    // set for runs: where to send prompts
+	RunTokenEnv = "THINKINGSCRIPT_API_RUN_TOKEN" // set for runs: authenticates the run's prompts
+)
+
+// Run statuses.
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// Event types.
+const (
+	EventStdout   = "stdout"
+	EventStderr   = "stderr"
+	EventPrompt   = "prompt"   // the run is waiting for approval.answer
+	EventAnswered = "answered" // a prompt was answered
+	EventExit     = "exit"     // the run finished; always the last event
+)
+
+// Methods.
+const (
+	MethodAuth    = "auth"
+	MethodSubmit  = "run.submit"
+	MethodList    = "run.list"
+	MethodGet     = "run.get"
+	MethodEvents  = "run.events" // replays and then streams run.event notifications
+	MethodWait    = "run.wait"
+	MethodCancel  = "run.cancel"
+	MethodAnswer  = "approval.answer"
+	MethodEvent   = "run.event" // notification sent to run.events subscribers
+	methodApprove = "prompt.approve"
+	methodInput   = "prompt.input"
+)
+
+// JSON-RPC error codes; -32000 and up are this API's.
+const (
+	CodeParse          = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeUnauthorized   = -32001
+	CodeNotFound       = -32002
+	CodeFailed         = -32003
+)
+
+// Error is a JSON-RPC error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// message is a JSON-RPC request, response, or notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// AuthParams authenticates a connection: Token is the server's token, or a
+// run's token for that run's prompts.
+type AuthParams struct {
+	Token string `json:"token"`
+}
+
+// SubmitParams describe a run. Script is resolved against Cwd, which
+// defaults to the server's working directory.
+type SubmitParams struct {
+	Script   string   `json:"script"`
+	Args     []string `json:"args,omitempty"`
+	Cwd      string   `json:"cwd,omitempty"`
+	Stdin    string   `json:"stdin,omitempty"`
+	ReadOnly bool     `json:"read_only,omitempty"`
+	Allow    []string `json:"allow,omitempty"` // --allow paths
+	Write    []string `json:"write,omitempty"` // --write paths
+	Backend  string   `json:"backend,omitempty"`
+}
+
+// RunParams name a run. Since (run.events) skips events before that
+// sequence number.
+type RunParams struct {
+	ID    string `json:"id"`
+	Since int    `json:"since,omitempty"`
+}
+
+// Run describes a run. Stdout is only filled in by run.wait.
+type Run struct {
+	ID       string     `json:"id"`
+	Script   string     `json:"script"`
+	Args     []string   `json:"args,omitempty"`
+	Status   string     `json:"status"`
+	ExitCode int        `json:"exit_code"`
+	Started  time.Time  `json:"started"`
+	Ended    *time.Time `json:"ended,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Stdout   string     `json:"stdout,omitempty"`
+}
+
+// Event is something that happened in a run. Seq counts from 1.
+type Event struct {
+	Run      string    `json:"run"`
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	Data     string    `json:"data,omitempty"`      // stdout/stderr output
+	Prompt   *Prompt   `json:"prompt,omitempty"`    // prompt and answered events
+	ExitCode *int      `json:"exit_code,omitempty"` // exit events
+}
+
+// Prompt is a question a run is waiting on. Kind is "read", "write",
+// "delete", "env", "net", "tool", "secret", or "input".
+type Prompt struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Target   string `json:"target"`             // path, env var, host, or question
+	Activity string `json:"activity,omitempty"` // what the run was doing
+	Default  string `json:"default,omitempty"`  // input prompts
+}
+
+// AnswerParams answer a prompt. Approval prompts take a Decision ("once",
+// "run", "hour", "always", "deny-once", or "deny") and an optional Note;
+// input prompts take a Value.
+type AnswerParams struct {
+	Run      string `json:"run"`
+	Prompt   string `json:"prompt"`
+	Decision string `json:"decision,omitempty"`
+	Note     string `json:"note,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// answer is the reply to a run's prompt.approve or prompt.input call.
+type answer struct {
+	Decision string `json:"decision,omitempty"`
+	Note     string `json:"note,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// Client is a connection to the API server. Notifications (run.events)
+// arrive on Events, which must be drained while subscribed.
+type Client struct {
+	conn   net.Conn
+	events chan Event
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	nextID  int64
+	pending map[string]chan message
+	err     error // set when the connection is gone
+}
+
+// Dial connects to the server at socket and authenticates with token.
+func Dial(socket, token string) (*Client, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:    conn,
+		events:  make(chan Event, 256),
+		enc:     json.NewEncoder(conn),
+		pending: make(map[string]chan message),
+	}
+	go c.read()
+	if err := c.Call(MethodAuth, AuthParams{Token: token}, nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Call sends a request and decodes its result into result (if non-nil).
+// A JSON-RPC error comes back as *Error.
+func (c *Client) Call(method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := fmt.Sprint(c.nextID)
+	ch := make(chan message, 1)
+	c.pending[id] = ch
+	err = c.enc.Encode(message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: raw})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	reply, ok := <-ch
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if result != nil && len(reply.Result) > 0 {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+// Events returns the run.event notifications received on this connection.
+// It is closed when the connection closes.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Client) read() {
+	defer close(c.events)
+	r := bufio.NewReader(c.conn)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var m message
+			if json.Unmarshal(line, &m) == nil {
+				c.dispatch(m)
+			}
+		}
+		if err != nil {
+			c.mu.Lock()
+			c.err = errors.New("api connection closed")
+			for id, ch := range c.pending {
+				delete(c.pending, id)
+				close(ch)
+			}
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (c *Client) dispatch(m message) {
+	if m.Method == MethodEvent {
+		var e Event
+		if json.Unmarshal(m.Params, &e) == nil {
+			c.events <- e
+		}
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.pending[string(m.ID)]; ok {
+		delete(c.pending, string(m.ID))
+		ch <- m
+	}
+}
+
+// Prompter answers a run's prompts through the server that started it. It
+// implements approval.Prompter.
+type Prompter struct {
+	c *Client
+}
+
+// DialPrompter connects a run to the server named by SocketEnv, using the
+// run's RunTokenEnv token.
+func DialPrompter(socket, runToken string) (*Prompter, error) {
+	c, err := Dial(socket, runToken)
+	if err != nil {
+		return nil, err
+	}
+	return &Prompter{c: c}, nil
+}
+
+func (p *Prompter) Approve(kind, target, activity string) (string, string, error) {
+	var a answer
+	err := p.c.Call(methodApprove, Prompt{Kind: kind, Target: target, Activity: activity}, &a)
+	return a.Decision, a.Note, err
+}
+
+func (p *Prompter) Input(question, defaultValue string) (string, error) {
+	var a answer
+	err := p.c.Call(methodInput, Prompt{Kind: "input", Target: question, Default: defaultValue}, &a)
+	return a.Value, err
+}
+
+// Close closes the connection to the server.
+func (p *Prompter) Close() error {
+	return p.c.Close()
+}
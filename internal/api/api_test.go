@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain doubles as a fake think for runs: with API_TEST_CHILD set it
+// asks for approval and input through the server, like a run would.
+func TestMain(m *testing.M) {
+	if os.Getenv("API_TEST_CHILD") == "1" {
+		os.Exit(fakeThink())
+	}
+	os.Exit(m.Run())
+}
+
+func fakeThink() int {
+	args := os.Args[1:]
+	for i, a := range args {
+		if a == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	if len(args) > 0 && args[0] == "sleep.thought" {
+		time.Sleep(time.Minute)
+		return 0
+	}
+	p, err := DialPrompter(os.Getenv(SocketEnv), os.Getenv(RunTokenEnv))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer p.Close()
+	decision, note, err := p.Approve("net", "api.weather.gov", "fetching forecast")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 3
+	}
+	city, _ := p.Input("city?", "Paris")
+	fmt.Printf("%s %s %s %s\n", strings.Join(args, ","), decision, note, city)
+	return 0
+}
+
+func startServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	// Unix socket paths are short; t.TempDir can be too long on macOS
+	dir, err := os.MkdirTemp("", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "s")
+	l, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("API_TEST_CHILD", "1")
+	s := &Server{Token: "secret", Socket: socket, Executable: os.Args[0]}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Serve(ctx, l)
+	return s, socket
+}
+
+func TestRunWithRemoteApproval(t *testing.T) {
+	_, socket := startServer(t)
+
+	if _, err := Dial(socket, "wrong"); err == nil {
+		t.Fatal("Dial accepted a wrong token")
+	}
+	c, err := Dial(socket, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var run Run
+	if err := c.Call(MethodSubmit, SubmitParams{Script: "weather.thought", Args: []string{"--today"}}, &run); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Call(MethodEvents, RunParams{ID: run.ID}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	for e := range c.Events() {
+		types = append(types, e.Type)
+		if e.Type == EventPrompt {
+			ans := AnswerParams{Run: run.ID, Prompt: e.Prompt.ID, Decision: "once", Note: "ci"}
+			if e.Prompt.Kind == "input" {
+				ans = AnswerParams{Run: run.ID, Prompt: e.Prompt.ID, Value: "Oslo"}
+			} else if e.Prompt.Target != "api.weather.gov" || e.Prompt.Activity != "fetching forecast" {
+				t.Errorf("prompt = %+v", e.Prompt)
+			}
+			// Answers are checked against the prompt kind
+			if e.Prompt.Kind != "input" {
+				bad := ans
+				bad.Decision = "sure"
+				if err := c.Call(MethodAnswer, bad, nil); err == nil {
+					t.Error("approval.answer accepted an invalid decision")
+				}
+			}
+			go c.Call(MethodAnswer, ans, nil)
+		}
+		if e.Type == EventExit {
+			break
+		}
+	}
+
+	var result Run
+	if err := c.Call(MethodWait, RunParams{ID: run.ID}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != StatusSucceeded || result.Stdout != "weather.thought,--today once ci Oslo\n" {
+		t.Errorf("result = %+v", result)
+	}
+	want := "prompt answered prompt answered stdout exit"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+
+	// Late subscribers get the whole history from Since
+	c2, _ := Dial(socket, "secret")
+	defer c2.Close()
+	c2.Call(MethodEvents, RunParams{ID: run.ID, Since: 5}, nil)
+	if e := <-c2.Events(); e.Type != EventStdout || e.Seq != 5 {
+		t.Errorf("replayed event = %+v", e)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	_, socket := startServer(t)
+	c, err := Dial(socket, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var run Run
+	c.Call(MethodSubmit, SubmitParams{Script: "sleep.thought"}, &run)
+	if err := c.Call(MethodCancel, RunParams{ID: run.ID}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var result Run
+	c.Call(MethodWait, RunParams{ID: run.ID}, &result)
+	if result.Status != StatusCanceled {
+		t.Errorf("status = %s, want canceled", result.Status)
+	}
+
+	var rpcErr *Error
+	if err := c.Call(MethodGet, RunParams{ID: "r99"}, nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeNotFound {
+		t.Errorf("run.get unknown err = %v", err)
+	}
+}
+
+func TestRunTokenOnlySendsPrompts(t *testing.T) {
+	s, socket := startServer(t)
+	c, _ := Dial(socket, "secret")
+	defer c.Close()
+	var run Run
+	c.Call(MethodSubmit, SubmitParams{Script: "sleep.thought"}, &run)
+	defer c.Call(MethodCancel, RunParams{ID: run.ID}, nil)
+
+	r, _ := s.run(run.ID)
+	rc, err := Dial(socket, r.token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if err := rc.Call(MethodList, nil, nil); err == nil {
+		t.Error("a run's token could list runs")
+	}
+}
+
+func TestRunEnvDropsToken(t *testing.T) {
+	env := runEnv([]string{"PATH=/bin", TokenEnv + "=secret", RunTokenEnv + "=x"})
+	if len(env) != 1 || env[0] != "PATH=/bin" {
+		t.Errorf("runEnv = %v", env)
+	}
+}
+
+func TestListenLeavesOtherFiles(t *testing.T) {
+	dir, err := os.MkdirTemp("", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	notes := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notes, []byte("keep me"), 0644)
+	if _, err := Listen(notes); err == nil || !strings.Contains(err.Error(), "not a socket") {
+		t.Errorf("Listen(regular file) = %v, want not a socket", err)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "keep me" {
+		t.Errorf("file = %q after Listen", data)
+	}
+
+	// A stale socket from a server that's gone is replaced
+	socket := filepath.Join(dir, "s")
+	l, err := Listen(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = Listen(socket)
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	l.Close()
+}
+
+func TestWriteToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s.token")
+	os.WriteFile(path, []byte("old"), 0666)
+	os.Chmod(path, 0666)
+	if err := WriteToken(path, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); string(data) != "secret\n" {
+		t.Errorf("token file = %q", data)
+	}
+}
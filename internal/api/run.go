@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// run is a submitted run: its child process's state, every event so far
+// (kept for late subscribers), and the prompts waiting for an answer.
+type run struct {
+	id     string
+	script string
+	args   []string
+	token  string
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the process exits
+
+	mu         sync.Mutex
+	canceled   bool // run.cancel was called
+	status     string
+	exitCode   int
+	err        string
+	started    time.Time
+	ended      time.Time
+	stdout     strings.Builder
+	events     []Event
+	changed    chan struct{} // closed and replaced on every event
+	prompts    map[string]chan answer
+	nextPrompt int
+}
+
+func (r *run) info(withStdout bool) Run {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info := Run{
+		ID:       r.id,
+		Script:   r.script,
+		Args:     r.args,
+		Status:   r.status,
+		ExitCode: r.exitCode,
+		Started:  r.started,
+		Error:    r.err,
+	}
+	if !r.ended.IsZero() {
+		ended := r.ended
+		info.Ended = &ended
+	}
+	if withStdout {
+		info.Stdout = r.stdout.String()
+	}
+	return info
+}
+
+// emit appends an event and wakes subscribers. Callers hold r.mu.
+func (r *run) emit(e Event) {
+	e.Run = r.id
+	e.Seq = len(r.events) + 1
+	e.Time = time.Now()
+	r.events = append(r.events, e)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// finish records the process's exit. Prompts still waiting are abandoned.
+func (r *run) finish(code int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.status != StatusRunning {
+		return
+	}
+	r.exitCode = code
+	r.ended = time.Now()
+	switch {
+	case r.canceled:
+		r.status = StatusCanceled
+	case err != nil || code != 0:
+		r.status = StatusFailed
+	default:
+		r.status = StatusSucceeded
+	}
+	if err != nil {
+		r.err = err.Error()
+	}
+	for id, ch := range r.prompts {
+		delete(r.prompts, id)
+		close(ch)
+	}
+	r.emit(Event{Type: EventExit, ExitCode: &code})
+	close(r.done)
+}
+
+// stop kills the run's process.
+func (r *run) stop() {
+	r.mu.Lock()
+	r.canceled = true
+	r.mu.Unlock()
+	r.cancel()
+}
+
+// stream sends events from seq since on as run.event notifications until
+// the run's exit event has been sent or ctx ends.
+func (r *run) stream(ctx context.Context, c *conn, since int) {
+	next := since
+	if next < 1 {
+		next = 1
+	}
+	for {
+		r.mu.Lock()
+		pending := append([]Event(nil), r.events[min(next-1, len(r.events)):]...)
+		changed := r.changed
+		r.mu.Unlock()
+
+		for _, e := range pending {
+			raw, _ := json.Marshal(e)
+			if c.send(message{Method: MethodEvent, Params: raw}) != nil {
+				return
+			}
+			next = e.Seq + 1
+			if e.Type == EventExit {
+				return
+			}
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// prompt publishes p and waits for a client to answer it.
+func (r *run) prompt(ctx context.Context, p Prompt) (answer, error) {
+	r.mu.Lock()
+	if r.status != StatusRunning {
+		r.mu.Unlock()
+		return answer{}, errors.New("run is not running")
+	}
+	r.nextPrompt++
+	p.ID = fmt.Sprintf("p%d", r.nextPrompt)
+	ch := make(chan answer, 1)
+	r.prompts[p.ID] = ch
+	r.emit(Event{Type: EventPrompt, Prompt: &p})
+	r.mu.Unlock()
+
+	select {
+	case a, ok := <-ch:
+		if !ok {
+			return answer{}, errors.New("run ended before the prompt was answered")
+		}
+		return a, nil
+	case <-ctx.Done():
+		r.mu.Lock()
+		delete(r.prompts, p.ID)
+		r.mu.Unlock()
+		return answer{}, ctx.Err()
+	}
+}
+
+// answer delivers a client's answer to a waiting prompt.
+func (r *run) answer(a AnswerParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch, ok := r.prompts[a.Prompt]
+	if !ok {
+		return &Error{Code: CodeNotFound, Message: "no pending prompt " + a.Prompt}
+	}
+	var p *Prompt
+	for i := len(r.events) - 1; i >= 0; i-- {
+		if e := r.events[i]; e.Type == EventPrompt && e.Prompt.ID == a.Prompt {
+			p = e.Prompt
+			break
+		}
+	}
+	if p != nil && p.Kind != "input" {
+		switch a.Decision {
+		case "once", "run", "hour", "always", "deny-once", "deny":
+		default:
+			return &Error{Code: CodeInvalidParams, Message: `decision must be "once", "run", "hour", "always", "deny-once", or "deny"`}
+		}
+	}
+	delete(r.prompts, a.Prompt)
+	ch <- answer{Decision: a.Decision, Note: a.Note, Value: a.Value}
+	r.emit(Event{Type: EventAnswered, Prompt: p, Data: a.Decision})
+	return nil
+}
+
+// handlePrompt answers a prompt.approve or prompt.input call from the
+// run's own think process.
+func (r *run) handlePrompt(ctx context.Context, c *conn, m message) {
+	var p Prompt
+	if err := json.Unmarshal(m.Params, &p); err != nil {
+		c.reply(m.ID, nil, &Error{Code: CodeInvalidParams, Message: err.Error()})
+		return
+	}
+	switch m.Method {
+	case methodApprove:
+		if p.Kind == "input" {
+			p.Kind = "unknown"
+		}
+	case methodInput:
+		p.Kind = "input"
+	default:
+		c.reply(m.ID, nil, &Error{Code: CodeUnauthorized, Message: "runs may only send prompts"})
+		return
+	}
+	a, err := r.prompt(ctx, p)
+	c.reply(m.ID, a, err)
+}
+
+// outputWriter turns a run's stdout or stderr into events.
+type outputWriter struct {
+	r    *run
+	kind string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.r.mu.Lock()
+	defer w.r.mu.Unlock()
+	if w.kind == EventStdout {
+		w.r.stdout.Write(p)
+	}
+	w.r.emit(Event{Type: w.kind, Data: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,376 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server runs think on behalf of API clients.
+type Server struct {
+	Token      string // clients must authenticate with it
+	Socket     string // where the server listens, passed to runs for their prompts
+	Executable string // think binary for runs; "" = this executable
+
+	mu     sync.Mutex
+	runs   map[string]*run
+	order  []string
+	nextID int
+}
+
+// NewToken returns a random token for a server.
+func NewToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Listen removes a stale socket left by a server that's gone and listens on
+// socket, readable and writable only by the user. Anything at socket that
+// isn't a socket is left alone and reported.
+func Listen(socket string) (net.Listener, error) {
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", socket)
+		}
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: a server is already listening", socket)
+		}
+		os.Remove(socket)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// WriteToken writes a server's token to path, readable only by the user. A
+// file already there is removed first and the new one is created
+// exclusively, so a file another user put there (in /tmp, say) is never
+// written to with its own permissions.
+func WriteToken(path, token string) error {
+	os.Remove(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(token + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Serve accepts connections on l until ctx is cancelled, then cancels the
+// runs still going.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		l.Close()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, r := range s.runs {
+			r.stop()
+		}
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// conn is one client connection. Requests are handled concurrently, since
+// run.wait and prompts block; writes are serialized.
+type conn struct {
+	net.Conn
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (c *conn) send(m message) error {
+	m.JSONRPC = "2.0"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(m)
+}
+
+func (c *conn) reply(id json.RawMessage, result any, err error) {
+	if id == nil {
+		return
+	}
+	m := message{ID: id}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeFailed, Message: err.Error()}
+		}
+		m.Error = rpcErr
+	} else {
+		raw, mErr := json.Marshal(result)
+		if mErr != nil {
+			m.Error = &Error{Code: CodeFailed, Message: mErr.Error()}
+		} else {
+			m.Result = raw
+		}
+	}
+	c.send(m)
+}
+
+func (s *Server) serveConn(ctx context.Context, nc net.Conn) {
+	c := &conn{Conn: nc, enc: json.NewEncoder(nc)}
+	defer c.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var client bool   // authenticated with the server token
+	var runScope *run // authenticated with this run's token: only its prompts
+	r := bufio.NewReader(c)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			var m message
+			if jsonErr := json.Unmarshal(line, &m); jsonErr != nil {
+				c.reply(json.RawMessage("null"), nil, &Error{Code: CodeParse, Message: "parse error"})
+			} else if m.Method == MethodAuth {
+				client, runScope = s.auth(m.Params)
+				if client || runScope != nil {
+					c.reply(m.ID, map[string]bool{"ok": true}, nil)
+				} else {
+					c.reply(m.ID, nil, &Error{Code: CodeUnauthorized, Message: "invalid token"})
+				}
+			} else if runScope != nil {
+				go runScope.handlePrompt(ctx, c, m)
+			} else if !client {
+				c.reply(m.ID, nil, &Error{Code: CodeUnauthorized, Message: "authenticate first"})
+			} else {
+				go s.handle(ctx, c, m)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// auth checks a token against the server's and every run's.
+func (s *Server) auth(params json.RawMessage) (client bool, scope *run) {
+	var p AuthParams
+	if json.Unmarshal(params, &p) != nil || p.Token == "" {
+		return false, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(p.Token), []byte(s.Token)) == 1 {
+		return true, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.runs {
+		if subtle.ConstantTimeCompare([]byte(p.Token), []byte(r.token)) == 1 {
+			return false, r
+		}
+	}
+	return false, nil
+}
+
+func (s *Server) handle(ctx context.Context, c *conn, m message) {
+	decode := func(v any) error {
+		if err := json.Unmarshal(m.Params, v); err != nil {
+			return &Error{Code: CodeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+	var p RunParams
+	switch m.Method {
+	case MethodSubmit:
+		var sp SubmitParams
+		if err := decode(&sp); err != nil {
+			c.reply(m.ID, nil, err)
+			return
+		}
+		info, err := s.submit(&sp)
+		c.reply(m.ID, info, err)
+	case MethodList:
+		c.reply(m.ID, s.list(), nil)
+	case MethodGet, MethodWait, MethodCancel, MethodEvents:
+		if err := decode(&p); err != nil {
+			c.reply(m.ID, nil, err)
+			return
+		}
+		r, err := s.run(p.ID)
+		if err != nil {
+			c.reply(m.ID, nil, err)
+			return
+		}
+		switch m.Method {
+		case MethodGet:
+			c.reply(m.ID, r.info(false), nil)
+		case MethodWait:
+			select {
+			case <-r.done:
+				c.reply(m.ID, r.info(true), nil)
+			case <-ctx.Done():
+			}
+		case MethodCancel:
+			r.stop()
+			c.reply(m.ID, map[string]bool{"ok": true}, nil)
+		case MethodEvents:
+			c.reply(m.ID, map[string]bool{"ok": true}, nil)
+			r.stream(ctx, c, p.Since)
+		}
+	case MethodAnswer:
+		var ap AnswerParams
+		if err := decode(&ap); err != nil {
+			c.reply(m.ID, nil, err)
+			return
+		}
+		r, err := s.run(ap.Run)
+		if err == nil {
+			err = r.answer(ap)
+		}
+		c.reply(m.ID, map[string]bool{"ok": err == nil}, err)
+	default:
+		c.reply(m.ID, nil, &Error{Code: CodeMethodNotFound, Message: "unknown method " + m.Method})
+	}
+}
+
+func (s *Server) run(id string) (*run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[id]
+	if !ok {
+		return nil, &Error{Code: CodeNotFound, Message: "no run " + id}
+	}
+	return r, nil
+}
+
+func (s *Server) list() []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	runs := make([]Run, 0, len(s.order))
+	for _, id := range s.order {
+		runs = append(runs, s.runs[id].info(false))
+	}
+	return runs
+}
+
+// submit starts a run as a child think process.
+func (s *Server) submit(p *SubmitParams) (Run, error) {
+	if p.Script == "" {
+		return Run{}, &Error{Code: CodeInvalidParams, Message: "script is required"}
+	}
+	if p.Cwd != "" && !filepath.IsAbs(p.Cwd) {
+		return Run{}, &Error{Code: CodeInvalidParams, Message: "cwd must be absolute"}
+	}
+	exe := s.Executable
+	if exe == "" {
+		var err error
+		if exe, err = os.Executable(); err != nil {
+			return Run{}, err
+		}
+	}
+
+	s.mu.Lock()
+	if s.runs == nil {
+		s.runs = make(map[string]*run)
+	}
+	s.nextID++
+	id := fmt.Sprintf("r%d", s.nextID)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &run{
+		id:      id,
+		script:  p.Script,
+		args:    p.Args,
+		token:   NewToken(),
+		status:  StatusRunning,
+		started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+		prompts: make(map[string]chan answer),
+	}
+	s.runs[id] = r
+	s.order = append(s.order, id)
+	s.mu.Unlock()
+
+	cmd := exec.CommandContext(ctx, exe, runArgs(p)...)
+	cmd.Dir = p.Cwd
+	cmd.Env = append(runEnv(os.Environ()), SocketEnv+"="+s.Socket, RunTokenEnv+"="+r.token)
+	if p.Stdin != "" {
+		cmd.Stdin = strings.NewReader(p.Stdin)
+	}
+	cmd.Stdout = outputWriter{r, EventStdout}
+	cmd.Stderr = outputWriter{r, EventStderr}
+	if err := cmd.Start(); err != nil {
+		r.finish(-1, err)
+		return r.info(false), nil
+	}
+	go func() {
+		err := cmd.Wait()
+		code := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			code, err = exitErr.ExitCode(), nil
+		}
+		r.finish(code, err)
+	}()
+	return r.info(false), nil
+}
+
+// runArgs builds the think command line for a submitted run.
+func runArgs(p *SubmitParams) []string {
+	var args []string
+	if p.ReadOnly {
+		args = append(args, "--read-only")
+	}
+	for _, path := range p.Allow {
+		args = append(args, "--allow", path)
+	}
+	for _, path := range p.Write {
+		args = append(args, "--write", path)
+	}
+	if p.Backend != "" {
+		args = append(args, "--backend", p.Backend)
+	}
+	// Flags end here, so script and arguments are never parsed as flags
+	args = append(args, "--", p.Script)
+	return append(args, p.Args...)
+}
+
+// runEnv drops the API's own variables from env, so runs never see the
+// client token.
+func runEnv(env []string) []string {
+	kept := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if name == TokenEnv || name == SocketEnv || name == RunTokenEnv {
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	return kept
+}
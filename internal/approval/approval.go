@@ -2,14 +2,20 @@ package approval
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/i18n"
 	"github.com/thinkingscript/cli/internal/ui"
 	"golang.org/x/term"
 )
@@ -21,19 +27,78 @@ var ErrInterrupted = errors.New("interrupted")
 type promptDecision string
 
 const (
-	promptOnce   promptDecision = "once"   // one-time allow, not persisted
-	promptAlways promptDecision = "always" // persist allow to policy
-	promptDeny   promptDecision = "deny"   // persist deny to policy
+	promptOnce     promptDecision = "once"      // one-time allow, not persisted
+	promptRun      promptDecision = "run"       // allow until the run ends, not persisted
+	promptHour     promptDecision = "hour"      // persist allow to policy, expiring after hourApproval
+	promptAlways   promptDecision = "always"    // persist allow to policy
+	promptDenyOnce promptDecision = "deny-once" // one-time deny, not persisted
+	promptDeny     promptDecision = "deny"      // persist deny to policy
 )
 
+// hourApproval is how long an "allow for 1 hour" answer lasts.
+const hourApproval = time.Hour
+
+// allows reports whether the decision allows the request.
+func (d promptDecision) allows() bool {
+	return d == promptOnce || d == promptRun || d == promptHour || d == promptAlways
+}
+
+// expiry returns when an "allow for 1 hour" answer given now expires.
+func expiry() *time.Time {
+	t := time.Now().Add(hourApproval)
+	return &t
+}
+
 // Approver handles permission checks against policies.
+// It is safe for concurrent use; prompts are serialized.
 type Approver struct {
+	mu               sync.Mutex
 	thoughtDir       string
 	globalPolicyPath string
 	thoughtPolicy    *Policy // read-write, saved to thoughtDir/policy.json
 	globalPolicy     *Policy // read-only
+	runPolicy        *Policy // "allow for this run" answers, never saved
+	originDefaults   OriginDefaults
+	ctx              context.Context
+	activity         string // what the script is doing, shown in prompts
+	code             string // the running code, for the details view
+	shownDenials     map[string]bool
 	isTTY            bool
 	ttyInput         *os.File
+	lines            *bufio.Reader // ttyInput or stdin, buffered across readLine calls
+	prompter         Prompter      // answers prompts instead of the terminal; nil = terminal
+	auto             Mode          // answers prompts without asking; ModePrompt = ask
+	onAuto           func(kind, target string, mode Mode)
+}
+
+// Mode is how requests that no policy decides are answered.
+type Mode string
+
+const (
+	ModePrompt Mode = ""       // ask (denied when nothing can be asked)
+	ModeAllow  Mode = "allow"  // allow once without asking
+	ModeDeny   Mode = "deny"   // deny once without asking
+	ModePolicy Mode = "policy" // fail the request: only policy decides
+)
+
+// ParseMode parses an approval mode: allow, deny, policy, or "" / prompt.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModePrompt, "prompt":
+		return ModePrompt, nil
+	case ModeAllow, ModeDeny, ModePolicy:
+		return m, nil
+	}
+	return ModePrompt, fmt.Errorf("unknown approval mode %q (want allow, deny, or policy)", s)
+}
+
+// Prompter answers approval and input prompts in place of the terminal,
+// for runs driven remotely (see internal/api). Approve returns one of
+// "once", "run", "hour", "always", "deny-once", or "deny", plus an
+// optional note saved with "hour"/"always"/"deny" entries.
+type Prompter interface {
+	Approve(kind, target, activity string) (decision, note string, err error)
+	Input(question, defaultValue string) (string, error)
 }
 
 // NewApprover creates an Approver that checks policies and prompts for approval.
@@ -57,11 +122,72 @@ func NewApprover(thoughtDir, globalPolicyPath string) *Approver {
 		ttyInput:         ttyInput,
 		thoughtPolicy:    NewPolicy(),
 		globalPolicy:     NewPolicy(),
+		runPolicy:        NewPolicy(),
+		ctx:              context.Background(),
 	}
 	a.loadPolicies()
 	return a
 }
 
+// OriginDefaults are per-origin default approvals (e.g. thoughts installed
+// from a URL get no network by default). They apply after policy entries and
+// only when the thought policy's own default is still "prompt".
+type OriginDefaults struct {
+	Paths Approval
+	Env   Approval
+	Net   Approval
+}
+
+// SetOriginDefaults sets the trust defaults for the thought's origin.
+func (a *Approver) SetOriginDefaults(d OriginDefaults) {
+	a.originDefaults = d
+}
+
+// SetContext binds prompts to ctx: cancelling it dismisses any open prompt
+// and makes it return ErrInterrupted.
+func (a *Approver) SetContext(ctx context.Context) {
+	a.ctx = ctx
+}
+
+// SetActivity records what the running code is doing (an agent-declared
+// reason or a snippet of the script) so prompts can show why access was
+// requested, and the code itself for the prompt's details view. Pass ""
+// for both to clear them.
+func (a *Approver) SetActivity(activity, code string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.activity = activity
+	a.code = code
+}
+
+// SetPrompter sends every prompt to p instead of the terminal. Prompts are
+// asked even when there's no TTY.
+func (a *Approver) SetPrompter(p Prompter) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.prompter = p
+	a.isTTY = true
+}
+
+// SetMode answers every request no policy decides by mode instead of
+// prompting, even with a TTY or a Prompter; nothing is saved to the
+// policy. record, if set, is called with each such request. Input and
+// confirmation prompts are unaffected.
+func (a *Approver) SetMode(mode Mode, record func(kind, target string, mode Mode)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.auto = mode
+	a.onAuto = record
+}
+
+// Interactive reports whether prompts can be answered: there is a TTY or
+// a Prompter.
+func (a *Approver) Interactive() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.isTTY
+}
+
 // Close releases resources held by the Approver.
 func (a *Approver) Close() {
 	if a.ttyInput != nil {
@@ -70,204 +196,238 @@ func (a *Approver) Close() {
 	}
 }
 
-// ApproveNet checks if network access to a specific host is allowed.
-func (a *Approver) ApproveNet(host string) (bool, error) {
-	// Check thought policy
-	if entry := a.thoughtPolicy.Net.Hosts.MatchHost(host); entry != nil {
-		if entry.Approval == ApprovalAllow {
+// requestKind is how decide looks up one kind of request in a policy and
+// saves the answer to a prompt for it.
+type requestKind struct {
+	name string // "net", "env", "tool", "secret", or a path op; shown in prompts and denial notes
+
+	// protected returns the approval of p's protected entry for key, and
+	// match that of its other entries; "" when no entry decides.
+	protected func(p *Policy, key string) Approval
+	match     func(p *Policy, key string) Approval
+	defaults  func(p *Policy) Approval
+	add       func(p *Policy, key string, approval Approval, note string, expires *time.Time)
+}
+
+// decide runs the decision ladder shared by every kind of request. The
+// first of these to allow or deny key decides: the global policy's
+// protected entries, which nothing overrides; "allow for this run"
+// answers; the thought policy's entries, then the global policy's; the
+// thought policy's default, origin, then the global policy's default.
+// Otherwise the approval mode answers, or the user is asked and the
+// answer saved. Called with a.mu held.
+func (a *Approver) decide(k requestKind, key string, origin Approval) (bool, error) {
+	switch k.protected(a.globalPolicy, key) {
+	case ApprovalAllow:
+		return true, nil
+	case ApprovalDeny:
+		a.noteDenied(k.name, key, "protected")
+		return false, nil
+	}
+	if k.match(a.runPolicy, key) == ApprovalAllow {
+		return true, nil
+	}
+	for _, p := range []struct {
+		policy *Policy
+		source string
+	}{{a.thoughtPolicy, "thought"}, {a.globalPolicy, "global"}} {
+		switch k.match(p.policy, key) {
+		case ApprovalAllow:
 			return true, nil
-		}
-		if entry.Approval == ApprovalDeny {
+		case ApprovalDeny:
+			a.noteDenied(k.name, key, p.source)
 			return false, nil
 		}
-		// ApprovalPrompt falls through to prompt
 	}
-
-	// Check global policy
-	if entry := a.globalPolicy.Net.Hosts.MatchHost(host); entry != nil {
-		if entry.Approval == ApprovalAllow {
+	for _, d := range []Approval{k.defaults(a.thoughtPolicy), origin, k.defaults(a.globalPolicy)} {
+		switch d {
+		case ApprovalAllow:
 			return true, nil
-		}
-		if entry.Approval == ApprovalDeny {
+		case ApprovalDeny:
 			return false, nil
 		}
 	}
 
-	// Check defaults
-	if a.thoughtPolicy.Net.Hosts.Default == ApprovalAllow {
-		return true, nil
+	if a.auto != ModePrompt {
+		return a.answer(k.name, key)
 	}
-	if a.thoughtPolicy.Net.Hosts.Default == ApprovalDeny {
-		return false, nil
-	}
-	if a.globalPolicy.Net.Hosts.Default == ApprovalAllow {
-		return true, nil
-	}
-	if a.globalPolicy.Net.Hosts.Default == ApprovalDeny {
-		return false, nil
-	}
-
 	if !a.isTTY {
 		return false, nil
 	}
 
-	decision, err := a.prompt("net", host)
+	decision, note, scoped, err := a.prompt(k.name, key)
 	if err != nil {
 		return false, err
 	}
 
 	switch decision {
+	case promptRun:
+		k.add(a.runPolicy, scoped, ApprovalAllow, "", nil)
+	case promptHour:
+		k.add(a.thoughtPolicy, scoped, ApprovalAllow, note, expiry())
+		a.saveThoughtPolicy()
 	case promptAlways:
-		a.thoughtPolicy.AddHostEntry(host, ApprovalAllow, SourcePrompt)
+		k.add(a.thoughtPolicy, scoped, ApprovalAllow, note, nil)
 		a.saveThoughtPolicy()
 	case promptDeny:
-		a.thoughtPolicy.AddHostEntry(host, ApprovalDeny, SourcePrompt)
+		k.add(a.thoughtPolicy, scoped, ApprovalDeny, note, nil)
 		a.saveThoughtPolicy()
 	}
 
-	return decision == promptAlways || decision == promptOnce, nil
+	return decision.allows(), nil
 }
 
-// ApprovePath checks if a filesystem operation on a path is allowed.
-// The op parameter is one of "read", "write", "delete".
-func (a *Approver) ApprovePath(op, path string) (bool, error) {
-	// SECURITY: Never allow modifying the thought's own policy file
-	if a.thoughtDir != "" {
-		policyPath := filepath.Join(a.thoughtDir, "policy.json")
-		if path == policyPath || strings.HasPrefix(path, policyPath) {
-			return false, nil
-		}
-	}
+var netRequests = requestKind{
+	name:      "net",
+	protected: func(p *Policy, host string) Approval { return p.Net.Hosts.MatchProtected(host).decision() },
+	match:     func(p *Policy, host string) Approval { return p.Net.Hosts.MatchHost(host).decision() },
+	defaults:  func(p *Policy) Approval { return p.Net.Hosts.Default },
+	add: func(p *Policy, host string, approval Approval, note string, expires *time.Time) {
+		e := p.AddHostEntry(host, approval, SourcePrompt)
+		e.Note, e.Expires = note, expires
+	},
+}
 
-	modeChar := opToModeChar(op)
+// ApproveNet checks if network access to a specific host is allowed.
+func (a *Approver) ApproveNet(host string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.decide(netRequests, host, a.originDefaults.Net)
+}
 
-	// Check global protected entries FIRST - these cannot be overridden
-	for _, entry := range a.globalPolicy.Paths.Protected {
-		if pathMatches(entry.Path, path) && hasMode(entry.Mode, modeChar) {
-			if entry.Approval == ApprovalDeny {
-				return false, nil // Protected deny cannot be overridden
+// pathRequests is the requestKind of op ("read", "write", "delete"):
+// entries decide only for the modes they list.
+func pathRequests(op string) requestKind {
+	mode := opToModeChar(op)
+	return requestKind{
+		name: op,
+		protected: func(p *Policy, path string) Approval {
+			for _, entry := range p.Paths.Protected {
+				if pathMatches(entry.Path, path) && hasMode(entry.Mode, mode) && entry.Approval != ApprovalPrompt {
+					return entry.Approval
+				}
 			}
-			if entry.Approval == ApprovalAllow {
-				return true, nil
+			return ""
+		},
+		match: func(p *Policy, path string) Approval {
+			if entry := p.Paths.MatchPath(path); entry != nil && hasMode(entry.Mode, mode) {
+				return entry.Approval
 			}
-		}
+			return ""
+		},
+		defaults: func(p *Policy) Approval { return p.Paths.Default },
+		add: func(p *Policy, path string, approval Approval, note string, expires *time.Time) {
+			// Only the mode requested is granted or denied
+			e := p.AddPathEntry(path, mode, approval, SourcePrompt)
+			e.Note, e.Expires = note, expires
+		},
 	}
+}
 
-	// Check thought policy
-	if entry := a.thoughtPolicy.Paths.MatchPath(path); entry != nil {
-		if hasMode(entry.Mode, modeChar) {
-			if entry.Approval == ApprovalAllow {
-				return true, nil
-			}
-			if entry.Approval == ApprovalDeny {
-				return false, nil
-			}
-		}
+// isPolicyFile reports whether path is, or is under, the thought's own
+// policy file, which scripts may never touch.
+func (a *Approver) isPolicyFile(path string) bool {
+	if a.thoughtDir == "" {
+		return false
 	}
+	policyPath := filepath.Join(a.thoughtDir, "policy.json")
+	return path == policyPath || strings.HasPrefix(path, policyPath)
+}
 
-	// Check regular global policy entries
-	if entry := a.globalPolicy.Paths.MatchPath(path); entry != nil {
-		if hasMode(entry.Mode, modeChar) {
-			if entry.Approval == ApprovalAllow {
-				return true, nil
-			}
-			if entry.Approval == ApprovalDeny {
-				return false, nil
-			}
-		}
-	}
+// ApprovePath checks if a filesystem operation on a path is allowed.
+// The op parameter is one of "read", "write", "delete".
+func (a *Approver) ApprovePath(op, path string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	// Check defaults
-	if a.thoughtPolicy.Paths.Default == ApprovalAllow {
-		return true, nil
-	}
-	if a.thoughtPolicy.Paths.Default == ApprovalDeny {
-		return false, nil
-	}
-	if a.globalPolicy.Paths.Default == ApprovalAllow {
-		return true, nil
-	}
-	if a.globalPolicy.Paths.Default == ApprovalDeny {
+	// SECURITY: Never allow modifying the thought's own policy file
+	if a.isPolicyFile(path) {
 		return false, nil
 	}
+	return a.decide(pathRequests(op), path, a.originDefaults.Paths)
+}
 
-	if !a.isTTY {
-		return false, nil
-	}
+// PathDenied reports whether a saved policy entry explicitly denies op on
+// path. It never prompts, so callers that have already been granted a
+// parent directory can use it to honor deny entries further down the tree.
+func (a *Approver) PathDenied(op, path string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	decision, err := a.prompt(op, path)
-	if err != nil {
-		return false, err
+	if a.isPolicyFile(path) {
+		return true
 	}
-
-	switch decision {
-	case promptAlways:
-		// When approving, grant the specific mode requested
-		a.thoughtPolicy.AddPathEntry(path, modeChar, ApprovalAllow, SourcePrompt)
-		a.saveThoughtPolicy()
-	case promptDeny:
-		a.thoughtPolicy.AddPathEntry(path, modeChar, ApprovalDeny, SourcePrompt)
-		a.saveThoughtPolicy()
+	k := pathRequests(op)
+	if approval := k.protected(a.globalPolicy, path); approval != "" {
+		return approval == ApprovalDeny
 	}
+	for _, p := range []*Policy{a.thoughtPolicy, a.globalPolicy} {
+		switch k.match(p, path) {
+		case ApprovalDeny:
+			return true
+		case ApprovalAllow:
+			return false
+		}
+	}
+	return false
+}
 
-	return decision == promptAlways || decision == promptOnce, nil
+var envRequests = requestKind{
+	name:      "env",
+	protected: func(p *Policy, name string) Approval { return p.Env.MatchProtected(name).decision() },
+	match:     func(p *Policy, name string) Approval { return p.Env.MatchEnv(name).decision() },
+	defaults:  func(p *Policy) Approval { return p.Env.Default },
+	add: func(p *Policy, name string, approval Approval, note string, expires *time.Time) {
+		e := p.AddEnvEntry(name, approval, SourcePrompt)
+		e.Note, e.Expires = note, expires
+	},
 }
 
 // ApproveEnvRead checks if reading an environment variable is allowed.
 func (a *Approver) ApproveEnvRead(varName string) (bool, error) {
-	// Check thought policy
-	if entry := a.thoughtPolicy.Env.MatchEnv(varName); entry != nil {
-		if entry.Approval == ApprovalAllow {
-			return true, nil
-		}
-		if entry.Approval == ApprovalDeny {
-			return false, nil
-		}
-	}
-
-	// Check global policy
-	if entry := a.globalPolicy.Env.MatchEnv(varName); entry != nil {
-		if entry.Approval == ApprovalAllow {
-			return true, nil
-		}
-		if entry.Approval == ApprovalDeny {
-			return false, nil
-		}
-	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.decide(envRequests, varName, a.originDefaults.Env)
+}
 
-	// Check defaults
-	if a.thoughtPolicy.Env.Default == ApprovalAllow {
-		return true, nil
-	}
-	if a.thoughtPolicy.Env.Default == ApprovalDeny {
-		return false, nil
-	}
-	if a.globalPolicy.Env.Default == ApprovalAllow {
-		return true, nil
-	}
-	if a.globalPolicy.Env.Default == ApprovalDeny {
-		return false, nil
-	}
+var toolRequests = requestKind{
+	name:      "tool",
+	protected: func(p *Policy, name string) Approval { return p.Tools.MatchProtected(name).decision() },
+	match:     func(p *Policy, name string) Approval { return p.Tools.MatchTool(name).decision() },
+	defaults:  func(p *Policy) Approval { return p.Tools.Default },
+	add: func(p *Policy, name string, approval Approval, note string, expires *time.Time) {
+		e := p.AddToolEntry(name, approval, SourcePrompt)
+		e.Note, e.Expires = note, expires
+	},
+}
 
-	if !a.isTTY {
-		return false, nil
-	}
+// ApproveTool checks if starting an MCP server (mcp__<server>) or calling
+// one of its tools (mcp__<server>__<tool>) is allowed. Thoughts have no
+// origin default for tools: they prompt unless a policy says otherwise.
+func (a *Approver) ApproveTool(name string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	decision, err := a.prompt("env", varName)
-	if err != nil {
-		return false, err
-	}
+	return a.decide(toolRequests, name, "")
+}
 
-	switch decision {
-	case promptAlways:
-		a.thoughtPolicy.AddEnvEntry(varName, ApprovalAllow, SourcePrompt)
-		a.saveThoughtPolicy()
-	case promptDeny:
-		a.thoughtPolicy.AddEnvEntry(varName, ApprovalDeny, SourcePrompt)
-		a.saveThoughtPolicy()
-	}
+var secretRequests = requestKind{
+	name:      "secret",
+	protected: func(p *Policy, name string) Approval { return p.Secrets.MatchProtected(name).decision() },
+	match:     func(p *Policy, name string) Approval { return p.Secrets.MatchSecret(name).decision() },
+	defaults:  func(p *Policy) Approval { return p.Secrets.Default },
+	add: func(p *Policy, name string, approval Approval, note string, expires *time.Time) {
+		e := p.AddSecretEntry(name, approval, SourcePrompt)
+		e.Note, e.Expires = note, expires
+	},
+}
 
-	return decision == promptAlways || decision == promptOnce, nil
+// ApproveSecret checks if secrets.get may read the named secret from the OS
+// credential store. Like tools, secrets have no origin default.
+func (a *Approver) ApproveSecret(name string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.decide(secretRequests, name, "")
 }
 
 // opToModeChar converts an operation name to a mode character.
@@ -299,25 +459,59 @@ var (
 	detailStyle  = ui.Renderer.NewStyle().Foreground(lipgloss.Color("255"))
 	numberStyle  = ui.Renderer.NewStyle().Foreground(dimColor)
 	commandStyle = ui.Renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("255"))
+	deniedStyle  = ui.Renderer.NewStyle().Foreground(lipgloss.Color("203"))
 )
 
 // approvalModel is a bubbletea model for the approval prompt
 type approvalModel struct {
-	cursor int
-	choice string
-	done   bool
+	cursor      int
+	choice      string
+	done        bool
+	noting      bool   // typing a note for the saved entry
+	note        string // optional note, saved with "hour" and "always" answers
+	scopes      []scope
+	scope       int    // into scopes; changed with tab
+	details     string // see Approver.details
+	showDetails bool   // toggled with d
 }
 
-var choices = []string{"allow", "deny"}
+var choices = []string{"allow-once", "allow-run", "allow-hour", "allow", "deny-once", "deny"}
 
 func (m approvalModel) Init() tea.Cmd {
 	return nil
 }
 
 func (m approvalModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok && m.noting {
+		switch key.Type {
+		case tea.KeyEnter:
+			m.noting = false
+		case tea.KeyEsc:
+			m.noting = false
+			m.note = ""
+		case tea.KeyBackspace:
+			if r := []rune(m.note); len(r) > 0 {
+				m.note = string(r[:len(r)-1])
+			}
+		case tea.KeyCtrlC:
+			m.choice = ""
+			m.done = true
+			return m, tea.Quit
+		case tea.KeyRunes, tea.KeySpace:
+			m.note += string(key.Runes)
+		}
+		return m, nil
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "n":
+			m.noting = true
+		case "d":
+			m.showDetails = !m.showDetails
+		case "tab", "s":
+			m.scope = (m.scope + 1) % len(m.scopes)
 		case "up", "k":
 			if m.cursor > 0 {
 				m.cursor--
@@ -330,12 +524,8 @@ func (m approvalModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.choice = choices[m.cursor]
 			m.done = true
 			return m, tea.Quit
-		case "1":
-			m.choice = "allow"
-			m.done = true
-			return m, tea.Quit
-		case "2":
-			m.choice = "deny"
+		case "1", "2", "3", "4", "5", "6":
+			m.choice = choices[msg.String()[0]-'1']
 			m.done = true
 			return m, tea.Quit
 		case "ctrl+c", "esc":
@@ -364,15 +554,30 @@ func (m approvalModel) View() string {
 		cmd string
 	}
 	options := []option{
-		{"1", "Allow"},
-		{"2", "Deny"},
+		{"1", i18n.T("approval.allow_once")},
+		{"2", i18n.T("approval.allow_run")},
+		{"3", i18n.T("approval.allow_hour")},
+		{"4", i18n.T("approval.allow_always")},
+		{"5", i18n.T("approval.deny_once")},
+		{"6", i18n.T("approval.deny_always")},
 	}
 
 	// Layout: numbers under ◆, commands under NET label
 	// Header is: "\n  ◆ NET  detail"
 	// Options:   "❯ 1 Allow" or "  2 Deny"
 	var b strings.Builder
+	if m.noting {
+		b.WriteString(fmt.Sprintf("\n  %s %s%s\n", numberStyle.Render(i18n.T("approval.note")), detailStyle.Render(m.note), selectedStyle.Render("█")))
+		b.WriteString(fmt.Sprintf("  %s\n", numberStyle.Render(i18n.T("approval.note_keep"))))
+		return b.String()
+	}
 	b.WriteString("\n")
+	if m.showDetails {
+		for _, line := range strings.Split(m.details, "\n") {
+			b.WriteString(fmt.Sprintf("    %s\n", numberStyle.Render(line)))
+		}
+		b.WriteString("\n")
+	}
 	for i, opt := range options {
 		if i == m.cursor {
 			// Selected: amber arrow, bright text
@@ -387,34 +592,79 @@ func (m approvalModel) View() string {
 				unselectedStyle.Render(opt.cmd)))
 		}
 	}
+	detailsHint := i18n.T("approval.details_hint")
+	if m.showDetails {
+		detailsHint = i18n.T("approval.details_hide")
+	}
+	if len(m.scopes) > 1 {
+		b.WriteString(fmt.Sprintf("  %s %s  %s\n",
+			numberStyle.Render(i18n.T("approval.scope")),
+			selectedStyle.Render(m.scopes[m.scope].label),
+			numberStyle.Render("· "+i18n.T("approval.scope_hint"))))
+	}
+	if m.note != "" {
+		b.WriteString(fmt.Sprintf("  %s %s  %s\n", numberStyle.Render(i18n.T("approval.note")), unselectedStyle.Render(m.note), numberStyle.Render("· "+detailsHint)))
+	} else {
+		b.WriteString(fmt.Sprintf("  %s\n", numberStyle.Render(i18n.T("approval.note_hint")+" · "+detailsHint)))
+	}
 	return b.String()
 }
 
-// prompt shows an approval dialog and returns the user's decision.
-func (a *Approver) prompt(label, detail string) (promptDecision, error) {
+// prompt shows an approval dialog and returns the user's decision, the
+// optional note they typed for the policy entry, and the target a
+// remembered decision covers: detail itself or a scope around it.
+func (a *Approver) prompt(label, detail string) (promptDecision, string, string, error) {
+	if a.prompter != nil {
+		decision, note, err := a.prompter.Approve(label, detail, a.activity)
+		if err != nil {
+			return promptDeny, "", detail, err
+		}
+		switch d := promptDecision(decision); d {
+		case promptOnce, promptRun, promptHour, promptAlways, promptDeny:
+			return d, note, detail, nil
+		}
+		return promptDenyOnce, note, detail, nil
+	}
+
 	lock, err := acquirePromptLock()
 	if err != nil {
-		return promptDeny, fmt.Errorf("acquiring prompt lock: %w", err)
+		return promptDeny, "", detail, fmt.Errorf("acquiring prompt lock: %w", err)
 	}
 	defer releasePromptLock(lock)
 
+	if a.ctx.Err() != nil {
+		return promptDeny, "", detail, ErrInterrupted
+	}
+	if ui.Accessible() {
+		return a.promptPlain(label, detail)
+	}
+
 	fmt.Fprintf(os.Stderr, "\n  %s %s  %s\n",
 		markerStyle.Render("◆"),
 		opStyle.Render(strings.ToUpper(label)),
 		detailStyle.Render(truncate(detail, 200)))
+	if a.activity != "" {
+		fmt.Fprintf(os.Stderr, "    %s %s\n",
+			numberStyle.Render(i18n.T("approval.requested_while")),
+			numberStyle.Render(truncate(a.activity, 120)))
+	}
 
 	// Set up bubbletea options
 	opts := []tea.ProgramOption{
 		tea.WithOutput(os.Stderr),
+		tea.WithContext(a.ctx),
 	}
 	if a.ttyInput != nil {
 		opts = append(opts, tea.WithInput(a.ttyInput))
 	}
 
-	p := tea.NewProgram(approvalModel{}, opts...)
+	p := tea.NewProgram(approvalModel{details: a.details(label, detail), scopes: scopesFor(label, detail)}, opts...)
 	finalModel, err := p.Run()
 	if err != nil {
-		return promptDeny, err
+		if a.ctx.Err() != nil {
+			return promptDeny, "", detail, ErrInterrupted
+		}
+		return promptDeny, "", detail, err
 	}
 
 	m := finalModel.(approvalModel)
@@ -422,14 +672,211 @@ func (a *Approver) prompt(label, detail string) (promptDecision, error) {
 		os.Exit(130)
 	}
 
+	target := m.scopes[m.scope].target
 	switch m.choice {
+	case "allow-once":
+		return promptOnce, m.note, target, nil
+	case "allow-run":
+		return promptRun, m.note, target, nil
+	case "allow-hour":
+		return promptHour, m.note, target, nil
 	case "allow":
-		return promptAlways, nil
+		return promptAlways, m.note, target, nil
 	case "deny":
-		return promptDeny, nil
+		return promptDeny, m.note, target, nil
 	default:
-		return promptDeny, nil
+		return promptDenyOnce, m.note, target, nil
+	}
+}
+
+// promptPlain is prompt for accessible mode: the choices as numbered
+// lines, answered by typing a number (and optionally a note) and Enter.
+// A remembered decision with more than one scope asks for the scope next.
+// Nothing is redrawn, so screen readers read it in order.
+func (a *Approver) promptPlain(label, detail string) (promptDecision, string, string, error) {
+	fmt.Fprintf(os.Stderr, "\n%s\n", i18n.T("approval.plain_header", strings.ToUpper(label), truncate(detail, 200)))
+	if a.activity != "" {
+		fmt.Fprintf(os.Stderr, "%s %s\n", i18n.T("approval.requested_while"), truncate(a.activity, 120))
+	}
+	for i, key := range []string{"approval.allow_once", "approval.allow_run", "approval.allow_hour", "approval.allow_always", "approval.deny_once", "approval.deny_always"} {
+		fmt.Fprintf(os.Stderr, "%d. %s\n", i+1, i18n.T(key))
+	}
+	decisions := map[string]promptDecision{"1": promptOnce, "2": promptRun, "3": promptHour, "4": promptAlways, "5": promptDenyOnce, "6": promptDeny}
+	for {
+		fmt.Fprintf(os.Stderr, "%s ", i18n.T("approval.plain_choose"))
+		line, err := a.readLine()
+		if err != nil {
+			return promptDeny, "", detail, err
+		}
+		num, note, _ := strings.Cut(strings.TrimSpace(line), " ")
+		note = strings.TrimSpace(note)
+		switch d, ok := decisions[num]; {
+		case num == "d" || num == "D":
+			fmt.Fprintf(os.Stderr, "%s\n", a.details(label, detail))
+			continue
+		case !ok:
+			fmt.Fprintln(os.Stderr, i18n.T("approval.plain_invalid"))
+			continue
+		case d == promptOnce || d == promptDenyOnce:
+			return d, note, detail, nil
+		default:
+			target, err := a.promptPlainScope(scopesFor(label, detail))
+			return d, note, target, err
+		}
+	}
+}
+
+// promptPlainScope asks which scope a remembered decision covers, when
+// there is more than one.
+func (a *Approver) promptPlainScope(scopes []scope) (string, error) {
+	if len(scopes) == 1 {
+		return scopes[0].target, nil
+	}
+	fmt.Fprintln(os.Stderr, i18n.T("approval.plain_scope"))
+	for i, sc := range scopes {
+		fmt.Fprintf(os.Stderr, "%d. %s\n", i+1, sc.label)
+	}
+	for {
+		fmt.Fprintf(os.Stderr, "%s ", i18n.T("approval.plain_scope_choose", len(scopes)))
+		line, err := a.readLine()
+		if err != nil {
+			return scopes[0].target, err
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(line)); err == nil && n >= 1 && n <= len(scopes) {
+			return scopes[n-1].target, nil
+		}
+	}
+}
+
+// answer decides a request by the approval mode instead of prompting,
+// recording it and telling the user (once per target) what was decided.
+// Under ModePolicy the request fails with an error naming what's missing.
+func (a *Approver) answer(op, target string) (bool, error) {
+	if a.onAuto != nil {
+		a.onAuto(op, target, a.auto)
 	}
+	key := "auto\x00" + op + "\x00" + target
+	if !a.shownDenials[key] {
+		if a.shownDenials == nil {
+			a.shownDenials = make(map[string]bool)
+		}
+		a.shownDenials[key] = true
+		if a.auto == ModeAllow {
+			fmt.Fprintf(os.Stderr, "  %s %s %s\n",
+				opStyle.Render("✓ "+strings.ToUpper(op)),
+				detailStyle.Render(truncate(target, 200)),
+				numberStyle.Render(i18n.T("approval.auto_allowed")))
+		} else {
+			fmt.Fprintf(os.Stderr, "  %s %s %s\n",
+				deniedStyle.Render("✕ "+strings.ToUpper(op)),
+				detailStyle.Render(truncate(target, 200)),
+				numberStyle.Render(i18n.T("approval.auto_denied", string(a.auto))))
+		}
+	}
+	switch a.auto {
+	case ModeAllow:
+		return true, nil
+	case ModePolicy:
+		return false, fmt.Errorf("%s %s: no policy entry allows it, and approvals are policy-only (add one with thought policy)", op, target)
+	}
+	return false, nil
+}
+
+// noteDenied tells the user (once per target) that a saved policy entry
+// blocked an operation, so remembered denials don't fail silently.
+func (a *Approver) noteDenied(op, target, scope string) {
+	key := op + "\x00" + target
+	if a.shownDenials[key] {
+		return
+	}
+	if a.shownDenials == nil {
+		a.shownDenials = make(map[string]bool)
+	}
+	a.shownDenials[key] = true
+	fmt.Fprintf(os.Stderr, "  %s %s %s\n",
+		deniedStyle.Render("✕ "+strings.ToUpper(op)),
+		detailStyle.Render(truncate(target, 200)),
+		numberStyle.Render(i18n.T("approval.denied_by_policy", scope)))
+}
+
+// SetManagedPolicy merges an organization's managed policy into the global
+// policy as protected entries: every path, env, host, tool, and secret
+// entry in it is checked first, ahead of local protected entries and the
+// thought policy, and can't be overridden. Its defaults are ignored.
+func (a *Approver) SetManagedPolicy(m *Policy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var paths []PathEntry
+	for _, e := range append(append([]PathEntry{}, m.Paths.Protected...), m.Paths.Entries...) {
+		e.Source = SourceManaged
+		paths = append(paths, e)
+	}
+	a.globalPolicy.Paths.Protected = append(paths, a.globalPolicy.Paths.Protected...)
+
+	var env []EnvEntry
+	for _, e := range append(append([]EnvEntry{}, m.Env.Protected...), m.Env.Entries...) {
+		e.Source = SourceManaged
+		env = append(env, e)
+	}
+	a.globalPolicy.Env.Protected = append(env, a.globalPolicy.Env.Protected...)
+
+	var hosts []HostEntry
+	for _, e := range append(append([]HostEntry{}, m.Net.Hosts.Protected...), m.Net.Hosts.Entries...) {
+		e.Source = SourceManaged
+		hosts = append(hosts, e)
+	}
+	a.globalPolicy.Net.Hosts.Protected = append(hosts, a.globalPolicy.Net.Hosts.Protected...)
+
+	var tools []ToolEntry
+	for _, e := range append(append([]ToolEntry{}, m.Tools.Protected...), m.Tools.Entries...) {
+		e.Source = SourceManaged
+		tools = append(tools, e)
+	}
+	a.globalPolicy.Tools.Protected = append(tools, a.globalPolicy.Tools.Protected...)
+
+	var secrets []SecretEntry
+	for _, e := range append(append([]SecretEntry{}, m.Secrets.Protected...), m.Secrets.Entries...) {
+		e.Source = SourceManaged
+		secrets = append(secrets, e)
+	}
+	a.globalPolicy.Secrets.Protected = append(secrets, a.globalPolicy.Secrets.Protected...)
+}
+
+// SeedPolicy copies a shared thought's policy into this thought's: its
+// defaults replace the thought's and its entries are added next to the
+// bootstrapped ones, then the result is saved. Callers seed only a thought
+// that had no policy file, so the user's own decisions are never replaced.
+func (a *Approver) SeedPolicy(p *Policy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	t := a.thoughtPolicy
+	if p.Paths.Default != "" {
+		t.Paths.Default = p.Paths.Default
+	}
+	if p.Env.Default != "" {
+		t.Env.Default = p.Env.Default
+	}
+	if p.Net.Hosts.Default != "" {
+		t.Net.Hosts.Default = p.Net.Hosts.Default
+	}
+	if p.Net.Listen.Default != "" {
+		t.Net.Listen.Default = p.Net.Listen.Default
+	}
+	if p.Tools.Default != "" {
+		t.Tools.Default = p.Tools.Default
+	}
+	if p.Secrets.Default != "" {
+		t.Secrets.Default = p.Secrets.Default
+	}
+	t.Paths.Entries = append(t.Paths.Entries, p.Paths.Entries...)
+	t.Env.Entries = append(t.Env.Entries, p.Env.Entries...)
+	t.Net.Hosts.Entries = append(t.Net.Hosts.Entries, p.Net.Hosts.Entries...)
+	t.Net.Listen.Entries = append(t.Net.Listen.Entries, p.Net.Listen.Entries...)
+	t.Tools.Entries = append(t.Tools.Entries, p.Tools.Entries...)
+	t.Secrets.Entries = append(t.Secrets.Entries, p.Secrets.Entries...)
+	a.saveThoughtPolicy()
 }
 
 func (a *Approver) loadPolicies() {
@@ -440,6 +887,8 @@ func (a *Approver) loadPolicies() {
 		} else if !os.IsNotExist(err) {
 			fmt.Fprintf(os.Stderr, "warning: corrupted global policy file, ignoring: %v\n", err)
 		}
+		builtin := BuiltinProtected(userHome(), filepath.Dir(a.globalPolicyPath))
+		a.globalPolicy.Paths.Protected = append(a.globalPolicy.Paths.Protected, builtin...)
 	}
 
 	// Load thought policy (read-write)
@@ -475,6 +924,9 @@ func truncate(s string, max int) string {
 // PromptInput asks the user a free-form question and returns their answer.
 // Returns ErrInterrupted if stdin closes or the TTY is unavailable.
 func (a *Approver) PromptInput(question, defaultValue string) (string, error) {
+	if a.prompter != nil {
+		return a.prompter.Input(question, defaultValue)
+	}
 	if !a.isTTY {
 		return "", errors.New("no TTY available for input")
 	}
@@ -487,31 +939,87 @@ func (a *Approver) PromptInput(question, defaultValue string) (string, error) {
 
 	fmt.Fprintf(os.Stderr, "\n  %s %s  %s\n",
 		markerStyle.Render("◆"),
-		askStyle.Render("ASK"),
+		askStyle.Render(i18n.T("prompt.ask")),
 		detailStyle.Render(truncate(question, 500)))
 
 	if defaultValue != "" {
-		fmt.Fprintf(os.Stderr, "  %s\n", defaultStyle.Render("(default: "+defaultValue+")"))
+		fmt.Fprintf(os.Stderr, "  %s\n", defaultStyle.Render(i18n.T("prompt.default", defaultValue)))
 	}
 	fmt.Fprint(os.Stderr, "  ")
 
-	in := a.ttyInput
-	if in == nil {
-		in = os.Stdin
-	}
-
-	reader := bufio.NewReader(in)
-	line, err := reader.ReadString('\n')
-	if err != nil && line == "" {
-		return "", ErrInterrupted
+	line, err := a.readLine()
+	if err != nil {
+		return "", err
 	}
-	line = strings.TrimRight(line, "\r\n")
 	if line == "" {
 		line = defaultValue
 	}
 	return line, nil
 }
 
+// readLine reads one line from the terminal, without its line ending.
+// It returns ErrInterrupted if input closes or the run is cancelled.
+func (a *Approver) readLine() (string, error) {
+	if a.lines == nil {
+		in := a.ttyInput
+		if in == nil {
+			in = os.Stdin
+		}
+		a.lines = bufio.NewReader(in)
+	}
+
+	// Read in the background so cancellation returns immediately; the
+	// pending read is abandoned along with the process.
+	type readResult struct {
+		line string
+		err  error
+	}
+	ch := make(chan readResult, 1)
+	go func() {
+		line, err := a.lines.ReadString('\n')
+		ch <- readResult{line, err}
+	}()
+
+	select {
+	case r := <-ch:
+		if r.err != nil && r.line == "" {
+			return "", ErrInterrupted
+		}
+		return strings.TrimRight(r.line, "\r\n"), nil
+	case <-a.ctx.Done():
+		fmt.Fprintln(os.Stderr)
+		return "", ErrInterrupted
+	}
+}
+
+// Confirm asks a yes/no question. Anything but "y", "yes", or the
+// locale's own yes (see i18n.YesAnswers) is no.
+func (a *Approver) Confirm(question string) (bool, error) {
+	answer, err := a.PromptInput(question+" "+i18n.T("confirm.hint"), "")
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return slices.Contains(i18n.YesAnswers(), answer), nil
+}
+
+// GrantPath persists an allow entry for a path granted on the command line.
+// The thought's own policy.json can never be granted.
+func (a *Approver) GrantPath(path, mode string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.thoughtDir != "" && strings.HasPrefix(path, filepath.Join(a.thoughtDir, "policy.json")) {
+		return
+	}
+	if entry := a.thoughtPolicy.Paths.MatchPath(path); entry != nil && entry.Path == path &&
+		entry.Approval == ApprovalAllow && entry.Mode == mode {
+		return
+	}
+	a.thoughtPolicy.AddPathEntry(path, mode, ApprovalAllow, SourceCLI)
+	a.saveThoughtPolicy()
+}
+
 // BootstrapDefaults adds default policy entries for workspace and memories.
 // These are auto-approved paths that the thought can always access.
 // Policy.json is explicitly denied to prevent privilege escalation.
@@ -3,7 +3,10 @@ package approval
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestOpToModeChar(t *testing.T) {
@@ -292,6 +295,57 @@ func TestApproveNetWithPolicy(t *testing.T) {
 	}
 }
 
+func TestApproveToolWithPolicy(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+
+	policy := NewPolicy()
+	policy.AddToolEntry("mcp__github", ApprovalAllow, SourceConfig)
+	policy.AddToolEntry("mcp__github__delete_*", ApprovalDeny, SourceConfig)
+	policy.AddToolEntry("mcp__github__*", ApprovalAllow, SourceConfig)
+	policy.Save(filepath.Join(thoughtDir, "policy.json"))
+
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+
+	for name, want := range map[string]bool{
+		"mcp__github":              true,
+		"mcp__github__list_issues": true,
+		"mcp__github__delete_repo": false,
+		"mcp__slack":               false, // no entry, no TTY
+		"mcp__slack__post_message": false,
+	} {
+		if got, _ := approver.ApproveTool(name); got != want {
+			t.Errorf("ApproveTool(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestApproveSecretWithPolicy(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+
+	policy := NewPolicy()
+	policy.AddSecretEntry("GITHUB_ADMIN_TOKEN", ApprovalDeny, SourceConfig)
+	policy.AddSecretEntry("GITHUB_*", ApprovalAllow, SourceConfig)
+	policy.Save(filepath.Join(thoughtDir, "policy.json"))
+
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+
+	for name, want := range map[string]bool{
+		"GITHUB_TOKEN":       true,
+		"GITHUB_ADMIN_TOKEN": false,
+		"OPENAI_API_KEY":     false, // no entry, no TTY
+	} {
+		if got, _ := approver.ApproveSecret(name); got != want {
+			t.Errorf("ApproveSecret(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
 func TestGlobalPolicyProtected(t *testing.T) {
 	dir := t.TempDir()
 	thoughtDir := filepath.Join(dir, "thought")
@@ -319,3 +373,471 @@ func TestGlobalPolicyProtected(t *testing.T) {
 		t.Error("expected /etc/shadow to be denied (protected)")
 	}
 }
+
+func TestManagedPolicy(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	globalPolicyPath := filepath.Join(dir, "global_policy.json")
+	os.MkdirAll(thoughtDir, 0700)
+
+	// A local protected allow must not beat a managed deny
+	globalPolicy := NewPolicy()
+	globalPolicy.Paths.Protected = []PathEntry{{Path: "/secrets", Mode: "r", Approval: ApprovalAllow}}
+	globalPolicy.Save(globalPolicyPath)
+
+	thoughtPolicy := NewPolicy()
+	thoughtPolicy.AddEnvEntry("AWS_*", ApprovalAllow, SourceConfig)
+	thoughtPolicy.AddHostEntry("paste.example.com", ApprovalAllow, SourceConfig)
+	thoughtPolicy.Save(filepath.Join(thoughtDir, "policy.json"))
+
+	managed := NewPolicy()
+	managed.AddPathEntry("/secrets", "rwd", ApprovalDeny, SourceConfig)
+	managed.AddEnvEntry("AWS_SECRET_*", ApprovalDeny, SourceConfig)
+	managed.AddHostEntry("*.example.com", ApprovalDeny, SourceConfig)
+
+	approver := NewApprover(thoughtDir, globalPolicyPath)
+	defer approver.Close()
+	approver.SetManagedPolicy(managed)
+
+	if ok, _ := approver.ApprovePath("read", "/secrets/key"); ok {
+		t.Error("expected /secrets/key to be denied by managed policy")
+	}
+	if ok, _ := approver.ApproveEnvRead("AWS_SECRET_ACCESS_KEY"); ok {
+		t.Error("expected AWS_SECRET_ACCESS_KEY to be denied by managed policy")
+	}
+	if ok, _ := approver.ApproveEnvRead("AWS_REGION"); !ok {
+		t.Error("expected AWS_REGION to be allowed by thought policy")
+	}
+	if ok, _ := approver.ApproveNet("paste.example.com"); ok {
+		t.Error("expected paste.example.com to be denied by managed policy")
+	}
+	if src := approver.globalPolicy.Paths.Protected[0].Source; src != SourceManaged {
+		t.Errorf("managed entry source = %q, want %q", src, SourceManaged)
+	}
+}
+
+func TestSeedPolicy(t *testing.T) {
+	thoughtDir := t.TempDir()
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+	approver.BootstrapDefaults(filepath.Join(thoughtDir, "workspace"), "", "")
+
+	seed := NewPolicy()
+	seed.Net.Hosts.Default = ApprovalDeny
+	seed.AddHostEntry("api.weather.gov", ApprovalAllow, SourceConfig)
+	approver.SeedPolicy(seed)
+
+	saved, err := LoadPolicy(filepath.Join(thoughtDir, "policy.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Net.Hosts.Default != ApprovalDeny || len(saved.Net.Hosts.Entries) != 1 {
+		t.Errorf("seeded net policy = %+v", saved.Net.Hosts)
+	}
+	// Bootstrapped entries survive (workspace + policy.json deny)
+	if len(saved.Paths.Entries) != 2 {
+		t.Errorf("path entries = %+v, want the 2 bootstrapped ones", saved.Paths.Entries)
+	}
+}
+
+type fakePrompter struct {
+	decision string
+	input    string // answer to input prompts; "" = echo the question
+	asked    []string
+}
+
+func (f *fakePrompter) Approve(kind, target, activity string) (string, string, error) {
+	f.asked = append(f.asked, kind+" "+target+" ("+activity+")")
+	return f.decision, "from ci", nil
+}
+
+func (f *fakePrompter) Input(question, defaultValue string) (string, error) {
+	f.asked = append(f.asked, question)
+	if f.input != "" {
+		return f.input, nil
+	}
+	return "answer to " + question, nil
+}
+
+func TestPrompter(t *testing.T) {
+	thoughtDir := t.TempDir()
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+	prompter := &fakePrompter{decision: "always"}
+	approver.SetPrompter(prompter)
+	approver.SetActivity("fetching forecast", "")
+
+	if ok, err := approver.ApproveNet("api.weather.gov"); !ok || err != nil {
+		t.Fatalf("ApproveNet = %v, %v; want allowed", ok, err)
+	}
+	// "always" is saved, so the second check doesn't ask again
+	approver.ApproveNet("api.weather.gov")
+	if len(prompter.asked) != 1 || prompter.asked[0] != "net api.weather.gov (fetching forecast)" {
+		t.Errorf("prompts = %v", prompter.asked)
+	}
+	saved, _ := LoadPolicy(filepath.Join(thoughtDir, "policy.json"))
+	if e := saved.Net.Hosts.MatchHost("api.weather.gov"); e == nil || e.Note != "from ci" {
+		t.Errorf("saved host entry = %+v", e)
+	}
+
+	// Unknown decisions deny without saving
+	prompter.decision = "maybe"
+	if ok, _ := approver.ApproveEnvRead("SECRET"); ok {
+		t.Error("unknown decision allowed env access")
+	}
+	if answer, _ := approver.PromptInput("name?", ""); answer != "answer to name?" {
+		t.Errorf("PromptInput = %q", answer)
+	}
+}
+
+func TestRunAndHourApprovals(t *testing.T) {
+	thoughtDir := t.TempDir()
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+	prompter := &fakePrompter{decision: "run"}
+	approver.SetPrompter(prompter)
+
+	// "run" lasts for this Approver only and is never saved
+	for range 2 {
+		if ok, err := approver.ApproveEnvRead("API_KEY"); !ok || err != nil {
+			t.Fatalf("ApproveEnvRead = %v, %v; want allowed", ok, err)
+		}
+	}
+	if len(prompter.asked) != 1 {
+		t.Errorf("prompts = %v, want one", prompter.asked)
+	}
+	if saved, _ := LoadPolicy(filepath.Join(thoughtDir, "policy.json")); saved.Env.MatchEnv("API_KEY") != nil {
+		t.Error("run approval was saved")
+	}
+	again := NewApprover(thoughtDir, "")
+	defer again.Close()
+	if again.runPolicy.Env.MatchEnv("API_KEY") != nil {
+		t.Error("run approval outlived its Approver")
+	}
+
+	// "hour" is saved with an expiry
+	prompter.decision = "hour"
+	if ok, _ := approver.ApprovePath("write", "/tmp/out"); !ok {
+		t.Fatal("hour approval denied")
+	}
+	saved, _ := LoadPolicy(filepath.Join(thoughtDir, "policy.json"))
+	e := saved.Paths.MatchPath("/tmp/out")
+	if e == nil || e.Expires == nil || e.Note != "from ci" {
+		t.Fatalf("saved path entry = %+v", e)
+	}
+	if d := time.Until(*e.Expires); d <= 59*time.Minute || d > time.Hour {
+		t.Errorf("entry expires in %s, want an hour", d)
+	}
+
+	// Once it expires, the request prompts again
+	past := time.Now().Add(-time.Second)
+	approver.thoughtPolicy.Paths.MatchPath("/tmp/out").Expires = &past
+	prompter.decision = "deny-once"
+	if ok, _ := approver.ApprovePath("write", "/tmp/out"); ok {
+		t.Error("expired entry still allowed")
+	}
+	if len(prompter.asked) != 3 {
+		t.Errorf("prompts = %v, want three", prompter.asked)
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	thoughtDir := t.TempDir()
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+	prompter := &fakePrompter{decision: "always"}
+	approver.SetPrompter(prompter)
+	approver.thoughtPolicy.AddHostEntry("denied.example", ApprovalDeny, SourceCLI)
+
+	var recorded []string
+	approver.SetMode(ModeAllow, func(kind, target string, mode Mode) {
+		recorded = append(recorded, kind+" "+target+" "+string(mode))
+	})
+	if ok, err := approver.ApproveNet("api.weather.gov"); !ok || err != nil {
+		t.Errorf("allow mode: ApproveNet = %v, %v", ok, err)
+	}
+	// Policy still decides first
+	if ok, _ := approver.ApproveNet("denied.example"); ok {
+		t.Error("allow mode overrode a deny entry")
+	}
+
+	approver.SetMode(ModeDeny, nil)
+	if ok, err := approver.ApproveEnvRead("HOME"); ok || err != nil {
+		t.Errorf("deny mode: ApproveEnvRead = %v, %v", ok, err)
+	}
+	approver.SetMode(ModePolicy, nil)
+	if ok, err := approver.ApproveTool("mcp__github"); ok || err == nil || !strings.Contains(err.Error(), "policy-only") {
+		t.Errorf("policy mode: ApproveTool = %v, %v; want a policy-only error", ok, err)
+	}
+
+	if len(prompter.asked) != 0 {
+		t.Errorf("prompted despite a mode: %v", prompter.asked)
+	}
+	if len(recorded) != 1 || recorded[0] != "net api.weather.gov allow" {
+		t.Errorf("recorded = %v", recorded)
+	}
+	saved, _ := LoadPolicy(filepath.Join(thoughtDir, "policy.json"))
+	if saved != nil && saved.Net.Hosts.MatchHost("api.weather.gov") != nil {
+		t.Error("allow mode saved a policy entry")
+	}
+
+	for in, want := range map[string]Mode{"": ModePrompt, "prompt": ModePrompt, "Allow": ModeAllow, "policy": ModePolicy} {
+		if got, err := ParseMode(in); got != want || err != nil {
+			t.Errorf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("yes"); err == nil {
+		t.Error("ParseMode(yes) succeeded")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	approver := NewApprover(t.TempDir(), "")
+	defer approver.Close()
+	prompter := &fakePrompter{}
+	approver.SetPrompter(prompter)
+
+	for answer, want := range map[string]bool{"y": true, " Yes ": true, "n": false, "sure": false} {
+		prompter.input = answer
+		if got, err := approver.Confirm("Save it?"); got != want || err != nil {
+			t.Errorf("Confirm with %q = %v, %v; want %v", answer, got, err, want)
+		}
+	}
+	if prompter.asked[0] != "Save it? [y/N]" {
+		t.Errorf("question = %q", prompter.asked[0])
+	}
+}
+
+func TestPromptPlain(t *testing.T) {
+	approver := NewApprover(t.TempDir(), "")
+	defer approver.Close()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	approver.ttyInput = r
+
+	// Invalid answers ask again; text after the number is the note
+	w.WriteString("allow\n4  build cache \n")
+	if d, note, target, err := approver.promptPlain("net", "example.com"); d != promptAlways || note != "build cache" || target != "example.com" || err != nil {
+		t.Errorf("promptPlain = %q, %q, %q, %v; want always with a note", d, note, target, err)
+	}
+	w.WriteString("5\n")
+	if d, _, _, _ := approver.promptPlain("net", "example.com"); d != promptDenyOnce {
+		t.Errorf("promptPlain = %q, want deny-once", d)
+	}
+	// A remembered answer with a wider scope on offer asks for the scope
+	w.WriteString("2\n9\n2\n")
+	if d, _, target, _ := approver.promptPlain("net", "api.example.com"); d != promptRun || target != "*.example.com" {
+		t.Errorf("promptPlain = %q, %q; want run for *.example.com", d, target)
+	}
+	w.Close()
+	if _, _, _, err := approver.promptPlain("net", "example.com"); err != ErrInterrupted {
+		t.Errorf("promptPlain after input closed = %v, want ErrInterrupted", err)
+	}
+}
+
+func TestScopesFor(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		label, target string
+		want          []string
+	}{
+		{"net", "api.example.com", []string{"api.example.com", "*.example.com"}},
+		{"net", "example.com", []string{"example.com"}},
+		{"net", "bbc.co.uk", []string{"bbc.co.uk"}},
+		{"net", "news.bbc.co.uk", []string{"news.bbc.co.uk", "*.bbc.co.uk"}},
+		{"net", "someone.github.io", []string{"someone.github.io"}},
+		{"net", "192.168.1.10", []string{"192.168.1.10"}},
+		{"env", "AWS_SECRET_KEY", []string{"AWS_SECRET_KEY", "AWS_*"}},
+		{"env", "HOME", []string{"HOME"}},
+		{"secret", "GITHUB_TOKEN", []string{"GITHUB_TOKEN", "GITHUB_*"}},
+		{"tool", "mcp__github__create_issue", []string{"mcp__github__create_issue", "mcp__github__*"}},
+		{"tool", "mcp__github", []string{"mcp__github"}},
+		{"read", filepath.Join(dir, "data", "a.csv"), []string{filepath.Join(dir, "data", "a.csv"), filepath.Join(dir, "data")}},
+		{"read", "/etc", []string{"/etc"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, sc := range scopesFor(tt.label, tt.target) {
+			got = append(got, sc.target)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("scopesFor(%s, %s) = %v, want %v", tt.label, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestDetails(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+	policy := NewPolicy()
+	policy.AddHostEntry("api.example.com", ApprovalPrompt, SourceConfig)
+	policy.AddHostEntry("*.example.com", ApprovalDeny, SourcePrompt)
+	policy.AddHostEntry("cdn.example.org", ApprovalAllow, SourcePrompt).Note = "images"
+	policy.AddHostEntry("raw.github.com", ApprovalAllow, SourcePrompt)
+	policy.AddPathEntry("/data/in", "r", ApprovalAllow, SourcePrompt)
+	policy.AddPathEntry("/data/out.txt", "w", ApprovalAllow, SourceDefault)
+	policy.Save(filepath.Join(thoughtDir, "policy.json"))
+
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+	approver.SetActivity("fetching", "const a = 1\nnet.fetch('https://api.example.com/v1')\n")
+
+	matches, similar := approver.relatedEntries("net", "api.example.com")
+	if len(matches) != 2 || matches[0].approval != ApprovalPrompt || matches[1].pattern != "*.example.com" || len(similar) != 0 {
+		t.Errorf("net entries = %v, similar %v", matches, similar)
+	}
+	if _, similar := approver.relatedEntries("net", "img.example.org:443"); len(similar) != 1 || similar[0].note != "images" {
+		t.Errorf("similar hosts = %v", similar)
+	}
+	// Defaults from bootstrap aren't decisions
+	if matches, similar := approver.relatedEntries("write", "/data/new.txt"); len(matches) != 0 || len(similar) != 1 || similar[0].pattern != "/data/in" {
+		t.Errorf("path entries = %v, similar %v", matches, similar)
+	}
+
+	d := approver.details("net", "api.example.com")
+	if !strings.Contains(d, "fetching") || !strings.Contains(d, "   2  net.fetch") || strings.Contains(d, "const a") {
+		t.Errorf("details = %s", d)
+	}
+	if got := codeMentions("a\nb\n", []string{"zzz"}); len(got) != 4 || got[1] != "   1  a" {
+		t.Errorf("codeMentions without a match = %q", got)
+	}
+}
+
+func TestOriginDefaults(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+
+	policy := NewPolicy()
+	policy.AddHostEntry("api.github.com", ApprovalAllow, SourceConfig)
+	policy.Save(filepath.Join(thoughtDir, "policy.json"))
+
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+	approver.SetOriginDefaults(OriginDefaults{Net: ApprovalDeny, Env: ApprovalAllow})
+
+	// Explicit entries still win over origin defaults
+	approved, _ := approver.ApproveNet("api.github.com")
+	if !approved {
+		t.Error("expected api.github.com to be approved by policy entry")
+	}
+
+	approved, _ = approver.ApproveNet("example.com")
+	if approved {
+		t.Error("expected example.com to be denied by origin default")
+	}
+
+	approved, _ = approver.ApproveEnvRead("HOME")
+	if !approved {
+		t.Error("expected HOME to be approved by origin default")
+	}
+}
+
+func TestGrantPath(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+
+	approver.GrantPath("/data/project", "rwd")
+	approver.GrantPath("/data/project", "rwd") // duplicate is ignored
+	approver.GrantPath(filepath.Join(thoughtDir, "policy.json"), "rwd")
+
+	policy, err := LoadPolicy(filepath.Join(thoughtDir, "policy.json"))
+	if err != nil {
+		t.Fatalf("LoadPolicy error: %v", err)
+	}
+	if len(policy.Paths.Entries) != 1 {
+		t.Fatalf("entries = %d, want 1", len(policy.Paths.Entries))
+	}
+	entry := policy.Paths.Entries[0]
+	if entry.Path != "/data/project" || entry.Mode != "rwd" || entry.Source != SourceCLI {
+		t.Errorf("entry = %+v, want /data/project rwd cli", entry)
+	}
+}
+
+func TestPathDenied(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+
+	policy := NewPolicy()
+	policy.AddPathEntry("/data", "r", ApprovalAllow, SourceConfig)
+	policy.AddPathEntry("/data/secret", "r", ApprovalDeny, SourceConfig)
+	policy.Save(filepath.Join(thoughtDir, "policy.json"))
+
+	approver := NewApprover(thoughtDir, "")
+	defer approver.Close()
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/public", false},
+		{"/data/secret", true},
+		{"/data/secret/keys", true},
+		{"/elsewhere", false}, // no entry: not explicitly denied
+		{filepath.Join(thoughtDir, "policy.json"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := approver.PathDenied("list", tt.path); got != tt.want {
+				t.Errorf("PathDenied(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuiltinProtected(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	home, _ = filepath.EvalSymlinks(home)
+	dataHome := filepath.Join(t.TempDir(), ".thinkingscript")
+	os.MkdirAll(dataHome, 0700)
+	globalPolicyPath := filepath.Join(dataHome, "policy.json")
+	knownHosts := filepath.Join(home, ".ssh", "known_hosts")
+
+	// Even with every prompt answered yes, the built-in denies hold
+	approver := NewApprover(t.TempDir(), globalPolicyPath)
+	approver.SetMode(ModeAllow, nil)
+	for _, path := range []string{filepath.Join(home, ".ssh", "id_ed25519"), filepath.Join(home, ".aws", "credentials"), filepath.Join(dataHome, "agents", "anthropic.json")} {
+		if ok, _ := approver.ApprovePath("read", path); ok {
+			t.Errorf("read %s was allowed", path)
+		}
+	}
+	if ok, _ := approver.ApprovePath("read", filepath.Join(home, "notes.txt")); !ok {
+		t.Error("an unprotected path should reach the prompt")
+	}
+	if paths := approver.ProtectedPaths(); !slices.Contains(paths, filepath.Join(home, ".ssh")) {
+		t.Errorf("ProtectedPaths() = %v, want ~/.ssh", paths)
+	}
+	approver.Close()
+
+	// A protected allow in the global policy overrides them
+	global := NewPolicy()
+	global.Paths.Protected = []PathEntry{{Path: knownHosts, Mode: "r", Approval: ApprovalAllow, Source: SourceCLI}}
+	global.Save(globalPolicyPath)
+	approver = NewApprover(t.TempDir(), globalPolicyPath)
+	defer approver.Close()
+	approver.SetMode(ModeDeny, nil)
+	if ok, _ := approver.ApprovePath("read", knownHosts); !ok {
+		t.Error("the global protected allow should override the built-in deny")
+	}
+	if approver.PathDenied("read", knownHosts) {
+		t.Error("PathDenied should agree with the override")
+	}
+	if !approver.PathDenied("read", filepath.Join(home, ".ssh", "id_ed25519")) {
+		t.Error("the rest of ~/.ssh should stay denied")
+	}
+
+	if BuiltinProtectedPath(filepath.Join(home, "notes.txt"), home, dataHome) != nil {
+		t.Error("BuiltinProtectedPath matched an unprotected path")
+	}
+}
@@ -0,0 +1,256 @@
+package approval
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/i18n"
+)
+
+// Limits on the details view, so it fits on a screen next to the choices
+const (
+	detailCodeLines = 8 // lines of the running code
+	detailSimilar   = 5 // similar earlier decisions
+)
+
+// detailEntry is a policy entry of any kind, for the details view.
+type detailEntry struct {
+	scope    string // protected, thought, or global
+	pattern  string // the path, env var, or host
+	mode     string // paths only
+	approval Approval
+	source   Source
+	created  time.Time
+	note     string
+}
+
+func (e detailEntry) String() string {
+	s := fmt.Sprintf("%-9s %-6s", e.scope, e.approval)
+	if e.mode != "" {
+		s += " " + e.mode
+	}
+	s += "  " + e.pattern
+	var about []string
+	if e.note != "" {
+		about = append(about, e.note)
+	}
+	if e.source != "" {
+		about = append(about, string(e.source))
+	}
+	if !e.created.IsZero() {
+		about = append(about, e.created.Local().Format("2006-01-02"))
+	}
+	if len(about) > 0 {
+		s += "  (" + strings.Join(about, ", ") + ")"
+	}
+	return s
+}
+
+// details describes a request for the prompt's details view: the whole
+// target and activity, the lines of the running code that mention the
+// target, the policy entries that apply to it, and earlier decisions about
+// similar targets. label is "net", "env", "tool", "secret", or a path
+// operation.
+func (a *Approver) details(label, target string) string {
+	var b strings.Builder
+	section := func(title string) {
+		fmt.Fprintf(&b, "%s\n", title)
+	}
+	section(i18n.T("approval.details_target"))
+	fmt.Fprintf(&b, "  %s %s\n", strings.ToUpper(label), target)
+	if a.activity != "" {
+		fmt.Fprintf(&b, "  %s %s\n", i18n.T("approval.requested_while"), a.activity)
+	}
+
+	if lines := codeMentions(a.code, needles(label, target)); len(lines) > 0 {
+		section(i18n.T("approval.details_code"))
+		for _, l := range lines {
+			fmt.Fprintf(&b, "  %s\n", l)
+		}
+	}
+
+	matches, similar := a.relatedEntries(label, target)
+	section(i18n.T("approval.details_entries"))
+	if len(matches) == 0 {
+		fmt.Fprintf(&b, "  %s\n", i18n.T("approval.details_no_entries"))
+	}
+	for _, e := range matches {
+		fmt.Fprintf(&b, "  %s\n", e)
+	}
+	if len(similar) > 0 {
+		section(i18n.T("approval.details_similar"))
+		for _, e := range similar {
+			fmt.Fprintf(&b, "  %s\n", e)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// needles returns what to look for in the code to find the lines behind a
+// request: the host, the variable name, or the path and its file name.
+func needles(label, target string) []string {
+	if label != "net" && label != "env" && label != "tool" && label != "secret" {
+		if base := filepath.Base(target); len(base) > 2 && base != target {
+			return []string{target, base}
+		}
+	}
+	return []string{target}
+}
+
+// codeMentions returns the numbered lines of code containing any of
+// needles, or the first lines when none do (the target may be built at run
+// time).
+func codeMentions(code string, needles []string) []string {
+	if strings.TrimSpace(code) == "" {
+		return nil
+	}
+	lines := strings.Split(code, "\n")
+	numbered := func(i int) string {
+		return fmt.Sprintf("%4d  %s", i+1, truncate(strings.TrimRight(lines[i], " \t\r"), 160))
+	}
+	var out []string
+	for i, line := range lines {
+		if slices.ContainsFunc(needles, func(n string) bool { return strings.Contains(line, n) }) {
+			out = append(out, numbered(i))
+			if len(out) == detailCodeLines {
+				break
+			}
+		}
+	}
+	if len(out) > 0 {
+		return out
+	}
+	out = append(out, i18n.T("approval.details_code_start"))
+	for i := range lines[:min(len(lines), detailCodeLines)] {
+		out = append(out, numbered(i))
+	}
+	return out
+}
+
+// relatedEntries returns the policy entries that match target (whatever
+// their mode or approval) and, newest first, the entries for similar
+// targets: hosts in the same domain, paths in the same directory,
+// variables or secrets with the same prefix, or tools of the same MCP
+// server. Bootstrap defaults aren't decisions, so they're left out of the
+// similar ones.
+func (a *Approver) relatedEntries(label, target string) (matches, similar []detailEntry) {
+	for _, e := range a.allEntries(label) {
+		switch {
+		case entryMatches(label, e.pattern, target):
+			matches = append(matches, e)
+		case e.source != SourceDefault && entrySimilar(label, e.pattern, target):
+			similar = append(similar, e)
+		}
+	}
+	slices.SortStableFunc(similar, func(x, y detailEntry) int { return y.created.Compare(x.created) })
+	return matches, similar[:min(len(similar), detailSimilar)]
+}
+
+// allEntries lists the global policy's protected entries, then the
+// thought's entries, then the global ones, for label's kind of request.
+func (a *Approver) allEntries(label string) []detailEntry {
+	var out []detailEntry
+	add := func(scope string, p *Policy, protected bool) {
+		switch label {
+		case "net":
+			entries := p.Net.Hosts.Entries
+			if protected {
+				entries = p.Net.Hosts.Protected
+			}
+			for _, e := range entries {
+				out = append(out, detailEntry{scope, e.Host, "", e.Approval, e.Source, e.Created, e.Note})
+			}
+		case "env":
+			entries := p.Env.Entries
+			if protected {
+				entries = p.Env.Protected
+			}
+			for _, e := range entries {
+				out = append(out, detailEntry{scope, e.Name, "", e.Approval, e.Source, e.Created, e.Note})
+			}
+		case "tool":
+			entries := p.Tools.Entries
+			if protected {
+				entries = p.Tools.Protected
+			}
+			for _, e := range entries {
+				out = append(out, detailEntry{scope, e.Tool, "", e.Approval, e.Source, e.Created, e.Note})
+			}
+		case "secret":
+			entries := p.Secrets.Entries
+			if protected {
+				entries = p.Secrets.Protected
+			}
+			for _, e := range entries {
+				out = append(out, detailEntry{scope, e.Name, "", e.Approval, e.Source, e.Created, e.Note})
+			}
+		default:
+			entries := p.Paths.Entries
+			if protected {
+				entries = p.Paths.Protected
+			}
+			for _, e := range entries {
+				out = append(out, detailEntry{scope, e.Path, e.Mode, e.Approval, e.Source, e.Created, e.Note})
+			}
+		}
+	}
+	add("protected", a.globalPolicy, true)
+	add("thought", a.thoughtPolicy, false)
+	add("global", a.globalPolicy, false)
+	return out
+}
+
+func entryMatches(label, pattern, target string) bool {
+	switch label {
+	case "net":
+		return hostMatches(pattern, target)
+	case "env", "tool", "secret":
+		return envMatches(pattern, target)
+	default:
+		return pathMatches(pattern, target)
+	}
+}
+
+func entrySimilar(label, pattern, target string) bool {
+	switch label {
+	case "net":
+		d := domain(strings.TrimPrefix(pattern, "*."))
+		return d != "" && d == domain(target)
+	case "env", "secret":
+		prefix, _, _ := strings.Cut(strings.TrimSuffix(pattern, "*"), "_")
+		want, _, _ := strings.Cut(target, "_")
+		return prefix != "" && prefix == want
+	case "tool":
+		// Same MCP server: mcp__<server>
+		server := func(name string) string {
+			parts := strings.SplitN(strings.TrimSuffix(name, "*"), "__", 3)
+			if len(parts) < 2 {
+				return ""
+			}
+			return parts[0] + "__" + parts[1]
+		}
+		return server(pattern) != "" && server(pattern) == server(target)
+	default:
+		return pathMatches(filepath.Dir(target), pattern) || pathMatches(filepath.Dir(pattern), target)
+	}
+}
+
+// domain returns the last two labels of host (api.github.com → github.com),
+// or "" for IP addresses and single-label hosts.
+func domain(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return ""
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -28,14 +29,19 @@ const (
 	SourcePrompt  Source = "prompt"  // user answered a prompt
 	SourceConfig  Source = "config"  // manually edited
 	SourceCLI     Source = "cli"     // added via thought policy command
+	SourceManaged Source = "managed" // from the organization's managed policy
+	SourceImport  Source = "import"  // from thought policy import (a shared file or template)
+	SourceBuiltin Source = "builtin" // built-in protected deny (BuiltinProtected), never saved
 )
 
 // Policy represents the complete policy file.
 type Policy struct {
-	Version int        `json:"version"`
-	Paths   PathPolicy `json:"paths"`
-	Env     EnvPolicy  `json:"env"`
-	Net     NetPolicy  `json:"net"`
+	Version int          `json:"version"`
+	Paths   PathPolicy   `json:"paths"`
+	Env     EnvPolicy    `json:"env"`
+	Net     NetPolicy    `json:"net"`
+	Tools   ToolPolicy   `json:"tools"`
+	Secrets SecretPolicy `json:"secrets"`
 }
 
 // PathPolicy controls filesystem access.
@@ -47,11 +53,13 @@ type PathPolicy struct {
 
 // PathEntry represents a single path permission.
 type PathEntry struct {
-	Path     string    `json:"path"`
-	Mode     string    `json:"mode"` // combination of r, w, d
-	Approval Approval  `json:"approval"`
-	Source   Source    `json:"source,omitempty"`
-	Created  time.Time `json:"created,omitempty"`
+	Path     string     `json:"path"`
+	Mode     string     `json:"mode"` // combination of r, w, d
+	Approval Approval   `json:"approval"`
+	Source   Source     `json:"source,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Note     string     `json:"note,omitempty"`    // why the entry exists, e.g. "for weather API"
+	Expires  *time.Time `json:"expires,omitempty"` // nil = never; set by "allow for 1 hour" answers
 }
 
 // HasRead returns true if mode includes read permission.
@@ -71,16 +79,19 @@ func (e PathEntry) HasDelete() bool {
 
 // EnvPolicy controls environment variable access.
 type EnvPolicy struct {
-	Default Approval   `json:"default"`
-	Entries []EnvEntry `json:"entries"`
+	Default   Approval   `json:"default"`
+	Entries   []EnvEntry `json:"entries"`
+	Protected []EnvEntry `json:"protected,omitempty"` // can't be overridden by thought policy
 }
 
 // EnvEntry represents a single env var permission.
 type EnvEntry struct {
-	Name     string    `json:"name"` // supports wildcards like AWS_*
-	Approval Approval  `json:"approval"`
-	Source   Source    `json:"source,omitempty"`
-	Created  time.Time `json:"created,omitempty"`
+	Name     string     `json:"name"` // supports wildcards like AWS_*
+	Approval Approval   `json:"approval"`
+	Source   Source     `json:"source,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Note     string     `json:"note,omitempty"`    // why the entry exists, e.g. "for weather API"
+	Expires  *time.Time `json:"expires,omitempty"` // nil = never; set by "allow for 1 hour" answers
 }
 
 // NetPolicy controls network access.
@@ -91,16 +102,19 @@ type NetPolicy struct {
 
 // HostPolicy controls outbound connections.
 type HostPolicy struct {
-	Default Approval    `json:"default"`
-	Entries []HostEntry `json:"entries"`
+	Default   Approval    `json:"default"`
+	Entries   []HostEntry `json:"entries"`
+	Protected []HostEntry `json:"protected,omitempty"` // can't be overridden by thought policy
 }
 
 // HostEntry represents a single host permission.
 type HostEntry struct {
-	Host     string    `json:"host"` // supports wildcards like *.github.com
-	Approval Approval  `json:"approval"`
-	Source   Source    `json:"source,omitempty"`
-	Created  time.Time `json:"created,omitempty"`
+	Host     string     `json:"host"` // supports wildcards like *.github.com
+	Approval Approval   `json:"approval"`
+	Source   Source     `json:"source,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Note     string     `json:"note,omitempty"`    // why the entry exists, e.g. "for weather API"
+	Expires  *time.Time `json:"expires,omitempty"` // nil = never; set by "allow for 1 hour" answers
 }
 
 // ListenPolicy controls inbound connections (port binding).
@@ -115,6 +129,43 @@ type ListenEntry struct {
 	Approval Approval  `json:"approval"`
 	Source   Source    `json:"source,omitempty"`
 	Created  time.Time `json:"created,omitempty"`
+	Note     string    `json:"note,omitempty"` // why the entry exists, e.g. "for weather API"
+}
+
+// ToolPolicy controls MCP servers: starting each server (entries named
+// mcp__<server>) and calling its tools (mcp__<server>__<tool>).
+type ToolPolicy struct {
+	Default   Approval    `json:"default"`
+	Entries   []ToolEntry `json:"entries"`
+	Protected []ToolEntry `json:"protected,omitempty"` // can't be overridden by thought policy
+}
+
+// ToolEntry represents a single tool permission.
+type ToolEntry struct {
+	Tool     string     `json:"tool"` // supports wildcards like mcp__github__*
+	Approval Approval   `json:"approval"`
+	Source   Source     `json:"source,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Note     string     `json:"note,omitempty"`    // why the entry exists, e.g. "for weather API"
+	Expires  *time.Time `json:"expires,omitempty"` // nil = never; set by "allow for 1 hour" answers
+}
+
+// SecretPolicy controls secrets.get, which reads secrets from the OS
+// credential store.
+type SecretPolicy struct {
+	Default   Approval      `json:"default"`
+	Entries   []SecretEntry `json:"entries"`
+	Protected []SecretEntry `json:"protected,omitempty"` // can't be overridden by thought policy
+}
+
+// SecretEntry represents a single secret permission.
+type SecretEntry struct {
+	Name     string     `json:"name"` // supports wildcards like GITHUB_*
+	Approval Approval   `json:"approval"`
+	Source   Source     `json:"source,omitempty"`
+	Created  time.Time  `json:"created,omitempty"`
+	Note     string     `json:"note,omitempty"`    // why the entry exists, e.g. "for weather API"
+	Expires  *time.Time `json:"expires,omitempty"` // nil = never; set by "allow for 1 hour" answers
 }
 
 // NewPolicy creates an empty policy with defaults.
@@ -139,6 +190,14 @@ func NewPolicy() *Policy {
 				Entries: []ListenEntry{},
 			},
 		},
+		Tools: ToolPolicy{
+			Default: ApprovalPrompt,
+			Entries: []ToolEntry{},
+		},
+		Secrets: SecretPolicy{
+			Default: ApprovalPrompt,
+			Entries: []SecretEntry{},
+		},
 	}
 }
 
@@ -151,7 +210,11 @@ func LoadPolicy(path string) (*Policy, error) {
 		}
 		return nil, err
 	}
+	return ParsePolicy(data)
+}
 
+// ParsePolicy decodes a policy from JSON.
+func ParsePolicy(data []byte) (*Policy, error) {
 	var policy Policy
 	if err := json.Unmarshal(data, &policy); err != nil {
 		return nil, err
@@ -170,6 +233,12 @@ func LoadPolicy(path string) (*Policy, error) {
 	if policy.Net.Listen.Entries == nil {
 		policy.Net.Listen.Entries = []ListenEntry{}
 	}
+	if policy.Tools.Entries == nil {
+		policy.Tools.Entries = []ToolEntry{}
+	}
+	if policy.Secrets.Entries == nil {
+		policy.Secrets.Entries = []SecretEntry{}
+	}
 
 	return &policy, nil
 }
@@ -179,6 +248,7 @@ func (p *Policy) Save(path string) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
 		return err
 	}
+	p.dropExpired(time.Now())
 
 	data, err := json.MarshalIndent(p, "", "  ")
 	if err != nil {
@@ -188,14 +258,35 @@ func (p *Policy) Save(path string) error {
 	return os.WriteFile(path, data, 0600)
 }
 
-// MatchPath finds the best matching path entry for the given path.
-// Returns nil if no entry matches.
+// expired reports whether an entry that expires at t has expired by now.
+// A nil t never expires.
+func expired(t *time.Time, now time.Time) bool {
+	return t != nil && !now.Before(*t)
+}
+
+// dropExpired removes time-limited entries that have expired.
+func (p *Policy) dropExpired(now time.Time) {
+	p.Paths.Entries = slices.DeleteFunc(p.Paths.Entries, func(e PathEntry) bool { return expired(e.Expires, now) })
+	p.Env.Entries = slices.DeleteFunc(p.Env.Entries, func(e EnvEntry) bool { return expired(e.Expires, now) })
+	p.Net.Hosts.Entries = slices.DeleteFunc(p.Net.Hosts.Entries, func(e HostEntry) bool { return expired(e.Expires, now) })
+	p.Tools.Entries = slices.DeleteFunc(p.Tools.Entries, func(e ToolEntry) bool { return expired(e.Expires, now) })
+	p.Secrets.Entries = slices.DeleteFunc(p.Secrets.Entries, func(e SecretEntry) bool { return expired(e.Expires, now) })
+}
+
+// The Match methods return nil when no entry matches. Expired entries
+// never match.
+
+// MatchPath finds the most specific path entry for the given path.
 func (p *PathPolicy) MatchPath(targetPath string) *PathEntry {
 	var bestMatch *PathEntry
 	var bestLen int
 
+	now := time.Now()
 	for i := range p.Entries {
 		entry := &p.Entries[i]
+		if expired(entry.Expires, now) {
+			continue
+		}
 		if pathMatches(entry.Path, targetPath) {
 			// Prefer more specific (longer) matches
 			if len(entry.Path) > bestLen {
@@ -226,19 +317,36 @@ func pathMatches(pattern, path string) bool {
 	return false
 }
 
-// MatchEnv finds the best matching env entry for the given variable name.
-// Returns nil if no entry matches.
-func (p *EnvPolicy) MatchEnv(name string) *EnvEntry {
-	for i := range p.Entries {
-		entry := &p.Entries[i]
-		if envMatches(entry.Name, name) {
-			return entry
+// firstMatch returns the first of entries that matches and hasn't expired.
+func firstMatch[E any](entries []E, matches func(*E) bool, expires func(*E) *time.Time) *E {
+	now := time.Now()
+	for i := range entries {
+		if matches(&entries[i]) && !expired(expires(&entries[i]), now) {
+			return &entries[i]
 		}
 	}
 	return nil
 }
 
-// envMatches checks if a pattern matches an env var name.
+// MatchEnv finds the first env entry matching the given variable name.
+func (p *EnvPolicy) MatchEnv(name string) *EnvEntry {
+	return firstMatch(p.Entries, func(e *EnvEntry) bool { return envMatches(e.Name, name) }, func(e *EnvEntry) *time.Time { return e.Expires })
+}
+
+// MatchProtected finds the first protected entry matching name.
+func (p *EnvPolicy) MatchProtected(name string) *EnvEntry {
+	return firstMatch(p.Protected, func(e *EnvEntry) bool { return envMatches(e.Name, name) }, func(e *EnvEntry) *time.Time { return e.Expires })
+}
+
+// decision is e's approval, or "" when there is no entry.
+func (e *EnvEntry) decision() Approval {
+	if e == nil {
+		return ""
+	}
+	return e.Approval
+}
+
+// envMatches checks if a pattern matches an env var, tool, or secret name.
 // Supports exact matches and wildcards like AWS_*.
 func envMatches(pattern, name string) bool {
 	if pattern == name {
@@ -254,16 +362,22 @@ func envMatches(pattern, name string) bool {
 	return false
 }
 
-// MatchHost finds the best matching host entry for the given hostname.
-// Returns nil if no entry matches.
+// MatchHost finds the first host entry matching the given hostname.
 func (p *HostPolicy) MatchHost(host string) *HostEntry {
-	for i := range p.Entries {
-		entry := &p.Entries[i]
-		if hostMatches(entry.Host, host) {
-			return entry
-		}
+	return firstMatch(p.Entries, func(e *HostEntry) bool { return hostMatches(e.Host, host) }, func(e *HostEntry) *time.Time { return e.Expires })
+}
+
+// MatchProtected finds the first protected entry matching host.
+func (p *HostPolicy) MatchProtected(host string) *HostEntry {
+	return firstMatch(p.Protected, func(e *HostEntry) bool { return hostMatches(e.Host, host) }, func(e *HostEntry) *time.Time { return e.Expires })
+}
+
+// decision is e's approval, or "" when there is no entry.
+func (e *HostEntry) decision() Approval {
+	if e == nil {
+		return ""
 	}
-	return nil
+	return e.Approval
 }
 
 // hostMatches checks if a pattern matches a hostname.
@@ -282,8 +396,45 @@ func hostMatches(pattern, host string) bool {
 	return false
 }
 
-// AddPathEntry adds a new path entry to the policy.
-func (p *Policy) AddPathEntry(path, mode string, approval Approval, source Source) {
+// MatchTool finds the first tool entry matching name.
+func (p *ToolPolicy) MatchTool(name string) *ToolEntry {
+	return firstMatch(p.Entries, func(e *ToolEntry) bool { return envMatches(e.Tool, name) }, func(e *ToolEntry) *time.Time { return e.Expires })
+}
+
+// MatchProtected finds the first protected entry matching name.
+func (p *ToolPolicy) MatchProtected(name string) *ToolEntry {
+	return firstMatch(p.Protected, func(e *ToolEntry) bool { return envMatches(e.Tool, name) }, func(e *ToolEntry) *time.Time { return e.Expires })
+}
+
+// decision is e's approval, or "" when there is no entry.
+func (e *ToolEntry) decision() Approval {
+	if e == nil {
+		return ""
+	}
+	return e.Approval
+}
+
+// MatchSecret finds the first secret entry matching name.
+func (p *SecretPolicy) MatchSecret(name string) *SecretEntry {
+	return firstMatch(p.Entries, func(e *SecretEntry) bool { return envMatches(e.Name, name) }, func(e *SecretEntry) *time.Time { return e.Expires })
+}
+
+// MatchProtected finds the first protected entry matching name.
+func (p *SecretPolicy) MatchProtected(name string) *SecretEntry {
+	return firstMatch(p.Protected, func(e *SecretEntry) bool { return envMatches(e.Name, name) }, func(e *SecretEntry) *time.Time { return e.Expires })
+}
+
+// decision is e's approval, or "" when there is no entry.
+func (e *SecretEntry) decision() Approval {
+	if e == nil {
+		return ""
+	}
+	return e.Approval
+}
+
+// AddPathEntry adds a new path entry to the policy and returns it so the
+// caller can annotate it. The pointer is valid until the next add.
+func (p *Policy) AddPathEntry(path, mode string, approval Approval, source Source) *PathEntry {
 	p.Paths.Entries = append(p.Paths.Entries, PathEntry{
 		Path:     path,
 		Mode:     mode,
@@ -291,24 +442,64 @@ func (p *Policy) AddPathEntry(path, mode string, approval Approval, source Sourc
 		Source:   source,
 		Created:  time.Now(),
 	})
+	return &p.Paths.Entries[len(p.Paths.Entries)-1]
+}
+
+// MovePaths repoints path entries (including protected ones) at or under
+// oldPath to the same place under newPath. Reports whether anything changed.
+func (p *Policy) MovePaths(oldPath, newPath string) bool {
+	changed := false
+	for _, entries := range [][]PathEntry{p.Paths.Entries, p.Paths.Protected} {
+		for i, e := range entries {
+			if e.Path == oldPath || strings.HasPrefix(e.Path, oldPath+string(filepath.Separator)) {
+				entries[i].Path = newPath + strings.TrimPrefix(e.Path, oldPath)
+				changed = true
+			}
+		}
+	}
+	return changed
 }
 
-// AddEnvEntry adds a new env entry to the policy.
-func (p *Policy) AddEnvEntry(name string, approval Approval, source Source) {
+// AddEnvEntry adds a new env entry to the policy and returns it.
+func (p *Policy) AddEnvEntry(name string, approval Approval, source Source) *EnvEntry {
 	p.Env.Entries = append(p.Env.Entries, EnvEntry{
 		Name:     name,
 		Approval: approval,
 		Source:   source,
 		Created:  time.Now(),
 	})
+	return &p.Env.Entries[len(p.Env.Entries)-1]
 }
 
-// AddHostEntry adds a new host entry to the policy.
-func (p *Policy) AddHostEntry(host string, approval Approval, source Source) {
+// AddHostEntry adds a new host entry to the policy and returns it.
+func (p *Policy) AddHostEntry(host string, approval Approval, source Source) *HostEntry {
 	p.Net.Hosts.Entries = append(p.Net.Hosts.Entries, HostEntry{
 		Host:     host,
 		Approval: approval,
 		Source:   source,
 		Created:  time.Now(),
 	})
+	return &p.Net.Hosts.Entries[len(p.Net.Hosts.Entries)-1]
+}
+
+// AddToolEntry adds a new tool entry to the policy and returns it.
+func (p *Policy) AddToolEntry(tool string, approval Approval, source Source) *ToolEntry {
+	p.Tools.Entries = append(p.Tools.Entries, ToolEntry{
+		Tool:     tool,
+		Approval: approval,
+		Source:   source,
+		Created:  time.Now(),
+	})
+	return &p.Tools.Entries[len(p.Tools.Entries)-1]
+}
+
+// AddSecretEntry adds a new secret entry to the policy and returns it.
+func (p *Policy) AddSecretEntry(name string, approval Approval, source Source) *SecretEntry {
+	p.Secrets.Entries = append(p.Secrets.Entries, SecretEntry{
+		Name:     name,
+		Approval: approval,
+		Source:   source,
+		Created:  time.Now(),
+	})
+	return &p.Secrets.Entries[len(p.Secrets.Entries)-1]
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewPolicy(t *testing.T) {
@@ -218,3 +219,139 @@ func TestPolicySaveCreatesDir(t *testing.T) {
 		t.Error("expected policy file to be created")
 	}
 }
+
+func TestEntryNote(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.json")
+
+	p := NewPolicy()
+	p.AddHostEntry("api.weather.gov", ApprovalAllow, SourceCLI).Note = "for weather API"
+	if err := p.Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	loaded, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy error: %v", err)
+	}
+	if got := loaded.Net.Hosts.Entries[0].Note; got != "for weather API" {
+		t.Errorf("Note = %q, want %q", got, "for weather API")
+	}
+}
+
+func TestMovePaths(t *testing.T) {
+	p := NewPolicy()
+	p.AddPathEntry("/data/old/workspace", "rwd", ApprovalAllow, SourceDefault)
+	p.AddPathEntry("/data/old/workspace/out", "r", ApprovalAllow, SourceCLI)
+	p.AddPathEntry("/data/oldish", "r", ApprovalAllow, SourceCLI)
+
+	if !p.MovePaths("/data/old/workspace", "/project/.thought/workspace") {
+		t.Fatal("MovePaths reported no change")
+	}
+	want := []string{"/project/.thought/workspace", "/project/.thought/workspace/out", "/data/oldish"}
+	for i, e := range p.Paths.Entries {
+		if e.Path != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, e.Path, want[i])
+		}
+	}
+	if p.MovePaths("/nowhere", "/elsewhere") {
+		t.Error("MovePaths reported a change with no matching entries")
+	}
+}
+
+func TestExpiredEntries(t *testing.T) {
+	p := NewPolicy()
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	p.AddHostEntry("old.example", ApprovalAllow, SourcePrompt).Expires = &past
+	p.AddHostEntry("new.example", ApprovalAllow, SourcePrompt).Expires = &future
+	p.AddEnvEntry("OLD", ApprovalAllow, SourcePrompt).Expires = &past
+	p.AddPathEntry("/old", "r", ApprovalAllow, SourcePrompt).Expires = &past
+	p.AddToolEntry("mcp__old", ApprovalAllow, SourcePrompt).Expires = &past
+
+	if p.Net.Hosts.MatchHost("old.example") != nil || p.Env.MatchEnv("OLD") != nil ||
+		p.Paths.MatchPath("/old/file") != nil || p.Tools.MatchTool("mcp__old") != nil {
+		t.Error("an expired entry matched")
+	}
+	if p.Net.Hosts.MatchHost("new.example") == nil {
+		t.Error("an unexpired entry didn't match")
+	}
+
+	// Saving drops expired entries and keeps the expiry of the rest
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPolicy(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Net.Hosts.Entries) != 1 || len(loaded.Env.Entries) != 0 || len(loaded.Paths.Entries) != 0 || len(loaded.Tools.Entries) != 0 {
+		t.Errorf("saved entries = %+v", loaded)
+	}
+	if e := loaded.Net.Hosts.Entries[0]; e.Expires == nil || !e.Expires.Equal(future) {
+		t.Errorf("saved expiry = %v, want %v", e.Expires, future)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	home := "/home/ada"
+	p := NewPolicy()
+	p.AddPathEntry("/home/ada/thoughts/ws", "rwd", ApprovalAllow, SourceDefault)
+	p.AddPathEntry("/home/ada/data", "r", ApprovalAllow, SourceCLI)
+	p.AddHostEntry("example.com", ApprovalAllow, SourcePrompt)
+	expires := time.Now().Add(time.Hour)
+	p.AddEnvEntry("TOKEN", ApprovalAllow, SourcePrompt).Expires = &expires
+
+	out := p.Export(home)
+	if len(out.Paths.Entries) != 1 || out.Paths.Entries[0].Path != "~/data" {
+		t.Fatalf("exported paths = %+v, want only ~/data", out.Paths.Entries)
+	}
+	if len(out.Env.Entries) != 0 {
+		t.Errorf("time-limited env entry was exported: %+v", out.Env.Entries)
+	}
+
+	dst := NewPolicy()
+	dst.AddHostEntry("example.com", ApprovalDeny, SourceCLI)
+	n, err := dst.Import(out, "/Users/grace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("imported %d entries, want 2", n)
+	}
+	if e := dst.Paths.Entries[0]; e.Path != "/Users/grace/data" || e.Source != SourceImport {
+		t.Errorf("imported path = %+v", e)
+	}
+	if len(dst.Net.Hosts.Entries) != 1 || dst.Net.Hosts.Entries[0].Approval != ApprovalAllow {
+		t.Errorf("imported host should replace the existing entry: %+v", dst.Net.Hosts.Entries)
+	}
+}
+
+func TestImportRejectsInvalid(t *testing.T) {
+	bad := NewPolicy()
+	bad.AddHostEntry("example.com", Approval("always"), SourceCLI)
+	if _, err := NewPolicy().Import(bad, ""); err == nil {
+		t.Error("expected an error for an invalid approval")
+	}
+
+	rel := NewPolicy()
+	rel.AddPathEntry("data", "r", ApprovalAllow, SourceCLI)
+	if _, err := NewPolicy().Import(rel, "/home/ada"); err == nil {
+		t.Error("expected an error for a relative path")
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	for _, tmpl := range Templates {
+		p, err := LoadTemplate(tmpl.Name)
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl.Name, err)
+		}
+		if err := p.validate(); err != nil {
+			t.Errorf("%s: %v", tmpl.Name, err)
+		}
+	}
+	if _, err := LoadTemplate("nope"); err == nil {
+		t.Error("expected an error for an unknown template")
+	}
+}
@@ -0,0 +1,230 @@
+package approval
+
+import (
+	"embed"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Policies move between machines with `thought policy export` and
+// `thought policy import`. An exported policy keeps only the decisions a
+// person made: bootstrap defaults, managed entries, and time-limited grants
+// are left out, and paths under the home directory are written as ~/...
+// so they resolve on the importing machine.
+
+// Template is a built-in policy for `thought policy import --template`.
+type Template struct {
+	Name        string
+	Description string
+}
+
+// Templates lists the built-in policy templates, in the order they're
+// shown.
+var Templates = []Template{
+	{"readonly-cwd", "Read the working directory; deny writing it and every path outside workspace/ and memories/"},
+	{"offline", "No network access and no secrets"},
+	{"net-github", "Allow github.com, its API, and raw.githubusercontent.com downloads"},
+}
+
+//go:embed templates/*.json
+var templateFS embed.FS
+
+// LoadTemplate returns the built-in template name.
+func LoadTemplate(name string) (*Policy, error) {
+	data, err := templateFS.ReadFile("templates/" + name + ".json")
+	if err != nil {
+		var names []string
+		for _, t := range Templates {
+			names = append(names, t.Name)
+		}
+		return nil, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(names, ", "))
+	}
+	return ParsePolicy(data)
+}
+
+// Export returns a copy of p to share: without default and managed
+// entries, time-limited grants, or protected entries, and with paths
+// under home written as ~/...
+func (p *Policy) Export(home string) *Policy {
+	out := NewPolicy()
+	out.Paths.Default = p.Paths.Default
+	out.Env.Default = p.Env.Default
+	out.Net.Hosts.Default = p.Net.Hosts.Default
+	out.Net.Listen.Default = p.Net.Listen.Default
+	out.Tools.Default = p.Tools.Default
+	out.Secrets.Default = p.Secrets.Default
+
+	shared := func(source Source, expires *time.Time) bool {
+		return source != SourceDefault && source != SourceManaged && expires == nil
+	}
+	for _, e := range p.Paths.Entries {
+		if shared(e.Source, e.Expires) {
+			e.Path = abbreviateHome(e.Path, home)
+			out.Paths.Entries = append(out.Paths.Entries, e)
+		}
+	}
+	for _, e := range p.Env.Entries {
+		if shared(e.Source, e.Expires) {
+			out.Env.Entries = append(out.Env.Entries, e)
+		}
+	}
+	for _, e := range p.Net.Hosts.Entries {
+		if shared(e.Source, e.Expires) {
+			out.Net.Hosts.Entries = append(out.Net.Hosts.Entries, e)
+		}
+	}
+	for _, e := range p.Net.Listen.Entries {
+		if shared(e.Source, nil) {
+			out.Net.Listen.Entries = append(out.Net.Listen.Entries, e)
+		}
+	}
+	for _, e := range p.Tools.Entries {
+		if shared(e.Source, e.Expires) {
+			out.Tools.Entries = append(out.Tools.Entries, e)
+		}
+	}
+	for _, e := range p.Secrets.Entries {
+		if shared(e.Source, e.Expires) {
+			out.Secrets.Entries = append(out.Secrets.Entries, e)
+		}
+	}
+	return out
+}
+
+// Import merges an exported policy or template into p. Defaults set in
+// from replace p's; each entry replaces p's entry for the same path, name,
+// host, port, or tool, or is added. Imported entries are marked
+// SourceImport and never expire; ~ in paths is expanded to home. It
+// returns how many entries it imported.
+func (p *Policy) Import(from *Policy, home string) (int, error) {
+	if from.Version > PolicyVersion {
+		return 0, fmt.Errorf("policy version %d is newer than this version of thought understands (%d)", from.Version, PolicyVersion)
+	}
+	if err := from.validate(); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	setDefault := func(dst *Approval, src Approval) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	setDefault(&p.Paths.Default, from.Paths.Default)
+	setDefault(&p.Env.Default, from.Env.Default)
+	setDefault(&p.Net.Hosts.Default, from.Net.Hosts.Default)
+	setDefault(&p.Net.Listen.Default, from.Net.Listen.Default)
+	setDefault(&p.Tools.Default, from.Tools.Default)
+	setDefault(&p.Secrets.Default, from.Secrets.Default)
+
+	n := 0
+	for _, e := range from.Paths.Entries {
+		e.Path = expandHome(e.Path, home)
+		if !filepath.IsAbs(e.Path) {
+			return 0, fmt.Errorf("path entry %q must be absolute or start with ~/", e.Path)
+		}
+		e.Source, e.Created, e.Expires = SourceImport, now, nil
+		p.Paths.Entries = upsert(p.Paths.Entries, e, func(x PathEntry) bool { return x.Path == e.Path })
+		n++
+	}
+	for _, e := range from.Env.Entries {
+		e.Source, e.Created, e.Expires = SourceImport, now, nil
+		p.Env.Entries = upsert(p.Env.Entries, e, func(x EnvEntry) bool { return x.Name == e.Name })
+		n++
+	}
+	for _, e := range from.Net.Hosts.Entries {
+		e.Source, e.Created, e.Expires = SourceImport, now, nil
+		p.Net.Hosts.Entries = upsert(p.Net.Hosts.Entries, e, func(x HostEntry) bool { return x.Host == e.Host })
+		n++
+	}
+	for _, e := range from.Net.Listen.Entries {
+		e.Source, e.Created = SourceImport, now
+		p.Net.Listen.Entries = upsert(p.Net.Listen.Entries, e, func(x ListenEntry) bool { return x.Port == e.Port })
+		n++
+	}
+	for _, e := range from.Tools.Entries {
+		e.Source, e.Created, e.Expires = SourceImport, now, nil
+		p.Tools.Entries = upsert(p.Tools.Entries, e, func(x ToolEntry) bool { return x.Tool == e.Tool })
+		n++
+	}
+	for _, e := range from.Secrets.Entries {
+		e.Source, e.Created, e.Expires = SourceImport, now, nil
+		p.Secrets.Entries = upsert(p.Secrets.Entries, e, func(x SecretEntry) bool { return x.Name == e.Name })
+		n++
+	}
+	return n, nil
+}
+
+// validate checks the approvals and path modes of a policy read from a
+// file, so an import can't smuggle in values the matchers don't expect.
+func (p *Policy) validate() error {
+	var approvals []Approval
+	approvals = append(approvals, p.Paths.Default, p.Env.Default, p.Net.Hosts.Default, p.Net.Listen.Default, p.Tools.Default, p.Secrets.Default)
+	for _, e := range p.Paths.Entries {
+		if e.Mode == "" || strings.Trim(e.Mode, "rwd") != "" {
+			return fmt.Errorf("path entry %q: invalid mode %q (use r, w, and d)", e.Path, e.Mode)
+		}
+		approvals = append(approvals, e.Approval)
+	}
+	for _, e := range p.Env.Entries {
+		approvals = append(approvals, e.Approval)
+	}
+	for _, e := range p.Net.Hosts.Entries {
+		approvals = append(approvals, e.Approval)
+	}
+	for _, e := range p.Net.Listen.Entries {
+		approvals = append(approvals, e.Approval)
+	}
+	for _, e := range p.Tools.Entries {
+		approvals = append(approvals, e.Approval)
+	}
+	for _, e := range p.Secrets.Entries {
+		approvals = append(approvals, e.Approval)
+	}
+	for _, a := range approvals {
+		switch a {
+		case "", ApprovalAllow, ApprovalDeny, ApprovalPrompt:
+		default:
+			return fmt.Errorf("invalid approval %q (must be allow, deny, or prompt)", a)
+		}
+	}
+	return nil
+}
+
+// upsert replaces the first entry in entries that same matches with e, or
+// appends e.
+func upsert[E any](entries []E, e E, same func(E) bool) []E {
+	if i := slices.IndexFunc(entries, same); i >= 0 {
+		entries[i] = e
+		return entries
+	}
+	return append(entries, e)
+}
+
+// abbreviateHome writes path under home as ~/...
+func abbreviateHome(path, home string) string {
+	if home == "" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~/" + filepath.ToSlash(rest)
+	}
+	return path
+}
+
+// expandHome is abbreviateHome's inverse.
+func expandHome(path, home string) string {
+	if path == "~" {
+		return home
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		return filepath.Join(home, filepath.FromSlash(rest))
+	}
+	return path
+}
@@ -0,0 +1,131 @@
+package approval
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// Every global policy ends with built-in protected denies for locations no
+// thought should touch: keys and cloud credentials, thinkingscript's own
+// agent files (API keys), publishing key, and global policy, and browser
+// profiles (cookies, saved passwords). They come after the policy file's own protected
+// entries, so the only way past one is a protected allow entry in the
+// global policy, which `thought policy add --global --i-know-what-im-doing`
+// writes.
+
+// BuiltinProtected returns the built-in protected deny entries for the
+// user's home directory and the thinkingscript home (~/.thinkingscript).
+// Both are resolved through symlinks, as the sandbox resolves the paths it
+// checks.
+func BuiltinProtected(home, dataHome string) []PathEntry {
+	home, dataHome = realDir(home), realDir(dataHome)
+	var paths []string
+	if home != "" {
+		for _, p := range []string{".ssh", ".gnupg", filepath.Join(".aws", "credentials")} {
+			paths = append(paths, filepath.Join(home, p))
+		}
+		for _, p := range browserProfiles() {
+			paths = append(paths, filepath.Join(home, p))
+		}
+	}
+	if dataHome != "" {
+		paths = append(paths, filepath.Join(dataHome, "agents"), filepath.Join(dataHome, "keys"), filepath.Join(dataHome, "policy.json"), filepath.Join(dataHome, "managed"))
+	}
+
+	entries := make([]PathEntry, 0, len(paths))
+	for _, p := range paths {
+		entries = append(entries, PathEntry{Path: p, Mode: "rwd", Approval: ApprovalDeny, Source: SourceBuiltin})
+	}
+	return entries
+}
+
+// BuiltinProtectedPath returns the built-in protected entry that covers
+// path, or nil.
+func BuiltinProtectedPath(path, home, dataHome string) *PathEntry {
+	entries := BuiltinProtected(home, dataHome)
+	i := slices.IndexFunc(entries, func(e PathEntry) bool { return pathMatches(e.Path, path) })
+	if i < 0 {
+		return nil
+	}
+	return &entries[i]
+}
+
+// ProtectedPaths returns the paths of every protected deny entry in
+// effect: managed, the global policy's own, and built-in. The sandbox
+// checks PathDenied for anything under them even inside its own allowed
+// paths, so running in the home directory doesn't expose ~/.ssh, and a
+// managed deny holds under --allow and --write. PathDenied still lets a
+// global protected allow through where it overrides a built-in entry. A
+// nil Approver has none.
+func (a *Approver) ProtectedPaths() []string {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var paths []string
+	for _, e := range a.globalPolicy.Paths.Protected {
+		if e.Approval == ApprovalDeny {
+			paths = append(paths, e.Path)
+		}
+	}
+	return paths
+}
+
+// browserProfiles returns the browser profile directories for this OS,
+// relative to the home directory.
+func browserProfiles() []string {
+	switch runtime.GOOS {
+	case "darwin":
+		support := filepath.Join("Library", "Application Support")
+		return []string{
+			filepath.Join(support, "Google", "Chrome"),
+			filepath.Join(support, "Chromium"),
+			filepath.Join(support, "BraveSoftware"),
+			filepath.Join(support, "Microsoft Edge"),
+			filepath.Join(support, "Firefox"),
+			filepath.Join("Library", "Safari"),
+			filepath.Join("Library", "Cookies"),
+		}
+	case "windows":
+		local := filepath.Join("AppData", "Local")
+		return []string{
+			filepath.Join(local, "Google", "Chrome", "User Data"),
+			filepath.Join(local, "Chromium", "User Data"),
+			filepath.Join(local, "BraveSoftware"),
+			filepath.Join(local, "Microsoft", "Edge", "User Data"),
+			filepath.Join("AppData", "Roaming", "Mozilla", "Firefox"),
+		}
+	default:
+		return []string{
+			filepath.Join(".config", "google-chrome"),
+			filepath.Join(".config", "chromium"),
+			filepath.Join(".config", "BraveSoftware"),
+			filepath.Join(".config", "microsoft-edge"),
+			".mozilla",
+		}
+	}
+}
+
+// realDir resolves dir's symlinks, keeping it as is when it can't be.
+func realDir(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		return real
+	}
+	return dir
+}
+
+// userHome is the home directory the built-in entries protect; "" when
+// there is none.
+func userHome() string {
+	home, _ := os.UserHomeDir()
+	return home
+}
@@ -0,0 +1,77 @@
+package approval
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/i18n"
+	"golang.org/x/net/publicsuffix"
+)
+
+// scope is what a remembered answer covers: the request's own target or
+// the obvious family around it, such as the file's directory or the host's
+// parent domain. Answers for this request only ignore it.
+type scope struct {
+	label  string // shown in the prompt, e.g. "this file"
+	target string // the entry saved for it
+}
+
+// scopesFor lists the scopes offered for a request, narrowest first. The
+// first is always the target itself. label is "net", "env", "tool",
+// "secret", or a path operation.
+func scopesFor(label, target string) []scope {
+	switch label {
+	case "net":
+		scopes := []scope{{i18n.T("approval.scope_host"), target}}
+		if w := parentDomain(target); w != "" {
+			scopes = append(scopes, scope{w, w})
+		}
+		return scopes
+	case "env", "secret":
+		exact := i18n.T("approval.scope_env")
+		if label == "secret" {
+			exact = i18n.T("approval.scope_secret")
+		}
+		scopes := []scope{{exact, target}}
+		if prefix, _, ok := strings.Cut(target, "_"); ok && prefix != "" {
+			scopes = append(scopes, scope{prefix + "_*", prefix + "_*"})
+		}
+		return scopes
+	case "tool":
+		scopes := []scope{{i18n.T("approval.scope_tool"), target}}
+		if i := strings.LastIndex(target, "__"); strings.HasPrefix(target, "mcp__") && i > len("mcp_") {
+			w := target[:i+2] + "*"
+			scopes = append(scopes, scope{w, w})
+		}
+		return scopes
+	}
+	exact := i18n.T("approval.scope_file")
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		exact = i18n.T("approval.scope_dir")
+	}
+	scopes := []scope{{exact, target}}
+	if dir := filepath.Dir(target); dir != target && dir != filepath.Dir(dir) {
+		scopes = append(scopes, scope{i18n.T("approval.scope_parent", dir), dir})
+	}
+	return scopes
+}
+
+// parentDomain returns the wildcard covering host's siblings, like
+// *.example.com for api.example.com, or "" for IP addresses and hosts
+// whose parent is a public suffix (com, co.uk, github.io), which would
+// allow every site under it.
+func parentDomain(host string) string {
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return ""
+	}
+	_, parent, ok := strings.Cut(host, ".")
+	if !ok {
+		return ""
+	}
+	if _, err := publicsuffix.EffectiveTLDPlusOne(parent); err != nil {
+		return ""
+	}
+	return "*." + parent
+}
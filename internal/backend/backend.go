@@ -0,0 +1,110 @@
+// Package backend decides where sandboxed JavaScript runs. The in-process
+// backend runs it in this process, as think always has. The container
+// backends run it in a Docker or Podman container that bind-mounts only the
+// sandbox's allowed paths, so a bug in the sandbox can't reach the rest of
+// the machine. Approvals, prompts, journaling, and output are relayed to
+// the host over the container's stdin/stdout, so policy and the terminal
+// stay on the host.
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/sandbox"
+)
+
+// Names accepted by --backend and config.json's backend.
+const (
+	NameProcess = "process"
+	NameDocker  = "docker"
+	NamePodman  = "podman"
+)
+
+// DefaultImage is the container image used when container_image is unset.
+// The think binary is mounted in, so the image only needs a libc it can run
+// against (none at all for a static build).
+const DefaultImage = "debian:stable-slim"
+
+// Backend runs code in a sandbox built from cfg.
+type Backend interface {
+	Name() string
+	Run(ctx context.Context, cfg sandbox.Config, code string) (string, error)
+}
+
+// InProcess runs sandboxes in this process.
+var InProcess Backend = inProcess{}
+
+type inProcess struct{}
+
+func (inProcess) Name() string { return NameProcess }
+
+func (inProcess) Run(ctx context.Context, cfg sandbox.Config, code string) (string, error) {
+	sb, err := sandbox.New(cfg)
+	if err != nil {
+		return "", fmt.Errorf("creating sandbox: %w", err)
+	}
+	return sb.Run(ctx, code)
+}
+
+// Valid reports whether name is an accepted backend name. "" is the
+// in-process backend.
+func Valid(name string) bool {
+	switch name {
+	case "", NameProcess, NameDocker, NamePodman:
+		return true
+	}
+	return false
+}
+
+// Settings select and configure a backend.
+type Settings struct {
+	Name   string // NameProcess, NameDocker, or NamePodman; "" = NameProcess
+	Image  string // container image; "" = DefaultImage
+	Binary string // linux think binary mounted into the container; "" = this executable (linux only)
+}
+
+// FromConfig reads the backend settings from config.json. A non-empty
+// name (the --backend flag) overrides config.json's backend.
+func FromConfig(cfg *config.Config, name string) (Settings, error) {
+	if name == "" {
+		name = cfg.Backend
+	}
+	if !Valid(name) {
+		return Settings{}, fmt.Errorf("unknown backend %q (expected %s, %s, or %s)", name, NameProcess, NameDocker, NamePodman)
+	}
+	return Settings{Name: name, Image: cfg.ContainerImage, Binary: cfg.ContainerBinary}, nil
+}
+
+// Select returns the backend s names. When a container backend can't be
+// used — its runtime isn't on PATH, or there's no linux think binary to run
+// inside it — Select falls back to InProcess and returns the reason as
+// fallback, for the caller to warn about.
+func Select(s Settings) (b Backend, fallback error) {
+	if s.Name == "" || s.Name == NameProcess {
+		return InProcess, nil
+	}
+	path, err := exec.LookPath(s.Name)
+	if err != nil {
+		return InProcess, fmt.Errorf("%s not found, running sandboxes in-process", s.Name)
+	}
+	binary := s.Binary
+	if binary == "" {
+		if runtime.GOOS != "linux" {
+			return InProcess, errors.New("set container_binary to a linux think binary to use a container backend on " + runtime.GOOS + ", running sandboxes in-process")
+		}
+		if binary, err = os.Executable(); err != nil {
+			return InProcess, fmt.Errorf("locating think binary: %w, running sandboxes in-process", err)
+		}
+	}
+	image := s.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	return &Container{Runtime: path, Podman: s.Name == NamePodman, Image: image, Binary: binary}, nil
+}
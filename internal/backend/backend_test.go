@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/sandbox"
+)
+
+// runChild runs code through the host/child protocol over pipes, with the
+// child in a goroutine standing in for the container.
+func runChild(t *testing.T, cfg sandbox.Config, code string) (string, error) {
+	t.Helper()
+	toChild, fromHost := io.Pipe()
+	toHost, fromChild := io.Pipe()
+	childErr := make(chan error, 1)
+	go func() {
+		err := ServeChild(toChild, fromChild)
+		fromChild.Close()
+		childErr <- err
+	}()
+	result, err := serve(toHost, fromHost, newStart(cfg, code, realPath), cfg)
+	fromHost.Close()
+	if cerr := <-childErr; cerr != nil {
+		t.Fatalf("ServeChild: %v", cerr)
+	}
+	return result, err
+}
+
+func TestProtocolRelaysHooks(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	workspaceDir := filepath.Join(thoughtDir, "workspace")
+	os.MkdirAll(workspaceDir, 0700)
+	os.WriteFile(filepath.Join(workspaceDir, "old.txt"), []byte("old"), 0600)
+	t.Setenv("THINK_BACKEND_TEST", "from-host")
+
+	var stdout, stderr strings.Builder
+	var asked, writes []string
+	jrnl := journal.New(thoughtDir)
+	cfg := sandbox.Config{
+		AllowedPaths:  []string{thoughtDir},
+		WritablePaths: []string{workspaceDir},
+		WorkDir:       dir,
+		Stdout:        &stdout,
+		Stderr:        &stderr,
+		Args:          []string{"a1"},
+		ApprovePath: func(op, path string) (bool, error) {
+			asked = append(asked, op+" "+path)
+			return false, nil
+		},
+		ApproveEnv: func(name string) (bool, error) { return name == "THINK_BACKEND_TEST", nil },
+		OnWrite:    func(path, content string) { writes = append(writes, path+"="+content) },
+		Journal:    jrnl,
+	}
+	code := `
+		process.stdout.write("out:" + process.args[0] + "\n");
+		console.log("log line");
+		fs.writeFile("` + filepath.Join(workspaceDir, "old.txt") + `", "new");
+		let denied = false;
+		try { fs.readFile("/etc/hostname") } catch (e) { denied = true }
+		let envDenied = false;
+		try { env.get("HOME") } catch (e) { envDenied = true }
+		[env.get("THINK_BACKEND_TEST"), denied, envDenied].join(",")
+	`
+	result, err := runChild(t, cfg, code)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != "from-host,true,true" {
+		t.Errorf("result = %q", result)
+	}
+	if stdout.String() != "out:a1\n" || !strings.Contains(stderr.String(), "log line") {
+		t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+	if len(asked) != 1 || asked[0] != "read /etc/hostname" {
+		t.Errorf("ApprovePath calls = %v", asked)
+	}
+	target := filepath.Join(realPath(workspaceDir), "old.txt")
+	if len(writes) != 1 || writes[0] != target+"=new" {
+		t.Errorf("OnWrite calls = %v", writes)
+	}
+	if jrnl.RunID() == "" {
+		t.Error("write was not journaled on the host")
+	}
+}
+
+func TestProtocolResultErrors(t *testing.T) {
+	dir := t.TempDir()
+	cfg := sandbox.Config{AllowedPaths: []string{dir}, WorkDir: dir, Stderr: io.Discard}
+
+	_, err := runChild(t, cfg, `agent.resume("need help")`)
+	var resumeErr *sandbox.ResumeError
+	if !errors.As(err, &resumeErr) || resumeErr.Context != "need help" {
+		t.Errorf("resume err = %v, want ResumeError(need help)", err)
+	}
+
+	if _, err := runChild(t, cfg, `throw new Error("boom")`); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("throw err = %v, want boom", err)
+	}
+
+	cfg.PromptInput = func(question, defaultValue string) (string, error) { return "", approval.ErrInterrupted }
+	if _, err := runChild(t, cfg, `input.prompt("name?")`); !errors.Is(err, approval.ErrInterrupted) {
+		t.Errorf("interrupted prompt err = %v, want ErrInterrupted", err)
+	}
+}
+
+func TestHostRejectsUntrustedCalls(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	os.MkdirAll(thoughtDir, 0700)
+	secret := filepath.Join(dir, "secret")
+	os.WriteFile(secret, []byte("s3cret"), 0600)
+	t.Setenv("THINK_BACKEND_SECRET", "s3cret")
+
+	jrnl := journal.New(thoughtDir)
+	h := &host{
+		cfg: sandbox.Config{
+			ApproveEnv: func(string) (bool, error) { return false, nil },
+			Journal:    jrnl,
+		},
+		writable:    []string{filepath.Join(thoughtDir, "workspace")},
+		approvedEnv: map[string]bool{},
+	}
+
+	// A journal record outside the writable paths would snapshot the file
+	// where the container can read it
+	h.handle(message{ID: 1, Method: "journal", Args: []string{journal.OpWrite, secret}})
+	if jrnl.RunID() != "" {
+		t.Error("host journaled a path the child can't write")
+	}
+	// Env vars must be approved before they're read
+	if reply := h.handle(message{ID: 2, Method: "getenv", Args: []string{"THINK_BACKEND_SECRET"}}); reply.Value != "" {
+		t.Error("host read an unapproved env var")
+	}
+	// Hooks the host didn't configure deny
+	if reply := h.handle(message{ID: 3, Method: "approveNet", Args: []string{"example.com"}}); reply.OK {
+		t.Error("approveNet without a hook was allowed")
+	}
+	// Secrets are only read once approved, and there's no hook to approve them
+	h.cfg.Secret = func(string) (string, error) { return "s3cret", nil }
+	if reply := h.handle(message{ID: 4, Method: "approveSecret", Args: []string{"TOKEN"}}); reply.OK {
+		t.Error("approveSecret without a hook was allowed")
+	}
+	if reply := h.handle(message{ID: 5, Method: "secret", Args: []string{"TOKEN"}}); reply.Value != "" || reply.Error == "" {
+		t.Error("host read an unapproved secret")
+	}
+}
+
+func TestPrepareMounts(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	workspaceDir := filepath.Join(thoughtDir, "workspace")
+	os.MkdirAll(workspaceDir, 0700)
+	memoryJS := filepath.Join(thoughtDir, "memory.js")
+	cfg := sandbox.Config{
+		AllowedPaths:  []string{dir, thoughtDir, workspaceDir, memoryJS, filepath.Join(dir, "missing")},
+		WritablePaths: []string{workspaceDir, memoryJS},
+		TrashDir:      filepath.Join(thoughtDir, ".trash"),
+	}
+
+	mounts, cleanup, err := prepareMounts(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range mounts {
+		mode := "ro"
+		if m.writable {
+			mode = "rw"
+		}
+		rel, _ := filepath.Rel(realPath(dir), m.path)
+		got = append(got, rel+":"+mode)
+	}
+	want := "[.:ro thought:ro thought/.trash:rw thought/memory.js:rw thought/workspace:rw]"
+	if s := "[" + strings.Join(got, " ") + "]"; s != want {
+		t.Errorf("mounts = %s, want %s", s, want)
+	}
+
+	// The memory.js placeholder goes away if nothing was written to it
+	cleanup()
+	if _, err := os.Stat(memoryJS); !os.IsNotExist(err) {
+		t.Error("empty memory.js placeholder was left behind")
+	}
+
+	cfg.ReadOnly = true
+	mounts, cleanup, _ = prepareMounts(cfg)
+	defer cleanup()
+	for _, m := range mounts {
+		if m.writable {
+			t.Errorf("read-only run mounted %s writable", m.path)
+		}
+	}
+}
+
+func TestContainerArgs(t *testing.T) {
+	c := &Container{Runtime: "docker", Image: "img", Binary: "/opt/think"}
+	args := strings.Join(c.args("think-x", sandbox.Config{WorkDir: "/"}, []mount{{path: "/w", writable: true}}), " ")
+	for _, want := range []string{
+		"--network none",
+		"--mount type=bind,source=/opt/think,target=" + containerBinary + ",readonly",
+		"--mount type=bind,source=/w,target=/w ",
+		"--env " + ChildEnv + "=1",
+		"img " + containerBinary,
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args missing %q:\n%s", want, args)
+		}
+	}
+
+	withNet := strings.Join(c.args("think-x", sandbox.Config{ApproveNet: func(string) (bool, error) { return true, nil }}, nil), " ")
+	if strings.Contains(withNet, "--network") {
+		t.Errorf("args with ApproveNet disable the network:\n%s", withNet)
+	}
+}
+
+func TestSelectFallsBack(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	b, fallback := Select(Settings{Name: NameDocker})
+	if b != InProcess || fallback == nil {
+		t.Errorf("Select without docker = %v, %v; want InProcess with a reason", b, fallback)
+	}
+	if b, fallback := Select(Settings{}); b != InProcess || fallback != nil {
+		t.Errorf("Select default = %v, %v", b, fallback)
+	}
+	if Valid("kubernetes") {
+		t.Error("Valid accepted an unknown backend")
+	}
+}
@@ -0,0 +1,203 @@
+package backend
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/sandbox"
+)
+
+// containerBinary is where the think binary is mounted in the container.
+const containerBinary = "/usr/local/bin/think"
+
+// Container runs each sandbox in a fresh Docker or Podman container. The
+// sandbox's allowed paths are bind-mounted read-only at their real host
+// paths, its writable paths and trash read-write, and nothing else: a path
+// approved at a prompt is allowed but not visible inside the container, so
+// grant it up front with --allow/--write or the policy. The container has
+// no network unless the run may ask for it.
+type Container struct {
+	Runtime string // path to the docker or podman binary
+	Podman  bool
+	Image   string
+	Binary  string // linux think binary mounted into the container and run there
+}
+
+func (c *Container) Name() string {
+	if c.Podman {
+		return NamePodman
+	}
+	return NameDocker
+}
+
+func (c *Container) Run(ctx context.Context, cfg sandbox.Config, code string) (string, error) {
+	mounts, cleanup, err := prepareMounts(cfg)
+	defer cleanup()
+	if err != nil {
+		return "", fmt.Errorf("preparing %s mounts: %w", c.Name(), err)
+	}
+
+	name := containerName()
+	cmd := exec.Command(c.Runtime, c.args(name, cfg, mounts)...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("starting %s: %w", c.Name(), err)
+	}
+	kill := func() {
+		exec.Command(c.Runtime, "kill", name).Run()
+		cmd.Process.Kill()
+	}
+	stop := context.AfterFunc(ctx, kill)
+	defer stop()
+
+	result, err := serve(stdout, stdin, newStart(cfg, code, realPath), cfg)
+	stdin.Close()
+	if errors.Is(err, errProtocol) {
+		kill()
+	}
+	waitErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return "", errors.New("execution cancelled")
+	}
+	if errors.Is(err, errProtocol) && waitErr != nil {
+		return "", fmt.Errorf("%s sandbox: %w", c.Name(), waitErr)
+	}
+	return result, err
+}
+
+// args builds the container run command line.
+func (c *Container) args(name string, cfg sandbox.Config, mounts []mount) []string {
+	args := []string{
+		"run", "--rm", "--interactive", "--name", name,
+		"--read-only", "--tmpfs", "/tmp",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"--env", ChildEnv + "=1",
+	}
+	if c.Podman {
+		args = append(args, "--userns", "keep-id")
+	} else if uid := os.Getuid(); uid >= 0 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, os.Getgid()))
+	}
+	if cfg.ApproveNet == nil {
+		args = append(args, "--network", "none")
+	}
+	args = append(args, "--mount", bindMount(c.Binary, containerBinary, false))
+	for _, m := range mounts {
+		args = append(args, "--mount", bindMount(m.path, m.path, m.writable))
+	}
+	if wd := realPath(cfg.WorkDir); wd != "" {
+		args = append(args, "--workdir", wd)
+	}
+	return append(args, c.Image, containerBinary)
+}
+
+func bindMount(src, dst string, writable bool) string {
+	spec := "type=bind,source=" + src + ",target=" + dst
+	if !writable {
+		spec += ",readonly"
+	}
+	return spec
+}
+
+// mount is one host path bind-mounted at the same path in the container.
+type mount struct {
+	path     string
+	writable bool
+}
+
+// prepareMounts lists the host paths cfg gives the sandbox, resolved to
+// their real locations. Writable paths win over the same path listed as
+// allowed. A writable file that doesn't exist yet (memory.js before the
+// first run) can't be bind-mounted, so an empty placeholder is created for
+// it; the returned cleanup removes placeholders that are still empty.
+func prepareMounts(cfg sandbox.Config) ([]mount, func(), error) {
+	var placeholders []string
+	cleanup := func() {
+		for _, p := range placeholders {
+			if info, err := os.Stat(p); err == nil && info.Size() == 0 {
+				os.Remove(p)
+			}
+		}
+	}
+
+	writable := map[string]bool{}
+	if !cfg.ReadOnly {
+		paths := append([]string(nil), cfg.WritablePaths...)
+		if cfg.TrashDir != "" {
+			if err := os.MkdirAll(cfg.TrashDir, 0700); err != nil {
+				return nil, cleanup, err
+			}
+			paths = append(paths, cfg.TrashDir)
+		}
+		for _, p := range paths {
+			real := realPath(p)
+			if _, err := os.Lstat(real); os.IsNotExist(err) {
+				f, err := os.OpenFile(real, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+				if err != nil {
+					continue
+				}
+				f.Close()
+				placeholders = append(placeholders, real)
+			}
+			writable[real] = true
+		}
+	}
+	for _, p := range cfg.AllowedPaths {
+		real := realPath(p)
+		if _, err := os.Stat(real); err == nil && !writable[real] {
+			writable[real] = false
+		}
+	}
+
+	mounts := make([]mount, 0, len(writable))
+	for path, w := range writable {
+		if strings.Contains(path, ",") {
+			return nil, cleanup, fmt.Errorf("can't mount %s: paths containing commas aren't supported", path)
+		}
+		mounts = append(mounts, mount{path: path, writable: w})
+	}
+	// Parents before children, so nested mounts layer correctly
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].path < mounts[j].path })
+	return mounts, cleanup, nil
+}
+
+// realPath resolves p to an absolute path with symlinks followed, falling
+// back to resolving its parent when p doesn't exist yet.
+func realPath(p string) string {
+	if p == "" {
+		return ""
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+func containerName() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "think-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,540 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/secrets"
+	"github.com/thinkingscript/cli/internal/workspace"
+)
+
+// ChildEnv is set to "1" in the environment of the think process inside a
+// container; main hands it straight to ServeChild.
+const ChildEnv = "THINKINGSCRIPT_SANDBOX_CHILD"
+
+// The protocol between host and child is newline-delimited JSON over the
+// child's stdin (host to child) and stdout (child to host). The host sends
+// one start message; the child then sends calls, which block until the
+// host replies with the same ID, output notifications (no ID), and finally
+// one done message.
+//
+// The child is treated as untrusted: the host answers calls only through
+// the hooks of its own sandbox.Config and checks journal paths itself.
+type message struct {
+	ID     int64    `json:"id,omitempty"`
+	Method string   `json:"method,omitempty"`
+	Args   []string `json:"args,omitempty"`
+
+	// Replies
+	OK          bool   `json:"ok,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Interrupted bool   `json:"interrupted,omitempty"` // the user interrupted a prompt
+
+	Start *start `json:"start,omitempty"`
+	Done  *done  `json:"done,omitempty"`
+}
+
+// start describes the sandbox the child should build. Paths are already
+// resolved to where they're mounted.
+type start struct {
+	Code          string        `json:"code"`
+	AllowedPaths  []string      `json:"allowed_paths"`
+	WritablePaths []string      `json:"writable_paths"`
+	WorkDir       string        `json:"workdir"`
+	Args          []string      `json:"args"`
+	Timeout       time.Duration `json:"timeout"`
+	ReadOnly      bool          `json:"read_only"`
+	TrashDir      string        `json:"trash_dir"`
+	TrashExempt   []string      `json:"trash_exempt"`
+	Workspace     string        `json:"workspace"` // per-run workspace dir; "" = none
+	Journal       bool          `json:"journal"`
+	Eval          string        `json:"eval"`
+	Redact        bool          `json:"redact"` // mask secret values in console output and RedactPaths writes
+	RedactPaths   []string      `json:"redact_paths"`
+	Protected     []string      `json:"protected"`
+	Hooks         []string      `json:"hooks"` // methods the host answers; the rest stay nil in the child
+}
+
+// done is the outcome of the child's sandbox run.
+type done struct {
+	Result      string  `json:"result"`
+	Error       string  `json:"error,omitempty"`
+	Resume      *string `json:"resume,omitempty"` // agent.resume context
+	Interrupted bool    `json:"interrupted,omitempty"`
+}
+
+func newDone(result string, err error) *done {
+	d := &done{Result: result}
+	var resumeErr *sandbox.ResumeError
+	switch {
+	case err == nil:
+	case errors.As(err, &resumeErr):
+		d.Resume = &resumeErr.Context
+	case errors.Is(err, approval.ErrInterrupted):
+		d.Interrupted = true
+	default:
+		d.Error = err.Error()
+	}
+	return d
+}
+
+func (d *done) result() (string, error) {
+	switch {
+	case d.Resume != nil:
+		return "", &sandbox.ResumeError{Context: *d.Resume}
+	case d.Interrupted:
+		return "", approval.ErrInterrupted
+	case d.Error != "":
+		return "", errors.New(d.Error)
+	}
+	return d.Result, nil
+}
+
+// newStart describes cfg for the child, with paths resolved by resolve.
+func newStart(cfg sandbox.Config, code string, resolve func(string) string) *start {
+	st := &start{
+		Code:     code,
+		WorkDir:  resolve(cfg.WorkDir),
+		Args:     cfg.Args,
+		Timeout:  cfg.Timeout,
+		ReadOnly: cfg.ReadOnly,
+		Journal:  cfg.Journal != nil,
+		Eval:     cfg.Eval,
+		Redact:   cfg.Redact != nil,
+		// No BlobCache: the shared cache isn't mounted into containers,
+		// so net.download there always fetches. No Profile either:
+		// --profile times in-process sandboxes only.
+	}
+	for _, p := range cfg.AllowedPaths {
+		st.AllowedPaths = append(st.AllowedPaths, resolve(p))
+	}
+	for _, p := range cfg.WritablePaths {
+		st.WritablePaths = append(st.WritablePaths, resolve(p))
+	}
+	if cfg.TrashDir != "" {
+		st.TrashDir = resolve(cfg.TrashDir)
+	}
+	for _, p := range cfg.TrashExempt {
+		st.TrashExempt = append(st.TrashExempt, resolve(p))
+	}
+	for _, p := range cfg.RedactPaths {
+		st.RedactPaths = append(st.RedactPaths, resolve(p))
+	}
+	for _, p := range cfg.Protected {
+		st.Protected = append(st.Protected, resolve(p))
+	}
+	if cfg.Workspace != nil {
+		st.Workspace = resolve(cfg.Workspace.Dir())
+	}
+	hooks := []struct {
+		name string
+		set  bool
+	}{
+		{"approvePath", cfg.ApprovePath != nil},
+		{"pathDenied", cfg.PathDenied != nil},
+		{"approveEnv", cfg.ApproveEnv != nil},
+		{"approveNet", cfg.ApproveNet != nil},
+		{"approveSecret", cfg.ApproveSecret != nil},
+		{"promptInput", cfg.PromptInput != nil},
+		{"onWrite", cfg.OnWrite != nil},
+		{"onFetch", cfg.OnFetch != nil},
+		{"onRequire", cfg.OnRequire != nil},
+		{"onDownload", cfg.OnDownload != nil},
+	}
+	for _, h := range hooks {
+		if h.set {
+			st.Hooks = append(st.Hooks, h.name)
+		}
+	}
+	return st
+}
+
+// errProtocol marks failures of the connection to the child rather than
+// of the script it ran.
+var errProtocol = errors.New("sandbox protocol")
+
+// serve sends st to the child on w, then answers the child's calls read
+// from r with cfg's hooks until the child reports its result.
+func serve(r io.Reader, w io.Writer, st *start, cfg sandbox.Config) (string, error) {
+	h := &host{cfg: cfg, writable: append(append([]string(nil), st.WritablePaths...), st.TrashDir), approvedEnv: map[string]bool{}, approvedSecrets: map[string]bool{}}
+	if h.cfg.Stdout == nil {
+		h.cfg.Stdout = os.Stdout
+	}
+	if h.cfg.Stderr == nil {
+		h.cfg.Stderr = os.Stderr
+	}
+	// The child masks its console output too, but it's untrusted
+	h.cfg.Stderr = cfg.Redact.Writer(h.cfg.Stderr)
+	if st.ReadOnly {
+		h.writable = nil
+	}
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(message{Start: st}); err != nil {
+		return "", fmt.Errorf("%w: %v", errProtocol, err)
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var m message
+			if jsonErr := json.Unmarshal(line, &m); jsonErr != nil {
+				return "", fmt.Errorf("%w: %v", errProtocol, jsonErr)
+			}
+			if m.Done != nil {
+				return m.Done.result()
+			}
+			reply := h.handle(m)
+			if m.ID != 0 {
+				reply.ID = m.ID
+				if err := enc.Encode(reply); err != nil {
+					return "", fmt.Errorf("%w: %v", errProtocol, err)
+				}
+			}
+		}
+		if err == io.EOF {
+			return "", fmt.Errorf("%w: sandbox exited without a result", errProtocol)
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", errProtocol, err)
+		}
+	}
+}
+
+// host answers the child's calls.
+type host struct {
+	cfg             sandbox.Config
+	writable        []string        // paths the child can change, for checking journal records
+	approvedEnv     map[string]bool // env vars ApproveEnv allowed; getenv reads only these
+	approvedSecrets map[string]bool // secrets ApproveSecret allowed; secret reads only these
+}
+
+func (h *host) handle(m message) message {
+	arg := func(i int) string {
+		if i < len(m.Args) {
+			return m.Args[i]
+		}
+		return ""
+	}
+	var reply message
+	var err error
+	switch m.Method {
+	case "stdout":
+		h.cfg.Stdout.Write([]byte(arg(0)))
+	case "stderr":
+		h.cfg.Stderr.Write([]byte(arg(0)))
+	case "approvePath":
+		if h.cfg.ApprovePath != nil {
+			reply.OK, err = h.cfg.ApprovePath(arg(0), arg(1))
+		}
+	case "pathDenied":
+		if h.cfg.PathDenied != nil {
+			reply.OK = h.cfg.PathDenied(arg(0), arg(1))
+		}
+	case "approveEnv":
+		reply.OK = true
+		if h.cfg.ApproveEnv != nil {
+			reply.OK, err = h.cfg.ApproveEnv(arg(0))
+		}
+		if reply.OK {
+			h.approvedEnv[arg(0)] = true
+		}
+	case "getenv":
+		if h.cfg.ApproveEnv == nil || h.approvedEnv[arg(0)] {
+			reply.Value = getenv(h.cfg, arg(0))
+			h.cfg.Redact.AddEnv(arg(0), reply.Value)
+		}
+	case "approveNet":
+		if h.cfg.ApproveNet != nil {
+			reply.OK, err = h.cfg.ApproveNet(arg(0))
+		}
+	case "approveSecret":
+		if h.cfg.ApproveSecret != nil {
+			reply.OK, err = h.cfg.ApproveSecret(arg(0))
+		}
+		if reply.OK {
+			h.approvedSecrets[arg(0)] = true
+		}
+	case "secret":
+		if !h.approvedSecrets[arg(0)] {
+			err = fmt.Errorf("secret %s was not approved", arg(0))
+		} else {
+			reply.Value, err = getSecret(h.cfg, arg(0))
+			h.cfg.Redact.Add(reply.Value)
+		}
+	case "promptInput":
+		if h.cfg.PromptInput == nil {
+			err = errors.New("no input available")
+		} else {
+			reply.Value, err = h.cfg.PromptInput(arg(0), arg(1))
+		}
+	case "onWrite":
+		if h.cfg.OnWrite != nil {
+			h.cfg.OnWrite(arg(0), arg(1))
+		}
+	case "onFetch":
+		if h.cfg.OnFetch != nil {
+			h.cfg.OnFetch(arg(0))
+		}
+	case "onRequire":
+		if h.cfg.OnRequire != nil {
+			h.cfg.OnRequire(arg(0), arg(1))
+		}
+	case "onDownload":
+		if h.cfg.OnDownload != nil {
+			h.cfg.OnDownload(arg(0), arg(1), arg(2))
+		}
+	case "journal":
+		h.journal(journal.Record{Op: arg(0), Path: arg(1), Dest: arg(2), TrashID: arg(3)})
+	case "promote":
+		reply.Value, err = h.cfg.Workspace.Promote(arg(0))
+	default:
+		err = fmt.Errorf("unknown sandbox call %q", m.Method)
+	}
+	if err != nil {
+		reply.OK, reply.Value = false, ""
+		reply.Error = err.Error()
+		reply.Interrupted = errors.Is(err, approval.ErrInterrupted)
+	}
+	return reply
+}
+
+// journal records r in the host's journal. Only paths the child can
+// change are accepted: the journal snapshots files into the thought
+// directory, which the child can read.
+func (h *host) journal(r journal.Record) {
+	if !h.canChange(r.Path) || r.Op == journal.OpMove && !h.canChange(r.Dest) {
+		return
+	}
+	j := h.cfg.Journal
+	switch r.Op {
+	case journal.OpWrite:
+		j.Write(r.Path)
+	case journal.OpMkdir:
+		j.Mkdir(r.Path)
+	case journal.OpMove:
+		j.Move(r.Path, r.Dest)
+	case journal.OpTrash:
+		j.Trash(r.Path, r.TrashID)
+	case journal.OpRemove:
+		j.Remove(r.Path)
+	}
+}
+
+func (h *host) canChange(path string) bool {
+	path = filepath.Clean(path)
+	for _, w := range h.writable {
+		if w != "" && (path == w || strings.HasPrefix(path, w+string(filepath.Separator))) {
+			return true
+		}
+	}
+	return false
+}
+
+func getenv(cfg sandbox.Config, name string) string {
+	if cfg.Getenv != nil {
+		return cfg.Getenv(name)
+	}
+	return os.Getenv(name)
+}
+
+func getSecret(cfg sandbox.Config, name string) (string, error) {
+	if cfg.Secret != nil {
+		return cfg.Secret(name)
+	}
+	return secrets.Get(context.Background(), name)
+}
+
+// ServeChild is think's entry point inside a container. It reads the start
+// message from r, runs the sandbox it describes with every hook relayed to
+// the host over w, and sends the result.
+func ServeChild(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return fmt.Errorf("reading start message: %w", err)
+	}
+	var m message
+	if err := json.Unmarshal(line, &m); err != nil || m.Start == nil {
+		return fmt.Errorf("invalid start message")
+	}
+
+	c := &child{enc: json.NewEncoder(w), pending: map[int64]chan message{}}
+	go c.readReplies(br)
+	result, err := InProcess.Run(context.Background(), c.config(m.Start), m.Start.Code)
+	return c.send(message{Done: newDone(result, err)})
+}
+
+// child relays a sandbox's hooks to the host.
+type child struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	nextID  int64
+	pending map[int64]chan message
+	closed  error // set when the host's side of the pipe is gone
+}
+
+func (c *child) config(st *start) sandbox.Config {
+	cfg := sandbox.Config{
+		AllowedPaths:  st.AllowedPaths,
+		WritablePaths: st.WritablePaths,
+		WorkDir:       st.WorkDir,
+		Stdout:        writerFunc(func(p []byte) { c.send(message{Method: "stdout", Args: []string{string(p)}}) }),
+		Stderr:        writerFunc(func(p []byte) { c.send(message{Method: "stderr", Args: []string{string(p)}}) }),
+		Args:          st.Args,
+		Timeout:       st.Timeout,
+		ReadOnly:      st.ReadOnly,
+		TrashDir:      st.TrashDir,
+		TrashExempt:   st.TrashExempt,
+		Eval:          st.Eval,
+		RedactPaths:   st.RedactPaths,
+		Protected:     st.Protected,
+		Getenv: func(name string) string {
+			reply, _ := c.call("getenv", name)
+			return reply.Value
+		},
+		Secret: func(name string) (string, error) {
+			reply, err := c.call("secret", name)
+			return reply.Value, err
+		},
+	}
+	for _, hook := range st.Hooks {
+		switch hook {
+		case "approvePath":
+			cfg.ApprovePath = func(op, path string) (bool, error) { return c.ask("approvePath", op, path) }
+		case "pathDenied":
+			cfg.PathDenied = func(op, path string) bool {
+				denied, _ := c.ask("pathDenied", op, path)
+				return denied
+			}
+		case "approveEnv":
+			cfg.ApproveEnv = func(name string) (bool, error) { return c.ask("approveEnv", name) }
+		case "approveNet":
+			cfg.ApproveNet = func(host string) (bool, error) { return c.ask("approveNet", host) }
+		case "approveSecret":
+			cfg.ApproveSecret = func(name string) (bool, error) { return c.ask("approveSecret", name) }
+		case "promptInput":
+			cfg.PromptInput = func(question, defaultValue string) (string, error) {
+				reply, err := c.call("promptInput", question, defaultValue)
+				return reply.Value, err
+			}
+		case "onWrite":
+			cfg.OnWrite = func(path, content string) { c.call("onWrite", path, content) }
+		case "onFetch":
+			cfg.OnFetch = func(url string) { c.call("onFetch", url) }
+		case "onRequire":
+			cfg.OnRequire = func(path, sum string) { c.call("onRequire", path, sum) }
+		case "onDownload":
+			cfg.OnDownload = func(url, path, sum string) { c.call("onDownload", url, path, sum) }
+		}
+	}
+	if st.Redact {
+		cfg.Redact = redact.New()
+	}
+	if st.Journal {
+		cfg.Journal = journal.Forward(func(r journal.Record) {
+			c.call("journal", r.Op, r.Path, r.Dest, r.TrashID)
+		})
+	}
+	if st.Workspace != "" {
+		cfg.Workspace = workspace.Forward(st.Workspace, func(path string) (string, error) {
+			reply, err := c.call("promote", path)
+			return reply.Value, err
+		})
+	}
+	return cfg
+}
+
+func (c *child) ask(method string, args ...string) (bool, error) {
+	reply, err := c.call(method, args...)
+	return reply.OK, err
+}
+
+// call sends a call to the host and waits for its reply.
+func (c *child) call(method string, args ...string) (message, error) {
+	c.mu.Lock()
+	if c.closed != nil {
+		c.mu.Unlock()
+		return message{}, c.closed
+	}
+	c.nextID++
+	id := c.nextID
+	ch := make(chan message, 1)
+	c.pending[id] = ch
+	err := c.enc.Encode(message{ID: id, Method: method, Args: args})
+	c.mu.Unlock()
+	if err != nil {
+		return message{}, err
+	}
+
+	reply, ok := <-ch
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return message{}, c.closed
+	}
+	switch {
+	case reply.Interrupted:
+		return reply, approval.ErrInterrupted
+	case reply.Error != "":
+		return reply, errors.New(reply.Error)
+	}
+	return reply, nil
+}
+
+// send sends a message that gets no reply.
+func (c *child) send(m message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc.Encode(m)
+}
+
+// readReplies routes the host's replies to waiting calls until the host
+// closes the pipe, then fails every call still waiting.
+func (c *child) readReplies(br *bufio.Reader) {
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var m message
+			if json.Unmarshal(line, &m) == nil {
+				c.mu.Lock()
+				if ch, ok := c.pending[m.ID]; ok {
+					delete(c.pending, m.ID)
+					ch <- m
+				}
+				c.mu.Unlock()
+			}
+		}
+		if err != nil {
+			c.mu.Lock()
+			c.closed = errors.New("sandbox host closed the connection")
+			for id, ch := range c.pending {
+				delete(c.pending, id)
+				close(ch)
+			}
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+type writerFunc func(p []byte)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	f(p)
+	return len(p), nil
+}
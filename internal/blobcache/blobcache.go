@@ -0,0 +1,272 @@
+// Package blobcache is the download cache shared by every thought.
+// net.download stores what it fetches here once, by content hash, and
+// copies it out again when any thought asks for the same URL (revalidated
+// with its ETag or Last-Modified) or the same SHA-256. `thought cache
+// blobs` lists and prunes it.
+package blobcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+// Entry records one cached URL. Several URLs can share a blob.
+type Entry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	SHA256       string    `json:"sha256"`
+	Size         int64     `json:"size"`
+	Fetched      time.Time `json:"fetched"`
+	LastUsed     time.Time `json:"last_used"`
+}
+
+// Validates reports whether the server gave the entry a validator, so a
+// conditional request can confirm it's still current.
+func (e *Entry) Validates() bool {
+	return e.ETag != "" || e.LastModified != ""
+}
+
+// ErrTooLarge is returned by Store when the content exceeds its limit.
+var ErrTooLarge = errors.New("download exceeds the size limit")
+
+// Dir returns the shared cache directory.
+func Dir() string {
+	return filepath.Join(config.HomeDir(), "cache", "blobs")
+}
+
+// BlobPath is where the content with the given SHA-256 (hex) is stored.
+func BlobPath(dir, sum string) string {
+	return filepath.Join(dir, "sha256", sum)
+}
+
+// HasBlob reports whether the content with the given SHA-256 is cached.
+func HasBlob(dir, sum string) bool {
+	if !validSum(sum) {
+		return false
+	}
+	_, err := os.Stat(BlobPath(dir, sum))
+	return err == nil
+}
+
+func indexPath(dir, url string) string {
+	key := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "index", hex.EncodeToString(key[:])+".json")
+}
+
+// Lookup returns the entry for url, or nil if the URL isn't cached or its
+// blob is gone.
+func Lookup(dir, url string) (*Entry, error) {
+	data, err := os.ReadFile(indexPath(dir, url))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil || e.URL != url || !HasBlob(dir, e.SHA256) {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// Store reads r (at most maxSize bytes) into the cache and records it as
+// the content of url. Content already cached under the same hash is kept
+// as is.
+func Store(dir, url, etag, lastModified string, r io.Reader, maxSize int64) (*Entry, error) {
+	blobs := filepath.Join(dir, "sha256")
+	if err := os.MkdirAll(blobs, 0700); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(blobs, ".download-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, maxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n > maxSize {
+		return nil, ErrTooLarge
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	if !HasBlob(dir, sum) {
+		os.Chmod(tmp.Name(), 0400)
+		if err := os.Rename(tmp.Name(), BlobPath(dir, sum)); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	e := &Entry{URL: url, ETag: etag, LastModified: lastModified, SHA256: sum, Size: n, Fetched: now, LastUsed: now}
+	if err := writeEntry(dir, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Touch marks e as used now, which keeps it through GC's age and size
+// limits longer.
+func Touch(dir string, e *Entry) error {
+	e.LastUsed = time.Now()
+	return writeEntry(dir, e)
+}
+
+// writeEntry replaces e's index file atomically, so parallel runs never
+// read half of one.
+func writeEntry(dir string, e *Entry) error {
+	path := indexPath(dir, e.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// List returns the cached URLs, most recently used first. Entries whose
+// blob is missing are left out.
+func List(dir string) ([]Entry, error) {
+	files, err := os.ReadDir(filepath.Join(dir, "index"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "index", f.Name()))
+		if err != nil {
+			continue
+		}
+		var e Entry
+		if json.Unmarshal(data, &e) != nil || !HasBlob(dir, e.SHA256) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.After(entries[j].LastUsed) })
+	return entries, nil
+}
+
+// GCResult reports what GC removed.
+type GCResult struct {
+	Entries int   // URLs dropped from the index
+	Blobs   int   // content files deleted
+	Freed   int64 // bytes
+}
+
+// GC drops URLs not used within maxAge (0 = no age limit), then the least
+// recently used ones until the blobs still referenced fit in maxSize
+// bytes (0 = no size limit), and deletes every blob no URL refers to.
+func GC(dir string, maxAge time.Duration, maxSize int64) (*GCResult, error) {
+	res := &GCResult{}
+	entries, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// entries is newest first: keep entries until one is too old or
+	// doesn't fit, and drop the rest.
+	keep := map[string]bool{}
+	var total int64
+	full := false
+	for _, e := range entries {
+		if maxAge > 0 && time.Since(e.LastUsed) > maxAge {
+			full = true
+		}
+		if maxSize > 0 && !keep[e.SHA256] && total+e.Size > maxSize {
+			full = true
+		}
+		if !full {
+			if !keep[e.SHA256] {
+				total += e.Size
+			}
+			keep[e.SHA256] = true
+			continue
+		}
+		if err := os.Remove(indexPath(dir, e.URL)); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		res.Entries++
+	}
+
+	// Index files with no blob, or that don't parse, go too.
+	files, _ := os.ReadDir(filepath.Join(dir, "index"))
+	listed := map[string]bool{}
+	for _, e := range entries {
+		listed[filepath.Base(indexPath(dir, e.URL))] = true
+	}
+	for _, f := range files {
+		if !listed[f.Name()] && !inProgress(f) {
+			os.Remove(filepath.Join(dir, "index", f.Name()))
+		}
+	}
+
+	blobs, err := os.ReadDir(filepath.Join(dir, "sha256"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, b := range blobs {
+		if keep[b.Name()] || inProgress(b) {
+			continue
+		}
+		info, err := b.Info()
+		if err != nil || time.Since(info.ModTime()) < time.Minute {
+			continue // just stored; its index entry may not be written yet
+		}
+		if err := os.Remove(filepath.Join(dir, "sha256", b.Name())); err != nil {
+			return nil, err
+		}
+		res.Blobs++
+		res.Freed += info.Size()
+	}
+	return res, nil
+}
+
+// inProgress reports whether f is a recent temporary file of a download
+// or index update that may still be running.
+func inProgress(f os.DirEntry) bool {
+	if !strings.HasPrefix(f.Name(), ".") && !strings.HasSuffix(f.Name(), ".tmp") {
+		return false
+	}
+	info, err := f.Info()
+	return err == nil && time.Since(info.ModTime()) < time.Hour
+}
+
+func validSum(sum string) bool {
+	if len(sum) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
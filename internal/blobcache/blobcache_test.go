@@ -0,0 +1,96 @@
+package blobcache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreLookup(t *testing.T) {
+	dir := t.TempDir()
+
+	if e, err := Lookup(dir, "https://example.com/a"); e != nil || err != nil {
+		t.Fatalf("Lookup on an empty cache = %v, %v", e, err)
+	}
+
+	e, err := Store(dir, "https://example.com/a", `"v1"`, "", strings.NewReader("hello"), 100)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if e.Size != 5 || e.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("entry = %+v", e)
+	}
+	if data, _ := os.ReadFile(BlobPath(dir, e.SHA256)); string(data) != "hello" {
+		t.Errorf("blob = %q", data)
+	}
+
+	got, err := Lookup(dir, "https://example.com/a")
+	if err != nil || got == nil || got.ETag != `"v1"` || !got.Validates() {
+		t.Fatalf("Lookup = %+v, %v", got, err)
+	}
+
+	// A second URL with the same content shares the blob.
+	if _, err := Store(dir, "https://mirror.example.com/a", "", "", strings.NewReader("hello"), 100); err != nil {
+		t.Fatal(err)
+	}
+	blobs, _ := os.ReadDir(filepath.Join(dir, "sha256"))
+	if len(blobs) != 1 {
+		t.Errorf("%d blobs, want 1", len(blobs))
+	}
+
+	if _, err := Store(dir, "https://example.com/big", "", "", strings.NewReader("0123456789"), 5); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Store over the limit: err = %v", err)
+	}
+
+	// An entry whose blob is gone isn't found.
+	os.Remove(BlobPath(dir, e.SHA256))
+	if e, _ := Lookup(dir, "https://example.com/a"); e != nil {
+		t.Errorf("Lookup without a blob = %+v", e)
+	}
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	store := func(url, content string, used time.Time) *Entry {
+		t.Helper()
+		e, err := Store(dir, url, "", "", strings.NewReader(content), 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.LastUsed = used
+		if err := writeEntry(dir, e); err != nil {
+			t.Fatal(err)
+		}
+		// Old enough that GC doesn't take it for a download in progress.
+		old := time.Now().Add(-time.Hour)
+		os.Chtimes(BlobPath(dir, e.SHA256), old, old)
+		return e
+	}
+	now := time.Now()
+	store("https://example.com/new", strings.Repeat("n", 100), now)
+	store("https://example.com/mid", strings.Repeat("m", 100), now.Add(-2*time.Hour))
+	store("https://example.com/old", strings.Repeat("o", 100), now.Add(-48*time.Hour))
+
+	res, err := GC(dir, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Entries != 1 || res.Blobs != 1 || res.Freed != 100 {
+		t.Errorf("GC by age = %+v", res)
+	}
+
+	res, err = GC(dir, 0, 150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Entries != 1 || res.Blobs != 1 {
+		t.Errorf("GC by size = %+v", res)
+	}
+	entries, _ := List(dir)
+	if len(entries) != 1 || entries[0].URL != "https://example.com/new" {
+		t.Errorf("left %+v, want only the newest", entries)
+	}
+}
@@ -1,14 +1,32 @@
 // Package boot handles the memory.js execution flow.
 // It tries to run memory.js first, and returns whether the agent should take over.
+// Every memory.js run goes through here: think's main path and thought debug
+// (TryMemoryJS), stdin: stream (Stream, per line), and --map (Map, per
+// input). All of them read memory.js, Review it, and check its modules
+// against thought.lock the same way first; only TryMemoryJS writes the lock.
 package boot
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
+	"github.com/thinkingscript/cli/internal/backend"
+	"github.com/thinkingscript/cli/internal/blobcache"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/lockfile"
+	"github.com/thinkingscript/cli/internal/redact"
 	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/shared"
+	"github.com/thinkingscript/cli/internal/trash"
+	"github.com/thinkingscript/cli/internal/workspace"
 )
 
 // Result represents the outcome of trying to run memory.js.
@@ -19,84 +37,322 @@ type Result struct {
 	Output string
 	// ResumeContext is the context string for the agent (if resuming).
 	ResumeContext string
+	// Resumed is true if memory.js called agent.resume().
+	Resumed bool
+	// Drift lists the thought.lock modules that changed, when that kept
+	// memory.js from running.
+	Drift []string
+	// Line is the stdin line memory.js couldn't handle (Stream only).
+	Line string
+	// Err is why memory.js didn't run or failed: os.IsNotExist when there
+	// is none, the Review error, or its runtime error. nil on success or
+	// resume.
+	Err error
 }
 
 // Config holds the configuration for running memory.js.
 type Config struct {
-	MemoryJSPath string
-	WorkDir      string
-	ThoughtDir   string // readable but NOT writable (protects policy.json)
-	WorkspaceDir string
-	MemoriesDir  string
-	Args         []string
-	ApprovePath  func(op, path string) (bool, error)
-	ApproveEnv   func(name string) (bool, error)
-	ApproveNet   func(host string) (bool, error)
-}
+	MemoryJSPath  string
+	LockPath      string // thought.lock, verified before and written after memory.js runs; "" = no lockfile
+	SharedDir     string // read-only shared thought whose memory.js runs until MemoryJSPath exists; "" = none
+	WorkDir       string
+	ThoughtDir    string // readable but NOT writable (protects policy.json)
+	WorkspaceDir  string
+	MemoriesDir   string
+	Args          []string
+	ApprovePath   func(op, path string) (bool, error)
+	PathDenied    func(op, path string) bool
+	Journal       *journal.Journal // records fs changes for 'thought undo'; nil = off
+	Workspace     *workspace.Run   // per-run workspace (WorkspaceDir is its Dir); nil = persistent
+	ApproveEnv    func(name string) (bool, error)
+	ApproveNet    func(host string) (bool, error)
+	ApproveSecret func(name string) (bool, error) // secrets.get; nil = deny all
+	ReadOnly      bool                            // reject all writes, including memory.js
+	Backend       backend.Backend                 // where the sandbox runs; nil = in-process
+	Eval          string                          // frontmatter eval mode; "" = after-fetch
 
-// TryMemoryJS attempts to run memory.js if it exists.
-// Returns a Result indicating whether execution succeeded or the agent should resume.
-func TryMemoryJS(ctx context.Context, cfg Config) Result {
-	// Check if memory.js exists
-	if _, err := os.Stat(cfg.MemoryJSPath); os.IsNotExist(err) {
-		return Result{
-			Success:       false,
-			ResumeContext: "no memory.js exists, first run",
-		}
-	}
+	AllowPaths  []string                                            // extra readable paths (think --allow)
+	WritePaths  []string                                            // extra writable paths (think --write)
+	Stdout      io.Writer                                           // memory.js's process.stdout; nil = os.Stdout
+	Stderr      io.Writer                                           // its console.log; nil = os.Stderr
+	OnWrite     func(path, content string)                          // called after its file writes; nil = no-op
+	PromptInput func(question, defaultValue string) (string, error) // answers input.prompt; nil = no input
+	Profile     *sandbox.Profile                                    // think --profile; nil = off
+	Debug       *sandbox.Debug                                      // thought debug; nil = run normally
+	Redact      *redact.Set                                         // secret values to mask in console output, memories, and the resume context; nil = off
+	Protected   []string                                            // built-in protected paths, closed even inside the allowed paths (Approver.ProtectedPaths)
 
-	// Read memory.js
-	code, err := os.ReadFile(cfg.MemoryJSPath)
-	if err != nil {
-		return Result{
-			Success:       false,
-			ResumeContext: fmt.Sprintf("failed to read memory.js: %s", err),
-		}
-	}
+	// Review checks memory.js before it runs (think's lint); an error
+	// stops it, and the agent gets it as resume context. nil = no review.
+	Review func(code string) error
+	// OnRun is called as memory.js starts, for progress display; done is
+	// called when it stops (for Stream and Map, once for the whole run).
+	// nil = quiet.
+	OnRun func(code string) (done func())
+}
 
-	// Create sandbox
+// Sandbox returns the sandbox configuration memory.js runs with. Callers
+// that run memory.js themselves (streams, --map) start from it.
+func (cfg Config) Sandbox() sandbox.Config {
+	stderr := cfg.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
 	// SECURITY: ThoughtDir is readable but NOT writable (protects policy.json)
 	// Only memory.js, workspace, and memories are writable
-	sb, err := sandbox.New(sandbox.Config{
-		AllowedPaths:  []string{cfg.WorkDir, cfg.ThoughtDir, cfg.WorkspaceDir, cfg.MemoriesDir},
-		WritablePaths: []string{cfg.WorkspaceDir, cfg.MemoriesDir, cfg.MemoryJSPath},
+	return sandbox.Config{
+		AllowedPaths:  slices.Concat([]string{cfg.WorkDir, cfg.ThoughtDir, cfg.WorkspaceDir, cfg.MemoriesDir, cfg.MemoryJSPath}, cfg.AllowPaths, cfg.WritePaths),
+		WritablePaths: append([]string{cfg.WorkspaceDir, cfg.MemoriesDir, cfg.MemoryJSPath}, cfg.WritePaths...),
 		WorkDir:       cfg.WorkDir,
-		Stderr:        os.Stderr,
+		Stdout:        cfg.Stdout,
+		Stderr:        stderr,
 		Args:          cfg.Args,
 		Timeout:       -1, // Disable timeout - user can Ctrl+C, and approval prompts would race with timer
 		ApprovePath:   cfg.ApprovePath,
+		PathDenied:    cfg.PathDenied,
 		ApproveEnv:    cfg.ApproveEnv,
 		ApproveNet:    cfg.ApproveNet,
-	})
+		ApproveSecret: cfg.ApproveSecret,
+		PromptInput:   cfg.PromptInput,
+		OnWrite:       cfg.OnWrite,
+		ReadOnly:      cfg.ReadOnly,
+		TrashDir:      trash.Dir(cfg.ThoughtDir),
+		BlobCache:     blobcache.Dir(),
+		TrashExempt:   []string{cfg.WorkspaceDir},
+		Journal:       cfg.Journal,
+		Workspace:     cfg.Workspace,
+		Eval:          cfg.Eval,
+		Debug:         cfg.Debug,
+		Profile:       cfg.Profile,
+		Redact:        cfg.Redact,
+		RedactPaths:   []string{cfg.MemoriesDir},
+		Protected:     cfg.Protected,
+	}
+}
+
+// prepare reads memory.js (the user's, or the shared copy), reviews it,
+// and verifies its modules against thought.lock. It returns the lock when
+// there is one for this memory.js; a non-nil Result is why memory.js
+// can't run.
+func (cfg Config) prepare() ([]byte, *lockfile.Lock, *Result) {
+	code, err := shared.ReadMemoryJS(cfg.MemoryJSPath, cfg.SharedDir)
+	if os.IsNotExist(err) {
+		return nil, nil, &Result{
+			Success:       false,
+			ResumeContext: "no memory.js exists, first run",
+			Err:           err,
+		}
+	}
 	if err != nil {
-		return Result{
+		return nil, nil, &Result{
 			Success:       false,
-			ResumeContext: fmt.Sprintf("failed to create sandbox: %s", err),
+			ResumeContext: fmt.Sprintf("failed to read memory.js: %s", err),
+			Err:           err,
 		}
 	}
 
-	// Run memory.js
-	result, err := sb.Run(ctx, string(code))
-	if err == nil {
-		// Success! memory.js handled everything
-		return Result{
-			Success: true,
-			Output:  result,
+	if cfg.Review != nil {
+		if err := cfg.Review(string(code)); err != nil {
+			return nil, nil, &Result{
+				Success:       false,
+				ResumeContext: fmt.Sprintf("memory.js error: %s", err),
+				Err:           err,
+			}
+		}
+	}
+
+	if cfg.LockPath == "" {
+		return code, nil, nil
+	}
+	lock, err := lockfile.Load(cfg.LockPath)
+	if err != nil {
+		// Rewritten after the next successful run
+		fmt.Fprintf(cfg.Sandbox().Stderr, "warning: %v\n", err)
+	}
+	if lock != nil && !lock.For(code) {
+		lock = nil // memory.js was rewritten since it was locked
+	}
+	if lock != nil {
+		if drift := lock.Verify(filepath.Dir(cfg.LockPath)); drift != nil {
+			return nil, nil, &Result{
+				Success:       false,
+				ResumeContext: lockfile.DriftContext(drift),
+				Drift:         drift,
+				Err:           fmt.Errorf("thought.lock: %s", drift[0]),
+			}
 		}
 	}
+	return code, lock, nil
+}
+
+// TryMemoryJS attempts to run memory.js if it exists.
+// Returns a Result indicating whether execution succeeded or the agent should resume.
+func TryMemoryJS(ctx context.Context, cfg Config) Result {
+	code, lock, fail := cfg.prepare()
+	if fail != nil {
+		return *fail
+	}
+
+	sbCfg := cfg.Sandbox()
+	var deps *lockfile.Recorder
+	if cfg.LockPath != "" {
+		deps = lockfile.NewRecorder()
+		sbCfg.OnRequire = deps.Require
+		sbCfg.OnDownload = deps.Download
+		sbCfg.OnFetch = deps.Fetch
+	}
 
-	// Check if it's a resume request or an error
+	b := cfg.Backend
+	if b == nil {
+		b = backend.InProcess
+	}
+
+	// Run memory.js
+	done := func() {}
+	if cfg.OnRun != nil {
+		done = cfg.OnRun(string(code))
+	}
+	result, err := b.Run(ctx, sbCfg, string(code))
+	done()
+	if err != nil {
+		return cfg.failed(err)
+	}
+
+	// Success! memory.js handled everything
+	if deps != nil && !cfg.ReadOnly {
+		cfg.lock(lock, deps, code, sbCfg.Stderr)
+	}
+	return Result{
+		Success: true,
+		Output:  result,
+	}
+}
+
+// failed turns a memory.js run's error into the Result for the agent: a
+// resume request or a runtime error. The resume context and error go to
+// the agent and the terminal, so they are redacted.
+func (cfg Config) failed(err error) Result {
+	err = cfg.Redact.Error(err)
 	var resumeErr *sandbox.ResumeError
 	if errors.As(err, &resumeErr) {
 		return Result{
 			Success:       false,
-			ResumeContext: resumeErr.Context,
+			ResumeContext: cfg.Redact.String(resumeErr.Context),
+			Resumed:       true,
 		}
 	}
-
-	// Runtime error
 	return Result{
 		Success:       false,
 		ResumeContext: fmt.Sprintf("memory.js error: %s", err),
+		Err:           err,
+	}
+}
+
+// Stream runs memory.js for stdin: stream, in-process whatever
+// cfg.Backend says: it registers a line handler, and each line from lines
+// goes to it in the same runtime. The Result is a Success once input runs
+// out, or names the Line memory.js couldn't handle (or the next line, when
+// memory.js can't run at all) and why. The caller hands that line to the
+// agent and calls Stream again with the same scanner, so memory.js the
+// agent changed is read, reviewed, and checked again. The error is for
+// failures that end the stream: the sandbox, reading stdin, or the "end"
+// handler.
+func Stream(ctx context.Context, cfg Config, lines *bufio.Scanner) (Result, error) {
+	code, _, fail := cfg.prepare()
+	sb, err := sandbox.New(cfg.Sandbox()) // no timeout: streams run until stdin closes
+	if err != nil {
+		return Result{}, fmt.Errorf("creating sandbox: %w", err)
+	}
+
+	// Without memory.js to run, the empty program registers no handler,
+	// which pairs the reason with the next line
+	done := func() {}
+	if fail == nil && cfg.OnRun != nil {
+		done = cfg.OnRun(string(code))
+	}
+	err = sb.Stream(ctx, string(code), lines)
+	done()
+	if err == nil {
+		return Result{Success: true}, nil
+	}
+	var chunkErr *sandbox.ChunkError
+	if !errors.As(err, &chunkErr) {
+		return Result{}, err
+	}
+
+	res := cfg.failed(chunkErr.Err)
+	if fail != nil {
+		res = *fail
+	}
+	res.Line = chunkErr.Line
+	return res, nil
+}
+
+// Map runs memory.js once per input for --map, with the input as the only
+// process.args entry, up to jobs at a time on cfg.Backend, each with the
+// default sandbox timeout. memory.js is read, reviewed, and checked once:
+// when it can't run, nothing does and the second return value says why.
+// Otherwise there is a Result per input, in input order; a successful
+// one's Output is what the input wrote to stdout followed by memory.js's
+// result.
+func Map(ctx context.Context, cfg Config, inputs []string, jobs int) ([]Result, *Result) {
+	code, _, fail := cfg.prepare()
+	if fail != nil {
+		return nil, fail
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	b := cfg.Backend
+	if b == nil {
+		b = backend.InProcess
+	}
+	sbCfg := cfg.Sandbox()
+	sbCfg.Args = nil  // each worker gets its input
+	sbCfg.Timeout = 0 // and the default timeout
+
+	done := func() {}
+	if cfg.OnRun != nil {
+		done = cfg.OnRun(string(code))
+	}
+	results := make([]Result, len(inputs))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var stdout strings.Builder
+			workerCfg := sbCfg
+			workerCfg.Args = []string{input}
+			workerCfg.Stdout = &stdout
+			result, err := b.Run(ctx, workerCfg, string(code))
+			if err != nil {
+				// Partial output from a failed run is discarded; the agent redoes it
+				results[i] = cfg.failed(err)
+				return
+			}
+			results[i] = Result{Success: true, Output: stdout.String() + result}
+		}()
+	}
+	wg.Wait()
+	done()
+	return results, nil
+}
+
+// lock writes thought.lock after a successful run when memory.js has none
+// yet, and otherwise warns about APIs the lock doesn't list.
+func (cfg Config) lock(lock *lockfile.Lock, deps *lockfile.Recorder, code []byte, stderr io.Writer) {
+	if lock == nil {
+		if err := deps.Lock(code, filepath.Dir(cfg.LockPath)).Save(cfg.LockPath); err != nil {
+			fmt.Fprintf(stderr, "warning: writing thought.lock: %v\n", err)
+		}
+		return
+	}
+	for _, api := range lock.Unlocked(deps) {
+		fmt.Fprintf(stderr, "warning: thought.lock: memory.js used %s, which it wasn't locked with\n", api)
 	}
 }
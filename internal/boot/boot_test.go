@@ -1,7 +1,9 @@
 package boot
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -279,3 +281,189 @@ func TestMemoryJSFileAccess(t *testing.T) {
 		t.Errorf("Output = %q, want %q", result.Output, "test data")
 	}
 }
+
+func TestMemoryJSHooks(t *testing.T) {
+	dir := t.TempDir()
+	workspaceDir := filepath.Join(dir, "workspace")
+	os.MkdirAll(workspaceDir, 0700)
+	memoryJSPath := filepath.Join(dir, "memory.js")
+	out := filepath.Join(workspaceDir, "out.txt")
+	code := `process.stdout.write("hi"); console.log("note"); fs.writeFile("` + out + `", "x"); "done"`
+	if err := os.WriteFile(memoryJSPath, []byte(code), 0644); err != nil {
+		t.Fatalf("failed to create memory.js: %v", err)
+	}
+
+	var stdout, stderr strings.Builder
+	var writes, events []string
+	cfg := Config{
+		MemoryJSPath: memoryJSPath,
+		WorkDir:      dir,
+		ThoughtDir:   dir,
+		WorkspaceDir: workspaceDir,
+		MemoriesDir:  filepath.Join(dir, "memories"),
+		Stdout:       &stdout,
+		Stderr:       &stderr,
+		OnWrite:      func(path, content string) { writes = append(writes, path) },
+		Review: func(got string) error {
+			events = append(events, "review")
+			if got != code {
+				t.Errorf("Review got %q, want memory.js", got)
+			}
+			return nil
+		},
+		OnRun: func(string) func() {
+			events = append(events, "run")
+			return func() { events = append(events, "done") }
+		},
+	}
+
+	result := TryMemoryJS(context.Background(), cfg)
+
+	if !result.Success || result.Err != nil {
+		t.Fatalf("expected success, got ResumeContext=%q Err=%v", result.ResumeContext, result.Err)
+	}
+	if stdout.String() != "hi" {
+		t.Errorf("stdout = %q, want %q", stdout.String(), "hi")
+	}
+	if !strings.Contains(stderr.String(), "note") {
+		t.Errorf("stderr = %q, want console.log output", stderr.String())
+	}
+	if len(writes) != 1 || writes[0] != out {
+		t.Errorf("OnWrite calls = %v, want [%s]", writes, out)
+	}
+	if strings.Join(events, ",") != "review,run,done" {
+		t.Errorf("hooks ran %v, want review, run, done", events)
+	}
+}
+
+func TestMemoryJSReviewBlocks(t *testing.T) {
+	dir := t.TempDir()
+	memoryJSPath := filepath.Join(dir, "memory.js")
+	if err := os.WriteFile(memoryJSPath, []byte(`"ran"`), 0644); err != nil {
+		t.Fatalf("failed to create memory.js: %v", err)
+	}
+
+	ran := false
+	cfg := Config{
+		MemoryJSPath: memoryJSPath,
+		WorkDir:      dir,
+		ThoughtDir:   dir,
+		WorkspaceDir: filepath.Join(dir, "workspace"),
+		MemoriesDir:  filepath.Join(dir, "memories"),
+		Review:       func(string) error { return errors.New("blocked by lint") },
+		OnRun:        func(string) func() { ran = true; return func() {} },
+	}
+
+	result := TryMemoryJS(context.Background(), cfg)
+
+	if result.Success || result.Resumed || ran {
+		t.Errorf("memory.js ran after Review refused it: %+v", result)
+	}
+	if result.ResumeContext != "memory.js error: blocked by lint" {
+		t.Errorf("ResumeContext = %q", result.ResumeContext)
+	}
+}
+
+func TestMemoryJSResultKinds(t *testing.T) {
+	dir := t.TempDir()
+	memoryJSPath := filepath.Join(dir, "memory.js")
+	cfg := Config{
+		MemoryJSPath: memoryJSPath,
+		WorkDir:      dir,
+		ThoughtDir:   dir,
+		WorkspaceDir: filepath.Join(dir, "workspace"),
+		MemoriesDir:  filepath.Join(dir, "memories"),
+	}
+
+	if result := TryMemoryJS(context.Background(), cfg); !os.IsNotExist(result.Err) {
+		t.Errorf("missing memory.js: Err = %v, want not-exist", result.Err)
+	}
+
+	os.WriteFile(memoryJSPath, []byte(`agent.resume("help")`), 0644)
+	if result := TryMemoryJS(context.Background(), cfg); !result.Resumed || result.Err != nil {
+		t.Errorf("agent.resume(): Resumed = %v, Err = %v", result.Resumed, result.Err)
+	}
+
+	os.WriteFile(memoryJSPath, []byte(`throw new Error("boom")`), 0644)
+	if result := TryMemoryJS(context.Background(), cfg); result.Resumed || result.Err == nil {
+		t.Errorf("throw: Resumed = %v, Err = %v", result.Resumed, result.Err)
+	}
+}
+
+func TestSandboxConfig(t *testing.T) {
+	cfg := Config{
+		MemoryJSPath: "/t/memory.js",
+		WorkDir:      "/cwd",
+		ThoughtDir:   "/t",
+		WorkspaceDir: "/t/workspace",
+		MemoriesDir:  "/t/memories",
+		AllowPaths:   []string{"/data"},
+		WritePaths:   []string{"/out"},
+	}
+
+	sb := cfg.Sandbox()
+
+	if strings.Join(sb.AllowedPaths, " ") != "/cwd /t /t/workspace /t/memories /t/memory.js /data /out" {
+		t.Errorf("AllowedPaths = %v", sb.AllowedPaths)
+	}
+	// SECURITY: the thought directory (policy.json) is never writable
+	if strings.Join(sb.WritablePaths, " ") != "/t/workspace /t/memories /t/memory.js /out" {
+		t.Errorf("WritablePaths = %v", sb.WritablePaths)
+	}
+	if sb.Timeout != -1 || sb.Stderr != os.Stderr {
+		t.Errorf("Timeout = %v, Stderr = %v; want no timeout and os.Stderr", sb.Timeout, sb.Stderr)
+	}
+}
+
+func TestLockDrift(t *testing.T) {
+	dir := t.TempDir()
+	memoryJSPath := filepath.Join(dir, "memory.js")
+	libPath := filepath.Join(dir, "workspace", "lib.js")
+	os.MkdirAll(filepath.Dir(libPath), 0755)
+	os.WriteFile(libPath, []byte(`module.exports = "v1";`), 0644)
+	os.WriteFile(memoryJSPath, []byte(`require("./workspace/lib.js")`), 0644)
+
+	cfg := Config{
+		MemoryJSPath: memoryJSPath,
+		LockPath:     filepath.Join(dir, "thought.lock"),
+		WorkDir:      dir,
+		ThoughtDir:   dir,
+		WorkspaceDir: filepath.Join(dir, "workspace"),
+		MemoriesDir:  filepath.Join(dir, "memories"),
+	}
+
+	// The first successful run writes the lock
+	if result := TryMemoryJS(context.Background(), cfg); !result.Success {
+		t.Fatalf("first run failed: %q", result.ResumeContext)
+	}
+	if _, err := os.Stat(cfg.LockPath); err != nil {
+		t.Fatalf("no lock written: %v", err)
+	}
+
+	// A changed module stops memory.js and tells the agent why
+	os.WriteFile(libPath, []byte(`module.exports = "v2";`), 0644)
+	result := TryMemoryJS(context.Background(), cfg)
+	if result.Success || len(result.Drift) != 1 {
+		t.Fatalf("Success = %v, Drift = %v; want the changed module", result.Success, result.Drift)
+	}
+	if !strings.Contains(result.ResumeContext, "workspace/lib.js changed") {
+		t.Errorf("ResumeContext = %q", result.ResumeContext)
+	}
+	// and so do the stream and --map paths
+	lines := bufio.NewScanner(strings.NewReader("a\n"))
+	if result, err := Stream(context.Background(), cfg, lines); err != nil || len(result.Drift) != 1 || result.Line != "a" {
+		t.Errorf("Stream = %+v, %v; want the changed module with line a", result, err)
+	}
+	if results, fail := Map(context.Background(), cfg, []string{"a"}, 1); results != nil || fail == nil || len(fail.Drift) != 1 {
+		t.Errorf("Map = %v, %+v; want the changed module", results, fail)
+	}
+
+	// A rewritten memory.js isn't held to the old lock
+	os.WriteFile(memoryJSPath, []byte(`require("./workspace/lib.js") + "!"`), 0644)
+	if result := TryMemoryJS(context.Background(), cfg); !result.Success {
+		t.Fatalf("rewritten memory.js failed: %q", result.ResumeContext)
+	}
+	if result := TryMemoryJS(context.Background(), cfg); !result.Success || result.Output != "v2!" {
+		t.Errorf("relocked run: Success = %v, Output = %q", result.Success, result.Output)
+	}
+}
@@ -0,0 +1,177 @@
+// Package codecheck runs an organization's pre-execution check on code the
+// agent wants to run. The check is an external command (request on stdin,
+// verdict on stdout) or an HTTP endpoint (request POSTed as JSON, verdict
+// in the response), configured by code_check in config.json.
+package codecheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+const (
+	DefaultTimeout = 30 * time.Second
+	maxVerdictSize = 1 << 20
+)
+
+// Decisions a check can return.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// Request is what the check receives.
+type Request struct {
+	Tool    string `json:"tool"`   // "run_script"
+	Code    string `json:"code"`   // the JavaScript to be run
+	Reason  string `json:"reason"` // the agent's stated purpose, may be empty
+	Thought string `json:"thought"`
+	Script  string `json:"script"`
+	WorkDir string `json:"workdir"`
+}
+
+// Verdict is the check's answer. Annotations are notes passed on to the
+// user and the model either way.
+type Verdict struct {
+	Decision    string   `json:"decision"`
+	Reason      string   `json:"reason,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// Hook calls the configured check.
+type Hook struct {
+	Command  []string          // argv; used when set
+	URL      string            // otherwise POSTed to
+	Headers  map[string]string // extra HTTP headers, e.g. authorization
+	Timeout  time.Duration
+	FailOpen bool // allow when the check itself fails
+
+	client *http.Client
+}
+
+// FromConfig builds a Hook from config.json's code_check. It returns nil
+// when no check is configured.
+func FromConfig(cfg *config.CodeCheckConfig) (*Hook, error) {
+	if cfg == nil || (cfg.Command == "" && cfg.URL == "") {
+		return nil, nil
+	}
+	if cfg.Command != "" && cfg.URL != "" {
+		return nil, errors.New("code_check: set command or url, not both")
+	}
+	h := &Hook{
+		Command:  strings.Fields(cfg.Command),
+		URL:      cfg.URL,
+		Headers:  cfg.Headers,
+		Timeout:  DefaultTimeout,
+		FailOpen: cfg.FailOpen,
+	}
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil || u.Scheme != "https" && u.Scheme != "http" {
+			return nil, errors.New("code_check: url must be an http(s) URL")
+		}
+	}
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("code_check: invalid timeout %q", cfg.Timeout)
+		}
+		h.Timeout = d
+	}
+	return h, nil
+}
+
+// Decide runs the check and always returns a verdict: when the check
+// fails, the code is denied (or allowed with an annotation if FailOpen).
+func (h *Hook) Decide(ctx context.Context, req Request) Verdict {
+	v, err := h.Check(ctx, req)
+	if err == nil {
+		return v
+	}
+	if h.FailOpen {
+		return Verdict{Decision: Allow, Annotations: []string{"code check unavailable, allowed anyway: " + err.Error()}}
+	}
+	return Verdict{Decision: Deny, Reason: "code check unavailable: " + err.Error()}
+}
+
+// Check runs the check once. Anything but a well-formed allow or deny
+// verdict is an error.
+func (h *Hook) Check(ctx context.Context, req Request) (Verdict, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Verdict{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	var out []byte
+	if len(h.Command) > 0 {
+		out, err = h.runCommand(ctx, body)
+	} else {
+		out, err = h.post(ctx, body)
+	}
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	var v Verdict
+	if err := json.Unmarshal(out, &v); err != nil {
+		return Verdict{}, fmt.Errorf("malformed verdict: %w", err)
+	}
+	v.Decision = strings.ToLower(strings.TrimSpace(v.Decision))
+	if v.Decision != Allow && v.Decision != Deny {
+		return Verdict{}, fmt.Errorf("verdict has decision %q, want allow or deny", v.Decision)
+	}
+	return v, nil
+}
+
+func (h *Hook) runCommand(ctx context.Context, body []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %w", h.Command[0], err)
+	}
+	if stdout.Len() > maxVerdictSize {
+		return nil, fmt.Errorf("%s: verdict too large", h.Command[0])
+	}
+	return stdout.Bytes(), nil
+}
+
+func (h *Hook) post(ctx context.Context, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+	client := h.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s returned %s", h.URL, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxVerdictSize))
+}
@@ -0,0 +1,108 @@
+package codecheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+// TestMain doubles as a check command: with CODECHECK_TEST_CHILD set it
+// denies code mentioning "rm", allows the rest, and exits 2 for "crash".
+func TestMain(m *testing.M) {
+	if os.Getenv("CODECHECK_TEST_CHILD") == "1" {
+		var req Request
+		json.NewDecoder(os.Stdin).Decode(&req)
+		switch {
+		case strings.Contains(req.Code, "crash"):
+			os.Exit(2)
+		case strings.Contains(req.Code, "rm"):
+			fmt.Print(`{"decision": "deny", "reason": "deletes files"}`)
+		default:
+			fmt.Print(`{"decision": "Allow", "annotations": ["looked at ` + req.Thought + `"]}`)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestCommand(t *testing.T) {
+	t.Setenv("CODECHECK_TEST_CHILD", "1")
+	h := &Hook{Command: []string{os.Args[0]}, Timeout: 10 * time.Second}
+	ctx := context.Background()
+
+	v := h.Decide(ctx, Request{Code: "1 + 1", Thought: "demo"})
+	if v.Decision != Allow || len(v.Annotations) != 1 || v.Annotations[0] != "looked at demo" {
+		t.Errorf("allowed verdict = %+v", v)
+	}
+	if v := h.Decide(ctx, Request{Code: "fs.delete('rm')"}); v.Decision != Deny || v.Reason != "deletes files" {
+		t.Errorf("denied verdict = %+v", v)
+	}
+
+	// A failing check denies unless FailOpen
+	if v := h.Decide(ctx, Request{Code: "crash"}); v.Decision != Deny || !strings.Contains(v.Reason, "unavailable") {
+		t.Errorf("failed check = %+v, want deny", v)
+	}
+	h.FailOpen = true
+	if v := h.Decide(ctx, Request{Code: "crash"}); v.Decision != Allow || len(v.Annotations) != 1 {
+		t.Errorf("failed check with FailOpen = %+v, want allow with annotation", v)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case r.Header.Get("Authorization") != "Bearer t":
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.Code == "bad":
+			fmt.Fprint(w, `{"decision": "maybe"}`)
+		default:
+			fmt.Fprint(w, `{"decision": "deny", "reason": "tool `+req.Tool+`"}`)
+		}
+	}))
+	defer srv.Close()
+
+	h, err := FromConfig(&config.CodeCheckConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if v, err := h.Check(ctx, Request{Tool: "run_script"}); err != nil || v.Decision != Deny || v.Reason != "tool run_script" {
+		t.Errorf("Check = %+v, %v", v, err)
+	}
+	if _, err := h.Check(ctx, Request{Code: "bad"}); err == nil {
+		t.Error("unknown decision accepted")
+	}
+	h.Headers = nil
+	if _, err := h.Check(ctx, Request{}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("unauthorized error = %v", err)
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	if h, err := FromConfig(nil); h != nil || err != nil {
+		t.Errorf("nil config = %v, %v", h, err)
+	}
+	for _, cfg := range []config.CodeCheckConfig{
+		{Command: "check", URL: "https://x"},
+		{URL: "file:///etc/passwd"},
+		{Command: "check", Timeout: "soon"},
+	} {
+		if _, err := FromConfig(&cfg); err == nil {
+			t.Errorf("FromConfig(%+v) accepted", cfg)
+		}
+	}
+	h, err := FromConfig(&config.CodeCheckConfig{Command: "scan --strict", Timeout: "5s"})
+	if err != nil || len(h.Command) != 2 || h.Timeout != 5*time.Second {
+		t.Errorf("FromConfig = %+v, %v", h, err)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
 const (
@@ -16,37 +18,200 @@ const (
 	DefaultModel         = "claude-sonnet-4-5-20250929"
 	DefaultMaxTokens     = 4096
 	DefaultMaxIterations = 50
+	DefaultIterationCap  = 200 // absolute ceiling; frontmatter can't exceed it
+	DefaultSnapshots     = 5   // workspace snapshots kept per thought
 )
 
 type Config struct {
-	Version       int    `json:"version"`
-	Agent         string `json:"agent"`
-	MaxTokens     int    `json:"max_tokens"`
-	MaxIterations int    `json:"max_iterations"`
+	Version       int                    `json:"version"`
+	Agent         string                 `json:"agent"`
+	MaxTokens     int                    `json:"max_tokens"`
+	MaxIterations int                    `json:"max_iterations"`
+	IterationCap  int                    `json:"iteration_cap,omitempty"` // safety cap on any max_iterations
+	Trust         map[string]TrustConfig `json:"trust,omitempty"`         // per-origin default approvals
+	Snapshots     int                    `json:"snapshots,omitempty"`     // workspace snapshots to keep; negative disables
+	Git           bool                   `json:"git,omitempty"`           // commit memory.js/memories changes for every thought
+	ThoughtPath   []string               `json:"thought_path,omitempty"`  // read-only shared thought dirs layered under the user's
+	Prefer        string                 `json:"prefer,omitempty"`        // "file" or "installed" for a name that is both; "" or "ask" = prompt
+	FastPath      *bool                  `json:"fast_path,omitempty"`     // write memory.js for trivial prompts without the agent; nil = on
+	CostConfirm   float64                `json:"cost_confirm,omitempty"`  // dollars; confirm runs estimated above this
+	CostCeiling   float64                `json:"cost_ceiling,omitempty"`  // dollars; confirm each time a run spends this much more
+	Routes        map[string]string      `json:"routes,omitempty"`        // resume kind → model; see Route
+	CodeCheck     *CodeCheckConfig       `json:"code_check,omitempty"`    // pre-execution check of run_script code
+	Lint          map[string]string      `json:"lint,omitempty"`          // lint rule → off, warn, or deny; see internal/lint
+	FetchGuard    *FetchGuardConfig      `json:"fetch_guard,omitempty"`   // mark fetched content as untrusted; see internal/fetchguard
+
+	// Retries for transient provider errors; see provider.RetryProvider
+	RetryAttempts int    `json:"retry_attempts,omitempty"` // tries per request, including the first; 1 = no retries; 0 = default
+	RetryMaxWait  string `json:"retry_max_wait,omitempty"` // longest wait between tries, e.g. "30s"
+
+	// Estimated request tokens at which the agent compacts old turns; 0 =
+	// default, negative = never. See agent.SetContextLimit
+	ContextLimit int `json:"context_limit,omitempty"`
+
+	// When the agent replaces old bulky tool results with a summary and a
+	// workspace file; 0 = default, negative prune_after = never. See
+	// agent.SetPruning
+	PruneAfter    int `json:"prune_after,omitempty"`     // turns before pruning starts
+	PruneKeep     int `json:"prune_keep,omitempty"`      // latest turns never pruned
+	PruneMinChars int `json:"prune_min_chars,omitempty"` // smaller results are kept
+
+	// run_script calls from one turn that run at once; 1 = one at a time;
+	// 0 = default. See tools.ExecuteAll
+	ParallelTools int `json:"parallel_tools,omitempty"`
+
+	// Per-model prices, overriding or extending the built-in table used
+	// for usage summaries and cost limits; see cost.Lookup
+	Prices map[string]ModelPrice `json:"prices,omitempty"`
+
+	// Hard limits per run; the agent stops once either is used up.
+	// Frontmatter can only lower them; see Resolve
+	MaxCost        float64 `json:"max_cost,omitempty"`         // dollars
+	MaxTotalTokens int     `json:"max_total_tokens,omitempty"` // input plus output, all calls
+
+	// Organization-wide policy merged in as protected entries; see internal/managed
+	ManagedPolicyURL     string `json:"managed_policy_url,omitempty"`
+	ManagedPolicyKey     string `json:"managed_policy_key,omitempty"`     // base64 ed25519 public key
+	ManagedPolicyRefresh string `json:"managed_policy_refresh,omitempty"` // refetch interval, e.g. "1h"
+
+	// Where sandboxes run; see internal/backend
+	Backend         string `json:"backend,omitempty"`          // "process" (default), "docker", or "podman"
+	ContainerImage  string `json:"container_image,omitempty"`  // image for container backends
+	ContainerBinary string `json:"container_binary,omitempty"` // linux think binary run inside the container
+
+	// Language for prompts and labels, e.g. "de"; default from the
+	// environment. See internal/i18n
+	Locale string `json:"locale,omitempty"`
+
+	// Plain numbered prompts without live redraws, for screen readers;
+	// see ui.Accessible
+	Accessible bool `json:"accessible,omitempty"`
+}
+
+// CodeCheckConfig names the check run on agent code before it executes;
+// see internal/codecheck.
+type CodeCheckConfig struct {
+	Command  string            `json:"command,omitempty"` // split on whitespace; request on stdin, verdict on stdout
+	URL      string            `json:"url,omitempty"`     // request POSTed as JSON
+	Headers  map[string]string `json:"headers,omitempty"` // for url
+	Timeout  string            `json:"timeout,omitempty"` // default 30s
+	FailOpen bool              `json:"fail_open,omitempty"`
+}
+
+// FetchGuardConfig turns on internal/fetchguard for run_script results
+// that include fetched content.
+type FetchGuardConfig struct {
+	ApproveOverKB int `json:"approve_over_kb,omitempty"` // larger results need approval; 0 = never asked
+}
+
+// ModelPrice is a model's price in dollars per million tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	Cached float64 `json:"cached,omitempty"` // cached input; 0 = as input
 }
 
 type AgentConfig struct {
-	Version  int    `json:"version"`
-	Provider string `json:"provider"`
-	APIKey   string `json:"api_key"`
-	APIBase  string `json:"api_base"`
-	Model    string `json:"model"`
+	Version          int    `json:"version"`
+	Provider         string `json:"provider"`
+	APIKey           string `json:"api_key"`
+	APIBase          string `json:"api_base"`
+	Model            string `json:"model"`
+	CredentialHelper string `json:"credential_helper,omitempty"` // command that prints a short-lived key per run
+	Region           string `json:"region,omitempty"`            // bedrock/vertex region
+	Project          string `json:"project,omitempty"`           // vertex GCP project
+
+	// OpenAI-compatible API shape (Azure, OpenRouter, ...); see provider.OpenAIConfig
+	ChatPath string            `json:"chat_path,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Models   map[string]string `json:"models,omitempty"`
+
+	// Models tried in order when the model fails in a way another might
+	// not (overloaded, unknown or retired, context exceeded); see
+	// provider.FallbackProvider
+	FallbackModels []string `json:"fallback_models,omitempty"`
 }
 
 type ScriptConfig struct {
-	Agent     string `json:"agent" yaml:"agent"`
-	Model     string `json:"model" yaml:"model"`
-	MaxTokens *int   `json:"max_tokens" yaml:"max_tokens"`
+	Agent         string `json:"agent" yaml:"agent"`
+	Model         string `json:"model" yaml:"model"`
+	MaxTokens     *int   `json:"max_tokens" yaml:"max_tokens"`
+	MaxIterations *int   `json:"max_iterations" yaml:"max_iterations"`
+	Name          string `json:"name" yaml:"name"` // thought directory name; defaults to the file name
+	WorkDir       string `json:"workdir" yaml:"workdir"`
+	DataDir       string `json:"data_dir" yaml:"data_dir"`   // relocates memory.js, workspace, and memories
+	Stdin         string `json:"stdin" yaml:"stdin"`         // "stream" feeds memory.js one line at a time
+	Memoize       string `json:"memoize" yaml:"memoize"`     // TTL (e.g. "1h") for caching converged stdout
+	Workspace     string `json:"workspace" yaml:"workspace"` // "per-run" gives each run a fresh workspace
+	Git           bool   `json:"git" yaml:"git"`             // commit memory.js/memories changes after each run
+	Eval          string `json:"eval" yaml:"eval"`           // "after-fetch" (default), "allow", or "deny"
+
+	// Sampling, for providers that support it; unset = the provider's
+	// default. See ValidateSampling
+	Temperature *float64 `json:"temperature" yaml:"temperature"`
+	TopP        *float64 `json:"top_p" yaml:"top_p"`
+	Stop        []string `json:"stop" yaml:"stop"` // stop sequences
+
+	// Per-run budgets; can only lower config.json's
+	MaxCost        *float64 `json:"max_cost" yaml:"max_cost"`
+	MaxTotalTokens *int     `json:"max_total_tokens" yaml:"max_total_tokens"`
+
+	MCP []MCPServer `json:"mcp" yaml:"mcp"` // servers whose tools the agent gets
+
+	// What the thought does and the arguments it takes, for programs that
+	// call it (think serve)
+	Description string     `json:"description" yaml:"description"`
+	Arguments   []Argument `json:"arguments" yaml:"arguments"`
+}
+
+// Argument is one of a thought's positional arguments, in order.
+type Argument struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	Required    bool   `json:"required" yaml:"required"`
+}
+
+// MCPServer is an MCP server a thought's agent can use, started as a
+// subprocess and spoken to over its stdin and stdout.
+type MCPServer struct {
+	Name    string            `json:"name" yaml:"name"` // prefixes its tools; defaults to the command's base name
+	Command string            `json:"command" yaml:"command"`
+	Args    []string          `json:"args" yaml:"args"`
+	Env     map[string]string `json:"env" yaml:"env"` // added to the inherited environment
 }
 
 // ResolvedConfig holds the final merged configuration.
 type ResolvedConfig struct {
-	Provider      string
-	APIKey        string
-	APIBase       string
-	Model         string
-	MaxTokens     int
-	MaxIterations int
+	Agent            string
+	Provider         string
+	APIKey           string
+	CredentialHelper string // set when the key must come from FetchCredential
+	APIBase          string
+	Region           string
+	Project          string
+	ChatPath         string
+	Headers          map[string]string
+	Models           map[string]string
+	Model            string
+	FallbackModels   []string
+	MaxTokens        int
+	MaxIterations    int
+	Temperature      *float64 // nil = provider default
+	TopP             *float64 // nil = provider default
+	Stop             []string
+	CostConfirm      float64 // 0 = no cost preview
+	CostCeiling      float64 // 0 = no mid-run ceiling
+	Routes           map[string]string
+	RetryAttempts    int           // 0 = provider.DefaultRetryAttempts
+	RetryMaxWait     time.Duration // 0 = provider.DefaultRetryMaxWait
+	ParallelTools    int           // 0 = tools.DefaultParallelism
+	ContextLimit     int           // 0 = agent.DefaultContextLimit, < 0 = no compaction
+	PruneAfter       int           // 0 = agent.DefaultPruneAfter, < 0 = no pruning
+	PruneKeep        int           // 0 = agent.DefaultPruneKeep
+	PruneMinChars    int           // 0 = agent.DefaultPruneMinChars
+	Prices           map[string]ModelPrice
+	MaxCost          float64 // dollars per run; 0 = no limit
+	MaxTotalTokens   int     // tokens per run; 0 = no limit
 }
 
 func HomeDir() string {
@@ -95,28 +260,111 @@ func BinDir() string {
 	return filepath.Join(HomeDir(), "bin")
 }
 
-// ThoughtDir returns the per-thought data directory for a given script.
+// ThoughtDir returns the per-thought data directory for a given script
+// without a frontmatter name. See LocateThought.
 func ThoughtDir(scriptPath string) string {
-	return filepath.Join(HomeDir(), "thoughts", ThoughtName(scriptPath))
+	dir, _ := LocateThought(scriptPath, "")
+	return dir
 }
 
 // WorkspaceDir returns the workspace directory for a given script.
 // This is the agent's scratch space for files, modules, temp data, etc.
 func WorkspaceDir(scriptPath string) string {
-	return filepath.Join(ThoughtDir(scriptPath), "workspace")
+	return filepath.Join(ThoughtDataDir(ThoughtDir(scriptPath)), "workspace")
 }
 
 // MemoryJSPath returns the path to memory.js for a given script.
 // This is the static script that runs first before calling the agent.
 func MemoryJSPath(scriptPath string) string {
-	return filepath.Join(ThoughtDir(scriptPath), "memory.js")
+	return filepath.Join(ThoughtDataDir(ThoughtDir(scriptPath)), "memory.js")
 }
 
 // MemoriesDir returns the memories directory for a given script.
 // Memories are stored by thought name, not content hash, so they
 // survive script edits and binary rebuilds.
 func MemoriesDir(scriptPath string) string {
-	return filepath.Join(ThoughtDir(scriptPath), "memories")
+	return filepath.Join(ThoughtDataDir(ThoughtDir(scriptPath)), "memories")
+}
+
+// ResolveWorkDir validates a working-directory override and returns it as a
+// clean absolute path. "~" expands to the user's home directory; relative
+// paths are resolved against base.
+func ResolveWorkDir(dir, base string) (string, error) {
+	if dir == "~" || strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("expanding %s: %w", dir, err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(base, dir)
+	}
+	dir = filepath.Clean(dir)
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("working directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("working directory %s is not a directory", dir)
+	}
+	return dir, nil
+}
+
+// ResolveScriptWorkDir resolves a frontmatter workdir, relative to the
+// script's directory. The script's author chose it, not the person running
+// it, and the sandbox can read everything in it, so it must not be a
+// filesystem root, the user's home directory, the thinkingscript home or
+// anything inside it, or a directory containing either home. Remote
+// scripts, and thoughts installed from a URL, can only name the current
+// directory; run them with --cwd to pick another.
+func ResolveScriptWorkDir(dir, scriptPath, thoughtDir string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("getting working directory: %w", err)
+	}
+	if ResolveOrigin(scriptPath, thoughtDir) == OriginURL {
+		resolved, err := ResolveWorkDir(dir, cwd)
+		if err != nil {
+			return "", err
+		}
+		if realPath(resolved) != realPath(cwd) {
+			return "", fmt.Errorf("workdir %s is not allowed for remote scripts; use --cwd to choose a working directory", resolved)
+		}
+		return resolved, nil
+	}
+
+	scriptDir, err := filepath.Abs(filepath.Dir(scriptPath))
+	if err != nil {
+		return "", err
+	}
+	resolved, err := ResolveWorkDir(dir, scriptDir)
+	if err != nil {
+		return "", err
+	}
+	real := realPath(resolved)
+	unsafe := func(reason string) error {
+		return fmt.Errorf("workdir %s is not allowed: %s", resolved, reason)
+	}
+	if filepath.Dir(real) == real {
+		return "", unsafe("it is a filesystem root")
+	}
+	tsHome, _ := filepath.Abs(HomeDir())
+	tsHome = realPath(tsHome)
+	if within(real, tsHome) {
+		return "", unsafe("it is inside " + tsHome)
+	}
+	guarded := []string{tsHome}
+	if home, err := os.UserHomeDir(); err == nil {
+		guarded = append(guarded, realPath(home))
+	}
+	for _, g := range guarded {
+		if within(g, real) {
+			return "", unsafe("it contains " + g)
+		}
+	}
+	return resolved, nil
 }
 
 func LoadConfig() *Config {
@@ -125,6 +373,8 @@ func LoadConfig() *Config {
 		Agent:         DefaultAgent,
 		MaxTokens:     DefaultMaxTokens,
 		MaxIterations: DefaultMaxIterations,
+		IterationCap:  DefaultIterationCap,
+		Snapshots:     DefaultSnapshots,
 	}
 
 	path := filepath.Join(HomeDir(), "config.json")
@@ -143,6 +393,12 @@ func LoadConfig() *Config {
 	if cfg.MaxIterations == 0 {
 		cfg.MaxIterations = DefaultMaxIterations
 	}
+	if cfg.IterationCap == 0 {
+		cfg.IterationCap = DefaultIterationCap
+	}
+	if cfg.Snapshots == 0 {
+		cfg.Snapshots = DefaultSnapshots
+	}
 	return cfg
 }
 
@@ -174,6 +430,30 @@ func SaveAgent(name string, agent *AgentConfig) error {
 	return nil
 }
 
+// SetDefaultAgent makes name the agent in config.json, keeping the file's
+// other settings.
+func SetDefaultAgent(name string) error {
+	path := filepath.Join(HomeDir(), "config.json")
+	fields := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if _, ok := fields["version"]; !ok {
+		fields["version"] = json.RawMessage("1")
+	}
+	agent, _ := json.Marshal(name)
+	fields["agent"] = agent
+	data, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
 // Resolve merges config layers: defaults < config.yaml < agent.yaml < frontmatter < env vars
 func Resolve(scriptCfg *ScriptConfig) *ResolvedConfig {
 	cfg := LoadConfig()
@@ -190,12 +470,35 @@ func Resolve(scriptCfg *ScriptConfig) *ResolvedConfig {
 	agent := LoadAgent(agentName)
 
 	resolved := &ResolvedConfig{
-		Provider:      agent.Provider,
-		APIKey:        agent.APIKey,
-		APIBase:       agent.APIBase,
-		Model:         agent.Model,
-		MaxTokens:     cfg.MaxTokens,
-		MaxIterations: cfg.MaxIterations,
+		Agent:            agentName,
+		Provider:         agent.Provider,
+		APIKey:           agent.APIKey,
+		CredentialHelper: agent.CredentialHelper,
+		APIBase:          agent.APIBase,
+		Region:           agent.Region,
+		Project:          agent.Project,
+		ChatPath:         agent.ChatPath,
+		Headers:          agent.Headers,
+		Models:           agent.Models,
+		Model:            agent.Model,
+		FallbackModels:   agent.FallbackModels,
+		MaxTokens:        cfg.MaxTokens,
+		MaxIterations:    cfg.MaxIterations,
+		CostConfirm:      cfg.CostConfirm,
+		CostCeiling:      cfg.CostCeiling,
+		Routes:           cfg.Routes,
+		RetryAttempts:    cfg.RetryAttempts,
+		ParallelTools:    cfg.ParallelTools,
+		ContextLimit:     cfg.ContextLimit,
+		PruneAfter:       cfg.PruneAfter,
+		PruneKeep:        cfg.PruneKeep,
+		PruneMinChars:    cfg.PruneMinChars,
+		Prices:           cfg.Prices,
+		MaxCost:          cfg.MaxCost,
+		MaxTotalTokens:   cfg.MaxTotalTokens,
+	}
+	if d, err := time.ParseDuration(cfg.RetryMaxWait); err == nil && d > 0 {
+		resolved.RetryMaxWait = d
 	}
 
 	// Apply defaults if agent file didn't set them
@@ -214,6 +517,22 @@ func Resolve(scriptCfg *ScriptConfig) *ResolvedConfig {
 		if scriptCfg.MaxTokens != nil {
 			resolved.MaxTokens = *scriptCfg.MaxTokens
 		}
+		resolved.Temperature = scriptCfg.Temperature
+		resolved.TopP = scriptCfg.TopP
+		resolved.Stop = scriptCfg.Stop
+		if scriptCfg.MaxIterations != nil && *scriptCfg.MaxIterations > 0 {
+			resolved.MaxIterations = *scriptCfg.MaxIterations
+		}
+		// A thought from elsewhere must not lift the user's budget
+		if scriptCfg.MaxCost != nil && *scriptCfg.MaxCost > 0 && (resolved.MaxCost == 0 || *scriptCfg.MaxCost < resolved.MaxCost) {
+			resolved.MaxCost = *scriptCfg.MaxCost
+		}
+		if scriptCfg.MaxTotalTokens != nil && *scriptCfg.MaxTotalTokens > 0 && (resolved.MaxTotalTokens == 0 || *scriptCfg.MaxTotalTokens < resolved.MaxTotalTokens) {
+			resolved.MaxTotalTokens = *scriptCfg.MaxTotalTokens
+		}
+	}
+	if resolved.MaxIterations > cfg.IterationCap {
+		resolved.MaxIterations = cfg.IterationCap
 	}
 
 	// Apply env var overrides
@@ -226,11 +545,37 @@ func Resolve(scriptCfg *ScriptConfig) *ResolvedConfig {
 			resolved.MaxTokens = n
 		}
 	}
+	if v := getEnv("COST_CONFIRM"); v != "" {
+		var f float64
+		if _, err := fmt.Sscanf(v, "%g", &f); err == nil && f >= 0 {
+			resolved.CostConfirm = f
+		}
+	}
+	if v := getEnv("COST_CEILING"); v != "" {
+		var f float64
+		if _, err := fmt.Sscanf(v, "%g", &f); err == nil && f >= 0 {
+			resolved.CostCeiling = f
+		}
+	}
+	if v := getEnv("MAX_COST"); v != "" {
+		var f float64
+		if _, err := fmt.Sscanf(v, "%g", &f); err == nil && f >= 0 {
+			resolved.MaxCost = f
+		}
+	}
+	if v := getEnv("MAX_TOTAL_TOKENS"); v != "" {
+		var n int
+		if _, err := fmt.Sscanf(v, "%d", &n); err == nil && n >= 0 {
+			resolved.MaxTotalTokens = n
+		}
+	}
 	if v := getEnv("ANTHROPIC__API_KEY"); v != "" {
 		resolved.APIKey = v
+		resolved.CredentialHelper = ""
 	}
 	if v := getEnv("OPENAI__API_KEY"); v != "" && resolved.Provider == "openai" {
 		resolved.APIKey = v
+		resolved.CredentialHelper = ""
 	}
 	if v := getEnv("OPENAI__API_BASE"); v != "" && resolved.Provider == "openai" {
 		resolved.APIBase = v
@@ -239,6 +584,30 @@ func Resolve(scriptCfg *ScriptConfig) *ResolvedConfig {
 	return resolved
 }
 
+// maxStopSequences is the most stop sequences frontmatter may set; OpenAI
+// accepts no more than 4.
+const maxStopSequences = 4
+
+// ValidateSampling checks frontmatter temperature, top_p, and stop.
+func ValidateSampling(c *ScriptConfig) error {
+	if c == nil {
+		return nil
+	}
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		return fmt.Errorf("invalid temperature %g (must be from 0 to 2)", *c.Temperature)
+	}
+	if c.TopP != nil && (*c.TopP <= 0 || *c.TopP > 1) {
+		return fmt.Errorf("invalid top_p %g (must be above 0 and at most 1)", *c.TopP)
+	}
+	if len(c.Stop) > maxStopSequences {
+		return fmt.Errorf("too many stop sequences (%d, at most %d)", len(c.Stop), maxStopSequences)
+	}
+	if slices.Contains(c.Stop, "") {
+		return fmt.Errorf("invalid stop sequence \"\" (must not be empty)")
+	}
+	return nil
+}
+
 func getEnv(key string) string {
 	return os.Getenv("THINKINGSCRIPT__" + key)
 }
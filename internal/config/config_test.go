@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestThoughtName(t *testing.T) {
@@ -303,6 +304,33 @@ func TestLoadConfig(t *testing.T) {
 	})
 }
 
+func TestLoadConfigSnapshots(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string // "" = no config.json
+		want   int
+	}{
+		{"no config", "", DefaultSnapshots},
+		{"unset", `{"version": 1}`, DefaultSnapshots},
+		{"zero", `{"version": 1, "snapshots": 0}`, DefaultSnapshots},
+		{"set", `{"version": 1, "snapshots": 2}`, 2},
+		{"disabled", `{"version": 1, "snapshots": -1}`, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpHome := t.TempDir()
+			t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+			if tt.config != "" {
+				os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(tt.config), 0644)
+			}
+
+			if got := LoadConfig().Snapshots; got != tt.want {
+				t.Errorf("Snapshots = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestResolve(t *testing.T) {
 	t.Run("defaults with no config", func(t *testing.T) {
 		tmpHome := t.TempDir()
@@ -363,6 +391,175 @@ func TestResolve(t *testing.T) {
 	})
 }
 
+func TestResolveMaxIterations(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+
+	n := 80
+	if got := Resolve(&ScriptConfig{MaxIterations: &n}).MaxIterations; got != 80 {
+		t.Errorf("MaxIterations = %d, want 80", got)
+	}
+
+	n = 10000
+	if got := Resolve(&ScriptConfig{MaxIterations: &n}).MaxIterations; got != DefaultIterationCap {
+		t.Errorf("MaxIterations = %d, want cap %d", got, DefaultIterationCap)
+	}
+
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"iteration_cap": 30}`), 0644)
+	if got := Resolve(nil).MaxIterations; got != 30 {
+		t.Errorf("MaxIterations = %d, want config cap 30", got)
+	}
+}
+
+func TestResolveSampling(t *testing.T) {
+	t.Setenv("THINKINGSCRIPT_HOME", t.TempDir())
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+
+	if r := Resolve(nil); r.Temperature != nil || r.TopP != nil || r.Stop != nil {
+		t.Errorf("sampling without frontmatter = %v, %v, %v; want unset", r.Temperature, r.TopP, r.Stop)
+	}
+	zero := 0.0
+	r := Resolve(&ScriptConfig{Temperature: &zero, Stop: []string{"END"}})
+	if r.Temperature == nil || *r.Temperature != 0 || r.TopP != nil || len(r.Stop) != 1 {
+		t.Errorf("sampling = %v, %v, %v; want 0, unset, [END]", r.Temperature, r.TopP, r.Stop)
+	}
+
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		cfg  ScriptConfig
+		want bool
+	}{
+		{ScriptConfig{}, true},
+		{ScriptConfig{Temperature: f(0), TopP: f(1), Stop: []string{"\n\n"}}, true},
+		{ScriptConfig{Temperature: f(2.5)}, false},
+		{ScriptConfig{Temperature: f(-1)}, false},
+		{ScriptConfig{TopP: f(0)}, false},
+		{ScriptConfig{Stop: []string{"a", "b", "c", "d", "e"}}, false},
+		{ScriptConfig{Stop: []string{""}}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateSampling(&tt.cfg); (err == nil) != tt.want {
+			t.Errorf("ValidateSampling(%+v) = %v, want ok %v", tt.cfg, err, tt.want)
+		}
+	}
+}
+
+func TestResolveCostLimits(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+	t.Setenv("THINKINGSCRIPT__COST_CONFIRM", "")
+	t.Setenv("THINKINGSCRIPT__COST_CEILING", "")
+
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"cost_confirm": 0.5, "cost_ceiling": 2}`), 0644)
+	resolved := Resolve(nil)
+	if resolved.CostConfirm != 0.5 || resolved.CostCeiling != 2 {
+		t.Errorf("cost limits = %v, %v; want 0.5, 2", resolved.CostConfirm, resolved.CostCeiling)
+	}
+
+	t.Setenv("THINKINGSCRIPT__COST_CONFIRM", "0")
+	t.Setenv("THINKINGSCRIPT__COST_CEILING", "bogus")
+	resolved = Resolve(nil)
+	if resolved.CostConfirm != 0 || resolved.CostCeiling != 2 {
+		t.Errorf("cost limits = %v, %v; want 0, 2", resolved.CostConfirm, resolved.CostCeiling)
+	}
+}
+
+func TestResolveBudget(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+	t.Setenv("THINKINGSCRIPT__MAX_COST", "")
+	t.Setenv("THINKINGSCRIPT__MAX_TOTAL_TOKENS", "")
+
+	cost := func(f float64) *float64 { return &f }
+	tokens := func(n int) *int { return &n }
+
+	// Without config.json limits, frontmatter sets them
+	resolved := Resolve(&ScriptConfig{MaxCost: cost(1), MaxTotalTokens: tokens(50000)})
+	if resolved.MaxCost != 1 || resolved.MaxTotalTokens != 50000 {
+		t.Errorf("budget = %v, %d; want 1, 50000", resolved.MaxCost, resolved.MaxTotalTokens)
+	}
+
+	// Frontmatter can lower config.json's limits but not raise them
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"max_cost": 2, "max_total_tokens": 100000}`), 0644)
+	resolved = Resolve(&ScriptConfig{MaxCost: cost(0.5), MaxTotalTokens: tokens(1000000)})
+	if resolved.MaxCost != 0.5 || resolved.MaxTotalTokens != 100000 {
+		t.Errorf("budget = %v, %d; want 0.5, 100000", resolved.MaxCost, resolved.MaxTotalTokens)
+	}
+
+	// The environment overrides both
+	t.Setenv("THINKINGSCRIPT__MAX_COST", "10")
+	t.Setenv("THINKINGSCRIPT__MAX_TOTAL_TOKENS", "0")
+	resolved = Resolve(&ScriptConfig{MaxCost: cost(0.5)})
+	if resolved.MaxCost != 10 || resolved.MaxTotalTokens != 0 {
+		t.Errorf("budget = %v, %d; want 10, 0", resolved.MaxCost, resolved.MaxTotalTokens)
+	}
+}
+
+func TestResolveRetry(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+
+	resolved := Resolve(nil)
+	if resolved.RetryAttempts != 0 || resolved.RetryMaxWait != 0 {
+		t.Errorf("retry = %d, %v; want defaults (0, 0)", resolved.RetryAttempts, resolved.RetryMaxWait)
+	}
+
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"retry_attempts": 6, "retry_max_wait": "1m"}`), 0644)
+	resolved = Resolve(nil)
+	if resolved.RetryAttempts != 6 || resolved.RetryMaxWait != time.Minute {
+		t.Errorf("retry = %d, %v; want 6, 1m", resolved.RetryAttempts, resolved.RetryMaxWait)
+	}
+
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"retry_max_wait": "soon"}`), 0644)
+	if resolved = Resolve(nil); resolved.RetryMaxWait != 0 {
+		t.Errorf("invalid retry_max_wait resolved to %v, want 0", resolved.RetryMaxWait)
+	}
+}
+
+func TestResolveParallelTools(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+
+	if resolved := Resolve(nil); resolved.ParallelTools != 0 {
+		t.Errorf("parallel tools = %d, want default 0", resolved.ParallelTools)
+	}
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"parallel_tools": 1}`), 0644)
+	if resolved := Resolve(nil); resolved.ParallelTools != 1 {
+		t.Errorf("parallel tools = %d, want 1", resolved.ParallelTools)
+	}
+}
+
+func TestResolveFallbackModels(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+	t.Setenv("THINKINGSCRIPT__MODEL", "")
+
+	os.MkdirAll(filepath.Join(tmpHome, "agents"), 0755)
+	os.WriteFile(filepath.Join(tmpHome, "agents", "anthropic.json"), []byte(`{"provider": "anthropic", "model": "claude-opus-4-1", "fallback_models": ["claude-sonnet-4-5", "claude-haiku-4-5"]}`), 0644)
+	resolved := Resolve(nil)
+	if len(resolved.FallbackModels) != 2 || resolved.FallbackModels[0] != "claude-sonnet-4-5" {
+		t.Errorf("FallbackModels = %v", resolved.FallbackModels)
+	}
+}
+
+func TestResolvePrices(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+
+	os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(`{"prices": {"llama3": {"input": 0.2, "output": 0.4}}}`), 0644)
+	resolved := Resolve(nil)
+	if got := resolved.Prices["llama3"]; got != (ModelPrice{Input: 0.2, Output: 0.4}) {
+		t.Errorf("prices[llama3] = %+v", got)
+	}
+}
+
 func TestSaveAgent(t *testing.T) {
 	tmpHome := t.TempDir()
 	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
@@ -400,3 +597,128 @@ func TestSaveAgent(t *testing.T) {
 		t.Errorf("APIKey = %q, want %q", loaded.APIKey, "sk-test")
 	}
 }
+
+func TestResolveWorkDir(t *testing.T) {
+	base := t.TempDir()
+	sub := filepath.Join(base, "project")
+	os.MkdirAll(sub, 0700)
+	file := filepath.Join(base, "file.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+
+	t.Run("relative to base", func(t *testing.T) {
+		got, err := ResolveWorkDir("project", base)
+		if err != nil {
+			t.Fatalf("ResolveWorkDir error: %v", err)
+		}
+		if got != sub {
+			t.Errorf("ResolveWorkDir = %q, want %q", got, sub)
+		}
+	})
+
+	t.Run("absolute", func(t *testing.T) {
+		got, err := ResolveWorkDir(sub+"/../project", "/elsewhere")
+		if err != nil {
+			t.Fatalf("ResolveWorkDir error: %v", err)
+		}
+		if got != sub {
+			t.Errorf("ResolveWorkDir = %q, want %q", got, sub)
+		}
+	})
+
+	t.Run("missing directory", func(t *testing.T) {
+		if _, err := ResolveWorkDir("nope", base); err == nil {
+			t.Error("expected error for missing directory")
+		}
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		if _, err := ResolveWorkDir(file, base); err == nil {
+			t.Error("expected error for file")
+		}
+	})
+}
+
+func TestResolveScriptWorkDir(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := filepath.Join(home, "project")
+	os.MkdirAll(filepath.Join(project, "data"), 0700)
+	script := filepath.Join(project, "report.md")
+	os.WriteFile(script, []byte("Write a report."), 0644)
+
+	valid := map[string]string{
+		"data":            filepath.Join(project, "data"),
+		".":               project,
+		"~/project/data":  filepath.Join(project, "data"),
+		project + "/data": filepath.Join(project, "data"),
+	}
+	for in, want := range valid {
+		got, err := ResolveScriptWorkDir(in, script, "")
+		if err != nil || got != want {
+			t.Errorf("ResolveScriptWorkDir(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"/",
+		"~",
+		"..",
+		filepath.Dir(home),
+		tmpHome,
+		filepath.Join(tmpHome, "thoughts"),
+		filepath.Dir(tmpHome),
+	}
+	os.MkdirAll(filepath.Join(tmpHome, "thoughts"), 0700)
+	for _, in := range invalid {
+		if got, err := ResolveScriptWorkDir(in, script, ""); err == nil {
+			t.Errorf("ResolveScriptWorkDir(%q) = %q, want error", in, got)
+		}
+	}
+
+	// Remote scripts, and thoughts installed from one, keep the current directory
+	if _, err := ResolveScriptWorkDir(".", "https://example.com/report.md", ""); err != nil {
+		t.Errorf("ResolveScriptWorkDir(.) for a remote script: %v", err)
+	}
+	if got, err := ResolveScriptWorkDir(project, "https://example.com/report.md", ""); err == nil {
+		t.Errorf("ResolveScriptWorkDir for a remote script = %q, want error", got)
+	}
+	thoughtDir := filepath.Join(tmpHome, "thoughts", "report")
+	SaveOrigin(thoughtDir, &Origin{Origin: OriginURL, Source: "https://example.com/report.md"})
+	if got, err := ResolveScriptWorkDir("data", script, thoughtDir); err == nil {
+		t.Errorf("ResolveScriptWorkDir for a url-origin thought = %q, want error", got)
+	}
+}
+
+func TestSetDefaultAgent(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+	// No config.json yet: one is created with a version.
+	if err := SetDefaultAgent("ollama"); err != nil {
+		t.Fatalf("SetDefaultAgent() error: %v", err)
+	}
+	if got := LoadConfig().Agent; got != "ollama" {
+		t.Errorf("agent = %q, want %q", got, "ollama")
+	}
+
+	// Other fields survive a change of agent.
+	path := filepath.Join(tmpHome, "config.json")
+	if err := os.WriteFile(path, []byte(`{"version": 1, "agent": "ollama", "custom": {"x": true}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetDefaultAgent("anthropic"); err != nil {
+		t.Fatalf("SetDefaultAgent() error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"custom"`) {
+		t.Errorf("config.json lost its other fields:\n%s", data)
+	}
+	if got := LoadConfig().Agent; got != "anthropic" {
+		t.Errorf("agent = %q, want %q", got, "anthropic")
+	}
+}
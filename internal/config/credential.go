@@ -0,0 +1,65 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CredentialHelperTimeout bounds how long a credential helper may run.
+const CredentialHelperTimeout = 30 * time.Second
+
+// FetchCredential runs an external credential helper and returns the API key
+// it prints. Like git credential helpers, the configured command is split on
+// whitespace and invoked with a trailing "get" argument; the agent name and
+// provider are passed as THINKINGSCRIPT_AGENT and THINKINGSCRIPT_PROVIDER.
+//
+// The helper prints either the bare key, or key=value lines of which
+// "api_key" is used (other keys, e.g. an expiry, are ignored). The key is
+// never written to disk.
+func FetchCredential(ctx context.Context, helper, agentName, providerName string) (string, error) {
+	fields := strings.Fields(helper)
+	if len(fields) == 0 {
+		return "", errors.New("credential helper is empty")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CredentialHelperTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, fields[0], append(fields[1:], "get")...)
+	cmd.Env = append(os.Environ(),
+		"THINKINGSCRIPT_AGENT="+agentName,
+		"THINKINGSCRIPT_PROVIDER="+providerName,
+	)
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("credential helper %s: %w", fields[0], err)
+	}
+
+	key := parseCredential(stdout.String())
+	if key == "" {
+		return "", fmt.Errorf("credential helper %s returned no key", fields[0])
+	}
+	return key, nil
+}
+
+func parseCredential(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	for _, line := range lines {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "api_key="); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	if len(lines) == 1 {
+		return strings.TrimSpace(lines[0])
+	}
+	return ""
+}
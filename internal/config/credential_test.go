@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeHelper(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper tests use sh scripts")
+	}
+	path := filepath.Join(t.TempDir(), "helper")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFetchCredential(t *testing.T) {
+	t.Run("bare key", func(t *testing.T) {
+		helper := writeHelper(t, `echo "sk-$THINKINGSCRIPT_AGENT-$1"`)
+		key, err := FetchCredential(context.Background(), helper, "work", "anthropic")
+		if err != nil {
+			t.Fatalf("FetchCredential error: %v", err)
+		}
+		if key != "sk-work-get" {
+			t.Errorf("key = %q, want %q", key, "sk-work-get")
+		}
+	})
+
+	t.Run("key=value output", func(t *testing.T) {
+		helper := writeHelper(t, "echo expires_at=2030-01-01T00:00:00Z\necho api_key=sk-short\n")
+		key, err := FetchCredential(context.Background(), helper+" --profile x", "default", "anthropic")
+		if err != nil {
+			t.Fatalf("FetchCredential error: %v", err)
+		}
+		if key != "sk-short" {
+			t.Errorf("key = %q, want %q", key, "sk-short")
+		}
+	})
+
+	t.Run("helper failure", func(t *testing.T) {
+		helper := writeHelper(t, "exit 1\n")
+		if _, err := FetchCredential(context.Background(), helper, "default", "anthropic"); err == nil {
+			t.Error("expected error from failing helper")
+		}
+	})
+
+	t.Run("empty output", func(t *testing.T) {
+		helper := writeHelper(t, "true\n")
+		if _, err := FetchCredential(context.Background(), helper, "default", "anthropic"); err == nil {
+			t.Error("expected error for empty output")
+		}
+	})
+}
+
+func TestResolveCredentialHelper(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("THINKINGSCRIPT__AGENT", "")
+	t.Setenv("THINKINGSCRIPT__ANTHROPIC__API_KEY", "")
+
+	if err := SaveAgent("anthropic", &AgentConfig{Provider: "anthropic", CredentialHelper: "vault-key"}); err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := Resolve(nil)
+	if resolved.CredentialHelper != "vault-key" {
+		t.Errorf("CredentialHelper = %q, want %q", resolved.CredentialHelper, "vault-key")
+	}
+	if resolved.Agent != "anthropic" {
+		t.Errorf("Agent = %q, want %q", resolved.Agent, "anthropic")
+	}
+
+	// An explicit env key bypasses the helper.
+	t.Setenv("THINKINGSCRIPT__ANTHROPIC__API_KEY", "sk-env")
+	resolved = Resolve(nil)
+	if resolved.CredentialHelper != "" || resolved.APIKey != "sk-env" {
+		t.Errorf("got helper %q key %q, want env key to win", resolved.CredentialHelper, resolved.APIKey)
+	}
+}
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A thought's data (memory.js, workspace/, memories/) normally lives in its
+// thought directory. Frontmatter `data_dir:` moves it elsewhere, typically
+// into the project that holds the script so it can be checked in. Policy,
+// journal, trash, and snapshots stay in the thought directory: a policy
+// file inside a repository would let whoever controls the repository
+// pre-approve access.
+
+type dataDirRecord struct {
+	DataDir string `json:"data_dir"`
+}
+
+func dataDirRecordPath(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "data_dir.json")
+}
+
+// ResolveDataDir validates a frontmatter data_dir and returns it as a clean
+// absolute path. Relative paths and "~" are resolved like workdir. The
+// directory must not be a filesystem root, the user's home directory, the
+// thinkingscript home or anything inside it, or the script's own directory
+// or one of its parents: everything in it becomes readable by the sandbox.
+// Remote scripts, and thoughts installed from a URL, can't set it.
+func ResolveDataDir(dir, scriptPath, thoughtDir string) (string, error) {
+	if ResolveOrigin(scriptPath, thoughtDir) == OriginURL {
+		return "", errors.New("data_dir is not allowed for remote scripts")
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~"+string(filepath.Separator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("expanding %s: %w", dir, err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	scriptDir, err := filepath.Abs(filepath.Dir(scriptPath))
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(scriptDir, dir)
+	}
+	dir = realPath(filepath.Clean(dir))
+
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		return "", fmt.Errorf("data_dir %s is not a directory", dir)
+	}
+
+	unsafe := func(reason string) error {
+		return fmt.Errorf("data_dir %s is not allowed: %s", dir, reason)
+	}
+	if filepath.Dir(dir) == dir {
+		return "", unsafe("it is a filesystem root")
+	}
+	tsHome, _ := filepath.Abs(HomeDir())
+	tsHome = realPath(tsHome)
+	if within(dir, tsHome) {
+		return "", unsafe("it is inside " + tsHome)
+	}
+	guarded := []string{tsHome, realPath(scriptDir)}
+	if home, err := os.UserHomeDir(); err == nil {
+		guarded = append(guarded, realPath(home))
+	}
+	for _, g := range guarded {
+		if within(g, dir) {
+			return "", unsafe("it contains " + g)
+		}
+	}
+	return dir, nil
+}
+
+// ThoughtDataDir returns where a thought's memory.js, workspace, and
+// memories live: the recorded data_dir, or the thought directory itself.
+func ThoughtDataDir(thoughtDir string) string {
+	data, err := os.ReadFile(dataDirRecordPath(thoughtDir))
+	if err != nil {
+		return thoughtDir
+	}
+	var rec dataDirRecord
+	if json.Unmarshal(data, &rec) != nil || rec.DataDir == "" {
+		return thoughtDir
+	}
+	return rec.DataDir
+}
+
+// SaveDataDir records a thought's data directory so `thought` commands can
+// find it. An empty dataDir, or the thought directory itself, clears the
+// record.
+func SaveDataDir(thoughtDir, dataDir string) error {
+	if dataDir == "" || dataDir == thoughtDir {
+		err := os.Remove(dataDirRecordPath(thoughtDir))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if ThoughtDataDir(thoughtDir) == dataDir {
+		return nil
+	}
+	data, err := json.MarshalIndent(dataDirRecord{DataDir: dataDir}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(thoughtDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(dataDirRecordPath(thoughtDir), data, 0600)
+}
+
+// within reports whether path is root or inside it.
+func within(path, root string) bool {
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// realPath resolves symlinks in the longest existing prefix of path, so a
+// data_dir that doesn't exist yet is still compared by its real location.
+func realPath(path string) string {
+	return resolveLinks(path, 0)
+}
+
+func resolveLinks(path string, depth int) string {
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		return real
+	}
+	parent := filepath.Dir(path)
+	if parent == path || depth > 40 {
+		return path
+	}
+	parent = resolveLinks(parent, depth+1)
+	// A dangling link is judged by where it points
+	if target, err := os.Readlink(filepath.Join(parent, filepath.Base(path))); err == nil {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(parent, target)
+		}
+		return resolveLinks(filepath.Clean(target), depth+1)
+	}
+	return filepath.Join(parent, filepath.Base(path))
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveDataDir(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	userHome := t.TempDir()
+	userHome, _ = filepath.EvalSymlinks(userHome)
+	t.Setenv("HOME", userHome)
+
+	project := filepath.Join(userHome, "src", "project")
+	os.MkdirAll(project, 0755)
+	script := filepath.Join(project, "report.md")
+	os.WriteFile(script, []byte("Write a report."), 0644)
+	os.WriteFile(filepath.Join(project, "notes.txt"), []byte("x"), 0644)
+
+	valid := map[string]string{
+		".thought":                       filepath.Join(project, ".thought"),
+		"data/report":                    filepath.Join(project, "data", "report"),
+		"~/data/report":                  filepath.Join(userHome, "data", "report"),
+		filepath.Join(userHome, "other"): filepath.Join(userHome, "other"),
+	}
+	for in, want := range valid {
+		got, err := ResolveDataDir(in, script, "")
+		if err != nil {
+			t.Errorf("ResolveDataDir(%q) error: %v", in, err)
+		} else if got != want {
+			t.Errorf("ResolveDataDir(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	invalid := []string{
+		"/",
+		"~",
+		".",
+		"..",
+		"notes.txt",
+		tmpHome,
+		filepath.Join(tmpHome, "thoughts", "report"),
+		filepath.Dir(tmpHome),
+	}
+	for _, in := range invalid {
+		if got, err := ResolveDataDir(in, script, ""); err == nil {
+			t.Errorf("ResolveDataDir(%q) = %q, want error", in, got)
+		}
+	}
+
+	if _, err := ResolveDataDir("data", "https://example.com/report.md", ""); err == nil {
+		t.Error("ResolveDataDir for a remote script should fail")
+	}
+
+	// A thought installed from a URL runs from a local copy but is still remote
+	thoughtDir := filepath.Join(tmpHome, "thoughts", "report")
+	SaveOrigin(thoughtDir, &Origin{Origin: OriginURL, Source: "https://example.com/report.md"})
+	if _, err := ResolveDataDir("data", script, thoughtDir); err == nil {
+		t.Error("ResolveDataDir for a url-origin thought should fail")
+	}
+}
+
+func TestResolveDataDirSymlink(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	script := filepath.Join(project, "report.md")
+	os.WriteFile(script, []byte("Write a report."), 0644)
+
+	// A link into the thinkingscript home is judged by where it points
+	os.Symlink(filepath.Join(tmpHome, "thoughts"), filepath.Join(project, "sneaky"))
+	if got, err := ResolveDataDir("sneaky/report", script, ""); err == nil {
+		t.Errorf("ResolveDataDir through symlink = %q, want error", got)
+	}
+}
+
+func TestSaveDataDir(t *testing.T) {
+	thoughtDir := filepath.Join(t.TempDir(), "report")
+	dataDir := filepath.Join(t.TempDir(), "data")
+
+	if got := ThoughtDataDir(thoughtDir); got != thoughtDir {
+		t.Errorf("ThoughtDataDir without record = %q, want %q", got, thoughtDir)
+	}
+	if err := SaveDataDir(thoughtDir, dataDir); err != nil {
+		t.Fatalf("SaveDataDir: %v", err)
+	}
+	if got := ThoughtDataDir(thoughtDir); got != dataDir {
+		t.Errorf("ThoughtDataDir = %q, want %q", got, dataDir)
+	}
+
+	// Dropping data_dir from the frontmatter clears the record
+	if err := SaveDataDir(thoughtDir, thoughtDir); err != nil {
+		t.Fatalf("SaveDataDir(clear): %v", err)
+	}
+	if _, err := os.Stat(dataDirRecordPath(thoughtDir)); !os.IsNotExist(err) {
+		t.Error("data_dir record should have been removed")
+	}
+	if got := ThoughtDataDir(thoughtDir); got != thoughtDir {
+		t.Errorf("ThoughtDataDir after clear = %q, want %q", got, thoughtDir)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A frozen thought never starts the agent: when memory.js hands over or
+// fails, the run fails instead of calling the provider. `thought freeze`
+// marks a converged thought this way so it can't silently run up cost or
+// send data to the API again.
+
+// Frozen records when a thought was frozen.
+type Frozen struct {
+	Frozen time.Time `json:"frozen"`
+}
+
+// FrozenPath returns the path to a thought's freeze record.
+func FrozenPath(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "frozen.json")
+}
+
+// LoadFrozen returns a thought's freeze record, or nil if it isn't frozen.
+// An unreadable record still freezes the thought: failing closed is the
+// point of freezing.
+func LoadFrozen(thoughtDir string) *Frozen {
+	data, err := os.ReadFile(FrozenPath(thoughtDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	var f Frozen
+	if err == nil {
+		json.Unmarshal(data, &f)
+	}
+	return &f
+}
+
+// Freeze writes the freeze record for a thought.
+func Freeze(thoughtDir string) error {
+	if err := os.MkdirAll(thoughtDir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(&Frozen{Frozen: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(FrozenPath(thoughtDir), data, 0600)
+}
+
+// Unfreeze removes a thought's freeze record. Unfreezing a thought that
+// isn't frozen is not an error.
+func Unfreeze(thoughtDir string) error {
+	err := os.Remove(FrozenPath(thoughtDir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// ErrFrozen is the error for a run of a frozen thought that would have
+// started the agent. reason says why it would have (memory.js's resume
+// context, or the flag that needs the agent).
+func ErrFrozen(thoughtDir, reason string) error {
+	name := filepath.Base(thoughtDir)
+	if reason != "" {
+		return fmt.Errorf("%s is frozen; not starting the agent (%s). Fix memory.js by hand, or 'thought unfreeze %s' to let the agent run", name, reason, name)
+	}
+	return fmt.Errorf("%s is frozen; not starting the agent. Fix memory.js by hand, or 'thought unfreeze %s' to let the agent run", name, name)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Identity records which script owns a thought directory, so two scripts
+// with the same file name in different projects don't share memories.
+type Identity struct {
+	Script  string    `json:"script"` // absolute path or URL
+	Claimed time.Time `json:"claimed"`
+}
+
+// IdentityPath returns the path to the identity record for a thought.
+func IdentityPath(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "identity.json")
+}
+
+// ScriptIdentity returns the stable identity of a script: its absolute,
+// symlink-resolved path, or the URL for remote scripts.
+func ScriptIdentity(scriptPath string) string {
+	if IsURL(scriptPath) {
+		return scriptPath
+	}
+	abs, err := filepath.Abs(scriptPath)
+	if err != nil {
+		return scriptPath
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real
+	}
+	return abs
+}
+
+// ValidateThoughtName checks a frontmatter `name:` or rename target. Names
+// become directory names under thoughts/, so they must be a single path
+// element.
+func ValidateThoughtName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid thought name %q (must be a plain name like \"weather\")", name)
+	}
+	return nil
+}
+
+// ThoughtOwner returns the script a thought directory belongs to: the
+// identity record, or the install source for installed thoughts. Returns
+// "" for directories nobody has claimed (new, or created before identities
+// were recorded).
+func ThoughtOwner(thoughtDir string) string {
+	if data, err := os.ReadFile(IdentityPath(thoughtDir)); err == nil {
+		var id Identity
+		if json.Unmarshal(data, &id) == nil && id.Script != "" {
+			return id.Script
+		}
+	}
+	if o := LoadOrigin(thoughtDir); o != nil {
+		return ScriptIdentity(o.Source)
+	}
+	return ""
+}
+
+// LocateThought returns the data directory for a script. An explicit name
+// (frontmatter `name:`) always wins. Installed thoughts use their command
+// name. Otherwise the script's file name is used unless another script
+// already owns that directory; then the script gets (or keeps) a directory
+// of its own and conflict names the other owner, so the caller can warn.
+func LocateThought(scriptPath, name string) (dir, conflict string) {
+	thoughts := filepath.Join(HomeDir(), "thoughts")
+	if name != "" {
+		return filepath.Join(thoughts, name), ""
+	}
+	base := ThoughtName(scriptPath)
+	baseDir := filepath.Join(thoughts, base)
+	if isInstalled(scriptPath) {
+		return baseDir, ""
+	}
+
+	id := ScriptIdentity(scriptPath)
+	owner := ThoughtOwner(baseDir)
+	if owner == id {
+		return baseDir, ""
+	}
+	// A directory claimed under another name ('thought rename', or an
+	// earlier collision) follows the script.
+	if entries, err := os.ReadDir(thoughts); err == nil {
+		for _, e := range entries {
+			if e.IsDir() && e.Name() != base && ThoughtOwner(filepath.Join(thoughts, e.Name())) == id {
+				return filepath.Join(thoughts, e.Name()), ""
+			}
+		}
+	}
+	if owner == "" {
+		return baseDir, ""
+	}
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(thoughts, base+"-"+hex.EncodeToString(sum[:4])), owner
+}
+
+// ClaimThought records scriptPath as the owner of thoughtDir unless the
+// directory already has an owner. Installed thoughts are owned through
+// their origin record and are never claimed.
+func ClaimThought(thoughtDir, scriptPath string) error {
+	if isInstalled(scriptPath) || ThoughtOwner(thoughtDir) != "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(Identity{Script: ScriptIdentity(scriptPath), Claimed: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(thoughtDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(IdentityPath(thoughtDir), data, 0600)
+}
+
+// isInstalled reports whether scriptPath is a thought installed in BinDir.
+func isInstalled(scriptPath string) bool {
+	if IsURL(scriptPath) {
+		return false
+	}
+	abs, err := filepath.Abs(scriptPath)
+	if err != nil {
+		return false
+	}
+	bin, err := filepath.Abs(BinDir())
+	if err != nil {
+		return false
+	}
+	return filepath.Dir(abs) == bin
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocateThought(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	projects := t.TempDir()
+	projects, _ = filepath.EvalSymlinks(projects)
+
+	work := filepath.Join(projects, "work", "weather.md")
+	home := filepath.Join(projects, "home", "weather.md")
+	for _, p := range []string{work, home} {
+		os.MkdirAll(filepath.Dir(p), 0755)
+		os.WriteFile(p, []byte("Get the weather."), 0644)
+	}
+	thoughts := filepath.Join(tmpHome, "thoughts")
+
+	// First script gets the plain name and claims it
+	dir, conflict := LocateThought(work, "")
+	if dir != filepath.Join(thoughts, "weather") || conflict != "" {
+		t.Fatalf("LocateThought(work) = %q, %q", dir, conflict)
+	}
+	if err := ClaimThought(dir, work); err != nil {
+		t.Fatalf("ClaimThought: %v", err)
+	}
+	if got := ThoughtOwner(dir); got != work {
+		t.Errorf("ThoughtOwner = %q, want %q", got, work)
+	}
+
+	// Same script again: same directory
+	if again, _ := LocateThought(work, ""); again != dir {
+		t.Errorf("LocateThought(work) again = %q, want %q", again, dir)
+	}
+
+	// A different script with the same file name is moved aside, with a warning
+	other, conflict := LocateThought(home, "")
+	if other == dir || !strings.HasPrefix(filepath.Base(other), "weather-") {
+		t.Errorf("LocateThought(home) = %q, want weather-<hash>", other)
+	}
+	if conflict != work {
+		t.Errorf("conflict = %q, want %q", conflict, work)
+	}
+	ClaimThought(other, home)
+	// Once claimed, the hashed directory is found without a conflict
+	if again, conflict := LocateThought(home, ""); again != other || conflict != "" {
+		t.Errorf("LocateThought(home) again = %q, %q", again, conflict)
+	}
+
+	// An explicit name always wins
+	if named, _ := LocateThought(home, "weather-home"); named != filepath.Join(thoughts, "weather-home") {
+		t.Errorf("LocateThought(home, name) = %q", named)
+	}
+
+	// A renamed directory follows its owner
+	renamed := filepath.Join(thoughts, "forecast")
+	os.Rename(other, renamed)
+	if got, _ := LocateThought(home, ""); got != renamed {
+		t.Errorf("after rename LocateThought(home) = %q, want %q", got, renamed)
+	}
+}
+
+func TestLocateThoughtLegacyAndInstalled(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+	src := filepath.Join(t.TempDir(), "notes.md")
+	os.WriteFile(src, []byte("Take notes."), 0644)
+
+	// An unclaimed directory from before identities were recorded is reused
+	legacy := filepath.Join(tmpHome, "thoughts", "notes")
+	os.MkdirAll(legacy, 0700)
+	if dir, conflict := LocateThought(src, ""); dir != legacy || conflict != "" {
+		t.Errorf("LocateThought(legacy) = %q, %q", dir, conflict)
+	}
+
+	// Installed thoughts are keyed by command name and owned via origin.json
+	SaveOrigin(legacy, &Origin{Origin: OriginLocal, Source: src, Installed: time.Now()})
+	bin := filepath.Join(BinDir(), "notes")
+	os.MkdirAll(BinDir(), 0700)
+	os.WriteFile(bin, []byte("Take notes."), 0755)
+	if dir, _ := LocateThought(bin, ""); dir != legacy {
+		t.Errorf("LocateThought(installed) = %q, want %q", dir, legacy)
+	}
+	if dir, conflict := LocateThought(src, ""); dir != legacy || conflict != "" {
+		t.Errorf("LocateThought(install source) = %q, %q", dir, conflict)
+	}
+	ClaimThought(legacy, bin)
+	if _, err := os.Stat(IdentityPath(legacy)); !os.IsNotExist(err) {
+		t.Error("installed thoughts should not get an identity record")
+	}
+}
+
+func TestValidateThoughtName(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"weather": true, "weather-work": true, "": false, ".": false, "..": false,
+		"a/b": false, `a\b`: false, ".hidden": false,
+	} {
+		if err := ValidateThoughtName(name); (err == nil) != ok {
+			t.Errorf("ValidateThoughtName(%q) = %v, want ok=%v", name, err, ok)
+		}
+	}
+}
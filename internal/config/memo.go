@@ -0,0 +1,69 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Memo is a cached stdout for one invocation of a converged thought.
+type Memo struct {
+	Created time.Time `json:"created"`
+	Stdout  string    `json:"stdout"`
+}
+
+// MemoKey hashes the inputs of an invocation: args, stdin, the working
+// directory, and the paths granted with --allow and --write, since the
+// same args can read different files. The script fingerprint is already
+// part of the cache dir.
+func MemoKey(args []string, stdin, workDir string, allow, write []string) string {
+	h := sha256.New()
+	for _, list := range [][]string{args, allow, write} {
+		for _, a := range list {
+			h.Write([]byte(a))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{1})
+	}
+	h.Write([]byte(workDir))
+	h.Write([]byte{0})
+	h.Write([]byte(stdin))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func memoPath(cacheDir, key string) string {
+	return filepath.Join(cacheDir, "memo", key+".json")
+}
+
+// LoadMemo returns the cached stdout for key if it is younger than ttl.
+func LoadMemo(cacheDir, key string, ttl time.Duration) (string, bool) {
+	data, err := os.ReadFile(memoPath(cacheDir, key))
+	if err != nil {
+		return "", false
+	}
+	var m Memo
+	if err := json.Unmarshal(data, &m); err != nil {
+		return "", false
+	}
+	if time.Since(m.Created) > ttl {
+		os.Remove(memoPath(cacheDir, key))
+		return "", false
+	}
+	return m.Stdout, true
+}
+
+// SaveMemo stores stdout for key.
+func SaveMemo(cacheDir, key, stdout string) error {
+	path := memoPath(cacheDir, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(Memo{Created: time.Now(), Stdout: stdout})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoKey(t *testing.T) {
+	a := MemoKey([]string{"a", "b"}, "", "/work", nil, nil)
+	if a != MemoKey([]string{"a", "b"}, "", "/work", nil, nil) {
+		t.Error("MemoKey should be deterministic")
+	}
+	if a == MemoKey([]string{"ab"}, "", "/work", nil, nil) {
+		t.Error("argument boundaries should affect the key")
+	}
+	if a == MemoKey([]string{"a", "b"}, "stdin", "/work", nil, nil) {
+		t.Error("stdin should affect the key")
+	}
+	if a == MemoKey([]string{"a", "b"}, "", "/other", nil, nil) {
+		t.Error("the working directory should affect the key")
+	}
+	allowed := MemoKey([]string{"a", "b"}, "", "/work", []string{"/data"}, nil)
+	if a == allowed {
+		t.Error("--allow paths should affect the key")
+	}
+	if allowed == MemoKey([]string{"a", "b"}, "", "/work", nil, []string{"/data"}) {
+		t.Error("--allow and --write should key differently")
+	}
+	if a == MemoKey([]string{"a", "b", "/data"}, "", "/work", nil, nil) || allowed == MemoKey([]string{"a", "b", "/data"}, "", "/work", nil, nil) {
+		t.Error("list boundaries should affect the key")
+	}
+}
+
+func TestMemoRoundTrip(t *testing.T) {
+	cacheDir := t.TempDir()
+	key := MemoKey([]string{"NYC"}, "", "/work", nil, nil)
+
+	if _, ok := LoadMemo(cacheDir, key, time.Hour); ok {
+		t.Fatal("expected miss before save")
+	}
+
+	if err := SaveMemo(cacheDir, key, "sunny\n"); err != nil {
+		t.Fatalf("SaveMemo error: %v", err)
+	}
+
+	got, ok := LoadMemo(cacheDir, key, time.Hour)
+	if !ok || got != "sunny\n" {
+		t.Errorf("LoadMemo = %q, %v; want %q, true", got, ok, "sunny\n")
+	}
+
+	// Expired entries miss
+	if _, ok := LoadMemo(cacheDir, key, -time.Second); ok {
+		t.Error("expected miss for expired memo")
+	}
+}
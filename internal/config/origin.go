@@ -0,0 +1,97 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Thought origins. The origin is recorded at install time and selects which
+// trust defaults apply when the thought runs.
+const (
+	OriginLocal = "local"
+	OriginURL   = "url"
+)
+
+// Origin records where an installed thought came from.
+type Origin struct {
+	Origin    string    `json:"origin"`
+	Source    string    `json:"source"`
+	Installed time.Time `json:"installed"`
+}
+
+// TrustConfig holds the default approvals for thoughts of a given origin.
+// Empty fields leave the policy defaults untouched.
+type TrustConfig struct {
+	Paths string `json:"paths,omitempty"`
+	Env   string `json:"env,omitempty"`
+	Net   string `json:"net,omitempty"`
+}
+
+// DefaultTrust returns the built-in per-origin defaults. Thoughts fetched
+// from URLs get no network access unless the policy explicitly allows it.
+func DefaultTrust() map[string]TrustConfig {
+	return map[string]TrustConfig{
+		OriginLocal: {},
+		OriginURL:   {Net: "deny"},
+	}
+}
+
+// OriginPath returns the path to the origin record for a thought.
+func OriginPath(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "origin.json")
+}
+
+// LoadOrigin reads the origin record for a thought. Returns nil if the
+// thought has no record (e.g. it was never installed).
+func LoadOrigin(thoughtDir string) *Origin {
+	data, err := os.ReadFile(OriginPath(thoughtDir))
+	if err != nil {
+		return nil
+	}
+	var o Origin
+	if err := json.Unmarshal(data, &o); err != nil || o.Origin == "" {
+		return nil
+	}
+	return &o
+}
+
+// SaveOrigin writes the origin record for a thought.
+func SaveOrigin(thoughtDir string, o *Origin) error {
+	if err := os.MkdirAll(thoughtDir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(OriginPath(thoughtDir), data, 0600)
+}
+
+// ResolveOrigin determines the origin of a script being run. The recorded
+// install origin wins; otherwise URLs are "url" and everything else "local".
+func ResolveOrigin(scriptPath, thoughtDir string) string {
+	if o := LoadOrigin(thoughtDir); o != nil {
+		return o.Origin
+	}
+	if IsURL(scriptPath) {
+		return OriginURL
+	}
+	return OriginLocal
+}
+
+// TrustFor returns the trust defaults for an origin, with config.json
+// entries taking precedence over the built-in defaults.
+func TrustFor(origin string) TrustConfig {
+	if t, ok := LoadConfig().Trust[origin]; ok {
+		return t
+	}
+	return DefaultTrust()[origin]
+}
+
+// IsURL reports whether a script path refers to a remote thought.
+func IsURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveOrigin(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+	thoughtDir := filepath.Join(tmpHome, "thoughts", "weather")
+
+	if got := ResolveOrigin("examples/weather.md", thoughtDir); got != OriginLocal {
+		t.Errorf("ResolveOrigin(local) = %q, want %q", got, OriginLocal)
+	}
+	if got := ResolveOrigin("https://example.com/weather.md", thoughtDir); got != OriginURL {
+		t.Errorf("ResolveOrigin(url) = %q, want %q", got, OriginURL)
+	}
+
+	// Recorded origin wins over the path shape
+	err := SaveOrigin(thoughtDir, &Origin{Origin: OriginURL, Source: "https://example.com/weather.md", Installed: time.Now()})
+	if err != nil {
+		t.Fatalf("SaveOrigin error: %v", err)
+	}
+	if got := ResolveOrigin(filepath.Join(tmpHome, "bin", "weather"), thoughtDir); got != OriginURL {
+		t.Errorf("ResolveOrigin(installed) = %q, want %q", got, OriginURL)
+	}
+}
+
+func TestTrustFor(t *testing.T) {
+	t.Run("built-in defaults", func(t *testing.T) {
+		tmpHome := t.TempDir()
+		t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+		if got := TrustFor(OriginURL).Net; got != "deny" {
+			t.Errorf("url net = %q, want %q", got, "deny")
+		}
+		if got := TrustFor(OriginLocal).Net; got != "" {
+			t.Errorf("local net = %q, want empty", got)
+		}
+	})
+
+	t.Run("config.json overrides", func(t *testing.T) {
+		tmpHome := t.TempDir()
+		t.Setenv("THINKINGSCRIPT_HOME", tmpHome)
+
+		configJSON := `{"version": 1, "trust": {"url": {"net": "prompt", "env": "deny"}}}`
+		os.WriteFile(filepath.Join(tmpHome, "config.json"), []byte(configJSON), 0644)
+
+		trust := TrustFor(OriginURL)
+		if trust.Net != "prompt" {
+			t.Errorf("url net = %q, want %q", trust.Net, "prompt")
+		}
+		if trust.Env != "deny" {
+			t.Errorf("url env = %q, want %q", trust.Env, "deny")
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// RouteFallbackAfter is how many routed agent runs in a row may leave
+// memory.js still failing before the primary model takes over.
+const RouteFallbackAfter = 2
+
+// routeState counts routed agent runs since memory.js last succeeded.
+type routeState struct {
+	Failures int `json:"failures"`
+}
+
+func routeStatePath(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "routing.json")
+}
+
+func loadRouteState(thoughtDir string) routeState {
+	var s routeState
+	if data, err := os.ReadFile(routeStatePath(thoughtDir)); err == nil {
+		_ = json.Unmarshal(data, &s)
+	}
+	return s
+}
+
+// Route picks the model for an agent run whose resume context is of kind
+// ("first_run", "memory_error", or "resume"; see agent.ResumeKind). A
+// config.json route for kind wins over the primary model until
+// RouteFallbackAfter routed runs in a row haven't produced a working
+// memory.js. routed reports whether the route was used.
+func Route(resolved *ResolvedConfig, thoughtDir, kind string) (model string, routed bool) {
+	model = resolved.Routes[kind]
+	if model == "" || model == resolved.Model {
+		return resolved.Model, false
+	}
+	if loadRouteState(thoughtDir).Failures >= RouteFallbackAfter {
+		return resolved.Model, false
+	}
+	return model, true
+}
+
+// RecordRoutedRun counts a routed agent run. It is reset by
+// ResetRouteFailures once memory.js succeeds, so the count only stays up
+// while the routed model keeps failing.
+func RecordRoutedRun(thoughtDir string) error {
+	s := loadRouteState(thoughtDir)
+	s.Failures++
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(routeStatePath(thoughtDir), data, 0600)
+}
+
+// ResetRouteFailures forgets routed runs after memory.js succeeded.
+func ResetRouteFailures(thoughtDir string) {
+	os.Remove(routeStatePath(thoughtDir))
+}
@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestRoute(t *testing.T) {
+	dir := t.TempDir()
+	resolved := &ResolvedConfig{
+		Model:  "big",
+		Routes: map[string]string{"memory_error": "small", "resume": "big"},
+	}
+
+	if model, routed := Route(resolved, dir, "first_run"); model != "big" || routed {
+		t.Errorf("first_run = %q, %v; want primary", model, routed)
+	}
+	if model, routed := Route(resolved, dir, "resume"); model != "big" || routed {
+		t.Errorf("route to the primary model = %q, %v; want not routed", model, routed)
+	}
+
+	for i := 0; i < RouteFallbackAfter; i++ {
+		if model, routed := Route(resolved, dir, "memory_error"); model != "small" || !routed {
+			t.Fatalf("routed run %d = %q, %v; want small", i+1, model, routed)
+		}
+		if err := RecordRoutedRun(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if model, routed := Route(resolved, dir, "memory_error"); model != "big" || routed {
+		t.Errorf("after %d failures = %q, %v; want primary", RouteFallbackAfter, model, routed)
+	}
+
+	ResetRouteFailures(dir)
+	if model, _ := Route(resolved, dir, "memory_error"); model != "small" {
+		t.Errorf("after reset = %q, want small", model)
+	}
+}
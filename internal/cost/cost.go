@@ -0,0 +1,151 @@
+// Package cost estimates what provider calls cost in dollars. Token counts
+// come from the API when it reports them and are otherwise approximated
+// from request and response sizes (about four bytes per token); prices are
+// list prices. Every figure is an estimate, not a bill.
+package cost
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// Price is a model's list price in dollars per million tokens.
+type Price struct {
+	Input  float64
+	Output float64
+	Cached float64 // input read from the prompt cache; 0 = priced as Input
+}
+
+// prices holds list prices by model family. A model ID matches the longest
+// family it contains, so dated, Bedrock ("us.anthropic.claude-...") and
+// Vertex ("claude-...@date") IDs all resolve.
+var prices = map[string]Price{
+	"claude-opus-4":     {15, 75, 1.5},
+	"claude-opus-4-5":   {5, 25, 0.5},
+	"claude-sonnet-4":   {3, 15, 0.3},
+	"claude-3-7-sonnet": {3, 15, 0.3},
+	"claude-3-5-sonnet": {3, 15, 0.3},
+	"claude-haiku-4-5":  {1, 5, 0.1},
+	"claude-3-5-haiku":  {0.8, 4, 0.08},
+	"claude-3-haiku":    {0.25, 1.25, 0.03},
+	"gpt-4o":            {2.5, 10, 1.25},
+	"gpt-4o-mini":       {0.15, 0.6, 0.075},
+	"gpt-4.1":           {2, 8, 0.5},
+	"gpt-4.1-mini":      {0.4, 1.6, 0.1},
+	"gpt-4.1-nano":      {0.1, 0.4, 0.025},
+	"gpt-5":             {1.25, 10, 0.125},
+	"gpt-5-mini":        {0.25, 2, 0.025},
+	"o3":                {2, 8, 0.5},
+	"o4-mini":           {1.1, 4.4, 0.275},
+}
+
+// Preview assumptions: a run makes at least this many calls, each
+// resending the request and producing about this many output tokens.
+const (
+	minTurns       = 2
+	outputPerTurn  = 300
+	bytesPerToken  = 4
+	tokensPerMTok  = 1e6
+	minimumDollars = 0.01
+)
+
+// Lookup returns the price for model, or false when it isn't known.
+// custom (config.json "prices") is searched first, the same way, so it can
+// both correct the built-in table and price models missing from it.
+func Lookup(model string, custom map[string]Price) (Price, bool) {
+	model = strings.ToLower(model)
+	if p, ok := lookupIn(custom, model); ok {
+		return p, true
+	}
+	return lookupIn(prices, model)
+}
+
+func lookupIn(table map[string]Price, model string) (Price, bool) {
+	var best string
+	found := false
+	for family := range table {
+		if (!found || len(family) > len(best)) && strings.Contains(model, strings.ToLower(family)) {
+			best, found = family, true
+		}
+	}
+	if !found {
+		return Price{}, false
+	}
+	return table[best], true
+}
+
+// Of returns the cost of a call with the given token counts.
+func (p Price) Of(inputTokens, outputTokens int) float64 {
+	return (float64(inputTokens)*p.Input + float64(outputTokens)*p.Output) / tokensPerMTok
+}
+
+// Usage returns the cost of reported usage, with cached input at the
+// cache price.
+func (p Price) Usage(u provider.Usage) float64 {
+	cached := p.Cached
+	if cached == 0 {
+		cached = p.Input
+	}
+	return p.Of(u.InputTokens-u.CachedTokens, u.OutputTokens) + float64(u.CachedTokens)*cached/tokensPerMTok
+}
+
+// Call returns what one provider call cost: from the token counts the API
+// reported, or estimated from the request and response when it reported
+// none.
+func (p Price) Call(params provider.ChatParams, resp *provider.ChatResponse) float64 {
+	if resp != nil && resp.Usage != (provider.Usage{}) {
+		return p.Usage(resp.Usage)
+	}
+	out := 0
+	if resp != nil {
+		out = blocksTokens(resp.Content)
+	}
+	return p.Of(RequestTokens(params), out)
+}
+
+// Preview estimates the least a run starting with params will cost: the
+// first request resent for a minimal tool loop, plus typical output.
+func (p Price) Preview(params provider.ChatParams) float64 {
+	return p.Of(minTurns*RequestTokens(params), minTurns*outputPerTurn)
+}
+
+// RequestTokens estimates the input tokens of a request: system prompt,
+// messages, and tool definitions.
+func RequestTokens(params provider.ChatParams) int {
+	n := tokens(params.System)
+	for _, m := range params.Messages {
+		n += blocksTokens(m.Content)
+	}
+	if data, err := json.Marshal(params.Tools); err == nil {
+		n += len(data) / bytesPerToken
+	}
+	return n
+}
+
+// ResponseTokens estimates the output tokens of a response.
+func ResponseTokens(resp *provider.ChatResponse) int {
+	return blocksTokens(resp.Content)
+}
+
+func blocksTokens(blocks []provider.ContentBlock) int {
+	n := 0
+	for _, b := range blocks {
+		n += tokens(b.Text) + tokens(b.Content) + len(b.Input)/bytesPerToken
+	}
+	return n
+}
+
+func tokens(s string) int {
+	return (len(s) + bytesPerToken - 1) / bytesPerToken
+}
+
+// Format renders a dollar amount for display, with "<$0.01" for tiny ones.
+func Format(dollars float64) string {
+	if dollars < minimumDollars {
+		return "<$0.01"
+	}
+	return fmt.Sprintf("$%.2f", dollars)
+}
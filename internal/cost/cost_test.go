@@ -0,0 +1,84 @@
+package cost
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Price
+		ok    bool
+	}{
+		{"claude-sonnet-4-5-20250929", Price{3, 15, 0.3}, true},
+		{"us.anthropic.claude-opus-4-5-20251101-v1:0", Price{5, 25, 0.5}, true},
+		{"claude-opus-4-1@20250805", Price{15, 75, 1.5}, true},
+		{"gpt-4o-mini", Price{0.15, 0.6, 0.075}, true},
+		{"GPT-4o", Price{2.5, 10, 1.25}, true},
+		{"llama3", Price{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := Lookup(tt.model, nil)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %v, %v; want %v, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	custom := map[string]Price{"llama3": {}, "claude-sonnet-4-5": {Input: 2, Output: 10}}
+	if got, ok := Lookup("llama3:70b", custom); !ok || got != (Price{}) {
+		t.Errorf("custom price = %v, %v", got, ok)
+	}
+	if got, _ := Lookup("claude-sonnet-4-5-20250929", custom); got != (Price{Input: 2, Output: 10}) {
+		t.Errorf("custom override = %v, want {2 10}", got)
+	}
+	if got, _ := Lookup("claude-opus-4-5", custom); got != (Price{5, 25, 0.5}) {
+		t.Errorf("built-in price with custom table = %v, want {5 25 0.5}", got)
+	}
+}
+
+func TestEstimates(t *testing.T) {
+	price := Price{Input: 3, Output: 15}
+	params := provider.ChatParams{
+		System: strings.Repeat("s", 4000),
+		Messages: []provider.Message{
+			provider.NewUserMessage(provider.NewTextBlock(strings.Repeat("u", 400))),
+		},
+	}
+	// 1000 system + 100 message tokens; "null" tools round down to 1
+	if got := RequestTokens(params); got != 1101 {
+		t.Errorf("RequestTokens = %d, want 1101", got)
+	}
+
+	resp := &provider.ChatResponse{Content: []provider.ContentBlock{provider.NewTextBlock(strings.Repeat("a", 400))}}
+	if got, want := price.Call(params, resp), (1101*3+100*15)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Call = %v, want %v", got, want)
+	}
+	resp.Usage = provider.Usage{InputTokens: 2000, OutputTokens: 50}
+	if got, want := price.Call(params, resp), (2000*3+50*15)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Call with reported usage = %v, want %v", got, want)
+	}
+	resp.Usage.CachedTokens = 1500
+	if got, want := price.Call(params, resp), (500*3+1500*3+50*15)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Call with cached input and no cache price = %v, want %v", got, want)
+	}
+	price.Cached = 0.3
+	if got, want := price.Call(params, resp), (500*3+1500*0.3+50*15)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Call with cached input = %v, want %v", got, want)
+	}
+	price.Cached = 0
+	if got, want := price.Preview(params), (2*1101*3+2*300*15)/1e6; math.Abs(got-want) > 1e-12 {
+		t.Errorf("Preview = %v, want %v", got, want)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for dollars, want := range map[float64]string{0: "<$0.01", 0.004: "<$0.01", 0.01: "$0.01", 1.234: "$1.23"} {
+		if got := Format(dollars); got != want {
+			t.Errorf("Format(%v) = %q, want %q", dollars, got, want)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Package debugger is the interactive front end of `thought debug`: it
+// decides where a sandboxed memory.js pauses (breakpoints, step, next,
+// out) and, at each pause, shows the source around the current line, the
+// fs/net/env/sys calls made since the last pause, and watch expressions,
+// then reads commands until the script may go on.
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// ErrQuit is returned to the sandbox when the user quits.
+var ErrQuit = errors.New("stopped by the debugger")
+
+// How execution continues after a pause.
+const (
+	modeStep     = iota // pause at the next statement
+	modeNext            // pause at the next statement at the same or a shallower depth
+	modeOut             // pause once the current function returns
+	modeContinue        // pause only at breakpoints
+	modeDetached        // never pause (input ended)
+)
+
+const help = `Commands:
+  s, step          run to the next statement, entering function calls
+  n, next          run to the next statement in this function
+  o, out           run until this function returns
+  c, continue      run until a breakpoint
+  b <line>         set a breakpoint (b alone lists them)
+  d <line>         delete a breakpoint
+  p <expr>         evaluate an expression where the script is paused
+  w <expr>         watch an expression (w alone lists watches)
+  uw <n>           remove watch n
+  v, vars          show the variables in scope
+  l, list          show more source around the current line
+  q, quit          stop the script
+An empty line repeats the last command.`
+
+var (
+	lineStyle    = ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+	currentStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("255")).Bold(true)
+	markStyle    = ui.Renderer.NewStyle().Foreground(lipgloss.Color("82"))
+	breakStyle   = ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
+	opStyle      = ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+	valueStyle   = ui.Renderer.NewStyle().Foreground(lipgloss.Color("75"))
+	errStyle     = ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// Session is one debugging run of a script.
+type Session struct {
+	source      []string
+	in          *bufio.Reader
+	out         io.Writer
+	breakpoints map[int]bool
+	watches     []string
+	ops         []string // bridge calls since the last pause
+
+	mode        int
+	depth       int // call depth at the last pause
+	resumeLine  int // line paused at, until another line runs; breakpoints there don't fire again
+	lastCommand string
+}
+
+// New starts a session for source that reads commands from in and writes
+// to out. It pauses at the first statement.
+func New(source string, in io.Reader, out io.Writer) *Session {
+	return &Session{
+		source:      strings.Split(source, "\n"),
+		in:          bufio.NewReader(in),
+		out:         out,
+		breakpoints: map[int]bool{},
+		mode:        modeStep,
+	}
+}
+
+// Break sets a breakpoint at a 1-based line.
+func (s *Session) Break(line int) error {
+	if line < 1 || line > len(s.source) {
+		return fmt.Errorf("line %d is outside the script (1-%d)", line, len(s.source))
+	}
+	s.breakpoints[line] = true
+	return nil
+}
+
+// Watch adds an expression shown at every pause.
+func (s *Session) Watch(expr string) {
+	s.watches = append(s.watches, expr)
+}
+
+// Continue makes the session run to the first breakpoint instead of
+// pausing at the first statement.
+func (s *Session) Continue() {
+	s.mode = modeContinue
+}
+
+// Debug returns the sandbox hooks for this session.
+func (s *Session) Debug() *sandbox.Debug {
+	return &sandbox.Debug{Step: s.step, Op: s.op}
+}
+
+// Finish prints the calls made since the last pause.
+func (s *Session) Finish() {
+	s.printOps()
+}
+
+func (s *Session) op(call string) {
+	s.ops = append(s.ops, call)
+}
+
+// shouldPause decides whether to stop before the statement at line.
+func (s *Session) shouldPause(line, depth int) bool {
+	if line != s.resumeLine {
+		s.resumeLine = 0
+	}
+	if s.mode == modeDetached {
+		return false
+	}
+	if s.breakpoints[line] && line != s.resumeLine {
+		return true
+	}
+	switch s.mode {
+	case modeStep:
+		return true
+	case modeNext:
+		return depth <= s.depth
+	case modeOut:
+		return depth < s.depth
+	}
+	return false
+}
+
+func (s *Session) step(st sandbox.Step) error {
+	if !s.shouldPause(st.Line, st.Depth) {
+		return nil
+	}
+	s.depth = st.Depth
+	s.resumeLine = st.Line
+	s.printOps()
+	s.printSource(st.Line, 2)
+	s.printWatches(st)
+
+	for {
+		fmt.Fprint(s.out, "(debug) ")
+		input, err := s.in.ReadString('\n')
+		if err != nil && input == "" {
+			fmt.Fprintln(s.out, "\ninput ended; running to the end")
+			s.mode = modeDetached
+			return nil
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			input = s.lastCommand
+		}
+		s.lastCommand = input
+		cmd, arg, _ := strings.Cut(input, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch cmd {
+		case "s", "step":
+			s.mode = modeStep
+			return nil
+		case "n", "next":
+			s.mode = modeNext
+			return nil
+		case "o", "out":
+			s.mode = modeOut
+			return nil
+		case "c", "continue":
+			s.mode = modeContinue
+			return nil
+		case "q", "quit":
+			return ErrQuit
+		case "b", "break":
+			if arg == "" {
+				s.printBreakpoints()
+				continue
+			}
+			line, err := strconv.Atoi(arg)
+			if err == nil {
+				err = s.Break(line)
+			}
+			if err != nil {
+				s.printError(fmt.Errorf("b: %v", err))
+			}
+		case "d", "delete":
+			line, err := strconv.Atoi(arg)
+			if err != nil || !s.breakpoints[line] {
+				s.printError(fmt.Errorf("d: no breakpoint at %q", arg))
+				continue
+			}
+			delete(s.breakpoints, line)
+		case "p", "print":
+			if arg == "" {
+				s.printError(errors.New("p: give an expression"))
+				continue
+			}
+			s.printEval(st, arg)
+		case "w", "watch":
+			if arg == "" {
+				s.printWatches(st)
+				continue
+			}
+			s.Watch(arg)
+			s.printEval(st, arg)
+		case "uw", "unwatch":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(s.watches) {
+				s.printError(fmt.Errorf("uw: no watch %q", arg))
+				continue
+			}
+			s.watches = append(s.watches[:n-1], s.watches[n:]...)
+		case "v", "vars":
+			if len(st.Names) == 0 {
+				fmt.Fprintln(s.out, lineStyle.Render("no variables declared here"))
+			}
+			for _, name := range st.Names {
+				s.printEval(st, name)
+			}
+		case "l", "list":
+			s.printSource(st.Line, 8)
+		case "h", "help", "?":
+			fmt.Fprintln(s.out, help)
+		default:
+			s.printError(fmt.Errorf("unknown command %q (h for help)", cmd))
+		}
+	}
+}
+
+func (s *Session) printOps() {
+	for _, op := range s.ops {
+		fmt.Fprintf(s.out, "  %s %s\n", opStyle.Render("▸"), lineStyle.Render(op))
+	}
+	s.ops = nil
+}
+
+func (s *Session) printSource(line, around int) {
+	from, to := max(line-around, 1), min(line+around, len(s.source))
+	width := len(strconv.Itoa(to))
+	for i := from; i <= to; i++ {
+		mark := " "
+		if s.breakpoints[i] {
+			mark = breakStyle.Render("●")
+		}
+		num := fmt.Sprintf("%*d", width, i)
+		text := s.source[i-1]
+		if i == line {
+			fmt.Fprintf(s.out, "%s%s %s %s\n", mark, markStyle.Render("→"), currentStyle.Render(num), currentStyle.Render(text))
+		} else {
+			fmt.Fprintf(s.out, "%s  %s %s\n", mark, lineStyle.Render(num), text)
+		}
+	}
+}
+
+func (s *Session) printWatches(st sandbox.Step) {
+	for i, expr := range s.watches {
+		fmt.Fprintf(s.out, "%s ", lineStyle.Render(fmt.Sprintf("watch %d:", i+1)))
+		s.printEval(st, expr)
+	}
+}
+
+func (s *Session) printEval(st sandbox.Step, expr string) {
+	v, err := st.Eval(expr)
+	if err != nil {
+		fmt.Fprintf(s.out, "%s = %s\n", expr, errStyle.Render(err.Error()))
+		return
+	}
+	fmt.Fprintf(s.out, "%s = %s\n", expr, valueStyle.Render(v))
+}
+
+func (s *Session) printBreakpoints() {
+	if len(s.breakpoints) == 0 {
+		fmt.Fprintln(s.out, lineStyle.Render("no breakpoints"))
+		return
+	}
+	lines := make([]int, 0, len(s.breakpoints))
+	for line := range s.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	for _, line := range lines {
+		fmt.Fprintf(s.out, "%s %d: %s\n", breakStyle.Render("●"), line, strings.TrimSpace(s.source[line-1]))
+	}
+}
+
+func (s *Session) printError(err error) {
+	fmt.Fprintln(s.out, errStyle.Render(err.Error()))
+}
@@ -0,0 +1,110 @@
+package debugger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/sandbox"
+)
+
+const script = `function add(a, b) {
+  var sum = a + b;
+  return sum;
+}
+var total = 0;
+for (var i = 1; i <= 3; i++) {
+  total = add(total, i);
+}
+fs.readFile("in.txt") + total`
+
+func run(t *testing.T, s *Session) (string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "in.txt"), []byte("n="), 0644)
+	sb, err := sandbox.New(sandbox.Config{AllowedPaths: []string{dir}, WorkDir: dir, Debug: s.Debug()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sb.Run(context.Background(), script)
+}
+
+func TestBreakpointsAndWatches(t *testing.T) {
+	var out bytes.Buffer
+	s := New(script, strings.NewReader("p sum\nc\nc\nd 3\nc\n"), &out)
+	s.Continue()
+	if err := s.Break(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Break(9); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Break(10); err == nil {
+		t.Error("breakpoint past the end accepted")
+	}
+	s.Watch("total")
+
+	result, err := run(t, s)
+	if err != nil || result != "n=6" {
+		t.Fatalf("Run = %q, %v", result, err)
+	}
+	got := out.String()
+	// Paused at line 3 for each call, then the breakpoint was deleted and
+	// the run stopped at line 9
+	if n := strings.Count(got, "→ 3"); n != 3 {
+		t.Errorf("paused at line 3 %d times, want 3:\n%s", n, got)
+	}
+	for _, want := range []string{"sum = 1", "watch 1: total = 3", "→ 9"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "▸ fs.readFile") {
+		t.Error("fs call reported before it was made")
+	}
+	s.Finish()
+	if !strings.Contains(out.String(), `▸ fs.readFile("in.txt")`) {
+		t.Errorf("Finish didn't report the last call:\n%s", out.String())
+	}
+}
+
+func TestStepping(t *testing.T) {
+	var out bytes.Buffer
+	// Start at line 5; next to 6, 7; step into add (2); out back to 7
+	s := New(script, strings.NewReader("n\nn\ns\no\nv\nq\n"), &out)
+	_, err := run(t, s)
+	if err == nil || !strings.Contains(err.Error(), ErrQuit.Error()) {
+		t.Fatalf("quit run error = %v", err)
+	}
+	var lines []string
+	for _, l := range strings.Split(out.String(), "\n") {
+		if i := strings.Index(l, "→ "); i >= 0 {
+			lines = append(lines, strings.Fields(l[i+len("→ "):])[0])
+		}
+	}
+	if got := strings.Join(lines, " "); got != "5 6 7 2 7" {
+		t.Errorf("paused at %s, want 5 6 7 2 7", got)
+	}
+	if !strings.Contains(out.String(), "i = 1") || !strings.Contains(out.String(), "total = 0") {
+		t.Errorf("vars not shown:\n%s", out.String())
+	}
+}
+
+func TestInputEnds(t *testing.T) {
+	var out bytes.Buffer
+	s := New(script, strings.NewReader("bogus\n"), &out)
+	result, err := run(t, s)
+	if err != nil || result != "n=6" {
+		t.Fatalf("Run = %q, %v", result, err)
+	}
+	if !strings.Contains(out.String(), `unknown command "bogus"`) || !strings.Contains(out.String(), "input ended") {
+		t.Errorf("output:\n%s", out.String())
+	}
+	if errors.Is(err, ErrQuit) {
+		t.Error("input end quit the script")
+	}
+}
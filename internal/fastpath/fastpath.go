@@ -0,0 +1,69 @@
+// Package fastpath writes memory.js for trivial prompts without calling a
+// provider. A prompt only matches when the whole of it is one recognized
+// instruction (print a quoted string, the arguments, or an environment
+// variable); anything else goes to the agent as usual.
+package fastpath
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Kinds of fast paths.
+const (
+	KindEcho = "echo" // print a quoted literal
+	KindArgs = "args" // print the arguments
+	KindEnv  = "env"  // print an environment variable
+)
+
+const verbs = `(?i:print|output|echo|say|display|show|write)\s+(?i:out\s+)?`
+
+var (
+	echoRE = regexp.MustCompile(`^` + verbs + `(?i:the\s+(?:text|string|words?|message)\s+)?(?:"([^"]*)"|'([^']*)'|“([^”]*)”)$`)
+	argsRE = regexp.MustCompile(`^` + verbs + `(?i:back\s+)?(?i:(?:all\s+)?(?:the|my|its)\s+)?(?i:command[- ]line\s+)?(?i:arguments|args)(?i:\s+(?:back|separated\s+by\s+spaces))?$`)
+	// Bare names must be upper case ("the HOME environment variable"), so
+	// ordinary words are never read as variable names
+	envRE = regexp.MustCompile(`^` + verbs + `(?i:the\s+)?(?i:value\s+of\s+)?(?i:the\s+)?(?:\$([A-Za-z_][A-Za-z0-9_]*)|([A-Z_][A-Z0-9_]*)\s+(?i:environment|env)\s+(?i:variable|var)|(?i:environment|env)\s+(?i:variable|var)\s+\$?([A-Za-z_][A-Za-z0-9_]*))$`)
+
+	trailerRE = regexp.MustCompile(`(?i)(?:[,;]?\s+(?:and\s+|then\s+)+exit)?[.!]?$`)
+)
+
+// Synthesize returns memory.js for prompt when it is a trivial
+// instruction, and which kind of fast path matched.
+func Synthesize(prompt string) (kind, code string, ok bool) {
+	p := normalize(prompt)
+	if p == "" {
+		return "", "", false
+	}
+	var body string
+	if m := echoRE.FindStringSubmatch(p); m != nil {
+		kind, body = KindEcho, "process.stdout.write("+jsString(m[1]+m[2]+m[3]+"\n")+");"
+	} else if argsRE.MatchString(p) {
+		kind, body = KindArgs, `process.stdout.write(process.args.join(" ") + "\n");`
+	} else if m := envRE.FindStringSubmatch(p); m != nil {
+		kind, body = KindEnv, "process.stdout.write(env.get("+jsString(m[1]+m[2]+m[3])+") + \"\\n\");"
+	} else {
+		return "", "", false
+	}
+	// The prompt goes in a comment, so it must stay on one line
+	code = "// Written by think's fast path (" + kind + ") for: " + p + "\n" + body + "\n"
+	return kind, code, true
+}
+
+// normalize reduces a prompt to its single instruction, or "" when it has
+// more than one line.
+func normalize(prompt string) string {
+	p := strings.TrimSpace(prompt)
+	if strings.ContainsAny(p, "\r\n") {
+		return ""
+	}
+	p = strings.Join(strings.Fields(p), " ")
+	return strings.TrimSpace(trailerRE.ReplaceAllString(p, ""))
+}
+
+// jsString quotes s as a JavaScript string literal.
+func jsString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
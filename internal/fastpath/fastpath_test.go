@@ -0,0 +1,60 @@
+package fastpath
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/sandbox"
+)
+
+func TestSynthesize(t *testing.T) {
+	tests := []struct {
+		prompt string
+		kind   string // "" = no fast path
+		out    string
+	}{
+		{`Print "hello world" and exit`, KindEcho, "hello world\n"},
+		{"  say 'hi there'.  ", KindEcho, "hi there\n"},
+		{`Output the text "a \"quoted\" word"`, "", ""},
+		{`Echo “smart quotes”`, KindEcho, "smart quotes\n"},
+		{"Print the arguments", KindArgs, "x y\n"},
+		{"echo back all the command-line args, then exit.", KindArgs, "x y\n"},
+		{"Print $GREETING", KindEnv, "hi\n"},
+		{"Show the value of the GREETING environment variable", KindEnv, "hi\n"},
+		{"print env var GREETING", KindEnv, "hi\n"},
+
+		// Everything else goes to the agent
+		{"Print hello world and exit", "", ""},
+		{"Print a list of stock values", "", ""},
+		{"Print the home environment variable", "", ""},
+		{"Print \"a\"\nThen print \"b\"", "", ""},
+		{`Print "a" and "b"`, "", ""},
+	}
+	for _, tt := range tests {
+		kind, code, ok := Synthesize(tt.prompt)
+		if kind != tt.kind || ok != (tt.kind != "") {
+			t.Errorf("Synthesize(%q) kind = %q, %v; want %q", tt.prompt, kind, ok, tt.kind)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		var stdout bytes.Buffer
+		sb, err := sandbox.New(sandbox.Config{
+			Stdout:     &stdout,
+			Args:       []string{"x", "y"},
+			ApproveEnv: func(string) (bool, error) { return true, nil },
+			Getenv:     func(name string) string { return map[string]string{"GREETING": "hi"}[name] },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sb.Run(context.Background(), code); err != nil {
+			t.Errorf("%q: memory.js failed: %v\n%s", tt.prompt, err, code)
+		}
+		if stdout.String() != tt.out {
+			t.Errorf("%q: output = %q, want %q", tt.prompt, stdout.String(), tt.out)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Package flock takes exclusive, advisory locks on files, so only one
+// process at a time runs a queue worker or a schedule daemon. A lock is
+// released when its holder unlocks it or exits, however it exits.
+package flock
+
+import "errors"
+
+// ErrLocked is returned by TryLock when another process holds the lock.
+var ErrLocked = errors.New("locked by another process")
+
+// TryLock takes the lock at path without waiting, creating the file if
+// needed. It is released when the returned function is called or the
+// process exits.
+func TryLock(path string) (unlock func(), err error) {
+	return tryLock(path)
+}
@@ -0,0 +1,29 @@
+package flock
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestTryLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "worker.lock")
+	unlock, err := TryLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TryLock(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second TryLock error = %v, want ErrLocked", err)
+	}
+
+	unlock()
+	unlock, err = TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock after unlock: %v", err)
+	}
+	unlock()
+
+	if _, err := TryLock(filepath.Join(t.TempDir(), "missing", "worker.lock")); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("TryLock in a missing directory: err = %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+// Package gitstate keeps a thought's memory.js and memories in a git
+// repository, committing after each run that changes them, so teams can
+// review how a converged thought evolved.
+package gitstate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNoGit is returned when no git binary is on PATH.
+	ErrNoGit = errors.New("git not found in PATH")
+	// ErrNotTracked is returned by Load for a directory without a repository.
+	ErrNotTracked = errors.New("thought is not tracked in git (set \"git: true\" in its frontmatter)")
+)
+
+// ErrNested is returned by Open when the directory is already inside
+// another repository (usually a data_dir checked into a project), which
+// should be committed there instead.
+type ErrNested struct {
+	Toplevel string
+}
+
+func (e *ErrNested) Error() string {
+	return fmt.Sprintf("already inside the git repository at %s", e.Toplevel)
+}
+
+// gitignore tracks only memory.js and memories/: the workspace holds
+// scratch data, and the thought directory holds policy and run history.
+const gitignore = `# Managed by thinkingscript: only memory.js and memories/ are tracked
+/*
+!/.gitignore
+!/memory.js
+!/memories/
+`
+
+// Fallback identity for machines with no git user configured.
+const (
+	fallbackName  = "thinkingscript"
+	fallbackEmail = "thought@localhost"
+)
+
+// Repo is a git repository rooted at a thought's data directory.
+type Repo struct {
+	dir string
+}
+
+// Open returns the repository for dir, initializing it if needed.
+func Open(dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrNoGit
+	}
+	r := &Repo{dir: dir}
+	if r.exists() {
+		return r, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if top, err := r.output("rev-parse", "--show-toplevel"); err == nil {
+		return nil, &ErrNested{Toplevel: strings.TrimSpace(top)}
+	}
+	if _, err := r.output("init", "--quiet"); err != nil {
+		return nil, err
+	}
+	ignorePath := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignorePath); os.IsNotExist(err) {
+		if err := os.WriteFile(ignorePath, []byte(gitignore), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Load returns the existing repository for dir without creating one.
+func Load(dir string) (*Repo, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrNoGit
+	}
+	r := &Repo{dir: dir}
+	if !r.exists() {
+		return nil, ErrNotTracked
+	}
+	return r, nil
+}
+
+// Dir returns the repository's root directory.
+func (r *Repo) Dir() string {
+	return r.dir
+}
+
+// Commit stages everything the .gitignore lets through and commits it.
+// It reports false, without error, when nothing changed.
+func (r *Repo) Commit(message string) (bool, error) {
+	if _, err := r.output("add", "--all"); err != nil {
+		return false, err
+	}
+	if _, err := r.output("diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+	var env []string
+	if _, err := r.output("config", "user.email"); err != nil {
+		env = []string{
+			"GIT_AUTHOR_NAME=" + fallbackName, "GIT_AUTHOR_EMAIL=" + fallbackEmail,
+			"GIT_COMMITTER_NAME=" + fallbackName, "GIT_COMMITTER_EMAIL=" + fallbackEmail,
+		}
+	}
+	args := []string{"commit", "--quiet", "--no-verify", "--no-gpg-sign", "--file", "-"}
+	cmd := r.command(strings.NewReader(message), args...)
+	cmd.Env = append(cmd.Env, env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return false, gitError(args, err, stderr.String())
+	}
+	return true, nil
+}
+
+// Show writes the last n commits with their changes to w. With stat, only
+// a per-file summary is shown instead of the full diff.
+func (r *Repo) Show(w io.Writer, n int, stat bool) error {
+	if _, err := r.output("rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return errors.New("no runs recorded yet")
+	}
+	args := []string{"log", "-n", fmt.Sprint(n)}
+	if stat {
+		args = append(args, "--stat")
+	} else {
+		args = append(args, "--patch")
+	}
+	cmd := r.command(nil, args...)
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return gitError(args, err, stderr.String())
+	}
+	return nil
+}
+
+// exists reports whether dir is itself the root of a repository.
+func (r *Repo) exists() bool {
+	_, err := os.Stat(filepath.Join(r.dir, ".git"))
+	return err == nil
+}
+
+func (r *Repo) output(args ...string) (string, error) {
+	cmd := r.command(nil, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", gitError(args, err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func (r *Repo) command(stdin io.Reader, args ...string) *exec.Cmd {
+	cmd := exec.Command("git", append([]string{"-C", r.dir, "--no-pager"}, args...)...)
+	cmd.Stdin = stdin
+	// Never prompt: runs are often unattended
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+func gitError(args []string, err error, stderr string) error {
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return fmt.Errorf("git %s: %w", args[0], err)
+}
@@ -0,0 +1,89 @@
+package gitstate
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func requireGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// Keep the user's global config (hooks, signing, identity) out of tests
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+}
+
+func TestCommitTracksOnlyThoughtState(t *testing.T) {
+	requireGit(t)
+	dir := filepath.Join(t.TempDir(), "weather")
+	os.MkdirAll(filepath.Join(dir, "memories"), 0700)
+	os.MkdirAll(filepath.Join(dir, "workspace"), 0700)
+	os.WriteFile(filepath.Join(dir, "memory.js"), []byte("console.log(1)"), 0644)
+	os.WriteFile(filepath.Join(dir, "memories", "api.md"), []byte("use v2"), 0644)
+	os.WriteFile(filepath.Join(dir, "workspace", "cache.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "policy.json"), []byte("{}"), 0644)
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	committed, err := repo.Commit("weather: agent run\n\nStatus: ok\n")
+	if err != nil || !committed {
+		t.Fatalf("Commit = %v, %v; want true, nil", committed, err)
+	}
+
+	files, _ := repo.output("ls-files")
+	got := strings.Fields(files)
+	want := []string{".gitignore", "memories/api.md", "memory.js"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("tracked files = %v, want %v", got, want)
+	}
+
+	// No changes: nothing to commit
+	if committed, err := repo.Commit("again"); err != nil || committed {
+		t.Errorf("Commit without changes = %v, %v; want false, nil", committed, err)
+	}
+
+	os.WriteFile(filepath.Join(dir, "memory.js"), []byte("console.log(2)"), 0644)
+	if committed, err := repo.Commit("weather: agent run"); err != nil || !committed {
+		t.Fatalf("second Commit = %v, %v", committed, err)
+	}
+	var out strings.Builder
+	if err := repo.Show(&out, 1, false); err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	if !strings.Contains(out.String(), "-console.log(1)") || !strings.Contains(out.String(), "+console.log(2)") {
+		t.Errorf("Show output missing memory.js diff:\n%s", out.String())
+	}
+
+	// Reopening uses the existing repository
+	if _, err := Open(dir); err != nil {
+		t.Errorf("reopen: %v", err)
+	}
+}
+
+func TestOpenInsideRepository(t *testing.T) {
+	requireGit(t)
+	project := t.TempDir()
+	if out, err := exec.Command("git", "-C", project, "init", "--quiet").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	_, err := Open(filepath.Join(project, ".thought"))
+	var nested *ErrNested
+	if !errors.As(err, &nested) {
+		t.Fatalf("Open inside a repository err = %v, want ErrNested", err)
+	}
+}
+
+func TestLoadUntracked(t *testing.T) {
+	requireGit(t)
+	if _, err := Load(t.TempDir()); err != ErrNotTracked {
+		t.Errorf("Load err = %v, want ErrNotTracked", err)
+	}
+}
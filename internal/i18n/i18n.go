@@ -0,0 +1,171 @@
+// Package i18n translates the strings think shows people: approval
+// prompts, questions, spinners, and status labels. Catalogs are flat JSON
+// objects of key → fmt format, one per locale: the built-in ones are
+// embedded from locales/, and files in ~/.thinkingscript/locales/ add
+// languages or override built-in strings, so translations can be tried
+// without rebuilding. A missing string falls back to the language without
+// its region, then English, then the key itself.
+//
+// Error messages and everything sent to the model stay in English: they
+// are read by the agent and attached to bug reports.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+// Source is the locale every key is defined in first.
+const Source = "en"
+
+//go:embed locales/*.json
+var builtin embed.FS
+
+var (
+	once    sync.Once
+	locale  string
+	catalog map[string]string
+)
+
+func load() {
+	locale = Detect(os.Getenv, config.LoadConfig().Locale)
+	catalog = Catalog(locale, Dir())
+}
+
+// T returns the current locale's string for key, formatted with args as
+// by fmt.Sprintf when there are any.
+func T(key string, args ...any) string {
+	once.Do(load)
+	s, ok := catalog[key]
+	if !ok {
+		s = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+	return s
+}
+
+// Locale returns the locale strings are shown in, e.g. "pt-BR".
+func Locale() string {
+	once.Do(load)
+	return locale
+}
+
+// Dir returns where community translations are read from.
+func Dir() string {
+	return filepath.Join(config.HomeDir(), "locales")
+}
+
+// Detect picks the locale: THINKINGSCRIPT__LOCALE, then config.json's
+// locale, then LC_ALL, LC_MESSAGES, and LANG as POSIX defines them.
+// "C", "POSIX", and unset mean English.
+func Detect(getenv func(string) string, configured string) string {
+	for _, v := range []string{getenv("THINKINGSCRIPT__LOCALE"), configured, getenv("LC_ALL"), getenv("LC_MESSAGES"), getenv("LANG")} {
+		if v == "" {
+			continue
+		}
+		if tag := Normalize(v); tag != "" {
+			return tag
+		}
+		return Source
+	}
+	return Source
+}
+
+// Normalize turns a POSIX or BCP 47 locale ("pt_BR.UTF-8", "de-de",
+// "sr@latin") into the tag catalogs are named by ("pt-BR", "de-DE",
+// "sr"). "C" and "POSIX" have none.
+func Normalize(v string) string {
+	v, _, _ = strings.Cut(v, ".")
+	v, _, _ = strings.Cut(v, "@")
+	lang, region, _ := strings.Cut(strings.ReplaceAll(v, "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	if lang == "" || lang == "c" || lang == "posix" {
+		return ""
+	}
+	if region != "" {
+		return lang + "-" + strings.ToUpper(region)
+	}
+	return lang
+}
+
+// Catalog returns the strings for tag: English, overlaid with the
+// language's catalog, then the region's. At each step a file in dir
+// overrides the built-in one key by key. Unreadable files are skipped.
+func Catalog(tag, dir string) map[string]string {
+	layers := []string{Source}
+	if lang, _, _ := strings.Cut(tag, "-"); lang != Source {
+		layers = append(layers, lang)
+	}
+	if strings.Contains(tag, "-") {
+		layers = append(layers, tag)
+	}
+	out := map[string]string{}
+	for _, name := range layers {
+		if data, err := builtin.ReadFile("locales/" + name + ".json"); err == nil {
+			merge(out, data)
+		}
+		if dir != "" {
+			if data, err := os.ReadFile(filepath.Join(dir, name+".json")); err == nil {
+				merge(out, data)
+			}
+		}
+	}
+	return out
+}
+
+func merge(into map[string]string, data []byte) {
+	var strs map[string]string
+	if json.Unmarshal(data, &strs) != nil {
+		return
+	}
+	for k, v := range strs {
+		if v != "" {
+			into[k] = v
+		}
+	}
+}
+
+// Builtin returns the embedded catalog for tag exactly as shipped, without
+// fallbacks, or nil if there is none; `thought locale --template` prints
+// the English one for translators to start from.
+func Builtin(tag string) map[string]string {
+	data, err := builtin.ReadFile("locales/" + tag + ".json")
+	if err != nil {
+		return nil
+	}
+	strs := map[string]string{}
+	merge(strs, data)
+	return strs
+}
+
+// Locales lists the built-in catalogs' tags.
+func Locales() []string {
+	entries, _ := builtin.ReadDir("locales")
+	var tags []string
+	for _, e := range entries {
+		tags = append(tags, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return tags
+}
+
+// YesAnswers returns the answers that count as yes to a [y/N] question:
+// the locale's confirm.yes list plus English "y" and "yes", which always
+// work.
+func YesAnswers() []string {
+	answers := []string{"y", "yes"}
+	for _, a := range strings.Split(T("confirm.yes"), ",") {
+		if a = strings.ToLower(strings.TrimSpace(a)); a != "" {
+			answers = append(answers, a)
+		}
+	}
+	return answers
+}
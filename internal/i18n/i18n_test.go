@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(name string) string { return vars[name] }
+	}
+	tests := []struct {
+		vars       map[string]string
+		configured string
+		want       string
+	}{
+		{nil, "", "en"},
+		{map[string]string{"LANG": "de_DE.UTF-8"}, "", "de-DE"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LC_MESSAGES": "es_ES.UTF-8"}, "", "es-ES"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "LC_ALL": "C"}, "", "en"},
+		{map[string]string{"LANG": "de_DE.UTF-8"}, "es", "es"},
+		{map[string]string{"LANG": "de_DE.UTF-8", "THINKINGSCRIPT__LOCALE": "pt-br"}, "es", "pt-BR"},
+	}
+	for _, tt := range tests {
+		if got := Detect(env(tt.vars), tt.configured); got != tt.want {
+			t.Errorf("Detect(%v, %q) = %q, want %q", tt.vars, tt.configured, got, tt.want)
+		}
+	}
+	if got := Normalize("sr_RS@latin"); got != "sr-RS" {
+		t.Errorf("Normalize(sr_RS@latin) = %q", got)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "de-AT.json"), []byte(`{"confirm.run_plan": "Mit dem Plan loslegen?"}`), 0600)
+	os.WriteFile(filepath.Join(dir, "xx.json"), []byte(`{"prompt.ask": "XX", "prompt.default": ""}`), 0600)
+
+	// Region overrides language, which overrides English
+	c := Catalog("de-AT", dir)
+	if c["confirm.run_plan"] != "Mit dem Plan loslegen?" || c["prompt.ask"] != "FRAGE" {
+		t.Errorf("de-AT = %q, %q", c["confirm.run_plan"], c["prompt.ask"])
+	}
+	// A language that's only in dir; empty strings fall back
+	c = Catalog("xx", dir)
+	if c["prompt.ask"] != "XX" || c["prompt.default"] != "(default: %s)" {
+		t.Errorf("xx = %q, %q", c["prompt.ask"], c["prompt.default"])
+	}
+	if c := Catalog("zz-ZZ", ""); c["prompt.ask"] != "ASK" {
+		t.Errorf("unknown locale = %q, want English", c["prompt.ask"])
+	}
+}
+
+// Every built-in translation must only have keys English has, with the
+// same format verbs in the same order, and every key the code uses must
+// exist in English.
+func TestBuiltinCatalogs(t *testing.T) {
+	source := Builtin(Source)
+	verbs := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for _, tag := range Locales() {
+		for key, s := range Builtin(tag) {
+			en, ok := source[key]
+			if !ok {
+				t.Errorf("%s.json: %q is not in %s.json", tag, key, Source)
+				continue
+			}
+			if got, want := verbs.FindAllString(s, -1), verbs.FindAllString(en, -1); !slices.Equal(got, want) {
+				t.Errorf("%s.json: %q has verbs %v, want %v", tag, key, got, want)
+			}
+		}
+	}
+
+	used := regexp.MustCompile(`i18n\.T\("([^"]+)"`)
+	root := filepath.Join("..", "..")
+	filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, m := range used.FindAllStringSubmatch(string(data), -1) {
+			if _, ok := source[m[1]]; !ok {
+				t.Errorf("%s: i18n key %q is not in %s.json", path, m[1], Source)
+			}
+		}
+		return nil
+	})
+}
+
+func TestYesAnswers(t *testing.T) {
+	once.Do(func() {})
+	locale, catalog = "de", Catalog("de", "")
+	t.Cleanup(func() { locale, catalog = Source, Catalog(Source, "") })
+	if got := YesAnswers(); !slices.Contains(got, "ja") || !slices.Contains(got, "yes") {
+		t.Errorf("YesAnswers in de = %v", got)
+	}
+	if got := T("approval.denied_by_policy", "global"); !strings.HasPrefix(got, "durch gespeicherte global-Richtlinie") {
+		t.Errorf("T = %q", got)
+	}
+}
@@ -0,0 +1,369 @@
+// Package journal records the filesystem effects of a run so they can be
+// rolled back with `thought undo`. Each mutating fs operation is logged
+// before it happens; small files that are about to be overwritten or
+// removed are snapshotted so their old content can be put back.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/trash"
+)
+
+// MaxSnapshotSize is the largest file whose previous content is kept.
+// Larger files are logged but can't be restored.
+const MaxSnapshotSize = 1 << 20
+
+// KeepRuns is how many run journals are kept per thought.
+const KeepRuns = 20
+
+// Operations recorded in the journal.
+const (
+	OpWrite  = "write"  // file created or overwritten (writeFile, appendFile, copy destination)
+	OpMkdir  = "mkdir"  // directory created
+	OpMove   = "move"   // Path renamed to Dest
+	OpTrash  = "trash"  // Path moved to the trash as TrashID
+	OpRemove = "remove" // Path removed permanently
+)
+
+// Record is one journaled operation.
+type Record struct {
+	Seq      int       `json:"seq"`
+	Time     time.Time `json:"time"`
+	Op       string    `json:"op"`
+	Path     string    `json:"path"`
+	Dest     string    `json:"dest,omitempty"`
+	Existed  bool      `json:"existed,omitempty"`  // a file was at Path (or Dest for move) before
+	Snapshot string    `json:"snapshot,omitempty"` // blob holding the previous content
+	TrashID  string    `json:"trash_id,omitempty"`
+}
+
+// Journal collects the records of one run. A nil *Journal records nothing,
+// so callers can pass it around unconditionally. Safe for concurrent use.
+type Journal struct {
+	thoughtDir string
+
+	forward func(Record) // set by Forward; records go here instead of the log
+
+	mu     sync.Mutex
+	runDir string // created on the first record
+	seq    int
+}
+
+// Dir returns the journal directory for a thought.
+func Dir(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "journal")
+}
+
+// New returns a journal for a new run of the thought. Nothing is written
+// until the first operation is recorded.
+func New(thoughtDir string) *Journal {
+	return &Journal{thoughtDir: thoughtDir}
+}
+
+// Forward returns a journal that passes each record to fn instead of
+// writing it, for a sandbox running in another process (a container): fn
+// relays the record to the host's journal, which snapshots the file before
+// fn returns and the sandbox goes on to change it. Only Op, Path, Dest, and
+// TrashID are set.
+func Forward(fn func(Record)) *Journal {
+	return &Journal{forward: fn}
+}
+
+// RunID returns the ID of this run, or "" if nothing has been recorded.
+func (j *Journal) RunID() string {
+	if j == nil {
+		return ""
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.runDir == "" {
+		return ""
+	}
+	return filepath.Base(j.runDir)
+}
+
+// Write records that path is about to be created or modified.
+func (j *Journal) Write(path string) {
+	j.record(Record{Op: OpWrite, Path: path}, path)
+}
+
+// Mkdir records that path is about to be created with MkdirAll. Only the
+// topmost missing directory is recorded, since undo removes it whole.
+func (j *Journal) Mkdir(path string) {
+	if j == nil {
+		return
+	}
+	top := ""
+	for p := path; ; p = filepath.Dir(p) {
+		if _, err := os.Lstat(p); err == nil {
+			break
+		}
+		top = p
+		if filepath.Dir(p) == p {
+			break
+		}
+	}
+	if top != "" {
+		j.record(Record{Op: OpMkdir, Path: top}, "")
+	}
+}
+
+// Move records that src is about to be renamed to dst.
+func (j *Journal) Move(src, dst string) {
+	j.record(Record{Op: OpMove, Path: src, Dest: dst}, dst)
+}
+
+// Trash records that path was moved to the trash as id.
+func (j *Journal) Trash(path, id string) {
+	j.record(Record{Op: OpTrash, Path: path, TrashID: id}, "")
+}
+
+// Remove records that path is about to be removed permanently.
+func (j *Journal) Remove(path string) {
+	j.record(Record{Op: OpRemove, Path: path}, path)
+}
+
+// record appends r to the log, first snapshotting the file at snap if it
+// exists and is small enough. Journaling is best effort: failures never
+// block the operation being recorded.
+func (j *Journal) record(r Record, snap string) {
+	if j == nil {
+		return
+	}
+	if j.forward != nil {
+		j.forward(r)
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.runDir == "" {
+		dir, err := newRunDir(Dir(j.thoughtDir))
+		if err != nil {
+			return
+		}
+		j.runDir = dir
+		prune(Dir(j.thoughtDir), KeepRuns)
+	}
+
+	j.seq++
+	r.Seq = j.seq
+	r.Time = time.Now()
+	if snap != "" {
+		if info, err := os.Lstat(snap); err == nil {
+			r.Existed = true
+			if info.Mode().IsRegular() && info.Size() <= MaxSnapshotSize {
+				blob := fmt.Sprintf("%d", r.Seq)
+				if copyFile(snap, filepath.Join(j.runDir, "blobs", blob)) == nil {
+					r.Snapshot = blob
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(j.runDir, "log.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+func newRunDir(journalDir string) (string, error) {
+	if err := os.MkdirAll(journalDir, 0700); err != nil {
+		return "", err
+	}
+	stamp := time.Now().Format("20060102-150405")
+	for n := 0; ; n++ {
+		dir := filepath.Join(journalDir, fmt.Sprintf("%s-%d", stamp, n))
+		err := os.Mkdir(dir, 0700)
+		if err == nil {
+			return dir, os.Mkdir(filepath.Join(dir, "blobs"), 0700)
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+	}
+}
+
+// prune removes the oldest run journals beyond keep.
+func prune(journalDir string, keep int) {
+	runs, err := Runs(filepath.Dir(journalDir))
+	if err != nil || len(runs) <= keep {
+		return
+	}
+	for _, r := range runs[:len(runs)-keep] {
+		os.RemoveAll(filepath.Join(journalDir, r.ID))
+	}
+}
+
+// Run summarizes one run's journal.
+type Run struct {
+	ID      string
+	Started time.Time
+	Ops     int
+	Undone  bool
+}
+
+// Runs lists a thought's run journals, oldest first.
+func Runs(thoughtDir string) ([]Run, error) {
+	dirs, err := os.ReadDir(Dir(thoughtDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []Run
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		runDir := filepath.Join(Dir(thoughtDir), d.Name())
+		records, err := readLog(runDir)
+		if err != nil {
+			continue
+		}
+		run := Run{ID: d.Name(), Ops: len(records)}
+		if len(records) > 0 {
+			run.Started = records[0].Time
+		}
+		if _, err := os.Stat(filepath.Join(runDir, "undone")); err == nil {
+			run.Undone = true
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, k int) bool { return runs[i].ID < runs[k].ID })
+	return runs, nil
+}
+
+// ErrUndone is returned when a run has already been rolled back.
+var ErrUndone = errors.New("run was already undone")
+
+// Undo rolls back a run's operations in reverse order. It keeps going past
+// operations it can't revert and returns a description of each as a
+// problem. The run is marked undone afterwards.
+func Undo(thoughtDir, runID string) (undone int, problems []string, err error) {
+	runDir := filepath.Join(Dir(thoughtDir), filepath.Base(runID))
+	if _, err := os.Stat(filepath.Join(runDir, "undone")); err == nil {
+		return 0, nil, ErrUndone
+	}
+	records, err := readLog(runDir)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading journal: %w", err)
+	}
+
+	for i := len(records) - 1; i >= 0; i-- {
+		if err := undoRecord(thoughtDir, runDir, records[i]); err != nil {
+			problems = append(problems, fmt.Sprintf("%s %s: %v", records[i].Op, records[i].Path, err))
+			continue
+		}
+		undone++
+	}
+	return undone, problems, os.WriteFile(filepath.Join(runDir, "undone"), []byte(time.Now().Format(time.RFC3339)+"\n"), 0600)
+}
+
+func undoRecord(thoughtDir, runDir string, r Record) error {
+	restore := func(path string) error {
+		if r.Snapshot == "" {
+			return errors.New("previous content was not saved (too large or not a regular file)")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return copyFile(filepath.Join(runDir, "blobs", r.Snapshot), path)
+	}
+
+	switch r.Op {
+	case OpWrite:
+		if r.Existed {
+			return restore(r.Path)
+		}
+		if err := os.Remove(r.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	case OpMkdir:
+		if err := os.Remove(r.Path); err != nil && !os.IsNotExist(err) {
+			return errors.New("directory is not empty")
+		}
+		return nil
+	case OpMove:
+		if _, err := os.Lstat(r.Path); err == nil {
+			return fmt.Errorf("%s exists again", r.Path)
+		}
+		if err := os.Rename(r.Dest, r.Path); err != nil {
+			return err
+		}
+		if r.Existed {
+			return restore(r.Dest)
+		}
+		return nil
+	case OpTrash:
+		_, err := trash.Restore(trash.Dir(thoughtDir), r.TrashID, "")
+		return err
+	case OpRemove:
+		if !r.Existed {
+			return nil
+		}
+		if _, err := os.Lstat(r.Path); err == nil {
+			return fmt.Errorf("%s exists again", r.Path)
+		}
+		return restore(r.Path)
+	}
+	return fmt.Errorf("unknown op %q", r.Op)
+}
+
+func readLog(runDir string) ([]Record, error) {
+	f, err := os.Open(filepath.Join(runDir, "log.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []Record
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			continue // a torn final line from a crash
+		}
+		records = append(records, r)
+	}
+	return records, sc.Err()
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
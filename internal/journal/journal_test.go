@@ -0,0 +1,109 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/trash"
+)
+
+func TestUndo(t *testing.T) {
+	dir := t.TempDir()
+	thoughtDir := filepath.Join(dir, "thought")
+	data := filepath.Join(dir, "data")
+	os.MkdirAll(data, 0755)
+
+	edited := filepath.Join(data, "edited.txt")
+	created := filepath.Join(data, "new", "created.txt")
+	moved := filepath.Join(data, "moved.txt")
+	trashed := filepath.Join(data, "trashed.txt")
+	removed := filepath.Join(data, "removed.txt")
+	for path, content := range map[string]string{edited: "before", moved: "m", trashed: "t", removed: "r"} {
+		os.WriteFile(path, []byte(content), 0644)
+	}
+
+	j := New(thoughtDir)
+	if j.RunID() != "" {
+		t.Fatal("RunID should be empty before anything is recorded")
+	}
+
+	// Simulate a run, journaling before each change like the sandbox does.
+	j.Write(edited)
+	os.WriteFile(edited, []byte("after"), 0644)
+
+	j.Mkdir(filepath.Dir(created))
+	os.MkdirAll(filepath.Dir(created), 0755)
+	j.Write(created)
+	os.WriteFile(created, []byte("new"), 0644)
+
+	j.Move(moved, moved+".bak")
+	os.Rename(moved, moved+".bak")
+
+	e, err := trash.Put(trash.Dir(thoughtDir), trashed)
+	if err != nil {
+		t.Fatalf("trash.Put error: %v", err)
+	}
+	j.Trash(trashed, e.ID)
+
+	j.Remove(removed)
+	os.Remove(removed)
+
+	runID := j.RunID()
+	if runID == "" {
+		t.Fatal("RunID should be set after recording")
+	}
+
+	undone, problems, err := Undo(thoughtDir, runID)
+	if err != nil {
+		t.Fatalf("Undo error: %v", err)
+	}
+	if undone != 6 || len(problems) != 0 {
+		t.Errorf("Undo = %d undone, problems %v; want 6, none", undone, problems)
+	}
+
+	for path, want := range map[string]string{edited: "before", moved: "m", trashed: "t", removed: "r"} {
+		if got, err := os.ReadFile(path); err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v; want %q", filepath.Base(path), got, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(data, "new")); !os.IsNotExist(err) {
+		t.Error("created directory should have been removed")
+	}
+
+	if _, _, err := Undo(thoughtDir, runID); !errors.Is(err, ErrUndone) {
+		t.Errorf("second Undo error = %v, want ErrUndone", err)
+	}
+	runs, err := Runs(thoughtDir)
+	if err != nil || len(runs) != 1 || !runs[0].Undone || runs[0].Ops != 6 {
+		t.Errorf("Runs = %+v, %v; want one undone run of 6 ops", runs, err)
+	}
+}
+
+func TestUndoLargeFile(t *testing.T) {
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.bin")
+	os.WriteFile(big, make([]byte, MaxSnapshotSize+1), 0644)
+
+	j := New(filepath.Join(dir, "thought"))
+	j.Write(big)
+	os.WriteFile(big, []byte("small"), 0644)
+
+	_, problems, err := Undo(filepath.Join(dir, "thought"), j.RunID())
+	if err != nil {
+		t.Fatalf("Undo error: %v", err)
+	}
+	if len(problems) != 1 {
+		t.Errorf("problems = %v, want one for the unsaved large file", problems)
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	var j *Journal
+	j.Write("/nowhere")
+	j.Remove("/nowhere")
+	if j.RunID() != "" {
+		t.Error("nil journal should have no run ID")
+	}
+}
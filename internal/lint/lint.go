@@ -0,0 +1,194 @@
+// Package lint runs cheap local checks over code before the sandbox runs
+// it: agent scripts (run_script) and memory.js. Each rule warns or denies;
+// config.json "lint" changes a rule's action or turns it off, and a
+// denied script can still be run if the user says so at a prompt.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// Actions a rule can take.
+const (
+	Off  = "off"
+	Warn = "warn"
+	Deny = "deny"
+)
+
+// Rule is one check.
+type Rule struct {
+	Name        string
+	Description string
+	Default     string
+	match       func(code string) (evidence string, ok bool)
+}
+
+var (
+	policyRE   = regexp.MustCompile(`policy\.json`)
+	fsWriteRE  = regexp.MustCompile(`\bfs\.(?:writeFile|appendFile|delete|move|copy)\s*\(`)
+	metadataRE = regexp.MustCompile(`169\.254\.169\.254|169\.254\.170\.2|100\.100\.100\.200|fd00:ec2::254|metadata\.google\.internal|metadata\.azure\.com`)
+	evalRE     = regexp.MustCompile(`\beval\s*\(|\bFunction\s*\(`)
+	fetchRE    = regexp.MustCompile(`\bnet\.fetch\s*\(`)
+)
+
+// Rules lists every rule in the order findings are reported.
+var Rules = []Rule{
+	{
+		Name:        "policy-write",
+		Description: "modifies a file named policy.json",
+		Default:     Deny,
+		match: func(code string) (string, bool) {
+			if !fsWriteRE.MatchString(code) {
+				return "", false
+			}
+			return evidence(code, policyRE)
+		},
+	},
+	{
+		Name:        "metadata-service",
+		Description: "addresses a cloud metadata service, which can hand out credentials",
+		Default:     Deny,
+		match: func(code string) (string, bool) {
+			return evidence(code, metadataRE)
+		},
+	},
+	{
+		Name:        "eval-fetched",
+		Description: "evaluates code (eval or Function) in a script that fetches from the network",
+		Default:     Warn,
+		match: func(code string) (string, bool) {
+			if !fetchRE.MatchString(code) {
+				return "", false
+			}
+			return evidence(code, evalRE)
+		},
+	},
+}
+
+// evidence returns the line where re first matches.
+func evidence(code string, re *regexp.Regexp) (string, bool) {
+	loc := re.FindStringIndex(code)
+	if loc == nil {
+		return "", false
+	}
+	start := strings.LastIndexByte(code[:loc[0]], '\n') + 1
+	end := len(code)
+	if i := strings.IndexByte(code[loc[1]:], '\n'); i >= 0 {
+		end = loc[1] + i
+	}
+	line := strings.TrimSpace(code[start:end])
+	if len(line) > 120 {
+		line = line[:117] + "..."
+	}
+	return line, true
+}
+
+// Finding is a rule that matched.
+type Finding struct {
+	Rule        string
+	Action      string
+	Description string
+	Evidence    string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s (%s)", f.Rule, f.Description, f.Evidence)
+}
+
+// Linter applies the rules with their configured actions.
+type Linter struct {
+	actions map[string]string
+}
+
+// New returns a Linter with config.json's per-rule action overrides.
+func New(overrides map[string]string) (*Linter, error) {
+	l := &Linter{actions: make(map[string]string, len(Rules))}
+	for _, r := range Rules {
+		l.actions[r.Name] = r.Default
+	}
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := l.actions[name]; !ok {
+			return nil, fmt.Errorf("lint: unknown rule %q", name)
+		}
+		switch action := overrides[name]; action {
+		case Off, Warn, Deny:
+			l.actions[name] = action
+		default:
+			return nil, fmt.Errorf("lint: rule %s: action must be off, warn, or deny, not %q", name, action)
+		}
+	}
+	return l, nil
+}
+
+// Check returns the findings for code; rules that are off never match.
+func (l *Linter) Check(code string) []Finding {
+	var findings []Finding
+	for _, r := range Rules {
+		action := l.actions[r.Name]
+		if action == Off {
+			continue
+		}
+		if ev, ok := r.match(code); ok {
+			findings = append(findings, Finding{Rule: r.Name, Action: action, Description: r.Description, Evidence: ev})
+		}
+	}
+	return findings
+}
+
+var (
+	warnStyle  = ui.Renderer.NewStyle().Foreground(lipgloss.Color("214"))
+	denyStyle  = ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
+	labelStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+)
+
+// Review lints code (what names it in messages, e.g. "this script"),
+// prints the findings, and asks confirm whether to run code that a deny
+// rule matched. It returns the findings as notes for the model, and an
+// error when the code must not run. A nil Linter allows everything.
+func (l *Linter) Review(code, what string, confirm func(question string) (bool, error)) ([]string, error) {
+	if l == nil {
+		return nil, nil
+	}
+	var notes []string
+	var denied []Finding
+	for _, f := range l.Check(code) {
+		notes = append(notes, "lint "+f.String())
+		if f.Action == Deny {
+			denied = append(denied, f)
+			fmt.Fprintf(os.Stderr, "    %s %s\n", denyStyle.Render("✕ lint "+f.Rule+":"), labelStyle.Render(f.Description+": "+f.Evidence))
+		} else {
+			fmt.Fprintf(os.Stderr, "    %s %s\n", warnStyle.Render("! lint "+f.Rule+":"), labelStyle.Render(f.Description+": "+f.Evidence))
+		}
+	}
+	if len(denied) == 0 {
+		return notes, nil
+	}
+
+	rules := make([]string, len(denied))
+	for i, f := range denied {
+		rules[i] = f.Rule
+	}
+	ok, err := confirm(i18n.T("confirm.lint", what, strings.Join(rules, ", ")))
+	if err == nil && ok {
+		return append(notes, "the user chose to run it despite the lint findings"), nil
+	}
+	msg := fmt.Sprintf("%s was not run: blocked by lint", what)
+	for _, f := range denied {
+		msg += "\n- " + f.String()
+	}
+	return nil, errors.New(msg)
+}
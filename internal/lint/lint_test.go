@@ -0,0 +1,95 @@
+package lint
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	l, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		code  string
+		rules []string
+	}{
+		{`fs.writeFile("out.txt", "hi")`, nil},
+		{`var p = fs.readFile("/home/u/.thinkingscript/policy.json")`, nil},
+		{"var p = \"/home/u/.thinkingscript/policy.json\";\nfs.writeFile(p, \"{}\")", []string{"policy-write"}},
+		{`net.fetch("http://169.254.169.254/latest/meta-data/")`, []string{"metadata-service"}},
+		{`net.fetch("http://metadata.google.internal/computeMetadata/v1/")`, []string{"metadata-service"}},
+		{`eval(net.fetch(url).body)`, []string{"eval-fetched"}},
+		{`var f = new Function("return 1")`, nil},
+		{`var f = new Function(net.fetch(url).body)`, []string{"eval-fetched"}},
+		{`var r = evaluate(net.fetch(url).body)`, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, f := range l.Check(tt.code) {
+			got = append(got, f.Rule)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.rules, ",") {
+			t.Errorf("Check(%q) = %v, want %v", tt.code, got, tt.rules)
+		}
+	}
+
+	f := l.Check("var a = 1;\nnet.fetch(\"http://169.254.169.254/\");\nvar b = 2;")[0]
+	if f.Evidence != `net.fetch("http://169.254.169.254/");` || f.Action != Deny {
+		t.Errorf("finding = %+v", f)
+	}
+}
+
+func TestNew(t *testing.T) {
+	l, err := New(map[string]string{"metadata-service": "off", "eval-fetched": "deny"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs := l.Check(`net.fetch("http://169.254.169.254/")`); len(fs) != 0 {
+		t.Errorf("rule turned off still matched: %v", fs)
+	}
+	if fs := l.Check(`eval(net.fetch(u).body)`); len(fs) != 1 || fs[0].Action != Deny {
+		t.Errorf("overridden action = %v", fs)
+	}
+	if _, err := New(map[string]string{"nope": "warn"}); err == nil {
+		t.Error("unknown rule accepted")
+	}
+	if _, err := New(map[string]string{"policy-write": "block"}); err == nil {
+		t.Error("unknown action accepted")
+	}
+}
+
+func TestReview(t *testing.T) {
+	l, _ := New(nil)
+	var asked []string
+	answer := func(ok bool, err error) func(string) (bool, error) {
+		return func(q string) (bool, error) {
+			asked = append(asked, q)
+			return ok, err
+		}
+	}
+
+	if notes, err := l.Review(`eval(net.fetch(u).body)`, "this script", answer(false, nil)); err != nil || len(notes) != 1 || len(asked) != 0 {
+		t.Errorf("warning: notes %v, err %v, asked %v", notes, err, asked)
+	}
+
+	code := `net.fetch("http://169.254.169.254/")`
+	if _, err := l.Review(code, "this script", answer(false, nil)); err == nil || !strings.Contains(err.Error(), "metadata-service") {
+		t.Errorf("declined deny: err = %v", err)
+	}
+	if _, err := l.Review(code, "memory.js", answer(true, errors.New("no TTY"))); err == nil {
+		t.Error("deny without a terminal ran anyway")
+	}
+	if notes, err := l.Review(code, "this script", answer(true, nil)); err != nil || len(notes) != 2 {
+		t.Errorf("bypassed deny: notes %v, err %v", notes, err)
+	}
+	if len(asked) != 3 || !strings.Contains(asked[0], "metadata-service") {
+		t.Errorf("questions = %v", asked)
+	}
+
+	var none *Linter
+	if notes, err := none.Review(code, "this script", nil); notes != nil || err != nil {
+		t.Errorf("nil Linter = %v, %v", notes, err)
+	}
+}
@@ -0,0 +1,272 @@
+// Package lockfile pins what a converged memory.js relies on. After a
+// successful run, thought.lock (next to memory.js) records the modules it
+// loaded with require(), each with its SHA-256, its package version if it
+// has a package.json, and the URL net.download fetched it from if it was
+// downloaded, plus the API base URLs the run fetched from. Before memory.js
+// runs again, the modules are checked against the lock: when one has
+// changed or gone missing, the run hands over to the agent with a resume
+// context listing the drift instead of running memory.js against code it
+// wasn't written for. APIs can only be checked as the run uses them; a new
+// one is reported as a warning.
+//
+// Other downloads are data that memory.js may refresh on every run, so
+// they aren't locked; net.download's sha256 option pins one when it must
+// not change.
+//
+// A lock belongs to one memory.js, by content hash. When the agent (or a
+// person) rewrites memory.js, the old lock no longer applies, and the next
+// successful run writes a new one.
+package lockfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Name is the lockfile's name, in the directory holding memory.js.
+const Name = "thought.lock"
+
+// Version is the lockfile format version.
+const Version = 1
+
+// Lock is the contents of thought.lock. Paths are relative to the
+// lockfile's directory (with forward slashes) when they're inside it.
+type Lock struct {
+	Version  int       `json:"version"`
+	Created  time.Time `json:"created"`
+	MemoryJS string    `json:"memory_js"` // SHA-256 of the memory.js this lock is for
+	Modules  []Module  `json:"modules"`
+	APIs     []string  `json:"apis"` // scheme://host of each URL net.fetch or net.download used
+}
+
+// Module is a file memory.js loaded with require().
+type Module struct {
+	Path    string `json:"path"`
+	URL     string `json:"url,omitempty"`     // where net.download fetched it from, if it did
+	Version string `json:"version,omitempty"` // from the nearest package.json, if any
+	SHA256  string `json:"sha256"`
+}
+
+// Path returns the lockfile for the memory.js in dir.
+func Path(dir string) string {
+	return filepath.Join(dir, Name)
+}
+
+// Load reads a lockfile. It returns nil, nil when there is none.
+func Load(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var l Lock
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if l.Version > Version {
+		return nil, fmt.Errorf("%s: version %d is newer than this version of think understands (%d)", path, l.Version, Version)
+	}
+	return &l, nil
+}
+
+// Save writes the lockfile.
+func (l *Lock) Save(path string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// For reports whether the lock was written for this memory.js.
+func (l *Lock) For(code []byte) bool {
+	return l.MemoryJS == sum(code)
+}
+
+// Verify checks the locked modules, relative to dir, and describes each
+// one that no longer matches. nil means no drift.
+func (l *Lock) Verify(dir string) []string {
+	var drift []string
+	for _, m := range l.Modules {
+		what := "module " + m.Path
+		if m.Version != "" {
+			what += " (version " + m.Version + ")"
+		}
+		if m.URL != "" {
+			what += " from " + m.URL
+		}
+		data, err := os.ReadFile(abs(dir, m.Path))
+		switch {
+		case os.IsNotExist(err):
+			drift = append(drift, what+" is missing")
+		case err != nil:
+			drift = append(drift, fmt.Sprintf("%s can't be read: %v", what, err))
+		case sum(data) != m.SHA256:
+			drift = append(drift, fmt.Sprintf("%s changed (locked sha256 %s, now %s)", what, short(m.SHA256), short(sum(data))))
+		}
+	}
+	return drift
+}
+
+// Unlocked returns the APIs r saw that the lock doesn't list.
+func (l *Lock) Unlocked(r *Recorder) []string {
+	var out []string
+	for _, api := range r.apis() {
+		if !slices.Contains(l.APIs, api) {
+			out = append(out, api)
+		}
+	}
+	return out
+}
+
+// DriftContext is the resume context for a run stopped by drift.
+func DriftContext(drift []string) string {
+	return "thought.lock: resources memory.js was locked against have changed:\n- " +
+		strings.Join(drift, "\n- ") +
+		"\nRestore the locked files, or update memory.js for the new ones (a rewritten memory.js is locked again after its next successful run)."
+}
+
+// Recorder collects what one memory.js run depends on. Its methods are the
+// sandbox's OnRequire, OnDownload, and OnFetch hooks, and are safe for
+// concurrent use.
+type Recorder struct {
+	mu        sync.Mutex
+	modules   map[string]string // path -> sha256
+	downloads map[string]string // path -> URL
+	seen      map[string]bool   // APIs
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		modules:   make(map[string]string),
+		downloads: make(map[string]string),
+		seen:      make(map[string]bool),
+	}
+}
+
+// Require records a module.
+func (r *Recorder) Require(path, sha string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modules[path] = sha
+}
+
+// Download records where a file came from, in case memory.js requires it,
+// and the API it came from.
+func (r *Recorder) Download(rawURL, path, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.downloads[path] = rawURL
+	r.api(rawURL)
+}
+
+// Fetch records the API a net.fetch used.
+func (r *Recorder) Fetch(rawURL string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.api(rawURL)
+}
+
+func (r *Recorder) api(rawURL string) {
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		r.seen[u.Scheme+"://"+u.Host] = true
+	}
+}
+
+func (r *Recorder) apis() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for api := range r.seen {
+		out = append(out, api)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Lock returns the lock for code from what r recorded, with paths
+// relative to dir.
+func (r *Recorder) Lock(code []byte, dir string) *Lock {
+	// The sandbox reports paths with symlinks resolved
+	if d, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = d
+	}
+	l := &Lock{
+		Version:  Version,
+		Created:  time.Now().UTC(),
+		MemoryJS: sum(code),
+		Modules:  []Module{},
+		APIs:     r.apis(),
+	}
+	if l.APIs == nil {
+		l.APIs = []string{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for path, sha := range r.modules {
+		l.Modules = append(l.Modules, Module{Path: rel(dir, path), URL: r.downloads[path], Version: packageVersion(dir, path), SHA256: sha})
+	}
+	slices.SortFunc(l.Modules, func(a, b Module) int { return strings.Compare(a.Path, b.Path) })
+	return l
+}
+
+// packageVersion returns the version in the package.json nearest to a
+// module, looking no higher than dir (or the module's own directory when
+// it's outside dir).
+func packageVersion(dir, path string) string {
+	for d := filepath.Dir(path); ; d = filepath.Dir(d) {
+		data, err := os.ReadFile(filepath.Join(d, "package.json"))
+		if err == nil {
+			var pkg struct {
+				Version string `json:"version"`
+			}
+			json.Unmarshal(data, &pkg)
+			return pkg.Version
+		}
+		if d == dir || !strings.HasPrefix(d, dir+string(filepath.Separator)) {
+			return ""
+		}
+	}
+}
+
+func sum(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// short abbreviates a SHA-256 for messages.
+func short(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// rel writes path relative to dir when it's inside it.
+func rel(dir, path string) string {
+	if r, err := filepath.Rel(dir, path); err == nil && r != ".." && !strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(r)
+	}
+	return path
+}
+
+// abs is rel's inverse.
+func abs(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, filepath.FromSlash(path))
+}
@@ -0,0 +1,73 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	pkgDir := filepath.Join(dir, "workspace", "node_modules", "left-pad")
+	os.MkdirAll(pkgDir, 0755)
+	os.WriteFile(filepath.Join(pkgDir, "package.json"), []byte(`{"name": "left-pad", "version": "1.3.0"}`), 0644)
+	mod := filepath.Join(pkgDir, "index.js")
+	os.WriteFile(mod, []byte("module.exports = 1;"), 0644)
+
+	r := NewRecorder()
+	r.Download("https://registry.example.com/left-pad.js?v=1", mod, "")
+	r.Require(mod, sum([]byte("module.exports = 1;")))
+	r.Fetch("https://api.example.com/v2/items")
+
+	code := []byte("require('left-pad')")
+	l := r.Lock(code, dir)
+	if !l.For(code) || l.For([]byte("other")) {
+		t.Error("lock should apply to its memory.js only")
+	}
+	if len(l.Modules) != 1 {
+		t.Fatalf("Modules = %+v", l.Modules)
+	}
+	m := l.Modules[0]
+	if m.Path != "workspace/node_modules/left-pad/index.js" || m.Version != "1.3.0" || m.URL != "https://registry.example.com/left-pad.js?v=1" {
+		t.Errorf("module = %+v", m)
+	}
+	want := []string{"https://api.example.com", "https://registry.example.com"}
+	if len(l.APIs) != 2 || l.APIs[0] != want[0] || l.APIs[1] != want[1] {
+		t.Errorf("APIs = %v, want %v", l.APIs, want)
+	}
+
+	path := Path(dir)
+	if err := l.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift := loaded.Verify(dir); drift != nil {
+		t.Errorf("unexpected drift: %v", drift)
+	}
+
+	os.WriteFile(mod, []byte("module.exports = 2;"), 0644)
+	if drift := loaded.Verify(dir); len(drift) != 1 {
+		t.Errorf("drift = %v, want the changed module", drift)
+	}
+	os.Remove(mod)
+	if drift := loaded.Verify(dir); len(drift) != 1 {
+		t.Errorf("drift = %v, want the missing module", drift)
+	}
+
+	r2 := NewRecorder()
+	r2.Fetch("https://api.example.com/v3/items")
+	r2.Fetch("https://new.example.com/")
+	if got := loaded.Unlocked(r2); len(got) != 1 || got[0] != "https://new.example.com" {
+		t.Errorf("Unlocked = %v", got)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	l, err := Load(filepath.Join(t.TempDir(), Name))
+	if l != nil || err != nil {
+		t.Errorf("Load = %v, %v; want nil, nil", l, err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package managed fetches an organization's policy from managed_policy_url,
+// verifies its signature, and caches it so runs work offline. The approver
+// merges it in as protected entries that thought policies can't override.
+//
+// A policy's "serial" (an integer the organization raises with each signed
+// release) keeps an older signed policy from being served again in place
+// of a newer one: a fetched policy whose serial is lower than the cached
+// copy's is rejected.
+package managed
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+const (
+	DefaultRefresh = time.Hour
+	fetchTimeout   = 10 * time.Second
+	maxPolicySize  = 1 << 20
+)
+
+// Settings configure where the managed policy comes from.
+type Settings struct {
+	URL      string
+	Key      ed25519.PublicKey
+	Refresh  time.Duration
+	CacheDir string
+}
+
+// FromConfig reads the managed policy settings from config.json. It
+// returns nil settings when no managed_policy_url is set.
+func FromConfig(cfg *config.Config) (*Settings, error) {
+	if cfg.ManagedPolicyURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.ManagedPolicyURL)
+	if err != nil || u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("managed_policy_url must be an http(s) URL")
+	}
+	if cfg.ManagedPolicyKey == "" {
+		return nil, errors.New("managed_policy_url needs managed_policy_key to verify it")
+	}
+	key, err := base64.StdEncoding.DecodeString(cfg.ManagedPolicyKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("managed_policy_key must be a base64 ed25519 public key")
+	}
+	refresh := DefaultRefresh
+	if cfg.ManagedPolicyRefresh != "" {
+		refresh, err = time.ParseDuration(cfg.ManagedPolicyRefresh)
+		if err != nil || refresh <= 0 {
+			return nil, fmt.Errorf("invalid managed_policy_refresh %q", cfg.ManagedPolicyRefresh)
+		}
+	}
+	return &Settings{
+		URL:      cfg.ManagedPolicyURL,
+		Key:      ed25519.PublicKey(key),
+		Refresh:  refresh,
+		CacheDir: filepath.Join(config.HomeDir(), "managed"),
+	}, nil
+}
+
+// SignatureURL is where the detached signature for policyURL lives: the
+// same URL with ".sig" appended to its path.
+func SignatureURL(policyURL string) string {
+	u, err := url.Parse(policyURL)
+	if err != nil {
+		return policyURL + ".sig"
+	}
+	u.Path += ".sig"
+	return u.String()
+}
+
+// Load returns the managed policy. A cached copy younger than the refresh
+// interval is used as is; otherwise the policy is fetched again, falling
+// back to the stale cache (with stale set) when the fetch fails. Every
+// copy, fetched or cached, must carry a valid signature.
+func Load(ctx context.Context, s *Settings) (policy *approval.Policy, stale bool, err error) {
+	cached, cachedAt, cacheErr := s.cached()
+	if cacheErr == nil && time.Since(cachedAt) < s.Refresh {
+		return cached, false, nil
+	}
+
+	policy, fetchErr := s.fetch(ctx)
+	if fetchErr == nil {
+		return policy, false, nil
+	}
+	if cacheErr == nil {
+		return cached, true, fetchErr
+	}
+	return nil, false, fetchErr
+}
+
+// Cached returns the cached managed policy without fetching, or an error
+// if there is none or its signature doesn't verify.
+func Cached(s *Settings) (*approval.Policy, time.Time, error) {
+	return s.cached()
+}
+
+func (s *Settings) policyPath() string { return filepath.Join(s.CacheDir, "policy.json") }
+func (s *Settings) sigPath() string    { return filepath.Join(s.CacheDir, "policy.json.sig") }
+
+func (s *Settings) cached() (*approval.Policy, time.Time, error) {
+	info, err := os.Stat(s.policyPath())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	data, err := os.ReadFile(s.policyPath())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	sig, err := os.ReadFile(s.sigPath())
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	policy, err := s.verify(data, sig)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("cached managed policy: %w", err)
+	}
+	return policy, info.ModTime(), nil
+}
+
+func (s *Settings) fetch(ctx context.Context) (*approval.Policy, error) {
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	data, err := get(ctx, s.URL)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := get(ctx, SignatureURL(s.URL))
+	if err != nil {
+		return nil, err
+	}
+	policy, err := s.verify(data, sig)
+	if err != nil {
+		return nil, err
+	}
+	if prev, err := s.cachedSerial(); err == nil && serial(data) < prev {
+		return nil, fmt.Errorf("managed policy from %s has serial %d, older than the cached copy's %d", s.URL, serial(data), prev)
+	}
+
+	// Cache only what verified; the signature is written last so a torn
+	// write leaves a cache that fails verification rather than a bad one
+	if err := os.MkdirAll(s.CacheDir, 0700); err != nil {
+		return policy, nil
+	}
+	if os.WriteFile(s.policyPath(), data, 0600) == nil {
+		os.WriteFile(s.sigPath(), sig, 0600)
+	}
+	return policy, nil
+}
+
+// cachedSerial returns the serial of the cached policy, if it verifies.
+func (s *Settings) cachedSerial() (int, error) {
+	data, err := os.ReadFile(s.policyPath())
+	if err != nil {
+		return 0, err
+	}
+	sig, err := os.ReadFile(s.sigPath())
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.verify(data, sig); err != nil {
+		return 0, err
+	}
+	return serial(data), nil
+}
+
+// serial returns a policy file's "serial", 0 when it has none.
+func serial(data []byte) int {
+	var v struct {
+		Serial int `json:"serial"`
+	}
+	json.Unmarshal(data, &v)
+	return v.Serial
+}
+
+// verify checks a detached ed25519 signature (base64) over the policy
+// bytes and parses them.
+func (s *Settings) verify(data, sig []byte) (*approval.Policy, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(s.Key, data, raw) {
+		return nil, errors.New("managed policy signature does not verify")
+	}
+	policy, err := approval.ParsePolicy(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing managed policy: %w", err)
+	}
+	return policy, nil
+}
+
+func get(ctx context.Context, rawURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPolicySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", rawURL, err)
+	}
+	if len(data) > maxPolicySize {
+		return nil, fmt.Errorf("fetching %s: larger than %d bytes", rawURL, maxPolicySize)
+	}
+	return data, nil
+}
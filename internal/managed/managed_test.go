@@ -0,0 +1,184 @@
+package managed
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+const policyJSON = `{"version":1,"paths":{"default":"prompt","entries":[{"path":"/secrets","mode":"rwd","approval":"deny"}]}}`
+
+type server struct {
+	*httptest.Server
+	hits   atomic.Int32
+	policy []byte
+	sig    []byte
+	down   atomic.Bool
+}
+
+func newServer(t *testing.T, priv ed25519.PrivateKey) *server {
+	t.Helper()
+	s := &server{policy: []byte(policyJSON)}
+	s.sig = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, s.policy)))
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/org/policy.json":
+			s.hits.Add(1)
+			w.Write(s.policy)
+		case "/org/policy.json.sig":
+			w.Write(s.sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func settings(t *testing.T, srv *server, pub ed25519.PublicKey) *Settings {
+	t.Helper()
+	return &Settings{URL: srv.URL + "/org/policy.json", Key: pub, Refresh: time.Hour, CacheDir: t.TempDir()}
+}
+
+func TestLoadFetchesAndCaches(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	srv := newServer(t, priv)
+	s := settings(t, srv, pub)
+	ctx := context.Background()
+
+	policy, stale, err := Load(ctx, s)
+	if err != nil || stale {
+		t.Fatalf("Load = %v, stale=%v", err, stale)
+	}
+	if len(policy.Paths.Entries) != 1 || policy.Paths.Entries[0].Path != "/secrets" {
+		t.Errorf("policy entries = %+v", policy.Paths.Entries)
+	}
+
+	// Within the refresh interval the cache is used
+	if _, _, err := Load(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.hits.Load(); n != 1 {
+		t.Errorf("fetched %d times, want 1", n)
+	}
+
+	// Expired and unreachable: the stale cache is used, with the error
+	past := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(s.policyPath(), past, past)
+	srv.down.Store(true)
+	policy, stale, err = Load(ctx, s)
+	if policy == nil || !stale || err == nil {
+		t.Errorf("Load offline = %v, stale=%v, err=%v; want cached policy, stale, error", policy, stale, err)
+	}
+}
+
+func TestLoadRejectsBadSignature(t *testing.T) {
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	srv := newServer(t, other)
+	s := settings(t, srv, pub)
+
+	if _, _, err := Load(context.Background(), s); err == nil {
+		t.Fatal("Load accepted a policy signed with another key")
+	}
+	if _, err := os.Stat(s.policyPath()); !os.IsNotExist(err) {
+		t.Error("unverified policy was cached")
+	}
+}
+
+func TestLoadRejectsOlderSerial(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	srv := newServer(t, priv)
+	serve := func(policy string) {
+		srv.policy = []byte(policy)
+		srv.sig = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, srv.policy)))
+	}
+	s := settings(t, srv, pub)
+	s.Refresh = time.Nanosecond // fetch every time
+	ctx := context.Background()
+
+	serve(`{"version":1,"serial":2,"paths":{"entries":[{"path":"/v2","mode":"r","approval":"deny"}]}}`)
+	if _, _, err := Load(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+
+	// A replayed older release is refused; the cached one stays in force
+	serve(`{"version":1,"serial":1,"paths":{"entries":[]}}`)
+	policy, stale, err := Load(ctx, s)
+	if err == nil || !strings.Contains(err.Error(), "older than the cached") || !stale {
+		t.Errorf("Load of serial 1 = stale %v, err %v; want the serial error", stale, err)
+	}
+	if policy == nil || len(policy.Paths.Entries) != 1 || policy.Paths.Entries[0].Path != "/v2" {
+		t.Errorf("policy = %+v, want the cached serial 2", policy)
+	}
+
+	// The same serial and newer ones are accepted
+	for _, n := range []string{"2", "3"} {
+		serve(`{"version":1,"serial":` + n + `,"paths":{"entries":[]}}`)
+		if _, stale, err := Load(ctx, s); err != nil || stale {
+			t.Errorf("Load of serial %s = stale %v, err %v", n, stale, err)
+		}
+	}
+}
+
+func TestCachedRejectsTampering(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	srv := newServer(t, priv)
+	s := settings(t, srv, pub)
+	if _, _, err := Load(context.Background(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(s.policyPath(), []byte(`{"version":1}`), 0600)
+	if _, _, err := Cached(s); err == nil {
+		t.Error("Cached accepted a modified policy")
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	t.Setenv("THINKINGSCRIPT_HOME", t.TempDir())
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	if s, err := FromConfig(&config.Config{}); s != nil || err != nil {
+		t.Errorf("FromConfig without URL = %v, %v; want nil, nil", s, err)
+	}
+	s, err := FromConfig(&config.Config{ManagedPolicyURL: "https://example.com/p.json", ManagedPolicyKey: key, ManagedPolicyRefresh: "15m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Refresh != 15*time.Minute {
+		t.Errorf("Refresh = %v, want 15m", s.Refresh)
+	}
+
+	for _, cfg := range []config.Config{
+		{ManagedPolicyURL: "https://example.com/p.json"},
+		{ManagedPolicyURL: "https://example.com/p.json", ManagedPolicyKey: "not-a-key"},
+		{ManagedPolicyURL: "file:///etc/p.json", ManagedPolicyKey: key},
+		{ManagedPolicyURL: "https://example.com/p.json", ManagedPolicyKey: key, ManagedPolicyRefresh: "-1h"},
+	} {
+		if _, err := FromConfig(&cfg); err == nil {
+			t.Errorf("FromConfig(%+v) succeeded, want error", cfg)
+		}
+	}
+}
+
+func TestSignatureURL(t *testing.T) {
+	if got := SignatureURL("https://example.com/org/policy.json?v=2"); got != "https://example.com/org/policy.json.sig?v=2" {
+		t.Errorf("SignatureURL = %q", got)
+	}
+}
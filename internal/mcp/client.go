@@ -0,0 +1,289 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limits on a server's process.
+const (
+	initTimeout  = 30 * time.Second // to start and answer initialize
+	closeTimeout = 2 * time.Second  // to exit after its stdin closes
+	stderrTail   = 4 << 10          // of its stderr, kept for error messages
+	maxLine      = 16 << 20         // longest message read from it
+)
+
+// Client is a session with one server process.
+type Client struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tail
+	exited chan struct{} // closed when the process has exited
+
+	Server       Implementation // from the handshake
+	Instructions string         // from the handshake
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	nextID  int64
+	pending map[string]chan message
+	err     error // set when the connection is gone
+}
+
+// Start runs command with args in dir, with env as its environment, and
+// completes the initialize handshake.
+func Start(ctx context.Context, command string, args, env []string, dir string) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = env
+	cmd.Dir = dir
+	cmd.WaitDelay = closeTimeout // for children still holding its stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		cmd:     cmd,
+		stdin:   stdin,
+		stderr:  &tail{max: stderrTail},
+		exited:  make(chan struct{}),
+		enc:     json.NewEncoder(stdin),
+		pending: make(map[string]chan message),
+	}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go c.read(stdout)
+	go func() {
+		cmd.Wait()
+		close(c.exited)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, initTimeout)
+	defer cancel()
+	var init InitializeResult
+	err = c.Call(ctx, MethodInitialize, InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: "think", Version: "1.0"},
+	}, &init)
+	if err == nil {
+		err = c.notify(MethodInitialized, struct{}{})
+	}
+	if err != nil {
+		c.Close()
+		return nil, c.describe(fmt.Errorf("initializing: %w", err))
+	}
+	c.Server = init.ServerInfo
+	c.Instructions = init.Instructions
+	return c, nil
+}
+
+// ListTools returns every tool the server offers.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var page ListToolsResult
+		if err := c.Call(ctx, MethodToolsList, ListToolsParams{Cursor: cursor}, &page); err != nil {
+			return nil, c.describe(fmt.Errorf("listing tools: %w", err))
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool calls a tool with arguments, a JSON object.
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.Call(ctx, MethodToolsCall, CallToolParams{Name: name, Arguments: arguments}, &result); err != nil {
+		return nil, c.describe(err)
+	}
+	return &result, nil
+}
+
+// Call sends a request and decodes its result into result (if non-nil).
+// A JSON-RPC error comes back as *Error. When ctx ends first, the server
+// is told the request was cancelled.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := fmt.Sprint(c.nextID)
+	ch := make(chan message, 1)
+	c.pending[id] = ch
+	err = c.enc.Encode(message{JSONRPC: "2.0", ID: json.RawMessage(id), Method: method, Params: raw})
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var reply message
+	var ok bool
+	select {
+	case reply, ok = <-ch:
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		c.notify(MethodCancelled, map[string]any{"requestId": json.RawMessage(id), "reason": ctx.Err().Error()})
+		return ctx.Err()
+	}
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	}
+	if reply.Error != nil {
+		return reply.Error
+	}
+	if result != nil && len(reply.Result) > 0 {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+func (c *Client) notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.enc.Encode(message{JSONRPC: "2.0", Method: method, Params: raw})
+}
+
+// Close ends the session: the server's stdin is closed, and the process is
+// killed if it doesn't exit soon after.
+func (c *Client) Close() error {
+	c.stdin.Close()
+	select {
+	case <-c.exited:
+	case <-time.After(closeTimeout):
+		c.cmd.Process.Kill()
+		<-c.exited
+	}
+	return nil
+}
+
+func (c *Client) read(stdout io.Reader) {
+	r := bufio.NewScanner(stdout)
+	r.Buffer(make([]byte, 0, 64<<10), maxLine)
+	for r.Scan() {
+		var m message
+		if json.Unmarshal(r.Bytes(), &m) == nil {
+			c.dispatch(m)
+		}
+	}
+	c.mu.Lock()
+	c.err = errors.New("server exited")
+	if err := r.Err(); err != nil {
+		c.err = fmt.Errorf("reading from server: %w", err)
+	}
+	for id, ch := range c.pending {
+		delete(c.pending, id)
+		close(ch)
+	}
+	c.mu.Unlock()
+}
+
+// dispatch routes a reply to its caller. The server's own requests get
+// an empty result for ping and method-not-found for anything else, since
+// think offers no client features; its notifications are ignored.
+func (c *Client) dispatch(m message) {
+	if m.Method != "" {
+		if len(m.ID) == 0 {
+			return
+		}
+		reply := message{JSONRPC: "2.0", ID: m.ID}
+		if m.Method == MethodPing {
+			reply.Result = json.RawMessage("{}")
+		} else {
+			reply.Error = &Error{Code: CodeMethodNotFound, Message: "method not found: " + m.Method}
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.err == nil {
+			c.enc.Encode(reply)
+		}
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ch, ok := c.pending[string(m.ID)]; ok {
+		delete(c.pending, string(m.ID))
+		ch <- m
+	}
+}
+
+// describe adds the end of the server's stderr to err once the server is
+// gone, since that usually says why it failed.
+func (c *Client) describe(err error) error {
+	c.mu.Lock()
+	gone := c.err != nil
+	c.mu.Unlock()
+	if gone {
+		// Its stdout closes first; wait for the rest of its stderr
+		select {
+		case <-c.exited:
+		case <-time.After(closeTimeout):
+		}
+	}
+	select {
+	case <-c.exited:
+		gone = true
+	default:
+	}
+	if s := strings.TrimSpace(c.stderr.String()); gone && s != "" {
+		return fmt.Errorf("%w\nserver stderr:\n%s", err, s)
+	}
+	return err
+}
+
+// tail keeps the last max bytes written to it.
+type tail struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
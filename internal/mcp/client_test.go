@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain doubles as an MCP server: with MCP_TEST_SERVER=1 it offers
+// "echo", "fail", and "crash" over stdio, two tools per tools/list page,
+// and exits when "crash" is called. With MCP_TEST_SERVER=broken it exits
+// at once. With MCP_TEST_SERVER=server it runs testServer.
+func TestMain(m *testing.M) {
+	switch os.Getenv("MCP_TEST_SERVER") {
+	case "1":
+		serve()
+		os.Exit(0)
+	case "server":
+		testServer.Serve(context.Background(), os.Stdin, os.Stdout)
+		os.Exit(0)
+	case "broken":
+		fmt.Fprintln(os.Stderr, "missing API token")
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+func serve() {
+	out := json.NewEncoder(os.Stdout)
+	reply := func(id json.RawMessage, result any) {
+		raw, _ := json.Marshal(result)
+		out.Encode(message{JSONRPC: "2.0", ID: id, Result: raw})
+	}
+	tools := []Tool{
+		{Name: "echo", Description: "Echo text", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}},"required":["text"]}`)},
+		{Name: "fail", InputSchema: json.RawMessage(`{"type":"object"}`)},
+		{Name: "crash", InputSchema: json.RawMessage(`{"type":"object"}`), Annotations: &ToolAnnotations{ReadOnlyHint: true}},
+	}
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		var m message
+		if json.Unmarshal(in.Bytes(), &m) != nil || len(m.ID) == 0 {
+			continue
+		}
+		switch m.Method {
+		case MethodInitialize:
+			// Ask the client something first; it must answer
+			out.Encode(message{JSONRPC: "2.0", ID: json.RawMessage(`"s1"`), Method: "roots/list"})
+			reply(m.ID, InitializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "test", Version: "0.1"}, Instructions: "be nice"})
+		case MethodToolsList:
+			var p ListToolsParams
+			json.Unmarshal(m.Params, &p)
+			if p.Cursor == "" {
+				reply(m.ID, ListToolsResult{Tools: tools[:2], NextCursor: "2"})
+			} else {
+				reply(m.ID, ListToolsResult{Tools: tools[2:]})
+			}
+		case MethodToolsCall:
+			var p struct {
+				Name      string            `json:"name"`
+				Arguments map[string]string `json:"arguments"`
+			}
+			json.Unmarshal(m.Params, &p)
+			switch p.Name {
+			case "echo":
+				reply(m.ID, CallToolResult{Content: []Content{{Type: "text", Text: p.Arguments["text"]}, {Type: "image", MimeType: "image/png", Data: "AAAA"}}})
+			case "fail":
+				reply(m.ID, CallToolResult{Content: []Content{{Type: "text", Text: "no such issue"}}, IsError: true})
+			case "crash":
+				fmt.Fprintln(os.Stderr, "panic: boom")
+				os.Exit(2)
+			default:
+				out.Encode(message{JSONRPC: "2.0", ID: m.ID, Error: &Error{Code: CodeInvalidParams, Message: "unknown tool " + p.Name}})
+			}
+		default:
+			if m.Method == "" {
+				continue // the client's answer to roots/list
+			}
+			out.Encode(message{JSONRPC: "2.0", ID: m.ID, Error: &Error{Code: CodeMethodNotFound, Message: m.Method}})
+		}
+	}
+}
+
+func start(t *testing.T, mode string) (*Client, error) {
+	t.Helper()
+	return Start(context.Background(), os.Args[0], nil, append(os.Environ(), "MCP_TEST_SERVER="+mode), t.TempDir())
+}
+
+func TestClient(t *testing.T) {
+	c, err := start(t, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Server.Name != "test" || c.Instructions != "be nice" {
+		t.Errorf("handshake: server %+v, instructions %q", c.Server, c.Instructions)
+	}
+
+	ctx := context.Background()
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "echo,fail,crash" {
+		t.Errorf("tools = %v, want both pages", names)
+	}
+
+	res, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Text(); got != "hi\n[image image/png content not shown]" {
+		t.Errorf("echo = %q", got)
+	}
+
+	res, err = c.CallTool(ctx, "fail", nil)
+	if err != nil || !res.IsError || res.Text() != "no such issue" {
+		t.Errorf("fail = %+v, %v; want an error result", res, err)
+	}
+
+	var rpcErr *Error
+	if _, err := c.CallTool(ctx, "nope", nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("unknown tool error = %v", err)
+	}
+
+	// A server that dies fails the call and says why
+	_, err = c.CallTool(ctx, "crash", nil)
+	if err == nil || !strings.Contains(err.Error(), "panic: boom") {
+		t.Errorf("crash error = %v, want the server's stderr", err)
+	}
+	if _, err := c.CallTool(ctx, "echo", json.RawMessage(`{"text":"hi"}`)); err == nil {
+		t.Error("call after the server exited succeeded")
+	}
+}
+
+func TestStartBroken(t *testing.T) {
+	_, err := start(t, "broken")
+	if err == nil || !strings.Contains(err.Error(), "missing API token") {
+		t.Errorf("Start = %v, want the server's stderr", err)
+	}
+}
+
+func TestCallCancelled(t *testing.T) {
+	c, err := Start(context.Background(), "sh", []string{"-c", `read line; echo '{"jsonrpc":"2.0","id":1,"result":{}}'; cat >/dev/null`}, nil, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.ListTools(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListTools = %v, want deadline exceeded", err)
+	}
+}
@@ -0,0 +1,147 @@
+// Package mcp speaks the Model Context Protocol: JSON-RPC 2.0, one message
+// per line, over a server's stdin and stdout. Thoughts declare servers in
+// frontmatter (`mcp:`); think starts each one, lists its tools, and offers
+// them to the agent next to run_script. `think serve --mcp` is the other
+// side: a Server offering installed thoughts as tools. Only tools are
+// supported, not resources, prompts, or sampling.
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ProtocolVersion is the MCP revision think asks for. Servers may answer
+// with an older one; the tool calls used here are the same in all of them.
+const ProtocolVersion = "2025-06-18"
+
+// Methods.
+const (
+	MethodInitialize  = "initialize"
+	MethodInitialized = "notifications/initialized"
+	MethodCancelled   = "notifications/cancelled"
+	MethodPing        = "ping"
+	MethodToolsList   = "tools/list"
+	MethodToolsCall   = "tools/call"
+)
+
+// JSON-RPC error codes.
+const (
+	CodeParse          = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternal       = -32603
+)
+
+// Error is a JSON-RPC error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// message is a JSON-RPC request, response, or notification.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Implementation names a client or server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// InitializeParams open a session.
+type InitializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      Implementation `json:"clientInfo"`
+}
+
+// InitializeResult is the server's side of the handshake.
+type InitializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      Implementation `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+// Tool is a tool a server offers. InputSchema is a JSON Schema object.
+type Tool struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title,omitempty"`
+	Description string           `json:"description,omitempty"`
+	InputSchema json.RawMessage  `json:"inputSchema"`
+	Annotations *ToolAnnotations `json:"annotations,omitempty"`
+}
+
+// ToolAnnotations are the server's hints about a tool. They aren't
+// trusted for approval, only for how repeated calls are treated.
+type ToolAnnotations struct {
+	ReadOnlyHint   bool `json:"readOnlyHint,omitempty"`
+	IdempotentHint bool `json:"idempotentHint,omitempty"`
+}
+
+// ListToolsParams page through tools/list.
+type ListToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// ListToolsResult is one page of tools.
+type ListToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// CallToolParams call a tool.
+type CallToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// CallToolResult is a tool's output. IsError marks a failure the model
+// should see, as opposed to a protocol error.
+type CallToolResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Content is one piece of a tool result. Only text is passed to the model;
+// other kinds are described.
+type Content struct {
+	Type     string `json:"type"` // text, image, audio, resource, resource_link
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Data     string `json:"data,omitempty"` // base64, for image and audio
+	URI      string `json:"uri,omitempty"`  // resource_link
+}
+
+// Text joins a result's content into the text the model gets.
+func (r *CallToolResult) Text() string {
+	var parts []string
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "resource_link":
+			parts = append(parts, "[resource "+c.URI+"]")
+		default:
+			desc := "[" + c.Type
+			if c.MimeType != "" {
+				desc += " " + c.MimeType
+			}
+			parts = append(parts, desc+" content not shown]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
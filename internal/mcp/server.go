@@ -0,0 +1,173 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+)
+
+// knownVersions are the revisions a client may ask for and get back as
+// is; any other gets ProtocolVersion.
+var knownVersions = []string{"2024-11-05", "2025-03-26", ProtocolVersion}
+
+// Server offers tools to an MCP client over one connection, the other
+// side of Client. Calls run concurrently, each cancelled when the client
+// sends notifications/cancelled for it or the connection ends.
+type Server struct {
+	Info         Implementation
+	Instructions string
+	Tools        []Tool
+
+	// Call runs a tool the client asked for by name. An error is reported
+	// to the client as a failed call (isError), not a protocol error.
+	Call func(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error)
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	running map[string]context.CancelFunc
+}
+
+// Serve reads requests from r and answers on w until r ends or ctx is
+// done, then cancels the calls still running and waits for them.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var calls sync.WaitGroup
+	defer func() {
+		cancel()
+		calls.Wait()
+	}()
+	s.enc = json.NewEncoder(w)
+	s.running = make(map[string]context.CancelFunc)
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		in := bufio.NewScanner(r)
+		in.Buffer(make([]byte, 0, 64<<10), maxLine)
+		for in.Scan() {
+			select {
+			case lines <- slices.Clone(in.Bytes()):
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- in.Err()
+	}()
+
+	for {
+		var line []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case line = <-lines:
+		}
+
+		var m message
+		if err := json.Unmarshal(line, &m); err != nil {
+			s.reply(json.RawMessage("null"), nil, &Error{Code: CodeParse, Message: err.Error()})
+			continue
+		}
+		switch {
+		case m.Method == "":
+			// A reply; this server never asks the client anything
+		case len(m.ID) == 0:
+			s.notification(m)
+		case m.Method == MethodToolsCall:
+			var p CallToolParams
+			if err := json.Unmarshal(m.Params, &p); err != nil {
+				s.reply(m.ID, nil, &Error{Code: CodeInvalidParams, Message: err.Error()})
+				continue
+			}
+			if !slices.ContainsFunc(s.Tools, func(t Tool) bool { return t.Name == p.Name }) {
+				s.reply(m.ID, nil, &Error{Code: CodeInvalidParams, Message: "unknown tool: " + p.Name})
+				continue
+			}
+			callCtx, cancelCall := context.WithCancel(ctx)
+			s.mu.Lock()
+			s.running[string(m.ID)] = cancelCall
+			s.mu.Unlock()
+			calls.Add(1)
+			go func(id json.RawMessage) {
+				defer calls.Done()
+				defer cancelCall()
+				result, err := s.Call(callCtx, p.Name, p.Arguments)
+				if err != nil {
+					result = &CallToolResult{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}
+				}
+				s.mu.Lock()
+				delete(s.running, string(id))
+				s.mu.Unlock()
+				if callCtx.Err() == nil {
+					s.reply(id, result, nil)
+				}
+			}(m.ID)
+		default:
+			result, rpcErr := s.handle(m)
+			s.reply(m.ID, result, rpcErr)
+		}
+	}
+}
+
+// handle answers every request but tools/call.
+func (s *Server) handle(m message) (any, *Error) {
+	switch m.Method {
+	case MethodInitialize:
+		var p InitializeParams
+		json.Unmarshal(m.Params, &p)
+		version := ProtocolVersion
+		if slices.Contains(knownVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      s.Info,
+			Instructions:    s.Instructions,
+		}, nil
+	case MethodPing:
+		return struct{}{}, nil
+	case MethodToolsList:
+		return ListToolsResult{Tools: s.Tools}, nil
+	}
+	return nil, &Error{Code: CodeMethodNotFound, Message: "method not found: " + m.Method}
+}
+
+// notification handles what the client tells the server without asking:
+// only cancellation matters.
+func (s *Server) notification(m message) {
+	if m.Method != MethodCancelled {
+		return
+	}
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(m.Params, &p) != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.running[string(p.RequestID)]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (s *Server) reply(id json.RawMessage, result any, rpcErr *Error) {
+	m := message{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			m.Error = &Error{Code: CodeInternal, Message: err.Error()}
+		} else {
+			m.Result = raw
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(m)
+}
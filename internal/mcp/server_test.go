@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testServer offers "greet", which fails without a name, and "wait",
+// which runs until it is cancelled.
+var testServer = &Server{
+	Info:         Implementation{Name: "think-test", Version: "1"},
+	Instructions: "call greet",
+	Tools: []Tool{
+		{Name: "greet", InputSchema: json.RawMessage(`{"type":"object","properties":{"name":{"type":"string"}}}`)},
+		{Name: "wait", InputSchema: json.RawMessage(`{"type":"object"}`)},
+	},
+	Call: func(ctx context.Context, name string, arguments json.RawMessage) (*CallToolResult, error) {
+		if name == "wait" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		var args struct{ Name string }
+		json.Unmarshal(arguments, &args)
+		if args.Name == "" {
+			return nil, errors.New("name is required")
+		}
+		return &CallToolResult{Content: []Content{{Type: "text", Text: "hello " + args.Name}}}, nil
+	},
+}
+
+func TestServer(t *testing.T) {
+	c, err := start(t, "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Server.Name != "think-test" || c.Instructions != "call greet" {
+		t.Errorf("handshake: server %+v, instructions %q", c.Server, c.Instructions)
+	}
+
+	ctx := context.Background()
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 2 || tools[0].Name != "greet" {
+		t.Fatalf("ListTools = %+v, %v", tools, err)
+	}
+
+	res, err := c.CallTool(ctx, "greet", json.RawMessage(`{"name":"ada"}`))
+	if err != nil || res.IsError || res.Text() != "hello ada" {
+		t.Errorf("greet = %+v, %v", res, err)
+	}
+
+	// A failing call is a result the model sees, not a protocol error
+	res, err = c.CallTool(ctx, "greet", nil)
+	if err != nil || !res.IsError || res.Text() != "name is required" {
+		t.Errorf("greet without a name = %+v, %v", res, err)
+	}
+
+	var rpcErr *Error
+	if _, err := c.CallTool(ctx, "nope", nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("unknown tool error = %v", err)
+	}
+	if err := c.Call(ctx, "resources/list", struct{}{}, nil); !errors.As(err, &rpcErr) || rpcErr.Code != CodeMethodNotFound {
+		t.Errorf("resources/list error = %v", err)
+	}
+
+	// Cancelling a call stops it on the server, which keeps serving
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.CallTool(waitCtx, "wait", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait = %v, want deadline exceeded", err)
+	}
+	if err := c.Call(ctx, MethodPing, struct{}{}, nil); err != nil {
+		t.Errorf("ping after cancel = %v", err)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	for asked, want := range map[string]string{"2024-11-05": "2024-11-05", "1999-01-01": ProtocolVersion} {
+		result, _ := testServer.handle(message{Method: MethodInitialize, Params: json.RawMessage(`{"protocolVersion":"` + asked + `"}`)})
+		if got := result.(InitializeResult).ProtocolVersion; got != want {
+			t.Errorf("asked for %s, got %s, want %s", asked, got, want)
+		}
+	}
+}
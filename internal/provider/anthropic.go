@@ -1,9 +1,14 @@
 package provider
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -11,6 +16,7 @@ import (
 
 type AnthropicProvider struct {
 	client *anthropic.Client
+	stream bool // ChatStream streams; Bedrock's invoke endpoint can't
 }
 
 func NewAnthropicProvider(apiKey string) *AnthropicProvider {
@@ -18,11 +24,75 @@ func NewAnthropicProvider(apiKey string) *AnthropicProvider {
 	if apiKey != "" {
 		opts = append(opts, option.WithAPIKey(apiKey))
 	}
-	client := anthropic.NewClient(opts...)
+	p := newAnthropicProvider(opts...)
+	p.stream = true
+	return p
+}
+
+// newAnthropicProvider builds a provider on the Anthropic Messages API with
+// the given client options. Bedrock and Vertex reuse it with a base URL and a
+// middleware that rewrites and authenticates each request.
+// The SDK's own retries are off; RetryProvider retries for every provider
+// the same way.
+func newAnthropicProvider(opts ...option.RequestOption) *AnthropicProvider {
+	client := anthropic.NewClient(append(opts, option.WithMaxRetries(0))...)
 	return &AnthropicProvider{client: &client}
 }
 
 func (p *AnthropicProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	started := time.Now()
+	var httpResp *http.Response
+	resp, err := p.client.Messages.New(ctx, anthropicParams(params), option.WithResponseInto(&httpResp))
+	if err != nil {
+		return nil, fmt.Errorf("anthropic API error: %w", err)
+	}
+	return fromAnthropic(resp, httpResp, started), nil
+}
+
+// ChatStream streams text and tool input deltas as they arrive. When the
+// endpoint can't stream, the response is fetched whole and replayed.
+func (p *AnthropicProvider) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	if !p.stream {
+		resp, err := p.Chat(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		replay(resp, onEvent)
+		return resp, nil
+	}
+
+	started := time.Now()
+	var httpResp *http.Response
+	stream := p.client.Messages.NewStreaming(ctx, anthropicParams(params), option.WithResponseInto(&httpResp))
+	defer stream.Close()
+	var msg anthropic.Message
+	for stream.Next() {
+		event := stream.Current()
+		if err := msg.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("anthropic API error: %w", err)
+		}
+		switch event.Type {
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				onEvent(StreamEvent{Type: "tool_use", ToolName: event.ContentBlock.Name})
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				onEvent(StreamEvent{Type: "text", Text: event.Delta.Text})
+			case "input_json_delta":
+				onEvent(StreamEvent{Type: "tool_input", Text: event.Delta.PartialJSON})
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("anthropic API error: %w", err)
+	}
+	return fromAnthropic(&msg, httpResp, started), nil
+}
+
+// anthropicParams maps a request onto the Messages API.
+func anthropicParams(params ChatParams) anthropic.MessageNewParams {
 	messages := make([]anthropic.MessageParam, 0, len(params.Messages))
 	for _, msg := range params.Messages {
 		blocks := make([]anthropic.ContentBlockParamUnion, 0, len(msg.Content))
@@ -60,29 +130,57 @@ func (p *AnthropicProvider) Chat(ctx context.Context, params ChatParams) (*ChatR
 		}
 		tools = append(tools, anthropic.ToolUnionParam{OfTool: &tool})
 	}
+	// The tools and system prompt (with memories) are the same on every
+	// iteration of a run: mark both as cache breakpoints so later calls
+	// read them from the prompt cache instead of processing them again.
+	// Prefixes under the model's minimum (1024 tokens for most) just
+	// aren't cached.
+	if len(tools) > 0 {
+		tools[len(tools)-1].OfTool.CacheControl = anthropic.NewCacheControlEphemeralParam()
+	}
 
 	maxTokens := int64(params.MaxTokens)
 	if maxTokens == 0 {
 		maxTokens = 4096
 	}
 
-	apiParams := anthropic.MessageNewParams{
-		Model:     anthropic.Model(params.Model),
-		MaxTokens: maxTokens,
-		Messages:  messages,
-		Tools:     tools,
+	req := anthropic.MessageNewParams{
+		Model:         anthropic.Model(params.Model),
+		MaxTokens:     maxTokens,
+		Messages:      messages,
+		Tools:         tools,
+		StopSequences: params.StopSequences,
 		System: []anthropic.TextBlockParam{
-			{Text: params.System},
+			{Text: params.System, CacheControl: anthropic.NewCacheControlEphemeralParam()},
 		},
 	}
-
-	resp, err := p.client.Messages.New(ctx, apiParams)
-	if err != nil {
-		return nil, fmt.Errorf("anthropic API error: %w", err)
+	if params.Temperature != nil {
+		req.Temperature = anthropic.Float(*params.Temperature)
 	}
+	if params.TopP != nil {
+		req.TopP = anthropic.Float(*params.TopP)
+	}
+	return req
+}
 
+// fromAnthropic converts a Messages API response, received over httpResp
+// (nil when the SDK didn't record it) for a request sent at started.
+func fromAnthropic(resp *anthropic.Message, httpResp *http.Response, started time.Time) *ChatResponse {
 	result := &ChatResponse{
-		StopReason: string(resp.StopReason),
+		StopReason:   string(resp.StopReason),
+		StopSequence: resp.StopSequence,
+		// input_tokens leaves out what was written to or read from the
+		// prompt cache; count those too so totals and budgets see the
+		// whole prompt.
+		Usage: Usage{
+			InputTokens:  int(resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens + resp.Usage.CacheReadInputTokens),
+			OutputTokens: int(resp.Usage.OutputTokens),
+			CachedTokens: int(resp.Usage.CacheReadInputTokens),
+		},
+		Latency: time.Since(started),
+	}
+	if httpResp != nil {
+		result.RequestID = httpResp.Header.Get("request-id")
 	}
 
 	for _, block := range resp.Content {
@@ -95,6 +193,47 @@ func (p *AnthropicProvider) Chat(ctx context.Context, params ChatParams) (*ChatR
 			result.Content = append(result.Content, NewToolUseBlock(tb.ID, tb.Name, json.RawMessage(tb.Input)))
 		}
 	}
+	return result
+}
+
+// rewriteBody decodes a Messages API request body, lets fn edit its
+// top-level fields, and installs the re-encoded body on r. Used by the
+// Bedrock and Vertex middlewares, which move "model" into the URL.
+func rewriteBody(r *http.Request, fn func(fields map[string]json.RawMessage) error) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("decoding request body: %w", err)
+	}
+	if err := fn(fields); err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
 
-	return result, nil
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	r.ContentLength = int64(len(data))
+	return data, nil
+}
+
+// takeModel removes and returns the "model" field from a request body.
+func takeModel(fields map[string]json.RawMessage) (string, error) {
+	var model string
+	if err := json.Unmarshal(fields["model"], &model); err != nil || model == "" {
+		return "", errors.New("request has no model")
+	}
+	delete(fields, "model")
+	return model, nil
 }
@@ -0,0 +1,257 @@
+package provider
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// bedrockVersion is the anthropic_version Bedrock expects in the body.
+const bedrockVersion = "bedrock-2023-05-31"
+
+// awsCredentials are the static credentials used to sign Bedrock requests.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// NewBedrockProvider returns a provider that calls Claude through AWS
+// Bedrock. Region falls back to AWS_REGION / AWS_DEFAULT_REGION. Requests are
+// authenticated with AWS_BEARER_TOKEN_BEDROCK if set, otherwise SigV4-signed
+// with credentials from the AWS_* env vars or the shared credentials file
+// (~/.aws/credentials, profile from AWS_PROFILE).
+func NewBedrockProvider(region string) (*AnthropicProvider, error) {
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return nil, errors.New("bedrock: no region (set \"region\" in the agent config or AWS_REGION)")
+	}
+
+	bearer := os.Getenv("AWS_BEARER_TOKEN_BEDROCK")
+	var creds *awsCredentials
+	if bearer == "" {
+		var err error
+		if creds, err = loadAWSCredentials(); err != nil {
+			return nil, fmt.Errorf("bedrock: %w", err)
+		}
+	}
+
+	middleware := func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		body, err := rewriteBody(r, func(fields map[string]json.RawMessage) error {
+			model, err := takeModel(fields)
+			if err != nil {
+				return err
+			}
+			delete(fields, "stream")
+			fields["anthropic_version"] = json.RawMessage(`"` + bedrockVersion + `"`)
+			if r.URL.Path == "/v1/messages" {
+				id := BedrockModelID(model)
+				r.URL.Path = "/model/" + id + "/invoke"
+				r.URL.RawPath = "/model/" + url.QueryEscape(id) + "/invoke"
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bedrock: %w", err)
+		}
+
+		// Never forward an Anthropic key to AWS.
+		r.Header.Del("X-Api-Key")
+		if bearer != "" {
+			r.Header.Set("Authorization", "Bearer "+bearer)
+		} else {
+			signV4(r, body, creds, region, "bedrock", time.Now())
+		}
+		return next(r)
+	}
+
+	return newAnthropicProvider(
+		option.WithBaseURL(fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region)),
+		option.WithMiddleware(middleware),
+	), nil
+}
+
+// BedrockModelID maps an Anthropic model name to its Bedrock model ID.
+// Names that already look like Bedrock IDs (e.g. "anthropic.claude-…",
+// "us.anthropic.claude-…", ARNs) pass through unchanged.
+func BedrockModelID(model string) string {
+	if strings.Contains(model, ".") || strings.HasPrefix(model, "arn:") {
+		return model
+	}
+	return "anthropic." + model + "-v1:0"
+}
+
+// loadAWSCredentials reads credentials from the environment, then from the
+// shared credentials file.
+func loadAWSCredentials() (*awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, errors.New("no AWS credentials found")
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("no AWS credentials found (set AWS_ACCESS_KEY_ID or configure ~/.aws/credentials)")
+	}
+	defer f.Close()
+
+	var creds awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(k) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(v)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(v)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(v)
+		}
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no credentials for profile %q in %s", profile, path)
+	}
+	return &creds, nil
+}
+
+// signV4 adds AWS Signature Version 4 headers to r.
+func signV4(r *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": r.URL.Host}
+	for _, name := range []string{"Content-Type", "X-Amz-Date", "X-Amz-Security-Token"} {
+		if v := r.Header.Get(name); v != "" {
+			headers[strings.ToLower(name)] = strings.TrimSpace(v)
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		awsURIEncode(r.URL.EscapedPath(), false),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything except unreserved characters
+// (and "/" unless encodeSlash is set), as SigV4 requires.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
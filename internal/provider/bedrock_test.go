@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The AWS SigV4 test suite's credentials, region, service, and time.
+var (
+	sigV4TestCreds = &awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	sigV4TestTime  = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+)
+
+func TestSignV4(t *testing.T) {
+	// From the AWS Signature Version 4 test suite
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		signed      string
+		signature   string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "", "", "host;x-amz-date",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", "host;x-amz-date",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "", "", "host;x-amz-date",
+			"5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", "content-type;host;x-amz-date",
+			"ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	for _, tt := range tests {
+		r, err := http.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.contentType != "" {
+			r.Header.Set("Content-Type", tt.contentType)
+		}
+		signV4(r, []byte(tt.body), sigV4TestCreds, "us-east-1", "service", sigV4TestTime)
+
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" + tt.signed + ", Signature=" + tt.signature
+		if got := r.Header.Get("Authorization"); got != want {
+			t.Errorf("%s: Authorization =\n%s\nwant\n%s", tt.name, got, want)
+		}
+		if got := r.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+			t.Errorf("%s: X-Amz-Date = %q", tt.name, got)
+		}
+	}
+}
+
+func TestSignV4SessionToken(t *testing.T) {
+	r, _ := http.NewRequest("POST", "https://bedrock-runtime.us-east-1.amazonaws.com/model/x/invoke", nil)
+	creds := *sigV4TestCreds
+	creds.SessionToken = "session-token"
+	signV4(r, nil, &creds, "us-east-1", "bedrock", sigV4TestTime)
+	if r.Header.Get("X-Amz-Security-Token") != "session-token" {
+		t.Errorf("X-Amz-Security-Token = %q", r.Header.Get("X-Amz-Security-Token"))
+	}
+	if auth := r.Header.Get("Authorization"); !strings.Contains(auth, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization = %q, want the token signed", auth)
+	}
+
+	// The same request without a token signs differently
+	r2, _ := http.NewRequest("POST", r.URL.String(), nil)
+	signV4(r2, nil, sigV4TestCreds, "us-east-1", "bedrock", sigV4TestTime)
+	if r2.Header.Get("Authorization") == r.Header.Get("Authorization") {
+		t.Error("the session token doesn't change the signature")
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", ""},
+		{"Param2=value2&Param1=value1", "Param1=value1&Param2=value2"},
+		{"b=2&a=3&a=1", "a=1&a=3&b=2"},
+		{"key=a%20b&k%2Fy=c%2Fd", "k%2Fy=c%2Fd&key=a%20b"},
+		{"empty=", "empty="},
+		{"tilde=~&star=*", "star=%2A&tilde=~"},
+	}
+	for _, tt := range tests {
+		q, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := canonicalQuery(q); got != tt.want {
+			t.Errorf("canonicalQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	tests := []struct {
+		in          string
+		encodeSlash bool
+		want        string
+	}{
+		{"/model/anthropic.claude-v1:0/invoke", false, "/model/anthropic.claude-v1%3A0/invoke"},
+		{"a/b", true, "a%2Fb"},
+		{"AZaz09-_.~", true, "AZaz09-_.~"},
+		{"a b+c", false, "a%20b%2Bc"},
+		{"ሴ", false, "%E1%88%B4"},
+		{"%3A", false, "%253A"},
+	}
+	for _, tt := range tests {
+		if got := awsURIEncode(tt.in, tt.encodeSlash); got != tt.want {
+			t.Errorf("awsURIEncode(%q, %v) = %q, want %q", tt.in, tt.encodeSlash, got, tt.want)
+		}
+	}
+}
+
+func TestBedrockModelID(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"claude-sonnet-4-5-20250929", "anthropic.claude-sonnet-4-5-20250929-v1:0"},
+		{"anthropic.claude-3-haiku-20240307-v1:0", "anthropic.claude-3-haiku-20240307-v1:0"},
+		{"us.anthropic.claude-sonnet-4-5-20250929-v1:0", "us.anthropic.claude-sonnet-4-5-20250929-v1:0"},
+		{"arn:aws:bedrock:us-east-1:123456789012:inference-profile/x", "arn:aws:bedrock:us-east-1:123456789012:inference-profile/x"},
+	}
+	for _, tt := range tests {
+		if got := BedrockModelID(tt.model); got != tt.want {
+			t.Errorf("BedrockModelID(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
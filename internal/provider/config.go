@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+// FromConfig creates the provider an agent config names, without retries
+// or fallbacks.
+func FromConfig(cfg *config.ResolvedConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "anthropic":
+		return NewAnthropicProvider(cfg.APIKey), nil
+	case "openai":
+		return NewOpenAIProvider(OpenAIConfig{
+			APIBase:  cfg.APIBase,
+			APIKey:   cfg.APIKey,
+			ChatPath: cfg.ChatPath,
+			Headers:  cfg.Headers,
+			Models:   cfg.Models,
+		}), nil
+	case "ollama":
+		return NewOllamaProvider(cfg.APIBase), nil
+	case "bedrock":
+		p, err := NewBedrockProvider(cfg.Region)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	case "vertex":
+		p, err := NewVertexProvider(cfg.Region, cfg.Project)
+		if err != nil {
+			return nil, err
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", cfg.Provider)
+	}
+}
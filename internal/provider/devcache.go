@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// DevCache replays recorded responses for requests it has seen before, so
+// iterating on a thought doesn't pay for the same early turns again. The
+// key is a hash of the whole request (model, system prompt, messages,
+// tools, max tokens): a run replays until its conversation diverges, then
+// every later turn goes to the provider and is recorded.
+type DevCache struct {
+	inner Provider
+	dir   string
+}
+
+// NewDevCache wraps p with a response cache stored in dir.
+func NewDevCache(p Provider, dir string) *DevCache {
+	return &DevCache{inner: p, dir: dir}
+}
+
+func (c *DevCache) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	return c.chat(ctx, params, func(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+		return c.inner.Chat(ctx, params)
+	}, nil)
+}
+
+// ChatStream replays a cached response as events, or streams from the
+// wrapped provider and records the result.
+func (c *DevCache) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	return c.chat(ctx, params, func(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+		return ChatStream(ctx, c.inner, params, onEvent)
+	}, onEvent)
+}
+
+// chat returns the cached response for params, replaying it to onEvent
+// unless that is nil, or calls fetch and records what it returns.
+func (c *DevCache) chat(ctx context.Context, params ChatParams, fetch func(context.Context, ChatParams) (*ChatResponse, error), onEvent func(StreamEvent)) (*ChatResponse, error) {
+	key, err := requestKey(params)
+	if err != nil {
+		return fetch(ctx, params)
+	}
+	path := filepath.Join(c.dir, key+".json")
+	if data, err := os.ReadFile(path); err == nil {
+		var resp ChatResponse
+		if json.Unmarshal(data, &resp) == nil {
+			resp.Usage = Usage{} // a replay costs nothing
+			resp.RequestID, resp.Latency = "", 0
+			if onEvent != nil {
+				replay(&resp, onEvent)
+			}
+			return &resp, nil
+		}
+	}
+
+	resp, err := fetch(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	// Failing to record only costs a later replay
+	if data, err := json.Marshal(resp); err == nil && os.MkdirAll(c.dir, 0700) == nil {
+		tmp := path + ".tmp"
+		if os.WriteFile(tmp, data, 0600) == nil {
+			os.Rename(tmp, path)
+		}
+	}
+	return resp, nil
+}
+
+// requestKey hashes everything that affects a response.
+func requestKey(params ChatParams) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
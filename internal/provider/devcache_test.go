@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func devCacheParams() ChatParams {
+	temp := 0.5
+	return ChatParams{
+		Model:       "claude-sonnet-4-5",
+		System:      "Be brief.",
+		Messages:    []Message{NewUserMessage(NewTextBlock("hi")), NewAssistantMessage(NewToolUseBlock("t1", "ls", json.RawMessage(`{}`)))},
+		Tools:       []ToolDefinition{{Name: "ls", InputSchema: ToolInputSchema{Type: "object", Properties: map[string]any{"path": map[string]any{"type": "string"}, "all": map[string]any{"type": "boolean"}}}}},
+		MaxTokens:   1024,
+		Temperature: &temp,
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	// The key of a fixed request. If this changes, every recorded
+	// response is orphaned; that's fine only on purpose.
+	const want = "6cbbe11e8d91ab1b341bdabe14995169177c53b998d2d21dde09d134b866b78a"
+	for range 10 {
+		// Map order mustn't matter
+		if got, err := requestKey(devCacheParams()); err != nil || got != want {
+			t.Fatalf("requestKey = %s, %v; want %s", got, err, want)
+		}
+	}
+
+	changes := map[string]func(*ChatParams){
+		"model":       func(p *ChatParams) { p.Model = "claude-haiku-4-5" },
+		"system":      func(p *ChatParams) { p.System = "Be thorough." },
+		"message":     func(p *ChatParams) { p.Messages[0].Content[0].Text = "hello" },
+		"tool input":  func(p *ChatParams) { p.Messages[1].Content[0].Input = json.RawMessage(`{"path":"."}`) },
+		"tool":        func(p *ChatParams) { p.Tools[0].Description = "List files" },
+		"max tokens":  func(p *ChatParams) { p.MaxTokens = 2048 },
+		"temperature": func(p *ChatParams) { p.Temperature = nil },
+		"stop":        func(p *ChatParams) { p.StopSequences = []string{"END"} },
+	}
+	for name, change := range changes {
+		params := devCacheParams()
+		change(&params)
+		if got, _ := requestKey(params); got == want {
+			t.Errorf("changing the %s doesn't change the key", name)
+		}
+	}
+}
+
+func TestDevCacheReplay(t *testing.T) {
+	calls := 0
+	p := funcProvider(func(context.Context, ChatParams) (*ChatResponse, error) {
+		calls++
+		return &ChatResponse{Content: []ContentBlock{NewTextBlock("hello")}, StopReason: "end_turn", Usage: Usage{InputTokens: 10}, RequestID: "req_1"}, nil
+	})
+	c := NewDevCache(p, t.TempDir())
+
+	first, err := c.Chat(context.Background(), devCacheParams())
+	if err != nil || first.Usage.InputTokens != 10 {
+		t.Fatalf("first Chat = %+v, %v", first, err)
+	}
+	var events []StreamEvent
+	replayed, err := c.ChatStream(context.Background(), devCacheParams(), func(e StreamEvent) { events = append(events, e) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("provider called %d times, want 1", calls)
+	}
+	if replayed.Content[0].Text != "hello" || replayed.Usage != (Usage{}) || replayed.RequestID != "" {
+		t.Errorf("replayed = %+v, want the recorded content at no cost", replayed)
+	}
+	if len(events) != 1 || events[0].Text != "hello" {
+		t.Errorf("events = %+v", events)
+	}
+
+	params := devCacheParams()
+	params.System = "Be thorough."
+	if _, err := c.Chat(context.Background(), params); err != nil || calls != 2 {
+		t.Errorf("a different request: %v after %d calls, want 2", err, calls)
+	}
+}
@@ -0,0 +1,107 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// FallbackProvider sends a request that failed on its model to the next
+// of a list of fallback models, until one answers. Only failures another
+// model might not have are passed on: overload and other transient errors
+// (after RetryProvider gave up), an unknown or retired model, and a prompt
+// too long for the model's context window. The response's Model names the
+// model that answered.
+type FallbackProvider struct {
+	p      Provider
+	models []string
+
+	// onFallback is called before each retry on another model; nil = quiet.
+	onFallback func(from, to string, err error)
+}
+
+// NewFallback wraps p so requests fall back to models in order.
+// onFallback, if set, is told each time.
+func NewFallback(p Provider, models []string, onFallback func(from, to string, err error)) *FallbackProvider {
+	return &FallbackProvider{p: p, models: models, onFallback: onFallback}
+}
+
+func (f *FallbackProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	return f.fallback(ctx, params, func(params ChatParams) (*ChatResponse, bool, error) {
+		resp, err := f.p.Chat(ctx, params)
+		return resp, true, err
+	})
+}
+
+// ChatStream falls back only while nothing has been streamed, like
+// RetryProvider.
+func (f *FallbackProvider) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	return f.fallback(ctx, params, func(params ChatParams) (*ChatResponse, bool, error) {
+		streamed := false
+		resp, err := ChatStream(ctx, f.p, params, func(e StreamEvent) {
+			streamed = true
+			onEvent(e)
+		})
+		return resp, !streamed, err
+	})
+}
+
+func (f *FallbackProvider) fallback(ctx context.Context, params ChatParams, try func(ChatParams) (*ChatResponse, bool, error)) (*ChatResponse, error) {
+	models := []string{params.Model}
+	for _, m := range f.models {
+		if m != "" && m != params.Model {
+			models = append(models, m)
+		}
+	}
+	for i, model := range models {
+		params.Model = model
+		resp, canFallback, err := try(params)
+		if err == nil {
+			resp.Model = model
+			return resp, nil
+		}
+		if i == len(models)-1 || !canFallback || ctx.Err() != nil || !Fallbackable(err) {
+			return nil, err
+		}
+		if f.onFallback != nil {
+			f.onFallback(model, models[i+1], err)
+		}
+	}
+	panic("unreachable")
+}
+
+// Fallbackable reports whether err might not happen with another model:
+// a transient failure (see Retryable), an unknown or retired model, or a
+// context window exceeded. Bad keys and malformed requests fail the same
+// on every model.
+func Fallbackable(err error) bool {
+	if ok, _ := Retryable(err); ok {
+		return true
+	}
+	code := 0
+	var se *StatusError
+	var ae *anthropic.Error
+	switch {
+	case errors.As(err, &se):
+		code = se.StatusCode
+	case errors.As(err, &ae):
+		code = ae.StatusCode
+	}
+	if code == http.StatusNotFound || code == http.StatusGone {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"context_length_exceeded", "context length", "context window", "maximum context",
+		"prompt is too long", "too many tokens", "model_not_found", "does not exist",
+		"deprecated", "decommissioned", "not supported model", "unknown model",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
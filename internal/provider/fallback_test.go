@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFallbackable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"overloaded", statusErr(529, 0), true},
+		{"rate limited", statusErr(429, 0), true},
+		{"dropped connection", io.ErrUnexpectedEOF, true},
+		{"unknown model", statusErr(404, 0), true},
+		{"retired model", statusErr(410, 0), true},
+		{"context window", &StatusError{API: "openai", StatusCode: 400, Status: "400 Bad Request", Message: "This model's maximum context length is 128000 tokens"}, true},
+		{"prompt too long", errors.New(`400 Bad Request: {"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}`), true},
+		{"model not found", errors.New("ollama API error: model_not_found"), true},
+		{"bad key", statusErr(401, 0), false},
+		{"forbidden", statusErr(403, 0), false},
+		{"bad request", &StatusError{API: "openai", StatusCode: 400, Status: "400 Bad Request", Message: "messages: field required"}, false},
+		{"cancelled", context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := Fallbackable(tt.err); got != tt.want {
+			t.Errorf("%s: Fallbackable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackProvider(t *testing.T) {
+	tests := []struct {
+		name   string
+		fail   map[string]error // error by model; others answer
+		tried  string
+		served string // "" = the error is returned
+	}{
+		{"first answers", nil, "a", "a"},
+		{"overloaded", map[string]error{"a": statusErr(529, 0)}, "a b", "b"},
+		{"unknown model", map[string]error{"a": statusErr(404, 0), "b": statusErr(404, 0)}, "a b c", "c"},
+		{"bad key", map[string]error{"a": statusErr(401, 0)}, "a", ""},
+		{"bad request", map[string]error{"a": statusErr(400, 0)}, "a", ""},
+		{"all fail", map[string]error{"a": statusErr(503, 0), "b": statusErr(503, 0), "c": statusErr(503, 0)}, "a b c", ""},
+	}
+	for _, tt := range tests {
+		var tried []string
+		p := funcProvider(func(_ context.Context, params ChatParams) (*ChatResponse, error) {
+			tried = append(tried, params.Model)
+			if err := tt.fail[params.Model]; err != nil {
+				return nil, err
+			}
+			return &ChatResponse{Usage: Usage{InputTokens: 10, OutputTokens: 1}}, nil
+		})
+		var fallbacks []string
+		f := NewFallback(p, []string{"b", "a", "", "c"}, func(from, to string, err error) {
+			fallbacks = append(fallbacks, from+"→"+to)
+		})
+		resp, err := f.Chat(context.Background(), ChatParams{Model: "a"})
+		if got := strings.Join(tried, " "); got != tt.tried {
+			t.Errorf("%s: tried %s, want %s", tt.name, got, tt.tried)
+		}
+		if len(fallbacks) != len(tried)-1 {
+			t.Errorf("%s: onFallback called for %v", tt.name, fallbacks)
+		}
+		switch {
+		case tt.served == "" && err == nil:
+			t.Errorf("%s: served by %s, want an error", tt.name, resp.Model)
+		case tt.served != "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.served != "" && resp.Model != tt.served:
+			t.Errorf("%s: Model = %q, want %q", tt.name, resp.Model, tt.served)
+		}
+	}
+}
+
+func TestFallbackStreamed(t *testing.T) {
+	var tried []string
+	p := &streamProvider{stream: func(onEvent func(StreamEvent)) (*ChatResponse, error) {
+		tried = append(tried, "call")
+		onEvent(StreamEvent{Type: "text", Text: "partial"})
+		return nil, statusErr(529, 0)
+	}}
+	f := NewFallback(p, []string{"b"}, nil)
+	if _, err := f.ChatStream(context.Background(), ChatParams{Model: "a"}, func(StreamEvent) {}); err == nil || len(tried) != 1 {
+		t.Errorf("ChatStream = %v after %d calls; a stream that showed output fell back", err, len(tried))
+	}
+}
+
+// Usage is counted against the model that answered, not the one asked for.
+func TestFallbackUsage(t *testing.T) {
+	p := funcProvider(func(_ context.Context, params ChatParams) (*ChatResponse, error) {
+		if params.Model == "big" {
+			return nil, statusErr(529, 0)
+		}
+		return &ChatResponse{Usage: Usage{InputTokens: 100, OutputTokens: 5}}, nil
+	})
+	meter := NewMeter()
+	wrapped := meter.Wrap(NewFallback(p, []string{"small"}, nil))
+	for range 2 {
+		if _, err := wrapped.Chat(context.Background(), ChatParams{Model: "big"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	usage := meter.Usage()
+	for i := range usage {
+		usage[i].TimeMS = 0 // varies
+	}
+	want := []ModelUsage{{Model: "small", Calls: 2, Usage: Usage{InputTokens: 200, OutputTokens: 10}}}
+	if fmt.Sprint(usage) != fmt.Sprint(want) {
+		t.Errorf("Usage = %+v, want %+v", usage, want)
+	}
+}
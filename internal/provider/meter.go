@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ModelUsage is what one model used over a Meter's lifetime.
+type ModelUsage struct {
+	Model  string  `json:"model"`
+	Calls  int     `json:"calls"`
+	TimeMS float64 `json:"time_ms"` // waiting for responses, retries included
+	Usage
+}
+
+// Meter adds up the token usage of every response from the providers it
+// wraps, by model, so one Meter can count all the agents of a --map run.
+// A nil *Meter counts nothing. Safe for concurrent use.
+type Meter struct {
+	mu     sync.Mutex
+	models map[string]*ModelUsage
+}
+
+// NewMeter returns an empty Meter.
+func NewMeter() *Meter {
+	return &Meter{models: map[string]*ModelUsage{}}
+}
+
+// Wrap returns p with its usage counted by m.
+func (m *Meter) Wrap(p Provider) Provider {
+	if m == nil {
+		return p
+	}
+	return &metered{p: p, m: m}
+}
+
+// Usage returns the usage so far, one entry per model, sorted by model.
+func (m *Meter) Usage() []ModelUsage {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ModelUsage, 0, len(m.models))
+	for _, u := range m.models {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Model < out[j].Model })
+	return out
+}
+
+func (m *Meter) add(model string, resp *ChatResponse, took time.Duration) {
+	if resp == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := m.models[model]
+	if u == nil {
+		u = &ModelUsage{Model: model}
+		m.models[model] = u
+	}
+	u.Calls++
+	u.TimeMS += float64(took) / float64(time.Millisecond)
+	u.Usage = u.Usage.Add(resp.Usage)
+}
+
+type metered struct {
+	p Provider
+	m *Meter
+}
+
+func (w *metered) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	started := time.Now()
+	resp, err := w.p.Chat(ctx, params)
+	w.m.add(servedBy(params, resp), resp, time.Since(started))
+	return resp, err
+}
+
+func (w *metered) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	started := time.Now()
+	resp, err := ChatStream(ctx, w.p, params, onEvent)
+	w.m.add(servedBy(params, resp), resp, time.Since(started))
+	return resp, err
+}
+
+// servedBy is the model usage is counted against: the one that answered,
+// which a FallbackProvider may have changed.
+func servedBy(params ChatParams, resp *ChatResponse) string {
+	if resp != nil && resp.Model != "" {
+		return resp.Model
+	}
+	return params.Model
+}
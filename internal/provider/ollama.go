@@ -0,0 +1,471 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultOllamaBase is the address of a local Ollama server.
+const DefaultOllamaBase = "http://localhost:11434"
+
+// OllamaProvider talks to Ollama's native /api/chat endpoint, so thoughts
+// can run fully offline against local models. Models with tool support
+// get the tools natively; for a model Ollama says can't use tools, the
+// provider switches to JSON mode and describes the tools in the system
+// prompt instead (see toolEmulationPrompt). That choice is remembered
+// per model for the life of the provider.
+type OllamaProvider struct {
+	base   string
+	client *http.Client
+
+	mu       sync.Mutex
+	emulated map[string]bool // models answered in JSON mode
+}
+
+// NewOllamaProvider returns a provider for the Ollama server at base. An
+// empty base falls back to OLLAMA_HOST, then DefaultOllamaBase.
+func NewOllamaProvider(base string) *OllamaProvider {
+	if base == "" {
+		base = os.Getenv("OLLAMA_HOST")
+	}
+	if base == "" {
+		base = DefaultOllamaBase
+	}
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	return &OllamaProvider{base: strings.TrimRight(base, "/"), client: http.DefaultClient, emulated: map[string]bool{}}
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"` // on "tool" messages
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []openAITool    `json:"tools,omitempty"` // same shape as OpenAI's
+	Format   string          `json:"format,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  struct {
+		NumPredict  int      `json:"num_predict,omitempty"`
+		Temperature *float64 `json:"temperature,omitempty"`
+		TopP        *float64 `json:"top_p,omitempty"`
+		Stop        []string `json:"stop,omitempty"`
+	} `json:"options"`
+}
+
+type ollamaResponse struct {
+	Message    ollamaMessage `json:"message"`
+	Done       bool          `json:"done"`
+	DoneReason string        `json:"done_reason"`
+	Error      string        `json:"error"`
+
+	// Token counts, in the final object
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (r *ollamaResponse) usage() Usage {
+	return Usage{InputTokens: r.PromptEvalCount, OutputTokens: r.EvalCount}
+}
+
+// errNoTools reports that a model can't be given tools.
+var errNoTools = errors.New("model does not support tools")
+
+func (p *OllamaProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	if !p.isEmulated(params.Model) {
+		resp, err := p.chat(ctx, params, false, nil)
+		if !errors.Is(err, errNoTools) {
+			return resp, err
+		}
+		p.setEmulated(params.Model)
+	}
+	return p.chatEmulated(ctx, params)
+}
+
+// ChatStream streams content deltas from models with native tools. Tool
+// calls arrive whole, so each is reported as soon as its chunk does. JSON
+// mode answers aren't readable until parsed; they are replayed.
+func (p *OllamaProvider) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	if !p.isEmulated(params.Model) {
+		resp, err := p.chat(ctx, params, true, onEvent)
+		if !errors.Is(err, errNoTools) {
+			return resp, err
+		}
+		p.setEmulated(params.Model)
+	}
+	resp, err := p.chatEmulated(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	replay(resp, onEvent)
+	return resp, nil
+}
+
+func (p *OllamaProvider) isEmulated(model string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.emulated[model]
+}
+
+func (p *OllamaProvider) setEmulated(model string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.emulated[model] = true
+}
+
+// chat sends a request with native tools, streaming it to onEvent if
+// stream is set.
+func (p *OllamaProvider) chat(ctx context.Context, params ChatParams, stream bool, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	started := time.Now()
+	req := ollamaRequest{Model: params.Model, Messages: toOllamaMessages(params.System, params.Messages), Stream: stream}
+	req.Options.NumPredict = params.MaxTokens
+	req.Options.Temperature, req.Options.TopP, req.Options.Stop = params.Temperature, params.TopP, params.StopSequences
+	for _, t := range params.Tools {
+		var tool openAITool
+		tool.Type = "function"
+		tool.Function.Name = t.Name
+		tool.Function.Description = t.Description
+		tool.Function.Parameters = t.InputSchema
+		req.Tools = append(req.Tools, tool)
+	}
+
+	body, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Streamed or not, the body is one or more JSON objects
+	var (
+		msg    ollamaMessage
+		reason string
+		usage  Usage
+	)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("ollama API error: decoding response: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, ollamaError(chunk.Error)
+		}
+		if chunk.Message.Content != "" {
+			msg.Content += chunk.Message.Content
+			if onEvent != nil {
+				onEvent(StreamEvent{Type: "text", Text: chunk.Message.Content})
+			}
+		}
+		for _, tc := range chunk.Message.ToolCalls {
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+			if onEvent != nil {
+				onEvent(StreamEvent{Type: "tool_use", ToolName: tc.Function.Name})
+				onEvent(StreamEvent{Type: "tool_input", Text: string(tc.Function.Arguments)})
+			}
+		}
+		if chunk.Done {
+			reason = chunk.DoneReason
+			usage = chunk.usage()
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ollama API error: %w", err)
+	}
+
+	result := &ChatResponse{StopReason: ollamaStopReason(reason), Usage: usage, Latency: time.Since(started)}
+	if msg.Content != "" {
+		result.Content = append(result.Content, NewTextBlock(msg.Content))
+	}
+	for i, tc := range msg.ToolCalls {
+		result.Content = append(result.Content, NewToolUseBlock(ollamaCallID(params, i), tc.Function.Name, toolArguments(tc.Function.Arguments)))
+	}
+	if len(msg.ToolCalls) > 0 {
+		result.StopReason = "tool_use"
+	}
+	return result, nil
+}
+
+// toolEmulationPrompt is appended to the system prompt in JSON mode. %s is
+// the JSON of the tool definitions.
+const toolEmulationPrompt = `
+
+## Calling tools
+
+You can call these tools (JSON Schema for each tool's input):
+
+%s
+
+Reply with a single JSON object and nothing else:
+
+{"content": "optional short note", "tool_calls": [{"name": "tool_name", "arguments": {...}}]}
+
+Leave "tool_calls" empty when you are done. Tool results come back in the
+next user message.`
+
+// emulatedReply is the JSON a model answers with in JSON mode.
+type emulatedReply struct {
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"tool_calls"`
+}
+
+// chatEmulated sends a request in JSON mode, with the tools described in
+// the system prompt and past tool calls and results written out as text.
+func (p *OllamaProvider) chatEmulated(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	started := time.Now()
+	system := params.System
+	if len(params.Tools) > 0 {
+		type toolDef struct {
+			Name        string          `json:"name"`
+			Description string          `json:"description"`
+			InputSchema ToolInputSchema `json:"input_schema"`
+		}
+		var tools []toolDef
+		for _, t := range params.Tools {
+			tools = append(tools, toolDef{t.Name, t.Description, t.InputSchema})
+		}
+		defs, err := json.MarshalIndent(tools, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		system += fmt.Sprintf(toolEmulationPrompt, defs)
+	}
+	req := ollamaRequest{Model: params.Model, Messages: toEmulatedMessages(system, params.Messages), Format: "json"}
+	req.Options.NumPredict = params.MaxTokens
+	// No stop sequences: one inside the JSON answer would cut it short
+	req.Options.Temperature, req.Options.TopP = params.Temperature, params.TopP
+
+	body, err := p.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	var out ollamaResponse
+	if err := json.NewDecoder(body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("ollama API error: decoding response: %w", err)
+	}
+	if out.Error != "" {
+		return nil, ollamaError(out.Error)
+	}
+
+	var reply emulatedReply
+	if err := json.Unmarshal([]byte(out.Message.Content), &reply); err != nil {
+		// Not the requested shape; treat it as a final answer
+		return &ChatResponse{Content: []ContentBlock{NewTextBlock(out.Message.Content)}, StopReason: ollamaStopReason(out.DoneReason), Usage: out.usage(), Latency: time.Since(started)}, nil
+	}
+	result := &ChatResponse{StopReason: ollamaStopReason(out.DoneReason), Usage: out.usage(), Latency: time.Since(started)}
+	if reply.Content != "" {
+		result.Content = append(result.Content, NewTextBlock(reply.Content))
+	}
+	for i, tc := range reply.ToolCalls {
+		result.Content = append(result.Content, NewToolUseBlock(ollamaCallID(params, i), tc.Name, toolArguments(tc.Arguments)))
+	}
+	if len(reply.ToolCalls) > 0 {
+		result.StopReason = "tool_use"
+	}
+	return result, nil
+}
+
+// post sends req to /api/chat and returns the response body, or the error
+// Ollama reported.
+func (p *OllamaProvider) post(ctx context.Context, req ollamaRequest) (io.ReadCloser, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+"/api/chat", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama API error: %w (is Ollama running at %s?)", err, p.base)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var out ollamaResponse
+		if json.Unmarshal(data, &out) == nil && out.Error != "" {
+			if strings.Contains(out.Error, "does not support tools") {
+				return nil, ollamaError(out.Error)
+			}
+			return nil, newStatusError("ollama", resp, out.Error)
+		}
+		return nil, newStatusError("ollama", resp, strings.TrimSpace(string(data)))
+	}
+	return resp.Body, nil
+}
+
+// ollamaError wraps an error Ollama returned, marking the one it gives
+// for a model without tool support.
+func ollamaError(msg string) error {
+	if strings.Contains(msg, "does not support tools") {
+		return fmt.Errorf("ollama API error: %s: %w", msg, errNoTools)
+	}
+	return fmt.Errorf("ollama API error: %s", msg)
+}
+
+// toOllamaMessages flattens content blocks into Ollama chat messages, the
+// same way as for OpenAI: tool results become "tool" messages.
+func toOllamaMessages(system string, msgs []Message) []ollamaMessage {
+	var out []ollamaMessage
+	if system != "" {
+		out = append(out, ollamaMessage{Role: "system", Content: system})
+	}
+	names := map[string]string{} // tool use ID → tool name
+	for _, msg := range msgs {
+		m := ollamaMessage{Role: msg.Role}
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				m.Content += block.Text
+			case "tool_use":
+				var tc ollamaToolCall
+				tc.Function.Name = block.ToolName
+				tc.Function.Arguments = toolArguments(block.Input)
+				m.ToolCalls = append(m.ToolCalls, tc)
+				names[block.ToolUseID] = block.ToolName
+			case "tool_result":
+				content := block.Content
+				if block.IsError {
+					content = "Error: " + content
+				}
+				out = append(out, ollamaMessage{Role: "tool", Content: content, ToolName: names[block.ToolUseIDRef]})
+			}
+		}
+		if m.Content != "" || len(m.ToolCalls) > 0 {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// toEmulatedMessages writes tool calls and results as text for JSON mode:
+// an assistant turn becomes the JSON reply it stands for, and tool results
+// become a user message.
+func toEmulatedMessages(system string, msgs []Message) []ollamaMessage {
+	out := []ollamaMessage{{Role: "system", Content: system}}
+	for _, msg := range msgs {
+		if msg.Role == "assistant" {
+			var reply emulatedReply
+			for _, block := range msg.Content {
+				switch block.Type {
+				case "text":
+					reply.Content += block.Text
+				case "tool_use":
+					reply.ToolCalls = append(reply.ToolCalls, struct {
+						Name      string          `json:"name"`
+						Arguments json.RawMessage `json:"arguments"`
+					}{block.ToolName, toolArguments(block.Input)})
+				}
+			}
+			data, _ := json.Marshal(reply)
+			out = append(out, ollamaMessage{Role: "assistant", Content: string(data)})
+			continue
+		}
+		var b strings.Builder
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				b.WriteString(block.Text)
+				b.WriteString("\n")
+			case "tool_result":
+				status := "result"
+				if block.IsError {
+					status = "error"
+				}
+				fmt.Fprintf(&b, "Tool %s (%s):\n%s\n\n", status, block.ToolUseIDRef, block.Content)
+			}
+		}
+		out = append(out, ollamaMessage{Role: "user", Content: strings.TrimSpace(b.String())})
+	}
+	return out
+}
+
+// toolArguments returns args as a JSON object, "{}" when empty. Some
+// models send arguments as a JSON-encoded string; that is unwrapped.
+func toolArguments(args json.RawMessage) json.RawMessage {
+	var s string
+	if json.Unmarshal(args, &s) == nil {
+		args = json.RawMessage(s)
+	}
+	if len(bytes.TrimSpace(args)) == 0 || string(args) == "null" {
+		return json.RawMessage("{}")
+	}
+	return args
+}
+
+// ollamaCallID makes up an ID for the i-th tool call of a response, as
+// Ollama doesn't send any. The turn number keeps IDs unique in a run.
+func ollamaCallID(params ChatParams, i int) string {
+	return fmt.Sprintf("call_%d_%d", len(params.Messages), i)
+}
+
+func ollamaStopReason(reason string) string {
+	if reason == "length" {
+		return "max_tokens"
+	}
+	return "end_turn"
+}
+
+// OllamaModels lists the models installed on the Ollama server at base
+// (resolved as in NewOllamaProvider).
+func OllamaModels(ctx context.Context, base string) ([]string, error) {
+	p := NewOllamaProvider(base)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.base+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama API error: %w (is Ollama running at %s?)", err, p.base)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("ollama", resp, "")
+	}
+	var out struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("ollama API error: decoding model list: %w", err)
+	}
+	names := make([]string, len(out.Models))
+	for i, m := range out.Models {
+		names[i] = m.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ollamaNoTools is an Ollama server whose model can't use tools: native
+// tool requests fail, and JSON mode ones are answered with reply.
+func ollamaNoTools(t *testing.T, reply *string) (*httptest.Server, *[]ollamaRequest) {
+	t.Helper()
+	var reqs []ollamaRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		reqs = append(reqs, req)
+		if len(req.Tools) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "registry.ollama.ai/library/" + req.Model + " does not support tools"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"message":           map[string]string{"role": "assistant", "content": *reply},
+			"done":              true,
+			"done_reason":       "stop",
+			"prompt_eval_count": 50,
+			"eval_count":        7,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestOllamaEmulatedTools(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply string
+		want  string // the response's content, one block per line
+		stop  string
+	}{
+		{
+			"one call",
+			`{"content": "Listing.", "tool_calls": [{"name": "ls", "arguments": {"path": "."}}]}`,
+			`text Listing.` + "\n" + `tool_use call_1_0 ls {"path": "."}`,
+			"tool_use",
+		},
+		{
+			"several calls",
+			`{"tool_calls": [{"name": "ls", "arguments": {}}, {"name": "read", "arguments": {"path": "a"}}, {"name": "read", "arguments": {"path": "b"}}]}`,
+			`tool_use call_1_0 ls {}` + "\n" + `tool_use call_1_1 read {"path": "a"}` + "\n" + `tool_use call_1_2 read {"path": "b"}`,
+			"tool_use",
+		},
+		{
+			"arguments as a string",
+			`{"tool_calls": [{"name": "read", "arguments": "{\"path\": \"a\"}"}]}`,
+			`tool_use call_1_0 read {"path": "a"}`,
+			"tool_use",
+		},
+		{
+			"no arguments",
+			`{"tool_calls": [{"name": "ls"}, {"name": "pwd", "arguments": null}]}`,
+			`tool_use call_1_0 ls {}` + "\n" + `tool_use call_1_1 pwd {}`,
+			"tool_use",
+		},
+		{
+			"done",
+			`{"content": "All done.", "tool_calls": []}`,
+			`text All done.`,
+			"end_turn",
+		},
+		{
+			"not JSON",
+			`Sure! I'll list the files.`,
+			`text Sure! I'll list the files.`,
+			"end_turn",
+		},
+		{
+			"cut short",
+			`{"content": "Listing.", "tool_calls": [{"name": "ls", "argu`,
+			`text {"content": "Listing.", "tool_calls": [{"name": "ls", "argu`,
+			"end_turn",
+		},
+		{
+			"wrong shape",
+			`{"tool_calls": "ls"}`,
+			`text {"tool_calls": "ls"}`,
+			"end_turn",
+		},
+	}
+	tools := []ToolDefinition{{Name: "ls", Description: "List files", InputSchema: ToolInputSchema{Type: "object"}}}
+	for _, tt := range tests {
+		reply := tt.reply
+		srv, _ := ollamaNoTools(t, &reply)
+		p := NewOllamaProvider(srv.URL)
+		resp, err := p.Chat(context.Background(), ChatParams{Model: "tiny", Tools: tools, Messages: []Message{NewUserMessage(NewTextBlock("list files"))}})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, b := range resp.Content {
+			if b.Type == "text" {
+				got = append(got, "text "+b.Text)
+			} else {
+				got = append(got, strings.Join([]string{b.Type, b.ToolUseID, b.ToolName, string(b.Input)}, " "))
+			}
+		}
+		if strings.Join(got, "\n") != tt.want {
+			t.Errorf("%s: content =\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), tt.want)
+		}
+		if resp.StopReason != tt.stop {
+			t.Errorf("%s: StopReason = %q, want %q", tt.name, resp.StopReason, tt.stop)
+		}
+		if resp.Usage != (Usage{InputTokens: 50, OutputTokens: 7}) {
+			t.Errorf("%s: Usage = %+v", tt.name, resp.Usage)
+		}
+	}
+}
+
+func TestOllamaEmulatedRequest(t *testing.T) {
+	reply := `{"content": "Done."}`
+	srv, reqs := ollamaNoTools(t, &reply)
+	p := NewOllamaProvider(srv.URL)
+	tools := []ToolDefinition{{Name: "ls", Description: "List files", InputSchema: ToolInputSchema{Type: "object"}}}
+	messages := []Message{
+		NewUserMessage(NewTextBlock("list files")),
+		NewAssistantMessage(NewTextBlock("Listing."), NewToolUseBlock("call_1_0", "ls", nil), NewToolUseBlock("call_1_1", "ls", json.RawMessage(`{"path":"b"}`))),
+		NewUserMessage(NewToolResultBlock("call_1_0", "a.txt", false), NewToolResultBlock("call_1_1", "no such directory", true)),
+	}
+	for range 2 {
+		if _, err := p.Chat(context.Background(), ChatParams{Model: "tiny", System: "Be brief.", Tools: tools, Messages: messages}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The model is asked for tools once, then answered in JSON mode
+	if len(*reqs) != 3 || len((*reqs)[0].Tools) == 0 || (*reqs)[1].Format != "json" || (*reqs)[2].Format != "json" {
+		t.Fatalf("requests: %+v", *reqs)
+	}
+	msgs := (*reqs)[2].Messages
+	if len(msgs) != 4 {
+		t.Fatalf("messages: %+v", msgs)
+	}
+	if system := msgs[0].Content; !strings.HasPrefix(system, "Be brief.\n\n## Calling tools") || !strings.Contains(system, `"name": "ls"`) {
+		t.Errorf("system prompt = %q", system)
+	}
+	if got, want := msgs[2].Content, `{"content":"Listing.","tool_calls":[{"name":"ls","arguments":{}},{"name":"ls","arguments":{"path":"b"}}]}`; got != want {
+		t.Errorf("assistant turn = %s\nwant %s", got, want)
+	}
+	if got, want := msgs[3].Content, "Tool result (call_1_0):\na.txt\n\nTool error (call_1_1):\nno such directory"; got != want {
+		t.Errorf("tool results = %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,417 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOpenAIBase is the API base used when an openai agent sets none.
+const DefaultOpenAIBase = "https://api.openai.com/v1"
+
+// OpenAIConfig describes an OpenAI-compatible chat completions endpoint.
+// Vendor differences (Azure deployments, OpenRouter headers) are expressed
+// as data rather than code:
+//
+//   - ChatPath is appended to APIBase and may contain {model}, e.g.
+//     "/openai/deployments/{model}/chat/completions?api-version=2024-10-21".
+//   - Headers values may contain {api_key} and {model}. When set, they
+//     replace the default "Authorization: Bearer {api_key}" header.
+//   - Models maps requested model names to upstream names or deployments.
+type OpenAIConfig struct {
+	APIBase  string
+	APIKey   string
+	ChatPath string
+	Headers  map[string]string
+	Models   map[string]string
+}
+
+// OpenAIProvider talks to any OpenAI-compatible chat completions API.
+type OpenAIProvider struct {
+	cfg    OpenAIConfig
+	client *http.Client
+}
+
+func NewOpenAIProvider(cfg OpenAIConfig) *OpenAIProvider {
+	if cfg.APIBase == "" {
+		cfg.APIBase = DefaultOpenAIBase
+	}
+	if cfg.ChatPath == "" {
+		cfg.ChatPath = "/chat/completions"
+	}
+	if len(cfg.Headers) == 0 {
+		cfg.Headers = map[string]string{"Authorization": "Bearer {api_key}"}
+	}
+	return &OpenAIProvider{cfg: cfg, client: http.DefaultClient}
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  ToolInputSchema `json:"parameters"`
+	} `json:"function"`
+}
+
+type openAIRequest struct {
+	Model     string          `json:"model"`
+	Messages  []openAIMessage `json:"messages"`
+	Tools     []openAITool    `json:"tools,omitempty"`
+	MaxTokens int             `json:"max_tokens,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// MaxCompletionTokens replaces MaxTokens on api.openai.com, whose
+	// reasoning models reject max_tokens. Compatible gateways often don't
+	// know it, so they keep max_tokens.
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+
+	Stream bool `json:"stream,omitempty"`
+
+	// StreamOptions asks api.openai.com to end a stream with its usage.
+	// Gateways that don't know it may reject it, so only it gets it.
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// openAIUsage is a response's token count.
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+}
+
+func (u *openAIUsage) toUsage() Usage {
+	if u == nil {
+		return Usage{}
+	}
+	usage := Usage{InputTokens: u.PromptTokens, OutputTokens: u.CompletionTokens}
+	if u.PromptTokensDetails != nil {
+		usage.CachedTokens = u.PromptTokensDetails.CachedTokens
+	}
+	return usage
+}
+
+// openAIMeta fills in what a response's headers and timing say about it.
+func openAIMeta(result *ChatResponse, resp *http.Response, started time.Time) *ChatResponse {
+	result.RequestID = resp.Header.Get("x-request-id")
+	result.Latency = time.Since(started)
+	return result
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message      openAIMessage `json:"message"`
+		FinishReason string        `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	started := time.Now()
+	resp, err := p.do(ctx, params, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("openai API error: %w", err)
+	}
+
+	var out openAIResponse
+	if err := json.Unmarshal(data, &out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, newStatusError("openai", resp, strings.TrimSpace(string(data)))
+		}
+		return nil, fmt.Errorf("openai API error: decoding response: %w", err)
+	}
+	if out.Error != nil {
+		return nil, newStatusError("openai", resp, out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("openai", resp, "")
+	}
+	if len(out.Choices) == 0 {
+		return nil, errors.New("openai API error: response has no choices")
+	}
+
+	choice := out.Choices[0]
+	result := fromOpenAI(choice.Message, choice.FinishReason)
+	result.Usage = out.Usage.toUsage()
+	return openAIMeta(result, resp, started), nil
+}
+
+// openAIChunk is one server-sent event of a streamed completion.
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"` // in the last chunk, when requested
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ChatStream requests a streamed completion and reports content and tool
+// call argument deltas as they arrive. Tool calls are assembled by their
+// index, as the API sends each call's id and name once, then its
+// arguments in pieces.
+func (p *OpenAIProvider) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	started := time.Now()
+	resp, err := p.do(ctx, params, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		var out openAIResponse
+		if json.Unmarshal(data, &out) == nil && out.Error != nil {
+			return nil, newStatusError("openai", resp, out.Error.Message)
+		}
+		return nil, newStatusError("openai", resp, strings.TrimSpace(string(data)))
+	}
+
+	var (
+		msg    openAIMessage
+		text   strings.Builder
+		finish string
+		usage  Usage
+		byIdx  = map[int]int{} // call index in the stream → position in msg.ToolCalls
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("openai API error: decoding stream: %w", err)
+		}
+		if chunk.Error != nil {
+			return nil, fmt.Errorf("openai API error: %s", chunk.Error.Message)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.toUsage()
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.Delta.Content != "" {
+			text.WriteString(choice.Delta.Content)
+			onEvent(StreamEvent{Type: "text", Text: choice.Delta.Content})
+		}
+		for _, tc := range choice.Delta.ToolCalls {
+			i, ok := byIdx[tc.Index]
+			if !ok {
+				i = len(msg.ToolCalls)
+				byIdx[tc.Index] = i
+				var call openAIToolCall
+				call.ID = tc.ID
+				call.Type = "function"
+				call.Function.Name = tc.Function.Name
+				msg.ToolCalls = append(msg.ToolCalls, call)
+				onEvent(StreamEvent{Type: "tool_use", ToolName: tc.Function.Name})
+			}
+			if tc.Function.Arguments != "" {
+				msg.ToolCalls[i].Function.Arguments += tc.Function.Arguments
+				onEvent(StreamEvent{Type: "tool_input", Text: tc.Function.Arguments})
+			}
+		}
+		if choice.FinishReason != "" {
+			finish = choice.FinishReason
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("openai API error: %w", err)
+	}
+	if text.Len() > 0 {
+		content := text.String()
+		msg.Content = &content
+	}
+	result := fromOpenAI(msg, finish)
+	result.Usage = usage
+	return openAIMeta(result, resp, started), nil
+}
+
+// do sends a chat completions request.
+func (p *OpenAIProvider) do(ctx context.Context, params ChatParams, stream bool) (*http.Response, error) {
+	model := params.Model
+	if routed, ok := p.cfg.Models[model]; ok {
+		model = routed
+	}
+
+	req := openAIRequest{
+		Model:       model,
+		Messages:    toOpenAIMessages(params.System, params.Messages),
+		MaxTokens:   params.MaxTokens,
+		Temperature: params.Temperature,
+		TopP:        params.TopP,
+		Stop:        params.StopSequences,
+		Stream:      stream,
+	}
+	if strings.TrimRight(p.cfg.APIBase, "/") == DefaultOpenAIBase {
+		req.MaxCompletionTokens, req.MaxTokens = req.MaxTokens, 0
+		if stream {
+			req.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+		}
+	}
+	for _, t := range params.Tools {
+		var tool openAITool
+		tool.Type = "function"
+		tool.Function.Name = t.Name
+		tool.Function.Description = t.Description
+		tool.Function.Parameters = t.InputSchema
+		req.Tools = append(req.Tools, tool)
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	expand := strings.NewReplacer("{api_key}", p.cfg.APIKey, "{model}", model).Replace
+	url := strings.TrimRight(p.cfg.APIBase, "/") + expand(p.cfg.ChatPath)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range p.cfg.Headers {
+		httpReq.Header.Set(k, expand(v))
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("openai API error: %w", err)
+	}
+	return resp, nil
+}
+
+// fromOpenAI converts an assistant message and its finish reason.
+func fromOpenAI(msg openAIMessage, finishReason string) *ChatResponse {
+	result := &ChatResponse{StopReason: openAIStopReason(finishReason)}
+	if msg.Content != nil && *msg.Content != "" {
+		result.Content = append(result.Content, NewTextBlock(*msg.Content))
+	}
+	for _, tc := range msg.ToolCalls {
+		args := json.RawMessage(tc.Function.Arguments)
+		if len(args) == 0 {
+			args = json.RawMessage("{}")
+		}
+		result.Content = append(result.Content, NewToolUseBlock(tc.ID, tc.Function.Name, args))
+	}
+	if len(msg.ToolCalls) > 0 {
+		result.StopReason = "tool_use"
+	}
+	return result
+}
+
+// toOpenAIMessages flattens content blocks into OpenAI chat messages: tool
+// results become "tool" role messages and tool_use blocks become tool_calls.
+func toOpenAIMessages(system string, msgs []Message) []openAIMessage {
+	var out []openAIMessage
+	if system != "" {
+		out = append(out, openAIMessage{Role: "system", Content: &system})
+	}
+	for _, msg := range msgs {
+		var text strings.Builder
+		var calls []openAIToolCall
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "text":
+				text.WriteString(block.Text)
+			case "tool_use":
+				var tc openAIToolCall
+				tc.ID = block.ToolUseID
+				tc.Type = "function"
+				tc.Function.Name = block.ToolName
+				tc.Function.Arguments = string(block.Input)
+				if len(block.Input) == 0 {
+					tc.Function.Arguments = "{}"
+				}
+				calls = append(calls, tc)
+			case "tool_result":
+				content := block.Content
+				if block.IsError {
+					content = "Error: " + content
+				}
+				out = append(out, openAIMessage{Role: "tool", Content: &content, ToolCallID: block.ToolUseIDRef})
+			}
+		}
+		if text.Len() == 0 && len(calls) == 0 {
+			continue
+		}
+		m := openAIMessage{Role: msg.Role, ToolCalls: calls}
+		if text.Len() > 0 {
+			s := text.String()
+			m.Content = &s
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func openAIStopReason(reason string) string {
+	switch reason {
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "length":
+		return "max_tokens"
+	default:
+		return "end_turn"
+	}
+}
@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// rewriteHost sends every request to srv, whatever its URL.
+type rewriteHost struct{ srv *httptest.Server }
+
+func (rt rewriteHost) RoundTrip(r *http.Request) (*http.Response, error) {
+	u, _ := url.Parse(rt.srv.URL)
+	r = r.Clone(r.Context())
+	r.URL.Scheme, r.URL.Host = u.Scheme, u.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// openAITestServer answers every request with response and records each
+// request's body as JSON.
+func openAITestServer(t *testing.T, response string) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var bodies []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		bodies = append(bodies, body)
+		w.Header().Set("x-request-id", "req_1")
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &bodies
+}
+
+func TestOpenAIMaxTokens(t *testing.T) {
+	srv, bodies := openAITestServer(t, `{"choices": [{"message": {"role": "assistant", "content": "hi"}, "finish_reason": "stop"}]}`)
+	params := ChatParams{Model: "gpt-5", MaxTokens: 1000, Messages: []Message{NewUserMessage(NewTextBlock("hello"))}}
+
+	tests := []struct {
+		name   string
+		base   string
+		set    string // the field that carries MaxTokens
+		absent string
+	}{
+		{"api.openai.com", DefaultOpenAIBase, "max_completion_tokens", "max_tokens"},
+		{"api.openai.com with a slash", DefaultOpenAIBase + "/", "max_completion_tokens", "max_tokens"},
+		{"gateway", "https://gateway.example/v1", "max_tokens", "max_completion_tokens"},
+	}
+	for _, tt := range tests {
+		p := NewOpenAIProvider(OpenAIConfig{APIBase: tt.base, APIKey: "sk-test"})
+		p.client = &http.Client{Transport: rewriteHost{srv}}
+		if _, err := p.Chat(context.Background(), params); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		body := (*bodies)[len(*bodies)-1]
+		if body[tt.set] != float64(1000) {
+			t.Errorf("%s: %s = %v, want 1000", tt.name, tt.set, body[tt.set])
+		}
+		if _, ok := body[tt.absent]; ok {
+			t.Errorf("%s: request has %s", tt.name, tt.absent)
+		}
+	}
+}
+
+func TestOpenAIRequestMessages(t *testing.T) {
+	srv, bodies := openAITestServer(t, `{"choices": [{"message": {"role": "assistant", "content": "done"}, "finish_reason": "stop"}]}`)
+	p := NewOpenAIProvider(OpenAIConfig{APIBase: srv.URL, APIKey: "sk-test"})
+
+	messages := []Message{
+		NewUserMessage(NewTextBlock("list files")),
+		NewAssistantMessage(NewTextBlock("Listing."), NewToolUseBlock("call_1", "ls", nil), NewToolUseBlock("call_2", "read", json.RawMessage(`{"path":"a"}`))),
+		NewUserMessage(NewToolResultBlock("call_1", "a", false), NewToolResultBlock("call_2", "no such file", true)),
+	}
+	tests := []struct {
+		system string
+		want   string // the request's messages, as JSON
+	}{
+		{"", `[
+			{"role": "user", "content": "list files"},
+			{"role": "assistant", "content": "Listing.", "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "ls", "arguments": "{}"}},
+				{"id": "call_2", "type": "function", "function": {"name": "read", "arguments": "{\"path\":\"a\"}"}}
+			]},
+			{"role": "tool", "content": "a", "tool_call_id": "call_1"},
+			{"role": "tool", "content": "Error: no such file", "tool_call_id": "call_2"}
+		]`},
+		{"Be brief.", `[
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "list files"},
+			{"role": "assistant", "content": "Listing.", "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "ls", "arguments": "{}"}},
+				{"id": "call_2", "type": "function", "function": {"name": "read", "arguments": "{\"path\":\"a\"}"}}
+			]},
+			{"role": "tool", "content": "a", "tool_call_id": "call_1"},
+			{"role": "tool", "content": "Error: no such file", "tool_call_id": "call_2"}
+		]`},
+	}
+	for _, tt := range tests {
+		if _, err := p.Chat(context.Background(), ChatParams{Model: "gpt-4o", System: tt.system, Messages: messages}); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := json.Marshal((*bodies)[len(*bodies)-1]["messages"])
+		var want any
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		wantJSON, _ := json.Marshal(want)
+		if string(got) != string(wantJSON) {
+			t.Errorf("system %q: messages =\n%s\nwant\n%s", tt.system, got, wantJSON)
+		}
+	}
+}
+
+func TestOpenAIToolCalls(t *testing.T) {
+	srv, _ := openAITestServer(t, `{
+		"choices": [{
+			"message": {"role": "assistant", "content": "Checking.", "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "ls", "arguments": ""}},
+				{"id": "call_2", "type": "function", "function": {"name": "read", "arguments": "{\"path\":\"a\"}"}}
+			]},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 100, "completion_tokens": 20, "prompt_tokens_details": {"cached_tokens": 60}}
+	}`)
+	p := NewOpenAIProvider(OpenAIConfig{APIBase: srv.URL})
+
+	resp, err := p.Chat(context.Background(), ChatParams{Model: "gpt-4o", Messages: []Message{NewUserMessage(NewTextBlock("hi"))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ContentBlock{
+		NewTextBlock("Checking."),
+		NewToolUseBlock("call_1", "ls", json.RawMessage(`{}`)),
+		NewToolUseBlock("call_2", "read", json.RawMessage(`{"path":"a"}`)),
+	}
+	got, _ := json.Marshal(resp.Content)
+	wantJSON, _ := json.Marshal(want)
+	if string(got) != string(wantJSON) {
+		t.Errorf("Content =\n%s\nwant\n%s", got, wantJSON)
+	}
+	if resp.StopReason != "tool_use" {
+		t.Errorf("StopReason = %q, want tool_use", resp.StopReason)
+	}
+	if resp.Usage != (Usage{InputTokens: 100, OutputTokens: 20, CachedTokens: 60}) {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+	if resp.RequestID != "req_1" {
+		t.Errorf("RequestID = %q", resp.RequestID)
+	}
+}
+
+func TestOpenAIStreamToolCalls(t *testing.T) {
+	srv, bodies := openAITestServer(t, strings.Join([]string{
+		`data: {"choices": [{"delta": {"content": "Checking."}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_1", "function": {"name": "ls"}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 1, "id": "call_2", "function": {"name": "read", "arguments": "{\"pa"}}]}}]}`,
+		`data: {"choices": [{"delta": {"tool_calls": [{"index": 1, "function": {"arguments": "th\":\"a\"}"}}]}, "finish_reason": "tool_calls"}]}`,
+		`data: {"choices": [], "usage": {"prompt_tokens": 10, "completion_tokens": 5}}`,
+		`data: [DONE]`,
+	}, "\n\n"))
+	p := NewOpenAIProvider(OpenAIConfig{APIBase: srv.URL})
+
+	var events []string
+	resp, err := p.ChatStream(context.Background(), ChatParams{Model: "gpt-4o"}, func(e StreamEvent) {
+		events = append(events, e.Type+":"+e.Text+e.ToolName)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(events, " "), `text:Checking. tool_use:ls tool_use:read tool_input:{"pa tool_input:th":"a"}`; got != want {
+		t.Errorf("events = %s\nwant %s", got, want)
+	}
+	if len(resp.Content) != 3 || string(resp.Content[1].Input) != `{}` || string(resp.Content[2].Input) != `{"path":"a"}` {
+		t.Errorf("Content = %+v", resp.Content)
+	}
+	if resp.StopReason != "tool_use" || resp.Usage.InputTokens != 10 {
+		t.Errorf("StopReason = %q, Usage = %+v", resp.StopReason, resp.Usage)
+	}
+	// Only api.openai.com is asked for stream usage
+	if _, ok := (*bodies)[0]["stream_options"]; ok {
+		t.Error("a gateway was sent stream_options")
+	}
+}
+
+func TestOpenAIErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{http.StatusTooManyRequests, `{"error": {"message": "Rate limit reached"}}`, "Rate limit reached"},
+		{http.StatusBadGateway, `upstream unavailable`, "upstream unavailable"},
+		{http.StatusOK, `{"choices": []}`, "response has no choices"},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			io.WriteString(w, tt.body)
+		}))
+		p := NewOpenAIProvider(OpenAIConfig{APIBase: srv.URL})
+		_, err := p.Chat(context.Background(), ChatParams{Model: "gpt-4o"})
+		srv.Close()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("status %d: err = %v, want %q", tt.status, err, tt.want)
+		}
+	}
+}
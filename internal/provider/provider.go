@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"time"
 )
 
 // Provider is the interface that all LLM providers must implement.
@@ -16,6 +17,11 @@ type ChatParams struct {
 	Messages  []Message
 	Tools     []ToolDefinition
 	MaxTokens int
+
+	// Sampling; nil/empty = the provider's default
+	Temperature   *float64
+	TopP          *float64
+	StopSequences []string
 }
 
 type Message struct {
@@ -41,8 +47,29 @@ type ContentBlock struct {
 }
 
 type ChatResponse struct {
-	Content    []ContentBlock
-	StopReason string // "end_turn", "tool_use", "max_tokens"
+	Content      []ContentBlock
+	StopReason   string        // "end_turn", "tool_use", "max_tokens", "stop_sequence"
+	StopSequence string        // the stop sequence that ended the response, when the API says
+	Usage        Usage         // as reported by the API; zero when it reports none
+	Model        string        // the model that answered, when a FallbackProvider chose it; "" = as requested
+	RequestID    string        // the API's ID for the request, for support; "" when it gives none
+	Latency      time.Duration // from sending the request to the whole response, this attempt only
+}
+
+// Usage is the token count of one call.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"` // the whole prompt, cached part included
+	OutputTokens int `json:"output_tokens"`
+	CachedTokens int `json:"cached_tokens,omitempty"` // input tokens read from the prompt cache
+}
+
+// Add returns the sum of u and v.
+func (u Usage) Add(v Usage) Usage {
+	return Usage{
+		InputTokens:  u.InputTokens + v.InputTokens,
+		OutputTokens: u.OutputTokens + v.OutputTokens,
+		CachedTokens: u.CachedTokens + v.CachedTokens,
+	}
 }
 
 type ToolDefinition struct {
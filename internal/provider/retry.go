@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Retry defaults; config.json can override both.
+const (
+	DefaultRetryAttempts = 4
+	DefaultRetryMaxWait  = 30 * time.Second
+)
+
+// retryBaseWait is the wait before the first retry; it doubles after each.
+const retryBaseWait = time.Second
+
+// StatusError is an HTTP error response from a provider API.
+type StatusError struct {
+	API        string // "openai", "ollama"
+	StatusCode int
+	Status     string        // e.g. "429 Too Many Requests"
+	Message    string        // detail from the response body, if any
+	RetryAfter time.Duration // from the Retry-After header; 0 = not sent
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s API error: %s", e.API, e.Status)
+	}
+	return fmt.Sprintf("%s API error: %s: %s", e.API, e.Status, e.Message)
+}
+
+// newStatusError reports resp, whose body the caller has already read for
+// msg.
+func newStatusError(api string, resp *http.Response, msg string) *StatusError {
+	return &StatusError{
+		API:        api,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    msg,
+		RetryAfter: retryAfter(resp.Header),
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds. The HTTP-date
+// form isn't used by the APIs we talk to.
+func retryAfter(h http.Header) time.Duration {
+	secs, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// retryableStatus reports whether a response with this status is worth
+// sending again: rate limits, timeouts, overload (Anthropic's 529), and
+// server errors. Anything else (401, 400 invalid request, 404 unknown
+// model) fails the same way every time.
+func retryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusConflict ||
+		code == http.StatusTooManyRequests || code >= 500
+}
+
+// Retryable reports whether err is a transient failure: a retryable
+// status, a timeout or dropped connection, or an overload reported in the
+// middle of a stream. It also returns the wait the API asked for, if any.
+func Retryable(err error) (bool, time.Duration) {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return retryableStatus(se.StatusCode), se.RetryAfter
+	}
+	var ae *anthropic.Error
+	if errors.As(err, &ae) {
+		var wait time.Duration
+		if ae.Response != nil {
+			wait = retryAfter(ae.Response.Header)
+		}
+		return retryableStatus(ae.StatusCode), wait
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true, 0
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true, 0
+	}
+	// Anthropic streams report overload as an error event, not a status.
+	msg := err.Error()
+	if strings.Contains(msg, "overloaded_error") || strings.Contains(msg, `"api_error"`) {
+		return true, 0
+	}
+	return false, 0
+}
+
+// RetryConfig controls RetryProvider.
+type RetryConfig struct {
+	Attempts int           // tries per request, including the first; < 2 = no retries
+	MaxWait  time.Duration // longest wait between tries
+
+	// OnRetry is called before each wait; nil = wait silently.
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+// RetryProvider retries requests that fail with a transient error,
+// waiting 1s, 2s, 4s, ... (with jitter, capped at MaxWait) between tries,
+// or as long as the API's Retry-After asks if that's longer. A Retry-After
+// beyond MaxWait ends the retries; the error is returned right away.
+type RetryProvider struct {
+	p   Provider
+	cfg RetryConfig
+}
+
+// NewRetry wraps p so transient errors are retried.
+func NewRetry(p Provider, cfg RetryConfig) *RetryProvider {
+	return &RetryProvider{p: p, cfg: cfg}
+}
+
+func (r *RetryProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	return r.retry(ctx, func() (*ChatResponse, bool, error) {
+		resp, err := r.p.Chat(ctx, params)
+		return resp, true, err
+	})
+}
+
+// ChatStream retries only while nothing has been streamed: once events
+// have been shown, sending the request again would show them twice.
+func (r *RetryProvider) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	return r.retry(ctx, func() (*ChatResponse, bool, error) {
+		streamed := false
+		resp, err := ChatStream(ctx, r.p, params, func(e StreamEvent) {
+			streamed = true
+			onEvent(e)
+		})
+		return resp, !streamed, err
+	})
+}
+
+// retry calls try until it succeeds, fails with an error that isn't
+// retryable (or after it already produced output), or runs out of
+// attempts.
+func (r *RetryProvider) retry(ctx context.Context, try func() (*ChatResponse, bool, error)) (*ChatResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, canRetry, err := try()
+		if err == nil {
+			return resp, nil
+		}
+		ok, wait := Retryable(err)
+		if !ok || !canRetry || attempt >= r.cfg.Attempts || ctx.Err() != nil {
+			return nil, err
+		}
+		if r.cfg.MaxWait > 0 && wait > r.cfg.MaxWait {
+			return nil, err
+		}
+		wait = max(wait, r.backoff(attempt))
+		if r.cfg.OnRetry != nil {
+			r.cfg.OnRetry(attempt, wait, err)
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// backoff is the wait after the given failed attempt: retryBaseWait
+// doubled per attempt, plus up to 25% jitter so parallel runs (--map)
+// don't retry in lockstep, capped at MaxWait.
+func (r *RetryProvider) backoff(attempt int) time.Duration {
+	wait := retryBaseWait << min(attempt-1, 10)
+	wait += time.Duration(rand.Int64N(int64(wait)/4 + 1))
+	if r.cfg.MaxWait > 0 && wait > r.cfg.MaxWait {
+		wait = r.cfg.MaxWait
+	}
+	return wait
+}
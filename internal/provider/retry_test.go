@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// funcProvider is a Provider that calls itself.
+type funcProvider func(ctx context.Context, params ChatParams) (*ChatResponse, error)
+
+func (f funcProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	return f(ctx, params)
+}
+
+func statusErr(code int, retryAfter time.Duration) error {
+	return &StatusError{API: "openai", StatusCode: code, Status: http.StatusText(code), RetryAfter: retryAfter}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+		wait time.Duration
+	}{
+		{"429", statusErr(429, 0), true, 0},
+		{"429 with Retry-After", statusErr(429, 7*time.Second), true, 7 * time.Second},
+		{"408", statusErr(408, 0), true, 0},
+		{"409", statusErr(409, 0), true, 0},
+		{"500", statusErr(500, 0), true, 0},
+		{"529 overloaded", statusErr(529, 0), true, 0},
+		{"wrapped 503", fmt.Errorf("calling model: %w", statusErr(503, 2*time.Second)), true, 2 * time.Second},
+		{"400", statusErr(400, 0), false, 0},
+		{"401", statusErr(401, 0), false, 0},
+		{"404", statusErr(404, 0), false, 0},
+		{"400 ignores Retry-After", statusErr(400, 5*time.Second), false, 5 * time.Second},
+		{"cancelled", context.Canceled, false, 0},
+		{"cancelled mid-read", fmt.Errorf("reading: %w", errors.Join(context.Canceled, io.ErrUnexpectedEOF)), false, 0},
+		{"timeout", fmt.Errorf("openai API error: %w", os.ErrDeadlineExceeded), true, 0},
+		{"unexpected EOF", io.ErrUnexpectedEOF, true, 0},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true, 0},
+		{"broken pipe", syscall.EPIPE, true, 0},
+		{"overloaded event", errors.New(`stream error: {"type":"overloaded_error","message":"Overloaded"}`), true, 0},
+		{"API error event", errors.New(`stream error: {"type":"api_error"}`), true, 0},
+		{"other", errors.New("decoding response: invalid character"), false, 0},
+	}
+	for _, tt := range tests {
+		ok, wait := Retryable(tt.err)
+		if ok != tt.want || wait != tt.wait {
+			t.Errorf("%s: Retryable = %v, %v; want %v, %v", tt.name, ok, wait, tt.want, tt.wait)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"3", 3 * time.Second},
+		{"0", 0},
+		{"-1", 0},
+		{"Wed, 21 Oct 2026 07:28:00 GMT", 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		h.Set("Retry-After", tt.header)
+		if got := retryAfter(h); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		maxWait  time.Duration
+		min, max time.Duration
+	}{
+		{1, 0, time.Second, 1250 * time.Millisecond},
+		{2, 0, 2 * time.Second, 2500 * time.Millisecond},
+		{3, 0, 4 * time.Second, 5 * time.Second},
+		{3, 3 * time.Second, 3 * time.Second, 3 * time.Second},
+		{50, 0, 1024 * time.Second, 1280 * time.Second},
+	}
+	for _, tt := range tests {
+		r := NewRetry(nil, RetryConfig{MaxWait: tt.maxWait})
+		for range 20 {
+			if got := r.backoff(tt.attempt); got < tt.min || got > tt.max {
+				t.Errorf("backoff(%d) with MaxWait %v = %v, want %v to %v", tt.attempt, tt.maxWait, got, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestRetryProvider(t *testing.T) {
+	ok := &ChatResponse{StopReason: "end_turn"}
+	tests := []struct {
+		name     string
+		attempts int
+		errs     []error // returned by each call in turn; then success
+		calls    int
+		wantErr  bool
+	}{
+		{"success", 4, nil, 1, false},
+		{"retried until success", 4, []error{statusErr(529, 0), io.ErrUnexpectedEOF}, 3, false},
+		{"attempt cap", 3, []error{statusErr(500, 0), statusErr(500, 0), statusErr(500, 0), statusErr(500, 0)}, 3, true},
+		{"no retries", 1, []error{statusErr(500, 0)}, 1, true},
+		{"not retryable", 4, []error{statusErr(401, 0)}, 1, true},
+		{"Retry-After beyond MaxWait", 4, []error{statusErr(429, time.Hour)}, 1, true},
+	}
+	for _, tt := range tests {
+		calls := 0
+		p := funcProvider(func(context.Context, ChatParams) (*ChatResponse, error) {
+			calls++
+			if calls <= len(tt.errs) {
+				return nil, tt.errs[calls-1]
+			}
+			return ok, nil
+		})
+		var retries []int
+		r := NewRetry(p, RetryConfig{Attempts: tt.attempts, MaxWait: time.Millisecond, OnRetry: func(attempt int, wait time.Duration, err error) {
+			retries = append(retries, attempt)
+		}})
+		resp, err := r.Chat(context.Background(), ChatParams{})
+		if (err != nil) != tt.wantErr || (err == nil && resp != ok) {
+			t.Errorf("%s: Chat = %v, %v", tt.name, resp, err)
+		}
+		if calls != tt.calls || len(retries) != tt.calls-1 {
+			t.Errorf("%s: %d calls and %d retries, want %d calls", tt.name, calls, len(retries), tt.calls)
+		}
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	p := funcProvider(func(context.Context, ChatParams) (*ChatResponse, error) {
+		calls++
+		return nil, statusErr(503, 0)
+	})
+	// Cancelled while waiting to retry: the last error is returned at once
+	r := NewRetry(p, RetryConfig{Attempts: 4, MaxWait: time.Hour, OnRetry: func(int, time.Duration, error) { cancel() }})
+	started := time.Now()
+	if _, err := r.Chat(ctx, ChatParams{}); err == nil || calls != 1 {
+		t.Errorf("Chat = %v after %d calls, want the 503 after 1", err, calls)
+	}
+	if elapsed := time.Since(started); elapsed > 500*time.Millisecond {
+		t.Errorf("returned after %v; the wait wasn't cut short", elapsed)
+	}
+
+	// Already cancelled: no retry
+	calls = 0
+	r = NewRetry(p, RetryConfig{Attempts: 4, MaxWait: time.Millisecond})
+	if _, err := r.Chat(ctx, ChatParams{}); err == nil || calls != 1 {
+		t.Errorf("Chat = %v after %d calls, want the 503 after 1", err, calls)
+	}
+}
+
+func TestRetryStreamed(t *testing.T) {
+	calls := 0
+	p := &streamProvider{stream: func(onEvent func(StreamEvent)) (*ChatResponse, error) {
+		calls++
+		onEvent(StreamEvent{Type: "text", Text: "partial"})
+		return nil, io.ErrUnexpectedEOF
+	}}
+	r := NewRetry(p, RetryConfig{Attempts: 4, MaxWait: time.Millisecond})
+	if _, err := r.ChatStream(context.Background(), ChatParams{}, func(StreamEvent) {}); err == nil || calls != 1 {
+		t.Errorf("ChatStream = %v after %d calls; a stream that showed output was retried", err, calls)
+	}
+}
+
+// streamProvider is a Streamer whose stream calls itself.
+type streamProvider struct {
+	stream func(onEvent func(StreamEvent)) (*ChatResponse, error)
+}
+
+func (p *streamProvider) Chat(ctx context.Context, params ChatParams) (*ChatResponse, error) {
+	return p.stream(func(StreamEvent) {})
+}
+
+func (p *streamProvider) ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	return p.stream(onEvent)
+}
@@ -0,0 +1,45 @@
+package provider
+
+import "context"
+
+// StreamEvent is a piece of a response, delivered as the model generates it.
+type StreamEvent struct {
+	Type     string // "text", "tool_use", or "tool_input"
+	Text     string // text delta ("text") or a fragment of the tool call's JSON input ("tool_input")
+	ToolName string // tool being called ("tool_use"); its input follows as "tool_input" events
+}
+
+// Streamer is implemented by providers that can stream responses.
+// ChatStream calls onEvent for each piece as it arrives, in order, and
+// returns the same complete response Chat would.
+type Streamer interface {
+	ChatStream(ctx context.Context, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error)
+}
+
+// ChatStream streams a response from p if it implements Streamer. Other
+// providers are called with Chat and their response is replayed as
+// events, so callers render both the same way.
+func ChatStream(ctx context.Context, p Provider, params ChatParams, onEvent func(StreamEvent)) (*ChatResponse, error) {
+	if s, ok := p.(Streamer); ok {
+		return s.ChatStream(ctx, params, onEvent)
+	}
+	resp, err := p.Chat(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	replay(resp, onEvent)
+	return resp, nil
+}
+
+// replay reports a complete response as events.
+func replay(resp *ChatResponse, onEvent func(StreamEvent)) {
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			onEvent(StreamEvent{Type: "text", Text: block.Text})
+		case "tool_use":
+			onEvent(StreamEvent{Type: "tool_use", ToolName: block.ToolName})
+			onEvent(StreamEvent{Type: "tool_input", Text: string(block.Input)})
+		}
+	}
+}
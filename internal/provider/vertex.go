@@ -0,0 +1,296 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// vertexVersion is the anthropic_version Vertex AI expects in the body.
+const vertexVersion = "vertex-2023-10-16"
+
+const (
+	googleTokenURL    = "https://oauth2.googleapis.com/token"
+	googleScope       = "https://www.googleapis.com/auth/cloud-platform"
+	gceMetadataTokens = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// NewVertexProvider returns a provider that calls Claude through GCP Vertex
+// AI. Region falls back to CLOUD_ML_REGION (default "us-east5"); project
+// falls back to ANTHROPIC_VERTEX_PROJECT_ID, GOOGLE_CLOUD_PROJECT, then the
+// credentials file. Requests use Application Default Credentials: the file
+// named by GOOGLE_APPLICATION_CREDENTIALS, the gcloud ADC file, or the GCE
+// metadata server.
+func NewVertexProvider(region, project string) (*AnthropicProvider, error) {
+	if region == "" {
+		region = os.Getenv("CLOUD_ML_REGION")
+	}
+	if region == "" {
+		region = "us-east5"
+	}
+
+	ts, err := newGoogleTokenSource()
+	if err != nil {
+		return nil, fmt.Errorf("vertex: %w", err)
+	}
+	for _, v := range []string{project, os.Getenv("ANTHROPIC_VERTEX_PROJECT_ID"), os.Getenv("GOOGLE_CLOUD_PROJECT"), ts.project} {
+		if v != "" {
+			project = v
+			break
+		}
+	}
+	if project == "" {
+		return nil, errors.New("vertex: no project (set \"project\" in the agent config or ANTHROPIC_VERTEX_PROJECT_ID)")
+	}
+
+	host := region + "-aiplatform.googleapis.com"
+	if region == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+
+	middleware := func(r *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+		_, err := rewriteBody(r, func(fields map[string]json.RawMessage) error {
+			model, err := takeModel(fields)
+			if err != nil {
+				return err
+			}
+			fields["anthropic_version"] = json.RawMessage(`"` + vertexVersion + `"`)
+			if r.URL.Path == "/v1/messages" {
+				method := "rawPredict"
+				if string(fields["stream"]) == "true" {
+					method = "streamRawPredict"
+				}
+				r.URL.Path = fmt.Sprintf("/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s", project, region, VertexModelID(model), method)
+				r.URL.RawPath = ""
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("vertex: %w", err)
+		}
+
+		token, err := ts.token(r.Context())
+		if err != nil {
+			return nil, fmt.Errorf("vertex: %w", err)
+		}
+		// Never forward an Anthropic key to Google.
+		r.Header.Del("X-Api-Key")
+		r.Header.Set("Authorization", "Bearer "+token)
+		return next(r)
+	}
+
+	p := newAnthropicProvider(
+		option.WithBaseURL("https://"+host+"/"),
+		option.WithMiddleware(middleware),
+	)
+	p.stream = true
+	return p, nil
+}
+
+var vertexDateSuffix = regexp.MustCompile(`-(\d{8})$`)
+
+// VertexModelID maps an Anthropic model name to its Vertex AI model ID
+// ("claude-sonnet-4-5-20250929" → "claude-sonnet-4-5@20250929"). Names that
+// already carry an "@" version pass through unchanged.
+func VertexModelID(model string) string {
+	if strings.Contains(model, "@") {
+		return model
+	}
+	return vertexDateSuffix.ReplaceAllString(model, "@$1")
+}
+
+// googleCredentials is the subset of an ADC file this package understands.
+type googleCredentials struct {
+	Type           string `json:"type"`
+	ProjectID      string `json:"project_id"`
+	QuotaProjectID string `json:"quota_project_id"`
+
+	// authorized_user
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+
+	// service_account
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// googleTokenSource fetches OAuth access tokens and caches them in memory
+// until shortly before they expire.
+type googleTokenSource struct {
+	creds   *googleCredentials // nil = GCE metadata server
+	project string
+
+	mu      sync.Mutex
+	current string
+	expiry  time.Time
+}
+
+func newGoogleTokenSource() (*googleTokenSource, error) {
+	path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	if path == "" {
+		path = gcloudADCPath()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") != "" {
+			return nil, fmt.Errorf("reading credentials: %w", err)
+		}
+		// No ADC file: assume we're on GCE and use the metadata server.
+		return &googleTokenSource{}, nil
+	}
+
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("parsing credentials %s: %w", path, err)
+	}
+	switch creds.Type {
+	case "authorized_user", "service_account":
+	default:
+		return nil, fmt.Errorf("unsupported credentials type %q in %s", creds.Type, path)
+	}
+
+	project := creds.ProjectID
+	if project == "" {
+		project = creds.QuotaProjectID
+	}
+	return &googleTokenSource{creds: &creds, project: project}, nil
+}
+
+func gcloudADCPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+func (ts *googleTokenSource) token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.current != "" && time.Until(ts.expiry) > time.Minute {
+		return ts.current, nil
+	}
+
+	var req *http.Request
+	var err error
+	switch {
+	case ts.creds == nil:
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, gceMetadataTokens, nil)
+		if err == nil {
+			req.Header.Set("Metadata-Flavor", "Google")
+		}
+	case ts.creds.Type == "authorized_user":
+		req, err = newTokenRequest(ctx, googleTokenURL, url.Values{
+			"grant_type":    {"refresh_token"},
+			"client_id":     {ts.creds.ClientID},
+			"client_secret": {ts.creds.ClientSecret},
+			"refresh_token": {ts.creds.RefreshToken},
+		})
+	default:
+		tokenURL := ts.creds.TokenURI
+		if tokenURL == "" {
+			tokenURL = googleTokenURL
+		}
+		var assertion string
+		if assertion, err = signJWT(ts.creds, tokenURL); err == nil {
+			req, err = newTokenRequest(ctx, tokenURL, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching access token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching access token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tok); err != nil || tok.AccessToken == "" {
+		return "", errors.New("fetching access token: malformed response")
+	}
+	ts.current = tok.AccessToken
+	ts.expiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return ts.current, nil
+}
+
+func newTokenRequest(ctx context.Context, tokenURL string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// signJWT builds the RS256-signed assertion a service account exchanges for
+// an access token.
+func signJWT(creds *googleCredentials, audience string) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errors.New("service account private key is not PEM")
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("service account private key is not RSA")
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", fmt.Errorf("parsing service account private key: %w", err)
+	}
+
+	now := time.Now().Unix()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   creds.ClientEmail,
+		"scope": googleScope,
+		"aud":   audience,
+		"iat":   now,
+		"exp":   now + 3600,
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
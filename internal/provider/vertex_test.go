@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVertexModelID(t *testing.T) {
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"claude-sonnet-4-5-20250929", "claude-sonnet-4-5@20250929"},
+		{"claude-3-5-haiku-20241022", "claude-3-5-haiku@20241022"},
+		{"claude-sonnet-4-5@20250929", "claude-sonnet-4-5@20250929"},
+		{"claude-sonnet-4-5", "claude-sonnet-4-5"},
+		{"claude-opus-4-1-2025", "claude-opus-4-1-2025"},
+	}
+	for _, tt := range tests {
+		if got := VertexModelID(tt.model); got != tt.want {
+			t.Errorf("VertexModelID(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+// testServiceAccount returns service account credentials with a new RSA
+// key, PKCS#8 or PKCS#1 encoded.
+func testServiceAccount(t *testing.T, pkcs8 bool, tokenURI string) (*googleCredentials, *rsa.PublicKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if pkcs8 {
+		der, _ := x509.MarshalPKCS8PrivateKey(key)
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	return &googleCredentials{
+		Type:        "service_account",
+		ClientEmail: "runner@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(block)),
+		TokenURI:    tokenURI,
+	}, &key.PublicKey
+}
+
+// verifyJWT checks an RS256 JWT's signature and returns its claims.
+func verifyJWT(t *testing.T, jwt string, pub *rsa.PublicKey) map[string]any {
+	t.Helper()
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts", len(parts))
+	}
+	enc := base64.RawURLEncoding
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig); err != nil {
+		t.Fatalf("JWT signature: %v", err)
+	}
+	var header map[string]string
+	headerJSON, _ := enc.DecodeString(parts[0])
+	json.Unmarshal(headerJSON, &header)
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("JWT header = %v", header)
+	}
+	var claims map[string]any
+	claimsJSON, _ := enc.DecodeString(parts[1])
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestSignJWT(t *testing.T) {
+	for _, pkcs8 := range []bool{true, false} {
+		creds, pub := testServiceAccount(t, pkcs8, "")
+		jwt, err := signJWT(creds, googleTokenURL)
+		if err != nil {
+			t.Fatalf("pkcs8=%v: %v", pkcs8, err)
+		}
+		claims := verifyJWT(t, jwt, pub)
+		if claims["iss"] != creds.ClientEmail || claims["aud"] != googleTokenURL || claims["scope"] != googleScope {
+			t.Errorf("pkcs8=%v: claims = %v", pkcs8, claims)
+		}
+		if exp, iat := claims["exp"].(float64), claims["iat"].(float64); exp-iat != 3600 {
+			t.Errorf("pkcs8=%v: token lifetime %v, want 3600", pkcs8, exp-iat)
+		}
+	}
+
+	if _, err := signJWT(&googleCredentials{PrivateKey: "not a key"}, googleTokenURL); err == nil {
+		t.Error("signJWT accepted a key that isn't PEM")
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	var creds *googleCredentials
+	var pub *rsa.PublicKey
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			http.Error(w, "bad grant", http.StatusBadRequest)
+			return
+		}
+		if claims := verifyJWT(t, r.Form.Get("assertion"), pub); claims["aud"] != creds.TokenURI {
+			t.Errorf("aud = %v, want the token URI", claims["aud"])
+		}
+		w.Write([]byte(`{"access_token": "ya29.token", "expires_in": 3600}`))
+	}))
+	defer srv.Close()
+	creds, pub = testServiceAccount(t, true, srv.URL+"/token")
+
+	ts := &googleTokenSource{creds: creds}
+	for range 2 {
+		token, err := ts.token(context.Background())
+		if err != nil || token != "ya29.token" {
+			t.Fatalf("token = %q, %v", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("%d token requests, want 1 (the second is cached)", requests)
+	}
+}
+
+func TestNewGoogleTokenSource(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0600)
+		return path
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", write("user.json", `{"type": "authorized_user", "quota_project_id": "quota-project", "refresh_token": "r"}`))
+	ts, err := newGoogleTokenSource()
+	if err != nil || ts.project != "quota-project" || ts.creds.RefreshToken != "r" {
+		t.Errorf("authorized_user: %+v, %v", ts, err)
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", write("sa.json", `{"type": "service_account", "project_id": "sa-project", "quota_project_id": "other"}`))
+	if ts, err := newGoogleTokenSource(); err != nil || ts.project != "sa-project" {
+		t.Errorf("service_account: %+v, %v", ts, err)
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", write("ext.json", `{"type": "external_account"}`))
+	if _, err := newGoogleTokenSource(); err == nil || !strings.Contains(err.Error(), "unsupported credentials type") {
+		t.Errorf("external_account: err = %v", err)
+	}
+
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(dir, "missing.json"))
+	if _, err := newGoogleTokenSource(); err == nil {
+		t.Error("a missing GOOGLE_APPLICATION_CREDENTIALS file should be an error")
+	}
+}
@@ -0,0 +1,293 @@
+// Package publish packages a converged thought to share: its script,
+// memory.js, thought.lock, and the permissions it declares, in a gzipped
+// tar archive signed with the publisher's ed25519 key. Policies, secrets,
+// memories, and the workspace never go in: the permissions are a list of
+// what the thought was allowed to use, for whoever installs it to review,
+// not a policy that grants anything.
+//
+// Before writing, Scan looks through the files for embedded secrets (API
+// key formats, private keys, and the values of secret-looking environment
+// variables), so a key the agent hardcoded into memory.js doesn't ship
+// with it.
+package publish
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/redact"
+)
+
+// Version is the manifest format version.
+const Version = 1
+
+// Package is what a published thought contains.
+type Package struct {
+	Name        string
+	Script      string // file name of the script
+	ScriptData  []byte
+	MemoryJS    []byte
+	Lock        []byte // thought.lock, or nil
+	Permissions Permissions
+}
+
+// Manifest is manifest.json: the archive's files with their SHA-256, and
+// the key that signed it.
+type Manifest struct {
+	Version   int               `json:"version"`
+	Name      string            `json:"name"`
+	Created   time.Time         `json:"created"`
+	Files     map[string]string `json:"files"`      // name -> sha256
+	PublicKey string            `json:"public_key"` // base64 ed25519
+}
+
+// Permissions is permissions.json: what the thought was allowed to use
+// when it was published. Paths under the publisher's home are written as
+// ~/...
+type Permissions struct {
+	Paths   []PathPermission `json:"paths,omitempty"`
+	Env     []string         `json:"env,omitempty"`
+	Hosts   []string         `json:"hosts,omitempty"`
+	Listen  []string         `json:"listen,omitempty"`
+	Tools   []string         `json:"tools,omitempty"`
+	Secrets []string         `json:"secrets,omitempty"` // names only
+	APIs    []string         `json:"apis,omitempty"`    // from thought.lock
+}
+
+// PathPermission is a path the thought may use, with its r/w/d mode.
+type PathPermission struct {
+	Path string `json:"path"`
+	Mode string `json:"mode"`
+}
+
+// Declare returns the permissions a thought policy allows. Only allow
+// entries a person or the agent's prompts added count: bootstrap defaults
+// (the thought's own workspace and memories), managed entries, and
+// time-limited grants are left out, as `thought policy export` leaves
+// them out. Denies and prompts aren't permissions the thought needs.
+func Declare(p *approval.Policy, home string) Permissions {
+	p = p.Export(home)
+	var perms Permissions
+	for _, e := range p.Paths.Entries {
+		if e.Approval == approval.ApprovalAllow {
+			perms.Paths = append(perms.Paths, PathPermission{Path: e.Path, Mode: e.Mode})
+		}
+	}
+	for _, e := range p.Env.Entries {
+		if e.Approval == approval.ApprovalAllow {
+			perms.Env = append(perms.Env, e.Name)
+		}
+	}
+	for _, e := range p.Net.Hosts.Entries {
+		if e.Approval == approval.ApprovalAllow {
+			perms.Hosts = append(perms.Hosts, e.Host)
+		}
+	}
+	for _, e := range p.Net.Listen.Entries {
+		if e.Approval == approval.ApprovalAllow {
+			perms.Listen = append(perms.Listen, e.Port)
+		}
+	}
+	for _, e := range p.Tools.Entries {
+		if e.Approval == approval.ApprovalAllow {
+			perms.Tools = append(perms.Tools, e.Tool)
+		}
+	}
+	for _, e := range p.Secrets.Entries {
+		if e.Approval == approval.ApprovalAllow {
+			perms.Secrets = append(perms.Secrets, e.Name)
+		}
+	}
+	return perms
+}
+
+// files returns the archive's files, in order.
+func (pkg *Package) files() ([][2]string, error) {
+	perms, err := json.MarshalIndent(pkg.Permissions, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files := [][2]string{
+		{pkg.Script, string(pkg.ScriptData)},
+		{"memory.js", string(pkg.MemoryJS)},
+	}
+	if pkg.Lock != nil {
+		files = append(files, [2]string{"thought.lock", string(pkg.Lock)})
+	}
+	return append(files, [2]string{"permissions.json", string(perms) + "\n"}), nil
+}
+
+// Write writes pkg as a gzipped tar archive to w, with every file under a
+// top-level directory named after the thought and manifest.json last, and
+// returns the archive's detached signature: base64 ed25519 over the
+// archive's bytes, the format managed policies use.
+func Write(w io.Writer, pkg *Package, key ed25519.PrivateKey) (sig []byte, err error) {
+	files, err := pkg.files()
+	if err != nil {
+		return nil, err
+	}
+	m := Manifest{
+		Version:   Version,
+		Name:      pkg.Name,
+		Created:   time.Now().UTC(),
+		Files:     make(map[string]string),
+		PublicKey: PublicKey(key),
+	}
+	for _, f := range files {
+		h := sha256.Sum256([]byte(f[1]))
+		m.Files[f[0]] = hex.EncodeToString(h[:])
+	}
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, [2]string{"manifest.json", string(manifest) + "\n"})
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, f := range files {
+		hdr := &tar.Header{Name: pkg.Name + "/" + f[0], Mode: 0644, Size: int64(len(f[1])), ModTime: m.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(tw, f[1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, buf.Bytes())) + "\n"), nil
+}
+
+// PublicKey returns key's public half in base64, as manifests and
+// managed_policy_key write it.
+func PublicKey(key ed25519.PrivateKey) string {
+	return base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+// Verify checks an archive's detached signature against key.
+func Verify(archive, sig []byte, key ed25519.PublicKey) error {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || !ed25519.Verify(key, archive, raw) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// KeyPath returns where the publishing key is kept under the
+// thinkingscript home.
+func KeyPath(home string) string {
+	return filepath.Join(home, "keys", "publish.key")
+}
+
+// LoadKey reads the publishing key at path, generating one the first time.
+// It reports whether the key was created.
+func LoadKey(path string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, false, fmt.Errorf("%s: not a base64 ed25519 seed", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, err
+	}
+	seed := base64.StdEncoding.EncodeToString(key.Seed()) + "\n"
+	if err := os.WriteFile(path, []byte(seed), 0600); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// Finding is a possible secret Scan found.
+type Finding struct {
+	File string
+	Line int
+	What string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.What)
+}
+
+// secretPatterns are the secret formats Scan looks for.
+var secretPatterns = []struct {
+	what string
+	re   *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Anthropic API key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"OpenAI API key", regexp.MustCompile(`\bsk-(proj-)?[A-Za-z0-9]{20,}`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"hardcoded credential", regexp.MustCompile(`(?i)\b[a-z0-9_]*(api_?key|token|secret|password)[a-z0-9_]*["']?\s*[:=]\s*["'][^"'\s{}$]{12,}["']`)},
+}
+
+// Scan looks through the package's files for embedded secrets: known key
+// and token formats, hardcoded credentials, and the values of environment
+// variables with secret-looking names (values of redact.MinLen or more).
+func (pkg *Package) Scan() []Finding {
+	var env [][2]string // name, value
+	for _, kv := range os.Environ() {
+		if name, value, _ := strings.Cut(kv, "="); redact.SecretName(name) && len(value) >= redact.MinLen {
+			env = append(env, [2]string{name, value})
+		}
+	}
+
+	var found []Finding
+	files, _ := pkg.files()
+	for _, f := range files {
+		for i, line := range strings.Split(f[1], "\n") {
+			for _, p := range secretPatterns {
+				if p.re.MatchString(line) {
+					found = append(found, Finding{File: f[0], Line: i + 1, What: p.what})
+					break
+				}
+			}
+			for _, e := range env {
+				if strings.Contains(line, e[1]) {
+					found = append(found, Finding{File: f[0], Line: i + 1, What: "value of $" + e[0]})
+				}
+			}
+		}
+	}
+	return slices.CompactFunc(found, func(a, b Finding) bool { return a == b })
+}
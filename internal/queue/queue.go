@@ -0,0 +1,322 @@
+// Package queue is a local run queue: `thought queue add` stores items
+// under ~/.thinkingscript/queue/ and `thought queue work` runs them one at
+// a time with think, writing each item's output to its own log. Items are
+// plain files, so the queue survives restarts.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/flock"
+)
+
+// Item statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Item is one queued run. Each item lives in its own directory under the
+// queue dir: item.json plus the run's combined stdout and stderr as
+// output.log.
+type Item struct {
+	ID       string     `json:"id"`
+	Script   string     `json:"script"` // absolute path or URL
+	Args     []string   `json:"args,omitempty"`
+	Flags    []string   `json:"flags,omitempty"` // think flags, e.g. --allow <path>
+	Cwd      string     `json:"cwd"`
+	Status   string     `json:"status"`
+	Added    time.Time  `json:"added"`
+	Started  *time.Time `json:"started,omitempty"`
+	Ended    *time.Time `json:"ended,omitempty"`
+	ExitCode int        `json:"exit_code"`
+	Error    string     `json:"error,omitempty"` // think could not be started
+}
+
+var (
+	// ErrNotFound is returned when no item has the given ID.
+	ErrNotFound = errors.New("queue item not found")
+	// ErrRunning is returned when removing an item a worker is running.
+	ErrRunning = errors.New("queue item is running")
+	// ErrBusy is returned by Work when another worker holds the queue.
+	ErrBusy = errors.New("another worker is running")
+)
+
+// pollInterval is how often an idle worker looks for new items.
+var pollInterval = time.Second
+
+// Add appends a run of script with args in cwd to the queue. flags are
+// passed to think before the script.
+func Add(dir, script string, args, flags []string, cwd string) (*Item, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var id string
+	for n := 0; ; n++ {
+		id = now.Format("20060102-150405") + fmt.Sprintf("-%d", n)
+		err := os.Mkdir(filepath.Join(dir, id), 0700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+	it := &Item{ID: id, Script: script, Args: args, Flags: flags, Cwd: cwd, Status: StatusQueued, Added: now}
+	if err := save(dir, it); err != nil {
+		os.RemoveAll(filepath.Join(dir, id))
+		return nil, err
+	}
+	return it, nil
+}
+
+// List returns all items in queue order, oldest first.
+func List(dir string) ([]Item, error) {
+	dirs, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var items []Item
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		it, err := load(dir, d.Name())
+		if err != nil {
+			continue
+		}
+		items = append(items, *it)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Added.Before(items[j].Added) })
+	return items, nil
+}
+
+// Get returns the item with the given ID.
+func Get(dir, id string) (*Item, error) {
+	it, err := load(dir, filepath.Base(id))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return it, nil
+}
+
+// LogPath returns where an item's output is written.
+func LogPath(dir, id string) string {
+	return filepath.Join(dir, filepath.Base(id), "output.log")
+}
+
+// Remove deletes an item and its log. Running items can't be removed.
+func Remove(dir, id string) error {
+	it, err := Get(dir, id)
+	if err != nil {
+		return err
+	}
+	if it.Status == StatusRunning {
+		return ErrRunning
+	}
+	return os.RemoveAll(filepath.Join(dir, it.ID))
+}
+
+// Clear removes finished items. Returns the number removed.
+func Clear(dir string) (int, error) {
+	items, err := List(dir)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, it := range items {
+		if it.Status != StatusSucceeded && it.Status != StatusFailed {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, it.ID)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Worker runs queued items one at a time.
+type Worker struct {
+	Dir   string
+	Think string   // think binary
+	Env   []string // environment for runs; nil = this process's
+
+	OnStart func(Item) // called before an item runs, if set
+	OnDone  func(Item) // called after an item finishes, if set
+}
+
+// Work runs queued items oldest first until ctx is cancelled, or until the
+// queue is empty when drain is set. Only one worker runs per queue; items a
+// crashed worker left running are queued again. An item interrupted by
+// ctx goes back to the queue too, so it runs again on the next start.
+func (w *Worker) Work(ctx context.Context, drain bool) error {
+	if err := os.MkdirAll(w.Dir, 0700); err != nil {
+		return err
+	}
+	unlock, err := flock.TryLock(filepath.Join(w.Dir, "worker.lock"))
+	if errors.Is(err, flock.ErrLocked) {
+		return ErrBusy
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	items, err := List(w.Dir)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		if it := &items[i]; it.Status == StatusRunning {
+			it.Status, it.Started = StatusQueued, nil
+			if err := save(w.Dir, it); err != nil {
+				return err
+			}
+		}
+	}
+
+	for ctx.Err() == nil {
+		it, err := w.next()
+		if err != nil {
+			return err
+		}
+		if it == nil {
+			if drain {
+				return nil
+			}
+			select {
+			case <-time.After(pollInterval):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		if err := w.run(ctx, it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next returns the oldest queued item, or nil when there is none.
+func (w *Worker) next() (*Item, error) {
+	items, err := List(w.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if it.Status == StatusQueued {
+			return &it, nil
+		}
+	}
+	return nil, nil
+}
+
+// run runs one item and records how it ended. Errors are only returned
+// for failures to update the queue itself.
+func (w *Worker) run(ctx context.Context, it *Item) error {
+	started := time.Now()
+	it.Status, it.Started = StatusRunning, &started
+	if err := save(w.Dir, it); err != nil {
+		return err
+	}
+	if w.OnStart != nil {
+		w.OnStart(*it)
+	}
+
+	code, runErr := w.exec(ctx, it)
+	if ctx.Err() != nil {
+		// Interrupted: run it again next time
+		it.Status, it.Started = StatusQueued, nil
+		return save(w.Dir, it)
+	}
+
+	ended := time.Now()
+	it.Ended, it.ExitCode = &ended, code
+	it.Status = StatusSucceeded
+	if runErr != nil || code != 0 {
+		it.Status = StatusFailed
+	}
+	if runErr != nil {
+		it.Error = runErr.Error()
+	}
+	if err := save(w.Dir, it); err != nil {
+		return err
+	}
+	if w.OnDone != nil {
+		w.OnDone(*it)
+	}
+	return nil
+}
+
+// exec runs think on the item with stdin from /dev/null and output to its
+// log. Cancelling ctx interrupts think so it can clean up.
+func (w *Worker) exec(ctx context.Context, it *Item) (int, error) {
+	log, err := os.OpenFile(LogPath(w.Dir, it.ID), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return -1, err
+	}
+	defer log.Close()
+
+	// "--" ends think's flags, so arguments are never parsed as flags
+	args := append([]string(nil), it.Flags...)
+	args = append(args, "--", it.Script)
+	args = append(args, it.Args...)
+	cmd := exec.CommandContext(ctx, w.Think, args...)
+	cmd.Dir = it.Cwd
+	cmd.Env = w.Env
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 10 * time.Second
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// save writes an item's metadata, replacing it atomically so readers never
+// see a partial file.
+func save(dir string, it *Item) error {
+	data, err := json.MarshalIndent(it, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, it.ID, "item.json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func load(dir, id string) (*Item, error) {
+	data, err := os.ReadFile(filepath.Join(dir, id, "item.json"))
+	if err != nil {
+		return nil, err
+	}
+	var it Item
+	if err := json.Unmarshal(data, &it); err != nil {
+		return nil, err
+	}
+	return &it, nil
+}
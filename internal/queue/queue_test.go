@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/flock"
+)
+
+// TestMain doubles as a fake think for workers: with QUEUE_TEST_CHILD set
+// it prints its arguments and directory, and exits 3 when the script is
+// fail.thought.
+func TestMain(m *testing.M) {
+	if os.Getenv("QUEUE_TEST_CHILD") == "1" {
+		wd, _ := os.Getwd()
+		fmt.Println(strings.Join(os.Args[1:], " "), filepath.Base(wd))
+		if os.Args[len(os.Args)-1] == "fail.thought" {
+			os.Exit(3)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func newWorker(t *testing.T) *Worker {
+	t.Helper()
+	return &Worker{
+		Dir:   filepath.Join(t.TempDir(), "queue"),
+		Think: os.Args[0],
+		Env:   append(os.Environ(), "QUEUE_TEST_CHILD=1"),
+	}
+}
+
+func TestWorkRunsInOrder(t *testing.T) {
+	w := newWorker(t)
+	cwd := t.TempDir()
+	a, _ := Add(w.Dir, "/s/a.thought", []string{"--flag", "x"}, []string{"--read-only"}, cwd)
+	b, _ := Add(w.Dir, "fail.thought", nil, nil, cwd)
+
+	var started []string
+	w.OnStart = func(it Item) { started = append(started, it.ID) }
+	if err := w.Work(context.Background(), true); err != nil {
+		t.Fatalf("Work error: %v", err)
+	}
+	if len(started) != 2 || started[0] != a.ID || started[1] != b.ID {
+		t.Errorf("started = %v, want [%s %s]", started, a.ID, b.ID)
+	}
+
+	got, _ := Get(w.Dir, a.ID)
+	if got.Status != StatusSucceeded || got.Ended == nil {
+		t.Errorf("first item = %+v, want succeeded", got)
+	}
+	out, _ := os.ReadFile(LogPath(w.Dir, a.ID))
+	if want := "--read-only -- /s/a.thought --flag x " + filepath.Base(cwd) + "\n"; string(out) != want {
+		t.Errorf("log = %q, want %q", out, want)
+	}
+	if got, _ := Get(w.Dir, b.ID); got.Status != StatusFailed || got.ExitCode != 3 {
+		t.Errorf("second item = %+v, want failed with exit 3", got)
+	}
+
+	if n, err := Clear(w.Dir); n != 2 || err != nil {
+		t.Errorf("Clear = %d, %v; want 2", n, err)
+	}
+	if items, _ := List(w.Dir); len(items) != 0 {
+		t.Errorf("expected an empty queue, got %d items", len(items))
+	}
+}
+
+func TestWorkRequeuesStaleRunning(t *testing.T) {
+	w := newWorker(t)
+	it, _ := Add(w.Dir, "/s/a.thought", nil, nil, t.TempDir())
+	it.Status = StatusRunning
+	save(w.Dir, it)
+
+	if err := Remove(w.Dir, it.ID); !errors.Is(err, ErrRunning) {
+		t.Errorf("Remove running error = %v, want ErrRunning", err)
+	}
+	if err := w.Work(context.Background(), true); err != nil {
+		t.Fatalf("Work error: %v", err)
+	}
+	if got, _ := Get(w.Dir, it.ID); got.Status != StatusSucceeded {
+		t.Errorf("status = %s, want succeeded", got.Status)
+	}
+	if err := Remove(w.Dir, it.ID); err != nil {
+		t.Errorf("Remove error: %v", err)
+	}
+	if _, err := Get(w.Dir, it.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Remove error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestWorkIsExclusive(t *testing.T) {
+	w := newWorker(t)
+	os.MkdirAll(w.Dir, 0700)
+	unlock, err := flock.TryLock(filepath.Join(w.Dir, "worker.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if err := w.Work(context.Background(), true); !errors.Is(err, ErrBusy) {
+		t.Errorf("Work error = %v, want ErrBusy", err)
+	}
+}
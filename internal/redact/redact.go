@@ -0,0 +1,133 @@
+// Package redact masks the secret values a run has read: env.get values of
+// variables with secret-looking names, and secrets.get values. A Set
+// collects them as the sandbox hands them out, and masks them in what the
+// run shows or keeps: console output, tool results (so agent transcripts
+// and session.json), resume contexts, and files written to memories/.
+// Stdout is left alone; it's the thought's output.
+package redact
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Mask replaces each redacted value.
+const Mask = "[redacted]"
+
+// MinLen is the shortest value a Set masks; replacing shorter ones would
+// mangle ordinary text.
+const MinLen = 8
+
+// secretWords mark config keys and environment variables whose values are
+// treated as secrets.
+var secretWords = []string{"key", "token", "secret", "password", "authorization", "credential", "cookie"}
+
+// SecretName reports whether name (an environment variable or config key)
+// looks like it holds a secret.
+func SecretName(name string) bool {
+	name = strings.ToLower(name)
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Set is the secret values read so far in one run. It is safe for
+// concurrent use, and a nil *Set masks nothing.
+type Set struct {
+	mu     sync.RWMutex
+	values []string // longest first, so a value containing another is masked whole
+}
+
+// New returns an empty Set.
+func New() *Set {
+	return &Set{}
+}
+
+// Add records value as a secret. Values shorter than MinLen are ignored.
+func (s *Set) Add(value string) {
+	if s == nil || len(value) < MinLen {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.values, value) {
+		return
+	}
+	s.values = append(s.values, value)
+	slices.SortFunc(s.values, func(a, b string) int { return len(b) - len(a) })
+}
+
+// AddEnv records value if the environment variable name looks secret.
+func (s *Set) AddEnv(name, value string) {
+	if SecretName(name) {
+		s.Add(value)
+	}
+}
+
+// String returns text with every recorded value replaced by Mask.
+func (s *Set) String(text string) string {
+	if s == nil {
+		return text
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, v := range s.values {
+		text = strings.ReplaceAll(text, v, Mask)
+	}
+	return text
+}
+
+// Error returns err with recorded values masked in its message. Errors
+// without any are returned as they are, so errors.Is still sees through
+// them.
+func (s *Set) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	if masked := s.String(msg); masked != msg {
+		return &maskedError{msg: masked, err: err}
+	}
+	return err
+}
+
+// maskedError is an error whose message had secrets masked. Unwrap keeps
+// errors.Is and errors.As working; only the text changes.
+type maskedError struct {
+	msg string
+	err error
+}
+
+func (e *maskedError) Error() string { return e.msg }
+func (e *maskedError) Unwrap() error { return e.err }
+
+// Writer returns a writer that masks recorded values in each Write before
+// passing it to w. Values are matched within one Write, which is how the
+// sandbox writes a console.log line. A nil Set returns w.
+func (s *Set) Writer(w io.Writer) io.Writer {
+	if s == nil {
+		return w
+	}
+	return &writer{set: s, w: w}
+}
+
+type writer struct {
+	set *Set
+	w   io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	masked := w.set.String(string(p))
+	if masked == string(p) {
+		return w.w.Write(p)
+	}
+	if _, err := io.WriteString(w.w, masked); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
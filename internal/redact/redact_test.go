@@ -0,0 +1,46 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	s := New()
+	s.Add("short")        // under MinLen: ignored
+	s.Add("tok-abcdefgh") // contained in the next one
+	s.Add("tok-abcdefgh-extended")
+	s.AddEnv("HOME", "/home/someone")
+	s.AddEnv("GITHUB_TOKEN", "ghp_0123456789")
+
+	got := s.String("short tok-abcdefgh-extended tok-abcdefgh /home/someone ghp_0123456789")
+	want := "short [redacted] [redacted] /home/someone [redacted]"
+	if got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	w := s.Writer(&buf)
+	if n, err := w.Write([]byte("token ghp_0123456789\n")); err != nil || n != 21 {
+		t.Errorf("Write = %d, %v", n, err)
+	}
+	if buf.String() != "token [redacted]\n" {
+		t.Errorf("written = %q", buf.String())
+	}
+
+	base := errors.New("bad token ghp_0123456789")
+	err := s.Error(base)
+	if err.Error() != "bad token [redacted]" || !errors.Is(err, base) {
+		t.Errorf("Error = %v, want masked and wrapping the original", err)
+	}
+	if plain := errors.New("nothing secret"); s.Error(plain) != plain {
+		t.Error("Error changed an error without secrets")
+	}
+
+	var none *Set
+	none.Add("ignored-value")
+	if none.String("ignored-value") != "ignored-value" || none.Writer(&buf) != &buf {
+		t.Error("nil Set should mask nothing")
+	}
+}
@@ -0,0 +1,257 @@
+package runlog
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// MaxHistory is how many agent runs a thought's history/ keeps; older
+// transcripts are removed when a new one starts.
+const MaxHistory = 50
+
+// Entry is a line of a history transcript (history/<id>.jsonl). A
+// transcript starts with a run entry and, unless the run crashed, ends
+// with an end entry; in between, each agent's system prompt (again when it
+// changes) and messages in order.
+type Entry struct {
+	Type string    `json:"type"` // run, system, message, or end
+	Time time.Time `json:"time"`
+
+	// The agent a system or message entry belongs to; stream and map runs
+	// have one per line or input, numbered from 1
+	Conversation int `json:"conversation,omitempty"`
+
+	Run     *Run              `json:"run,omitempty"` // run and end
+	System  string            `json:"system,omitempty"`
+	Message *provider.Message `json:"message,omitempty"`
+
+	// For an assistant message: why it stopped, what it used, the model
+	// that answered when a fallback model did, and the API's request ID
+	// and response time
+	StopReason   string          `json:"stop_reason,omitempty"`
+	StopSequence string          `json:"stop_sequence,omitempty"`
+	Usage        *provider.Usage `json:"usage,omitempty"`
+	Model        string          `json:"model,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	LatencyMS    int64           `json:"latency_ms,omitempty"`
+}
+
+// History is a saved transcript, summarized for `thought history`.
+type History struct {
+	ID       string
+	Run      Run // as of the end entry, or the run entry if the run crashed
+	Agents   int
+	Messages int
+}
+
+// HistoryDir returns the directory holding a thought's transcripts.
+func HistoryDir(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "history")
+}
+
+// Conversation numbers a new agent's conversation for Message.
+func (r *Recorder) Conversation() int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conversations++
+	return r.conversations
+}
+
+// Message appends msg, sent with system prompt system, to the run's
+// history transcript, which is created by the first message of the run.
+// For an assistant message, resp is the response it came from.
+func (r *Recorder) Message(conv int, system string, msg provider.Message, resp *provider.ChatResponse) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.history == "" {
+		path, err := startHistory(r.thoughtDir, r.run)
+		if err != nil {
+			return
+		}
+		r.history = path
+		r.systems = map[int]string{}
+	}
+	now := time.Now()
+	var entries []Entry
+	if prev, ok := r.systems[conv]; !ok || prev != system {
+		r.systems[conv] = system
+		entries = append(entries, Entry{Type: "system", Time: now, Conversation: conv, System: system})
+	}
+	e := Entry{Type: "message", Time: now, Conversation: conv, Message: &msg}
+	if resp != nil && msg.Role == "assistant" {
+		e.StopReason = resp.StopReason
+		e.StopSequence = resp.StopSequence
+		e.Model = resp.Model
+		e.RequestID = resp.RequestID
+		e.LatencyMS = resp.Latency.Milliseconds()
+		if resp.Usage != (provider.Usage{}) {
+			e.Usage = &resp.Usage
+		}
+	}
+	appendEntries(r.history, append(entries, e)...)
+}
+
+// startHistory creates the transcript for run, starting with a run
+// entry, and removes the oldest so MaxHistory remain.
+func startHistory(thoughtDir string, run Run) (string, error) {
+	dir := HistoryDir(thoughtDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	ids, _ := historyIDs(dir)
+	for len(ids) >= MaxHistory {
+		os.Remove(filepath.Join(dir, ids[0]+".jsonl"))
+		ids = ids[1:]
+	}
+	// Runs started in the same second get -2, -3, ...
+	id := run.Started.Format("20060102-150405")
+	path := filepath.Join(dir, id+".jsonl")
+	for n := 2; ; n++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			break
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.jsonl", id, n))
+	}
+	run.Stdin, run.StdinTruncated = "", false // kept in last-run/ only
+	return path, appendEntries(path, Entry{Type: "run", Time: run.Started, Run: &run})
+}
+
+func appendEntries(path string, entries ...Entry) error {
+	var buf []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(buf)
+	return err
+}
+
+// historyIDs returns the transcripts in dir, oldest first.
+func historyIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".jsonl"); ok && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	// Names sort by time, with same-second runs (-2, -3, ..., -10) in order
+	stamp := func(id string) string { return id[:min(len(id), len("20060102-150405"))] }
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(strings.Compare(stamp(a), stamp(b)), cmp.Compare(len(a), len(b)), strings.Compare(a, b))
+	})
+	return ids, nil
+}
+
+// ListHistory returns a thought's transcripts, newest first.
+func ListHistory(thoughtDir string) ([]History, error) {
+	ids, err := historyIDs(HistoryDir(thoughtDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []History
+	for _, id := range slices.Backward(ids) {
+		entries, err := readEntries(filepath.Join(HistoryDir(thoughtDir), id+".jsonl"))
+		if err != nil {
+			continue
+		}
+		h := History{ID: id}
+		agents := map[int]bool{}
+		for _, e := range entries {
+			switch e.Type {
+			case "run", "end":
+				if e.Run != nil {
+					h.Run = *e.Run
+				}
+			case "message":
+				h.Messages++
+				agents[e.Conversation] = true
+			}
+		}
+		h.Agents = len(agents)
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// ReadHistory returns the entries of a thought's transcript id, which may
+// be shortened to any prefix that matches only one transcript.
+func ReadHistory(thoughtDir, id string) (string, []Entry, error) {
+	ids, err := historyIDs(HistoryDir(thoughtDir))
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, err
+	}
+	var matches []string
+	for _, have := range ids {
+		if have == id {
+			matches = []string{have}
+			break
+		}
+		if strings.HasPrefix(have, id) {
+			matches = append(matches, have)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", nil, fmt.Errorf("no transcript %q in %s", id, filepath.Base(thoughtDir))
+	case 1:
+	default:
+		return "", nil, fmt.Errorf("%q matches %d transcripts; use more of the ID", id, len(matches))
+	}
+	entries, err := readEntries(filepath.Join(HistoryDir(thoughtDir), matches[0]+".jsonl"))
+	return matches[0], entries, err
+}
+
+// readEntries reads a transcript, skipping lines that don't parse (a line
+// cut short by a crash).
+func readEntries(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
@@ -0,0 +1,194 @@
+package runlog
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/redact"
+)
+
+// Redacted replaces secret values in a report.
+const Redacted = redact.Mask
+
+// Redact returns the JSON document data with the values of secret-looking
+// keys, and every header value, replaced by Redacted. Data that isn't JSON
+// is dropped entirely rather than risk leaking it.
+func Redact(data []byte) []byte {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []byte(`"` + Redacted + ` (not valid JSON)"` + "\n")
+	}
+	out, _ := json.MarshalIndent(redactJSON(v, false), "", "  ")
+	return append(out, '\n')
+}
+
+func redactJSON(v any, all bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			switch {
+			case all || redact.SecretName(k):
+				if _, isMap := val.(map[string]any); isMap {
+					v[k] = redactJSON(val, true)
+				} else if val != nil && val != "" {
+					v[k] = Redacted
+				}
+			case strings.EqualFold(k, "headers"):
+				v[k] = redactJSON(val, true)
+			default:
+				v[k] = redactJSON(val, false)
+			}
+		}
+	case []any:
+		for i, val := range v {
+			v[i] = redactJSON(val, all)
+		}
+	}
+	return v
+}
+
+// RedactText returns s with every occurrence of a secret replaced by
+// Redacted: the given values, and the values of environment variables
+// with secret-looking names. Values under 8 characters are left alone,
+// since replacing them would mangle ordinary text.
+func RedactText(s string, secrets ...string) string {
+	for _, kv := range os.Environ() {
+		if name, value, _ := strings.Cut(kv, "="); redact.SecretName(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	// Longest first, so a secret containing another is replaced whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		if len(secret) >= 8 {
+			s = strings.ReplaceAll(s, secret, Redacted)
+		}
+	}
+	return s
+}
+
+// Environment is env.json: where the run happened.
+type Environment struct {
+	Version string            `json:"version"` // think's module version
+	Go      string            `json:"go"`
+	OS      string            `json:"os"`
+	Arch    string            `json:"arch"`
+	Env     map[string]string `json:"env"` // THINKINGSCRIPT* variables, secrets redacted
+}
+
+// CurrentEnvironment describes this process.
+func CurrentEnvironment() Environment {
+	env := Environment{Version: "(unknown)", Go: runtime.Version(), OS: runtime.GOOS, Arch: runtime.GOARCH, Env: map[string]string{}}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		env.Version = info.Main.Version
+	}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, "THINKINGSCRIPT") {
+			continue
+		}
+		if redact.SecretName(name) {
+			value = Redacted
+		}
+		env.Env[name] = value
+	}
+	return env
+}
+
+// WriteReport writes a gzipped tar archive for a bug report about the
+// thought to w. Everything sits under a top-level directory named root:
+// the last run's record (when lastRun is set and one exists), memory.js,
+// the script, the thought and global policies, config.json and the run's
+// agent definition with secrets redacted, and env.json. It returns the
+// archive's file names.
+func WriteReport(w io.Writer, thoughtDir, root string, lastRun bool) ([]string, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var names []string
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: root + "/" + name, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		names = append(names, name)
+		return nil
+	}
+	addFile := func(name, path string, filter func([]byte) []byte) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil // optional: reports include what exists
+		}
+		if filter != nil {
+			data = filter(data)
+		}
+		return add(name, data)
+	}
+
+	var run Run
+	if lastRun {
+		dir := Dir(thoughtDir)
+		if data, err := os.ReadFile(filepath.Join(dir, "run.json")); err == nil {
+			json.Unmarshal(data, &run)
+		}
+		var files []string
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		sort.Strings(files)
+		for _, path := range files {
+			rel, _ := filepath.Rel(dir, path)
+			if err := addFile("last-run/"+filepath.ToSlash(rel), path, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := addFile("memory.js", filepath.Join(config.ThoughtDataDir(thoughtDir), "memory.js"), nil); err != nil {
+		return nil, err
+	}
+	if run.Script != "" && !strings.Contains(run.Script, "://") {
+		if err := addFile("script/"+filepath.Base(run.Script), run.Script, nil); err != nil {
+			return nil, err
+		}
+	}
+	if err := addFile("policy/thought.json", filepath.Join(thoughtDir, "policy.json"), nil); err != nil {
+		return nil, err
+	}
+	if err := addFile("policy/global.json", filepath.Join(config.HomeDir(), "policy.json"), nil); err != nil {
+		return nil, err
+	}
+	if err := addFile("config.json", filepath.Join(config.HomeDir(), "config.json"), Redact); err != nil {
+		return nil, err
+	}
+	if run.Agent != "" && !strings.ContainsAny(run.Agent, `/\`) {
+		if err := addFile("agents/"+run.Agent+".json", filepath.Join(config.HomeDir(), "agents", run.Agent+".json"), Redact); err != nil {
+			return nil, err
+		}
+	}
+	env, _ := json.MarshalIndent(CurrentEnvironment(), "", "  ")
+	if err := add("env.json", append(env, '\n')); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return names, gz.Close()
+}
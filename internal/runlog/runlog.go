@@ -0,0 +1,288 @@
+// Package runlog keeps a record of a thought's most recent run in
+// <thought>/last-run/: what went in (arguments, stdin), the agent's
+// transcript, the code and output of every sandbox run, and how the run
+// ended. `thought report` packages it with the policy, redacted config, and
+// environment into an archive that can be attached to a bug report.
+//
+// Agent runs are also kept in <thought>/history/ as JSONL transcripts, one
+// per run, for `thought history`.
+package runlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/backend"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/usage"
+)
+
+// MaxCapture is how much of stdin and of each sandbox run's stdout and
+// stderr is kept.
+const MaxCapture = 256 << 10
+
+// Run describes the run as a whole (run.json).
+type Run struct {
+	Thought  string    `json:"thought"`
+	Script   string    `json:"script"`
+	Kind     string    `json:"kind"` // memory.js, agent, stream, or map
+	Agent    string    `json:"agent"`
+	Provider string    `json:"provider"`
+	Model    string    `json:"model"`
+	Backend  string    `json:"backend"`
+	Args     []string  `json:"args"`
+	Stdin    string    `json:"stdin,omitempty"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration,omitempty"`
+	Status   string    `json:"status"` // running, ok, or error
+	Error    string    `json:"error,omitempty"`
+
+	StdinTruncated bool `json:"stdin_truncated,omitempty"`
+
+	// Requests answered by the approval mode instead of a prompt
+	AutoApprovals []AutoApproval `json:"auto_approvals,omitempty"`
+
+	// Token usage, and with --profile the bridge timings; see SetUsage
+	Usage *usage.Record `json:"usage,omitempty"`
+}
+
+// AutoApproval is a request the approval mode (think --yes, --no,
+// --policy-only) answered without prompting.
+type AutoApproval struct {
+	Kind   string    `json:"kind"` // net, env, tool, or a path operation
+	Target string    `json:"target"`
+	Mode   string    `json:"mode"` // allow, deny, or policy
+	Time   time.Time `json:"time"`
+}
+
+// Sandbox is one sandbox run (a line of sandbox.jsonl). The code is in
+// sandbox/<Code>.
+type Sandbox struct {
+	Seq      int    `json:"seq"`
+	Source   string `json:"source"` // memory.js or run_script
+	Code     string `json:"code"`
+	Result   string `json:"result,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// transcript is transcript.json.
+type transcript struct {
+	System   string             `json:"system"`
+	Messages []provider.Message `json:"messages"`
+}
+
+// Dir returns the directory holding a thought's last run.
+func Dir(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "last-run")
+}
+
+// Recorder writes the record of one run. A nil *Recorder records nothing,
+// so callers can pass it around unconditionally. Safe for concurrent use.
+type Recorder struct {
+	dir        string
+	thoughtDir string
+
+	mu  sync.Mutex
+	run Run
+	seq int
+
+	// The history transcript, once an agent has sent a message
+	history       string
+	conversations int
+	systems       map[int]string // last system prompt recorded per conversation
+}
+
+// Start replaces the thought's last-run record with a new one for run.
+func Start(thoughtDir string, run Run) (*Recorder, error) {
+	dir := Dir(thoughtDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "sandbox"), 0700); err != nil {
+		return nil, err
+	}
+	if len(run.Stdin) > MaxCapture {
+		run.Stdin = run.Stdin[:MaxCapture]
+		run.StdinTruncated = true
+	}
+	run.Status = "running"
+	r := &Recorder{dir: dir, thoughtDir: thoughtDir, run: run}
+	if err := r.writeJSON("run.json", run); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Finish records how the run ended: its kind, the model it used, and err.
+func (r *Recorder) Finish(kind, model string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Kind = kind
+	r.run.Model = model
+	r.run.Duration = time.Since(r.run.Started).Round(time.Millisecond).String()
+	r.run.Status = "ok"
+	if err != nil {
+		r.run.Status = "error"
+		r.run.Error = err.Error()
+	}
+	r.writeJSON("run.json", r.run)
+	if r.history != "" {
+		run := r.run
+		run.Stdin, run.StdinTruncated = "", false
+		appendEntries(r.history, Entry{Type: "end", Time: time.Now(), Run: &run})
+	}
+}
+
+// SetUsage records the run's usage, written by Finish.
+func (r *Recorder) SetUsage(rec usage.Record) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Usage = &rec
+}
+
+// AutoApproval records a request answered without prompting.
+func (r *Recorder) AutoApproval(kind, target, mode string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.AutoApprovals = append(r.run.AutoApprovals, AutoApproval{Kind: kind, Target: target, Mode: mode, Time: time.Now()})
+	r.writeJSON("run.json", r.run)
+}
+
+// Transcript records the agent's conversation so far.
+func (r *Recorder) Transcript(system string, messages []provider.Message) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writeJSON("transcript.json", transcript{System: system, Messages: messages})
+}
+
+// sandbox records a finished sandbox run.
+func (r *Recorder) sandbox(s Sandbox, code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	os.WriteFile(filepath.Join(r.dir, "sandbox", s.Code), []byte(code), 0600)
+	line, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(r.dir, "sandbox.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(append(line, '\n'))
+}
+
+func (r *Recorder) next() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	return r.seq
+}
+
+func (r *Recorder) writeJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(r.dir, name), append(data, '\n'), 0600)
+}
+
+// Wrap returns a backend that runs sandboxes on b and records each one as
+// coming from source (memory.js or run_script).
+func (r *Recorder) Wrap(b backend.Backend, source string) backend.Backend {
+	if r == nil {
+		return b
+	}
+	if b == nil {
+		b = backend.InProcess
+	}
+	return recording{Backend: b, r: r, source: source}
+}
+
+type recording struct {
+	backend.Backend
+	r      *Recorder
+	source string
+}
+
+func (rb recording) Run(ctx context.Context, cfg sandbox.Config, code string) (string, error) {
+	seq := rb.r.next()
+	var stdout, stderr capture
+	cfg.Stdout = io.MultiWriter(orStd(cfg.Stdout, os.Stdout), &stdout)
+	cfg.Stderr = io.MultiWriter(orStd(cfg.Stderr, os.Stderr), &stderr)
+
+	started := time.Now()
+	result, err := rb.Backend.Run(ctx, cfg, code)
+	s := Sandbox{
+		Seq:      seq,
+		Source:   rb.source,
+		Code:     fmt.Sprintf("%03d-%s.js", seq, strings.TrimSuffix(rb.source, ".js")),
+		Result:   result,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(started).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	rb.r.sandbox(s, code)
+	return result, err
+}
+
+func orStd(w, std io.Writer) io.Writer {
+	if w == nil {
+		return std
+	}
+	return w
+}
+
+// capture keeps the first MaxCapture bytes written to it.
+type capture struct {
+	mu        sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+func (c *capture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if room := MaxCapture - len(c.buf); len(p) > room {
+		c.buf = append(c.buf, p[:room]...)
+		c.truncated = true
+	} else {
+		c.buf = append(c.buf, p...)
+	}
+	return len(p), nil
+}
+
+func (c *capture) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.truncated {
+		return string(c.buf) + "\n[truncated]"
+	}
+	return string(c.buf)
+}
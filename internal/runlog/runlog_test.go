@@ -0,0 +1,236 @@
+package runlog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/usage"
+)
+
+// fakeBackend prints code to stdout and fails when code says "fail".
+type fakeBackend struct{}
+
+func (fakeBackend) Name() string { return "fake" }
+
+func (fakeBackend) Run(ctx context.Context, cfg sandbox.Config, code string) (string, error) {
+	io.WriteString(cfg.Stdout, "out:"+code)
+	if code == "fail" {
+		return "", errors.New("boom")
+	}
+	return "result", nil
+}
+
+func TestRecorder(t *testing.T) {
+	thoughtDir := t.TempDir()
+	os.MkdirAll(filepath.Join(Dir(thoughtDir), "sandbox"), 0700)
+	os.WriteFile(filepath.Join(Dir(thoughtDir), "stale.json"), []byte("{}"), 0600)
+
+	r, err := Start(thoughtDir, Run{Thought: "demo", Args: []string{"a"}, Stdin: strings.Repeat("x", MaxCapture+1), Started: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(Dir(thoughtDir), "stale.json")); !os.IsNotExist(err) {
+		t.Error("Start kept the previous run's files")
+	}
+
+	var stdout bytes.Buffer
+	b := r.Wrap(fakeBackend{}, "run_script")
+	if b.Name() != "fake" {
+		t.Errorf("wrapped Name = %q", b.Name())
+	}
+	b.Run(context.Background(), sandbox.Config{Stdout: &stdout}, "ok")
+	b.Run(context.Background(), sandbox.Config{Stdout: &stdout}, "fail")
+	if stdout.String() != "out:okout:fail" {
+		t.Errorf("sandbox stdout = %q, want it passed through", stdout.String())
+	}
+	r.Transcript("sys", []provider.Message{provider.NewUserMessage(provider.NewTextBlock("hi"))})
+	r.SetUsage(usage.Record{Calls: 3, Bridges: []sandbox.BridgeStat{{Name: "fs.readFile", Calls: 2}}})
+	r.Finish("agent", "m", errors.New("bad"))
+
+	data, _ := os.ReadFile(filepath.Join(Dir(thoughtDir), "sandbox.jsonl"))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("sandbox.jsonl has %d lines, want 2", len(lines))
+	}
+	var s Sandbox
+	json.Unmarshal([]byte(lines[1]), &s)
+	if s.Seq != 2 || s.Source != "run_script" || s.Error != "boom" || s.Stdout != "out:fail" || s.Code != "002-run_script.js" {
+		t.Errorf("second sandbox run = %+v", s)
+	}
+	if code, _ := os.ReadFile(filepath.Join(Dir(thoughtDir), "sandbox", s.Code)); string(code) != "fail" {
+		t.Errorf("recorded code = %q", code)
+	}
+
+	var run Run
+	data, _ = os.ReadFile(filepath.Join(Dir(thoughtDir), "run.json"))
+	json.Unmarshal(data, &run)
+	if run.Kind != "agent" || run.Status != "error" || run.Error != "bad" || len(run.Stdin) != MaxCapture || !run.StdinTruncated {
+		t.Errorf("run.json = %+v", run)
+	}
+	if run.Usage == nil || run.Usage.Calls != 3 || len(run.Usage.Bridges) != 1 {
+		t.Errorf("run.json usage = %+v", run.Usage)
+	}
+
+	// A nil recorder records nothing and leaves backends alone
+	var none *Recorder
+	if none.Wrap(fakeBackend{}, "memory.js") != (fakeBackend{}) {
+		t.Error("nil Recorder wrapped the backend")
+	}
+	none.Transcript("", nil)
+	none.SetUsage(usage.Record{})
+	none.Finish("agent", "", nil)
+	none.Message(none.Conversation(), "", provider.Message{}, nil)
+}
+
+func TestHistory(t *testing.T) {
+	thoughtDir := t.TempDir()
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+
+	// Runs that never reach the agent save no transcript
+	r, _ := Start(thoughtDir, Run{Thought: "demo", Started: started})
+	r.Finish("memory.js", "", nil)
+	if runs, _ := ListHistory(thoughtDir); len(runs) != 0 {
+		t.Fatalf("memory.js run saved %d transcripts", len(runs))
+	}
+
+	r, _ = Start(thoughtDir, Run{Thought: "demo", Args: []string{"a"}, Stdin: "secret input", Started: started})
+	conv := r.Conversation()
+	r.Message(conv, "sys", provider.NewUserMessage(provider.NewTextBlock("hi")), nil)
+	r.Message(conv, "sys", provider.NewAssistantMessage(provider.NewToolUseBlock("t1", "run_script", json.RawMessage(`{}`))),
+		&provider.ChatResponse{StopReason: "tool_use", Usage: provider.Usage{InputTokens: 10, OutputTokens: 2}, Model: "backup"})
+	r.Message(conv, "sys 2", provider.NewUserMessage(provider.NewToolResultBlock("t1", "done", false)), nil)
+	r.Finish("agent", "m", nil)
+
+	// A second run in the same second gets its own transcript
+	r, _ = Start(thoughtDir, Run{Thought: "demo", Started: started})
+	r.Message(r.Conversation(), "sys", provider.NewUserMessage(provider.NewTextBlock("again")), nil)
+
+	runs, err := ListHistory(thoughtDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != "20260102-030405-2" || runs[1].ID != "20260102-030405" {
+		t.Fatalf("ListHistory = %+v", runs)
+	}
+	if runs[0].Run.Status != "running" || runs[1].Run.Status != "ok" || runs[1].Run.Kind != "agent" || runs[1].Messages != 3 || runs[1].Agents != 1 {
+		t.Errorf("ListHistory = %+v", runs)
+	}
+
+	id, entries, err := ReadHistory(thoughtDir, "20260102-030405")
+	if err != nil || id != "20260102-030405" {
+		t.Fatalf("ReadHistory = %q, %v", id, err)
+	}
+	var types []string
+	for _, e := range entries {
+		types = append(types, e.Type)
+	}
+	if got := strings.Join(types, " "); got != "run system message message system message end" {
+		t.Errorf("entries = %s", got)
+	}
+	reply := entries[3]
+	if reply.StopReason != "tool_use" || reply.Model != "backup" || reply.Usage == nil || reply.Usage.InputTokens != 10 {
+		t.Errorf("assistant entry = %+v", reply)
+	}
+	if entries[0].Run.Stdin != "" {
+		t.Error("stdin saved in the history")
+	}
+	if _, _, err := ReadHistory(thoughtDir, "2026"); err == nil {
+		t.Error("ambiguous prefix matched")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	in := `{"agent": "a", "api_key": "sk-1", "empty_token": "", "code_check": {"url": "u", "headers": {"X-Team": "t"}},
+		"managed_policy_key": "k", "routes": {"resume": "small"}, "credential_helper": {"command": "pass show"}}`
+	var got map[string]any
+	if err := json.Unmarshal(Redact([]byte(in)), &got); err != nil {
+		t.Fatal(err)
+	}
+	check := got["code_check"].(map[string]any)
+	if got["api_key"] != Redacted || got["managed_policy_key"] != Redacted || check["headers"].(map[string]any)["X-Team"] != Redacted {
+		t.Errorf("secrets kept: %v", got)
+	}
+	if got["credential_helper"].(map[string]any)["command"] != Redacted {
+		t.Errorf("nested secret kept: %v", got["credential_helper"])
+	}
+	if got["agent"] != "a" || check["url"] != "u" || got["routes"].(map[string]any)["resume"] != "small" || got["empty_token"] != "" {
+		t.Errorf("non-secrets changed: %v", got)
+	}
+	if strings.Contains(string(Redact([]byte("api_key=sk-1"))), "sk-1") {
+		t.Error("invalid JSON passed through")
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	t.Setenv("DEPLOY_TOKEN", "ghp-0123456789")
+	t.Setenv("DEPLOY_HOST", "example.com:8443")
+	in := "token ghp-0123456789, key sk-ant-abcdefgh, host example.com:8443, short 1234"
+	want := "token [redacted], key [redacted], host example.com:8443, short 1234"
+	if got := RedactText(in, "sk-ant-abcdefgh", "1234", ""); got != want {
+		t.Errorf("RedactText = %q, want %q", got, want)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", home)
+	t.Setenv("THINKINGSCRIPT__API_KEY", "sk-env")
+	thoughtDir := filepath.Join(home, "thoughts", "demo")
+	os.MkdirAll(filepath.Join(home, "agents"), 0700)
+	os.WriteFile(filepath.Join(home, "config.json"), []byte(`{"agent": "work"}`), 0600)
+	os.WriteFile(filepath.Join(home, "agents", "work.json"), []byte(`{"api_key": "sk-agent", "model": "m"}`), 0600)
+	scriptPath := filepath.Join(home, "demo.md")
+	os.WriteFile(scriptPath, []byte("Say hi"), 0600)
+
+	r, err := Start(thoughtDir, Run{Script: scriptPath, Agent: "work", Started: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Wrap(fakeBackend{}, "memory.js").Run(context.Background(), sandbox.Config{Stdout: io.Discard}, "ok")
+	r.Finish("memory.js", "m", nil)
+	os.WriteFile(filepath.Join(thoughtDir, "memory.js"), []byte("ok"), 0600)
+
+	var buf bytes.Buffer
+	if _, err := WriteReport(&buf, thoughtDir, "demo-report", true); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+	for _, name := range []string{"last-run/run.json", "last-run/sandbox.jsonl", "last-run/sandbox/001-memory.js", "memory.js", "script/demo.md", "config.json", "agents/work.json", "env.json"} {
+		if _, ok := files["demo-report/"+name]; !ok {
+			t.Errorf("report lacks %s (has %v)", name, files)
+		}
+	}
+	for name, data := range files {
+		if strings.Contains(data, "sk-") {
+			t.Errorf("%s leaks a secret:\n%s", name, data)
+		}
+	}
+}
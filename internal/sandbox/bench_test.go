@@ -0,0 +1,137 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Benchmarks for the costs every run_script call and memory.js run pays.
+// TestPerformanceBudget holds them to budgets in CI.
+
+func BenchmarkSandboxNew(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < b.N; i++ {
+		sb, err := New(Config{AllowedPaths: []string{dir}, WorkDir: dir})
+		if err != nil {
+			b.Fatal(err)
+		}
+		sb.ctx = context.Background()
+		sb.newRuntime()
+	}
+}
+
+func BenchmarkRunSmallScript(b *testing.B) {
+	dir := b.TempDir()
+	os.WriteFile(filepath.Join(dir, "in.json"), []byte(`{"items": [1, 2, 3]}`), 0644)
+	sb, err := New(Config{AllowedPaths: []string{dir}, WorkDir: dir})
+	if err != nil {
+		b.Fatal(err)
+	}
+	code := `var data = JSON.parse(fs.readFile("in.json"));
+data.items.map(function (n) { return n * 2; }).join(",")`
+	for i := 0; i < b.N; i++ {
+		if _, err := sb.Run(context.Background(), code); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGlobLargeTree(b *testing.B) {
+	dir := b.TempDir()
+	for d := 0; d < 20; d++ {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%02d", d), "nested")
+		os.MkdirAll(sub, 0755)
+		for f := 0; f < 100; f++ {
+			ext := ".txt"
+			if f%4 == 0 {
+				ext = ".go"
+			}
+			os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%03d%s", f, ext)), nil, 0644)
+		}
+	}
+	sb, err := New(Config{AllowedPaths: []string{dir}, WorkDir: dir})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sb.Run(context.Background(), `fs.glob("**/*.go").length`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRunConvergedScript runs a memory.js-sized script (a few
+// hundred lines of functions, little work per run) repeatedly, as --map
+// does. Parsing and compiling dominate unless the program is cached.
+func BenchmarkRunConvergedScript(b *testing.B) {
+	sb, err := New(Config{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var code strings.Builder
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&code, "function step%d(s) {\n  var parts = s.split(\",\");\n  return parts.map(function (p) { return p.trim() + %d; }).join(\",\");\n}\n", i, i)
+	}
+	code.WriteString(`step0("a, b") + step199("c")`)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sb.Run(context.Background(), code.String()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFetchApproval measures net.fetch up to the approval decision:
+// URL checks, the approval callback, and the thrown denial. No request is
+// sent.
+func BenchmarkFetchApproval(b *testing.B) {
+	sb, err := New(Config{ApproveNet: func(host string) (bool, error) { return false, nil }})
+	if err != nil {
+		b.Fatal(err)
+	}
+	code := `try { net.fetch("http://203.0.113.7/data.json") } catch (e) { e.code }`
+	for i := 0; i < b.N; i++ {
+		if v, err := sb.Run(context.Background(), code); err != nil || v != "EACCES" {
+			b.Fatalf("Run = %q, %v", v, err)
+		}
+	}
+}
+
+// budgets are the most each benchmark may take per operation. They are a
+// few times what a laptop measures, so slow CI machines pass, but they
+// catch regressions such as compiling setup scripts or memory.js for
+// every runtime.
+var budgets = []struct {
+	name   string
+	bench  func(*testing.B)
+	budget time.Duration
+}{
+	{"SandboxNew", BenchmarkSandboxNew, 600 * time.Microsecond},
+	{"RunSmallScript", BenchmarkRunSmallScript, time.Millisecond},
+	{"RunConvergedScript", BenchmarkRunConvergedScript, 2 * time.Millisecond},
+	{"GlobLargeTree", BenchmarkGlobLargeTree, 30 * time.Millisecond},
+	{"FetchApproval", BenchmarkFetchApproval, 1500 * time.Microsecond},
+}
+
+// TestPerformanceBudget runs the benchmarks and fails when one exceeds its
+// budget. It takes a few seconds, so it only runs with
+// THINKINGSCRIPT_PERF_BUDGET=1 (set in CI).
+func TestPerformanceBudget(t *testing.T) {
+	if os.Getenv("THINKINGSCRIPT_PERF_BUDGET") == "" {
+		t.Skip("set THINKINGSCRIPT_PERF_BUDGET=1 to check performance budgets")
+	}
+	for _, bb := range budgets {
+		r := testing.Benchmark(bb.bench)
+		per := time.Duration(r.NsPerOp())
+		t.Logf("%s: %v/op (budget %v)", bb.name, per, bb.budget)
+		if per > bb.budget {
+			t.Errorf("%s takes %v per op, over its %v budget", bb.name, per, bb.budget)
+		}
+	}
+}
@@ -13,7 +13,7 @@ func (s *Sandbox) registerEnv(vm *goja.Runtime) {
 		name := call.Argument(0).String()
 
 		if s.cfg.ApproveEnv != nil {
-			approved, err := s.cfg.ApproveEnv(name)
+			approved, err := interruptible(s.ctx, func() (bool, error) { return s.cfg.ApproveEnv(name) })
 			if err != nil {
 				s.checkInterrupted(err)
 				throwError(vm, "env.get: "+err.Error())
@@ -23,7 +23,13 @@ func (s *Sandbox) registerEnv(vm *goja.Runtime) {
 			}
 		}
 
-		return vm.ToValue(os.Getenv(name))
+		getenv := s.cfg.Getenv
+		if getenv == nil {
+			getenv = os.Getenv
+		}
+		value := getenv(name)
+		s.cfg.Redact.AddEnv(name, value)
+		return vm.ToValue(value)
 	})
 
 	vm.Set("env", env)
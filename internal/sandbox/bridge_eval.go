@@ -0,0 +1,111 @@
+package sandbox
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// Eval modes for Config.Eval: whether code may compile strings into code
+// with eval, Function, or the generator/async function constructors.
+const (
+	EvalAfterFetch = "after-fetch" // default: allowed until the script receives a net.fetch response
+	EvalAllow      = "allow"
+	EvalDeny       = "deny"
+)
+
+// ValidEval reports whether mode is a Config.Eval value ("" is the default).
+func ValidEval(mode string) bool {
+	switch mode {
+	case "", EvalAfterFetch, EvalAllow, EvalDeny:
+		return true
+	}
+	return false
+}
+
+// evalGuardJS replaces eval and every function constructor goja has (it
+// lacks async generators) with wrappers that ask guard first.
+// Function("return this"), which bundled libraries use to find the global
+// object, is answered without compiling anything.
+const evalGuardJS = `(function (guard) {
+	var realEval = eval;
+	globalThis.eval = function eval(src) {
+		guard("eval");
+		return realEval(src);
+	};
+
+	var RealFunction = Function;
+	var returnThis = /^\s*return\s+this\s*;?\s*$/;
+	function wrap(Real, name) {
+		var Guarded = function () {
+			if (Real === RealFunction && arguments.length === 1 && returnThis.test(String(arguments[0]))) {
+				return function () { return globalThis; };
+			}
+			guard(name);
+			return Real.apply(null, arguments);
+		};
+		Object.defineProperty(Guarded, "name", {value: Real.name});
+		Guarded.prototype = Real.prototype;
+		Object.defineProperty(Real.prototype, "constructor", {value: Guarded, writable: false, configurable: false});
+		return Guarded;
+	}
+	globalThis.Function = wrap(Function, "Function");
+	wrap(Object.getPrototypeOf(function* () {}).constructor, "GeneratorFunction");
+	wrap(Object.getPrototypeOf(async function () {}).constructor, "AsyncFunction");
+})`
+
+// evalGuardProgram is evalGuardJS compiled once for every runtime.
+var evalGuardProgram = sync.OnceValue(func() *goja.Program {
+	return goja.MustCompile(internalSource, evalGuardJS, false)
+})
+
+func (s *Sandbox) registerEval(vm *goja.Runtime) {
+	s.fetched = false
+	s.untrusted = nil
+	s.realEval = vm.Get("eval")
+	if s.cfg.Eval == EvalAllow {
+		return
+	}
+	install, err := vm.RunProgram(evalGuardProgram())
+	if err != nil {
+		panic(fmt.Sprintf("sandbox: installing eval guard: %v", err))
+	}
+	fn, _ := goja.AssertFunction(install)
+	guard := func(name string) {
+		switch {
+		case s.cfg.Eval == EvalDeny:
+			throwError(vm, fmt.Sprintf("%s is disabled in this sandbox (frontmatter eval: deny). Compiling strings into code bypasses review of what runs. Parse data with JSON.parse or string methods; require() loads only files this script didn't write or download.", name))
+		case s.fetched:
+			throwError(vm, fmt.Sprintf("%s is disabled after net.fetch in this script (frontmatter eval: after-fetch): code built from fetched data would run unreviewed. Parse responses with JSON.parse or string methods; require() won't load files this script writes or downloads after fetching either.", name))
+		}
+	}
+	if _, err := fn(goja.Undefined(), vm.ToValue(guard)); err != nil {
+		panic(fmt.Sprintf("sandbox: installing eval guard: %v", err))
+	}
+}
+
+// noteWrite records a file the script wrote, appended to, copied, moved,
+// or downloaded, and reports it to OnWrite. Under eval deny, and once a
+// net.fetch has returned under after-fetch, the path is untrusted:
+// writing fetched text to a file and require()-ing it would compile it
+// just as eval would.
+func (s *Sandbox) noteWrite(path, content string) {
+	if s.cfg.Eval == EvalDeny || (s.cfg.Eval != EvalAllow && s.fetched) {
+		s.untrusted = append(s.untrusted, path)
+	}
+	if s.cfg.OnWrite != nil {
+		s.cfg.OnWrite(path, content)
+	}
+}
+
+// checkRequire refuses to load a file the script made untrusted.
+func (s *Sandbox) checkRequire(path string) error {
+	if !withinAny(path, s.untrusted) {
+		return nil
+	}
+	if s.cfg.Eval == EvalDeny {
+		return fmt.Errorf("require: %s was written by this script, and loading it would compile its contents (frontmatter eval: deny)", path)
+	}
+	return fmt.Errorf("require: %s was written after net.fetch in this script (frontmatter eval: after-fetch): code from fetched data would run unreviewed", path)
+}
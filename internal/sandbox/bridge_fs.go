@@ -1,17 +1,18 @@
 package sandbox
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/dop251/goja"
+	"github.com/thinkingscript/cli/internal/trash"
+	"github.com/thinkingscript/cli/internal/workspace"
 )
 
-const maxGlobMatches = 1000000
-
 func (s *Sandbox) registerFS(vm *goja.Runtime) {
 	fs := vm.NewObject()
 
@@ -19,19 +20,19 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		path := call.Argument(0).String()
 		resolved, err := s.resolvePath("read", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
 		// Check file size before reading
 		info, err := os.Stat(resolved)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.readFile: %s not found", path))
+			throwFsError(vm, fmt.Sprintf("fs.readFile: %s not found", path))
 		}
 		if info.Size() > MaxReadSize {
-			throwError(vm, fmt.Sprintf("fs.readFile: %s exceeds maximum read size (%d MB)", path, MaxReadSize>>20))
+			throwFsError(vm, fmt.Sprintf("fs.readFile: %s exceeds maximum read size (%d MB)", path, MaxReadSize>>20))
 		}
 		data, err := os.ReadFile(resolved)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.readFile: %s not found", path))
+			throwFsError(vm, fmt.Sprintf("fs.readFile: %s not found", path))
 		}
 		return vm.ToValue(string(data))
 	})
@@ -41,18 +42,18 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		content := call.Argument(1).String()
 		// Check content size before writing
 		if len(content) > MaxWriteSize {
-			throwError(vm, fmt.Sprintf("fs.writeFile: content exceeds maximum write size (%d MB)", MaxWriteSize>>20))
+			throwFsError(vm, fmt.Sprintf("fs.writeFile: content exceeds maximum write size (%d MB)", MaxWriteSize>>20))
 		}
 		resolved, err := s.resolvePath("write", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
+		content = s.redactWrite(resolved, content)
+		s.cfg.Journal.Write(resolved)
 		if err := os.WriteFile(resolved, []byte(content), 0644); err != nil {
-			throwError(vm, fmt.Sprintf("fs.writeFile: cannot write %s", path))
-		}
-		if s.cfg.OnWrite != nil {
-			s.cfg.OnWrite(resolved, content)
+			throwFsError(vm, fmt.Sprintf("fs.writeFile: cannot write %s", path))
 		}
+		s.noteWrite(resolved, content)
 		return goja.Undefined()
 	})
 
@@ -60,59 +61,155 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		path := call.Argument(0).String()
 		resolved, err := s.resolvePath("read", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
+		}
+
+		// Without options, return the plain array of direct children.
+		if len(call.Arguments) < 2 || goja.IsUndefined(call.Argument(1)) || goja.IsNull(call.Argument(1)) {
+			entries, _, err := s.listDir(resolved, readDirOptions{})
+			if err != nil {
+				throwFsError(vm, fmt.Sprintf("fs.readDir: cannot read %s", path))
+			}
+			result := make([]map[string]any, 0, len(entries))
+			for _, e := range entries {
+				result = append(result, map[string]any{
+					"name":      e.name,
+					"isDir":     e.isDir,
+					"isSymlink": e.isSymlink,
+					"size":      e.size,
+					"mode":      e.mode,
+				})
+			}
+			return vm.ToValue(result)
+		}
+
+		opts := readDirOptions{MaxEntries: DefaultReadDirPage}
+		o := call.Argument(1).ToObject(vm)
+		if v := o.Get("recursive"); v != nil && !goja.IsUndefined(v) {
+			opts.Recursive = v.ToBoolean()
+		}
+		if v := o.Get("maxEntries"); v != nil && !goja.IsUndefined(v) {
+			opts.MaxEntries = int(v.ToInteger())
+		}
+		if v := o.Get("offset"); v != nil && !goja.IsUndefined(v) {
+			opts.Offset = max(int(v.ToInteger()), 0)
+		}
+		if v := o.Get("sort"); v != nil && !goja.IsUndefined(v) {
+			opts.Sort = v.String()
+		}
+
+		entries, hasMore, err := s.listDir(resolved, opts)
+		if errors.Is(err, errGlobLimit) {
+			throwFsError(vm, fmt.Sprintf("fs.readDir: %s has too many entries (limit %d)", path, maxGlobMatches))
 		}
-		entries, err := os.ReadDir(resolved)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.readDir: cannot read %s", path))
+			if errors.Is(err, errBadSort) {
+				throwFsError(vm, "fs.readDir: "+err.Error())
+			}
+			throwFsError(vm, fmt.Sprintf("fs.readDir: cannot read %s", path))
 		}
 		result := make([]map[string]any, 0, len(entries))
 		for _, e := range entries {
-			info, err := e.Info()
+			result = append(result, e.toJS())
+		}
+		return vm.ToValue(map[string]any{
+			"entries":    result,
+			"hasMore":    hasMore,
+			"nextOffset": opts.Offset + len(entries),
+		})
+	})
+
+	fs.Set("stat", func(call goja.FunctionCall) goja.Value {
+		result, err := s.stat("fs.stat", call.Argument(0).String())
+		if err != nil {
+			throwFsError(vm, err.Error())
+		}
+		return vm.ToValue(result)
+	})
+
+	// fs.statMany stats a list of paths in one bridge call. A path that
+	// can't be statted gets {path, error, code} instead of throwing, so
+	// one missing file doesn't lose the rest.
+	fs.Set("statMany", func(call goja.FunctionCall) goja.Value {
+		var paths []string
+		if err := vm.ExportTo(call.Argument(0), &paths); err != nil {
+			throwFsError(vm, "fs.statMany: paths must be an array of strings")
+		}
+		results := make([]map[string]any, 0, len(paths))
+		for _, path := range paths {
+			result, err := s.stat("fs.statMany", path)
+			if s.interrupted {
+				throwFsError(vm, "fs.statMany: interrupted")
+			}
 			if err != nil {
-				continue
+				result = map[string]any{"error": err.Error(), "code": errorCode(err.Error())}
 			}
-			result = append(result, map[string]any{
-				"name":  e.Name(),
-				"isDir": e.IsDir(),
-				"size":  info.Size(),
-			})
+			result["path"] = path
+			results = append(results, result)
 		}
-		return vm.ToValue(result)
+		return vm.ToValue(results)
 	})
 
-	fs.Set("stat", func(call goja.FunctionCall) goja.Value {
+	fs.Set("du", func(call goja.FunctionCall) goja.Value {
 		path := call.Argument(0).String()
-		resolved, err := s.resolvePath("read", path)
+		resolved, err := s.resolvePath("list", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
+		}
+		opts := duOptions{MaxDepth: 1, MaxEntries: DefaultDuMaxEntries}
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) && !goja.IsNull(call.Argument(1)) {
+			o := call.Argument(1).ToObject(vm)
+			if v := o.Get("maxDepth"); v != nil && !goja.IsUndefined(v) {
+				opts.MaxDepth = max(int(v.ToInteger()), 0)
+			}
+			if v := o.Get("maxEntries"); v != nil && !goja.IsUndefined(v) {
+				opts.MaxEntries = max(int(v.ToInteger()), 0)
+			}
+		}
+		res, err := s.diskUsage(resolved, opts)
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			throwFsError(vm, fmt.Sprintf("fs.du: cancelled while measuring %s", path))
 		}
-		info, err := os.Stat(resolved)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.stat: %s not found", path))
+			throwFsError(vm, fmt.Sprintf("fs.du: %s not found", path))
 		}
-		return vm.ToValue(map[string]any{
-			"name":    info.Name(),
-			"isDir":   info.IsDir(),
-			"size":    info.Size(),
-			"modTime": info.ModTime().Unix(),
-		})
+		return vm.ToValue(res.toJS())
 	})
 
 	fs.Set("delete", func(call goja.FunctionCall) goja.Value {
 		path := call.Argument(0).String()
 		resolved, err := s.resolvePath("delete", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
 		// Block deletion of sandbox root directories themselves
 		for _, allowed := range s.allowedPaths {
 			if resolved == allowed {
-				throwError(vm, fmt.Sprintf("fs.delete: cannot delete sandbox root %s", path))
+				throwFsError(vm, fmt.Sprintf("fs.delete: cannot delete sandbox root %s", path))
+			}
+		}
+		permanent := false
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) && !goja.IsNull(call.Argument(1)) {
+			if v := call.Argument(1).ToObject(vm).Get("permanent"); v != nil && !goja.IsUndefined(v) {
+				permanent = v.ToBoolean()
 			}
 		}
+		// Outside the workspace, deletes go to the thought's trash so a
+		// wrong call can be undone with 'thought trash restore'.
+		if s.cfg.TrashDir != "" && !permanent && !withinAny(resolved, s.trashExempt) {
+			if _, err := os.Lstat(resolved); os.IsNotExist(err) {
+				return goja.Undefined()
+			}
+			e, err := trash.Put(s.cfg.TrashDir, resolved)
+			if err != nil {
+				throwFsError(vm, fmt.Sprintf("fs.delete: cannot move %s to trash", path))
+			}
+			s.cfg.Journal.Trash(resolved, e.ID)
+			return goja.Undefined()
+		}
+		s.cfg.Journal.Remove(resolved)
 		if err := os.RemoveAll(resolved); err != nil {
-			throwError(vm, fmt.Sprintf("fs.delete: cannot delete %s", path))
+			throwFsError(vm, fmt.Sprintf("fs.delete: cannot delete %s", path))
 		}
 		return goja.Undefined()
 	})
@@ -131,10 +228,11 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		path := call.Argument(0).String()
 		resolved, err := s.resolvePath("write", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
+		s.cfg.Journal.Mkdir(resolved)
 		if err := os.MkdirAll(resolved, 0755); err != nil {
-			throwError(vm, fmt.Sprintf("fs.mkdir: cannot create %s", path))
+			throwFsError(vm, fmt.Sprintf("fs.mkdir: cannot create %s", path))
 		}
 		return goja.Undefined()
 	})
@@ -144,37 +242,39 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		dst := call.Argument(1).String()
 		resolvedSrc, err := s.resolvePath("read", src)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
 		// Check source file size before copying
 		info, err := os.Stat(resolvedSrc)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.copy: cannot read %s", src))
+			throwFsError(vm, fmt.Sprintf("fs.copy: cannot read %s", src))
 		}
 		if info.Size() > MaxCopySize {
-			throwError(vm, fmt.Sprintf("fs.copy: %s exceeds maximum copy size (%d MB)", src, MaxCopySize>>20))
+			throwFsError(vm, fmt.Sprintf("fs.copy: %s exceeds maximum copy size (%d MB)", src, MaxCopySize>>20))
 		}
 		resolvedDst, err := s.resolvePath("write", dst)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
 		in, err := os.Open(resolvedSrc)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.copy: cannot read %s", src))
+			throwFsError(vm, fmt.Sprintf("fs.copy: cannot read %s", src))
 		}
 		defer in.Close()
+		s.cfg.Journal.Write(resolvedDst)
 		out, err := os.Create(resolvedDst)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.copy: cannot write %s", dst))
+			throwFsError(vm, fmt.Sprintf("fs.copy: cannot write %s", dst))
 		}
 		defer out.Close()
 		// Use LimitReader as defense in depth
 		if _, err := io.Copy(out, io.LimitReader(in, MaxCopySize+1)); err != nil {
-			throwError(vm, fmt.Sprintf("fs.copy: failed copying %s to %s", src, dst))
+			throwFsError(vm, fmt.Sprintf("fs.copy: failed copying %s to %s", src, dst))
 		}
 		if err := out.Sync(); err != nil {
-			throwError(vm, fmt.Sprintf("fs.copy: failed syncing %s", dst))
+			throwFsError(vm, fmt.Sprintf("fs.copy: failed syncing %s", dst))
 		}
+		s.noteWrite(resolvedDst, "")
 		return goja.Undefined()
 	})
 
@@ -183,15 +283,17 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		dst := call.Argument(1).String()
 		resolvedSrc, err := s.resolvePath("delete", src)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
 		resolvedDst, err := s.resolvePath("write", dst)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
+		s.cfg.Journal.Move(resolvedSrc, resolvedDst)
 		if err := os.Rename(resolvedSrc, resolvedDst); err != nil {
-			throwError(vm, fmt.Sprintf("fs.move: cannot move %s to %s", src, dst))
+			throwFsError(vm, fmt.Sprintf("fs.move: cannot move %s to %s", src, dst))
 		}
+		s.noteWrite(resolvedDst, "")
 		return goja.Undefined()
 	})
 
@@ -200,128 +302,138 @@ func (s *Sandbox) registerFS(vm *goja.Runtime) {
 		content := call.Argument(1).String()
 		// Check content size before appending
 		if len(content) > MaxAppendSize {
-			throwError(vm, fmt.Sprintf("fs.appendFile: content exceeds maximum append size (%d MB)", MaxAppendSize>>20))
+			throwFsError(vm, fmt.Sprintf("fs.appendFile: content exceeds maximum append size (%d MB)", MaxAppendSize>>20))
 		}
 		resolved, err := s.resolvePath("write", path)
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
 		}
+		content = s.redactWrite(resolved, content)
+		s.cfg.Journal.Write(resolved)
 		f, err := os.OpenFile(resolved, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("fs.appendFile: cannot open %s", path))
+			throwFsError(vm, fmt.Sprintf("fs.appendFile: cannot open %s", path))
 		}
 		defer f.Close()
 		if _, err := f.WriteString(content); err != nil {
-			throwError(vm, fmt.Sprintf("fs.appendFile: cannot write to %s", path))
+			throwFsError(vm, fmt.Sprintf("fs.appendFile: cannot write to %s", path))
 		}
+		s.noteWrite(resolved, content)
 		return goja.Undefined()
 	})
 
 	fs.Set("glob", func(call goja.FunctionCall) goja.Value {
 		pattern := call.Argument(0).String()
-
-		// Resolve the base directory (everything before the first wildcard)
-		base := pattern
-		for i, c := range base {
-			if c == '*' || c == '?' || c == '[' {
-				base = base[:i]
-				break
+		withSkipped := false
+		if len(call.Arguments) > 1 && !goja.IsUndefined(call.Argument(1)) && !goja.IsNull(call.Argument(1)) {
+			opts := call.Argument(1).ToObject(vm)
+			if v := opts.Get("withSkipped"); v != nil && !goja.IsUndefined(v) {
+				withSkipped = v.ToBoolean()
 			}
 		}
-		base = filepath.Dir(base)
-		if base == "" {
-			base = "."
-		}
 
-		// Resolve the base through sandbox path checks
-		resolvedBase, err := s.resolvePath("list", base)
+		matches, skipped, err := s.glob(pattern)
+		if errors.Is(err, errGlobLimit) {
+			throwFsError(vm, fmt.Sprintf("fs.glob: pattern %q returned too many matches (limit %d)", pattern, maxGlobMatches))
+		}
 		if err != nil {
-			throwError(vm, err.Error())
+			throwFsError(vm, err.Error())
+		}
+		if matches == nil {
+			matches = []string{}
 		}
 
-		// If pattern uses **, walk recursively; otherwise use filepath.Glob
-		var matches []string
-		if strings.Contains(pattern, "**") {
-			// Replace base in pattern with resolved base for matching
-			relPattern := strings.TrimPrefix(pattern, base)
-			relPattern = strings.TrimPrefix(relPattern, string(filepath.Separator))
-
-			filepath.WalkDir(resolvedBase, func(path string, d os.DirEntry, err error) error {
-				if err != nil {
-					return nil // skip errors
-				}
-				if len(matches) >= maxGlobMatches {
-					return fmt.Errorf("glob match limit exceeded")
-				}
-				// Get path relative to resolved base for matching
-				rel, err := filepath.Rel(resolvedBase, path)
-				if err != nil {
-					return nil
-				}
-				// Match against the ** pattern by trying each path segment depth
-				if globMatch(relPattern, rel) {
-					matches = append(matches, path)
-				}
-				return nil
-			})
-		} else {
-			// Resolve absolute pattern for filepath.Glob
-			absPattern := pattern
-			if !filepath.IsAbs(absPattern) {
-				absPattern = filepath.Join(s.cfg.WorkDir, absPattern)
+		if withSkipped {
+			if skipped == nil {
+				skipped = []globSkip{}
 			}
-			raw, _ := filepath.Glob(absPattern)
-			// Re-validate each match through sandbox path checks
-			for _, m := range raw {
-				if _, err := s.resolvePath("read", m); err == nil {
-					matches = append(matches, m)
-				}
-				if len(matches) >= maxGlobMatches {
-					break
-				}
+			skippedVals := make([]map[string]any, len(skipped))
+			for i, sk := range skipped {
+				skippedVals[i] = map[string]any{"path": sk.Path, "reason": sk.Reason}
 			}
+			return vm.ToValue(map[string]any{"matches": matches, "skipped": skippedVals})
 		}
-
-		if len(matches) >= maxGlobMatches {
-			throwError(vm, fmt.Sprintf("fs.glob: pattern %q returned too many matches (limit %d)", pattern, maxGlobMatches))
+		if len(skipped) > 0 {
+			fmt.Fprintf(s.cfg.Stderr, "fs.glob: skipped %d unreadable or denied directories (pass {withSkipped: true} for details)\n", len(skipped))
 		}
-
 		return vm.ToValue(matches)
 	})
 
+	// fs.promote(path) copies a file or directory from a per-run workspace
+	// into the persistent one when the run succeeds. Relative paths are
+	// relative to the run workspace, exposed as fs.workspace.
+	fs.Set("promote", func(call goja.FunctionCall) goja.Value {
+		path := call.Argument(0).String()
+		if s.cfg.Workspace == nil {
+			throwFsError(vm, workspace.ErrNotPerRun.Error())
+		}
+		if s.cfg.ReadOnly {
+			throwFsError(vm, fmt.Sprintf("read-only mode: cannot promote %s", path))
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.cfg.Workspace.Dir(), path)
+		}
+		resolved, err := s.resolvePath("read", path)
+		if err != nil {
+			throwFsError(vm, err.Error())
+		}
+		dest, err := s.cfg.Workspace.Promote(resolved)
+		if err != nil {
+			throwFsError(vm, err.Error())
+		}
+		return vm.ToValue(dest)
+	})
+	if s.cfg.Workspace != nil {
+		fs.Set("workspace", s.cfg.Workspace.Dir())
+	}
+
 	vm.Set("fs", fs)
 }
 
-// globMatch matches a path against a pattern supporting ** for recursive matching.
-func globMatch(pattern, path string) bool {
-	// Split pattern and path into segments
-	patParts := strings.Split(filepath.ToSlash(pattern), "/")
-	pathParts := strings.Split(filepath.ToSlash(path), "/")
-	return globMatchParts(patParts, pathParts)
+// isSymlink reports whether the path as the script wrote it (before
+// symlink resolution) is a symlink.
+// stat returns the fs.stat object for path. op names the calling bridge
+// function in errors.
+func (s *Sandbox) stat(op, path string) (map[string]any, error) {
+	resolved, err := s.resolvePath("read", path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s not found", op, path)
+	}
+	result := map[string]any{
+		"name":      info.Name(),
+		"isDir":     info.IsDir(),
+		"size":      info.Size(),
+		"modTime":   info.ModTime().Unix(),
+		"mode":      info.Mode().String(),
+		"perm":      fmt.Sprintf("%04o", info.Mode().Perm()),
+		"isSymlink": s.isSymlink(path),
+	}
+	if uid, gid, ok := fileOwner(info); ok {
+		result["uid"] = uid
+		result["gid"] = gid
+	}
+	return result, nil
 }
 
-func globMatchParts(pat, path []string) bool {
-	for len(pat) > 0 && len(path) > 0 {
-		if pat[0] == "**" {
-			// ** matches zero or more directories
-			rest := pat[1:]
-			for i := 0; i <= len(path); i++ {
-				if globMatchParts(rest, path[i:]) {
-					return true
-				}
-			}
-			return false
-		}
-		matched, _ := filepath.Match(pat[0], path[0])
-		if !matched {
-			return false
-		}
-		pat = pat[1:]
-		path = path[1:]
+func (s *Sandbox) isSymlink(userPath string) bool {
+	abs := userPath
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(s.cfg.WorkDir, abs)
 	}
-	// Handle trailing **
-	if len(pat) == 1 && pat[0] == "**" {
-		return true
+	info, err := os.Lstat(abs)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// redactWrite masks the run's secret values in content written to path
+// when path is under RedactPaths, so a memory never keeps a token the
+// script read.
+func (s *Sandbox) redactWrite(path, content string) string {
+	if !withinAny(path, s.redactPaths) {
+		return content
 	}
-	return len(pat) == 0 && len(path) == 0
+	return s.cfg.Redact.String(content)
 }
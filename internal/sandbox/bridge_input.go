@@ -26,7 +26,7 @@ func (s *Sandbox) registerInput(vm *goja.Runtime) {
 			throwError(vm, "input.prompt: no interactive input available")
 		}
 
-		answer, err := s.cfg.PromptInput(question, defaultValue)
+		answer, err := interruptible(s.ctx, func() (string, error) { return s.cfg.PromptInput(question, defaultValue) })
 		if err != nil {
 			s.checkInterrupted(err)
 			throwError(vm, "input.prompt: "+err.Error())
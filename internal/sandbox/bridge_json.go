@@ -0,0 +1,232 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+var errStreamLimit = errors.New("json.stream limit reached")
+
+// jsonBridge returns the function that adds the json global to vm. It
+// takes vm's JSON.parse now, while newRuntime sets vm up, so a script that
+// replaces JSON.parse can't change what json.stream returns.
+func (s *Sandbox) jsonBridge(vm *goja.Runtime) func(*goja.Runtime) {
+	parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	return func(vm *goja.Runtime) { s.registerJSON(vm, parse) }
+}
+
+func (s *Sandbox) registerJSON(vm *goja.Runtime, parse goja.Callable) {
+	j := vm.NewObject()
+
+	// json.stream(source, selector, options?) returns the values at
+	// selector without parsing the whole document into the runtime.
+	// source is a file path, or JSON text when it starts with { or [.
+	j.Set("stream", func(call goja.FunctionCall) goja.Value {
+		source := call.Argument(0).String()
+		selector := call.Argument(1).String()
+		if goja.IsUndefined(call.Argument(1)) {
+			selector = ""
+		}
+		limit := 0
+		if len(call.Arguments) > 2 && !goja.IsUndefined(call.Argument(2)) && !goja.IsNull(call.Argument(2)) {
+			if v := call.Argument(2).ToObject(vm).Get("limit"); v != nil && !goja.IsUndefined(v) {
+				limit = max(int(v.ToInteger()), 0)
+			}
+		}
+
+		var r io.Reader
+		if t := strings.TrimLeft(source, " \t\r\n"); strings.HasPrefix(t, "{") || strings.HasPrefix(t, "[") {
+			r = strings.NewReader(source)
+		} else {
+			resolved, err := s.resolvePath("read", source)
+			if err != nil {
+				throwFsError(vm, err.Error())
+			}
+			f, err := os.Open(resolved)
+			if err != nil {
+				throwFsError(vm, fmt.Sprintf("json.stream: %s not found", source))
+			}
+			defer f.Close()
+			r = &cancelReader{ctx: s.ctx, r: f}
+		}
+
+		var matches []json.RawMessage
+		err := streamJSON(r, parseSelector(selector), func(v json.RawMessage) error {
+			matches = append(matches, v)
+			if limit > 0 && len(matches) >= limit {
+				return errStreamLimit
+			}
+			return nil
+		})
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			throwFsError(vm, fmt.Sprintf("json.stream: cancelled while reading %s", source))
+		}
+		if err != nil && !errors.Is(err, errStreamLimit) {
+			throwError(vm, "json.stream: "+err.Error())
+		}
+		// Parsed by JSON.parse so objects keep their keys in source order
+		data, err := json.Marshal(matches)
+		if err != nil {
+			throwError(vm, "json.stream: "+err.Error())
+		}
+		if matches == nil {
+			data = []byte("[]")
+		}
+		v, err := parse(goja.Undefined(), vm.ToValue(string(data)))
+		if err != nil {
+			throwError(vm, "json.stream: "+err.Error())
+		}
+		return v
+	})
+
+	vm.Set("json", j)
+}
+
+// parseSelector splits a gjson-style path ("data.items.#.name") into its
+// parts. "\." is a literal dot in a key. "" selects each whole document.
+func parseSelector(sel string) []string {
+	if sel == "" {
+		return nil
+	}
+	var parts []string
+	var b strings.Builder
+	for i := 0; i < len(sel); i++ {
+		switch {
+		case sel[i] == '\\' && i+1 < len(sel):
+			i++
+			b.WriteByte(sel[i])
+		case sel[i] == '.':
+			parts = append(parts, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(sel[i])
+		}
+	}
+	return append(parts, b.String())
+}
+
+// streamJSON reads every top-level value in r (one document, or JSON
+// Lines) and calls emit with each value matching sel. Only matches are
+// decoded; everything else is skipped token by token. In sel, "#" or "*"
+// matches every array element or object member, a number matches that
+// array index, and any other part matches the object key.
+func streamJSON(r io.Reader, sel []string, emit func(json.RawMessage) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		err := streamValue(dec, sel, emit)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return jsonError(dec, err)
+		}
+	}
+}
+
+func streamValue(dec *json.Decoder, sel []string, emit func(json.RawMessage) error) error {
+	if len(sel) == 0 {
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		return emit(v)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return nil // a scalar has nothing below it to select
+	}
+	part := sel[0]
+	switch delim {
+	case '{':
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if part == "#" || part == "*" || key == part {
+				err = streamValue(dec, sel[1:], emit)
+			} else {
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case '[':
+		index, err := strconv.Atoi(part)
+		if err != nil {
+			index = -1
+		}
+		for i := 0; dec.More(); i++ {
+			if part == "#" || part == "*" || i == index {
+				err = streamValue(dec, sel[1:], emit)
+			} else {
+				err = skipValue(dec)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	_, err = dec.Token() // closing } or ]
+	return err
+}
+
+// skipValue consumes the next value without keeping any of it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// cancelReader stops a long read when the run is cancelled.
+type cancelReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *cancelReader) Read(p []byte) (int, error) {
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return c.r.Read(p)
+}
+
+// jsonError words a decoding error with where in the input it happened.
+func jsonError(dec *json.Decoder, err error) error {
+	if errors.Is(err, errStreamLimit) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("invalid JSON: unexpected end of input at offset %d", dec.InputOffset())
+	}
+	return fmt.Errorf("invalid JSON at offset %d: %s", dec.InputOffset(), err)
+}
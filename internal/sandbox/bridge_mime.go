@@ -0,0 +1,88 @@
+package sandbox
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+)
+
+// mimeSniffSize is how much of a file mime.detect reads.
+const mimeSniffSize = 4 << 10
+
+func (s *Sandbox) registerMime(vm *goja.Runtime) {
+	m := vm.NewObject()
+
+	m.Set("detect", func(call goja.FunctionCall) goja.Value {
+		path := call.Argument(0).String()
+		resolved, err := s.resolvePath("read", path)
+		if err != nil {
+			throwFsError(vm, err.Error())
+		}
+		typ, err := detectMime(resolved)
+		if err != nil {
+			throwFsError(vm, fmt.Sprintf("mime.detect: cannot read %s", path))
+		}
+		return vm.ToValue(typ)
+	})
+
+	vm.Set("mime", m)
+}
+
+// detectMime sniffs the first few KB of a file. When the content alone is
+// inconclusive (plain text or unknown binary) the extension refines it, so
+// "data.json" is application/json rather than text/plain.
+func detectMime(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "inode/directory", nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, mimeSniffSize))
+	if err != nil {
+		return "", err
+	}
+	if len(head) == 0 {
+		return "inode/x-empty", nil
+	}
+
+	sniffed := http.DetectContentType(head)
+	generic := strings.HasPrefix(sniffed, "text/plain") || sniffed == "application/octet-stream"
+	if !generic {
+		return sniffed, nil
+	}
+	if byExt := mime.TypeByExtension(filepath.Ext(path)); byExt != "" {
+		return byExt, nil
+	}
+	// DetectContentType only looks at 512 bytes; check the whole sample so
+	// text with a late NUL or invalid UTF-8 is reported as binary.
+	if bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(trimPartialRune(head)) {
+		return "application/octet-stream", nil
+	}
+	return "text/plain; charset=utf-8", nil
+}
+
+// trimPartialRune drops a UTF-8 sequence cut off by the sniff limit.
+func trimPartialRune(b []byte) []byte {
+	for i := 0; i < utf8.UTFMax && i < len(b); i++ {
+		if r, _ := utf8.DecodeLastRune(b[:len(b)-i]); r != utf8.RuneError {
+			return b[:len(b)-i]
+		}
+	}
+	return b
+}
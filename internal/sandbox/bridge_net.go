@@ -58,44 +58,7 @@ func (s *Sandbox) registerNet(vm *goja.Runtime) {
 
 	netObj.Set("fetch", func(call goja.FunctionCall) goja.Value {
 		urlStr := call.Argument(0).String()
-
-		// Extract host from URL for approval
-		parsedURL, err := url.Parse(urlStr)
-		if err != nil {
-			throwError(vm, fmt.Sprintf("net.fetch: invalid URL: %s", err.Error()))
-		}
-		host := parsedURL.Hostname()
-
-		// SSRF protection: block requests to private/internal IPs
-		if ip := net.ParseIP(host); ip != nil {
-			if isPrivateIP(ip) {
-				throwError(vm, fmt.Sprintf("net.fetch: access to private IP %s denied", host))
-			}
-		} else {
-			// Resolve hostname and check if it points to private IP
-			ips, err := net.LookupIP(host)
-			if err == nil {
-				for _, ip := range ips {
-					if isPrivateIP(ip) {
-						throwError(vm, fmt.Sprintf("net.fetch: %s resolves to private IP, access denied", host))
-					}
-				}
-			}
-		}
-
-		// Check network access approval
-		if s.cfg.ApproveNet != nil {
-			allowed, err := s.cfg.ApproveNet(host)
-			if err != nil {
-				s.checkInterrupted(err)
-				throwError(vm, fmt.Sprintf("net.fetch: %s", err.Error()))
-			}
-			if !allowed {
-				throwError(vm, fmt.Sprintf("net.fetch: access to %s denied", host))
-			}
-		} else {
-			throwError(vm, "net.fetch: network access denied (no approval handler)")
-		}
+		s.approveURL(vm, "net.fetch", urlStr)
 
 		// Parse options (method, headers, body)
 		method := "GET"
@@ -122,38 +85,168 @@ func (s *Sandbox) registerNet(vm *goja.Runtime) {
 
 		req, err := http.NewRequestWithContext(s.ctx, method, urlStr, body)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("net.fetch: invalid request: %s", err.Error()))
+			throwNetError(vm, fmt.Sprintf("net.fetch: invalid request: %s", err.Error()))
 		}
+		// Secret handles in header values become the secrets' values,
+		// which are only sent over https
+		var used []secret
+		var secretHeaders []string
 		for k, v := range headers {
+			v, u, err := s.revealSecrets(v)
+			if err != nil {
+				throwNetError(vm, "net.fetch: "+err.Error())
+			}
+			if len(u) > 0 {
+				used = append(used, u...)
+				secretHeaders = append(secretHeaders, k)
+			}
 			req.Header.Set(k, v)
 		}
+		client := httpClient
+		if len(used) > 0 {
+			if req.URL.Scheme != "https" {
+				throwNetError(vm, fmt.Sprintf("net.fetch: secrets must be sent over https, not to %s", urlStr))
+			}
+			client = secretClient(req.URL.Host, secretHeaders)
+		}
 
-		resp, err := httpClient.Do(req)
+		// The request is bound to s.ctx, so cancellation aborts both the
+		// round trip and any in-flight body read immediately.
+		resp, err := client.Do(req)
 		if err != nil {
-			throwError(vm, fmt.Sprintf("net.fetch: request to %s failed: %s", urlStr, err.Error()))
+			if s.ctx.Err() != nil {
+				s.interrupted = true
+				throwNetError(vm, "net.fetch: cancelled")
+			}
+			throwNetError(vm, fmt.Sprintf("net.fetch: request to %s failed: %s", urlStr, err.Error()))
 		}
 		defer resp.Body.Close()
 
 		respBody, err := io.ReadAll(io.LimitReader(resp.Body, MaxNetRespSize+1))
 		if err != nil {
-			throwError(vm, fmt.Sprintf("net.fetch: error reading response from %s", urlStr))
+			if s.ctx.Err() != nil {
+				s.interrupted = true
+				throwNetError(vm, "net.fetch: cancelled")
+			}
+			throwNetError(vm, fmt.Sprintf("net.fetch: error reading response from %s", urlStr))
 		}
 		if int64(len(respBody)) > MaxNetRespSize {
-			throwError(vm, fmt.Sprintf("net.fetch: response body from %s exceeds %dMB limit", urlStr, MaxNetRespSize>>20))
+			throwNetError(vm, fmt.Sprintf("net.fetch: response body from %s exceeds %dMB limit", urlStr, MaxNetRespSize>>20))
 		}
 
 		// Convert response headers to a plain object
 		respHeaders := make(map[string]string)
 		for k := range resp.Header {
-			respHeaders[strings.ToLower(k)] = resp.Header.Get(k)
+			respHeaders[strings.ToLower(k)] = redactSecrets(resp.Header.Get(k), used)
 		}
 
+		s.fetched = true
+		if s.cfg.OnFetch != nil {
+			s.cfg.OnFetch(urlStr)
+		}
 		return vm.ToValue(map[string]any{
 			"status":  resp.StatusCode,
 			"headers": respHeaders,
-			"body":    string(respBody),
+			"body":    redactSecrets(string(respBody), used),
+		})
+	})
+
+	netObj.Set("download", func(call goja.FunctionCall) goja.Value {
+		urlStr := call.Argument(0).String()
+		if goja.IsUndefined(call.Argument(1)) || goja.IsNull(call.Argument(1)) {
+			throwNetError(vm, "net.download: a destination path must be given")
+		}
+		dest := call.Argument(1).String()
+		s.approveURL(vm, "net.download", urlStr)
+		resolved, err := s.resolvePath("write", dest)
+		if err != nil {
+			throwFsError(vm, err.Error())
+		}
+
+		var headers map[string]string
+		want := ""
+		if len(call.Arguments) > 2 && !goja.IsUndefined(call.Argument(2)) && !goja.IsNull(call.Argument(2)) {
+			opts := call.Argument(2).ToObject(vm)
+			if h := opts.Get("headers"); h != nil && !goja.IsUndefined(h) {
+				headers = make(map[string]string)
+				hObj := h.ToObject(vm)
+				for _, key := range hObj.Keys() {
+					headers[key] = hObj.Get(key).String()
+				}
+			}
+			if v := opts.Get("sha256"); v != nil && !goja.IsUndefined(v) {
+				want = strings.ToLower(v.String())
+			}
+		}
+
+		// Set before the file lands, so noteWrite marks it untrusted
+		s.fetched = true
+		res, err := s.download(urlStr, resolved, headers, want)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				s.interrupted = true
+				throwNetError(vm, "net.download: cancelled")
+			}
+			throwNetError(vm, fmt.Sprintf("net.download: %s", err.Error()))
+		}
+		if s.cfg.OnDownload != nil {
+			s.cfg.OnDownload(urlStr, resolved, res.sum)
+		}
+
+		return vm.ToValue(map[string]any{
+			"path":   resolved,
+			"size":   res.size,
+			"sha256": res.sum,
+			"cached": res.cached,
 		})
 	})
 
 	vm.Set("net", netObj)
 }
+
+// approveURL throws unless urlStr may be requested: it must parse, must
+// not point at a private address (SSRF protection), and its host must be
+// approved. fn names the bridge function in errors.
+func (s *Sandbox) approveURL(vm *goja.Runtime, fn, urlStr string) {
+	// Extract host from URL for approval
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		throwNetError(vm, fmt.Sprintf("%s: invalid URL: %s", fn, err.Error()))
+	}
+	host := parsedURL.Hostname()
+
+	// SSRF protection: block requests to private/internal IPs
+	if ip := net.ParseIP(host); ip != nil {
+		if isPrivateIP(ip) {
+			throwNetError(vm, fmt.Sprintf("%s: access to private IP %s denied", fn, host))
+		}
+	} else {
+		// Resolve hostname and check if it points to private IP
+		addrs, err := net.DefaultResolver.LookupIPAddr(s.ctx, host)
+		if err != nil && s.ctx.Err() != nil {
+			s.interrupted = true
+			throwNetError(vm, fn+": cancelled")
+		}
+		if err == nil {
+			for _, addr := range addrs {
+				if isPrivateIP(addr.IP) {
+					throwNetError(vm, fmt.Sprintf("%s: %s resolves to private IP, access denied", fn, host))
+				}
+			}
+		}
+	}
+
+	// Check network access approval
+	if s.cfg.ApproveNet != nil {
+		allowed, err := interruptible(s.ctx, func() (bool, error) { return s.cfg.ApproveNet(host) })
+		if err != nil {
+			s.checkInterrupted(err)
+			throwNetError(vm, fmt.Sprintf("%s: %s", fn, err.Error()))
+		}
+		if !allowed {
+			throwNetError(vm, fmt.Sprintf("%s: access to %s denied", fn, host))
+		}
+	} else {
+		throwNetError(vm, fn+": network access denied (no approval handler)")
+	}
+}
@@ -2,7 +2,6 @@ package sandbox
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/dop251/goja"
@@ -33,6 +32,7 @@ func (s *Sandbox) registerProcess(vm *goja.Runtime) {
 		select {
 		case <-time.After(time.Duration(ms) * time.Millisecond):
 		case <-s.ctx.Done():
+			s.interrupted = true
 			throwError(vm, "sleep interrupted")
 		}
 		return goja.Undefined()
@@ -41,10 +41,29 @@ func (s *Sandbox) registerProcess(vm *goja.Runtime) {
 	stdout := vm.NewObject()
 	stdout.Set("write", func(call goja.FunctionCall) goja.Value {
 		text := call.Argument(0).String()
-		fmt.Fprint(os.Stdout, text)
+		fmt.Fprint(s.cfg.Stdout, text)
 		return goja.Undefined()
 	})
 	process.Set("stdout", stdout)
 
+	// process.stdin.on("line"|"end", fn) registers handlers for stream mode.
+	stdin := vm.NewObject()
+	stdin.Set("on", func(call goja.FunctionCall) goja.Value {
+		event := call.Argument(0).String()
+		fn, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			throwError(vm, "process.stdin.on: handler must be a function")
+		}
+		if event != "line" && event != "end" {
+			throwError(vm, fmt.Sprintf("process.stdin.on: unknown event %q (expected \"line\" or \"end\")", event))
+		}
+		if s.stdinHandlers == nil {
+			s.stdinHandlers = make(map[string]goja.Callable)
+		}
+		s.stdinHandlers[event] = fn
+		return goja.Undefined()
+	})
+	process.Set("stdin", stdin)
+
 	vm.Set("process", process)
 }
@@ -0,0 +1,124 @@
+package sandbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/thinkingscript/cli/internal/secrets"
+)
+
+// secret is a value secrets.get read. It stays in Go: the script only
+// gets a handle, which net.fetch replaces with the value in request
+// headers, so the value never reaches the JS heap, the script's output,
+// or the transcript.
+type secret struct {
+	name  string
+	value string
+}
+
+// secretHandleRE matches the handles secrets.get returns, such as
+// {{secret:GITHUB_TOKEN:3f9a1c0d2b4e6a8c}}. The random part keeps a script
+// from using a secret it didn't get in this run.
+var secretHandleRE = regexp.MustCompile(`\{\{secret:[A-Za-z0-9_.-]+:[0-9a-f]{16}\}\}`)
+
+func (s *Sandbox) registerSecrets(vm *goja.Runtime) {
+	obj := vm.NewObject()
+
+	obj.Set("get", func(call goja.FunctionCall) goja.Value {
+		name := call.Argument(0).String()
+		if err := secrets.ValidateName(name); err != nil {
+			throwError(vm, "secrets.get: "+err.Error())
+		}
+		if s.cfg.ApproveSecret == nil {
+			throwError(vm, "secrets.get: access denied for "+name+" (no approval handler)")
+		}
+		approved, err := interruptible(s.ctx, func() (bool, error) { return s.cfg.ApproveSecret(name) })
+		if err != nil {
+			s.checkInterrupted(err)
+			throwError(vm, "secrets.get: "+err.Error())
+		}
+		if !approved {
+			throwError(vm, "secrets.get: access denied for "+name)
+		}
+
+		read := s.cfg.Secret
+		if read == nil {
+			read = func(name string) (string, error) { return secrets.Get(s.ctx, name) }
+		}
+		value, err := read(name)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				s.interrupted = true
+				throwError(vm, "secrets.get: cancelled")
+			}
+			throwError(vm, fmt.Sprintf("secrets.get: %s: %v", name, err))
+		}
+
+		s.cfg.Redact.Add(value)
+
+		var b [8]byte
+		rand.Read(b[:])
+		handle := "{{secret:" + name + ":" + hex.EncodeToString(b[:]) + "}}"
+		if s.secrets == nil {
+			s.secrets = make(map[string]secret)
+		}
+		s.secrets[handle] = secret{name: name, value: value}
+		return vm.ToValue(handle)
+	})
+
+	vm.Set("secrets", obj)
+}
+
+// revealSecrets replaces the secret handles in a header value with the
+// secrets' values, and returns the secrets it used.
+func (s *Sandbox) revealSecrets(value string) (string, []secret, error) {
+	var used []secret
+	var unknown string
+	out := secretHandleRE.ReplaceAllStringFunc(value, func(handle string) string {
+		sec, ok := s.secrets[handle]
+		if !ok {
+			unknown = handle
+			return handle
+		}
+		used = append(used, sec)
+		return sec.value
+	})
+	if unknown != "" {
+		return "", nil, fmt.Errorf("unknown secret handle %s (handles only work in the run that called secrets.get)", unknown)
+	}
+	return out, used, nil
+}
+
+// redactSecrets replaces the values of used in text, for responses that
+// echo a header back.
+func redactSecrets(text string, used []secret) string {
+	for _, sec := range used {
+		text = strings.ReplaceAll(text, sec.value, "[secret "+sec.name+"]")
+	}
+	return text
+}
+
+// secretClient returns httpClient with a redirect policy that drops the
+// headers carrying secrets when a redirect leaves host or https, so the
+// secrets only reach the host the script asked for.
+func secretClient(host string, headers []string) *http.Client {
+	c := *httpClient
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if req.URL.Host != host || req.URL.Scheme != "https" {
+			for _, k := range headers {
+				req.Header.Del(k)
+			}
+		}
+		return nil
+	}
+	return &c
+}
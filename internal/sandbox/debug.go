@@ -0,0 +1,306 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+)
+
+// Debug pauses a script between statements for `thought debug`. With
+// Config.Debug set, Run instruments the code so Step is called before
+// every statement that sits in a statement list (a program, block,
+// function body, or switch case), and Op is called for every fs, net,
+// env, and sys bridge call. Code loaded with require() is not instrumented.
+type Debug struct {
+	Step func(s Step) error // returns when the script may go on; an error stops it
+	Op   func(call string)  // e.g. `fs.readFile("config.json")`; nil = not reported
+}
+
+// Step is the script's state at a pause.
+type Step struct {
+	Line  int      // 1-based line of the statement about to run
+	Depth int      // call depth; larger inside function calls
+	Names []string // variables declared in the innermost enclosing function, or top-level ones
+
+	eval func(expr string) (string, error)
+}
+
+// Eval evaluates expr where the script is paused, so local variables are
+// in scope, and formats the result for display.
+func (s Step) Eval(expr string) (string, error) {
+	return s.eval(expr)
+}
+
+// debugHook is the function instrumented code calls before each statement.
+const debugHook = "__thinkDebug"
+
+// instrument inserts a call to debugHook before each statement in a
+// statement list. Inserted text never contains a newline, so line numbers
+// in errors are unchanged. Each call passes the statement's line, an index
+// into the returned table of variable names, and an arrow function that
+// evaluates an expression in the statement's scope. Directive prologues
+// ("use strict") are left in front.
+func instrument(code string) (string, [][]string, error) {
+	prog, err := parser.ParseFile(nil, "", code, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	w := &instrumenter{names: map[ast.Node][]string{}, seen: map[ast.Statement]bool{}}
+	w.walk(reflect.ValueOf(prog), prog)
+
+	var tables [][]string
+	tableOf := map[ast.Node]int{}
+	type insertion struct {
+		offset int
+		text   string
+	}
+	var ins []insertion
+	for _, st := range w.stmts {
+		idx, ok := tableOf[st.scope]
+		if !ok {
+			idx = len(tables)
+			tableOf[st.scope] = idx
+			names := w.names[st.scope]
+			sort.Strings(names)
+			tables = append(tables, names)
+		}
+		offset := statementStart(code, st.node)
+		if offset < 0 || offset >= len(code) {
+			continue
+		}
+		line := strings.Count(code[:offset], "\n") + 1
+		ins = append(ins, insertion{offset, fmt.Sprintf("%s(%d, %d, (__e) => eval(__e)); ", debugHook, line, idx)})
+	}
+	sort.SliceStable(ins, func(i, j int) bool { return ins[i].offset < ins[j].offset })
+
+	var b strings.Builder
+	last := 0
+	for _, in := range ins {
+		b.WriteString(code[last:in.offset])
+		b.WriteString(in.text)
+		last = in.offset
+	}
+	b.WriteString(code[last:])
+	return b.String(), tables, nil
+}
+
+// statementStart returns the byte offset where st begins. goja's parser
+// doesn't record where the "if" keyword is, so that is found before the
+// condition.
+func statementStart(code string, st ast.Statement) int {
+	if is, ok := st.(*ast.IfStatement); ok && is.If == 0 {
+		end := int(is.Test.Idx0()) - 1
+		if end < 0 || end > len(code) {
+			return -1
+		}
+		return strings.LastIndex(code[:end], "if")
+	}
+	return int(st.Idx0()) - 1
+}
+
+type instrumenter struct {
+	stmts []struct {
+		node  ast.Statement
+		scope ast.Node
+	}
+	names map[ast.Node][]string // scope → declared names
+	seen  map[ast.Statement]bool
+}
+
+var astPkg = reflect.TypeOf(ast.Program{}).PkgPath()
+
+// walk visits every AST node under v, tracking the innermost function
+// (or the program) as the scope of the statements and names it finds.
+func (w *instrumenter) walk(v reflect.Value, scope ast.Node) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		if n, ok := v.Interface().(ast.Node); ok {
+			switch n := n.(type) {
+			case *ast.FunctionLiteral:
+				if n.Name != nil && scope != nil {
+					w.declare(scope, n.Name.Name.String())
+				}
+				scope = n
+			case *ast.ArrowFunctionLiteral:
+				scope = n
+			case *ast.Program:
+				w.statements(n.Body, scope, true)
+			case *ast.BlockStatement:
+				_, fn := scope.(*ast.FunctionLiteral)
+				_, arrow := scope.(*ast.ArrowFunctionLiteral)
+				w.statements(n.List, scope, fn || arrow)
+			case *ast.CaseStatement:
+				w.statements(n.Consequent, scope, false)
+			case *ast.Binding:
+				if id, ok := n.Target.(*ast.Identifier); ok {
+					w.declare(scope, id.Name.String())
+				}
+			case *ast.CatchStatement:
+				if id, ok := n.Parameter.(*ast.Identifier); ok {
+					w.declare(scope, id.Name.String())
+				}
+			}
+		}
+		w.walk(v.Elem(), scope)
+	case reflect.Struct:
+		if v.Type().PkgPath() != astPkg {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			w.walk(v.Field(i), scope)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			w.walk(v.Index(i), scope)
+		}
+	}
+}
+
+// statements records the statements of a list for instrumentation. A
+// function body or program keeps its leading string-literal directives
+// first. Function declarations are hoisted, so they aren't stopped at.
+func (w *instrumenter) statements(list []ast.Statement, scope ast.Node, body bool) {
+	if body {
+		list = list[len(directives(list)):]
+	}
+	for _, st := range list {
+		switch st.(type) {
+		case *ast.FunctionDeclaration, *ast.EmptyStatement:
+			continue
+		}
+		if w.seen[st] {
+			continue
+		}
+		w.seen[st] = true
+		w.stmts = append(w.stmts, struct {
+			node  ast.Statement
+			scope ast.Node
+		}{st, scope})
+	}
+}
+
+// directives returns the directive prologue at the start of list.
+func directives(list []ast.Statement) []ast.Statement {
+	for i, st := range list {
+		es, ok := st.(*ast.ExpressionStatement)
+		if !ok {
+			return list[:i]
+		}
+		if _, ok := es.Expression.(*ast.StringLiteral); !ok {
+			return list[:i]
+		}
+	}
+	return list
+}
+
+func (w *instrumenter) declare(scope ast.Node, name string) {
+	for _, n := range w.names[scope] {
+		if n == name {
+			return
+		}
+	}
+	w.names[scope] = append(w.names[scope], name)
+}
+
+// registerDebug installs debugHook and wraps the fs, net, env, and sys
+// bridges so each call is reported to Debug.Op.
+func (s *Sandbox) registerDebug(vm *goja.Runtime, tables [][]string) {
+	d := s.cfg.Debug
+	vm.Set(debugHook, func(call goja.FunctionCall) goja.Value {
+		line := int(call.Argument(0).ToInteger())
+		var names []string
+		if i := int(call.Argument(1).ToInteger()); i >= 0 && i < len(tables) {
+			names = tables[i]
+		}
+		evalIn, _ := goja.AssertFunction(call.Argument(2))
+		step := Step{
+			Line:  line,
+			Depth: len(vm.CaptureCallStack(0, nil)),
+			Names: names,
+			eval: func(expr string) (string, error) {
+				// The eval guard replaces the global eval; the intrinsic one
+				// is needed for a direct eval that sees local variables.
+				guarded := vm.Get("eval")
+				vm.Set("eval", s.realEval)
+				defer vm.Set("eval", guarded)
+				v, err := evalIn(goja.Undefined(), vm.ToValue(expr))
+				if err != nil {
+					if ex, ok := err.(*goja.Exception); ok {
+						return "", fmt.Errorf("%s", ex.Value().String())
+					}
+					return "", err
+				}
+				return inspect(vm, v), nil
+			},
+		}
+		if err := d.Step(step); err != nil {
+			vm.Interrupt(err.Error())
+		}
+		return goja.Undefined()
+	})
+
+	if d.Op == nil {
+		return
+	}
+	for _, name := range []string{"fs", "net", "env", "sys"} {
+		obj := vm.Get(name)
+		if obj == nil || goja.IsUndefined(obj) {
+			continue
+		}
+		o := obj.ToObject(vm)
+		for _, key := range o.Keys() {
+			fn, ok := goja.AssertFunction(o.Get(key))
+			if !ok {
+				continue
+			}
+			label := name + "." + key
+			o.Set(key, func(call goja.FunctionCall) goja.Value {
+				args := make([]string, len(call.Arguments))
+				for i, a := range call.Arguments {
+					args[i] = inspect(vm, a)
+					if len(args[i]) > 60 {
+						args[i] = args[i][:57] + "..."
+					}
+				}
+				d.Op(label + "(" + strings.Join(args, ", ") + ")")
+				v, err := fn(call.This, call.Arguments...)
+				if err != nil {
+					panic(err)
+				}
+				return v
+			})
+		}
+	}
+}
+
+// inspect formats a value for the debugger: strings quoted, objects as
+// JSON, functions by name.
+func inspect(vm *goja.Runtime, v goja.Value) string {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		if v == nil {
+			return "undefined"
+		}
+		return v.String()
+	}
+	if _, ok := goja.AssertFunction(v); ok {
+		name := v.ToObject(vm).Get("name")
+		if name != nil && name.String() != "" {
+			return "[Function: " + name.String() + "]"
+		}
+		return "[Function]"
+	}
+	if s, ok := v.Export().(string); ok {
+		q, _ := json.Marshal(s)
+		return string(q)
+	}
+	return stringify(vm, v)
+}
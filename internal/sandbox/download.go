@@ -0,0 +1,145 @@
+package sandbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/thinkingscript/cli/internal/blobcache"
+)
+
+// downloadClient is httpClient without the overall timeout: downloads may
+// take much longer than 30s, and the run's context still cancels them.
+var downloadClient = &http.Client{Transport: httpClient.Transport}
+
+var errDownloadTooLarge = fmt.Errorf("download exceeds %d GB limit", MaxDownloadSize>>30)
+
+// downloadResult is what net.download returns besides the path.
+type downloadResult struct {
+	size   int64
+	sum    string // hex SHA-256 of the content
+	cached bool   // the content came from the blob cache
+}
+
+// download fetches rawURL (already approved) into dest (already resolved
+// for writing). With a blob cache, content whose SHA-256 the script
+// expects is copied from the cache without a request, and a cached URL is
+// revalidated with its ETag or Last-Modified, so only changed content is
+// transferred. want, if set, must match the content's SHA-256.
+func (s *Sandbox) download(rawURL, dest string, headers map[string]string, want string) (*downloadResult, error) {
+	dir := s.cfg.BlobCache
+	if dir != "" && want != "" && blobcache.HasBlob(dir, want) {
+		return s.copyBlob(dir, want, dest, true)
+	}
+
+	var entry *blobcache.Entry
+	if dir != "" {
+		entry, _ = blobcache.Lookup(dir, rawURL)
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil && entry.Validates():
+		blobcache.Touch(dir, entry)
+		if err := checkSum(entry.SHA256, want); err != nil {
+			return nil, err
+		}
+		return s.copyBlob(dir, entry.SHA256, dest, true)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("%s returned %s", rawURL, resp.Status)
+	case dir == "":
+		return s.writeDownload(resp.Body, dest, want)
+	}
+
+	entry, err = blobcache.Store(dir, rawURL, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"), resp.Body, MaxDownloadSize)
+	if errors.Is(err, blobcache.ErrTooLarge) {
+		return nil, errDownloadTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSum(entry.SHA256, want); err != nil {
+		return nil, err
+	}
+	return s.copyBlob(dir, entry.SHA256, dest, false)
+}
+
+// copyBlob copies a cached blob to dest.
+func (s *Sandbox) copyBlob(dir, sum, dest string, cached bool) (*downloadResult, error) {
+	f, err := os.Open(blobcache.BlobPath(dir, sum))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	res, err := s.writeDownload(f, dest, "")
+	if err != nil {
+		return nil, err
+	}
+	res.cached = cached
+	return res, nil
+}
+
+// writeDownload writes r to dest through a temporary file in the same
+// directory, so dest is either replaced whole or left alone.
+func (s *Sandbox) writeDownload(r io.Reader, dest, want string) (*downloadResult, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".download-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(r, MaxDownloadSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if n > MaxDownloadSize {
+		return nil, errDownloadTooLarge
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := checkSum(sum, want); err != nil {
+		return nil, err
+	}
+
+	s.cfg.Journal.Write(dest)
+	os.Chmod(tmp.Name(), 0644)
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return nil, err
+	}
+	s.noteWrite(dest, "")
+	return &downloadResult{size: n, sum: sum}, nil
+}
+
+func checkSum(got, want string) error {
+	if want != "" && got != want {
+		return fmt.Errorf("sha256 mismatch: expected %s, got %s", want, got)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package sandbox
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultDuMaxEntries is how many files and directories fs.du walks before
+// it stops and reports a truncated total.
+const DefaultDuMaxEntries = 200000
+
+var errDuLimit = errors.New("du entry limit reached")
+
+// duOptions are the optional second argument to fs.du.
+type duOptions struct {
+	MaxDepth   int // depth of the per-entry breakdown; 0 = totals only
+	MaxEntries int
+}
+
+// duEntry is a file or directory in fs.du's breakdown, with the total of
+// everything under it.
+type duEntry struct {
+	name  string // relative to the measured path, slash-separated
+	isDir bool
+	size  int64
+	files int
+}
+
+// duResult is what fs.du returns.
+type duResult struct {
+	size      int64
+	files     int
+	dirs      int
+	truncated bool
+	entries   []*duEntry // largest first
+}
+
+func (r *duResult) toJS() map[string]any {
+	entries := make([]map[string]any, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, map[string]any{
+			"name":  e.name,
+			"isDir": e.isDir,
+			"size":  e.size,
+			"files": e.files,
+		})
+	}
+	return map[string]any{
+		"size":      r.size,
+		"files":     r.files,
+		"dirs":      r.dirs,
+		"truncated": r.truncated,
+		"entries":   entries,
+	}
+}
+
+// diskUsage totals the sizes of the files under root (already resolved and
+// approved), like du --apparent-size. Entries up to opts.MaxDepth levels
+// below root get their own totals. Like recursive fs.readDir, it doesn't
+// follow symlinks (a link counts as a file of its own size), leaves out
+// unreadable directories, and doesn't enter directories PathDenied
+// rejects. After opts.MaxEntries entries it stops with truncated set.
+func (s *Sandbox) diskUsage(root string, opts duOptions) (*duResult, error) {
+	res := &duResult{}
+	byName := map[string]*duEntry{}
+	walked := 0
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if path == root {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				if info, err := d.Info(); err == nil {
+					res.size, res.files = info.Size(), 1
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			return nil // unreadable subdirectory: leave it out
+		}
+		if walked >= opts.MaxEntries {
+			return errDuLimit
+		}
+		walked++
+		if walked%1000 == 0 && s.ctx != nil && s.ctx.Err() != nil {
+			return s.ctx.Err()
+		}
+
+		rel, _ := filepath.Rel(root, path)
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) <= opts.MaxDepth {
+			name := strings.Join(parts, "/")
+			byName[name] = &duEntry{name: name, isDir: d.IsDir()}
+			res.entries = append(res.entries, byName[name])
+		}
+
+		if d.IsDir() {
+			res.dirs++
+			if s.cfg.PathDenied != nil && s.cfg.PathDenied("list", path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		res.size += info.Size()
+		res.files++
+		// Add the file to itself and each directory above it that's in
+		// the breakdown.
+		for i := 1; i <= min(len(parts), opts.MaxDepth); i++ {
+			e := byName[strings.Join(parts[:i], "/")]
+			e.size += info.Size()
+			e.files++
+		}
+		return nil
+	})
+	if errors.Is(err, errDuLimit) {
+		res.truncated = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(res.entries, func(i, j int) bool {
+		if res.entries[i].size != res.entries[j].size {
+			return res.entries[i].size > res.entries[j].size
+		}
+		return res.entries[i].name < res.entries[j].name
+	})
+	return res, nil
+}
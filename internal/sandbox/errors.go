@@ -0,0 +1,174 @@
+package sandbox
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// Codes set on the Error objects bridges throw, so scripts can branch on
+// err.code instead of matching message text. They follow Node's names
+// where one fits.
+const (
+	CodeDenied    = "EACCES"    // outside the sandbox, refused, or disabled by policy
+	CodeReadOnly  = "EROFS"     // write or delete in read-only mode
+	CodeNotFound  = "ENOENT"    // file or path doesn't exist
+	CodeTooLarge  = "EFBIG"     // a size or count limit was exceeded
+	CodeInvalid   = "EINVAL"    // bad argument
+	CodeCancelled = "ECANCELED" // the run was cancelled or interrupted
+	CodeIO        = "EIO"       // anything else
+)
+
+// errorCode classifies a bridge error message. Bridges word their errors
+// consistently ("access denied", "not found", "exceeds maximum ..."), so
+// the message is enough to pick the code.
+func errorCode(msg string) string {
+	m := strings.ToLower(msg)
+	switch {
+	case strings.Contains(m, "read-only mode"):
+		return CodeReadOnly
+	case strings.Contains(m, "denied"), strings.Contains(m, "is disabled"), strings.Contains(m, "outside the sandbox"):
+		return CodeDenied
+	case strings.Contains(m, "not found"), strings.Contains(m, "not accessible"), strings.Contains(m, "no such file"):
+		return CodeNotFound
+	case strings.Contains(m, "exceeds"), strings.Contains(m, "too many"):
+		return CodeTooLarge
+	case strings.Contains(m, "cancelled"), strings.Contains(m, "interrupted"):
+		return CodeCancelled
+	case strings.Contains(m, "invalid"), strings.Contains(m, "unknown"), strings.Contains(m, "must be"):
+		return CodeInvalid
+	}
+	return CodeIO
+}
+
+// errorClassesJS defines the error classes bridges throw. FsError and
+// NetError also match, with instanceof, any error whose bridge property
+// names their bridge, so a denied fs.readFile is both an
+// AccessDeniedError and an FsError.
+const errorClassesJS = `(function () {
+  function define(name, bridge) {
+    var C = class extends Error {
+      constructor(message, code) {
+        super(message);
+        if (code !== undefined) this.code = code;
+      }
+    };
+    Object.defineProperty(C, "name", {value: name});
+    Object.defineProperty(C.prototype, "name", {value: name, writable: true, configurable: true});
+    if (bridge) {
+      Object.defineProperty(C, Symbol.hasInstance, {value: function (v) {
+        return Function.prototype[Symbol.hasInstance].call(this, v) || (v instanceof Error && v.bridge === bridge);
+      }});
+    }
+    globalThis[name] = C;
+  }
+  define("FsError", "fs");
+  define("NetError", "net");
+  define("AccessDeniedError");
+  define("LimitError");
+})();`
+
+// errorClasses are the globals errorClassesJS defines.
+var errorClasses = []string{"FsError", "NetError", "AccessDeniedError", "LimitError"}
+
+// errorClassesProgram is errorClassesJS compiled once for every runtime.
+var errorClassesProgram = sync.OnceValue(func() *goja.Program {
+	return goja.MustCompile(internalSource, errorClassesJS, false)
+})
+
+// registerErrors installs the error classes as globals.
+func registerErrors(vm *goja.Runtime) {
+	if _, err := vm.RunProgram(errorClassesProgram()); err != nil {
+		panic(err) // a bug in errorClassesJS, not in the script
+	}
+}
+
+// errorClass picks the class a bridge error is thrown as: denials and
+// limits by their code, anything else by the bridge it came from.
+func errorClass(bridge, code string) string {
+	switch {
+	case code == CodeDenied || code == CodeReadOnly:
+		return "AccessDeniedError"
+	case code == CodeTooLarge:
+		return "LimitError"
+	case bridge == "fs":
+		return "FsError"
+	case bridge == "net":
+		return "NetError"
+	}
+	return "Error"
+}
+
+// throwError panics with a JS error for msg, with a code property from
+// errorCode. Scripts can catch it like any Error: e.message, e.code, and
+// e.stack (which points at the script line that made the bridge call).
+func throwError(vm *goja.Runtime, msg string) {
+	throwBridgeError(vm, "", msg)
+}
+
+// throwFsError is throwError for the fs and mime bridges: the error is an
+// FsError unless a more specific class applies, and its bridge is "fs".
+func throwFsError(vm *goja.Runtime, msg string) {
+	throwBridgeError(vm, "fs", msg)
+}
+
+// throwNetError is throwError for the net bridge.
+func throwNetError(vm *goja.Runtime, msg string) {
+	throwBridgeError(vm, "net", msg)
+}
+
+func throwBridgeError(vm *goja.Runtime, bridge, msg string) {
+	code := errorCode(msg)
+	name := errorClass(bridge, code)
+	class := vm.Get(name)
+	if _, ok := goja.AssertConstructor(class); !ok { // the script replaced it
+		name, class = "Error", vm.Get("Error")
+	}
+	obj, err := vm.New(class, vm.ToValue(msg))
+	if err != nil {
+		panic(vm.ToValue(msg))
+	}
+	obj.Set("code", code)
+	if bridge != "" {
+		obj.Set("bridge", bridge)
+	}
+	obj.Set("stack", name+": "+msg+formatStack(vm.CaptureCallStack(0, nil)))
+	panic(obj)
+}
+
+// exceptionMessage formats an uncaught exception as its value ("Error:
+// message") followed by the JS stack, so errors shown to the user or the
+// agent say where in the script they happened.
+func exceptionMessage(ex *goja.Exception) string {
+	return ex.Value().String() + formatStack(ex.Stack())
+}
+
+// internalSource names the setup scripts the sandbox runs itself, so
+// their frames can be left out of stacks.
+const internalSource = "<sandbox>"
+
+// formatStack writes one "at" line per script frame. Frames without source
+// (the bridge functions themselves) and frames in the sandbox's own setup
+// code are left out; they mean nothing to a script. Frames in modules
+// loaded with require() name the module.
+func formatStack(frames []goja.StackFrame) string {
+	var b strings.Builder
+	for _, f := range frames {
+		pos := f.Position()
+		if pos.Line == 0 || f.SrcName() == internalSource {
+			continue
+		}
+		where := fmt.Sprintf("line %d:%d", pos.Line, pos.Column)
+		if pos.Filename != "" {
+			where = fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)
+		}
+		if name := f.FuncName(); name != "<anonymous>" {
+			fmt.Fprintf(&b, "\n    at %s (%s)", name, where)
+		} else {
+			fmt.Fprintf(&b, "\n    at %s", where)
+		}
+	}
+	return b.String()
+}
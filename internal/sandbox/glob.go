@@ -0,0 +1,340 @@
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const maxGlobMatches = 1000000
+
+// maxBraceAlternatives caps how many patterns a pattern's braces expand
+// to; {a,b} repeated 30 times would otherwise be 2^30 of them.
+const maxBraceAlternatives = 1024
+
+var errGlobLimit = errors.New("glob match limit exceeded")
+
+// globSkip records a directory the glob could not descend into.
+type globSkip struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// globber evaluates one fs.glob call. Every directory it reads is checked
+// against the sandbox first, so a pattern can only walk the trees its base
+// directory was approved for, minus anything policy explicitly denies.
+type globber struct {
+	s       *Sandbox
+	base    string          // resolved, approved base of the current pattern
+	seen    map[string]bool // matches and skipped directories already recorded
+	matches []string
+	skipped []globSkip
+}
+
+// glob expands braces in pattern, evaluates each alternative and returns
+// the sorted, de-duplicated matches. The base directory of each
+// alternative (everything before the first wildcard) goes through the
+// normal "list" approval, which may prompt.
+func (s *Sandbox) glob(pattern string) ([]string, []globSkip, error) {
+	g := &globber{s: s, seen: map[string]bool{}}
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range patterns {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(s.cfg.WorkDir, p)
+		}
+		p = filepath.Clean(p)
+
+		segs := strings.Split(filepath.ToSlash(p), "/")
+		static := 0
+		for static < len(segs) && !hasGlobMeta(segs[static]) {
+			static++
+		}
+		if static == len(segs) {
+			// No wildcards: the pattern names a single path.
+			static = len(segs) - 1
+		}
+		base := filepath.FromSlash(strings.Join(segs[:static], "/"))
+		if base == "" {
+			base = string(filepath.Separator)
+		}
+
+		if _, err := os.Stat(base); err != nil {
+			continue // nothing can match below a missing directory
+		}
+		resolvedBase, err := s.resolvePath("list", base)
+		if err != nil {
+			return nil, nil, err
+		}
+		g.base = resolvedBase
+		if err := g.walk(resolvedBase, segs[static:]); err != nil {
+			return nil, nil, err
+		}
+	}
+	sort.Strings(g.matches)
+	return g.matches, g.skipped, nil
+}
+
+// walk matches the remaining pattern segments below dir.
+func (g *globber) walk(dir string, segs []string) error {
+	if len(segs) == 0 {
+		return g.add(dir, false)
+	}
+	seg := segs[0]
+
+	if seg == "**" {
+		// ** matches zero directories...
+		if err := g.walk(dir, segs[1:]); err != nil {
+			return err
+		}
+		// ...or any number. Symlinked directories are not followed. A
+		// trailing ** matches files too.
+		entries, ok := g.readDir(dir)
+		if !ok {
+			return nil
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if e.IsDir() && e.Type()&os.ModeSymlink == 0 {
+				if err := g.walk(path, segs); err != nil {
+					return err
+				}
+			} else if len(segs) == 1 {
+				if err := g.add(path, e.Type()&os.ModeSymlink != 0); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if !hasGlobMeta(seg) {
+		path := filepath.Join(dir, seg)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil
+		}
+		if len(segs) == 1 {
+			return g.add(path, info.Mode()&os.ModeSymlink != 0)
+		}
+		real, ok := g.enter(path)
+		if !ok {
+			return nil
+		}
+		return g.walk(real, segs[1:])
+	}
+
+	entries, ok := g.readDir(dir)
+	if !ok {
+		return nil
+	}
+	for _, e := range entries {
+		matched, err := matchSegment(seg, e.Name())
+		if err != nil {
+			return fmt.Errorf("bad pattern %q: %w", seg, err)
+		}
+		if !matched {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if len(segs) == 1 {
+			if err := g.add(path, e.Type()&os.ModeSymlink != 0); err != nil {
+				return err
+			}
+			continue
+		}
+		real, ok := g.enter(path)
+		if !ok {
+			continue
+		}
+		if err := g.walk(real, segs[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enter resolves a directory the pattern wants to descend into and checks
+// it stays inside the sandbox, so a symlink can't lead the rest of the
+// pattern out of it. Non-directories are silently ignored.
+func (g *globber) enter(path string) (string, bool) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	if reason := g.check(real); reason != "" {
+		g.skip(path, reason)
+		return "", false
+	}
+	info, err := os.Stat(real)
+	if err != nil || !info.IsDir() {
+		return "", false
+	}
+	return real, true
+}
+
+// readDir lists dir after checking it against the sandbox, recording
+// denied or unreadable directories instead of dropping them silently.
+func (g *globber) readDir(dir string) ([]os.DirEntry, bool) {
+	if reason := g.check(dir); reason != "" {
+		g.skip(dir, reason)
+		return nil, false
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		reason := "unreadable"
+		if errors.Is(err, os.ErrPermission) {
+			reason = "permission denied"
+		}
+		g.skip(dir, reason)
+		return nil, false
+	}
+	return entries, true
+}
+
+func (g *globber) skip(dir, reason string) {
+	if g.seen["\x00"+dir] {
+		return
+	}
+	g.seen["\x00"+dir] = true
+	g.skipped = append(g.skipped, globSkip{dir, reason})
+}
+
+// check returns why dir may not be listed, or "" if it may. Directories
+// must be inside an allowed path or the approved base, and not explicitly
+// denied by policy.
+func (g *globber) check(dir string) string {
+	if !g.inside(dir) {
+		return "outside sandbox"
+	}
+	if g.s.cfg.PathDenied != nil && g.s.cfg.PathDenied("list", dir) {
+		return "denied by policy"
+	}
+	return ""
+}
+
+// inside reports whether the resolved path is inside an allowed path or
+// the approved base.
+func (g *globber) inside(real string) bool {
+	return withinAny(real, g.s.allowedPaths) || withinAny(real, []string{g.base})
+}
+
+// add records a match. Its directory is already resolved, so only a
+// symlink can lead out of the sandbox; one that does isn't a match,
+// whether a wildcard or a literal segment named it.
+func (g *globber) add(path string, symlink bool) error {
+	if g.seen[path] {
+		return nil
+	}
+	if symlink {
+		// A dangling symlink leads nowhere
+		if real, err := filepath.EvalSymlinks(path); err == nil && !g.inside(real) {
+			return nil
+		}
+	}
+	if g.s.cfg.PathDenied != nil && g.s.cfg.PathDenied("read", path) {
+		return nil
+	}
+	if len(g.matches) >= maxGlobMatches {
+		return errGlobLimit
+	}
+	g.seen[path] = true
+	g.matches = append(g.matches, path)
+	return nil
+}
+
+func withinAny(path string, roots []string) bool {
+	for _, root := range roots {
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) || root == string(filepath.Separator) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasGlobMeta(seg string) bool {
+	return strings.ContainsAny(seg, "*?[")
+}
+
+// matchSegment matches one path segment. It accepts [!...] as a negated
+// character class in addition to filepath.Match's [^...].
+func matchSegment(pattern, name string) (bool, error) {
+	pattern = strings.ReplaceAll(pattern, "[!", "[^")
+	return filepath.Match(pattern, name)
+}
+
+// expandBraces expands {a,b} alternatives, including nested ones, into
+// separate patterns. Unbalanced braces are left as literal text. More than
+// maxBraceAlternatives patterns is an error.
+func expandBraces(pattern string) ([]string, error) {
+	open := -1
+	depth := 0
+	for i, c := range pattern {
+		switch c {
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			alts := splitAlternatives(pattern[open+1 : i])
+			if len(alts) < 2 {
+				// {x} is not an alternation; keep it and look further on.
+				rests, err := expandBraces(pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				var out []string
+				for _, rest := range rests {
+					out = append(out, pattern[:i+1]+rest)
+				}
+				return out, nil
+			}
+			var out []string
+			for _, alt := range alts {
+				expanded, err := expandBraces(pattern[:open] + alt + pattern[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, expanded...)
+				if len(out) > maxBraceAlternatives {
+					return nil, fmt.Errorf("pattern's braces expand to more than %d patterns", maxBraceAlternatives)
+				}
+			}
+			return out, nil
+		}
+	}
+	return []string{pattern}, nil
+}
+
+// splitAlternatives splits the body of a brace group on top-level commas.
+func splitAlternatives(body string) []string {
+	var alts []string
+	depth, start := 0, 0
+	for i, c := range body {
+		switch c {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(alts, body[start:])
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package sandbox
+
+import "os"
+
+// fileOwner is not supported on windows; ownership is ACL-based there.
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
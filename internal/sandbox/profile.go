@@ -0,0 +1,134 @@
+package sandbox
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// Profile times every bridge call (fs.readFile, net.fetch, ...) and every
+// sandbox run that has it in its Config, for `think --profile`. One
+// Profile can be shared by all the sandboxes of a run. A nil *Profile
+// records nothing. Safe for concurrent use.
+type Profile struct {
+	mu      sync.Mutex
+	bridges map[string]*BridgeStat
+	runs    BridgeStat
+}
+
+// BridgeStat is the calls to one bridge function, or, for Profile.Runs,
+// the sandbox runs themselves.
+type BridgeStat struct {
+	Name   string  `json:"name"` // e.g. "fs.readFile"
+	Calls  int     `json:"calls"`
+	TimeMS float64 `json:"time_ms"` // all calls
+	MaxMS  float64 `json:"max_ms"`  // the slowest call
+}
+
+func (b *BridgeStat) add(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	b.Calls++
+	b.TimeMS += ms
+	b.MaxMS = max(b.MaxMS, ms)
+}
+
+// NewProfile returns an empty Profile.
+func NewProfile() *Profile {
+	return &Profile{bridges: map[string]*BridgeStat{}, runs: BridgeStat{Name: "sandbox"}}
+}
+
+// Bridges returns the bridge functions called so far, slowest in total
+// first.
+func (p *Profile) Bridges() []BridgeStat {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]BridgeStat, 0, len(p.bridges))
+	for _, b := range p.bridges {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].TimeMS != out[j].TimeMS {
+			return out[i].TimeMS > out[j].TimeMS
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// Runs returns the sandbox runs so far: how many, and their wall time,
+// bridge calls included.
+func (p *Profile) Runs() BridgeStat {
+	if p == nil {
+		return BridgeStat{}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.runs
+}
+
+func (p *Profile) addBridge(name string, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b := p.bridges[name]
+	if b == nil {
+		b = &BridgeStat{Name: name}
+		p.bridges[name] = b
+	}
+	b.add(d)
+}
+
+func (p *Profile) addRun(d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.runs.add(d)
+}
+
+// registerProfile wraps the functions of the fs, net, env, sys, mime,
+// json, and secrets bridges so each call is timed. The lazy ones are still
+// built on first use, and wrapped then.
+func (s *Sandbox) registerProfile(vm *goja.Runtime) {
+	for _, name := range []string{"fs", "net", "env"} {
+		s.profileObject(vm, name)
+	}
+	lazy := map[string]func(*goja.Runtime){"sys": s.registerSys, "mime": s.registerMime, "json": s.jsonBridge(vm), "secrets": s.registerSecrets}
+	for name, register := range lazy {
+		lazyGlobal(vm, name, func(vm *goja.Runtime) {
+			register(vm)
+			s.profileObject(vm, name)
+		})
+	}
+}
+
+// profileObject replaces each function of the global object name with one
+// that records how long it took, exceptions included.
+func (s *Sandbox) profileObject(vm *goja.Runtime, name string) {
+	obj := vm.Get(name)
+	if obj == nil || goja.IsUndefined(obj) {
+		return
+	}
+	o := obj.ToObject(vm)
+	for _, key := range o.Keys() {
+		fn, ok := goja.AssertFunction(o.Get(key))
+		if !ok {
+			continue
+		}
+		label := name + "." + key
+		o.Set(key, func(call goja.FunctionCall) goja.Value {
+			started := time.Now()
+			defer func() { s.cfg.Profile.addBridge(label, time.Since(started)) }()
+			v, err := fn(call.This, call.Arguments...)
+			if err != nil {
+				panic(err)
+			}
+			return v
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package sandbox
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// maxCachedPrograms bounds the program cache. A process normally runs one
+// memory.js (many times, with --map) plus the agent's scripts, so this is
+// plenty; when it fills up it starts over.
+const maxCachedPrograms = 64
+
+// programs caches compiled scripts by content hash, so code that runs
+// again in the same process (memory.js once per --map input, or each time
+// Stream resumes after the agent handled a line) skips parsing and
+// compiling. goja programs can't be serialized, so the cache only lives
+// as long as the process. A Program holds no runtime state and can run in
+// any number of runtimes at once.
+var programs = struct {
+	sync.Mutex
+	m map[[sha256.Size]byte]*goja.Program
+}{m: map[[sha256.Size]byte]*goja.Program{}}
+
+// compile returns the compiled program for code, compiling it on first
+// use. Code that doesn't compile isn't cached.
+func compile(code string) (*goja.Program, error) {
+	key := sha256.Sum256([]byte(code))
+	programs.Lock()
+	p, ok := programs.m[key]
+	programs.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	p, err := goja.Compile("", code, false)
+	if err != nil {
+		return nil, err
+	}
+	programs.Lock()
+	if len(programs.m) >= maxCachedPrograms {
+		clear(programs.m)
+	}
+	programs.m[key] = p
+	programs.Unlock()
+	return p, nil
+}
+
+// Compile reports whether code compiles, and why not, without running it.
+func Compile(code string) error {
+	_, err := compile(code)
+	return err
+}
+
+// runCode compiles code (or takes it from the cache) and runs it in vm.
+func runCode(vm *goja.Runtime, code string) (goja.Value, error) {
+	p, err := compile(code)
+	if err != nil {
+		// RunString reports the error as a SyntaxError exception, the
+		// way scripts and the agent have always seen it.
+		return vm.RunString(code)
+	}
+	return vm.RunProgram(p)
+}
@@ -0,0 +1,133 @@
+package sandbox
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultReadDirPage is the page size fs.readDir uses when called with an
+// options object but no maxEntries.
+const DefaultReadDirPage = 1000
+
+var errBadSort = errors.New("invalid sort (must be name, size, or modTime; prefix with - to reverse)")
+
+// readDirOptions are the optional second argument to fs.readDir.
+type readDirOptions struct {
+	Recursive  bool
+	MaxEntries int
+	Offset     int
+	Sort       string // name, size, modTime; "-" prefix reverses
+}
+
+// dirEntry is one fs.readDir result. Name is relative to the listed
+// directory, so recursive listings return paths like "src/main.go".
+type dirEntry struct {
+	name      string
+	isDir     bool
+	isSymlink bool
+	size      int64
+	modTime   int64
+	mode      string
+}
+
+func (e dirEntry) toJS() map[string]any {
+	return map[string]any{
+		"name":      e.name,
+		"isDir":     e.isDir,
+		"isSymlink": e.isSymlink,
+		"size":      e.size,
+		"modTime":   e.modTime,
+		"mode":      e.mode,
+	}
+}
+
+// listDir returns one page of the entries under dir (already resolved and
+// approved) and whether more remain. Recursive listings stay inside dir,
+// do not follow symlinked directories, and skip directories policy denies.
+func (s *Sandbox) listDir(dir string, opts readDirOptions) ([]dirEntry, bool, error) {
+	field, desc := strings.CutPrefix(opts.Sort, "-")
+	var less func(a, b dirEntry) bool
+	switch field {
+	case "", "name":
+		less = func(a, b dirEntry) bool { return a.name < b.name }
+	case "size":
+		less = func(a, b dirEntry) bool { return a.size < b.size }
+	case "modTime":
+		less = func(a, b dirEntry) bool { return a.modTime < b.modTime }
+	default:
+		return nil, false, errBadSort
+	}
+
+	var entries []dirEntry
+	add := func(rel string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if len(entries) >= maxGlobMatches {
+			return errGlobLimit
+		}
+		entries = append(entries, dirEntry{
+			name:      filepath.ToSlash(rel),
+			isDir:     d.IsDir(),
+			isSymlink: d.Type()&os.ModeSymlink != 0,
+			size:      info.Size(),
+			modTime:   info.ModTime().Unix(),
+			mode:      info.Mode().String(),
+		})
+		return nil
+	}
+
+	if opts.Recursive {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if path == dir {
+				return err
+			}
+			if err != nil {
+				return nil // unreadable subdirectory: leave it out
+			}
+			rel, _ := filepath.Rel(dir, path)
+			if err := add(rel, d); err != nil {
+				return err
+			}
+			// Denied directories are listed but not entered.
+			if d.IsDir() && s.cfg.PathDenied != nil && s.cfg.PathDenied("list", path) {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		list, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, d := range list {
+			if err := add(d.Name(), d); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if desc {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+
+	if opts.Offset >= len(entries) {
+		return []dirEntry{}, false, nil
+	}
+	entries = entries[opts.Offset:]
+	if opts.MaxEntries > 0 && len(entries) > opts.MaxEntries {
+		return entries[:opts.MaxEntries], true, nil
+	}
+	return entries, false, nil
+}
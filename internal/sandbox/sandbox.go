@@ -2,15 +2,19 @@ package sandbox
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/workspace"
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/require"
 )
@@ -23,21 +27,48 @@ const (
 	MaxCopySize      = 50 << 20         // 50 MB max copy per file
 	MaxAppendSize    = 10 << 20         // 10 MB max append per call
 	MaxNetRespSize   = 50 << 20         // 50 MB max network response
+	MaxDownloadSize  = 10 << 30         // 10 GB max net.download
 )
 
 // Config holds everything needed to create a sandbox.
+//
+// Paths are checked in this order: ReadOnly rejects every write and
+// delete; then reads and lists inside AllowedPaths, and writes and deletes
+// inside WritablePaths, go ahead without asking; anything else goes to
+// ApprovePath, and is denied when it is nil. ApprovePath can only widen
+// access: a policy deny doesn't reach paths inside the two sets.
 type Config struct {
-	AllowedPaths  []string      // Resolved absolute paths the sandbox may read freely (CWD, workspace)
-	WritablePaths []string      // Resolved absolute paths the sandbox may write freely (workspace, memories)
+	AllowedPaths  []string      // Paths the sandbox may read and list freely (CWD, workspace)
+	WritablePaths []string      // Paths the sandbox may write and delete freely (workspace, memories); each must be inside AllowedPaths
 	WorkDir       string        // CWD for relative path resolution
+	Stdout        io.Writer     // Where process.stdout.write goes (default os.Stdout)
 	Stderr        io.Writer     // Where console.log goes
 	Args          []string      // Script arguments
 	Timeout       time.Duration // Max execution time (default 30s)
 	ApprovePath   func(op, path string) (bool, error) // Called for paths outside AllowedPaths/WritablePaths; nil = deny all
+	PathDenied    func(op, path string) bool // Reports explicit policy denies without prompting (used by fs.glob below an approved base); nil = none
 	ApproveEnv    func(name string) (bool, error) // Called before reading env vars; nil = allow all
+	Getenv        func(name string) string        // Reads an approved env var; nil = os.Getenv
 	ApproveNet    func(host string) (bool, error) // Called before network access; nil = deny all
+	ApproveSecret func(name string) (bool, error) // Called before secrets.get; nil = deny all
+	Secret        func(name string) (string, error) // Reads an approved secret; nil = secrets.Get
 	PromptInput   func(question, defaultValue string) (string, error) // Called by input.prompt; nil = no input available
-	OnWrite       func(path, content string)      // Called after successful file writes; nil = no-op
+	OnWrite       func(path, content string)      // Called after successful writes, appends, copies, and moves (content is "" for copy/move); nil = no-op
+	OnFetch       func(url string)                // Called when net.fetch returns a response, before the script sees it; nil = no-op
+	OnRequire     func(path, sum string)          // Called when require() loads a file, with its SHA-256; nil = no-op
+	OnDownload    func(url, path, sum string)     // Called after net.download writes path, with its SHA-256; nil = no-op
+	ReadOnly      bool                            // Reject every write/delete, including WritablePaths
+	TrashDir      string   // fs.delete moves paths here instead of removing them; "" = delete permanently
+	TrashExempt   []string // Paths fs.delete still removes permanently when TrashDir is set (the workspace)
+	Journal       *journal.Journal // Records fs mutations for 'thought undo'; nil = not journaled
+	Workspace     *workspace.Run   // Per-run workspace for fs.promote; nil = the thought uses its persistent workspace
+	Eval          string           // EvalAfterFetch (""), EvalAllow, or EvalDeny; see bridge_eval.go
+	BlobCache     string           // Shared download cache for net.download (see internal/blobcache); "" = no cache
+	Debug         *Debug           // Pause between statements for `thought debug`; nil = run normally
+	Profile       *Profile         // Times bridge calls and runs for `think --profile`; nil = off
+	Redact        *redact.Set      // Collects secret env.get and secrets.get values and masks them in console output; nil = off
+	RedactPaths   []string         // Paths (memories) where fs.writeFile and fs.appendFile mask Redact's values in what they write
+	Protected     []string         // Paths (approval's protected deny entries) denied even inside AllowedPaths unless PathDenied clears them
 }
 
 // Sandbox executes JavaScript code with restricted filesystem access.
@@ -45,44 +76,57 @@ type Sandbox struct {
 	cfg           Config
 	allowedPaths  []string // resolved + cleaned allowed paths (reads)
 	writablePaths []string // resolved + cleaned writable paths (writes/deletes)
+	trashExempt   []string // resolved + cleaned TrashExempt paths
+	redactPaths   []string // resolved + cleaned RedactPaths
+	protected     []string // resolved + cleaned Protected
 	ctx           context.Context
-	interrupted   bool // set when a user prompt is interrupted (Ctrl+C)
+	interrupted   bool                     // set when a user prompt is interrupted (Ctrl+C)
+	stdinHandlers map[string]goja.Callable // registered via process.stdin.on (stream mode)
+	fetched       bool                     // net.fetch returned a response; see registerEval
+	untrusted     []string                 // files require() refuses; see noteWrite
+	secrets       map[string]secret        // handles from secrets.get; see bridge_secrets.go
+	realEval      goja.Value               // the intrinsic eval, before the guard replaces it
 }
 
-// New creates a Sandbox. AllowedPaths are resolved via EvalSymlinks at
-// creation time so that runtime path checks can't be tricked by symlinks.
+// New creates a Sandbox. AllowedPaths, WritablePaths, and TrashExempt are
+// resolved via EvalSymlinks at creation time so that runtime path checks
+// can't be tricked by symlinks. A writable path outside every allowed path
+// is a configuration error: what a sandbox writes, it can read back.
 func New(cfg Config) (*Sandbox, error) {
-	resolved := make([]string, 0, len(cfg.AllowedPaths))
-	for _, p := range cfg.AllowedPaths {
-		abs, err := filepath.Abs(p)
-		if err != nil {
-			return nil, fmt.Errorf("resolving allowed path %q: %w", p, err)
-		}
-		// Try to resolve symlinks; if the path doesn't exist yet that's OK —
-		// use the absolute path as-is (e.g., workspace dir created on first use).
-		real, err := filepath.EvalSymlinks(abs)
-		if err != nil {
-			real = abs
+	resolved, err := resolveConfigPaths("allowed", cfg.AllowedPaths)
+	if err != nil {
+		return nil, err
+	}
+	writable, err := resolveConfigPaths("writable", cfg.WritablePaths)
+	if err != nil {
+		return nil, err
+	}
+	for i, w := range writable {
+		if !withinAny(w, resolved) {
+			return nil, fmt.Errorf("writable path %q is not inside any allowed path", cfg.WritablePaths[i])
 		}
-		resolved = append(resolved, real)
+	}
+	exempt, err := resolveConfigPaths("trash-exempt", cfg.TrashExempt)
+	if err != nil {
+		return nil, err
 	}
 
-	writable := make([]string, 0, len(cfg.WritablePaths))
-	for _, p := range cfg.WritablePaths {
-		abs, err := filepath.Abs(p)
-		if err != nil {
-			return nil, fmt.Errorf("resolving writable path %q: %w", p, err)
-		}
-		real, err := filepath.EvalSymlinks(abs)
-		if err != nil {
-			real = abs
-		}
-		writable = append(writable, real)
+	redactPaths, err := resolveConfigPaths("redact", cfg.RedactPaths)
+	if err != nil {
+		return nil, err
+	}
+	protected, err := resolveConfigPaths("protected", cfg.Protected)
+	if err != nil {
+		return nil, err
 	}
 
+	if cfg.Stdout == nil {
+		cfg.Stdout = os.Stdout
+	}
 	if cfg.Stderr == nil {
 		cfg.Stderr = os.Stderr
 	}
+	cfg.Stderr = cfg.Redact.Writer(cfg.Stderr)
 
 	// Resolve WorkDir symlinks so it matches the resolved AllowedPaths.
 	if cfg.WorkDir != "" {
@@ -98,23 +142,69 @@ func New(cfg Config) (*Sandbox, error) {
 		cfg.Timeout = 0 // Disable timeout
 	}
 
-	return &Sandbox{cfg: cfg, allowedPaths: resolved, writablePaths: writable}, nil
+	return &Sandbox{cfg: cfg, allowedPaths: resolved, writablePaths: writable, trashExempt: exempt, redactPaths: redactPaths, protected: protected}, nil
 }
 
 // Run executes JavaScript code and returns the last expression value as a string.
 func (s *Sandbox) Run(ctx context.Context, code string) (result string, err error) {
 	s.ctx = ctx
+	if s.cfg.Profile != nil {
+		started := time.Now()
+		defer func() { s.cfg.Profile.addRun(time.Since(started)) }()
+	}
+	vm := s.newRuntime()
+	defer s.watch(ctx, vm)()
+	if s.cfg.Debug != nil {
+		instrumented, tables, err := instrument(code)
+		if err == nil { // a syntax error is reported by runCode below
+			code = instrumented
+			s.registerDebug(vm, tables)
+		}
+	}
+
+	var v goja.Value
+	err = s.call(func() error {
+		var runErr error
+		v, runErr = runCode(vm, code)
+		return runErr
+	})
+	if errors.Is(err, errCleanExit) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return "", nil
+	}
+	return stringify(vm, v), nil
+}
+
+// newRuntime creates a goja runtime with every bridge and require() wired.
+func (s *Sandbox) newRuntime() *goja.Runtime {
 	vm := goja.New()
 
-	// Wire bridges
+	// Wire bridges. Rarely used ones are built on first use; see
+	// BenchmarkSandboxNew before adding to the eager list.
+	for _, name := range errorClasses {
+		lazyGlobal(vm, name, registerErrors)
+	}
 	s.registerConsole(vm)
 	s.registerFS(vm)
+	lazyGlobal(vm, "mime", s.registerMime)
+	lazyGlobal(vm, "json", s.jsonBridge(vm))
 	s.registerNet(vm)
 	s.registerEnv(vm)
+	lazyGlobal(vm, "secrets", s.registerSecrets)
 	s.registerProcess(vm)
-	s.registerSys(vm)
+	lazyGlobal(vm, "sys", s.registerSys)
 	s.registerAgent(vm)
-	s.registerInput(vm)
+	lazyGlobal(vm, "input", s.registerInput)
+	s.registerEval(vm)
+	if s.cfg.Profile != nil {
+		s.registerProfile(vm)
+	}
 
 	// Enable require() with sandbox-aware source loading
 	registry := require.NewRegistry(
@@ -123,14 +213,44 @@ func (s *Sandbox) Run(ctx context.Context, code string) (result string, err erro
 			if err != nil {
 				return nil, require.ModuleFileDoesNotExistError
 			}
-			return os.ReadFile(resolved)
+			if err := s.checkRequire(resolved); err != nil {
+				return nil, err
+			}
+			data, err := os.ReadFile(resolved)
+			if err == nil && s.cfg.OnRequire != nil {
+				sum := sha256.Sum256(data)
+				s.cfg.OnRequire(resolved, hex.EncodeToString(sum[:]))
+			}
+			return data, err
 		}),
 	)
 	registry.Enable(vm)
 
-	// Context cancellation via interrupt
+	return vm
+}
+
+// lazyGlobal defines a global that register builds the first time a script
+// reads it. register must set the global (and may set others defined with
+// lazyGlobal; assigning to one replaces it).
+func lazyGlobal(vm *goja.Runtime, name string, register func(*goja.Runtime)) {
+	global := vm.GlobalObject()
+	get := func(goja.FunctionCall) goja.Value {
+		global.Delete(name)
+		register(vm)
+		return global.Get(name)
+	}
+	set := func(call goja.FunctionCall) goja.Value {
+		global.Delete(name)
+		global.Set(name, call.Argument(0))
+		return goja.Undefined()
+	}
+	global.DefineAccessorProperty(name, vm.ToValue(get), vm.ToValue(set), goja.FLAG_TRUE, goja.FLAG_FALSE)
+}
+
+// watch interrupts the runtime when ctx is cancelled or the timeout fires.
+// Call the returned function to stop watching.
+func (s *Sandbox) watch(ctx context.Context, vm *goja.Runtime) func() {
 	done := make(chan struct{})
-	defer close(done)
 	go func() {
 		select {
 		case <-ctx.Done():
@@ -140,13 +260,27 @@ func (s *Sandbox) Run(ctx context.Context, code string) (result string, err erro
 	}()
 
 	// Timeout (only if configured)
+	var timer *time.Timer
 	if s.cfg.Timeout > 0 {
-		timer := time.AfterFunc(s.cfg.Timeout, func() {
+		timer = time.AfterFunc(s.cfg.Timeout, func() {
 			vm.Interrupt("execution timed out")
 		})
-		defer timer.Stop()
 	}
 
+	return func() {
+		close(done)
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// errCleanExit signals process.exit(0).
+var errCleanExit = errors.New("process.exit(0)")
+
+// call runs fn (which executes JS) and converts bridge panics and JS
+// exceptions into clean errors.
+func (s *Sandbox) call(fn func() error) (err error) {
 	// Catch panics from goja (e.g., process.exit, agent.resume)
 	defer func() {
 		if r := recover(); r != nil {
@@ -155,7 +289,7 @@ func (s *Sandbox) Run(ctx context.Context, code string) (result string, err erro
 				err = resumeErr
 			} else if exitErr, ok := r.(*exitError); ok {
 				if exitErr.code == 0 {
-					err = nil
+					err = errCleanExit
 				} else {
 					err = fmt.Errorf("script exited with code %d", exitErr.code)
 				}
@@ -165,28 +299,25 @@ func (s *Sandbox) Run(ctx context.Context, code string) (result string, err erro
 		}
 	}()
 
-	v, runErr := vm.RunString(code)
+	runErr := fn()
 	if s.interrupted {
-		return "", approval.ErrInterrupted
+		return approval.ErrInterrupted
 	}
 	if runErr != nil {
-		// Extract just the error message, not Go internals
+		// The JS error and stack, not Go internals
 		if ex, ok := runErr.(*goja.Exception); ok {
-			return "", fmt.Errorf("%s", ex.Value().String())
+			return fmt.Errorf("%s", exceptionMessage(ex))
 		}
-		return "", fmt.Errorf("%s", runErr.Error())
+		return fmt.Errorf("%s", runErr.Error())
 	}
-
-	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
-		return "", nil
-	}
-	return stringify(vm, v), nil
+	return nil
 }
 
 // resolvePath takes a user-supplied path (possibly relative), resolves it
 // against WorkDir, evaluates symlinks, and checks that the result falls
-// within one of the allowed paths. The op parameter describes the operation
-// (e.g. "read", "write", "delete") and is shown in approval prompts.
+// within one of the allowed paths. The op parameter is "read" or "list",
+// checked against AllowedPaths, or "write" or "delete", checked against
+// WritablePaths; it is shown in approval prompts.
 func (s *Sandbox) resolvePath(op, userPath string) (string, error) {
 	var abs string
 	if filepath.IsAbs(userPath) {
@@ -195,6 +326,10 @@ func (s *Sandbox) resolvePath(op, userPath string) (string, error) {
 		abs = filepath.Join(s.cfg.WorkDir, userPath)
 	}
 
+	if s.cfg.ReadOnly && writeOp(op) {
+		return "", fmt.Errorf("read-only mode: cannot %s %s", op, userPath)
+	}
+
 	// For files that don't exist yet (write), resolve the parent and append the base.
 	real, err := filepath.EvalSymlinks(abs)
 	if err != nil {
@@ -207,20 +342,24 @@ func (s *Sandbox) resolvePath(op, userPath string) (string, error) {
 		real = filepath.Join(realParent, filepath.Base(abs))
 	}
 
+	// Protected locations stay closed even inside the allowed paths (a
+	// thought run from the home directory), unless the policy allows them
+	if withinAny(real, s.protected) && (s.cfg.PathDenied == nil || s.cfg.PathDenied(op, real)) {
+		return "", fmt.Errorf("access denied: %s is protected", userPath)
+	}
+
 	// For write/delete ops, check writable paths; for read/list, check allowed paths.
 	checkPaths := s.allowedPaths
-	if op == "write" || op == "delete" {
+	if writeOp(op) {
 		checkPaths = s.writablePaths
 	}
-	for _, allowed := range checkPaths {
-		if real == allowed || strings.HasPrefix(real, allowed+string(filepath.Separator)) {
-			return real, nil
-		}
+	if withinAny(real, checkPaths) {
+		return real, nil
 	}
 
 	// Path is outside the sandbox — ask for approval if a callback is set.
 	if s.cfg.ApprovePath != nil {
-		approved, err := s.cfg.ApprovePath(op, real)
+		approved, err := interruptible(s.ctx, func() (bool, error) { return s.cfg.ApprovePath(op, real) })
 		if err != nil {
 			if errors.Is(err, approval.ErrInterrupted) {
 				s.interrupted = true
@@ -235,6 +374,74 @@ func (s *Sandbox) resolvePath(op, userPath string) (string, error) {
 	return "", fmt.Errorf("access denied: path %q is outside the sandbox", userPath)
 }
 
+// writeOp reports whether a resolvePath op changes the filesystem.
+func writeOp(op string) bool {
+	return op == "write" || op == "delete"
+}
+
+// resolveConfigPaths makes Config paths absolute and resolves their
+// symlinks. A path that doesn't exist yet (e.g. a workspace created on
+// first use) resolves through its nearest existing ancestor, so it
+// compares equal to resolved paths above it.
+func resolveConfigPaths(kind string, paths []string) ([]string, error) {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("resolving %s path %q: %w", kind, p, err)
+		}
+		resolved = append(resolved, resolveExisting(abs))
+	}
+	return resolved, nil
+}
+
+// resolveExisting evaluates the symlinks in the longest existing prefix
+// of the absolute path abs and appends the rest unchanged.
+func resolveExisting(abs string) string {
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{real}, rest...)...)
+		}
+		if filepath.Dir(dir) == dir {
+			return abs
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// interruptible runs fn (typically an approval or input callback that may
+// block on the user) and returns ErrInterrupted as soon as ctx is cancelled,
+// so a pending prompt can't hold up shutdown. fn keeps running in the
+// background; its result is discarded.
+func interruptible[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	if ctx == nil {
+		return fn()
+	}
+	if err := ctx.Err(); err != nil {
+		var zero T
+		return zero, approval.ErrInterrupted
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		ch <- result{v, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-ctx.Done():
+		var zero T
+		return zero, approval.ErrInterrupted
+	}
+}
+
 // checkInterrupted sets the interrupted flag if err is ErrInterrupted.
 func (s *Sandbox) checkInterrupted(err error) {
 	if errors.Is(err, approval.ErrInterrupted) {
@@ -242,11 +449,6 @@ func (s *Sandbox) checkInterrupted(err error) {
 	}
 }
 
-// throwError panics with a clean JS Error (no Go stack traces).
-func throwError(vm *goja.Runtime, msg string) {
-	panic(vm.ToValue(msg))
-}
-
 // exitError is used with panic to implement process.exit().
 type exitError struct {
 	code int
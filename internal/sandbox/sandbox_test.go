@@ -1,12 +1,26 @@
 package sandbox
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/trash"
+	"github.com/thinkingscript/cli/internal/workspace"
 )
 
 func TestBasicExecution(t *testing.T) {
@@ -107,6 +121,58 @@ func TestJavaScriptException(t *testing.T) {
 	}
 }
 
+func TestBridgeErrors(t *testing.T) {
+	dir := t.TempDir()
+	sb, err := New(Config{AllowedPaths: []string{dir}, WorkDir: dir, ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Caught: a real Error with a code and a stack pointing at the call
+	result, err := sb.Run(context.Background(), `function load(p) {
+  return fs.readFile(p);
+}
+var codes = [];
+try { load("missing.txt") } catch (e) { codes.push(e instanceof Error, e.code, e.stack) }
+try { fs.writeFile("x.txt", "") } catch (e) { codes.push(e.code) }
+try { fs.readFile("/etc/hostname") } catch (e) { codes.push(e.code) }
+codes`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`true,"ENOENT"`, `at load (line 2:`, `"EROFS","EACCES"`} {
+		if !strings.Contains(result, want) {
+			t.Errorf("result = %s, want it to contain %s", result, want)
+		}
+	}
+	if strings.Contains(result, "native") || strings.Contains(result, "registerFS") {
+		t.Errorf("stack shows bridge internals: %s", result)
+	}
+
+	// Classes: the specific one by code, and FsError/NetError by bridge
+	result, err = sb.Run(context.Background(), `function kind(f) {
+  try { f() } catch (e) {
+    return [e.name, e instanceof FsError, e instanceof NetError, e instanceof AccessDeniedError, e instanceof LimitError].join(" ")
+  }
+}
+[kind(() => fs.stat("missing.txt")), kind(() => fs.readFile("/etc/hostname")), kind(() => net.fetch("http://127.0.0.1/")),
+ kind(() => fs.writeFile("x", "")), kind(() => { throw new LimitError("mine") })].join("; ")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "FsError true false false false; AccessDeniedError true false true false; AccessDeniedError false true true false; " +
+		"AccessDeniedError true false true false; LimitError false false false true"
+	if result != want {
+		t.Errorf("classes = %s\nwant      %s", result, want)
+	}
+
+	// Uncaught: the run error carries the JS stack
+	_, err = sb.Run(context.Background(), "var a = 1;\nfs.stat(\"missing.txt\")")
+	if err == nil || !strings.Contains(err.Error(), "FsError: fs.stat: missing.txt not found\n    at line 2:") {
+		t.Errorf("error = %v, want message and stack", err)
+	}
+}
+
 func TestReferenceError(t *testing.T) {
 	sb, err := New(Config{})
 	if err != nil {
@@ -257,6 +323,34 @@ func TestCannotWriteOutsideWritablePaths(t *testing.T) {
 	}
 }
 
+func TestWritablePathsMustBeAllowed(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+
+	_, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{other},
+		WorkDir:       dir,
+	})
+	if err == nil || !strings.Contains(err.Error(), "not inside any allowed path") {
+		t.Errorf("New with a writable path outside AllowedPaths: err = %v", err)
+	}
+
+	// A writable path that doesn't exist yet, below a symlinked allowed
+	// directory, still counts as inside it
+	link := filepath.Join(other, "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skip("symlinks not supported")
+	}
+	if _, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{filepath.Join(link, "new", "memory.js")},
+		WorkDir:       dir,
+	}); err != nil {
+		t.Errorf("New with a not-yet-created writable path: %v", err)
+	}
+}
+
 func TestCanReadOutsideWritablePaths(t *testing.T) {
 	dir := t.TempDir()
 	libDir := filepath.Join(dir, "lib")
@@ -365,6 +459,56 @@ func TestFsReadDir(t *testing.T) {
 	}
 }
 
+func TestFsReadDirPaging(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	os.MkdirAll(filepath.Join(dir, "sub", "deep"), 0755)
+	os.MkdirAll(filepath.Join(dir, "hidden"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("aaa"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "c.txt"), []byte("cc"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "deep", "d.txt"), []byte("d"), 0644)
+	os.WriteFile(filepath.Join(dir, "hidden", "e.txt"), []byte("e"), 0644)
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+		PathDenied: func(op, path string) bool {
+			return path == filepath.Join(dir, "hidden")
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"page", `var r = fs.readDir(".", {maxEntries: 2}); JSON.stringify([r.entries.map(e => e.name), r.hasMore, r.nextOffset])`, `[["a.txt","b.txt"],true,2]`},
+		{"offset", `var r = fs.readDir(".", {maxEntries: 2, offset: 2}); JSON.stringify([r.entries.map(e => e.name), r.hasMore])`, `[["hidden","sub"],false]`},
+		{"recursive", `JSON.stringify(fs.readDir(".", {recursive: true}).entries.map(e => e.name))`, `["a.txt","b.txt","hidden","sub","sub/c.txt","sub/deep","sub/deep/d.txt"]`},
+		{"sort size desc", `JSON.stringify(fs.readDir(".", {sort: "-size"}).entries.filter(e => !e.isDir).map(e => e.name))`, `["a.txt","b.txt"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sb.Run(context.Background(), tt.code)
+			if err != nil {
+				t.Fatalf("fs.readDir error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("got %s, want %s", result, tt.want)
+			}
+		})
+	}
+
+	if _, err := sb.Run(context.Background(), `fs.readDir(".", {sort: "color"})`); err == nil || !strings.Contains(err.Error(), "invalid sort") {
+		t.Errorf("expected invalid sort error, got %v", err)
+	}
+}
+
 func TestFsStat(t *testing.T) {
 	dir := t.TempDir()
 	dir, _ = filepath.EvalSymlinks(dir)
@@ -396,6 +540,235 @@ func TestFsStat(t *testing.T) {
 	}
 }
 
+func TestFsStatMetadata(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0755)
+	os.Symlink(filepath.Join(dir, "run.sh"), filepath.Join(dir, "link.sh"))
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	result, err := sb.Run(context.Background(), `
+		var a = fs.stat("run.sh"), b = fs.stat("link.sh");
+		var e = fs.readDir(".").find(e => e.name === "link.sh");
+		JSON.stringify([a.mode, a.perm, a.isSymlink, b.isSymlink, e.isSymlink])
+	`)
+	if err != nil {
+		t.Fatalf("fs.stat error: %v", err)
+	}
+	if want := `["-rwxr-xr-x","0755",false,true,true]`; result != want {
+		t.Errorf("got %s, want %s", result, want)
+	}
+}
+
+func TestFsStatMany(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	result, err := sb.Run(context.Background(), `
+		JSON.stringify(fs.statMany(["a.txt", "sub", "missing.txt", "/etc/passwd"]).map(s =>
+			s.error ? [s.path, s.code] : [s.path, s.size, s.isDir]))
+	`)
+	if err != nil {
+		t.Fatalf("fs.statMany error: %v", err)
+	}
+	if !strings.HasPrefix(result, `[["a.txt",5,false],["sub",`) ||
+		!strings.HasSuffix(result, `["missing.txt","ENOENT"],["/etc/passwd","EACCES"]]`) {
+		t.Errorf("got %s", result)
+	}
+
+	if _, err := sb.Run(context.Background(), `fs.statMany("a.txt")`); err == nil {
+		t.Error("expected an error for a non-array argument")
+	}
+}
+
+func TestFsDu(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.MkdirAll(filepath.Join(dir, "big", "deep"), 0755)
+	os.MkdirAll(filepath.Join(dir, "small"), 0755)
+	os.MkdirAll(filepath.Join(dir, "secret"), 0755)
+	os.WriteFile(filepath.Join(dir, "big", "a.bin"), make([]byte, 1000), 0644)
+	os.WriteFile(filepath.Join(dir, "big", "deep", "b.bin"), make([]byte, 500), 0644)
+	os.WriteFile(filepath.Join(dir, "small", "c.txt"), make([]byte, 10), 0644)
+	os.WriteFile(filepath.Join(dir, "top.txt"), make([]byte, 100), 0644)
+	os.WriteFile(filepath.Join(dir, "secret", "d.bin"), make([]byte, 5000), 0644)
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+		PathDenied: func(op, path string) bool {
+			return path == filepath.Join(dir, "secret")
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{
+			`var d = fs.du("."); JSON.stringify([d.size, d.files, d.dirs, d.truncated, d.entries.map(e => e.name + ":" + e.size + ":" + e.files)])`,
+			`[1610,4,4,false,["big:1500:2","top.txt:100:1","small:10:1","secret:0:0"]]`,
+		},
+		{
+			`JSON.stringify(fs.du("big", {maxDepth: 2}).entries.map(e => e.name + ":" + e.size))`,
+			`["a.bin:1000","deep:500","deep/b.bin:500"]`,
+		},
+		{
+			`var d = fs.du(".", {maxDepth: 0}); JSON.stringify([d.size, d.entries.length])`,
+			`[1610,0]`,
+		},
+		{
+			`var d = fs.du(".", {maxEntries: 3}); JSON.stringify([d.truncated, d.files + d.dirs])`,
+			`[true,3]`,
+		},
+		{
+			`fs.du("top.txt").size`,
+			`100`,
+		},
+	}
+	for _, tt := range tests {
+		result, err := sb.Run(context.Background(), tt.code)
+		if err != nil {
+			t.Errorf("%s: %v", tt.code, err)
+			continue
+		}
+		if result != tt.want {
+			t.Errorf("%s\n got %s\nwant %s", tt.code, result, tt.want)
+		}
+	}
+
+	if _, err := sb.Run(context.Background(), `fs.du("nope")`); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("fs.du of a missing path: err = %v", err)
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.WriteFile(filepath.Join(dir, "data.json"), []byte(`{"meta": {"count": 3}, "items": [
+		{"id": 1, "tags": ["a"]}, {"id": 2, "tags": []}, {"id": 3, "tags": ["b", "c"]}
+	], "a.b": true}`), 0644)
+	os.WriteFile(filepath.Join(dir, "lines.jsonl"), []byte("{\"n\": 1}\n{\"n\": 2}\n{\"n\": 3}\n"), 0644)
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	tests := []struct {
+		code string
+		want string
+	}{
+		{`JSON.stringify(json.stream("data.json", "items.#.id"))`, `[1,2,3]`},
+		{`JSON.stringify(json.stream("data.json", "items.2.tags.*"))`, `["b","c"]`},
+		{`JSON.stringify(json.stream("data.json", "meta"))`, `[{"count":3}]`},
+		{`JSON.stringify(json.stream("data.json", "a\\.b"))`, `[true]`},
+		{`JSON.stringify(json.stream("data.json", "items.#.missing"))`, `[]`},
+		{`JSON.stringify(json.stream("data.json", "items.#", {limit: 1}))`, `[{"id":1,"tags":["a"]}]`},
+		{`JSON.stringify(json.stream("lines.jsonl", "n"))`, `[1,2,3]`},
+		{`JSON.stringify(json.stream('[{"x": [1, 2]}, {"x": [3]}]', "#.x.#"))`, `[1,2,3]`},
+		{`JSON.stringify(json.stream(' {"x": 1}', ""))`, `[{"x":1}]`},
+		// Keys keep their source order
+		{`JSON.stringify(json.stream('{"z": 1, "a": {"y": 2, "b": 3}, "m": 4}', ""))`, `[{"z":1,"a":{"y":2,"b":3},"m":4}]`},
+		// A replaced JSON.parse doesn't change the results
+		{`var stringify = JSON.stringify; JSON.parse = function() { return "shadowed" }; stringify(json.stream('{"x": 1}', "x"))`, `[1]`},
+	}
+	for _, tt := range tests {
+		result, err := sb.Run(context.Background(), tt.code)
+		if err != nil {
+			t.Errorf("%s: %v", tt.code, err)
+			continue
+		}
+		if result != tt.want {
+			t.Errorf("%s\n got %s\nwant %s", tt.code, result, tt.want)
+		}
+	}
+
+	errTests := []struct {
+		code string
+		want string
+	}{
+		{`json.stream('{"items": [1, 2', "items.#")`, "invalid JSON"},
+		{`json.stream("nope.json", "x")`, "not found"},
+		{`json.stream("/etc/passwd", "x")`, "denied"},
+	}
+	for _, tt := range errTests {
+		if _, err := sb.Run(context.Background(), tt.code); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestMimeDetect(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+
+	files := map[string][]byte{
+		"image.dat": {0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n', 0, 0, 0, 0},
+		"page":      []byte("<!DOCTYPE html><html></html>"),
+		"data.json": []byte(`{"a": 1}`),
+		"notes":     []byte("just some text\n"),
+		"blob":      append(bytes.Repeat([]byte("a"), 1000), 0),
+		"empty.txt": {},
+	}
+	for name, data := range files {
+		os.WriteFile(filepath.Join(dir, name), data, 0644)
+	}
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	tests := map[string]string{
+		"image.dat": "image/png",
+		"page":      "text/html; charset=utf-8",
+		"data.json": "application/json",
+		"notes":     "text/plain; charset=utf-8",
+		"blob":      "application/octet-stream",
+		"empty.txt": "inode/x-empty",
+		".":         "inode/directory",
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := sb.Run(context.Background(), `mime.detect("`+name+`")`)
+			if err != nil {
+				t.Fatalf("mime.detect error: %v", err)
+			}
+			if result != want {
+				t.Errorf("mime.detect(%q) = %q, want %q", name, result, want)
+			}
+		})
+	}
+}
+
 func TestFsExists(t *testing.T) {
 	dir := t.TempDir()
 	dir, _ = filepath.EvalSymlinks(dir)
@@ -463,6 +836,139 @@ func TestFsGlob(t *testing.T) {
 	}
 }
 
+func TestFsGlobPatterns(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	outside := t.TempDir()
+	outside, _ = filepath.EvalSymlinks(outside)
+
+	for _, f := range []string{"a.txt", "b.md", "c.go", "src/x.go", "src/y.txt", "src/deep/z.go", "secret/key.go"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755)
+		os.WriteFile(filepath.Join(dir, f), []byte(f), 0644)
+	}
+	os.WriteFile(filepath.Join(outside, "leak.go"), []byte("x"), 0644)
+	os.MkdirAll(filepath.Join(outside, "x"), 0755)
+	os.WriteFile(filepath.Join(outside, "x", "secret.txt"), []byte("x"), 0644)
+	os.Symlink(outside, filepath.Join(dir, "link"))
+	os.Symlink(filepath.Join(outside, "leak.go"), filepath.Join(dir, "out.txt"))
+	os.Symlink(filepath.Join(outside, "leak.go"), filepath.Join(dir, "src", "out.go"))
+
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+		Stderr:       io.Discard,
+		PathDenied: func(op, path string) bool {
+			return path == filepath.Join(dir, "secret")
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.{txt,md}", []string{"a.txt", "b.md"}},
+		{"[ab].*", []string{"a.txt", "b.md"}},
+		{"[!ab].*", []string{"c.go"}},
+		{"**/*.go", []string{"c.go", "src/deep/z.go", "src/x.go"}},
+		{"src/**", []string{"src", "src/deep", "src/deep/z.go", "src/x.go", "src/y.txt"}},
+		{"{src,missing}/*.go", []string{"src/x.go"}},
+		// Symlinks that lead outside the sandbox
+		{"*/x/secret.txt", []string{}},
+		{"l*/leak.go", []string{}},
+		{"s*/out.go", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			result, err := sb.Run(context.Background(), `JSON.stringify(fs.glob("`+tt.pattern+`"))`)
+			if err != nil {
+				t.Fatalf("fs.glob error: %v", err)
+			}
+			want := make([]string, len(tt.want))
+			for i, w := range tt.want {
+				want[i] = filepath.Join(dir, w)
+			}
+			wantJSON, _ := json.Marshal(want)
+			if result != string(wantJSON) {
+				t.Errorf("fs.glob(%q) = %s, want %s", tt.pattern, result, wantJSON)
+			}
+		})
+	}
+
+	result, err := sb.Run(context.Background(), `JSON.stringify(fs.glob("*/*.go", {withSkipped: true}).skipped)`)
+	if err != nil {
+		t.Fatalf("fs.glob error: %v", err)
+	}
+	if !strings.Contains(result, "denied by policy") || !strings.Contains(result, "outside sandbox") {
+		t.Errorf("skipped = %s, want the denied and symlinked directories", result)
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	got, err := expandBraces("{a,b{c,d}}/{x,y}")
+	want := []string{"a/x", "a/y", "bc/x", "bc/y", "bd/x", "bd/y"}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("expandBraces = %v, %v; want %v", got, err, want)
+	}
+	if got, err := expandBraces(strings.Repeat("{a,b}", 10)); err != nil || len(got) != 1024 {
+		t.Errorf("10 groups: %d patterns, %v; want 1024", len(got), err)
+	}
+	if _, err := expandBraces(strings.Repeat("{a,b}", 30)); err == nil {
+		t.Error("30 groups expanded without an error")
+	}
+
+	sb, err := New(Config{WorkDir: t.TempDir(), Stderr: io.Discard})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	_, err = sb.Run(context.Background(), `fs.glob("`+strings.Repeat("{a,b}", 30)+`")`)
+	if err == nil || !strings.Contains(err.Error(), "more than 1024 patterns") {
+		t.Errorf("fs.glob err = %v, want the brace limit", err)
+	}
+}
+
+func TestProtectedInsideWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	for _, f := range []string{"notes.txt", "managed/key", "global/key"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755)
+		os.WriteFile(filepath.Join(dir, f), []byte(f), 0644)
+	}
+
+	// Protected denies from a managed policy and from the user's global
+	// policy, both inside the working directory
+	globalPolicyPath := filepath.Join(t.TempDir(), "policy.json")
+	global := approval.NewPolicy()
+	global.Paths.Protected = []approval.PathEntry{{Path: filepath.Join(dir, "global"), Mode: "rwd", Approval: approval.ApprovalDeny, Source: approval.SourceCLI}}
+	global.Save(globalPolicyPath)
+	approver := approval.NewApprover(t.TempDir(), globalPolicyPath)
+	defer approver.Close()
+	managed := approval.NewPolicy()
+	managed.AddPathEntry(filepath.Join(dir, "managed"), "rwd", approval.ApprovalDeny, approval.SourceConfig)
+	approver.SetManagedPolicy(managed)
+
+	sb, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{dir},
+		WorkDir:       dir,
+		Protected:     approver.ProtectedPaths(),
+		PathDenied:    approver.PathDenied,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+	if _, err := sb.Run(context.Background(), `fs.readFile("notes.txt")`); err != nil {
+		t.Errorf("reading notes.txt: %v", err)
+	}
+	for _, code := range []string{`fs.readFile("managed/key")`, `fs.writeFile("managed/new", "x")`, `fs.readFile("global/key")`} {
+		if _, err := sb.Run(context.Background(), code); err == nil || !strings.Contains(err.Error(), "is protected") {
+			t.Errorf("%s: err = %v, want protected", code, err)
+		}
+	}
+}
+
 func TestFsAppendFile(t *testing.T) {
 	dir := t.TempDir()
 	dir, _ = filepath.EvalSymlinks(dir)
@@ -516,6 +1022,52 @@ func TestFsDelete(t *testing.T) {
 	}
 }
 
+func TestFsDeleteTrash(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	workspace := filepath.Join(dir, "workspace")
+	data := filepath.Join(dir, "data")
+	trashDir := filepath.Join(dir, "trash")
+	os.MkdirAll(workspace, 0755)
+	os.MkdirAll(data, 0755)
+	os.WriteFile(filepath.Join(workspace, "tmp.txt"), []byte("tmp"), 0644)
+	os.WriteFile(filepath.Join(data, "keep.txt"), []byte("keep"), 0644)
+	os.WriteFile(filepath.Join(data, "gone.txt"), []byte("gone"), 0644)
+
+	sb, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{workspace, data},
+		WorkDir:       dir,
+		TrashDir:      trashDir,
+		TrashExempt:   []string{workspace},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	_, err = sb.Run(context.Background(), `
+		fs.delete("workspace/tmp.txt");
+		fs.delete("data/keep.txt");
+		fs.delete("data/gone.txt", {permanent: true});
+	`)
+	if err != nil {
+		t.Fatalf("fs.delete error: %v", err)
+	}
+
+	for _, f := range []string{"workspace/tmp.txt", "data/keep.txt", "data/gone.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s should have been deleted", f)
+		}
+	}
+	entries, err := trash.List(trashDir)
+	if err != nil {
+		t.Fatalf("trash.List error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Path != filepath.Join(data, "keep.txt") {
+		t.Errorf("trash entries = %+v, want only data/keep.txt", entries)
+	}
+}
+
 func TestFsMkdir(t *testing.T) {
 	dir := t.TempDir()
 	dir, _ = filepath.EvalSymlinks(dir)
@@ -661,6 +1213,114 @@ func TestEnvApproved(t *testing.T) {
 	}
 }
 
+// echoTransport answers every request with its Authorization header as
+// the body, and records the header.
+type echoTransport struct{ sent *string }
+
+func (e echoTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	*e.sent = req.Header.Get("Authorization")
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("echo: " + *e.sent)), Request: req}, nil
+}
+
+func TestSecrets(t *testing.T) {
+	var sent string
+	orig := httpClient
+	httpClient = &http.Client{Transport: echoTransport{&sent}}
+	t.Cleanup(func() { httpClient = orig })
+
+	sb, err := New(Config{
+		ApproveNet:    func(string) (bool, error) { return true, nil },
+		ApproveSecret: func(name string) (bool, error) { return name == "API_TOKEN", nil },
+		Secret:        func(string) (string, error) { return "tok-123456", nil },
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	// The script gets a handle; only the request carries the value, and
+	// the echoed response has it redacted
+	result, err := sb.Run(context.Background(), `var t = secrets.get("API_TOKEN");
+var r = net.fetch("https://93.184.216.34/", {headers: {Authorization: "Bearer " + t}});
+[t.startsWith("{{secret:API_TOKEN:"), r.body].join("|")`)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if sent != "Bearer tok-123456" {
+		t.Errorf("sent Authorization = %q", sent)
+	}
+	if result != "true|echo: Bearer [secret API_TOKEN]" {
+		t.Errorf("result = %q", result)
+	}
+
+	errorTests := []struct {
+		code string
+		want string
+	}{
+		{`secrets.get("OTHER")`, "access denied for OTHER"},
+		{`secrets.get("-rf")`, "invalid secret name"},
+		{`net.fetch("http://93.184.216.34/", {headers: {Authorization: secrets.get("API_TOKEN")}})`, "must be sent over https"},
+		{`net.fetch("https://93.184.216.34/", {headers: {Authorization: "{{secret:API_TOKEN:0123456789abcdef}}"}})`, "unknown secret handle"},
+	}
+	for _, tt := range errorTests {
+		if _, err := sb.Run(context.Background(), tt.code); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+
+	// No approval handler denies
+	sb, _ = New(Config{})
+	if _, err := sb.Run(context.Background(), `secrets.get("API_TOKEN")`); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("without ApproveSecret: err = %v", err)
+	}
+}
+
+func TestRedactEnv(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "sk-0123456789")
+	t.Setenv("TEST_PLAIN_VAR", "plain-value-123")
+	dir := t.TempDir()
+	memories := filepath.Join(dir, "memories")
+	os.Mkdir(memories, 0700)
+
+	var stderr bytes.Buffer
+	rs := redact.New()
+	sb, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{dir},
+		Stderr:        &stderr,
+		Redact:        rs,
+		RedactPaths:   []string{memories},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	// Only the secret-looking name is tracked; the script itself still
+	// gets the real value
+	result, err := sb.Run(context.Background(), `var k = env.get("TEST_API_KEY"), p = env.get("TEST_PLAIN_VAR");
+console.log("key", k, p);
+fs.writeFile("`+memories+`/note.md", "key is " + k);
+fs.writeFile("`+dir+`/elsewhere.txt", k);
+k`)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result != "sk-0123456789" {
+		t.Errorf("result = %q, want the real value", result)
+	}
+	if got := stderr.String(); got != "key [redacted] plain-value-123\n" {
+		t.Errorf("console = %q", got)
+	}
+	if data, _ := os.ReadFile(filepath.Join(memories, "note.md")); string(data) != "key is [redacted]" {
+		t.Errorf("memory = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "elsewhere.txt")); string(data) != "sk-0123456789" {
+		t.Errorf("file outside RedactPaths = %q, want it unmasked", data)
+	}
+	if got := rs.String("sk-0123456789"); got != redact.Mask {
+		t.Errorf("Set.String = %q, want the value recorded", got)
+	}
+}
+
 // Process bridge tests
 
 func TestProcessCwd(t *testing.T) {
@@ -1112,3 +1772,675 @@ func TestSSRFProtectionPrivateIP(t *testing.T) {
 		}
 	}
 }
+
+func TestReadOnlyMode(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.WriteFile(filepath.Join(dir, "data.txt"), []byte("hello"), 0644)
+
+	sb, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{dir},
+		WorkDir:       dir,
+		ReadOnly:      true,
+		ApprovePath: func(op, path string) (bool, error) {
+			t.Errorf("ApprovePath should not be called in read-only mode (op=%s)", op)
+			return true, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	result, err := sb.Run(context.Background(), `fs.readFile("data.txt")`)
+	if err != nil {
+		t.Fatalf("reads should work in read-only mode: %v", err)
+	}
+	if result != "hello" {
+		t.Errorf("result = %q, want %q", result, "hello")
+	}
+
+	for _, code := range []string{
+		`fs.writeFile("out.txt", "x")`,
+		`fs.appendFile("data.txt", "x")`,
+		`fs.delete("data.txt")`,
+		`fs.mkdir("sub")`,
+		`fs.copy("data.txt", "copy.txt")`,
+		`fs.move("data.txt", "moved.txt")`,
+	} {
+		_, err := sb.Run(context.Background(), code)
+		if err == nil || !strings.Contains(err.Error(), "read-only mode") {
+			t.Errorf("%s: err = %v, want read-only error", code, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "data.txt")); err != nil {
+		t.Errorf("data.txt should be untouched: %v", err)
+	}
+}
+
+func TestStreamLines(t *testing.T) {
+	var stderr strings.Builder
+	sb, err := New(Config{Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	code := `
+		var count = 0;
+		process.stdin.on("line", function (line) {
+			if (line === "???") {
+				agent.resume("unrecognized: " + line);
+			}
+			count++;
+			console.log("got " + line);
+		});
+		process.stdin.on("end", function () { console.log("total " + count); });
+	`
+	lines := bufio.NewScanner(strings.NewReader("a\nb\n???\nc\n"))
+
+	err = sb.Stream(context.Background(), code, lines)
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) {
+		t.Fatalf("expected ChunkError, got %T: %v", err, err)
+	}
+	if chunkErr.Line != "???" {
+		t.Errorf("chunk line = %q, want %q", chunkErr.Line, "???")
+	}
+	var resumeErr *ResumeError
+	if !errors.As(err, &resumeErr) || resumeErr.Context != "unrecognized: ???" {
+		t.Errorf("expected resume context, got %v", err)
+	}
+
+	// Continuing with the same scanner picks up after the failed line
+	sb2, _ := New(Config{Stderr: &stderr})
+	if err := sb2.Stream(context.Background(), code, lines); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := stderr.String()
+	for _, want := range []string{"got a", "got b", "got c", "total 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stderr = %q, want to contain %q", out, want)
+		}
+	}
+}
+
+func TestStreamNoHandler(t *testing.T) {
+	sb, err := New(Config{})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	err = sb.Stream(context.Background(), `var x = 1;`, bufio.NewScanner(strings.NewReader("first\n")))
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) || !errors.Is(err, ErrNoStdinHandler) {
+		t.Fatalf("expected ChunkError wrapping ErrNoStdinHandler, got %v", err)
+	}
+	if chunkErr.Line != "first" {
+		t.Errorf("chunk line = %q, want %q", chunkErr.Line, "first")
+	}
+
+	// No input at all: nothing to do
+	sb2, _ := New(Config{})
+	if err := sb2.Stream(context.Background(), ``, bufio.NewScanner(strings.NewReader(""))); err != nil {
+		t.Errorf("unexpected error with empty input: %v", err)
+	}
+}
+
+// blockingTransport stalls until the request context is cancelled, either
+// before the response (headers) or while the body is being read.
+type blockingTransport struct {
+	inBody bool
+}
+
+func (t blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.inBody {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{},
+		Body:       io.NopCloser(ctxReader{req.Context()}),
+	}, nil
+}
+
+type ctxReader struct{ ctx context.Context }
+
+func (r ctxReader) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+// downloadTransport serves content with an ETag and answers a matching
+// If-None-Match with 304, counting full responses.
+type downloadTransport struct {
+	content string
+	served  *int
+}
+
+func (t downloadTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/missing") {
+		return &http.Response{StatusCode: 404, Status: "404 Not Found", Header: http.Header{}, Body: http.NoBody}, nil
+	}
+	if req.Header.Get("If-None-Match") == `"v1"` {
+		return &http.Response{StatusCode: 304, Status: "304 Not Modified", Header: http.Header{}, Body: http.NoBody}, nil
+	}
+	*t.served++
+	return &http.Response{
+		StatusCode: 200,
+		Status:     "200 OK",
+		Header:     http.Header{"Etag": {`"v1"`}},
+		Body:       io.NopCloser(strings.NewReader(t.content)),
+	}, nil
+}
+
+func TestNetDownload(t *testing.T) {
+	served := 0
+	orig := downloadClient
+	downloadClient = &http.Client{Transport: downloadTransport{content: "model weights", served: &served}}
+	t.Cleanup(func() { downloadClient = orig })
+
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	cache := t.TempDir()
+	sum := sha256.Sum256([]byte("model weights"))
+	want := fmt.Sprintf("%x", sum)
+	allowNet := func(string) (bool, error) { return true, nil }
+
+	run := func(cfg Config, code string) (string, error) {
+		t.Helper()
+		cfg.AllowedPaths = []string{dir}
+		cfg.WritablePaths = []string{dir}
+		cfg.WorkDir = dir
+		cfg.ApproveNet = allowNet
+		sb, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sb.Run(context.Background(), code)
+	}
+
+	// Two thoughts download the same URL: the second revalidates and
+	// copies from the cache.
+	for i, wantCached := range []bool{false, true} {
+		result, err := run(Config{BlobCache: cache}, `var d = net.download("https://93.184.216.34/w.bin", "w`+fmt.Sprint(i)+`.bin");
+JSON.stringify([d.size, d.sha256, d.cached])`)
+		if err != nil {
+			t.Fatalf("download %d: %v", i, err)
+		}
+		if result != fmt.Sprintf(`[13,"%s",%v]`, want, wantCached) {
+			t.Errorf("download %d = %s", i, result)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, fmt.Sprintf("w%d.bin", i))); string(data) != "model weights" {
+			t.Errorf("w%d.bin = %q", i, data)
+		}
+	}
+	if served != 1 {
+		t.Errorf("content sent %d times, want 1", served)
+	}
+
+	// A known sha256 is copied from the cache without a request, even from
+	// another URL.
+	result, err := run(Config{BlobCache: cache}, `net.download("https://93.184.216.34/mirror.bin", "m.bin", {sha256: "`+strings.ToUpper(want)+`"}).cached`)
+	if err != nil || result != "true" || served != 1 {
+		t.Errorf("download by sha256 = %s, %v (served %d)", result, err, served)
+	}
+
+	// Without a cache the content is fetched every time.
+	if _, err := run(Config{}, `net.download("https://93.184.216.34/w.bin", "direct.bin")`); err != nil || served != 2 {
+		t.Errorf("uncached download: %v (served %d)", err, served)
+	}
+
+	errs := []struct{ code, want string }{
+		{`net.download("https://93.184.216.34/missing", "x.bin")`, "404 Not Found"},
+		{`net.download("https://93.184.216.34/other.bin", "x.bin", {sha256: "` + strings.Repeat("0", 64) + `"})`, "sha256 mismatch"},
+		{`net.download("https://93.184.216.34/w.bin")`, "destination path must be given"},
+		{`net.download("http://127.0.0.1/w.bin", "x.bin")`, "private IP"},
+	}
+	for _, tt := range errs {
+		if _, err := run(Config{}, tt.code); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.code, err, tt.want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x.bin")); !os.IsNotExist(err) {
+		t.Error("a failed download left x.bin behind")
+	}
+}
+
+func TestCancelDuringBridgeOps(t *testing.T) {
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	allowNet := func(string) (bool, error) { return true, nil }
+
+	tests := []struct {
+		name      string
+		cfg       Config
+		transport http.RoundTripper
+		code      string
+	}{
+		{
+			name: "process.sleep",
+			code: `process.sleep(10000)`,
+		},
+		{
+			name: "input.prompt",
+			cfg: Config{PromptInput: func(string, string) (string, error) {
+				<-block
+				return "", nil
+			}},
+			code: `input.prompt("name?")`,
+		},
+		{
+			name: "path approval",
+			cfg: Config{ApprovePath: func(string, string) (bool, error) {
+				<-block
+				return true, nil
+			}},
+			code: `fs.readFile("/etc/hostname")`,
+		},
+		{
+			name: "env approval",
+			cfg: Config{ApproveEnv: func(string) (bool, error) {
+				<-block
+				return true, nil
+			}},
+			code: `env.get("HOME")`,
+		},
+		{
+			name: "net approval",
+			cfg: Config{ApproveNet: func(string) (bool, error) {
+				<-block
+				return true, nil
+			}},
+			code: `net.fetch("http://93.184.216.34/")`,
+		},
+		{
+			name:      "net.fetch request",
+			cfg:       Config{ApproveNet: allowNet},
+			transport: blockingTransport{},
+			code:      `net.fetch("http://93.184.216.34/")`,
+		},
+		{
+			name:      "net.fetch body",
+			cfg:       Config{ApproveNet: allowNet},
+			transport: blockingTransport{inBody: true},
+			code:      `net.fetch("http://93.184.216.34/")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.transport != nil {
+				orig := httpClient
+				httpClient = &http.Client{Transport: tt.transport}
+				t.Cleanup(func() { httpClient = orig })
+			}
+
+			sb, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("failed to create sandbox: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			var cancelled time.Time
+			time.AfterFunc(50*time.Millisecond, func() {
+				cancelled = time.Now()
+				cancel()
+			})
+
+			_, err = sb.Run(ctx, tt.code)
+			elapsed := time.Since(cancelled)
+			if err == nil {
+				t.Fatal("expected error after cancellation")
+			}
+			if !errors.Is(err, approval.ErrInterrupted) {
+				t.Errorf("err = %v, want ErrInterrupted", err)
+			}
+			// Generous, so a loaded machine doesn't fail it: a bridge call
+			// that ignored the cancel would block until the test times out
+			if elapsed > 5*time.Second {
+				t.Errorf("returned %v after cancel, want it to return promptly", elapsed)
+			}
+		})
+	}
+}
+
+func TestFsPromote(t *testing.T) {
+	thoughtDir := t.TempDir()
+	thoughtDir, _ = filepath.EvalSymlinks(thoughtDir)
+	persistent := filepath.Join(thoughtDir, "workspace")
+	os.MkdirAll(persistent, 0755)
+
+	run, err := workspace.NewRun(thoughtDir, persistent)
+	if err != nil {
+		t.Fatalf("NewRun: %v", err)
+	}
+	defer run.Close()
+
+	sb, err := New(Config{
+		AllowedPaths:  []string{thoughtDir, run.Dir()},
+		WritablePaths: []string{run.Dir()},
+		WorkDir:       thoughtDir,
+		Workspace:     run,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	_, err = sb.Run(context.Background(), `
+		fs.mkdir(fs.workspace + "/out");
+		fs.writeFile(fs.workspace + "/out/report.txt", "done");
+		fs.writeFile(fs.workspace + "/scratch.txt", "temp");
+		fs.promote("out");
+	`)
+	if err != nil {
+		t.Fatalf("fs.promote error: %v", err)
+	}
+	if got := run.Promoted(); len(got) != 1 || got[0] != "out" {
+		t.Errorf("Promoted() = %v, want [out]", got)
+	}
+	// Nothing is copied until the run commits
+	if _, err := os.Stat(filepath.Join(persistent, "out")); !os.IsNotExist(err) {
+		t.Error("promoted path was copied before Commit")
+	}
+
+	for name, code := range map[string]string{
+		"outside run workspace": `fs.promote("` + filepath.Join(thoughtDir, "policy.json") + `")`,
+		"missing":               `fs.promote("nope.txt")`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sb.Run(context.Background(), code); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	t.Run("not per-run", func(t *testing.T) {
+		plain, err := New(Config{AllowedPaths: []string{thoughtDir}, WorkDir: thoughtDir})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := plain.Run(context.Background(), `fs.promote("x")`); err == nil || !strings.Contains(err.Error(), "per-run") {
+			t.Errorf("err = %v, want per-run error", err)
+		}
+	})
+}
+
+// bodyTransport answers every request with 200 and body.
+type bodyTransport string
+
+func (b bodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(string(b))), Request: req}, nil
+}
+
+func TestEvalModes(t *testing.T) {
+	orig := httpClient
+	httpClient = &http.Client{Transport: bodyTransport("1 + 1")}
+	t.Cleanup(func() { httpClient = orig })
+	allowNet := func(string) (bool, error) { return true, nil }
+
+	tests := []struct {
+		mode string
+		code string
+		want string // result, or error substring prefixed with "!"
+	}{
+		{"", `eval("1 + 1") + new Function("a", "return a * 2")(3)`, "8"},
+		{"", `var b = net.fetch("http://93.184.216.34/").body; eval(b)`, "!eval is disabled after net.fetch"},
+		{"", `var b = net.fetch("http://93.184.216.34/").body; Function("return " + b)()`, "!Function is disabled after net.fetch"},
+		{"", `net.fetch("http://93.184.216.34/"); (function () {}).constructor("return 1")()`, "!Function is disabled"},
+		{"", `net.fetch("http://93.184.216.34/"); (function* () {}).constructor("yield 1")`, "!GeneratorFunction is disabled"},
+		{"", `net.fetch("http://93.184.216.34/"); Function("return this")() === globalThis`, "true"},
+		{EvalDeny, `eval("1")`, "!eval is disabled in this sandbox (frontmatter eval: deny)"},
+		{EvalDeny, `new Function("return 1")()`, "!Function is disabled"},
+		{EvalAllow, `var b = net.fetch("http://93.184.216.34/").body; eval(b)`, "2"},
+	}
+	for _, tt := range tests {
+		sb, err := New(Config{ApproveNet: allowNet, Eval: tt.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := sb.Run(context.Background(), tt.code)
+		if want, isErr := strings.CutPrefix(tt.want, "!"); isErr {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%q (eval %q): err = %v, want %q", tt.code, tt.mode, err, want)
+			}
+			continue
+		}
+		if err != nil || result != tt.want {
+			t.Errorf("%q (eval %q) = %q, %v; want %q", tt.code, tt.mode, result, err, tt.want)
+		}
+	}
+}
+
+// Writing fetched code to a file and require()-ing it would get around the
+// eval guard, so require refuses files written once eval is off.
+func TestEvalRequire(t *testing.T) {
+	orig, origDownload := httpClient, downloadClient
+	httpClient = &http.Client{Transport: bodyTransport("module.exports = 1 + 1")}
+	downloadClient = httpClient
+	t.Cleanup(func() { httpClient, downloadClient = orig, origDownload })
+	allowNet := func(string) (bool, error) { return true, nil }
+
+	tests := []struct {
+		mode string
+		code string
+		want string // result, or error substring prefixed with "!"
+	}{
+		{"", `var b = net.fetch("http://93.184.216.34/").body; fs.writeFile("x.js", b); require("./x.js")`, "!written after net.fetch"},
+		{"", `net.download("http://93.184.216.34/lib.js", "x.js"); require("./x.js")`, "!written after net.fetch"},
+		{"", `var b = net.fetch("http://93.184.216.34/").body; fs.writeFile("a.js", b); fs.move("a.js", "x.js"); require("./x.js")`, "!written after net.fetch"},
+		{"", `fs.writeFile("x.js", "module.exports = 3"); net.fetch("http://93.184.216.34/"); require("./x.js")`, "3"},
+		{"", `require("./lib.js")`, "4"},
+		{EvalDeny, `fs.writeFile("x.js", "module.exports = 3"); require("./x.js")`, "!frontmatter eval: deny"},
+		{EvalDeny, `net.fetch("http://93.184.216.34/"); require("./lib.js")`, "4"},
+		{EvalAllow, `var b = net.fetch("http://93.184.216.34/").body; fs.writeFile("x.js", b); require("./x.js")`, "2"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "lib.js"), []byte("module.exports = 4"), 0644)
+		sb, err := New(Config{AllowedPaths: []string{dir}, WritablePaths: []string{dir}, WorkDir: dir, ApproveNet: allowNet, Eval: tt.mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := sb.Run(context.Background(), tt.code)
+		if want, isErr := strings.CutPrefix(tt.want, "!"); isErr {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%q (eval %q): err = %v, want %q", tt.code, tt.mode, err, want)
+			}
+			continue
+		}
+		if err != nil || result != tt.want {
+			t.Errorf("%q (eval %q) = %q, %v; want %q", tt.code, tt.mode, result, err, tt.want)
+		}
+	}
+}
+
+func TestDebug(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "n.txt"), []byte("2"), 0644)
+	code := `"use strict";
+function double(x) {
+  var y = x * 2;
+  return y;
+}
+let n = Number(fs.readFile("n.txt"));
+if (n > 1) { n = double(n) }
+n`
+
+	var lines []int
+	var ops []string
+	var seen string
+	sb, err := New(Config{AllowedPaths: []string{dir}, WorkDir: dir, Debug: &Debug{
+		Step: func(s Step) error {
+			lines = append(lines, s.Line)
+			if s.Line == 4 {
+				seen, _ = s.Eval("y + ':' + x")
+				if len(s.Names) != 2 || s.Names[0] != "x" || s.Names[1] != "y" {
+					t.Errorf("names in double = %v", s.Names)
+				}
+			}
+			return nil
+		},
+		Op: func(call string) { ops = append(ops, call) },
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := sb.Run(context.Background(), code)
+	if err != nil || result != "4" {
+		t.Fatalf("Run = %q, %v", result, err)
+	}
+	if got := fmt.Sprint(lines); got != "[6 7 7 3 4 8]" {
+		t.Errorf("paused at lines %s", got)
+	}
+	if seen != `"4:2"` {
+		t.Errorf("eval at line 4 = %s", seen)
+	}
+	if len(ops) != 1 || ops[0] != `fs.readFile("n.txt")` {
+		t.Errorf("ops = %v", ops)
+	}
+
+	// An error from Step stops the script
+	sb, _ = New(Config{Debug: &Debug{Step: func(Step) error { return errors.New("quit") }}})
+	if _, err := sb.Run(context.Background(), "1;\n2"); err == nil || !strings.Contains(err.Error(), "quit") {
+		t.Errorf("stopped run error = %v", err)
+	}
+}
+
+func TestLazyGlobals(t *testing.T) {
+	sb, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := sb.Run(context.Background(), `var out = [typeof sys.platform, typeof mime.detect, "input" in globalThis, typeof LimitError];
+input = 1;
+out.concat([input, new NetError("x") instanceof Error]).join(" ")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "function function true function 1 true" {
+		t.Errorf("result = %q", result)
+	}
+}
+
+func TestProgramCache(t *testing.T) {
+	code := `var n = process.args.length; "args:" + n`
+	first, err := compile(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := compile(code); again != first {
+		t.Error("same code compiled twice")
+	}
+
+	// One cached program runs in many runtimes at once, as with --map.
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			sb, err := New(Config{Args: make([]string, i)})
+			if err != nil {
+				errs <- err
+				return
+			}
+			result, err := sb.Run(context.Background(), code)
+			if err == nil && result != fmt.Sprintf("args:%d", i) {
+				err = fmt.Errorf("run %d: result = %q", i, result)
+			}
+			errs <- err
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Syntax errors read as they always have and aren't cached.
+	sb, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sb.Run(context.Background(), `var x = ;`)
+	if err == nil || !strings.HasPrefix(err.Error(), "SyntaxError") {
+		t.Errorf("err = %v, want a SyntaxError", err)
+	}
+	programs.Lock()
+	_, cached := programs.m[sha256.Sum256([]byte(`var x = ;`))]
+	programs.Unlock()
+	if cached {
+		t.Error("a program that didn't compile was cached")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	os.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"x": [1, 2]}`), 0644)
+
+	profile := NewProfile()
+	sb, err := New(Config{
+		AllowedPaths: []string{dir},
+		WorkDir:      dir,
+		Profile:      profile,
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	result, err := sb.Run(context.Background(), `
+		fs.readFile("a.json"); fs.readFile("a.json");
+		var code; try { fs.readFile("missing.json") } catch (e) { code = e.code }
+		[code, json.stream("a.json", "x.#").length, typeof sys.platform()].join(",")
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "ENOENT,2,string" {
+		t.Errorf("result = %s", result)
+	}
+
+	calls := map[string]int{}
+	for _, b := range profile.Bridges() {
+		calls[b.Name] = b.Calls
+	}
+	if calls["fs.readFile"] != 3 || calls["json.stream"] != 1 || calls["sys.platform"] != 1 || len(calls) != 3 {
+		t.Errorf("bridge calls = %v", calls)
+	}
+	if runs := profile.Runs(); runs.Calls != 1 || runs.TimeMS <= 0 {
+		t.Errorf("runs = %+v", runs)
+	}
+}
+
+func TestProtectedInsideAllowed(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".ssh"), 0700)
+	os.WriteFile(filepath.Join(dir, ".ssh", "id_ed25519"), []byte("key"), 0600)
+	os.WriteFile(filepath.Join(dir, ".ssh", "known_hosts"), []byte("hosts"), 0600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644)
+
+	sb, err := New(Config{
+		AllowedPaths:  []string{dir},
+		WritablePaths: []string{dir},
+		WorkDir:       dir,
+		Protected:     []string{filepath.Join(dir, ".ssh")},
+		PathDenied: func(op, path string) bool {
+			return filepath.Base(path) != "known_hosts"
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create sandbox: %v", err)
+	}
+
+	if _, err := sb.Run(context.Background(), `fs.readFile(".ssh/id_ed25519")`); err == nil || !strings.Contains(err.Error(), "protected") {
+		t.Errorf("reading a protected file: err = %v, want access denied", err)
+	}
+	if _, err := sb.Run(context.Background(), `fs.writeFile(".ssh/authorized_keys", "x")`); err == nil {
+		t.Error("writing into a protected directory should fail")
+	}
+	if result, err := sb.Run(context.Background(), `fs.readFile(".ssh/known_hosts") + fs.readFile("notes.txt")`); err != nil || result != "hostsnotes" {
+		t.Errorf("result = %q, err = %v; want the paths the policy allows", result, err)
+	}
+}
@@ -0,0 +1,105 @@
+package sandbox
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// MaxStdinLine is the longest stdin line accepted in stream mode.
+const MaxStdinLine = 1 << 20
+
+// ErrNoStdinHandler is reported when memory.js finishes without registering
+// a process.stdin.on("line", fn) handler.
+var ErrNoStdinHandler = errors.New(`memory.js did not register process.stdin.on("line", fn)`)
+
+// ChunkError reports a stdin line that memory.js could not handle, either
+// because it called agent.resume() or because it threw. Line has already
+// been consumed from the input.
+type ChunkError struct {
+	Line string
+	Err  error
+}
+
+func (e *ChunkError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// Stream runs code once so it can register process.stdin handlers, then feeds
+// each line from lines to the "line" handler in the same runtime. It returns
+// nil once input is exhausted (after calling the "end" handler), or a
+// *ChunkError for the first line memory.js couldn't handle. The caller can
+// hand that line to the agent and call Stream again with the same scanner.
+func (s *Sandbox) Stream(ctx context.Context, code string, lines *bufio.Scanner) error {
+	s.ctx = ctx
+	s.stdinHandlers = nil
+	vm := s.newRuntime()
+	defer s.watch(ctx, vm)()
+
+	err := s.call(func() error {
+		_, err := runCode(vm, code)
+		return err
+	})
+	if errors.Is(err, errCleanExit) {
+		return nil
+	}
+	if err != nil {
+		return nextChunk(lines, err)
+	}
+
+	onLine := s.stdinHandlers["line"]
+	if onLine == nil {
+		return nextChunk(lines, ErrNoStdinHandler)
+	}
+
+	for lines.Scan() {
+		line := lines.Text()
+		err := s.call(func() error {
+			_, err := onLine(goja.Undefined(), vm.ToValue(line))
+			return err
+		})
+		if errors.Is(err, errCleanExit) {
+			return nil
+		}
+		if err != nil {
+			return &ChunkError{Line: line, Err: err}
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return fmt.Errorf("reading stdin: %w", err)
+	}
+
+	if onEnd := s.stdinHandlers["end"]; onEnd != nil {
+		err := s.call(func() error {
+			_, err := onEnd(goja.Undefined())
+			return err
+		})
+		if err != nil && !errors.Is(err, errCleanExit) {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextChunk pairs a setup failure with the next unread line so the agent
+// sees real input. With no input left, only real errors are reported.
+func nextChunk(lines *bufio.Scanner, err error) error {
+	if lines.Scan() {
+		return &ChunkError{Line: lines.Text(), Err: err}
+	}
+	if scanErr := lines.Err(); scanErr != nil {
+		return fmt.Errorf("reading stdin: %w", scanErr)
+	}
+	var resumeErr *ResumeError
+	if errors.Is(err, ErrNoStdinHandler) || errors.As(err, &resumeErr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"0 8 * * *", "*/15 * * * 1-5", "0 9,17 1 jan-mar MON", "5/10 * * * 7", "@daily", "@HOURLY"} {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("ParseCron(%q) error: %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "0 8 * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "x * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		tm, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tests := []struct {
+		expr, after, want string
+	}{
+		{"0 8 * * *", "2026-01-01 07:59", "2026-01-01 08:00"},
+		{"0 8 * * *", "2026-01-01 08:00", "2026-01-02 08:00"},
+		{"*/15 * * * *", "2026-01-01 10:16", "2026-01-01 10:30"},
+		{"30 9 * * mon-fri", "2026-01-02 10:00", "2026-01-05 09:30"}, // Friday to Monday
+		{"0 0 * * 7", "2026-01-01 00:00", "2026-01-04 00:00"},        // 7 is Sunday
+		{"0 0 1 * *", "2026-01-31 12:00", "2026-02-01 00:00"},
+		{"0 0 29 2 *", "2026-03-01 00:00", "2028-02-29 00:00"},
+		// Both days restricted: the 13th or any Friday
+		{"0 12 13 * fri", "2026-01-03 00:00", "2026-01-09 12:00"},
+		{"@monthly", "2026-12-15 00:00", "2027-01-01 00:00"},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseCron(%q) error: %v", tt.expr, err)
+		}
+		got := c.Next(at(tt.after))
+		if want := at(tt.want); !got.Equal(want) {
+			t.Errorf("%q after %s = %s, want %s", tt.expr, tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+		if !c.Matches(got) {
+			t.Errorf("%q doesn't match its own Next %s", tt.expr, got)
+		}
+	}
+
+	c, _ := ParseCron("0 0 31 2 *")
+	if got := c.Next(at("2026-01-01 00:00")); !got.IsZero() {
+		t.Errorf("Next for Feb 31 = %s, want zero", got)
+	}
+}
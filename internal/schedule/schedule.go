@@ -0,0 +1,314 @@
+// Package schedule runs thoughts on cron schedules: `thought schedule add`
+// stores entries in ~/.thinkingscript/schedule/schedules.json and `thought
+// daemon` runs them when they're due. Scheduled runs use memory.js only
+// (think --no-agent) and never prompt; each run's output, and why
+// memory.js handed over when it did, is appended to the thought's
+// runs/schedule.log.
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/flock"
+	"github.com/thinkingscript/cli/internal/workspace"
+)
+
+// Entry is one scheduled thought.
+type Entry struct {
+	ID         string    `json:"id"`
+	Script     string    `json:"script"` // absolute path or URL
+	Args       []string  `json:"args,omitempty"`
+	Cwd        string    `json:"cwd"`
+	ThoughtDir string    `json:"thought_dir"` // where the run log goes
+	Cron       string    `json:"cron"`
+	Added      time.Time `json:"added"`
+}
+
+// Run is how a scheduled run ended.
+type Run struct {
+	Started  time.Time
+	Ended    time.Time
+	ExitCode int
+	Error    string // think could not be started
+}
+
+var (
+	// ErrNotFound is returned when no entry has the given ID.
+	ErrNotFound = errors.New("schedule entry not found")
+	// ErrBusy is returned by Run when another daemon holds the schedule.
+	ErrBusy = errors.New("another daemon is running")
+)
+
+// maxLogSize is how large a thought's schedule.log grows before its older
+// half is dropped.
+const maxLogSize = 1 << 20
+
+func schedulesPath(dir string) string {
+	return filepath.Join(dir, "schedules.json")
+}
+
+// LogPath returns the log a thought's scheduled runs append to.
+func LogPath(thoughtDir string) string {
+	return filepath.Join(workspace.RunsDir(thoughtDir), "schedule.log")
+}
+
+// Add schedules a run of script with args in cwd at the times cron
+// matches. thoughtDir is the thought's data directory.
+func Add(dir, script string, args []string, cwd, thoughtDir, cron string) (*Entry, error) {
+	if _, err := ParseCron(cron); err != nil {
+		return nil, err
+	}
+	entries, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var id string
+	for n := 0; ; n++ {
+		id = now.Format("20060102-150405") + fmt.Sprintf("-%d", n)
+		if find(entries, id) < 0 {
+			break
+		}
+	}
+	e := Entry{ID: id, Script: script, Args: args, Cwd: cwd, ThoughtDir: thoughtDir, Cron: cron, Added: now}
+	if err := save(dir, append(entries, e)); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// List returns all entries, oldest first.
+func List(dir string) ([]Entry, error) {
+	data, err := os.ReadFile(schedulesPath(dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", schedulesPath(dir), err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Added.Before(entries[j].Added) })
+	return entries, nil
+}
+
+// Remove deletes an entry. A run of it already in progress finishes.
+func Remove(dir, id string) error {
+	entries, err := List(dir)
+	if err != nil {
+		return err
+	}
+	i := find(entries, id)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return save(dir, append(entries[:i], entries[i+1:]...))
+}
+
+func find(entries []Entry, id string) int {
+	for i, e := range entries {
+		if e.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes the entries, replacing the file atomically so the daemon
+// never reads a partial one.
+func save(dir string, entries []Entry) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if entries == nil {
+		entries = []Entry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := schedulesPath(dir)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Daemon runs scheduled entries when they're due.
+type Daemon struct {
+	Dir   string
+	Think string   // think binary
+	Env   []string // environment for runs; nil = this process's
+
+	OnStart func(Entry)      // called before a run starts, if set
+	OnDone  func(Entry, Run) // called after a run finishes, if set
+	OnError func(error)      // called when the schedule can't be read, if set
+}
+
+// Run checks the schedule at the start of every minute until ctx is
+// cancelled, starting each entry whose cron matches. Entries are re-read
+// every minute, so changes apply without a restart. An entry still
+// running from an earlier minute is skipped. Only one daemon runs per
+// schedule; on cancel, running thoughts are interrupted and waited for.
+func (d *Daemon) Run(ctx context.Context) error {
+	if err := os.MkdirAll(d.Dir, 0700); err != nil {
+		return err
+	}
+	unlock, err := flock.TryLock(filepath.Join(d.Dir, "daemon.lock"))
+	if errors.Is(err, flock.ErrLocked) {
+		return ErrBusy
+	}
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		running = map[string]bool{}
+	)
+	defer wg.Wait()
+	for {
+		now := time.Now()
+		next := now.Truncate(time.Minute).Add(time.Minute)
+		select {
+		case <-time.After(next.Sub(now)):
+		case <-ctx.Done():
+			return nil
+		}
+
+		entries, err := List(d.Dir)
+		if err != nil {
+			if d.OnError != nil {
+				d.OnError(err)
+			}
+			continue
+		}
+		for _, e := range entries {
+			c, err := ParseCron(e.Cron)
+			if err != nil || !c.Matches(next) {
+				continue
+			}
+			mu.Lock()
+			busy := running[e.ID]
+			running[e.ID] = true
+			mu.Unlock()
+			if busy {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.run(ctx, e)
+				mu.Lock()
+				delete(running, e.ID)
+				mu.Unlock()
+			}()
+		}
+	}
+}
+
+// run runs one entry and appends it to the thought's schedule log.
+func (d *Daemon) run(ctx context.Context, e Entry) {
+	if d.OnStart != nil {
+		d.OnStart(e)
+	}
+	r := Run{Started: time.Now()}
+	var out []byte
+	r.ExitCode, out, r.Error = d.exec(ctx, e)
+	r.Ended = time.Now()
+	if err := appendLog(LogPath(e.ThoughtDir), e, r, out); err != nil && r.Error == "" {
+		r.Error = fmt.Sprintf("writing log: %v", err)
+	}
+	if d.OnDone != nil {
+		d.OnDone(e, r)
+	}
+}
+
+// exec runs think --no-agent on the entry with stdin from /dev/null,
+// returning its exit code and combined output. With no terminal, any
+// approval prompt is denied. Cancelling ctx interrupts think so it can
+// clean up.
+func (d *Daemon) exec(ctx context.Context, e Entry) (int, []byte, string) {
+	// "--" ends think's flags, so arguments are never parsed as flags
+	args := append([]string{"--no-agent", "--", e.Script}, e.Args...)
+	cmd := exec.CommandContext(ctx, d.Think, args...)
+	cmd.Dir = e.Cwd
+	cmd.Env = d.Env
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = 10 * time.Second
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), out, ""
+	}
+	if err != nil {
+		return -1, out, err.Error()
+	}
+	return 0, out, ""
+}
+
+// appendLog adds a run to a schedule log: a header line, then the output.
+// A log over maxLogSize keeps only its newer half.
+func appendLog(path string, e Entry, r Run, out []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLogSize {
+		if err := trimLog(path, info.Size()/2); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	status := "ok"
+	switch {
+	case r.Error != "":
+		status = "error: " + r.Error
+	case r.ExitCode != 0:
+		status = fmt.Sprintf("failed (exit %d)", r.ExitCode)
+	}
+	fmt.Fprintf(f, "=== %s  %s  %s (%s)\n", r.Started.Format(time.RFC3339), e.ID, status, r.Ended.Sub(r.Started).Round(time.Millisecond))
+	if len(out) > 0 {
+		f.Write(out)
+		if out[len(out)-1] != '\n' {
+			f.Write([]byte("\n"))
+		}
+	}
+	return nil
+}
+
+// trimLog drops the first skip bytes of a log, up to the next run header.
+func trimLog(path string, skip int64) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rest := data[skip:]
+	if i := bytes.Index(rest, []byte("\n=== ")); i >= 0 {
+		rest = rest[i+1:]
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, rest, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
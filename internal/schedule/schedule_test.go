@@ -0,0 +1,104 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/flock"
+)
+
+// TestMain doubles as a fake think for the daemon: with
+// SCHEDULE_TEST_CHILD set it prints its arguments, and exits 1 when the
+// script is fail.thought.
+func TestMain(m *testing.M) {
+	if os.Getenv("SCHEDULE_TEST_CHILD") == "1" {
+		fmt.Println(strings.Join(os.Args[1:], " "))
+		if os.Args[len(os.Args)-1] == "fail.thought" {
+			fmt.Println("memory.js didn't handle the run")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestAddListRemove(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "schedule")
+	if _, err := Add(dir, "/s/a.thought", nil, "/", "/data/a", "61 * * * *"); err == nil {
+		t.Error("Add with a bad cron succeeded")
+	}
+	a, err := Add(dir, "/s/a.thought", []string{"x"}, "/", "/data/a", "0 8 * * *")
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	b, _ := Add(dir, "/s/b.thought", nil, "/", "/data/b", "@hourly")
+	if a.ID == b.ID {
+		t.Errorf("entries share ID %s", a.ID)
+	}
+
+	entries, err := List(dir)
+	if err != nil || len(entries) != 2 || entries[0].ID != a.ID || entries[1].Cron != "@hourly" {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+	if err := Remove(dir, a.ID); err != nil {
+		t.Fatalf("Remove error: %v", err)
+	}
+	if err := Remove(dir, a.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Remove error = %v, want ErrNotFound", err)
+	}
+	if entries, _ := List(dir); len(entries) != 1 || entries[0].ID != b.ID {
+		t.Errorf("List after Remove = %+v", entries)
+	}
+}
+
+func TestDaemonRunLogs(t *testing.T) {
+	d := &Daemon{Dir: t.TempDir(), Think: os.Args[0], Env: append(os.Environ(), "SCHEDULE_TEST_CHILD=1")}
+	thoughtDir := t.TempDir()
+	var runs []Run
+	d.OnDone = func(_ Entry, r Run) { runs = append(runs, r) }
+
+	d.run(context.Background(), Entry{ID: "ok", Script: "/s/a.thought", Args: []string{"--flag"}, Cwd: t.TempDir(), ThoughtDir: thoughtDir})
+	d.run(context.Background(), Entry{ID: "bad", Script: "fail.thought", Cwd: t.TempDir(), ThoughtDir: thoughtDir})
+	if len(runs) != 2 || runs[0].ExitCode != 0 || runs[1].ExitCode != 1 {
+		t.Fatalf("runs = %+v, want exits 0 and 1", runs)
+	}
+
+	data, err := os.ReadFile(LogPath(thoughtDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := string(data)
+	for _, want := range []string{"ok  ok (", "--no-agent -- /s/a.thought --flag\n", "bad  failed (exit 1)", "memory.js didn't handle the run\n"} {
+		if !strings.Contains(log, want) {
+			t.Errorf("log missing %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestDaemonIsExclusive(t *testing.T) {
+	d := &Daemon{Dir: t.TempDir()}
+	unlock, err := flock.TryLock(filepath.Join(d.Dir, "daemon.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if err := d.Run(context.Background()); !errors.Is(err, ErrBusy) {
+		t.Errorf("Run error = %v, want ErrBusy", err)
+	}
+}
+
+func TestTrimLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.log")
+	os.WriteFile(path, []byte("=== 1\nold\n=== 2\nnew\n"), 0600)
+	if err := trimLog(path, 3); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "=== 2\nnew\n" {
+		t.Errorf("trimmed log = %q", data)
+	}
+}
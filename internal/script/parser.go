@@ -20,12 +20,16 @@ type ParsedScript struct {
 	IsURL       bool
 }
 
-func Parse(path string) (*ParsedScript, error) {
-	isURL := strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+// IsURL reports whether a script path refers to a remote thought.
+func IsURL(path string) bool {
+	return config.IsURL(path)
+}
 
+// Read returns the raw contents of a script from a local file or URL.
+func Read(path string) ([]byte, error) {
 	var data []byte
 	var err error
-	if isURL {
+	if IsURL(path) {
 		data, err = fetchURL(path)
 	} else {
 		data, err = os.ReadFile(path)
@@ -33,6 +37,16 @@ func Parse(path string) (*ParsedScript, error) {
 	if err != nil {
 		return nil, fmt.Errorf("reading script %s: %w", path, err)
 	}
+	return data, nil
+}
+
+func Parse(path string) (*ParsedScript, error) {
+	isURL := IsURL(path)
+
+	data, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
 
 	content := string(data)
 	fingerprint := config.Fingerprint(data)
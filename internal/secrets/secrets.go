@@ -0,0 +1,53 @@
+// Package secrets reads secrets from the operating system's credential
+// store: the macOS Keychain, libsecret (GNOME Keyring, KWallet) on Linux
+// and other Unix systems, and the Windows Credential Manager. Secrets are
+// stored under the service Service and looked up by name; see the README
+// for how to add one with each system's own tools.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Service is the service (macOS, libsecret) or target prefix (Windows)
+// secrets are stored under.
+const Service = "thinkingscript"
+
+// LookupTimeout bounds one lookup, including any unlock dialog the store
+// shows.
+const LookupTimeout = 30 * time.Second
+
+// ErrNotFound is returned when the store has no secret with the name.
+var ErrNotFound = errors.New("not found in the credential store")
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// ValidateName checks that name can be used as a secret name: letters,
+// digits, and _ . - only, not starting with a punctuation mark.
+func ValidateName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q (use letters, digits, _, ., and -)", name)
+	}
+	return nil
+}
+
+// Get returns the secret stored under name.
+func Get(ctx context.Context, name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, LookupTimeout)
+	defer cancel()
+	value, err := lookup(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
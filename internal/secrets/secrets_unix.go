@@ -0,0 +1,33 @@
+//go:build !darwin && !windows
+
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// lookup reads a secret with libsecret's secret-tool. A missing item
+// exits 1 without output.
+func lookup(ctx context.Context, name string) (string, error) {
+	path, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return "", errors.New("secret-tool not found; install libsecret-tools (or your distribution's libsecret package)")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "lookup", "service", Service, "name", name)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() == 1 && msg == "" {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("libsecret: %v: %s", err, msg)
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
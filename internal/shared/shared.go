@@ -0,0 +1,130 @@
+// Package shared layers a user's thought over a read-only, system-wide copy
+// of it. Directories listed in config.json's thought_path hold thoughts laid
+// out like ~/.thinkingscript/thoughts/<name>/ (converged memory.js, policy,
+// workspace, memories), typically installed by an admin for every user on a
+// server. The shared copy is never written: memory.js is read from it until
+// the user's run writes its own, workspace and memories are copied up into
+// the user's empty directories, and its policy seeds the user's first one.
+package shared
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/thinkingscript/cli/internal/approval"
+)
+
+// Find returns the first <dir>/<name> that is a directory, or "".
+func Find(name string, dirs []string) string {
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			abs, err := filepath.Abs(candidate)
+			if err != nil {
+				return candidate
+			}
+			return abs
+		}
+	}
+	return ""
+}
+
+// ReadMemoryJS reads the user's memory.js, falling back to the shared
+// copy until the user has one of their own. sharedDir may be "".
+func ReadMemoryJS(userPath, sharedDir string) ([]byte, error) {
+	code, err := os.ReadFile(userPath)
+	if err == nil || !os.IsNotExist(err) || sharedDir == "" {
+		return code, err
+	}
+	code, sharedErr := os.ReadFile(filepath.Join(sharedDir, "memory.js"))
+	if sharedErr != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// CopyUp copies the shared directory src into the user's dst when dst is
+// missing or empty, so the user starts from the shared state and writes
+// only their own copy. Symlinks are skipped and everything copied is owned
+// and writable by the user. Reports whether anything was copied.
+func CopyUp(dst, src string) (bool, error) {
+	if info, err := os.Stat(src); err != nil || !info.IsDir() {
+		return false, nil
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		return false, nil
+	}
+	if err := copyTree(src, dst); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Policy loads the shared thought's policy for seeding a user's policy.
+// Entries generated at bootstrap describe the shared copy's own layout and
+// are dropped (the user bootstraps their own); grants inside the shared
+// workspace and memories are moved to the user's. Returns nil if the
+// shared thought has no policy.
+func Policy(sharedDir, dataDir string) (*approval.Policy, error) {
+	path := filepath.Join(sharedDir, "policy.json")
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil
+	}
+	p, err := approval.LoadPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	kept := p.Paths.Entries[:0]
+	for _, e := range p.Paths.Entries {
+		if e.Source != approval.SourceDefault {
+			kept = append(kept, e)
+		}
+	}
+	p.Paths.Entries = kept
+	for _, sub := range []string{"workspace", "memories"} {
+		p.MovePaths(filepath.Join(sharedDir, sub), filepath.Join(dataDir, sub))
+	}
+	return p, nil
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0700)
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return copyFile(path, target, info.Mode().Perm()|0600)
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
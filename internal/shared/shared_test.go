@@ -0,0 +1,110 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/approval"
+)
+
+func sharedThought(t *testing.T) (root, dir string) {
+	t.Helper()
+	root = t.TempDir()
+	dir = filepath.Join(root, "weather")
+	os.MkdirAll(filepath.Join(dir, "workspace", "lib"), 0755)
+	os.MkdirAll(filepath.Join(dir, "memories"), 0755)
+	os.WriteFile(filepath.Join(dir, "memory.js"), []byte("shared()"), 0444)
+	os.WriteFile(filepath.Join(dir, "workspace", "lib", "parse.js"), []byte("parse()"), 0444)
+	os.WriteFile(filepath.Join(dir, "memories", "api.md"), []byte("use v2"), 0444)
+	return root, dir
+}
+
+func TestFind(t *testing.T) {
+	root, dir := sharedThought(t)
+	if got := Find("weather", []string{"", filepath.Join(root, "missing"), root}); got != dir {
+		t.Errorf("Find = %q, want %q", got, dir)
+	}
+	if got := Find("news", []string{root}); got != "" {
+		t.Errorf("Find(news) = %q, want empty", got)
+	}
+}
+
+func TestReadMemoryJS(t *testing.T) {
+	_, dir := sharedThought(t)
+	user := filepath.Join(t.TempDir(), "memory.js")
+
+	// Until the user has a memory.js, the shared one is used
+	if code, err := ReadMemoryJS(user, dir); err != nil || string(code) != "shared()" {
+		t.Errorf("ReadMemoryJS = %q, %v; want shared copy", code, err)
+	}
+	os.WriteFile(user, []byte("mine()"), 0644)
+	if code, _ := ReadMemoryJS(user, dir); string(code) != "mine()" {
+		t.Errorf("ReadMemoryJS = %q, want the user's copy", code)
+	}
+
+	// No shared copy: the user's not-exist error comes back
+	os.Remove(user)
+	if _, err := ReadMemoryJS(user, ""); !os.IsNotExist(err) {
+		t.Errorf("ReadMemoryJS without shared err = %v, want not-exist", err)
+	}
+}
+
+func TestCopyUp(t *testing.T) {
+	_, dir := sharedThought(t)
+	user := filepath.Join(t.TempDir(), "workspace")
+
+	copied, err := CopyUp(user, filepath.Join(dir, "workspace"))
+	if err != nil || !copied {
+		t.Fatalf("CopyUp = %v, %v", copied, err)
+	}
+	target := filepath.Join(user, "lib", "parse.js")
+	if data, _ := os.ReadFile(target); string(data) != "parse()" {
+		t.Errorf("copied file = %q", data)
+	}
+	// Copies are the user's to change
+	if err := os.WriteFile(target, []byte("changed()"), 0644); err != nil {
+		t.Errorf("copied file is not writable: %v", err)
+	}
+
+	// A non-empty user directory is left alone
+	if copied, _ := CopyUp(user, filepath.Join(dir, "workspace")); copied {
+		t.Error("CopyUp overwrote a non-empty directory")
+	}
+	if data, _ := os.ReadFile(target); string(data) != "changed()" {
+		t.Errorf("user file = %q, want it untouched", data)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	_, dir := sharedThought(t)
+	dataDir := filepath.Join(t.TempDir(), "weather")
+
+	if p, err := Policy(dir, dataDir); p != nil || err != nil {
+		t.Errorf("Policy without policy.json = %v, %v; want nil, nil", p, err)
+	}
+
+	p := approval.NewPolicy()
+	p.AddPathEntry(filepath.Join(dir, "workspace"), "rwd", approval.ApprovalAllow, approval.SourceDefault)
+	p.AddPathEntry(filepath.Join(dir, "workspace", "data"), "r", approval.ApprovalAllow, approval.SourceConfig)
+	p.AddPathEntry("/srv/reports", "r", approval.ApprovalAllow, approval.SourceConfig)
+	p.AddHostEntry("api.weather.gov", approval.ApprovalAllow, approval.SourceConfig)
+	p.Env.Default = approval.ApprovalDeny
+	p.Save(filepath.Join(dir, "policy.json"))
+
+	seed, err := Policy(dir, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, e := range seed.Paths.Entries {
+		paths = append(paths, e.Path)
+	}
+	want := []string{filepath.Join(dataDir, "workspace", "data"), "/srv/reports"}
+	if len(paths) != len(want) || paths[0] != want[0] || paths[1] != want[1] {
+		t.Errorf("seed paths = %v, want %v", paths, want)
+	}
+	if len(seed.Net.Hosts.Entries) != 1 || seed.Env.Default != approval.ApprovalDeny {
+		t.Errorf("seed lost host entries or defaults: %+v", seed)
+	}
+}
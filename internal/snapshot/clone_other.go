@@ -0,0 +1,12 @@
+//go:build !linux
+
+package snapshot
+
+import (
+	"errors"
+	"os"
+)
+
+func clone(dst, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,272 @@
+// Package snapshot keeps point-in-time copies of a thought's workspace so a
+// destructive agent run can be rolled back with `thought restore`. Files
+// are reflinked where the filesystem supports it and copied otherwise.
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// MaxSize is the largest workspace that is snapshotted. Bigger workspaces
+// are skipped with ErrTooLarge rather than doubling their disk usage.
+const MaxSize = 256 << 20
+
+// ErrNotFound is returned when no snapshot has the given ID.
+var ErrNotFound = errors.New("snapshot not found")
+
+// ErrTooLarge is returned by Take when the workspace exceeds MaxSize.
+var ErrTooLarge = errors.New("workspace too large to snapshot")
+
+// Snapshot describes one saved copy of the workspace. Each snapshot lives in
+// its own directory: meta.json plus the copied tree as "data".
+type Snapshot struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	Files   int       `json:"files"`
+}
+
+// Dir returns the snapshot directory for a thought.
+func Dir(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "snapshots")
+}
+
+// Take copies workspaceDir into a new snapshot. It returns nil and no error
+// when the workspace is missing or empty, since there is nothing to undo.
+func Take(thoughtDir, workspaceDir string) (*Snapshot, error) {
+	size, files, err := measure(workspaceDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if files == 0 {
+		return nil, nil
+	}
+	if size > MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	snapDir := Dir(thoughtDir)
+	if err := os.MkdirAll(snapDir, 0700); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var id, dir string
+	for n := 0; ; n++ {
+		id = now.Format("20060102-150405")
+		if n > 0 {
+			id += fmt.Sprintf("-%d", n)
+		}
+		dir = filepath.Join(snapDir, id)
+		err := os.Mkdir(dir, 0700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+
+	s := &Snapshot{ID: id, Created: now, Size: size, Files: files}
+	if err := copyTree(workspaceDir, filepath.Join(dir, "data")); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	// meta.json is written last so an interrupted copy is never listed.
+	if err := writeMeta(dir, s); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns a thought's snapshots, oldest first.
+func List(thoughtDir string) ([]Snapshot, error) {
+	dirs, err := os.ReadDir(Dir(thoughtDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snaps []Snapshot
+	for _, d := range dirs {
+		s, err := readMeta(filepath.Join(Dir(thoughtDir), d.Name()))
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, *s)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Created.Before(snaps[j].Created) })
+	return snaps, nil
+}
+
+// Restore replaces the contents of workspaceDir with snapshot id. The
+// current workspace is snapshotted first so the restore can itself be
+// undone; that snapshot is returned (nil if the workspace was empty).
+func Restore(thoughtDir, workspaceDir, id string) (*Snapshot, error) {
+	dir := filepath.Join(Dir(thoughtDir), filepath.Base(id))
+	if _, err := readMeta(dir); err != nil {
+		return nil, ErrNotFound
+	}
+
+	backup, err := Take(thoughtDir, workspaceDir)
+	if err != nil {
+		return nil, fmt.Errorf("snapshotting current workspace: %w", err)
+	}
+
+	// Empty the workspace in place rather than replacing the directory, so
+	// anything holding its path (policy entries, open shells) stays valid.
+	if err := os.MkdirAll(workspaceDir, 0700); err != nil {
+		return backup, err
+	}
+	entries, err := os.ReadDir(workspaceDir)
+	if err != nil {
+		return backup, err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(workspaceDir, e.Name())); err != nil {
+			return backup, err
+		}
+	}
+	return backup, copyTree(filepath.Join(dir, "data"), workspaceDir)
+}
+
+// GC removes the oldest snapshots beyond keep and returns how many it
+// removed. Directories left by an interrupted Take are removed too, once
+// they are old enough that no Take can still be writing them.
+func GC(thoughtDir string, keep int) (int, error) {
+	dirs, err := os.ReadDir(Dir(thoughtDir))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, d := range dirs {
+		dir := filepath.Join(Dir(thoughtDir), d.Name())
+		if _, err := readMeta(dir); err == nil {
+			continue
+		}
+		if info, err := d.Info(); err == nil && time.Since(info.ModTime()) > time.Hour && os.RemoveAll(dir) == nil {
+			n++
+		}
+	}
+
+	snaps, err := List(thoughtDir)
+	if err != nil {
+		return n, err
+	}
+	for i := 0; i < len(snaps)-max(keep, 0); i++ {
+		if err := os.RemoveAll(filepath.Join(Dir(thoughtDir), snaps[i].ID)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func writeMeta(dir string, s *Snapshot) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "meta.json"), data, 0600)
+}
+
+func readMeta(dir string) (*Snapshot, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// measure returns the total size and number of entries below dir.
+func measure(dir string) (size int64, files int, err error) {
+	if _, err := os.Stat(dir); err != nil {
+		return 0, 0, err
+	}
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		files++
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, files, err
+}
+
+// copyTree copies src into dst, preserving permissions and symlinks.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil // sockets, fifos, devices
+	})
+}
+
+// copyFile clones src to dst when the filesystem supports it, so a snapshot
+// costs no extra space until either side changes, and copies it otherwise.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if clone(out, in) == nil {
+		return out.Close()
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,159 @@
+package snapshot
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setup(t *testing.T) (thoughtDir, workspace string) {
+	t.Helper()
+	thoughtDir = t.TempDir()
+	workspace = filepath.Join(thoughtDir, "workspace")
+	os.MkdirAll(filepath.Join(workspace, "sub"), 0700)
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("alpha"), 0644)
+	os.WriteFile(filepath.Join(workspace, "sub", "b.sh"), []byte("#!/bin/sh\n"), 0755)
+	os.Symlink("a.txt", filepath.Join(workspace, "link"))
+	return thoughtDir, workspace
+}
+
+func TestTakeAndRestore(t *testing.T) {
+	thoughtDir, workspace := setup(t)
+
+	s, err := Take(thoughtDir, workspace)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if s == nil {
+		t.Fatal("Take returned no snapshot")
+	}
+	if s.Files != 4 {
+		t.Errorf("Files = %d, want 4", s.Files)
+	}
+	if s.Size != int64(len("alpha")+len("#!/bin/sh\n")) {
+		t.Errorf("Size = %d", s.Size)
+	}
+
+	// A destructive run
+	os.RemoveAll(filepath.Join(workspace, "sub"))
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("clobbered"), 0644)
+	os.WriteFile(filepath.Join(workspace, "new.txt"), []byte("new"), 0644)
+
+	backup, err := Restore(thoughtDir, workspace, s.ID)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if backup == nil {
+		t.Fatal("Restore did not snapshot the current workspace")
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(workspace, "a.txt")); string(data) != "alpha" {
+		t.Errorf("a.txt = %q, want %q", data, "alpha")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "new.txt")); !os.IsNotExist(err) {
+		t.Error("new.txt should be gone after restore")
+	}
+	info, err := os.Stat(filepath.Join(workspace, "sub", "b.sh"))
+	if err != nil {
+		t.Fatalf("sub/b.sh not restored: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("b.sh perm = %v, want 0755", info.Mode().Perm())
+	}
+	if link, err := os.Readlink(filepath.Join(workspace, "link")); err != nil || link != "a.txt" {
+		t.Errorf("link = %q, %v; want a.txt", link, err)
+	}
+
+	// The restore itself can be reverted
+	if _, err := Restore(thoughtDir, workspace, backup.ID); err != nil {
+		t.Fatalf("Restore backup: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "new.txt")); string(data) != "new" {
+		t.Errorf("new.txt = %q after restoring backup", data)
+	}
+}
+
+func TestSnapshotIsIndependent(t *testing.T) {
+	thoughtDir, workspace := setup(t)
+	s, err := Take(thoughtDir, workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Overwriting in place must not change the snapshot
+	os.WriteFile(filepath.Join(workspace, "a.txt"), []byte("changed"), 0644)
+	data, _ := os.ReadFile(filepath.Join(Dir(thoughtDir), s.ID, "data", "a.txt"))
+	if string(data) != "alpha" {
+		t.Errorf("snapshot a.txt = %q, want %q", data, "alpha")
+	}
+}
+
+func TestTakeEmpty(t *testing.T) {
+	thoughtDir := t.TempDir()
+	workspace := filepath.Join(thoughtDir, "workspace")
+
+	for _, name := range []string{"missing", "empty"} {
+		t.Run(name, func(t *testing.T) {
+			if name == "empty" {
+				os.MkdirAll(workspace, 0700)
+			}
+			s, err := Take(thoughtDir, workspace)
+			if err != nil || s != nil {
+				t.Errorf("Take = %v, %v; want nil, nil", s, err)
+			}
+		})
+	}
+}
+
+func TestRestoreNotFound(t *testing.T) {
+	thoughtDir, workspace := setup(t)
+	if _, err := Restore(thoughtDir, workspace, "nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	// The workspace is untouched
+	if _, err := os.Stat(filepath.Join(workspace, "a.txt")); err != nil {
+		t.Error("workspace was modified")
+	}
+}
+
+func TestListAndGC(t *testing.T) {
+	thoughtDir, workspace := setup(t)
+	for i := 0; i < 4; i++ {
+		if _, err := Take(thoughtDir, workspace); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Partial snapshots with no meta.json: a stale one and one still being written
+	partial := filepath.Join(Dir(thoughtDir), "partial")
+	os.MkdirAll(filepath.Join(partial, "data"), 0700)
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(partial, old, old)
+	os.MkdirAll(filepath.Join(Dir(thoughtDir), "in-progress"), 0700)
+
+	snaps, err := List(thoughtDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 4 {
+		t.Fatalf("List = %d snapshots, want 4", len(snaps))
+	}
+
+	removed, err := GC(thoughtDir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 3 {
+		t.Errorf("GC removed %d, want 3", removed)
+	}
+	left, _ := List(thoughtDir)
+	if len(left) != 2 || left[0].ID != snaps[2].ID || left[1].ID != snaps[3].ID {
+		t.Errorf("GC kept %v, want the newest two", left)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Error("stale partial snapshot was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(Dir(thoughtDir), "in-progress")); err != nil {
+		t.Error("in-progress snapshot was removed")
+	}
+}
@@ -0,0 +1,202 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/mcp"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// MCPPrefix starts the names of MCP tools: mcp__<server>__<tool>. A
+// server's own approval entry is mcp__<server>.
+const MCPPrefix = "mcp__"
+
+// maxToolName is the longest tool name providers accept.
+const maxToolName = 64
+
+var unsafeToolChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// MCPServers are the MCP servers a thought declares, started once per run
+// and shared by every registry given them with SetMCP.
+type MCPServers struct {
+	servers []*mcpServer
+}
+
+type mcpServer struct {
+	name   string
+	client *mcp.Client
+	tools  []mcp.Tool
+}
+
+// MCPServerInfo describes a connected server for the system prompt.
+type MCPServerInfo struct {
+	Name         string
+	Instructions string   // the server's own, from its handshake
+	Tools        []string // as the agent sees them
+}
+
+// ConnectMCP starts the thought's MCP servers in dir and lists their tools.
+// Starting a server needs approval as mcp__<name>, shown with its command
+// line. A server that is denied or fails to start is skipped with a
+// warning, so the agent runs without its tools; only an interrupted prompt
+// is an error.
+func ConnectMCP(ctx context.Context, approver *approval.Approver, servers []config.MCPServer, dir string) (*MCPServers, error) {
+	m := &MCPServers{}
+	seen := map[string]bool{}
+	for _, s := range servers {
+		name := s.Name
+		if name == "" {
+			name = filepath.Base(s.Command)
+		}
+		name = strings.Trim(unsafeToolChars.ReplaceAllString(name, "_"), "_")
+		switch {
+		case s.Command == "":
+			fmt.Fprintf(os.Stderr, "warning: mcp server %q has no command, skipping it\n", s.Name)
+			continue
+		case name == "":
+			fmt.Fprintf(os.Stderr, "warning: mcp server %s needs a name:, skipping it\n", s.Command)
+			continue
+		case seen[name]:
+			fmt.Fprintf(os.Stderr, "warning: two mcp servers are named %s; give each its own name:, skipping %s\n", name, s.Command)
+			continue
+		}
+		seen[name] = true
+
+		commandLine := strings.Join(append([]string{s.Command}, s.Args...), " ")
+		approver.SetActivity("start MCP server: "+commandLine, "")
+		ok, err := approver.ApproveTool(MCPPrefix + name)
+		approver.SetActivity("", "")
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "warning: mcp server %s not approved, running without its tools\n", name)
+			continue
+		}
+
+		env := os.Environ()
+		for k, v := range s.Env {
+			env = append(env, k+"="+v)
+		}
+		client, err := mcp.Start(ctx, s.Command, s.Args, env, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: mcp server %s failed to start, running without its tools: %v\n", name, err)
+			continue
+		}
+		tools, err := client.ListTools(ctx)
+		if err != nil {
+			client.Close()
+			fmt.Fprintf(os.Stderr, "warning: mcp server %s, running without its tools: %v\n", name, err)
+			continue
+		}
+		m.servers = append(m.servers, &mcpServer{name: name, client: client, tools: tools})
+	}
+	return m, nil
+}
+
+// Close stops the servers.
+func (m *MCPServers) Close() {
+	if m == nil {
+		return
+	}
+	for _, s := range m.servers {
+		s.client.Close()
+	}
+}
+
+// SetMCP adds the tools of m's servers. Each call needs approval as
+// mcp__<server>__<tool>, shown with its arguments; tools the server marks
+// read-only may be repeated within a turn.
+func (r *Registry) SetMCP(m *MCPServers) {
+	if m == nil {
+		return
+	}
+	for _, s := range m.servers {
+		var names []string
+		for _, tool := range s.tools {
+			name := mcpToolName(s.name, tool.Name)
+			if _, taken := r.regs[name]; taken {
+				fmt.Fprintf(os.Stderr, "warning: mcp server %s has two tools named %s, skipping one\n", s.name, name)
+				continue
+			}
+			names = append(names, name)
+			r.registerMCP(s, tool, name)
+		}
+		r.mcp = append(r.mcp, MCPServerInfo{Name: s.name, Instructions: s.client.Instructions, Tools: names})
+	}
+}
+
+// MCPServers describes the servers whose tools were added with SetMCP.
+func (r *Registry) MCPServers() []MCPServerInfo {
+	return r.mcp
+}
+
+func (r *Registry) registerMCP(s *mcpServer, tool mcp.Tool, name string) {
+	var schema provider.ToolInputSchema
+	json.Unmarshal(tool.InputSchema, &schema)
+	if schema.Type == "" {
+		schema.Type = "object"
+	}
+	if schema.Properties == nil {
+		schema.Properties = map[string]any{}
+	}
+	desc := tool.Description
+	if desc == "" {
+		desc = tool.Title
+	}
+
+	idempotency := SideEffects
+	if tool.Annotations != nil && tool.Annotations.ReadOnlyHint {
+		idempotency = Idempotent
+	}
+
+	approve := func(input json.RawMessage) (bool, error) {
+		return withActivity(r, r.approver, fmt.Sprintf("call %s with %s", name, input), "", func() (bool, error) {
+			return r.approver.ApproveTool(name)
+		})
+	}
+	r.register(provider.ToolDefinition{
+		Name:        name,
+		Description: fmt.Sprintf("[MCP server %s] %s", s.name, desc),
+		InputSchema: schema,
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		res, err := s.client.CallTool(ctx, tool.Name, input)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", name, err)
+		}
+		text := res.Text()
+		if res.IsError {
+			if text == "" {
+				text = "the tool reported an error"
+			}
+			return "", errors.New(text)
+		}
+		if text == "" {
+			text = "(no output)"
+		}
+		return text, nil
+	}, approve, idempotency, Sequential)
+}
+
+// mcpToolName returns the name the agent sees for a server's tool, made
+// safe for providers and cut to their length limit.
+func mcpToolName(server, tool string) string {
+	name := MCPPrefix + server + "__" + unsafeToolChars.ReplaceAllString(tool, "_")
+	if len(name) > maxToolName {
+		name = name[:maxToolName]
+	}
+	return name
+}
@@ -0,0 +1,304 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+type updateMemoryInput struct {
+	Diff   string `json:"diff"`
+	Reason string `json:"reason"`
+}
+
+// registerMemory adds update_memory, which changes memory.js by applying a
+// unified diff to the file as it is now. Only the hunks change, so edits a
+// person made elsewhere in the file survive, and each change is small
+// enough to review in the transcript. A diff whose context or removed
+// lines don't match the file, or whose result doesn't compile, is
+// rejected and memory.js is left alone. Read-only runs don't get it.
+func (r *Registry) registerMemory(cfg RegistryConfig) {
+	r.register(provider.ToolDefinition{
+		Name:        "update_memory",
+		Description: "Change memory.js by applying a unified diff to it. Prefer this to rewriting the whole file with fs.writeFile: make the smallest change that does the job, so it can be reviewed and edits the user made elsewhere in the file are kept. Hunks need @@ headers and the exact context and removed lines from the current file (read it first); the diff is rejected if they don't match or the result doesn't compile. For a new memory.js, use a single hunk @@ -0,0 +1,N @@ of added lines.",
+		InputSchema: provider.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"diff": map[string]any{
+					"type":        "string",
+					"description": "Unified diff against the current memory.js. ---/+++ file headers are optional.",
+				},
+				"reason": map[string]any{
+					"type":        "string",
+					"description": "Short description of the change (e.g. \"handle cities with spaces\"). Shown to the user.",
+				},
+			},
+			Required: []string{"diff"},
+		},
+	}, func(_ context.Context, input json.RawMessage) (string, error) {
+		var args updateMemoryInput
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("parsing update_memory input: %w", err)
+		}
+		old, err := os.ReadFile(cfg.MemoryJSPath)
+		if err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("reading memory.js: %w", err)
+		}
+		code, stats, err := applyDiff(string(old), args.Diff)
+		if err != nil {
+			return "", fmt.Errorf("memory.js was not updated: %w", err)
+		}
+		notes, err := r.CheckMemoryJS(code)
+		if err != nil {
+			return "", fmt.Errorf("memory.js was not updated: the patched file %w", err)
+		}
+
+		r.journal.Write(cfg.MemoryJSPath)
+		if err := os.WriteFile(cfg.MemoryJSPath, []byte(code), 0644); err != nil {
+			return "", fmt.Errorf("writing memory.js: %w", err)
+		}
+		r.recordWrite(cfg.MemoryJSPath)
+
+		_, stderr := r.writers()
+		what := args.Reason
+		if what == "" {
+			what = "updated memory.js"
+		}
+		fmt.Fprintf(stderr, "\n  %s %s\n", memoryDotStyle.Render("▸"), memoryDetailStyle.Render(fmt.Sprintf("%s (+%d -%d)", what, stats.added, stats.removed)))
+
+		result := fmt.Sprintf("memory.js updated: %d hunk(s), +%d -%d lines", stats.hunks, stats.added, stats.removed)
+		for _, note := range notes {
+			result += "\nnote: " + note
+		}
+		return result, nil
+	}, nil, SideEffects, Sequential)
+}
+
+// CheckMemoryJS is what code must pass to be saved as memory.js: it must
+// compile. The linter's findings are returned as notes, not errors, since
+// memory.js is reviewed again before it runs.
+func (r *Registry) CheckMemoryJS(code string) (notes []string, err error) {
+	if err := sandbox.Compile(code); err != nil {
+		return nil, fmt.Errorf("doesn't compile: %w", err)
+	}
+	if r.linter != nil {
+		for _, f := range r.linter.Check(code) {
+			notes = append(notes, "lint "+f.String())
+		}
+	}
+	return notes, nil
+}
+
+var (
+	memoryDotStyle    = ui.Renderer.NewStyle().Foreground(lipgloss.Color("39"))
+	memoryDetailStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
+)
+
+// hunkHeaderRE matches a hunk header, e.g. "@@ -12,5 +12,7 @@ function main".
+var hunkHeaderRE = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// hunk is one hunk of a unified diff: where it starts in the old file
+// (1-based; 0 for an empty file) and its lines, each still prefixed with
+// ' ', '-', or '+'.
+type hunk struct {
+	oldStart int
+	lines    []string
+}
+
+// old returns the lines the hunk expects in the file, new the lines that
+// replace them.
+func (h hunk) old() (lines []string) {
+	for _, l := range h.lines {
+		if l[0] != '+' {
+			lines = append(lines, l[1:])
+		}
+	}
+	return lines
+}
+
+func (h hunk) new() (lines []string) {
+	for _, l := range h.lines {
+		if l[0] != '-' {
+			lines = append(lines, l[1:])
+		}
+	}
+	return lines
+}
+
+type diffStats struct {
+	hunks, added, removed int
+}
+
+// parseDiff reads the hunks of a unified diff for one file. Line counts in
+// the headers are ignored, since the hunk's lines say the same thing, and
+// an empty line in a hunk is taken as an empty context line.
+func parseDiff(diff string) ([]hunk, error) {
+	var hunks []hunk
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case hunkHeaderRE.MatchString(line):
+			start, _ := strconv.Atoi(hunkHeaderRE.FindStringSubmatch(line)[1])
+			hunks = append(hunks, hunk{oldStart: start})
+		case len(hunks) == 0:
+			// File headers (---, +++, diff, index) before the first hunk
+			if strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index ") || strings.TrimSpace(line) == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: expected a hunk header like \"@@ -1,3 +1,4 @@\", got %q", i+1, line)
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case line == "":
+			if i < len(lines)-1 {
+				h := &hunks[len(hunks)-1]
+				h.lines = append(h.lines, " ")
+			}
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			h := &hunks[len(hunks)-1]
+			h.lines = append(h.lines, line)
+		default:
+			return nil, fmt.Errorf("line %d: hunk lines must start with ' ', '-', or '+', got %q", i+1, line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, errors.New("the diff has no hunks")
+	}
+	return hunks, nil
+}
+
+// maxHunkOffset is how far from its header's line applyDiff looks for a
+// hunk whose lines moved.
+const maxHunkOffset = 50
+
+// applyDiff applies a unified diff to text. Each hunk's context and removed
+// lines must match the file exactly; when they aren't at the line the
+// header gives (the file changed since the diff was made), they must match
+// exactly once after the previous hunk and within maxHunkOffset lines of
+// it. A hunk that matches nowhere, or in more than one place, rejects the
+// whole diff, as does a @@ -0,0 hunk (a new file) when text isn't empty.
+func applyDiff(text, diff string) (string, diffStats, error) {
+	var stats diffStats
+	hunks, err := parseDiff(diff)
+	if err != nil {
+		return "", stats, err
+	}
+
+	lines := strings.Split(text, "\n")
+	trailingNewline := text == "" || strings.HasSuffix(text, "\n")
+	if trailingNewline {
+		lines = lines[:len(lines)-1]
+	}
+
+	var out []string
+	pos := 0 // next line of lines not yet copied to out
+	for n, h := range hunks {
+		added, removed := 0, 0
+		for _, l := range h.lines {
+			switch l[0] {
+			case '+':
+				added++
+			case '-':
+				removed++
+			}
+		}
+		if added+removed == 0 {
+			continue // context only
+		}
+		old, repl := h.old(), h.new()
+		if h.oldStart == 0 && len(lines) > 0 {
+			return "", stats, fmt.Errorf("hunk %d (@@ -0,0) creates memory.js, but it already has %d lines. Read memory.js and send a diff against its current contents", n+1, len(lines))
+		}
+		want := max(h.oldStart-1, pos)
+		if len(old) == 0 && h.oldStart > 0 {
+			want = max(h.oldStart, pos) // pure insertion after line oldStart
+		}
+		at, err := findLines(lines, old, pos, want)
+		if err != nil {
+			return "", stats, fmt.Errorf("hunk %d (@@ -%d) doesn't apply: %w", n+1, h.oldStart, err)
+		}
+		if at < 0 {
+			return "", stats, hunkMismatch(n+1, h, lines, want)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, repl...)
+		pos = at + len(old)
+		stats.hunks++
+		stats.added += added
+		stats.removed += removed
+	}
+	if stats.hunks == 0 {
+		return "", stats, errors.New("the diff changes nothing")
+	}
+	out = append(out, lines[pos:]...)
+
+	result := strings.Join(out, "\n")
+	if trailingNewline && len(out) > 0 {
+		result += "\n"
+	}
+	return result, stats, nil
+}
+
+// findLines returns where want is in lines: at near if it matches there,
+// otherwise its one match at or after from and within maxHunkOffset lines
+// of near, or -1 if there is none. Several matches are an error, since
+// picking one could change the wrong lines. An empty want (a pure
+// insertion) goes at near, which must be in the file.
+func findLines(lines, want []string, from, near int) (int, error) {
+	if len(want) == 0 {
+		if near > len(lines) {
+			return -1, fmt.Errorf("line %d is past the end of memory.js (%d lines)", near, len(lines))
+		}
+		return near, nil
+	}
+	if near+len(want) <= len(lines) && matchAt(lines, want, near) {
+		return near, nil
+	}
+	var found []string
+	at := -1
+	for i := max(from, near-maxHunkOffset); i <= near+maxHunkOffset && i+len(want) <= len(lines); i++ {
+		if matchAt(lines, want, i) {
+			at = i
+			found = append(found, strconv.Itoa(i+1))
+		}
+	}
+	if len(found) > 1 {
+		return -1, fmt.Errorf("its lines match memory.js at lines %s. Add context lines so it matches in one place", strings.Join(found, ", "))
+	}
+	return at, nil
+}
+
+func matchAt(lines, want []string, at int) bool {
+	for j, w := range want {
+		if lines[at+j] != w {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkMismatch explains a hunk that doesn't apply: the first line it
+// expected and what the file has there, so the model can read memory.js
+// again and retry.
+func hunkMismatch(n int, h hunk, lines []string, at int) error {
+	old := h.old()
+	for j, w := range old {
+		got := "(end of file)"
+		if at+j < len(lines) {
+			got = lines[at+j]
+		}
+		if got != w {
+			return fmt.Errorf("hunk %d (@@ -%d) doesn't apply: at line %d it expects %q but memory.js has %q. Read memory.js again and send a diff against its current contents", n, h.oldStart, at+j+1, w, got)
+		}
+	}
+	return fmt.Errorf("hunk %d (@@ -%d) doesn't apply: its context and removed lines aren't in memory.js. Read memory.js again and send a diff against its current contents", n, h.oldStart)
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestApplyDiff(t *testing.T) {
+	file := "a\nb\nc\nd\ne\n"
+	// 120 lines, "x" at line 1 and "x" again at line 80
+	var long []string
+	for i := 1; i <= 120; i++ {
+		line := fmt.Sprintf("line %d", i)
+		if i == 1 || i == 80 {
+			line = "x"
+		}
+		long = append(long, line)
+	}
+	longFile := strings.Join(long, "\n") + "\n"
+
+	tests := []struct {
+		name string
+		text string
+		diff string
+		want string // result, or error substring prefixed with "!"
+	}{
+		{"replace", file, "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n", "a\nb\nC\nd\ne\n"},
+		{"file headers", file, "--- a/memory.js\n+++ b/memory.js\n@@ -1,2 +1,2 @@\n-a\n+A\n b\n", "A\nb\nc\nd\ne\n"},
+		{"two hunks", file, "@@ -1,1 +1,1 @@\n-a\n+A\n@@ -5,1 +5,1 @@\n-e\n+E\n", "A\nb\nc\nd\nE\n"},
+		{"pure insertion", file, "@@ -2,0 +3,1 @@\n+b2\n", "a\nb\nb2\nc\nd\ne\n"},
+		{"empty context line", "a\n\nc\n", "@@ -1,3 +1,3 @@\n a\n\n-c\n+C\n", "a\n\nC\n"},
+		{"no trailing newline", "a\nb", "@@ -2,1 +2,1 @@\n-b\n+B\n\\ No newline at end of file\n", "a\nB"},
+		{"moved a few lines", "new\n" + file, "@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n", "new\na\nb\nC\nd\ne\n"},
+		{"new file", "", "@@ -0,0 +1,2 @@\n+a\n+b\n", "a\nb\n"},
+		{"new file over existing", file, "@@ -0,0 +1,1 @@\n+z\n", "!already has 5 lines"},
+		{"mismatch", file, "@@ -2,2 +2,2 @@\n b\n-x\n+X\n", "!expects \"x\" but memory.js has \"c\""},
+		{"insertion past end", file, "@@ -9,0 +10,1 @@\n+z\n", "!past the end"},
+		{"header line wins", longFile, "@@ -80,1 +80,1 @@\n-x\n+y\n", strings.Replace(longFile, "\nx\n", "\ny\n", 1)},
+		{"match too far away", longFile, "@@ -20,1 +20,1 @@\n-line 100\n+y\n", "!doesn't apply"},
+		{"ambiguous", "a\nx\nb\nx\nc\n", "@@ -3,1 +3,1 @@\n-x\n+y\n", "!match memory.js at lines 2, 4"},
+		{"context only", file, "@@ -1,1 +1,1 @@\n a\n", "!changes nothing"},
+		{"no hunks", file, "--- a/memory.js\n+++ b/memory.js\n", "!no hunks"},
+		{"bad line", file, "@@ -1,1 +1,1 @@\n*a\n", "!must start with"},
+	}
+	for _, tt := range tests {
+		got, _, err := applyDiff(tt.text, tt.diff)
+		if want, isErr := strings.CutPrefix(tt.want, "!"); isErr {
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, want)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: applyDiff = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
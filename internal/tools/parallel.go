@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/i18n"
+	"github.com/thinkingscript/cli/internal/ui"
+)
+
+// Concurrency declares whether a tool's calls may run alongside others.
+type Concurrency int
+
+const (
+	// Sequential tools run alone, in the order the model asked for them,
+	// once every earlier call has finished (write_stdout keeps its output
+	// in order).
+	Sequential Concurrency = iota
+	// Parallel tools are independent of each other (run_script gets its
+	// own sandbox per call), so consecutive calls in a turn run at once.
+	Parallel
+)
+
+// DefaultParallelism is how many Parallel calls run at once unless
+// SetParallelism says otherwise.
+const DefaultParallelism = 4
+
+// Call is a tool_use block to execute.
+type Call struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+// Result is the outcome of a Call.
+type Result struct {
+	Output string
+	Err    error
+}
+
+// SetParallelism sets how many Parallel calls run at once; 1 runs every
+// call in order, 0 means DefaultParallelism.
+func (r *Registry) SetParallelism(n int) {
+	r.parallelism = n
+}
+
+// ExecuteAll runs a turn's calls and returns their results in the same
+// order. Consecutive Parallel calls run concurrently, up to the registry's
+// parallelism at a time, each with its console output held back and
+// printed in call order once the group is done; other calls run alone, as
+// Execute runs them. A call identical to one already in the group starts
+// the next group, so it's replayed as a duplicate rather than run twice.
+// Once a call is interrupted (Ctrl+C or a cancelled ctx) the remaining
+// calls aren't run and get the same error.
+func (r *Registry) ExecuteAll(ctx context.Context, calls []Call) []Result {
+	results := make([]Result, len(calls))
+	for i := 0; i < len(calls); {
+		n := r.groupSize(calls[i:])
+		if n == 1 {
+			results[i].Output, results[i].Err = r.Execute(ctx, calls[i].ID, calls[i].Name, calls[i].Input)
+		} else {
+			r.executeGroup(ctx, calls[i:i+n], results[i:i+n])
+		}
+		for _, res := range results[i : i+n] {
+			if interrupted(ctx, res.Err) {
+				for j := i + n; j < len(calls); j++ {
+					results[j].Err = res.Err
+				}
+				return results
+			}
+		}
+		i += n
+	}
+	return results
+}
+
+// groupSize returns how many of calls, from the first, can run together.
+func (r *Registry) groupSize(calls []Call) int {
+	jobs := r.parallelism
+	if jobs == 0 {
+		jobs = DefaultParallelism
+	}
+	if jobs < 2 {
+		return 1
+	}
+	ids := map[string]bool{}
+	keys := map[string]bool{}
+	n := 0
+	for _, c := range calls {
+		reg, ok := r.regs[c.Name]
+		if !ok || reg.concurrency != Parallel {
+			break
+		}
+		key := CallKey(c.Name, c.Input)
+		if (c.ID != "" && ids[c.ID]) || keys[key] {
+			break
+		}
+		ids[c.ID] = true
+		keys[key] = true
+		n++
+	}
+	return max(n, 1)
+}
+
+// callOutput holds back a call's console output while it runs alongside
+// others.
+type callOutput struct {
+	stdout, stderr bytes.Buffer
+}
+
+type outputKey struct{}
+
+// callerKey holds the registry running a call. A Subset's tools are its
+// parent's handlers, so they look up the registry that called them rather
+// than writing to the one they were registered on.
+type callerKey struct{}
+
+// output returns where a call should write what it shows: the buffers of
+// a call running in a group, or the output of the registry running it.
+func (r *Registry) output(ctx context.Context) (stdout, stderr io.Writer, grouped bool) {
+	if out, ok := ctx.Value(outputKey{}).(*callOutput); ok {
+		return &out.stdout, &out.stderr, true
+	}
+	if caller, ok := ctx.Value(callerKey{}).(*Registry); ok {
+		r = caller
+	}
+	stdout, stderr = r.writers()
+	return stdout, stderr, false
+}
+
+// writers returns the registry's output: SetOutput's, or the process's.
+func (r *Registry) writers() (stdout, stderr io.Writer) {
+	stdout, stderr = r.stdout, r.stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return stdout, stderr
+}
+
+func (r *Registry) executeGroup(ctx context.Context, calls []Call, results []Result) {
+	jobs := r.parallelism
+	if jobs == 0 {
+		jobs = DefaultParallelism
+	}
+	// Ctrl+C at one call's prompt stops the rest too
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]callOutput, len(calls))
+	stopSpinner := ui.Spinner(i18n.T("spinner.running_parallel", len(calls)))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, c := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c Call) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			callCtx := context.WithValue(ctx, outputKey{}, &outputs[i])
+			result, err := r.Execute(callCtx, c.ID, c.Name, c.Input)
+			results[i] = Result{result, err}
+			if errors.Is(err, approval.ErrInterrupted) {
+				cancel()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+	stopSpinner()
+
+	stdout, stderr := r.writers()
+	for i := range outputs {
+		stderr.Write(outputs[i].stderr.Bytes())
+		stdout.Write(outputs[i].stdout.Bytes())
+	}
+}
+
+// interrupted reports whether err means the user or ctx stopped the call,
+// rather than the call failing.
+func interrupted(ctx context.Context, err error) bool {
+	return err != nil && (ctx.Err() != nil || errors.Is(err, approval.ErrInterrupted))
+}
@@ -1,12 +1,25 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sync"
 
 	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/backend"
+	"github.com/thinkingscript/cli/internal/codecheck"
+	"github.com/thinkingscript/cli/internal/fetchguard"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/lint"
 	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/workspace"
 )
 
 // ApproveFunc inspects tool input and decides whether the action is allowed.
@@ -17,30 +30,124 @@ type ApproveFunc func(input json.RawMessage) (bool, error)
 // Handler executes a tool after approval has been granted.
 type Handler func(ctx context.Context, input json.RawMessage) (string, error)
 
+// Idempotency declares what happens when the same call is made twice.
+type Idempotency int
+
+const (
+	// SideEffects tools (writes, output, network) must not run twice for
+	// one request: a repeated tool_use ID, or an identical call within the
+	// same turn, replays the first result instead of executing again.
+	SideEffects Idempotency = iota
+	// Idempotent tools are safe to repeat; only repeated tool_use IDs are
+	// skipped.
+	Idempotent
+)
+
 type registration struct {
-	def     provider.ToolDefinition
-	approve ApproveFunc
-	handler Handler
+	def         provider.ToolDefinition
+	approve     ApproveFunc
+	handler     Handler
+	idempotency Idempotency
+	concurrency Concurrency
+}
+
+// callResult is a recorded tool outcome, replayed for duplicate calls.
+type callResult struct {
+	result string
+	err    error
 }
 
 type Registry struct {
 	regs  map[string]registration
 	order []string
+
+	// mu guards stats, seenIDs, turnSeen, and writes, which calls running
+	// in parallel share
+	mu       sync.Mutex
+	stats    Stats
+	seenIDs  map[string]callResult // tool_use ID → result, for the whole session
+	turnSeen map[string]callResult // name+input → result, reset by BeginTurn
+	writes   []string              // files written by tools this session, first-write order
+
+	approver    *approval.Approver
+	mcp         []MCPServerInfo   // servers whose tools SetMCP added
+	promptMu    sync.Mutex        // held while a run_script call prompts, so parallel calls take turns
+	parallelism int               // Parallel calls run at once; 0 = DefaultParallelism
+	journal     *journal.Journal  // passed to run_script sandboxes; nil = not journaled
+	wsRun       *workspace.Run    // per-run workspace for fs.promote; nil = persistent workspace
+	backend     backend.Backend   // where run_script sandboxes run; nil = in-process
+	check       *codecheck.Hook   // pre-execution check of run_script code; nil = none
+	linter      *lint.Linter      // local checks of run_script code; nil = none
+	fetchGuard  *fetchguard.Guard // marks run_script results with fetched content; nil = off
+	eval        string            // sandbox.Config.Eval for run_script
+	profile     *sandbox.Profile  // times run_script bridge calls; nil = off
+	redact      *redact.Set       // masks the run's secret values in tool results; nil = off
+	tools       []string          // RegistryConfig.Tools
+	stdout      io.Writer         // write_stdout and run_script output; nil = os.Stdout
+	stderr      io.Writer         // run_script console output; nil = os.Stderr
+}
+
+// Stats counts tool calls made through a Registry.
+type Stats struct {
+	Calls          int // every Execute call (ExecuteAll makes one per call), including rejected ones
+	SchemaFailures int // inputs rejected by InputSchema validation
+	Duplicates     int // calls skipped as duplicates (result replayed)
+}
+
+// RegistryConfig configures a Registry and the sandboxes its run_script
+// calls get. Optional parts (journal, backend, code check, MCP servers,
+// ...) are added with the Set methods.
+type RegistryConfig struct {
+	Approver     *approval.Approver
+	WorkDir      string
+	ThoughtDir   string
+	WorkspaceDir string
+	MemoriesDir  string
+	MemoryJSPath string
+	ScriptName   string   // the script's path, for code checks
+	ReadOnly     bool     // reject every run_script write
+	AllowPaths   []string // --allow grants: readable in run_script
+	WritePaths   []string // --write grants: readable and writable in run_script
+
+	// Tools lists the built-in tools to offer ("write_stdout",
+	// "run_script", "update_memory", "spawn_agent"); nil = all of them.
+	Tools []string
+
+	Stdout io.Writer // write_stdout and run_script output; nil = os.Stdout
+	Stderr io.Writer // run_script console output and progress; nil = os.Stderr
 }
 
-func NewRegistry(approver *approval.Approver, workDir, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, scriptName string) *Registry {
+func NewRegistry(cfg RegistryConfig) *Registry {
 	r := &Registry{
-		regs: make(map[string]registration),
+		regs:     make(map[string]registration),
+		seenIDs:  make(map[string]callResult),
+		turnSeen: make(map[string]callResult),
+		approver: cfg.Approver,
+		tools:    cfg.Tools,
+		stdout:   cfg.Stdout,
+		stderr:   cfg.Stderr,
 	}
 
-	r.registerStdio()
-	r.registerScript(approver, workDir, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, scriptName)
+	if r.offers("write_stdout") {
+		r.registerStdio()
+	}
+	if r.offers("run_script") {
+		r.registerScript(cfg)
+	}
+	if r.offers("update_memory") && cfg.MemoryJSPath != "" && !cfg.ReadOnly {
+		r.registerMemory(cfg)
+	}
 
 	return r
 }
 
-func (r *Registry) register(def provider.ToolDefinition, handler Handler, approve ApproveFunc) {
-	r.regs[def.Name] = registration{def: def, handler: handler, approve: approve}
+// offers reports whether the built-in tool name is enabled.
+func (r *Registry) offers(name string) bool {
+	return r.tools == nil || slices.Contains(r.tools, name)
+}
+
+func (r *Registry) register(def provider.ToolDefinition, handler Handler, approve ApproveFunc, idempotency Idempotency, concurrency Concurrency) {
+	r.regs[def.Name] = registration{def: def, handler: handler, approve: approve, idempotency: idempotency, concurrency: concurrency}
 	r.order = append(r.order, def.Name)
 }
 
@@ -52,14 +159,155 @@ func (r *Registry) Definitions() []provider.ToolDefinition {
 	return defs
 }
 
-// Execute runs a tool by name. If the tool has an ApproveFunc, it is called
-// first — this is the single security chokepoint for all tool execution.
-func (r *Registry) Execute(ctx context.Context, name string, input json.RawMessage) (string, error) {
+// Stats returns the registry's call counters.
+func (r *Registry) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// AddStats adds a child registry's counters (see Subset) to r's, so the
+// run's totals include a sub-agent's calls.
+func (r *Registry) AddStats(s Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats.Calls += s.Calls
+	r.stats.SchemaFailures += s.SchemaFailures
+	r.stats.Duplicates += s.Duplicates
+}
+
+// SetJournal makes run_script record its filesystem changes in j so the
+// run can be undone.
+func (r *Registry) SetJournal(j *journal.Journal) {
+	r.journal = j
+}
+
+// SetWorkspaceRun gives run_script sandboxes the per-run workspace so
+// fs.promote works.
+func (r *Registry) SetWorkspaceRun(run *workspace.Run) {
+	r.wsRun = run
+}
+
+// SetBackend makes run_script run its sandboxes on b.
+func (r *Registry) SetBackend(b backend.Backend) {
+	r.backend = b
+}
+
+// SetCodeCheck makes run_script submit its code to h before running it.
+func (r *Registry) SetCodeCheck(h *codecheck.Hook) {
+	r.check = h
+}
+
+// SetLinter makes run_script lint its code with l before running it.
+func (r *Registry) SetLinter(l *lint.Linter) {
+	r.linter = l
+}
+
+// SetFetchGuard makes run_script pass results that include fetched
+// content through g before the model sees them.
+func (r *Registry) SetFetchGuard(g *fetchguard.Guard) {
+	r.fetchGuard = g
+}
+
+// SetEval sets the frontmatter eval mode for run_script sandboxes.
+func (r *Registry) SetEval(mode string) {
+	r.eval = mode
+}
+
+// SetProfile makes run_script sandboxes time their bridge calls in p.
+func (r *Registry) SetProfile(p *sandbox.Profile) {
+	r.profile = p
+}
+
+// SetRedact masks the secret values in rs in every tool result and error,
+// and has run_script sandboxes add to it and mask console output and
+// memories with it.
+func (r *Registry) SetRedact(rs *redact.Set) {
+	r.redact = rs
+}
+
+// Writes returns the files tools created or modified this session.
+func (r *Registry) Writes() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.writes)
+}
+
+func (r *Registry) recordWrite(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if slices.Contains(r.writes, path) {
+		return
+	}
+	r.writes = append(r.writes, path)
+}
+
+// BeginTurn starts a new model turn. Identical side-effecting calls are only
+// deduplicated within a turn; repeating them in a later turn runs them again.
+func (r *Registry) BeginTurn() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.turnSeen = make(map[string]callResult)
+}
+
+// Execute runs a tool call. Duplicates are skipped first: a tool_use ID that
+// already ran (e.g. a provider retry), or for SideEffects tools an identical
+// call earlier in the same turn, returns the recorded result. Input is then
+// validated against the tool's InputSchema; a mismatch returns a
+// *ValidationError without running or prompting. If the tool has an
+// ApproveFunc, it is called next — this is the single security chokepoint
+// for all tool execution.
+func (r *Registry) Execute(ctx context.Context, id, name string, input json.RawMessage) (string, error) {
+	r.mu.Lock()
+	r.stats.Calls++
 	reg, ok := r.regs[name]
 	if !ok {
+		r.mu.Unlock()
 		return "", fmt.Errorf("unknown tool: %s", name)
 	}
 
+	if prev, ok := r.seenIDs[id]; ok && id != "" {
+		r.stats.Duplicates++
+		r.mu.Unlock()
+		return prev.result, prev.err
+	}
+	key := CallKey(name, input)
+	prev, dup := r.turnSeen[key]
+	dup = dup && reg.idempotency == SideEffects
+	if dup {
+		r.stats.Duplicates++
+	}
+	r.mu.Unlock()
+	if dup {
+		if prev.err != nil {
+			return "", fmt.Errorf("duplicate of an earlier identical %s call in this turn (not re-run); it failed: %w", name, prev.err)
+		}
+		return "Duplicate of an earlier identical call in this turn; not re-run. Result: " + prev.result, nil
+	}
+
+	result, err := r.execute(ctx, reg, input)
+	result, err = r.redact.String(result), r.redact.Error(err)
+	// Interrupted or cancelled calls didn't complete; don't record them.
+	if ctx.Err() == nil && !errors.Is(err, approval.ErrInterrupted) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if id != "" {
+			r.seenIDs[id] = callResult{result, err}
+		}
+		r.turnSeen[key] = callResult{result, err}
+	}
+	return result, err
+}
+
+func (r *Registry) execute(ctx context.Context, reg registration, input json.RawMessage) (string, error) {
+	name := reg.def.Name
+	if err := validateInput(name, reg.def.InputSchema, input); err != nil {
+		r.mu.Lock()
+		r.stats.SchemaFailures++
+		r.mu.Unlock()
+		return "", err
+	}
+
 	if reg.approve != nil {
 		approved, err := reg.approve(input)
 		if err != nil {
@@ -70,5 +318,15 @@ func (r *Registry) Execute(ctx context.Context, name string, input json.RawMessa
 		}
 	}
 
-	return reg.handler(ctx, input)
+	return reg.handler(context.WithValue(ctx, callerKey{}, r), input)
+}
+
+// CallKey identifies a call by tool name and compacted input, so
+// formatting differences don't defeat deduplication.
+func CallKey(name string, input json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, input); err != nil {
+		return name + "\x00" + string(input)
+	}
+	return name + "\x00" + buf.String()
 }
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// testRegistry returns a registry with no built-in tools and two of its
+// own: "write" (SideEffects) and "read" (Idempotent). Each returns the
+// number of times it has run; fail makes a call fail with its error
+// instead.
+func testRegistry(runs map[string]int, fail func(name string) error) *Registry {
+	r := NewRegistry(RegistryConfig{Tools: []string{}})
+	for _, tool := range []struct {
+		name        string
+		idempotency Idempotency
+	}{{"write", SideEffects}, {"read", Idempotent}} {
+		name := tool.name
+		r.register(provider.ToolDefinition{
+			Name:        name,
+			InputSchema: provider.ToolInputSchema{Type: "object"},
+		}, func(context.Context, json.RawMessage) (string, error) {
+			runs[name]++
+			if fail != nil {
+				if err := fail(name); err != nil {
+					return "", err
+				}
+			}
+			return fmt.Sprintf("%s run %d", name, runs[name]), nil
+		}, nil, tool.idempotency, Sequential)
+	}
+	return r
+}
+
+func TestExecuteDedup(t *testing.T) {
+	type call struct {
+		id, tool, input string // tool "" starts a new turn
+	}
+	tests := []struct {
+		name  string
+		calls []call
+		want  []string // each call's result; "" for a new turn
+		runs  int      // handler runs
+		dups  int      // Stats().Duplicates
+	}{
+		{
+			"same ID replays",
+			[]call{{"1", "read", `{}`}, {"1", "read", `{}`}},
+			[]string{"read run 1", "read run 1"}, 1, 1,
+		},
+		{
+			"same ID replays in a later turn",
+			[]call{{"1", "write", `{}`}, {}, {"1", "write", `{}`}},
+			[]string{"write run 1", "", "write run 1"}, 1, 1,
+		},
+		{
+			"same side effect in a turn is skipped",
+			[]call{{"1", "write", `{"a":1}`}, {"2", "write", `{ "a": 1 }`}},
+			[]string{"write run 1", "Duplicate of an earlier identical call in this turn; not re-run. Result: write run 1"}, 1, 1,
+		},
+		{
+			"same idempotent call runs again",
+			[]call{{"1", "read", `{"a":1}`}, {"2", "read", `{"a":1}`}},
+			[]string{"read run 1", "read run 2"}, 2, 0,
+		},
+		{
+			"same side effect in a later turn runs again",
+			[]call{{"1", "write", `{}`}, {}, {"2", "write", `{}`}},
+			[]string{"write run 1", "", "write run 2"}, 2, 0,
+		},
+		{
+			"different input runs again",
+			[]call{{"1", "write", `{"a":1}`}, {"2", "write", `{"a":2}`}},
+			[]string{"write run 1", "write run 2"}, 2, 0,
+		},
+		{
+			"calls without IDs aren't matched by ID",
+			[]call{{"", "read", `{}`}, {"", "read", `{}`}},
+			[]string{"read run 1", "read run 2"}, 2, 0,
+		},
+	}
+	for _, tt := range tests {
+		runs := map[string]int{}
+		r := testRegistry(runs, nil)
+		var got []string
+		for _, c := range tt.calls {
+			if c.tool == "" {
+				r.BeginTurn()
+				got = append(got, "")
+				continue
+			}
+			result, err := r.Execute(context.Background(), c.id, c.tool, json.RawMessage(c.input))
+			if err != nil {
+				t.Fatalf("%s: Execute(%s, %s) = %v", tt.name, c.id, c.tool, err)
+			}
+			got = append(got, result)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: results =\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+		if n := runs["read"] + runs["write"]; n != tt.runs {
+			t.Errorf("%s: %d runs, want %d", tt.name, n, tt.runs)
+		}
+		if dups := r.Stats().Duplicates; dups != tt.dups {
+			t.Errorf("%s: Duplicates = %d, want %d", tt.name, dups, tt.dups)
+		}
+	}
+}
+
+func TestExecuteDedupFailure(t *testing.T) {
+	runs := map[string]int{}
+	r := testRegistry(runs, func(string) error { return errors.New("disk full") })
+	if _, err := r.Execute(context.Background(), "1", "write", json.RawMessage(`{}`)); err == nil {
+		t.Fatal("first call succeeded")
+	}
+	_, err := r.Execute(context.Background(), "2", "write", json.RawMessage(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "duplicate of an earlier identical write call") || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("duplicate of a failed call: err = %v", err)
+	}
+	if runs["write"] != 1 {
+		t.Errorf("write ran %d times, want 1", runs["write"])
+	}
+}
+
+func TestExecuteInterruptedNotRecorded(t *testing.T) {
+	for _, tool := range []string{"write", "read"} {
+		runs := map[string]int{}
+		interrupt := true
+		r := testRegistry(runs, func(string) error {
+			if interrupt {
+				return fmt.Errorf("approving: %w", approval.ErrInterrupted)
+			}
+			return nil
+		})
+		if _, err := r.Execute(context.Background(), "1", tool, json.RawMessage(`{"a":1}`)); !errors.Is(err, approval.ErrInterrupted) {
+			t.Fatalf("%s: err = %v, want ErrInterrupted", tool, err)
+		}
+
+		// A cancelled call isn't recorded either
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		interrupt = false
+		if _, err := r.Execute(ctx, "2", tool, json.RawMessage(`{"a":2}`)); err != nil {
+			t.Fatalf("%s: cancelled call: %v", tool, err)
+		}
+
+		// Retrying either, with the same ID and input in the same turn,
+		// runs it again
+		for i, id := range []string{"1", "2"} {
+			input := fmt.Sprintf(`{"a":%d}`, i+1)
+			want := fmt.Sprintf("%s run %d", tool, i+3)
+			if got, err := r.Execute(context.Background(), id, tool, json.RawMessage(input)); got != want || err != nil {
+				t.Errorf("%s: retry of %s = %q, %v; want %q", tool, id, got, err, want)
+			}
+		}
+		if dups := r.Stats().Duplicates; dups != 0 {
+			t.Errorf("%s: Duplicates = %d, want 0", tool, dups)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// ValidationError reports tool input that doesn't match the tool's declared
+// InputSchema. Its message is returned to the model as the tool result so it
+// can correct the call and retry.
+type ValidationError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid input for %s:\n- %s\nFix the input to match the tool's input schema and call it again.",
+		e.Tool, strings.Join(e.Problems, "\n- "))
+}
+
+// validateInput checks input against schema. It supports the subset of JSON
+// Schema the registry's tools use: type, properties, required, items, enum,
+// and additionalProperties: false.
+func validateInput(name string, schema provider.ToolInputSchema, input json.RawMessage) error {
+	if len(bytes.TrimSpace(input)) == 0 {
+		input = json.RawMessage("{}")
+	}
+
+	var value any
+	dec := json.NewDecoder(bytes.NewReader(input))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return &ValidationError{Tool: name, Problems: []string{"input is not valid JSON: " + err.Error()}}
+	}
+
+	root := map[string]any{"type": schema.Type, "properties": schema.Properties}
+	if len(schema.Required) > 0 {
+		root["required"] = schema.Required
+	}
+
+	var problems []string
+	validateValue("input", root, value, &problems)
+	if len(problems) > 0 {
+		return &ValidationError{Tool: name, Problems: problems}
+	}
+	return nil
+}
+
+func validateValue(path string, schema map[string]any, value any, problems *[]string) {
+	if t, ok := schema["type"].(string); ok && t != "" && !matchesType(t, value) {
+		*problems = append(*problems, fmt.Sprintf("%s: expected %s, got %s", path, t, jsonType(value)))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, fmt.Sprintf("%s: must be one of %v", path, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for _, req := range stringList(schema["required"]) {
+			if _, ok := v[req]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s.%s: required property missing", path, req))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			propSchema, ok := props[k].(map[string]any)
+			if !ok {
+				if ap, set := schema["additionalProperties"].(bool); set && !ap {
+					*problems = append(*problems, fmt.Sprintf("%s.%s: unknown property", path, k))
+				}
+				continue
+			}
+			validateValue(path+"."+k, propSchema, v[k], problems)
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				validateValue(fmt.Sprintf("%s[%d]", path, i), items, item, problems)
+			}
+		}
+	}
+}
+
+func matchesType(t string, value any) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	default:
+		return jsonType(value) == t
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func stringList(v any) []string {
+	switch l := v.(type) {
+	case []string:
+		return l
+	case []any:
+		out := make([]string, 0, len(l))
+		for _, s := range l {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+		return out
+	}
+	return nil
+}
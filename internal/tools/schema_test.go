@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+func TestValidateInput(t *testing.T) {
+	schema := provider.ToolInputSchema{
+		Type: "object",
+		Properties: map[string]any{
+			"path":  map[string]any{"type": "string"},
+			"count": map[string]any{"type": "integer"},
+			"ratio": map[string]any{"type": "number"},
+			"force": map[string]any{"type": "boolean"},
+			"mode":  map[string]any{"type": "string", "enum": []any{"r", "rw"}},
+			"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"opts": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"depth": map[string]any{"type": "integer"},
+				},
+				"required":             []any{"depth"},
+				"additionalProperties": false,
+			},
+			"extra": map[string]any{"type": "object"},
+		},
+		Required: []string{"path"},
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string // "" when valid, or "!" and the expected problems, one per line
+	}{
+		{"minimal", `{"path": "a.txt"}`, ""},
+		{"every property", `{"path": "a", "count": 2, "ratio": 0.5, "force": true, "mode": "rw", "tags": ["x"], "opts": {"depth": 1}, "extra": {"any": 1}}`, ""},
+		{"unknown top-level property", `{"path": "a", "other": 1}`, ""},
+		{"empty input", ``, "!input.path: required property missing"},
+		{"not JSON", `{"path":`, "!input is not valid JSON"},
+		{"not an object", `["a"]`, "!input: expected object, got array"},
+		{"missing required", `{"count": 1}`, "!input.path: required property missing"},
+		{"string", `{"path": 3}`, "!input.path: expected string, got number"},
+		{"integer", `{"path": "a", "count": 1.5}`, "!input.count: expected integer, got number"},
+		{"integer as string", `{"path": "a", "count": "1"}`, "!input.count: expected integer, got string"},
+		{"number", `{"path": "a", "ratio": "half"}`, "!input.ratio: expected number, got string"},
+		{"boolean", `{"path": "a", "force": "yes"}`, "!input.force: expected boolean, got string"},
+		{"null", `{"path": null}`, "!input.path: expected string, got null"},
+		{"enum", `{"path": "a", "mode": "w"}`, "!input.mode: must be one of [r rw]"},
+		{"array items", `{"path": "a", "tags": ["x", 2]}`, "!input.tags[1]: expected string, got number"},
+		{"nested required", `{"path": "a", "opts": {}}`, "!input.opts.depth: required property missing"},
+		{"nested type", `{"path": "a", "opts": {"depth": "deep"}}`, "!input.opts.depth: expected integer, got string"},
+		{"nested additionalProperties", `{"path": "a", "opts": {"depth": 1, "width": 2}}`, "!input.opts.width: unknown property"},
+		{"every problem", `{"count": "1", "mode": "x"}`, "!input.path: required property missing\ninput.count: expected integer, got string\ninput.mode: must be one of [r rw]"},
+	}
+	for _, tt := range tests {
+		err := validateInput("tool", schema, json.RawMessage(tt.input))
+		want, isErr := strings.CutPrefix(tt.want, "!")
+		if !isErr {
+			if err != nil {
+				t.Errorf("%s: validateInput = %v, want nil", tt.name, err)
+			}
+			continue
+		}
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s: validateInput = %v, want a *ValidationError", tt.name, err)
+			continue
+		}
+		if got := strings.Join(ve.Problems, "\n"); !strings.Contains(got, want) {
+			t.Errorf("%s: problems =\n%s\nwant\n%s", tt.name, got, want)
+		}
+	}
+}
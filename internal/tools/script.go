@@ -2,24 +2,33 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"encoding/json"
 	"fmt"
-	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/backend"
+	"github.com/thinkingscript/cli/internal/blobcache"
+	"github.com/thinkingscript/cli/internal/codecheck"
+	"github.com/thinkingscript/cli/internal/i18n"
 	"github.com/thinkingscript/cli/internal/provider"
 	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/trash"
 	"github.com/thinkingscript/cli/internal/ui"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type runScriptInput struct {
-	Code string `json:"code"`
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
 }
 
-func (r *Registry) registerScript(approver *approval.Approver, workDir, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, scriptName string) {
+func (r *Registry) registerScript(cfg RegistryConfig) {
+	approver := cfg.Approver
 	r.register(provider.ToolDefinition{
 		Name:        "run_script",
 		Description: "Execute JavaScript code in a sandboxed runtime. Has access to the filesystem (current directory read-only; workspace and memories read-write; memory.js read-write; other paths require user approval), HTTP, environment variables, and system info. Use this for all tasks: file I/O, data processing, HTTP requests, and transformations.",
@@ -30,6 +39,10 @@ func (r *Registry) registerScript(approver *approval.Approver, workDir, thoughtD
 					"type":        "string",
 					"description": "JavaScript code to execute. The last expression value is returned as the result.",
 				},
+				"reason": map[string]any{
+					"type":        "string",
+					"description": "Short description of what this script does (e.g. \"reading the project's go.mod\"). Shown to the user in approval prompts.",
+				},
 			},
 			Required: []string{"code"},
 		},
@@ -39,47 +52,178 @@ func (r *Registry) registerScript(approver *approval.Approver, workDir, thoughtD
 			return "", fmt.Errorf("parsing run_script input: %w", err)
 		}
 
-		memoriesPrefix := memoriesDir + string(filepath.Separator)
+		stdout, stderr, grouped := r.output(ctx)
+		memoriesPrefix := cfg.MemoriesDir + string(filepath.Separator)
 		dotStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("39")) // Cyan for script actions
 		detailStyle := ui.Renderer.NewStyle().Foreground(lipgloss.Color("245"))
 
+		confirm := func(question string) (bool, error) {
+			r.promptMu.Lock() // one question at a time when scripts run in parallel
+			defer r.promptMu.Unlock()
+			return approver.Confirm(question)
+		}
+		notes, err := r.linter.Review(args.Code, "this script", confirm)
+		if err != nil {
+			// The error goes back to the model as the tool result
+			return "", err
+		}
+		if r.check != nil {
+			v := r.check.Decide(ctx, codecheck.Request{
+				Tool:    "run_script",
+				Code:    args.Code,
+				Reason:  args.Reason,
+				Thought: filepath.Base(cfg.ThoughtDir),
+				Script:  cfg.ScriptName,
+				WorkDir: cfg.WorkDir,
+			})
+			for _, note := range v.Annotations {
+				fmt.Fprintf(stderr, "    %s %s\n", detailStyle.Render("code check:"), note)
+			}
+			if v.Decision == codecheck.Deny {
+				fmt.Fprintf(stderr, "    %s %s\n", deniedStyle.Render("✕ code check denied:"), v.Reason)
+				// The error goes back to the model as the tool result
+				return "", errors.New(checkDenial(v))
+			}
+			for _, note := range v.Annotations {
+				notes = append(notes, "code check: "+note)
+			}
+		}
+
+		activity := args.Reason
+		if activity == "" {
+			activity = "running: " + scriptSnippet(args.Code)
+		}
+		// Prompts show what this script is doing, even with others running alongside
+		approvePath := func(op, path string) (bool, error) {
+			return withActivity(r, approver, activity, args.Code, func() (bool, error) { return approver.ApprovePath(op, path) })
+		}
+		approveEnv := func(name string) (bool, error) {
+			return withActivity(r, approver, activity, args.Code, func() (bool, error) { return approver.ApproveEnvRead(name) })
+		}
+		approveNet := func(host string) (bool, error) {
+			return withActivity(r, approver, activity, args.Code, func() (bool, error) { return approver.ApproveNet(host) })
+		}
+		approveSecret := func(name string) (bool, error) {
+			return withActivity(r, approver, activity, args.Code, func() (bool, error) { return approver.ApproveSecret(name) })
+		}
+		var fetchedMu sync.Mutex
+		var fetched []string // URLs net.fetch returned, for the fetch guard
+		onFetch := func(url string) {
+			fetchedMu.Lock()
+			defer fetchedMu.Unlock()
+			fetched = append(fetched, url)
+		}
+		promptInput := func(question, defaultValue string) (string, error) {
+			return withActivity(r, approver, activity, args.Code, func() (string, error) { return approver.PromptInput(question, defaultValue) })
+		}
+
 		// SECURITY: Carefully control what paths are writable.
 		// - workspace, memories directories are writable
 		// - memory.js is writable as an EXACT file match
-		// - thoughtDir is readable but NOT writable (protects policy.json)
+		// - ThoughtDir is readable but NOT writable (protects policy.json)
+		// - --allow paths granted on the command line are readable, --write
+		//   paths readable and writable
 		// - Other paths go through ApprovePath
-		sb, err := sandbox.New(sandbox.Config{
-			AllowedPaths:  []string{workDir, thoughtDir, workspaceDir, memoriesDir},
-			WritablePaths: []string{workspaceDir, memoriesDir, memoryJSPath},
-			WorkDir:       workDir,
-			Stderr:        os.Stderr,
+		sbCfg := sandbox.Config{
+			AllowedPaths:  slices.Concat([]string{cfg.WorkDir, cfg.ThoughtDir, cfg.WorkspaceDir, cfg.MemoriesDir, cfg.MemoryJSPath}, cfg.AllowPaths, cfg.WritePaths),
+			WritablePaths: append([]string{cfg.WorkspaceDir, cfg.MemoriesDir, cfg.MemoryJSPath}, cfg.WritePaths...),
+			WorkDir:       cfg.WorkDir,
+			Stdout:        stdout,
+			Stderr:        stderr,
 			Timeout:       -1, // Disable timeout - user can Ctrl+C, and approval prompts would race with timer
-			ApprovePath:   approver.ApprovePath,
-			ApproveEnv:    approver.ApproveEnvRead,
-			ApproveNet:    approver.ApproveNet,
-			PromptInput:   approver.PromptInput,
+			ApprovePath:   approvePath,
+			PathDenied:    approver.PathDenied,
+			ApproveEnv:    approveEnv,
+			ApproveNet:    approveNet,
+			ApproveSecret: approveSecret,
+			PromptInput:   promptInput,
+			OnFetch:       onFetch,
+			ReadOnly:      cfg.ReadOnly,
+			TrashDir:      trash.Dir(cfg.ThoughtDir),
+			BlobCache:     blobcache.Dir(),
+			TrashExempt:   []string{cfg.WorkspaceDir},
+			Journal:       r.journal,
+			Workspace:     r.wsRun,
+			Eval:          r.eval,
+			Profile:       r.profile,
+			Redact:        r.redact,
+			RedactPaths:   []string{cfg.MemoriesDir},
+			Protected:     approver.ProtectedPaths(),
 			OnWrite: func(path, content string) {
-				if strings.HasPrefix(path, memoriesPrefix) {
+				r.recordWrite(path)
+				if content != "" && strings.HasPrefix(path, memoriesPrefix) {
 					name := filepath.Base(path)
-					fmt.Fprintf(os.Stderr, "\n  %s %s\n\n", dotStyle.Render("▸"), detailStyle.Render("memorizing "+name)) // Triangle for script actions
+					fmt.Fprintf(stderr, "\n  %s %s\n\n", dotStyle.Render("▸"), detailStyle.Render("memorizing "+name)) // Triangle for script actions
 					for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
-						fmt.Fprintf(os.Stderr, "  %s\n", detailStyle.Render(line))
+						fmt.Fprintf(stderr, "  %s\n", detailStyle.Render(line))
 					}
-					fmt.Fprintf(os.Stderr, "\n  %s\n", detailStyle.Render(path))
+					fmt.Fprintf(stderr, "\n  %s\n", detailStyle.Render(path))
 				}
 			},
-		})
-		if err != nil {
-			return "", fmt.Errorf("creating sandbox: %w", err)
+		}
+		b := r.backend
+		if b == nil {
+			b = backend.InProcess
 		}
 
-		fmt.Fprintln(os.Stderr) // blank line after code
-		stopSpinner := ui.Spinner("Running...")
-		result, err := sb.Run(ctx, args.Code)
+		fmt.Fprintln(stderr) // blank line after code
+		stopSpinner := func() {}
+		if !grouped { // ExecuteAll shows one spinner for the group
+			stopSpinner = ui.Spinner(i18n.T("spinner.running"))
+		}
+		result, err := b.Run(ctx, sbCfg, args.Code)
 		stopSpinner()
 		if err != nil {
 			return "", err
 		}
+		fetchedMu.Lock()
+		urls := fetched
+		fetchedMu.Unlock()
+		if result, err = r.fetchGuard.Review(result, urls, confirm); err != nil {
+			// The error goes back to the model as the tool result
+			return "", err
+		}
+		for _, note := range notes {
+			result += "\nnote: " + note
+		}
 		return result, nil
-	}, nil)
+	}, nil, SideEffects, Parallel)
+}
+
+// withActivity asks the approver through ask with activity and code shown
+// in its prompt. Scripts running in parallel take turns, so each prompt
+// describes the script that asked.
+func withActivity[T any](r *Registry, approver *approval.Approver, activity, code string, ask func() (T, error)) (T, error) {
+	r.promptMu.Lock()
+	defer r.promptMu.Unlock()
+	approver.SetActivity(activity, code)
+	defer approver.SetActivity("", "")
+	return ask()
+}
+
+var deniedStyle = ui.Renderer.NewStyle().Foreground(lipgloss.Color("196"))
+
+// checkDenial tells the model why its code was refused and how to go on.
+func checkDenial(v codecheck.Verdict) string {
+	msg := "this script was not run: the organization's code check denied it"
+	if v.Reason != "" {
+		msg += " (" + v.Reason + ")"
+	}
+	for _, note := range v.Annotations {
+		msg += "\n- " + note
+	}
+	return msg + "\nDo not retry the same code. Find an approach the check allows, or explain to the user what you needed."
+}
+
+// scriptSnippet returns the first meaningful line of code, for approval
+// prompt context when the agent gave no reason.
+func scriptSnippet(code string) string {
+	for _, line := range strings.Split(code, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		return line
+	}
+	return ""
 }
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+// Spawner runs task in a child agent limited to maxIterations turns and
+// maxTokens tokens (0 = its defaults) and returns the child's final answer.
+type Spawner func(ctx context.Context, task string, maxIterations, maxTokens int) (string, error)
+
+type spawnAgentInput struct {
+	Task          string `json:"task"`
+	MaxIterations int    `json:"max_iterations"`
+	MaxTokens     int    `json:"max_tokens"`
+}
+
+// SetSpawner adds the spawn_agent tool, which hands subtasks to spawn,
+// unless RegistryConfig.Tools leaves it out.
+func (r *Registry) SetSpawner(spawn Spawner) {
+	if !r.offers("spawn_agent") {
+		return
+	}
+	r.register(provider.ToolDefinition{
+		Name:        "spawn_agent",
+		Description: "Delegate a self-contained subtask to a sub-agent with its own conversation, e.g. \"summarize each of these 40 files\" or \"find which config file sets the port\". The sub-agent has run_script, with the same sandbox and permissions, but not write_stdout, and only its final answer comes back, so the intermediate data stays out of your context. It can't see your conversation: put everything it needs in the task, including paths and what to return in what format.",
+		InputSchema: provider.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]any{
+				"task": map[string]any{
+					"type":        "string",
+					"description": "The subtask, complete on its own: what to do, where, and what to answer with.",
+				},
+				"max_iterations": map[string]any{
+					"type":        "integer",
+					"description": "Most turns the sub-agent may take (default 20).",
+				},
+				"max_tokens": map[string]any{
+					"type":        "integer",
+					"description": "Most tokens the sub-agent's API calls may use, input plus output (default 200000).",
+				},
+			},
+			Required: []string{"task"},
+		},
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var args spawnAgentInput
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("parsing spawn_agent input: %w", err)
+		}
+		return spawn(ctx, args.Task, args.MaxIterations, args.MaxTokens)
+	}, nil, SideEffects, Sequential)
+}
+
+// Subset returns a registry with only the named tools, for a child agent.
+// The tools are r's own, so their approvals, settings, and recorded writes
+// are shared; duplicate detection and stats are the child's (AddStats
+// adds them to r's). Nothing the child's tools print reaches r's stdout,
+// the script's output: both of the child's streams are r's stderr.
+func (r *Registry) Subset(names ...string) *Registry {
+	_, stderr := r.writers()
+	child := &Registry{
+		regs:        make(map[string]registration),
+		seenIDs:     make(map[string]callResult),
+		turnSeen:    make(map[string]callResult),
+		parallelism: r.parallelism,
+		redact:      r.redact,
+		stdout:      stderr,
+		stderr:      stderr,
+	}
+	for _, name := range r.order {
+		for _, want := range names {
+			if name == want {
+				child.regs[name] = r.regs[name]
+				child.order = append(child.order, name)
+			}
+		}
+	}
+	return child
+}
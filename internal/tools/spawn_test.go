@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/approval"
+)
+
+func TestSubsetOutput(t *testing.T) {
+	dir := t.TempDir()
+	dir, _ = filepath.EvalSymlinks(dir)
+	approver := approval.NewApprover(filepath.Join(dir, "thought"), "")
+	defer approver.Close()
+
+	var stdout, stderr bytes.Buffer
+	parent := NewRegistry(RegistryConfig{
+		Approver:     approver,
+		WorkDir:      dir,
+		ThoughtDir:   filepath.Join(dir, "thought"),
+		WorkspaceDir: filepath.Join(dir, "workspace"),
+		MemoriesDir:  filepath.Join(dir, "memories"),
+		Tools:        []string{"run_script"},
+		Stdout:       &stdout,
+		Stderr:       &stderr,
+	})
+	child := parent.Subset("run_script")
+
+	input := json.RawMessage(`{"code": "process.stdout.write('from the sub-agent\\n'); console.log('logged')"}`)
+	if _, err := child.Execute(context.Background(), "1", "run_script", input); err != nil {
+		t.Fatal(err)
+	}
+	// Run alongside another call, output is held back and written after
+	second := json.RawMessage(`{"code": "process.stdout.write('grouped\\n')"}`)
+	for _, res := range child.ExecuteAll(context.Background(), []Call{{ID: "2", Name: "run_script", Input: input}, {ID: "3", Name: "run_script", Input: second}}) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("the sub-agent wrote %q to stdout", stdout.String())
+	}
+	for _, want := range []string{"from the sub-agent", "logged", "grouped"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("stderr = %q, want %q", stderr.String(), want)
+		}
+	}
+
+	// The parent's own calls still write to stdout
+	if _, err := parent.Execute(context.Background(), "4", "run_script", second); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "grouped\n" {
+		t.Errorf("parent stdout = %q", stdout.String())
+	}
+
+	parent.AddStats(child.Stats())
+	if got := parent.Stats().Calls; got != 4 {
+		t.Errorf("parent Calls = %d, want 4 (3 from the sub-agent)", got)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 
 	"github.com/thinkingscript/cli/internal/provider"
 )
@@ -32,10 +31,11 @@ func (r *Registry) registerStdio() {
 		if err := json.Unmarshal(input, &args); err != nil {
 			return "", fmt.Errorf("parsing write_stdout input: %w", err)
 		}
-		_, err := fmt.Fprint(os.Stdout, args.Content)
+		stdout, _ := r.writers()
+		_, err := fmt.Fprint(stdout, args.Content)
 		if err != nil {
 			return "", fmt.Errorf("writing to stdout: %w", err)
 		}
 		return "ok", nil
-	}, nil) // no approval needed
+	}, nil, SideEffects, Sequential) // no approval needed
 }
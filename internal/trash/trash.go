@@ -0,0 +1,229 @@
+// Package trash implements soft deletes for the sandbox. fs.delete moves
+// paths into a per-thought trash directory instead of removing them, and
+// `thought trash` lists, restores, or empties it.
+package trash
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Entry describes one trashed path. Each entry lives in its own directory
+// under the trash dir: meta.json plus the moved file or tree as "data".
+type Entry struct {
+	ID      string    `json:"id"`
+	Path    string    `json:"path"` // original absolute path
+	Deleted time.Time `json:"deleted"`
+	IsDir   bool      `json:"is_dir"`
+	Size    int64     `json:"size"`
+}
+
+// ErrNotFound is returned when no entry has the given ID.
+var ErrNotFound = errors.New("trash entry not found")
+
+// Dir returns the trash directory for a thought.
+func Dir(thoughtDir string) string {
+	return filepath.Join(thoughtDir, ".trash")
+}
+
+// Put moves path into the trash directory and records where it came from.
+func Put(trashDir, path string) (*Entry, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(trashDir, 0700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var id, entryDir string
+	for n := 0; ; n++ {
+		id = now.Format("20060102-150405") + fmt.Sprintf("-%d", n)
+		entryDir = filepath.Join(trashDir, id)
+		err := os.Mkdir(entryDir, 0700)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+
+	e := &Entry{ID: id, Path: path, Deleted: now, IsDir: info.IsDir(), Size: treeSize(path)}
+	if err := writeMeta(entryDir, e); err != nil {
+		os.RemoveAll(entryDir)
+		return nil, err
+	}
+	if err := move(path, filepath.Join(entryDir, "data")); err != nil {
+		os.RemoveAll(entryDir)
+		return nil, err
+	}
+	return e, nil
+}
+
+// List returns all entries, oldest first.
+func List(trashDir string) ([]Entry, error) {
+	dirs, err := os.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, d := range dirs {
+		e, err := readMeta(filepath.Join(trashDir, d.Name()))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Deleted.Before(entries[j].Deleted) })
+	return entries, nil
+}
+
+// Restore moves an entry back to its original path, or to "to" when set.
+// It refuses to overwrite an existing path.
+func Restore(trashDir, id, to string) (*Entry, error) {
+	entryDir := filepath.Join(trashDir, filepath.Base(id))
+	e, err := readMeta(entryDir)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	dest := e.Path
+	if to != "" {
+		dest = to
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return nil, fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, err
+	}
+	if err := move(filepath.Join(entryDir, "data"), dest); err != nil {
+		return nil, err
+	}
+	return e, os.RemoveAll(entryDir)
+}
+
+// Empty permanently removes entries deleted before the cutoff. A zero
+// cutoff removes everything. Returns the number of entries removed.
+func Empty(trashDir string, before time.Time) (int, error) {
+	entries, err := List(trashDir)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, e := range entries {
+		if !before.IsZero() && !e.Deleted.Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(trashDir, e.ID)); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func writeMeta(entryDir string, e *Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(entryDir, "meta.json"), data, 0600)
+}
+
+func readMeta(entryDir string) (*Entry, error) {
+	data, err := os.ReadFile(filepath.Join(entryDir, "meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func treeSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// move renames src to dst, falling back to copy-and-remove when they are on
+// different filesystems.
+func move(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
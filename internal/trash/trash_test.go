@@ -0,0 +1,94 @@
+package trash
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPutRestore(t *testing.T) {
+	dir := t.TempDir()
+	trashDir := Dir(filepath.Join(dir, "thought"))
+
+	tree := filepath.Join(dir, "data", "reports")
+	os.MkdirAll(filepath.Join(tree, "2024"), 0755)
+	os.WriteFile(filepath.Join(tree, "2024", "q1.csv"), []byte("a,b\n"), 0644)
+
+	e, err := Put(trashDir, tree)
+	if err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	if _, err := os.Stat(tree); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be gone after Put", tree)
+	}
+	if !e.IsDir || e.Size != 4 || e.Path != tree {
+		t.Errorf("entry = %+v, want dir of 4 bytes at %s", e, tree)
+	}
+
+	entries, err := List(trashDir)
+	if err != nil || len(entries) != 1 || entries[0].ID != e.ID {
+		t.Fatalf("List = %v, %v; want the one entry", entries, err)
+	}
+
+	if _, err := Restore(trashDir, e.ID, ""); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tree, "2024", "q1.csv"))
+	if err != nil || string(data) != "a,b\n" {
+		t.Errorf("restored content = %q, %v", data, err)
+	}
+	if entries, _ := List(trashDir); len(entries) != 0 {
+		t.Errorf("expected empty trash after restore, got %d entries", len(entries))
+	}
+
+	if _, err := Restore(trashDir, e.ID, ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Restore error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRestoreRefusesOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	trashDir := Dir(dir)
+	file := filepath.Join(dir, "notes.txt")
+	os.WriteFile(file, []byte("old"), 0644)
+
+	e, err := Put(trashDir, file)
+	if err != nil {
+		t.Fatalf("Put error: %v", err)
+	}
+	os.WriteFile(file, []byte("new"), 0644)
+
+	if _, err := Restore(trashDir, e.ID, ""); err == nil {
+		t.Fatal("expected Restore to refuse overwriting an existing file")
+	}
+	other := filepath.Join(dir, "sub", "notes-old.txt")
+	if _, err := Restore(trashDir, e.ID, other); err != nil {
+		t.Fatalf("Restore --to error: %v", err)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "old" {
+		t.Errorf("restored content = %q, want old", data)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	dir := t.TempDir()
+	trashDir := Dir(dir)
+	for _, name := range []string{"a", "b"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(name), 0644)
+		if _, err := Put(trashDir, path); err != nil {
+			t.Fatalf("Put error: %v", err)
+		}
+	}
+
+	n, err := Empty(trashDir, time.Now().Add(-time.Hour))
+	if err != nil || n != 0 {
+		t.Errorf("Empty(older than 1h) = %d, %v; want 0", n, err)
+	}
+	n, err = Empty(trashDir, time.Time{})
+	if err != nil || n != 2 {
+		t.Errorf("Empty(all) = %d, %v; want 2", n, err)
+	}
+}
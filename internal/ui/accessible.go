@@ -0,0 +1,38 @@
+package ui
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+var accessible = sync.OnceValue(func() bool {
+	switch strings.ToLower(os.Getenv("THINKINGSCRIPT__ACCESSIBLE")) {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	if config.LoadConfig().Accessible {
+		return true
+	}
+	switch os.Getenv("TERM") {
+	case "dumb", "unknown":
+		return true
+	case "":
+		return runtime.GOOS != "windows" // Windows consoles don't set TERM
+	}
+	return false
+})
+
+// Accessible reports whether prompts should be plain numbered text read a
+// line at a time, without cursor movement or live redraws, for screen
+// readers and limited terminals. THINKINGSCRIPT__ACCESSIBLE turns it on
+// (1) or off (0); otherwise it's on with config.json "accessible": true or
+// when TERM is dumb, unknown, or unset.
+func Accessible() bool {
+	return accessible()
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
@@ -12,11 +13,16 @@ import (
 var frames = [...]string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 
 // Spinner displays an animated spinner with a message on stderr.
-// Call the returned function to stop and clear the spinner.
+// Call the returned function to stop and clear the spinner. In
+// accessible mode the message is printed once instead.
 func Spinner(msg string) func() {
 	if !term.IsTerminal(int(os.Stderr.Fd())) {
 		return func() {}
 	}
+	if Accessible() {
+		fmt.Fprintln(os.Stderr, strings.TrimSpace(msg))
+		return func() {}
+	}
 
 	stop := make(chan struct{})
 	done := make(chan struct{})
@@ -0,0 +1,136 @@
+// Package usage records the tokens each run of a thought used, as counted
+// by a provider.Meter, in <thought>/runs/usage.json, and prices them with
+// internal/cost for the summary line `think` prints when a run ends.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/workspace"
+)
+
+// MaxRecords is how many runs usage.json keeps; older ones are dropped.
+const MaxRecords = 100
+
+// Record is one run's usage (an element of usage.json).
+type Record struct {
+	Started  time.Time `json:"started"`
+	Kind     string    `json:"kind"`   // agent, stream, or map
+	Status   string    `json:"status"` // ok or error
+	Duration string    `json:"duration"`
+	Calls    int       `json:"calls"`
+	provider.Usage
+	Cost   *float64              `json:"cost,omitempty"` // estimated dollars; nil when a model has no known price
+	Models []provider.ModelUsage `json:"models"`
+
+	// Tool calls whose input didn't match the tool's schema
+	SchemaFailures int `json:"schema_failures,omitempty"`
+
+	// With --profile: the sandbox runs and the bridge calls in them
+	Sandbox *sandbox.BridgeStat  `json:"sandbox,omitempty"`
+	Bridges []sandbox.BridgeStat `json:"bridges,omitempty"`
+}
+
+// NewRecord totals models and prices them. The cost is left out unless
+// every model with usage has a price.
+func NewRecord(models []provider.ModelUsage, prices map[string]cost.Price) Record {
+	rec := Record{Models: models}
+	dollars, priced := 0.0, true
+	for _, m := range models {
+		rec.Calls += m.Calls
+		rec.Usage = rec.Usage.Add(m.Usage)
+		if m.Usage == (provider.Usage{}) {
+			continue
+		}
+		if p, ok := cost.Lookup(m.Model, prices); ok {
+			dollars += p.Usage(m.Usage)
+		} else {
+			priced = false
+		}
+	}
+	if priced {
+		rec.Cost = &dollars
+	}
+	return rec
+}
+
+// Summary renders rec for the end of a run, e.g.
+// "12,034 tokens in (9,800 cached) · 1,502 out · 4 calls · est. $0.06".
+func (r Record) Summary() string {
+	calls := fmt.Sprintf("%d calls", r.Calls)
+	if r.Calls == 1 {
+		calls = "1 call"
+	}
+	if r.Usage == (provider.Usage{}) {
+		return calls // the provider reported no token counts
+	}
+	in := thousands(r.InputTokens) + " tokens in"
+	if r.CachedTokens > 0 {
+		in += " (" + thousands(r.CachedTokens) + " cached)"
+	}
+	s := fmt.Sprintf("%s · %s out · %s", in, thousands(r.OutputTokens), calls)
+	if r.Cost != nil {
+		s += " · est. " + cost.Format(*r.Cost)
+	}
+	return s
+}
+
+func thousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// Path returns a thought's usage.json.
+func Path(thoughtDir string) string {
+	return filepath.Join(workspace.RunsDir(thoughtDir), "usage.json")
+}
+
+// Load returns a thought's records, oldest first.
+func Load(thoughtDir string) ([]Record, error) {
+	data, err := os.ReadFile(Path(thoughtDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", Path(thoughtDir), err)
+	}
+	return records, nil
+}
+
+// Append adds rec to a thought's usage.json, keeping the last MaxRecords.
+// An unreadable file is started over rather than failing the run.
+func Append(thoughtDir string, rec Record) error {
+	records, _ := Load(thoughtDir)
+	records = append(records, rec)
+	if len(records) > MaxRecords {
+		records = records[len(records)-MaxRecords:]
+	}
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := Path(thoughtDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,63 @@
+package usage
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/cost"
+	"github.com/thinkingscript/cli/internal/provider"
+)
+
+func TestNewRecord(t *testing.T) {
+	models := []provider.ModelUsage{
+		{Model: "claude-haiku-4-5", Calls: 1, Usage: provider.Usage{InputTokens: 10000, OutputTokens: 500}},
+		{Model: "claude-sonnet-4-5-20250929", Calls: 3, Usage: provider.Usage{InputTokens: 2034, OutputTokens: 1002}},
+	}
+	rec := NewRecord(models, nil)
+	if rec.Calls != 4 || rec.InputTokens != 12034 || rec.OutputTokens != 1502 {
+		t.Errorf("totals = %d calls, %+v", rec.Calls, rec.Usage)
+	}
+	want := (10000*1 + 500*5 + 2034*3 + 1002*15) / 1e6
+	if rec.Cost == nil || math.Abs(*rec.Cost-want) > 1e-12 {
+		t.Errorf("cost = %v, want %v", rec.Cost, want)
+	}
+	if got := rec.Summary(); got != "12,034 tokens in · 1,502 out · 4 calls · est. $0.03" {
+		t.Errorf("Summary = %q", got)
+	}
+
+	// One unpriced model leaves the whole cost out, unless config prices it
+	models = append(models, provider.ModelUsage{Model: "llama3", Calls: 1, Usage: provider.Usage{InputTokens: 1, OutputTokens: 1}})
+	if rec := NewRecord(models, nil); rec.Cost != nil {
+		t.Errorf("cost with an unpriced model = %v, want nil", *rec.Cost)
+	}
+	if rec := NewRecord(models, map[string]cost.Price{"llama3": {}}); rec.Cost == nil {
+		t.Error("cost with a configured price = nil")
+	}
+
+	// Providers that report no usage
+	rec = NewRecord([]provider.ModelUsage{{Model: "llama3", Calls: 1}}, nil)
+	if got := rec.Summary(); got != "1 call" {
+		t.Errorf("Summary without usage = %q", got)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	if records, err := Load(dir); records != nil || err != nil {
+		t.Fatalf("Load with no file = %v, %v", records, err)
+	}
+	for i := range MaxRecords + 5 {
+		rec := Record{Started: time.Unix(int64(i), 0), Calls: i}
+		if err := Append(dir, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != MaxRecords || records[0].Calls != 5 || records[MaxRecords-1].Calls != MaxRecords+4 {
+		t.Errorf("kept %d records, %d..%d", len(records), records[0].Calls, records[len(records)-1].Calls)
+	}
+}
@@ -0,0 +1,221 @@
+// Package workspace implements per-run workspaces. A thought with
+// `workspace: per-run` in its frontmatter gets a fresh, empty workspace for
+// every run; scripts copy results into the persistent workspace with
+// fs.promote, and the copies only happen when the run succeeds.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frontmatter values for `workspace:`.
+const (
+	ModePersistent = "persistent" // the default: one workspace shared by all runs
+	ModePerRun     = "per-run"
+)
+
+// staleAfter is how old a leftover run directory (from a crashed run) must
+// be before NewRun removes it.
+const staleAfter = 24 * time.Hour
+
+// ErrNotPerRun is returned by Promote on a nil *Run.
+var ErrNotPerRun = errors.New("fs.promote requires `workspace: per-run` in the script frontmatter")
+
+// ValidMode reports whether mode is an accepted `workspace:` value.
+func ValidMode(mode string) bool {
+	return mode == "" || mode == ModePersistent || mode == ModePerRun
+}
+
+// RunsDir returns the directory holding a thought's per-run workspaces.
+func RunsDir(thoughtDir string) string {
+	return filepath.Join(thoughtDir, "runs")
+}
+
+// Run is one run's private workspace plus the paths promoted from it.
+// Safe for concurrent use.
+type Run struct {
+	dir        string
+	persistent string
+	forward    func(path string) (string, error) // set by Forward
+
+	mu       sync.Mutex
+	promoted []string // relative to dir, in promotion order
+}
+
+// NewRun creates a fresh workspace under RunsDir(thoughtDir). Results are
+// promoted into persistentDir. Run directories left behind by crashed runs
+// are cleaned up here.
+func NewRun(thoughtDir, persistentDir string) (*Run, error) {
+	runsDir := RunsDir(thoughtDir)
+	if err := os.MkdirAll(runsDir, 0700); err != nil {
+		return nil, err
+	}
+	if entries, err := os.ReadDir(runsDir); err == nil {
+		for _, e := range entries {
+			// Files here (usage.json) aren't run workspaces
+			if !e.IsDir() {
+				continue
+			}
+			if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > staleAfter {
+				os.RemoveAll(filepath.Join(runsDir, e.Name()))
+			}
+		}
+	}
+	dir, err := os.MkdirTemp(runsDir, time.Now().Format("20060102-150405-"))
+	if err != nil {
+		return nil, err
+	}
+	// Resolve symlinks so paths match what the sandbox sees (e.g. /tmp on macOS)
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+	return &Run{dir: dir, persistent: persistentDir}, nil
+}
+
+// Forward returns a Run for a sandbox in another process (a container)
+// whose workspace is dir: Promote calls promote, which relays the path to
+// the host's Run, which commits and closes the workspace. Only Dir and
+// Promote are meant to be used on it.
+func Forward(dir string, promote func(path string) (string, error)) *Run {
+	return &Run{dir: dir, forward: promote}
+}
+
+// Dir returns the run's workspace directory.
+func (r *Run) Dir() string {
+	return r.dir
+}
+
+// Persistent returns the workspace results are promoted into.
+func (r *Run) Persistent() string {
+	return r.persistent
+}
+
+// Promote marks path, which must be inside the run workspace, to be copied
+// to the same relative location in the persistent workspace when the run
+// succeeds. It returns that destination.
+func (r *Run) Promote(path string) (string, error) {
+	if r == nil {
+		return "", ErrNotPerRun
+	}
+	if r.forward != nil {
+		return r.forward(path)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.dir, path)
+	}
+	path = filepath.Clean(path)
+	rel, err := filepath.Rel(r.dir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("fs.promote: %s is not inside the run workspace %s", path, r.dir)
+	}
+	if _, err := os.Lstat(path); err != nil {
+		return "", fmt.Errorf("fs.promote: %s does not exist", path)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.promoted {
+		if p == rel {
+			return filepath.Join(r.persistent, rel), nil
+		}
+	}
+	r.promoted = append(r.promoted, rel)
+	return filepath.Join(r.persistent, rel), nil
+}
+
+// Promoted returns the promoted paths, relative to the run workspace.
+func (r *Run) Promoted() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.promoted...)
+}
+
+// Commit copies every promoted path into the persistent workspace,
+// replacing whatever is there, and returns the destinations. Paths removed
+// from the run workspace after being promoted are skipped.
+func (r *Run) Commit() ([]string, error) {
+	if r == nil {
+		return nil, nil
+	}
+	var done []string
+	for _, rel := range r.Promoted() {
+		src := filepath.Join(r.dir, rel)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(r.persistent, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return done, err
+		}
+		if err := os.RemoveAll(dst); err != nil {
+			return done, err
+		}
+		if err := copyTree(src, dst); err != nil {
+			return done, fmt.Errorf("promoting %s: %w", rel, err)
+		}
+		done = append(done, dst)
+	}
+	return done, nil
+}
+
+// Close removes the run workspace.
+func (r *Run) Close() error {
+	if r == nil {
+		return nil
+	}
+	return os.RemoveAll(r.dir)
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case d.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,127 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newRun(t *testing.T) (*Run, string) {
+	t.Helper()
+	thoughtDir := t.TempDir()
+	thoughtDir, _ = filepath.EvalSymlinks(thoughtDir)
+	persistent := filepath.Join(thoughtDir, "workspace")
+	os.MkdirAll(persistent, 0700)
+	run, err := NewRun(thoughtDir, persistent)
+	if err != nil {
+		t.Fatalf("NewRun: %v", err)
+	}
+	return run, persistent
+}
+
+func TestPromoteAndCommit(t *testing.T) {
+	run, persistent := newRun(t)
+	os.MkdirAll(filepath.Join(run.Dir(), "out", "deep"), 0700)
+	os.WriteFile(filepath.Join(run.Dir(), "out", "deep", "a.txt"), []byte("new"), 0644)
+	os.WriteFile(filepath.Join(run.Dir(), "result.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(run.Dir(), "scratch.txt"), []byte("tmp"), 0644)
+
+	// Stale content in the persistent workspace is replaced, not merged
+	os.MkdirAll(filepath.Join(persistent, "out"), 0700)
+	os.WriteFile(filepath.Join(persistent, "out", "old.txt"), []byte("old"), 0644)
+
+	for _, p := range []string{"out", filepath.Join(run.Dir(), "result.json"), "out"} {
+		if _, err := run.Promote(p); err != nil {
+			t.Fatalf("Promote(%s): %v", p, err)
+		}
+	}
+	if got := run.Promoted(); len(got) != 2 {
+		t.Errorf("Promoted() = %v, want 2 entries", got)
+	}
+
+	done, err := run.Commit()
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if len(done) != 2 {
+		t.Errorf("Commit copied %v, want 2 paths", done)
+	}
+	if data, _ := os.ReadFile(filepath.Join(persistent, "out", "deep", "a.txt")); string(data) != "new" {
+		t.Errorf("out/deep/a.txt = %q, want %q", data, "new")
+	}
+	if _, err := os.Stat(filepath.Join(persistent, "out", "old.txt")); !os.IsNotExist(err) {
+		t.Error("out/old.txt should have been replaced")
+	}
+	if _, err := os.Stat(filepath.Join(persistent, "result.json")); err != nil {
+		t.Error("result.json was not promoted")
+	}
+	if _, err := os.Stat(filepath.Join(persistent, "scratch.txt")); !os.IsNotExist(err) {
+		t.Error("scratch.txt was not promoted and should not be copied")
+	}
+
+	if err := run.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(run.Dir()); !os.IsNotExist(err) {
+		t.Error("run workspace was not removed")
+	}
+}
+
+func TestPromoteRejects(t *testing.T) {
+	run, persistent := newRun(t)
+	os.WriteFile(filepath.Join(persistent, "x.txt"), []byte("x"), 0644)
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing", "nope.txt"},
+		{"outside", filepath.Join(persistent, "x.txt")},
+		{"escape", "../workspace/x.txt"},
+		{"run root", run.Dir()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := run.Promote(tt.path); err == nil {
+				t.Errorf("Promote(%s) succeeded, want error", tt.path)
+			}
+		})
+	}
+
+	var none *Run
+	if _, err := none.Promote("x"); err != ErrNotPerRun {
+		t.Errorf("nil Run Promote err = %v, want ErrNotPerRun", err)
+	}
+}
+
+func TestNewRunCleansStale(t *testing.T) {
+	thoughtDir := t.TempDir()
+	stale := filepath.Join(RunsDir(thoughtDir), "old")
+	os.MkdirAll(stale, 0700)
+	past := time.Now().Add(-2 * staleAfter)
+	os.Chtimes(stale, past, past)
+	usage := filepath.Join(RunsDir(thoughtDir), "usage.json")
+	os.WriteFile(usage, []byte("[]"), 0600)
+	os.Chtimes(usage, past, past)
+
+	run, err := NewRun(thoughtDir, filepath.Join(thoughtDir, "workspace"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer run.Close()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("stale run workspace was not removed")
+	}
+	if _, err := os.Stat(usage); err != nil {
+		t.Error("usage.json was removed with the stale workspaces")
+	}
+}
+
+func TestValidMode(t *testing.T) {
+	for mode, want := range map[string]bool{"": true, "persistent": true, "per-run": true, "perrun": false} {
+		if got := ValidMode(mode); got != want {
+			t.Errorf("ValidMode(%q) = %v, want %v", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"errors"
+
+	"github.com/thinkingscript/cli/internal/approval"
+)
+
+// Request is something a thought wants that its policy leaves to a prompt.
+type Request struct {
+	Kind     string // "read", "write", "delete", "env", "net", "tool", or "secret"
+	Target   string // the path, variable, host, tool, or secret
+	Activity string // what the thought was doing, e.g. "running memory.js"
+}
+
+// Decision answers a Request.
+type Decision string
+
+const (
+	AllowOnce   Decision = "once"
+	AllowRun    Decision = "run"    // until the run ends
+	AllowHour   Decision = "hour"   // saved to the thought's policy.json for an hour
+	AllowAlways Decision = "always" // saved to the thought's policy.json
+	DenyOnce    Decision = "deny-once"
+	DenyAlways  Decision = "deny" // saved to the thought's policy.json
+)
+
+// ApproveFunc decides a Request. An error stops the run.
+type ApproveFunc func(Request) (Decision, error)
+
+// AllowAll approves every request for this run only. Use it for trusted
+// thoughts, such as in tests.
+func AllowAll(Request) (Decision, error) { return AllowOnce, nil }
+
+// ErrNoInput is returned to input.prompt when Options.Input is nil.
+var ErrNoInput = errors.New("no input available")
+
+// prompter answers the approver's prompts with the run's callbacks.
+type prompter struct {
+	approve ApproveFunc
+	input   func(question, defaultValue string) (string, error)
+}
+
+var _ approval.Prompter = prompter{}
+
+func (p prompter) Approve(kind, target, activity string) (string, string, error) {
+	if p.approve == nil {
+		return string(DenyOnce), "", nil
+	}
+	d, err := p.approve(Request{Kind: kind, Target: target, Activity: activity})
+	return string(d), "", err
+}
+
+func (p prompter) Input(question, defaultValue string) (string, error) {
+	if p.input == nil {
+		return "", ErrNoInput
+	}
+	return p.input(question, defaultValue)
+}
@@ -0,0 +1,337 @@
+// Package runtime runs thoughts from Go programs, in-process, without the
+// think CLI. A run goes the way `think script.md args...` does: memory.js
+// first, and the agent when memory.js hands over or doesn't exist yet, in
+// the same sandbox, with the same policy files, journal, managed policy,
+// code check, and lint.
+//
+// What the thought's policy doesn't decide goes to Options.Approve instead
+// of a terminal prompt. Output goes to Options.Stdout and Options.Stderr;
+// the agent's progress display still goes to os.Stderr.
+//
+// The CLI's conveniences are left out: memoized output, git history,
+// workspace snapshots, run reports and usage records, model routes, cost
+// confirmation, shared thoughts (thought_path), sessions, and container
+// backends.
+package runtime
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/thinkingscript/cli/internal/agent"
+	"github.com/thinkingscript/cli/internal/approval"
+	"github.com/thinkingscript/cli/internal/boot"
+	"github.com/thinkingscript/cli/internal/codecheck"
+	"github.com/thinkingscript/cli/internal/config"
+	"github.com/thinkingscript/cli/internal/fetchguard"
+	"github.com/thinkingscript/cli/internal/journal"
+	"github.com/thinkingscript/cli/internal/lint"
+	"github.com/thinkingscript/cli/internal/lockfile"
+	"github.com/thinkingscript/cli/internal/managed"
+	"github.com/thinkingscript/cli/internal/provider"
+	"github.com/thinkingscript/cli/internal/redact"
+	"github.com/thinkingscript/cli/internal/sandbox"
+	"github.com/thinkingscript/cli/internal/script"
+	"github.com/thinkingscript/cli/internal/tools"
+	"github.com/thinkingscript/cli/internal/workspace"
+)
+
+// ErrHandedOver is returned by Run with Options.NoAgent when memory.js
+// didn't handle the run; Result.ResumeContext says why.
+var ErrHandedOver = errors.New("memory.js handed the run over to the agent")
+
+// ErrFrozen is returned by Run when memory.js didn't handle the run of a
+// thought frozen with 'thought freeze', which never starts the agent;
+// Result.ResumeContext says why.
+var ErrFrozen = errors.New("thought is frozen; not starting the agent")
+
+// Options configure a run. The zero value runs in the current directory
+// with the process's stdout and stderr and denies whatever the thought's
+// policy doesn't allow.
+type Options struct {
+	Args    []string  // the thought's arguments
+	Stdin   string    // given to the agent as the CLI gives piped stdin
+	WorkDir string    // "" = the frontmatter workdir, else the current directory
+	Stdout  io.Writer // memory.js and write_stdout output; nil = os.Stdout
+	Stderr  io.Writer // console.log output; nil = os.Stderr
+
+	// Approve decides requests the policy leaves to a prompt; nil denies
+	// them. Input answers input.prompt; nil = no input available.
+	Approve ApproveFunc
+	Input   func(question, defaultValue string) (string, error)
+
+	ReadOnly bool // reject every write, including memory.js
+	NoAgent  bool // stop with ErrHandedOver instead of starting the agent
+}
+
+// Result describes how a run went.
+type Result struct {
+	Agent         bool   // the agent ran because memory.js didn't handle the run
+	ResumeContext string // why memory.js handed over; "" when it didn't
+}
+
+// Run runs the thought in the script at path with args in opts.
+func Run(ctx context.Context, path string, opts Options) (*Result, error) {
+	stdout := cmp.Or[io.Writer](opts.Stdout, os.Stdout)
+	stderr := cmp.Or[io.Writer](opts.Stderr, os.Stderr)
+
+	parsed, err := script.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	fm := parsed.Config
+	if fm == nil {
+		fm = &config.ScriptConfig{}
+	}
+	if err := config.ValidateSampling(fm); err != nil {
+		return nil, err
+	}
+	resolved := config.Resolve(parsed.Config)
+	if err := config.EnsureHomeDir(); err != nil {
+		return nil, fmt.Errorf("setting up home directory: %w", err)
+	}
+
+	// Directories, as the CLI lays them out
+	if fm.Name != "" {
+		if err := config.ValidateThoughtName(fm.Name); err != nil {
+			return nil, err
+		}
+	}
+	located, _ := config.LocateThought(parsed.Path, fm.Name)
+	thoughtDir, _ := filepath.Abs(located)
+	workDir := opts.WorkDir
+	if workDir == "" {
+		if workDir, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+		if fm.WorkDir != "" {
+			if workDir, err = config.ResolveScriptWorkDir(fm.WorkDir, parsed.Path, thoughtDir); err != nil {
+				return nil, err
+			}
+		}
+	}
+	dataDir := thoughtDir
+	if fm.DataDir != "" {
+		if dataDir, err = config.ResolveDataDir(fm.DataDir, parsed.Path, thoughtDir); err != nil {
+			return nil, err
+		}
+	}
+	workspaceDir := filepath.Join(dataDir, "workspace")
+	memoriesDir := filepath.Join(dataDir, "memories")
+	memoryJSPath := filepath.Join(dataDir, "memory.js")
+	os.MkdirAll(workspaceDir, 0700)
+	os.MkdirAll(memoriesDir, 0700)
+	if err := config.ClaimThought(thoughtDir, parsed.Path); err != nil {
+		fmt.Fprintf(stderr, "warning: failed to record thought identity: %v\n", err)
+	}
+
+	if !workspace.ValidMode(fm.Workspace) {
+		return nil, fmt.Errorf("invalid workspace %q (must be %q or %q)", fm.Workspace, workspace.ModePersistent, workspace.ModePerRun)
+	}
+	if !sandbox.ValidEval(fm.Eval) {
+		return nil, fmt.Errorf("invalid eval %q (must be %q, %q, or %q)", fm.Eval, sandbox.EvalAfterFetch, sandbox.EvalAllow, sandbox.EvalDeny)
+	}
+	persistentDir := workspaceDir
+	var wsRun *workspace.Run
+	if fm.Workspace == workspace.ModePerRun {
+		if wsRun, err = workspace.NewRun(thoughtDir, persistentDir); err != nil {
+			return nil, fmt.Errorf("creating run workspace: %w", err)
+		}
+		defer wsRun.Close()
+		workspaceDir = wsRun.Dir()
+	}
+
+	approver, err := newApprover(ctx, parsed.Path, thoughtDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer approver.Close()
+	approver.BootstrapDefaults(persistentDir, memoriesDir, workDir)
+
+	codeCheck, err := codecheck.FromConfig(config.LoadConfig().CodeCheck)
+	if err != nil {
+		return nil, err
+	}
+	linter, err := lint.New(config.LoadConfig().Lint)
+	if err != nil {
+		return nil, err
+	}
+	fetchGuard, err := fetchguard.FromConfig(config.LoadConfig().FetchGuard)
+	if err != nil {
+		return nil, err
+	}
+	jrnl := journal.New(thoughtDir)
+	redactions := redact.New()
+
+	res := boot.TryMemoryJS(ctx, boot.Config{
+		MemoryJSPath:  memoryJSPath,
+		LockPath:      lockfile.Path(dataDir),
+		WorkDir:       workDir,
+		ThoughtDir:    thoughtDir,
+		WorkspaceDir:  workspaceDir,
+		MemoriesDir:   memoriesDir,
+		Args:          opts.Args,
+		ApprovePath:   approver.ApprovePath,
+		PathDenied:    approver.PathDenied,
+		ApproveEnv:    approver.ApproveEnvRead,
+		ApproveNet:    approver.ApproveNet,
+		ApproveSecret: approver.ApproveSecret,
+		Journal:       jrnl,
+		Workspace:     wsRun,
+		ReadOnly:      opts.ReadOnly,
+		Eval:          fm.Eval,
+		Stdout:        stdout,
+		Stderr:        stderr,
+		Redact:        redactions,
+		Protected:     approver.ProtectedPaths(),
+		Review: func(code string) error {
+			_, err := linter.Review(code, "memory.js", approver.Confirm)
+			return err
+		},
+	})
+	if res.Success {
+		if res.Output != "" {
+			fmt.Fprint(stdout, res.Output)
+		}
+		return &Result{}, commit(wsRun, nil)
+	}
+	result := &Result{ResumeContext: res.ResumeContext}
+	if config.LoadFrozen(thoughtDir) != nil {
+		return result, ErrFrozen
+	}
+	if opts.NoAgent {
+		return result, ErrHandedOver
+	}
+	result.Agent = true
+
+	registry := tools.NewRegistry(tools.RegistryConfig{
+		Approver:     approver,
+		WorkDir:      workDir,
+		ThoughtDir:   thoughtDir,
+		WorkspaceDir: workspaceDir,
+		MemoriesDir:  memoriesDir,
+		MemoryJSPath: memoryJSPath,
+		ScriptName:   parsed.Path,
+		ReadOnly:     opts.ReadOnly,
+		Stdout:       stdout,
+		Stderr:       stderr,
+	})
+	registry.SetJournal(jrnl)
+	registry.SetWorkspaceRun(wsRun)
+	registry.SetCodeCheck(codeCheck)
+	registry.SetLinter(linter)
+	registry.SetFetchGuard(fetchGuard)
+	registry.SetEval(fm.Eval)
+	registry.SetRedact(redactions)
+	registry.SetParallelism(resolved.ParallelTools)
+	if len(fm.MCP) > 0 {
+		servers, err := tools.ConnectMCP(ctx, approver, fm.MCP, workDir)
+		if err != nil {
+			return result, err
+		}
+		defer servers.Close()
+		registry.SetMCP(servers)
+	}
+
+	p, err := newProvider(ctx, resolved, stderr)
+	if err != nil {
+		return result, err
+	}
+	prompt := parsed.Prompt
+	if opts.Stdin != "" {
+		prompt += "\n\nStdin:\n" + opts.Stdin
+	}
+	if len(opts.Args) > 0 {
+		prompt += "\n\nArguments: " + strings.Join(opts.Args, " ")
+	}
+	a := agent.New(p, registry, resolved.Model, resolved.MaxTokens, resolved.MaxIterations, parsed.Path, thoughtDir, workspaceDir, memoriesDir, memoryJSPath, "persist", res.ResumeContext, opts.ReadOnly)
+	if wsRun != nil {
+		a.SetPerRunWorkspace(persistentDir)
+	}
+	a.SetContextLimit(resolved.ContextLimit)
+	a.SetPruning(resolved.PruneAfter, resolved.PruneKeep, resolved.PruneMinChars)
+	a.SetSampling(resolved.Temperature, resolved.TopP, resolved.Stop)
+	err = a.Run(ctx, prompt)
+	return result, commit(wsRun, err)
+}
+
+// commit promotes a per-run workspace's results when the run succeeded.
+func commit(wsRun *workspace.Run, runErr error) error {
+	if runErr != nil || wsRun == nil {
+		return runErr
+	}
+	if _, err := wsRun.Commit(); err != nil {
+		return fmt.Errorf("promoting workspace results: %w", err)
+	}
+	return nil
+}
+
+// newApprover sets up the thought's policy checks: its policy.json, the
+// global and managed policies, and trust defaults for where it came from,
+// with prompts going to opts.Approve.
+func newApprover(ctx context.Context, scriptPath, thoughtDir string, opts Options) (*approval.Approver, error) {
+	globalPolicyPath, _ := filepath.Abs(filepath.Join(config.HomeDir(), "policy.json"))
+	approver := approval.NewApprover(thoughtDir, globalPolicyPath)
+	approver.SetContext(ctx)
+	approver.SetPrompter(prompter{opts.Approve, opts.Input})
+
+	settings, err := managed.FromConfig(config.LoadConfig())
+	if err != nil {
+		approver.Close()
+		return nil, fmt.Errorf("managed policy: %w", err)
+	}
+	if settings != nil {
+		// As with the CLI, a run never goes ahead unmanaged
+		policy, _, err := managed.Load(ctx, settings)
+		if policy == nil {
+			approver.Close()
+			return nil, fmt.Errorf("managed policy unavailable: %w", err)
+		}
+		approver.SetManagedPolicy(policy)
+	}
+
+	trust := config.TrustFor(config.ResolveOrigin(scriptPath, thoughtDir))
+	approver.SetOriginDefaults(approval.OriginDefaults{
+		Paths: approval.Approval(trust.Paths),
+		Env:   approval.Approval(trust.Env),
+		Net:   approval.Approval(trust.Net),
+	})
+	return approver, nil
+}
+
+// newProvider creates the agent's provider with the CLI's retries and
+// fallback models, reporting them on stderr.
+func newProvider(ctx context.Context, resolved *config.ResolvedConfig, stderr io.Writer) (provider.Provider, error) {
+	if resolved.CredentialHelper != "" {
+		key, err := config.FetchCredential(ctx, resolved.CredentialHelper, resolved.Agent, resolved.Provider)
+		if err != nil {
+			return nil, err
+		}
+		resolved.APIKey = key
+	}
+	p, err := provider.FromConfig(resolved)
+	if err != nil {
+		return nil, err
+	}
+	attempts := cmp.Or(resolved.RetryAttempts, provider.DefaultRetryAttempts)
+	p = provider.NewRetry(p, provider.RetryConfig{
+		Attempts: attempts,
+		MaxWait:  cmp.Or(resolved.RetryMaxWait, provider.DefaultRetryMaxWait),
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			fmt.Fprintf(stderr, "%s; retrying in %s (attempt %d of %d)\n", err, wait.Round(100*time.Millisecond), attempt+1, attempts)
+		},
+	})
+	if len(resolved.FallbackModels) > 0 {
+		p = provider.NewFallback(p, resolved.FallbackModels, func(from, to string, err error) {
+			fmt.Fprintf(stderr, "%s; %s failed, trying %s\n", err, from, to)
+		})
+	}
+	return p, nil
+}
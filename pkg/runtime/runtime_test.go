@@ -0,0 +1,112 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingscript/cli/internal/config"
+)
+
+// setup installs a thought named "greet" with memory.js code in a fresh
+// home and returns its script path.
+func setup(t *testing.T, code string) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("THINKINGSCRIPT_HOME", home)
+	thoughtDir := filepath.Join(home, "thoughts", "greet")
+	if err := os.MkdirAll(thoughtDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if code != "" {
+		if err := os.WriteFile(filepath.Join(thoughtDir, "memory.js"), []byte(code), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "greet.md")
+	if err := os.WriteFile(path, []byte("---\nname: greet\n---\nGreet whoever is named in the arguments.\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunMemoryJS(t *testing.T) {
+	path := setup(t, `process.stdout.write("hello "); "" + process.args.join(",")`)
+	var stdout bytes.Buffer
+	res, err := Run(context.Background(), path, Options{Args: []string{"a", "b"}, WorkDir: t.TempDir(), Stdout: &stdout, NoAgent: true})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res.Agent {
+		t.Error("Agent = true, want memory.js to handle the run")
+	}
+	if got := stdout.String(); got != "hello a,b" {
+		t.Errorf("stdout = %q, want %q", got, "hello a,b")
+	}
+}
+
+func TestRunApprove(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(secret, []byte("s3cret"), 0600)
+	path := setup(t, `fs.readFile("`+secret+`")`)
+
+	var asked []Request
+	approve := func(d Decision) ApproveFunc {
+		return func(r Request) (Decision, error) {
+			asked = append(asked, r)
+			return d, nil
+		}
+	}
+
+	var stdout bytes.Buffer
+	if _, err := Run(context.Background(), path, Options{WorkDir: t.TempDir(), Stdout: &stdout, Approve: approve(AllowOnce), NoAgent: true}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if stdout.String() != "s3cret" {
+		t.Errorf("stdout = %q, want the file's contents", stdout.String())
+	}
+	if len(asked) != 1 || asked[0].Kind != "read" || !strings.HasSuffix(asked[0].Target, "secret.txt") {
+		t.Errorf("asked = %+v, want one read of secret.txt", asked)
+	}
+
+	// Denied, memory.js fails and hands over
+	asked = nil
+	res, err := Run(context.Background(), path, Options{WorkDir: t.TempDir(), Stdout: &stdout, Approve: approve(DenyOnce), NoAgent: true})
+	if !errors.Is(err, ErrHandedOver) {
+		t.Fatalf("err = %v, want ErrHandedOver", err)
+	}
+	if len(asked) != 1 || res.ResumeContext == "" {
+		t.Errorf("asked = %+v, ResumeContext = %q; want one prompt and a reason", asked, res.ResumeContext)
+	}
+}
+
+func TestRunNoMemoryJS(t *testing.T) {
+	path := setup(t, "")
+	res, err := Run(context.Background(), path, Options{WorkDir: t.TempDir(), NoAgent: true})
+	if !errors.Is(err, ErrHandedOver) {
+		t.Fatalf("err = %v, want ErrHandedOver", err)
+	}
+	if res.Agent || !strings.Contains(res.ResumeContext, "no memory.js") {
+		t.Errorf("res = %+v, want a first-run handover", res)
+	}
+}
+
+func TestRunFrozen(t *testing.T) {
+	path := setup(t, `agent.resume("stale")`)
+	thoughtDir := filepath.Join(os.Getenv("THINKINGSCRIPT_HOME"), "thoughts", "greet")
+	if err := config.Freeze(thoughtDir); err != nil {
+		t.Fatal(err)
+	}
+	// Without NoAgent: freezing alone keeps the agent from starting
+	res, err := Run(context.Background(), path, Options{WorkDir: t.TempDir()})
+	if !errors.Is(err, ErrFrozen) {
+		t.Fatalf("err = %v, want ErrFrozen", err)
+	}
+	if res.Agent || !strings.Contains(res.ResumeContext, "stale") {
+		t.Errorf("res = %+v, want memory.js's handover without the agent", res)
+	}
+}